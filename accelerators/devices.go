@@ -0,0 +1,42 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package accelerators
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Major number of the NVIDIA character devices.
+const nvidiaMajor = 195
+
+// Returns the minor numbers of the NVIDIA GPUs a devices cgroup allows, from its devices.list.
+// Containers allowed all devices (a *:* rwm), like the root cgroup, are not attributed any GPU.
+// e.g.: c 195:0 rwm
+func parseNvidiaDevices(devicesList string) []int {
+	var minors []int
+	for _, line := range strings.Split(devicesList, "\n") {
+		var major, minor int
+		var access string
+		if n, err := fmt.Sscanf(strings.TrimSpace(line), "c %d:%d %s", &major, &minor, &access); err != nil || n != 3 {
+			continue
+		}
+		// nvidiactl and nvidia-modeset use the last minor numbers.
+		if major == nvidiaMajor && minor < 254 {
+			minors = append(minors, minor)
+		}
+	}
+	return minors
+}
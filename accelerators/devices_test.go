@@ -0,0 +1,36 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package accelerators
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseNvidiaDevices(t *testing.T) {
+	devicesList := `c 1:5 rwm
+c 195:255 rw
+c 195:0 rw
+c 195:2 rwm
+c 195:* m
+b 8:0 r
+`
+	if minors := parseNvidiaDevices(devicesList); !reflect.DeepEqual(minors, []int{0, 2}) {
+		t.Errorf("expected GPUs 0 and 2, got %v", minors)
+	}
+	if minors := parseNvidiaDevices("a *:* rwm\n"); minors != nil {
+		t.Errorf("expected no GPU with all devices allowed, got %v", minors)
+	}
+}
@@ -0,0 +1,211 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+// Reports the usage of the NVIDIA GPUs of containers through NVML.
+// libnvidia-ml is loaded at runtime so that cAdvisor runs on machines without it.
+package accelerators
+
+/*
+#cgo LDFLAGS: -ldl
+#include <dlfcn.h>
+#include <stddef.h>
+
+typedef int nvmlReturn_t;
+typedef struct nvmlDevice_st *nvmlDevice_t;
+typedef struct {
+	unsigned long long total;
+	unsigned long long free;
+	unsigned long long used;
+} nvmlMemory_t;
+typedef struct {
+	unsigned int gpu;
+	unsigned int memory;
+} nvmlUtilization_t;
+
+static nvmlReturn_t (*nvmlInitFunc)(void);
+static nvmlReturn_t (*nvmlDeviceGetCountFunc)(unsigned int *);
+static nvmlReturn_t (*nvmlDeviceGetHandleByIndexFunc)(unsigned int, nvmlDevice_t *);
+static nvmlReturn_t (*nvmlDeviceGetMinorNumberFunc)(nvmlDevice_t, unsigned int *);
+static nvmlReturn_t (*nvmlDeviceGetNameFunc)(nvmlDevice_t, char *, unsigned int);
+static nvmlReturn_t (*nvmlDeviceGetUUIDFunc)(nvmlDevice_t, char *, unsigned int);
+static nvmlReturn_t (*nvmlDeviceGetMemoryInfoFunc)(nvmlDevice_t, nvmlMemory_t *);
+static nvmlReturn_t (*nvmlDeviceGetUtilizationRatesFunc)(nvmlDevice_t, nvmlUtilization_t *);
+
+// Loads and initializes NVML, returns -1 when libnvidia-ml is missing.
+static int nvmlLoad(void) {
+	void *handle = dlopen("libnvidia-ml.so.1", RTLD_LAZY | RTLD_GLOBAL);
+	if (handle == NULL) {
+		return -1;
+	}
+	nvmlInitFunc = dlsym(handle, "nvmlInit_v2");
+	nvmlDeviceGetCountFunc = dlsym(handle, "nvmlDeviceGetCount_v2");
+	nvmlDeviceGetHandleByIndexFunc = dlsym(handle, "nvmlDeviceGetHandleByIndex_v2");
+	nvmlDeviceGetMinorNumberFunc = dlsym(handle, "nvmlDeviceGetMinorNumber");
+	nvmlDeviceGetNameFunc = dlsym(handle, "nvmlDeviceGetName");
+	nvmlDeviceGetUUIDFunc = dlsym(handle, "nvmlDeviceGetUUID");
+	nvmlDeviceGetMemoryInfoFunc = dlsym(handle, "nvmlDeviceGetMemoryInfo");
+	nvmlDeviceGetUtilizationRatesFunc = dlsym(handle, "nvmlDeviceGetUtilizationRates");
+	if (nvmlInitFunc == NULL || nvmlDeviceGetCountFunc == NULL || nvmlDeviceGetHandleByIndexFunc == NULL ||
+	    nvmlDeviceGetMinorNumberFunc == NULL || nvmlDeviceGetNameFunc == NULL || nvmlDeviceGetUUIDFunc == NULL ||
+	    nvmlDeviceGetMemoryInfoFunc == NULL || nvmlDeviceGetUtilizationRatesFunc == NULL) {
+		return -1;
+	}
+	return nvmlInitFunc();
+}
+
+static nvmlReturn_t nvmlGetCount(unsigned int *count) {
+	return nvmlDeviceGetCountFunc(count);
+}
+
+static nvmlReturn_t nvmlGetHandle(unsigned int index, nvmlDevice_t *device) {
+	return nvmlDeviceGetHandleByIndexFunc(index, device);
+}
+
+static nvmlReturn_t nvmlGetMinor(nvmlDevice_t device, unsigned int *minor) {
+	return nvmlDeviceGetMinorNumberFunc(device, minor);
+}
+
+static nvmlReturn_t nvmlGetName(nvmlDevice_t device, char *name, unsigned int length) {
+	return nvmlDeviceGetNameFunc(device, name, length);
+}
+
+static nvmlReturn_t nvmlGetUUID(nvmlDevice_t device, char *uuid, unsigned int length) {
+	return nvmlDeviceGetUUIDFunc(device, uuid, length);
+}
+
+static nvmlReturn_t nvmlGetMemory(nvmlDevice_t device, nvmlMemory_t *memory) {
+	return nvmlDeviceGetMemoryInfoFunc(device, memory);
+}
+
+static nvmlReturn_t nvmlGetUtilization(nvmlDevice_t device, nvmlUtilization_t *utilization) {
+	return nvmlDeviceGetUtilizationRatesFunc(device, utilization);
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+	"unsafe"
+
+	"github.com/golang/glog"
+	"github.com/google/cadvisor/info"
+)
+
+const (
+	pciDevicesDir = "/sys/bus/pci/devices"
+	nvidiaVendor  = "0x10de"
+
+	// Large enough for the names and UUIDs of NVML.
+	nvmlStringLength = 96
+)
+
+type nvidiaDevice struct {
+	model  string
+	uuid   string
+	device C.nvmlDevice_t
+}
+
+// NVIDIA GPUs by minor number, found by Setup.
+var nvidiaDevices map[int]nvidiaDevice
+
+// Finds the NVIDIA GPUs of the machine. Without any, NVML is never loaded.
+func Setup() error {
+	if !hasNvidiaDevices() {
+		return fmt.Errorf("no NVIDIA devices found")
+	}
+	if ret := C.nvmlLoad(); ret != 0 {
+		return fmt.Errorf("failed to initialize NVML (%d), is libnvidia-ml.so.1 installed?", ret)
+	}
+	var count C.uint
+	if ret := C.nvmlGetCount(&count); ret != 0 {
+		return fmt.Errorf("failed to count NVIDIA devices (%d)", ret)
+	}
+	devices := make(map[int]nvidiaDevice, int(count))
+	for i := C.uint(0); i < count; i++ {
+		var device C.nvmlDevice_t
+		var minor C.uint
+		if ret := C.nvmlGetHandle(i, &device); ret != 0 {
+			return fmt.Errorf("failed to get NVIDIA device %d (%d)", i, ret)
+		}
+		if ret := C.nvmlGetMinor(device, &minor); ret != 0 {
+			return fmt.Errorf("failed to get the minor number of NVIDIA device %d (%d)", i, ret)
+		}
+		var name, uuid [nvmlStringLength]C.char
+		C.nvmlGetName(device, &name[0], nvmlStringLength)
+		C.nvmlGetUUID(device, &uuid[0], nvmlStringLength)
+		devices[int(minor)] = nvidiaDevice{
+			model:  C.GoString((*C.char)(unsafe.Pointer(&name[0]))),
+			uuid:   C.GoString((*C.char)(unsafe.Pointer(&uuid[0]))),
+			device: device,
+		}
+	}
+	nvidiaDevices = devices
+	glog.Infof("Found %d NVIDIA devices", len(devices))
+	return nil
+}
+
+func hasNvidiaDevices() bool {
+	entries, err := ioutil.ReadDir(pciDevicesDir)
+	if err != nil {
+		return false
+	}
+	for _, entry := range entries {
+		vendor, err := ioutil.ReadFile(path.Join(pciDevicesDir, entry.Name(), "vendor"))
+		if err == nil && strings.TrimSpace(string(vendor)) == nvidiaVendor {
+			return true
+		}
+	}
+	return false
+}
+
+// Get the stats of the GPUs the devices cgroup at the specified path has access to.
+func GetStats(devicesPath string) ([]info.AcceleratorStats, error) {
+	if len(nvidiaDevices) == 0 {
+		return nil, nil
+	}
+	out, err := ioutil.ReadFile(path.Join(devicesPath, "devices.list"))
+	if err != nil {
+		return nil, err
+	}
+	var stats []info.AcceleratorStats
+	for _, minor := range parseNvidiaDevices(string(out)) {
+		device, ok := nvidiaDevices[minor]
+		if !ok {
+			continue
+		}
+		var memory C.nvmlMemory_t
+		if ret := C.nvmlGetMemory(device.device, &memory); ret != 0 {
+			return nil, fmt.Errorf("failed to get the memory of NVIDIA device %s (%d)", device.uuid, ret)
+		}
+		var utilization C.nvmlUtilization_t
+		if ret := C.nvmlGetUtilization(device.device, &utilization); ret != 0 {
+			return nil, fmt.Errorf("failed to get the utilization of NVIDIA device %s (%d)", device.uuid, ret)
+		}
+		stats = append(stats, info.AcceleratorStats{
+			Make:        "nvidia",
+			Model:       device.model,
+			Id:          device.uuid,
+			MemoryTotal: uint64(memory.total),
+			MemoryUsed:  uint64(memory.used),
+			DutyCycle:   uint64(utilization.gpu),
+		})
+	}
+	return stats, nil
+}
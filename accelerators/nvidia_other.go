@@ -0,0 +1,33 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !linux || !cgo
+// +build !linux !cgo
+
+package accelerators
+
+import (
+	"fmt"
+
+	"github.com/google/cadvisor/info"
+)
+
+// The stats of NVIDIA GPUs are read through NVML, with cgo on Linux.
+func Setup() error {
+	return fmt.Errorf("GPU stats are only collected on Linux, with cgo")
+}
+
+func GetStats(devicesPath string) ([]info.AcceleratorStats, error) {
+	return nil, nil
+}
@@ -0,0 +1,222 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alert
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/cadvisor/info"
+)
+
+// Labels of containers with this prefix set thresholds on their metrics, e.g.:
+// "io.cadvisor.alert.high_memory=memory.working_set > 90% for 60s".
+const LabelPrefix = "io.cadvisor.alert."
+
+// An alert firing, or resolved, for a container.
+type Alert struct {
+	ContainerName string `json:"container_name"`
+
+	// Name and expression of the threshold.
+	Name       string `json:"name"`
+	Expression string `json:"expression"`
+
+	// Value of the metric of the threshold when the alert fired or was resolved.
+	Value float64 `json:"value"`
+
+	Timestamp time.Time `json:"timestamp"`
+
+	// Whether the alert fired, or was resolved.
+	Firing bool `json:"firing"`
+}
+
+func (self *Alert) String() string {
+	if self.Firing {
+		return fmt.Sprintf("alert %q (%s) of container %q fired with value %v", self.Name, self.Expression, self.ContainerName, self.Value)
+	}
+	return fmt.Sprintf("alert %q (%s) of container %q resolved with value %v", self.Name, self.Expression, self.ContainerName, self.Value)
+}
+
+// Parses thresholds in the form name=expression, separated by commas.
+func ParseThresholds(thresholds string) ([]*Threshold, error) {
+	result := []*Threshold{}
+	for _, threshold := range strings.Split(thresholds, ",") {
+		if strings.TrimSpace(threshold) == "" {
+			continue
+		}
+		parts := strings.SplitN(threshold, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid threshold %q, expected name=expression", threshold)
+		}
+		t, err := ParseThreshold(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, t)
+	}
+	return result, nil
+}
+
+// Returns the thresholds set by the labels of a container, sorted by name.
+func GetThresholds(labels map[string]string) ([]*Threshold, error) {
+	result := []*Threshold{}
+	for label, expression := range labels {
+		if !strings.HasPrefix(label, LabelPrefix) {
+			continue
+		}
+		t, err := ParseThreshold(strings.TrimPrefix(label, LabelPrefix), expression)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, t)
+	}
+	sort.Sort(byName(result))
+	return result, nil
+}
+
+// A threshold registered through the API for a container, and optionally its subcontainers.
+type Trigger struct {
+	// Name of the threshold, unique among the thresholds of a container.
+	Name string `json:"name"`
+
+	// Threshold expression, e.g.: "cpu.usage > 90% for 60s".
+	Expression string `json:"expression"`
+
+	// Absolute name of the container.
+	ContainerName string `json:"container_name"`
+
+	IncludeSubcontainers bool `json:"subcontainers"`
+}
+
+// Returns the threshold of the trigger.
+func (self *Trigger) Threshold() (*Threshold, error) {
+	return ParseThreshold(self.Name, self.Expression)
+}
+
+// Returns whether the trigger applies to a container.
+func (self *Trigger) Matches(containerName string) bool {
+	if containerName == self.ContainerName {
+		return true
+	}
+	if !self.IncludeSubcontainers {
+		return false
+	}
+	return self.ContainerName == "/" || strings.HasPrefix(containerName, self.ContainerName+"/")
+}
+
+type byName []*Threshold
+
+func (s byName) Len() int           { return len(s) }
+func (s byName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byName) Less(i, j int) bool { return s[i].Name < s[j].Name }
+
+// State of a threshold of a container.
+type thresholdState struct {
+	// Since when the value has been above the threshold, zero if it is not.
+	since time.Time
+
+	firing bool
+}
+
+// Checks the samples of a container against thresholds.
+type Checker struct {
+	lock       sync.Mutex
+	thresholds []*Threshold
+	states     []thresholdState
+
+	// The previous sample, to compute rates.
+	last *info.ContainerStats
+}
+
+func NewChecker(thresholds []*Threshold) *Checker {
+	return &Checker{
+		thresholds: thresholds,
+		states:     make([]thresholdState, len(thresholds)),
+	}
+}
+
+// Adds a threshold, fails if there is already one of the same name.
+func (self *Checker) Add(threshold *Threshold) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	for _, t := range self.thresholds {
+		if t.Name == threshold.Name {
+			return fmt.Errorf("threshold %q already exists", threshold.Name)
+		}
+	}
+	self.thresholds = append(self.thresholds, threshold)
+	self.states = append(self.states, thresholdState{})
+	return nil
+}
+
+// Removes the threshold of the specified name, returns whether there was one.
+func (self *Checker) Remove(name string) bool {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	for i, t := range self.thresholds {
+		if t.Name == name {
+			self.thresholds = append(self.thresholds[:i], self.thresholds[i+1:]...)
+			self.states = append(self.states[:i], self.states[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Checks a new sample of a container, returning the alerts that fired or were resolved. Alerts
+// whose metric is unknown in the sample keep their state.
+func (self *Checker) Check(containerName string, stats *info.ContainerStats, spec *info.ContainerSpec) []Alert {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	var alerts []Alert
+	for i, threshold := range self.thresholds {
+		value, ok := threshold.value(stats, self.last, spec)
+		if !ok {
+			continue
+		}
+		state := &self.states[i]
+		if value <= threshold.Value {
+			state.since = time.Time{}
+			if state.firing {
+				state.firing = false
+				alerts = append(alerts, newAlert(containerName, threshold, value, stats.Timestamp, false))
+			}
+			continue
+		}
+		if state.since.IsZero() {
+			state.since = stats.Timestamp
+		}
+		if !state.firing && stats.Timestamp.Sub(state.since) >= threshold.For {
+			state.firing = true
+			alerts = append(alerts, newAlert(containerName, threshold, value, stats.Timestamp, true))
+		}
+	}
+	self.last = stats
+	return alerts
+}
+
+func newAlert(containerName string, threshold *Threshold, value float64, timestamp time.Time, firing bool) Alert {
+	return Alert{
+		ContainerName: containerName,
+		Name:          threshold.Name,
+		Expression:    threshold.Expression,
+		Value:         value,
+		Timestamp:     timestamp,
+		Firing:        firing,
+	}
+}
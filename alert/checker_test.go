@@ -0,0 +1,112 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alert
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/info"
+)
+
+func TestCheck(t *testing.T) {
+	threshold, err := ParseThreshold("high_memory", "memory.usage > 50% for 2s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checker := NewChecker([]*Threshold{threshold})
+	spec := &info.ContainerSpec{}
+	spec.Memory.Limit = 100
+
+	start := time.Now()
+	expected := map[int]bool{}
+	for i, usage := range []uint64{40, 60, 70, 80, 90, 30, 60} {
+		stats := &info.ContainerStats{Timestamp: start.Add(time.Duration(i) * time.Second)}
+		stats.Memory.Usage = usage
+		for _, alert := range checker.Check("/test", stats, spec) {
+			if alert.ContainerName != "/test" || alert.Name != "high_memory" || alert.Value != float64(usage) {
+				t.Errorf("unexpected alert %+v", alert)
+			}
+			expected[i] = alert.Firing
+		}
+	}
+	// Fires 2s after going above the threshold, once, and resolves when it goes below.
+	if len(expected) != 2 || expected[3] != true || expected[5] != false {
+		t.Errorf("unexpected alerts %v", expected)
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	received := make(chan []Alert, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var alerts []Alert
+		if err := json.NewDecoder(r.Body).Decode(&alerts); err != nil {
+			t.Error(err)
+		}
+		received <- alerts
+	}))
+	defer server.Close()
+
+	webhook := NewWebhook(server.URL)
+	webhook.Notify([]Alert{{ContainerName: "/test", Name: "high_memory", Firing: true}})
+	select {
+	case alerts := <-received:
+		if len(alerts) != 1 || alerts[0].Name != "high_memory" || !alerts[0].Firing {
+			t.Errorf("unexpected alerts %+v", alerts)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the alerts were not posted")
+	}
+}
+
+func TestAddRemove(t *testing.T) {
+	checker := NewChecker(nil)
+	threshold, err := ParseThreshold("big", "memory.usage > 10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checker.Add(threshold); err != nil {
+		t.Fatal(err)
+	}
+	if err := checker.Add(threshold); err == nil {
+		t.Error("expected an error adding a threshold twice")
+	}
+	stats := &info.ContainerStats{Timestamp: time.Now()}
+	stats.Memory.Usage = 20
+	if alerts := checker.Check("/test", stats, &info.ContainerSpec{}); len(alerts) != 1 {
+		t.Errorf("expected the alert to fire, got %+v", alerts)
+	}
+	if !checker.Remove("big") || checker.Remove("big") {
+		t.Error("expected the threshold to be removed once")
+	}
+}
+
+func TestTriggerMatches(t *testing.T) {
+	trigger := &Trigger{Name: "busy", Expression: "cpu.usage > 1", ContainerName: "/docker"}
+	if !trigger.Matches("/docker") || trigger.Matches("/docker/a") {
+		t.Errorf("expected the trigger to match /docker only")
+	}
+	trigger.IncludeSubcontainers = true
+	if !trigger.Matches("/docker/a") || trigger.Matches("/dockerd") {
+		t.Errorf("expected the trigger to match the subcontainers of /docker")
+	}
+	trigger.ContainerName = "/"
+	if !trigger.Matches("/system.slice") {
+		t.Errorf("expected the trigger of / to match all containers")
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Alerts on the usage of containers crossing thresholds, e.g.: "memory.working_set > 90% for 60s".
+package alert
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/cadvisor/info"
+)
+
+// A threshold on a metric of a container.
+type Threshold struct {
+	// Name of the alert, e.g.: high_memory.
+	Name string `json:"name"`
+
+	// The expression the threshold was parsed from.
+	Expression string `json:"expression"`
+
+	// cpu.usage (in cores), memory.usage, memory.working_set, fs.usage (in bytes) or the name of
+	// a custom metric.
+	Metric string `json:"metric"`
+
+	// The value above which the alert fires, a percent of the limit of the container if Percent.
+	Value   float64 `json:"value"`
+	Percent bool    `json:"percent"`
+
+	// How long the value must stay above the threshold before the alert fires.
+	For time.Duration `json:"for"`
+}
+
+// The metrics of containers thresholds can be set on, besides custom metrics.
+var builtinMetrics = map[string]bool{
+	"cpu.usage":          true,
+	"memory.usage":       true,
+	"memory.working_set": true,
+	"fs.usage":           true,
+}
+
+// Parses a threshold expression: <metric> > <value>[%] [for <duration>].
+func ParseThreshold(name, expression string) (*Threshold, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != 3 && len(fields) != 5 {
+		return nil, fmt.Errorf("invalid threshold %q, expected <metric> > <value>[%%] [for <duration>]", expression)
+	}
+	if fields[1] != ">" {
+		return nil, fmt.Errorf("invalid threshold %q, only > is supported", expression)
+	}
+	threshold := &Threshold{
+		Name:       name,
+		Expression: expression,
+		Metric:     fields[0],
+	}
+	value := fields[2]
+	if strings.HasSuffix(value, "%") {
+		threshold.Percent = true
+		value = strings.TrimSuffix(value, "%")
+	}
+	var err error
+	threshold.Value, err = strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value in threshold %q: %v", expression, err)
+	}
+	if len(fields) == 5 {
+		if fields[3] != "for" {
+			return nil, fmt.Errorf("invalid threshold %q, expected for <duration>", expression)
+		}
+		threshold.For, err = time.ParseDuration(fields[4])
+		if err != nil {
+			return nil, fmt.Errorf("invalid duration in threshold %q: %v", expression, err)
+		}
+	}
+	if threshold.Percent && !builtinMetrics[threshold.Metric] {
+		return nil, fmt.Errorf("invalid threshold %q, custom metrics have no limit", expression)
+	}
+	return threshold, nil
+}
+
+// Returns the value of the metric of the threshold in a sample, as compared to the threshold, and
+// whether it is known. last is the previous sample, to compute rates.
+func (self *Threshold) value(stats, last *info.ContainerStats, spec *info.ContainerSpec) (float64, bool) {
+	var value, limit float64
+	switch self.Metric {
+	case "cpu.usage":
+		if last == nil || !stats.Timestamp.After(last.Timestamp) || stats.Cpu.Usage.Total < last.Cpu.Usage.Total {
+			return 0, false
+		}
+		value = float64(stats.Cpu.Usage.Total-last.Cpu.Usage.Total) / float64(stats.Timestamp.Sub(last.Timestamp))
+		if spec.Cpu.Quota != 0 && spec.Cpu.Period != 0 {
+			limit = float64(spec.Cpu.Quota) / float64(spec.Cpu.Period)
+		}
+	case "memory.usage", "memory.working_set":
+		value = float64(stats.Memory.Usage)
+		if self.Metric == "memory.working_set" {
+			value = float64(stats.Memory.WorkingSet)
+		}
+		// Unlimited containers have a huge limit.
+		if spec.Memory.Limit != 0 && spec.Memory.Limit < 1<<62 {
+			limit = float64(spec.Memory.Limit)
+		}
+	case "fs.usage":
+		// The fullest filesystem.
+		fullest := -1.0
+		for _, fs := range stats.Filesystem {
+			if fs.Limit == 0 {
+				continue
+			}
+			if usage := float64(fs.Usage) / float64(fs.Limit); usage > fullest {
+				fullest = usage
+				value, limit = float64(fs.Usage), float64(fs.Limit)
+			}
+		}
+		if fullest < 0 {
+			return 0, false
+		}
+	default:
+		values, ok := stats.CustomMetrics[self.Metric]
+		if !ok || len(values) == 0 {
+			return 0, false
+		}
+		// The highest of the values of the metric.
+		for i, val := range values {
+			v := val.FloatValue
+			if val.IntValue != 0 {
+				v = float64(val.IntValue)
+			}
+			if i == 0 || v > value {
+				value = v
+			}
+		}
+		return value, true
+	}
+	if !self.Percent {
+		return value, true
+	}
+	if limit == 0 {
+		return 0, false
+	}
+	return value / limit * 100, true
+}
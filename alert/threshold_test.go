@@ -0,0 +1,136 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alert
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/info"
+)
+
+func TestParseThreshold(t *testing.T) {
+	threshold, err := ParseThreshold("high_memory", "memory.working_set > 90% for 60s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if threshold.Metric != "memory.working_set" || threshold.Value != 90 || !threshold.Percent || threshold.For != time.Minute {
+		t.Errorf("unexpected threshold %+v", threshold)
+	}
+	threshold, err = ParseThreshold("requests", "requests_per_second > 1000.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if threshold.Metric != "requests_per_second" || threshold.Value != 1000.5 || threshold.Percent || threshold.For != 0 {
+		t.Errorf("unexpected threshold %+v", threshold)
+	}
+
+	for _, expression := range []string{
+		"memory.usage",
+		"memory.usage < 10",
+		"memory.usage > ten",
+		"memory.usage > 10 during 60s",
+		"memory.usage > 10 for ever",
+		"requests_per_second > 10%",
+	} {
+		if _, err := ParseThreshold("invalid", expression); err == nil {
+			t.Errorf("expected an error parsing %q", expression)
+		}
+	}
+}
+
+func TestParseThresholds(t *testing.T) {
+	thresholds, err := ParseThresholds("high_memory=memory.usage > 90%, busy = cpu.usage > 2 for 5m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(thresholds) != 2 || thresholds[0].Name != "high_memory" || thresholds[1].Name != "busy" || thresholds[1].For != 5*time.Minute {
+		t.Errorf("unexpected thresholds %+v", thresholds)
+	}
+	if thresholds, err := ParseThresholds(""); err != nil || len(thresholds) != 0 {
+		t.Errorf("expected no thresholds, got %+v, %v", thresholds, err)
+	}
+	if _, err := ParseThresholds("memory.usage > 90%"); err == nil {
+		t.Errorf("expected an error without name")
+	}
+}
+
+func TestGetThresholds(t *testing.T) {
+	thresholds, err := GetThresholds(map[string]string{
+		"io.cadvisor.alert.full_disk": "fs.usage > 95%",
+		"io.cadvisor.alert.busy":      "cpu.usage > 1.5",
+		"io.cadvisor.metric.app":      "/etc/app.json",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(thresholds) != 2 || thresholds[0].Name != "busy" || thresholds[1].Name != "full_disk" {
+		t.Errorf("unexpected thresholds %+v", thresholds)
+	}
+}
+
+func TestValue(t *testing.T) {
+	now := time.Now()
+	spec := &info.ContainerSpec{}
+	spec.Cpu.Quota = 200000
+	spec.Cpu.Period = 100000
+	spec.Memory.Limit = 1000
+	last := &info.ContainerStats{Timestamp: now}
+	stats := &info.ContainerStats{
+		Timestamp: now.Add(time.Second),
+		Memory: info.MemoryStats{
+			Usage:      800,
+			WorkingSet: 600,
+		},
+		Filesystem: []info.FsStats{
+			{Device: "/dev/sda1", Limit: 100, Usage: 50},
+			{Device: "/dev/sdb1", Limit: 1000, Usage: 900},
+		},
+		CustomMetrics: map[string][]info.MetricVal{
+			"requests": {{IntValue: 5}, {IntValue: 7}},
+		},
+	}
+	stats.Cpu.Usage.Total = uint64(time.Second)
+
+	for expression, expected := range map[string]float64{
+		"cpu.usage > 1":            1,
+		"cpu.usage > 50%":          50,
+		"memory.usage > 10":        800,
+		"memory.working_set > 10%": 60,
+		"fs.usage > 10":            900,
+		"fs.usage > 10%":           90,
+		"requests > 10":            7,
+	} {
+		threshold, err := ParseThreshold("test", expression)
+		if err != nil {
+			t.Fatal(err)
+		}
+		value, ok := threshold.value(stats, last, spec)
+		if !ok || value != expected {
+			t.Errorf("expected %v for %q, got %v (%v)", expected, expression, value, ok)
+		}
+	}
+
+	// Unknown without a previous sample, a limit or the metric.
+	for _, expression := range []string{"cpu.usage > 1", "memory.usage > 10%", "latency > 1"} {
+		threshold, err := ParseThreshold("test", expression)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value, ok := threshold.value(stats, nil, &info.ContainerSpec{}); ok {
+			t.Errorf("expected no value for %q, got %v", expression, value)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCors(t *testing.T) {
+	*corsAllowedOrigins = "http://dashboard.example.com, http://other.example.com"
+	defer func() { *corsAllowedOrigins = "" }()
+
+	called := false
+	handler := withCors(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	})
+
+	// Allowed origin.
+	r, _ := http.NewRequest("GET", "/api/v1.3/machine", nil)
+	r.Header.Set("Origin", "http://dashboard.example.com")
+	w := httptest.NewRecorder()
+	handler(w, r)
+	if !called {
+		t.Errorf("handler was not called")
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "http://dashboard.example.com" {
+		t.Errorf("unexpected Access-Control-Allow-Origin %q", got)
+	}
+
+	// Disallowed origin.
+	called = false
+	r.Header.Set("Origin", "http://evil.example.com")
+	w = httptest.NewRecorder()
+	handler(w, r)
+	if !called {
+		t.Errorf("handler was not called")
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("unexpected Access-Control-Allow-Origin %q for a disallowed origin", got)
+	}
+}
+
+func TestCorsPreflight(t *testing.T) {
+	*corsAllowedOrigins = "*"
+	defer func() { *corsAllowedOrigins = "" }()
+
+	called := false
+	handler := withCors(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	})
+
+	r, _ := http.NewRequest("OPTIONS", "/api/v1.3/containers/", nil)
+	r.Header.Set("Origin", "http://dashboard.example.com")
+	r.Header.Set("Access-Control-Request-Method", "POST")
+	r.Header.Set("Access-Control-Request-Headers", "Content-Type")
+	w := httptest.NewRecorder()
+	handler(w, r)
+	if called {
+		t.Errorf("handler should not be called for a preflight request")
+	}
+	if w.Code != http.StatusNoContent {
+		t.Errorf("expected status %d, got %d", http.StatusNoContent, w.Code)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Headers"); got != "Content-Type" {
+		t.Errorf("unexpected Access-Control-Allow-Headers %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Methods"); got == "" {
+		t.Errorf("no Access-Control-Allow-Methods in preflight response")
+	}
+}
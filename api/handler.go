@@ -21,44 +21,294 @@ import (
 	"io"
 	"net/http"
 	"path"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/golang/glog"
+	"github.com/google/cadvisor/alert"
+	"github.com/google/cadvisor/collector"
+	"github.com/google/cadvisor/config"
+	"github.com/google/cadvisor/events"
 	"github.com/google/cadvisor/info"
 	"github.com/google/cadvisor/manager"
+	"github.com/google/cadvisor/utils/httpauth"
 )
 
 const (
 	apiResource      = "/api/"
+	specResource     = "/api/spec"
 	containersApi    = "containers"
 	subcontainersApi = "subcontainers"
 	machineApi       = "machine"
 	dockerApi        = "docker"
+	aggregateApi     = "aggregate"
+	psApi            = "ps"
+	snapshotApi      = "snapshot"
+	storageApi       = "storage"
+	collectorsApi    = "collectors"
+	eventsApi        = "events"
+	thresholdsApi    = "thresholds"
+	configApi        = "config"
+	trackingApi      = "tracking"
+	flatApi          = "flat"
 
 	version1_0 = "v1.0"
 	version1_1 = "v1.1"
 	version1_2 = "v1.2"
+	version1_3 = "v1.3"
+	version2_0 = "v2.0"
 )
 
-var supportedApiVersions map[string]struct{} = map[string]struct{}{
-	version1_0: {},
-	version1_1: {},
-	version1_2: {},
+// Supported API versions, oldest first.
+var apiVersions = []string{
+	version1_0,
+	version1_1,
+	version1_2,
+	version1_3,
+	version2_0,
+}
+
+// A request type served under /api/<version>/.
+type apiHandler struct {
+	// Name of the request type in the resource path (e.g.: "containers").
+	requestType string
+
+	// Oldest API version that supports the request type.
+	minVersion string
+
+	// Short description of the request type, used in the API spec.
+	description string
+
+	// Description of the path following the request type ("" if it takes none).
+	argument string
+
+	// Object the request body is decoded into (nil if there is no body).
+	query interface{}
+
+	// Object returned by the handler.
+	response interface{}
+
+	// Whether a DELETE request removes the object named by the argument.
+	deletable bool
+
+	// Whether the request gathers many containers, limited by --api_max_recursive_requests.
+	recursive bool
+
+	// Handles the request. args is the path following the request type.
+	handle func(m manager.Manager, args string, r *http.Request) (interface{}, error)
+
+	// Handles the request when it asks to stream the results (stream=true) by writing them as
+	// they come. nil if the request type cannot be streamed.
+	stream func(m manager.Manager, args string, w http.ResponseWriter, r *http.Request) error
+}
+
+// All the request types of the API. These also generate the API spec.
+var apiHandlers = []*apiHandler{
+	{
+		requestType: machineApi,
+		minVersion:  version1_0,
+		description: "Information about the machine.",
+		response:    info.MachineInfo{},
+		handle:      handleMachine,
+	},
+	{
+		requestType: containersApi,
+		minVersion:  version1_0,
+		description: "Information about a container.",
+		argument:    "Absolute name of the container.",
+		query:       info.ContainerInfoRequest{},
+		response:    info.ContainerInfo{},
+		handle:      handleContainers,
+	},
+	{
+		requestType: subcontainersApi,
+		minVersion:  version1_1,
+		description: "Information about a container and all its subcontainers (recursively).",
+		argument:    "Absolute name of the container.",
+		query:       info.ContainerInfoRequest{},
+		response:    []info.ContainerInfo{},
+		recursive:   true,
+		handle:      handleSubcontainers,
+	},
+	{
+		requestType: dockerApi,
+		minVersion:  version1_2,
+		description: "Information about one or all Docker containers, keyed by absolute container name.",
+		argument:    "Docker name or ID of the container, blank for all Docker containers.",
+		query:       info.ContainerInfoRequest{},
+		response:    map[string]info.ContainerInfo{},
+		handle:      handleDocker,
+	},
+	{
+		requestType: aggregateApi,
+		minVersion:  version1_3,
+		description: "Latest usage of all containers summed by the value of a label.",
+		argument:    "Key of the label.",
+		response:    map[string]info.AggregateStats{},
+		recursive:   true,
+		handle:      handleAggregate,
+	},
+	{
+		requestType: psApi,
+		minVersion:  version1_3,
+		description: "Processes running in a container.",
+		argument:    "Absolute name of the container.",
+		response:    []info.ProcessInfo{},
+		handle:      handlePs,
+	},
+	{
+		requestType: snapshotApi,
+		minVersion:  version1_3,
+		description: "Latest stats of all containers, from the same housekeeping interval.",
+		response:    info.Snapshot{},
+		recursive:   true,
+		handle:      handleSnapshot,
+	},
+	{
+		requestType: storageApi,
+		minVersion:  version1_3,
+		description: "Health of the storage drivers stats are exported to.",
+		response:    []info.StorageDriverStatus{},
+		handle:      handleStorage,
+	},
+	{
+		requestType: collectorsApi,
+		minVersion:  version1_3,
+		description: "Collector configurations shared by the containers matching their selector. A POST sets the configuration in the body, a DELETE removes it.",
+		argument:    "Name of the collector, blank for all collectors.",
+		query:       collector.SharedConfig{},
+		response:    []collector.SharedConfig{},
+		deletable:   true,
+		handle:      handleCollectors,
+	},
+	{
+		requestType: eventsApi,
+		minVersion:  version1_3,
+		description: "Events of a container, filtered by the URL parameters start_time and end_time (RFC 3339), max_events, subcontainers=true and the types of events: all_events, creation_events, deletion_events, oom_events and threshold_events. New events are streamed with stream=true.",
+		argument:    "Absolute name of the container.",
+		response:    []info.Event{},
+		handle:      handleEvents,
+		stream:      streamEvents,
+	},
+	{
+		requestType: thresholdsApi,
+		minVersion:  version1_3,
+		description: "Thresholds on the usage of containers, recording threshold events when crossed. A POST sets the threshold in the body, a DELETE removes it.",
+		argument:    "Name of the threshold, blank for all thresholds.",
+		query:       alert.Trigger{},
+		response:    []alert.Trigger{},
+		deletable:   true,
+		handle:      handleThresholds,
+	},
+	{
+		requestType: configApi,
+		minVersion:  version1_3,
+		description: "Settings which can be updated while cAdvisor runs, by their flag names. A POST sets those in the body, all of them or none, until the config file is reloaded.",
+		query:       map[string]string{},
+		response:    map[string]string{},
+		handle:      handleConfig,
+	},
+	{
+		requestType: trackingApi,
+		minVersion:  version1_3,
+		description: "Number of containers monitored, and of those evicted to stay under --max_containers.",
+		response:    info.TrackingStatus{},
+		handle:      handleTracking,
+	},
+	{
+		requestType: flatApi,
+		minVersion:  version2_0,
+		description: "Latest stats of a container and all its subcontainers (recursively), as flat key/value pairs keyed by the path of their fields, e.g. cpu.usage.total.",
+		argument:    "Absolute name of the container, blank for all containers.",
+		response:    []info.FlatStats{},
+		recursive:   true,
+		handle:      handleFlat,
+	},
 }
 
 func RegisterHandlers(m manager.Manager) error {
-	http.HandleFunc(apiResource, func(w http.ResponseWriter, r *http.Request) {
-		err := handleRequest(m, w, r)
+	spec, err := json.Marshal(generateSpec())
+	if err != nil {
+		return fmt.Errorf("failed to generate API spec: %v", err)
+	}
+	http.HandleFunc(specResource, withCors(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(spec)
+	}))
+
+	limits := newLimits()
+	http.HandleFunc(apiResource, withCors(limits.withRate(func(w http.ResponseWriter, r *http.Request) {
+		err := handleRequest(m, limits, w, r)
 		if err != nil {
 			http.Error(w, err.Error(), 500)
 		}
-	})
+	})))
 
 	return nil
 }
 
-func handleRequest(m manager.Manager, w http.ResponseWriter, r *http.Request) error {
+// Returns the scope a bearer token needs for the request: changes and the config need the admin
+// scope, the events the events scope and anything else the stats scope. The queries POSTed for
+// containers (e.g. by the client library and the UI) only read stats.
+func RequestScope(r *http.Request) httpauth.Scope {
+	// <empty>/api/<version>/<request type>[/<args...>]
+	requestType := ""
+	requestElements := strings.Split(r.URL.Path, "/")
+	if len(requestElements) >= 4 && requestElements[1] == "api" {
+		requestType = requestElements[3]
+	}
+	switch r.Method {
+	case "GET", "HEAD", "OPTIONS":
+	case "POST":
+		switch requestType {
+		case containersApi, subcontainersApi, dockerApi:
+			return httpauth.ScopeStats
+		}
+		return httpauth.ScopeAdmin
+	default:
+		return httpauth.ScopeAdmin
+	}
+	switch requestType {
+	case configApi:
+		return httpauth.ScopeAdmin
+	case eventsApi:
+		return httpauth.ScopeEvents
+	}
+	return httpauth.ScopeStats
+}
+
+// Returns the container an API request is about, e.g. "/docker/abc" for
+// /api/v1.3/containers/docker/abc, or empty if none. Docker containers are returned by their
+// Docker name or ID.
+func RequestContainer(r *http.Request) string {
+	// <empty>/api/<version>/<request type>[/<args...>]
+	requestElements := strings.Split(r.URL.Path, "/")
+	if len(requestElements) < 4 || requestElements[1] != "api" {
+		return ""
+	}
+	args := strings.Join(requestElements[4:], "/")
+	switch requestElements[3] {
+	case containersApi, subcontainersApi, psApi, eventsApi, flatApi:
+		return path.Join("/", args)
+	case dockerApi:
+		return strings.Trim(args, "/")
+	}
+	return ""
+}
+
+// Returns the position of the version in apiVersions or -1 if it is not supported.
+func versionIndex(version string) int {
+	for i, v := range apiVersions {
+		if v == version {
+			return i
+		}
+	}
+	return -1
+}
+
+func handleRequest(m manager.Manager, limits *limits, w http.ResponseWriter, r *http.Request) error {
 	start := time.Now()
 	defer glog.V(2).Infof("Request took %s", time.Since(start))
 
@@ -82,9 +332,6 @@ func handleRequest(m manager.Manager, w http.ResponseWriter, r *http.Request) er
 		requestArgs = requestElements[4:]
 	}
 
-	// The container name is the path after the requestType.
-	containerName := path.Join("/", strings.Join(requestArgs, "/"))
-
 	// Check elements.
 	if len(emptyElement) != 0 {
 		return fmt.Errorf("unexpected API request format %q", request)
@@ -92,111 +339,395 @@ func handleRequest(m manager.Manager, w http.ResponseWriter, r *http.Request) er
 	if apiElement != "api" {
 		return fmt.Errorf("invalid API request format %q", request)
 	}
-	if _, ok := supportedApiVersions[version]; !ok {
+	versionIdx := versionIndex(version)
+	if versionIdx < 0 {
 		return fmt.Errorf("unsupported API version %q", version)
 	}
 
-	switch {
-	case requestType == machineApi:
-		glog.V(2).Infof("Api - Machine")
+	for _, handler := range apiHandlers {
+		if handler.requestType != requestType {
+			continue
+		}
+		if versionIdx < versionIndex(handler.minVersion) {
+			return fmt.Errorf("request type of %q not supported in API version %q", requestType, version)
+		}
 
-		// Get the MachineInfo
-		machineInfo, err := m.GetMachineInfo()
-		if err != nil {
-			return err
+		args := strings.Join(requestArgs, "/")
+		// Requests for all the Docker containers are recursive too.
+		if handler.recursive || (requestType == dockerApi && args == "") {
+			if !limits.acquireRecursive() {
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, fmt.Sprintf("too many requests gathering many containers, limited to %d at once", cap(limits.recursive)), http.StatusServiceUnavailable)
+				return nil
+			}
+			defer limits.releaseRecursive()
 		}
 
-		err = writeResult(machineInfo, w)
+		if handler.stream != nil && r.URL.Query().Get("stream") == "true" {
+			return handler.stream(m, args, w, r)
+		}
+		res, err := handler.handle(m, args, r)
 		if err != nil {
 			return err
 		}
-	case requestType == containersApi:
-		glog.V(2).Infof("Api - Container(%s)", containerName)
+		return writeResult(res, w)
+	}
+	return fmt.Errorf("unknown API request type %q", requestType)
+}
+
+func handleMachine(m manager.Manager, args string, r *http.Request) (interface{}, error) {
+	glog.V(2).Infof("Api - Machine")
+
+	// Get the MachineInfo
+	return m.GetMachineInfo()
+}
+
+func handleContainers(m manager.Manager, args string, r *http.Request) (interface{}, error) {
+	containerName := path.Join("/", args)
+	glog.V(2).Infof("Api - Container(%s)", containerName)
+
+	// Get the query request.
+	query, err := getContainerInfoRequest(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the container.
+	cont, err := m.GetContainerInfo(containerName, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get container %q with error: %s", containerName, err)
+	}
+	return cont, nil
+}
+
+func handleSubcontainers(m manager.Manager, args string, r *http.Request) (interface{}, error) {
+	containerName := path.Join("/", args)
+	glog.V(2).Infof("Api - Subcontainers(%s)", containerName)
+
+	// Get the query request.
+	query, err := getContainerInfoRequest(r.Body)
+	if err != nil {
+		return nil, err
+	}
 
-		// Get the query request.
-		query, err := getContainerInfoRequest(r.Body)
+	// Get the subcontainers.
+	containers, err := m.SubcontainersInfo(containerName, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subcontainers for container %q with error: %s", containerName, err)
+	}
+	return containers, nil
+}
+
+func handleDocker(m manager.Manager, args string, r *http.Request) (interface{}, error) {
+	containerName := strings.TrimLeft(path.Join("/", args), "/")
+	glog.V(2).Infof("Api - Docker(%s)", containerName)
+
+	// Get the query request.
+	query, err := getContainerInfoRequest(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if containerName == "" {
+		// Get all Docker containers.
+		containers, err := m.AllDockerContainers(query)
 		if err != nil {
-			return err
+			return nil, fmt.Errorf("failed to get all Docker containers with error: %v", err)
 		}
+		return containers, nil
+	}
+
+	// Get one Docker container.
+	cont, err := m.DockerContainer(containerName, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Docker container %q with error: %v", containerName, err)
+	}
+	return map[string]info.ContainerInfo{
+		cont.Name: cont,
+	}, nil
+}
+
+func handleAggregate(m manager.Manager, args string, r *http.Request) (interface{}, error) {
+	// The label key is the path after the requestType.
+	label := args
+	if label == "" {
+		return nil, fmt.Errorf("no label specified in request %q", r.URL.Path)
+	}
+	glog.V(2).Infof("Api - Aggregate(%s)", label)
+
+	aggregates, err := m.AggregateByLabel(label)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate containers by label %q with error: %v", label, err)
+	}
+	return aggregates, nil
+}
+
+func handlePs(m manager.Manager, args string, r *http.Request) (interface{}, error) {
+	containerName := path.Join("/", args)
+	glog.V(2).Infof("Api - Ps(%s)", containerName)
+
+	processes, err := m.GetProcessList(containerName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get processes of container %q with error: %v", containerName, err)
+	}
+	return processes, nil
+}
+
+func handleSnapshot(m manager.Manager, args string, r *http.Request) (interface{}, error) {
+	glog.V(2).Infof("Api - Snapshot")
+
+	snapshot, err := m.GetSnapshot()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get snapshot with error: %v", err)
+	}
+	return snapshot, nil
+}
+
+func handleTracking(m manager.Manager, args string, r *http.Request) (interface{}, error) {
+	glog.V(2).Infof("Api - Tracking")
+
+	return m.GetTrackingStatus(), nil
+}
 
-		// Get the container.
-		cont, err := m.GetContainerInfo(containerName, query)
+func handleFlat(m manager.Manager, args string, r *http.Request) (interface{}, error) {
+	containerName := path.Join("/", args)
+	glog.V(2).Infof("Api - Flat(%s)", containerName)
+
+	containers, err := m.SubcontainersInfo(containerName, &info.ContainerInfoRequest{NumStats: 1})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get container %q with error: %s", containerName, err)
+	}
+	flat := make([]info.FlatStats, 0, len(containers))
+	for _, cont := range containers {
+		if len(cont.Stats) == 0 {
+			continue
+		}
+		stats, err := cont.Stats[len(cont.Stats)-1].Flatten()
 		if err != nil {
-			return fmt.Errorf("failed to get container %q with error: %s", containerName, err)
+			return nil, fmt.Errorf("failed to flatten the stats of container %q with error: %s", cont.Name, err)
 		}
+		flat = append(flat, info.FlatStats{Name: cont.Name, Stats: stats})
+	}
+	sort.Sort(byFlatName(flat))
+	return flat, nil
+}
 
-		// Only output the container as JSON.
-		err = writeResult(cont, w)
-		if err != nil {
-			return err
+type byFlatName []info.FlatStats
+
+func (s byFlatName) Len() int           { return len(s) }
+func (s byFlatName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byFlatName) Less(i, j int) bool { return s[i].Name < s[j].Name }
+
+func handleStorage(m manager.Manager, args string, r *http.Request) (interface{}, error) {
+	glog.V(2).Infof("Api - Storage")
+
+	statuses := m.GetStorageStatus()
+	if statuses == nil {
+		statuses = []info.StorageDriverStatus{}
+	}
+	return statuses, nil
+}
+
+func handleCollectors(m manager.Manager, args string, r *http.Request) (interface{}, error) {
+	name := strings.Trim(args, "/")
+	glog.V(2).Infof("Api - Collectors(%s) %s", name, r.Method)
+
+	switch r.Method {
+	case "POST", "PUT":
+		if name == "" {
+			return nil, fmt.Errorf("no collector specified in request %q", r.URL.Path)
 		}
-	case requestType == subcontainersApi:
-		if version == version1_0 {
-			return fmt.Errorf("request type of %q not supported in API version %q", requestType, version)
+		config := &collector.SharedConfig{}
+		if err := json.NewDecoder(r.Body).Decode(config); err != nil {
+			return nil, fmt.Errorf("unable to decode the json value: %s", err)
 		}
+		if config.Name == "" {
+			config.Name = name
+		} else if config.Name != name {
+			return nil, fmt.Errorf("the name of the collector %q does not match the request %q", config.Name, r.URL.Path)
+		}
+		if err := m.SetCollectorConfig(config); err != nil {
+			return nil, fmt.Errorf("failed to set collector %q with error: %v", name, err)
+		}
+	case "DELETE":
+		if err := m.RemoveCollectorConfig(name); err != nil {
+			return nil, fmt.Errorf("failed to remove collector %q with error: %v", name, err)
+		}
+		return []collector.SharedConfig{}, nil
+	}
 
-		glog.V(2).Infof("Api - Subcontainers(%s)", containerName)
+	configs := m.GetCollectorConfigs()
+	if name == "" {
+		return configs, nil
+	}
+	for _, config := range configs {
+		if config.Name == name {
+			return []collector.SharedConfig{config}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown collector %q", name)
+}
 
-		// Get the query request.
-		query, err := getContainerInfoRequest(r.Body)
-		if err != nil {
-			return err
+func handleThresholds(m manager.Manager, args string, r *http.Request) (interface{}, error) {
+	name := strings.Trim(args, "/")
+	glog.V(2).Infof("Api - Thresholds(%s) %s", name, r.Method)
+
+	switch r.Method {
+	case "POST", "PUT":
+		if name == "" {
+			return nil, fmt.Errorf("no threshold specified in request %q", r.URL.Path)
 		}
+		trigger := &alert.Trigger{}
+		if err := json.NewDecoder(r.Body).Decode(trigger); err != nil {
+			return nil, fmt.Errorf("unable to decode the json value: %s", err)
+		}
+		if trigger.Name == "" {
+			trigger.Name = name
+		} else if trigger.Name != name {
+			return nil, fmt.Errorf("the name of the threshold %q does not match the request %q", trigger.Name, r.URL.Path)
+		}
+		if err := m.SetTrigger(trigger); err != nil {
+			return nil, fmt.Errorf("failed to set threshold %q with error: %v", name, err)
+		}
+	case "DELETE":
+		if err := m.RemoveTrigger(name); err != nil {
+			return nil, fmt.Errorf("failed to remove threshold %q with error: %v", name, err)
+		}
+		return []alert.Trigger{}, nil
+	}
 
-		// Get the subcontainers.
-		containers, err := m.SubcontainersInfo(containerName, query)
-		if err != nil {
-			return fmt.Errorf("failed to get subcontainers for container %q with error: %s", containerName, err)
+	triggers := m.GetTriggers()
+	if name == "" {
+		return triggers, nil
+	}
+	for _, trigger := range triggers {
+		if trigger.Name == name {
+			return []alert.Trigger{trigger}, nil
 		}
+	}
+	return nil, fmt.Errorf("unknown threshold %q", name)
+}
 
-		// Only output the containers as JSON.
-		err = writeResult(containers, w)
-		if err != nil {
-			return err
+func handleConfig(m manager.Manager, args string, r *http.Request) (interface{}, error) {
+	glog.V(2).Infof("Api - Config %s", r.Method)
+
+	switch r.Method {
+	case "POST", "PUT":
+		settings := make(map[string]string)
+		if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+			return nil, fmt.Errorf("unable to decode the json value: %s", err)
 		}
-	case requestType == dockerApi:
-		if version == version1_0 || version == version1_1 {
-			return fmt.Errorf("request type of %q not supported in API version %q", requestType, version)
+		if err := config.Update(settings); err != nil {
+			return nil, fmt.Errorf("failed to update the settings with error: %v", err)
 		}
+	}
+	return config.Reloadable(), nil
+}
 
-		containerName = strings.TrimLeft(containerName, "/")
-		glog.V(2).Infof("Api - Docker(%s)", containerName)
+// URL parameters selecting the types of events.
+var eventTypeParams = map[string]info.EventType{
+	"creation_events":  info.EventContainerCreation,
+	"deletion_events":  info.EventContainerDeletion,
+	"oom_events":       info.EventOom,
+	"threshold_events": info.EventThreshold,
+}
 
-		// Get the query request.
-		query, err := getContainerInfoRequest(r.Body)
+// Returns the events request of the URL parameters of an events request.
+func getEventRequest(args string, r *http.Request) (*events.Request, error) {
+	query := r.URL.Query()
+	request := &events.Request{
+		ContainerName:        path.Join("/", args),
+		IncludeSubcontainers: query.Get("subcontainers") == "true",
+		EventType:            make(map[info.EventType]bool),
+	}
+	var err error
+	if value := query.Get("start_time"); value != "" {
+		request.StartTime, err = time.Parse(time.RFC3339, value)
 		if err != nil {
-			return err
+			return nil, fmt.Errorf("invalid start_time %q: %v", value, err)
 		}
-
-		var containers map[string]info.ContainerInfo
-		if containerName == "" {
-			// Get all Docker containers.
-			containers, err = m.AllDockerContainers(query)
-			if err != nil {
-				return fmt.Errorf("failed to get all Docker containers with error: %v", err)
-			}
-		} else {
-			// Get one Docker container.
-			var cont info.ContainerInfo
-			cont, err = m.DockerContainer(containerName, query)
-			if err != nil {
-				return fmt.Errorf("failed to get Docker container %q with error: %v", containerName, err)
-			}
-			containers = map[string]info.ContainerInfo{
-				cont.Name: cont,
+	}
+	if value := query.Get("end_time"); value != "" {
+		request.EndTime, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid end_time %q: %v", value, err)
+		}
+	}
+	if value := query.Get("max_events"); value != "" {
+		request.MaxEventsReturned, err = strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid max_events %q: %v", value, err)
+		}
+	}
+	if query.Get("all_events") != "true" {
+		for param, eventType := range eventTypeParams {
+			if query.Get(param) == "true" {
+				request.EventType[eventType] = true
 			}
 		}
+	}
+	return request, nil
+}
 
-		// Only output the containers as JSON.
-		err = writeResult(containers, w)
-		if err != nil {
-			return err
+func handleEvents(m manager.Manager, args string, r *http.Request) (interface{}, error) {
+	request, err := getEventRequest(args, r)
+	if err != nil {
+		return nil, err
+	}
+	glog.V(2).Infof("Api - Events(%+v)", request)
+
+	pastEvents, err := m.GetPastEvents(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the events of container %q with error: %v", request.ContainerName, err)
+	}
+	return pastEvents, nil
+}
+
+// Writes the new events matching the request as JSON objects, one per line, until the client goes
+// away.
+func streamEvents(m manager.Manager, args string, w http.ResponseWriter, r *http.Request) error {
+	request, err := getEventRequest(args, r)
+	if err != nil {
+		return err
+	}
+	glog.V(2).Infof("Api - Events(%+v) stream", request)
+
+	watch, err := m.WatchForEvents(request)
+	if err != nil {
+		return fmt.Errorf("failed to watch the events of container %q with error: %v", request.ContainerName, err)
+	}
+	defer m.CloseEventChannel(watch.GetWatchId())
+
+	var closed <-chan bool
+	if notifier, ok := w.(http.CloseNotifier); ok {
+		closed = notifier.CloseNotify()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	flush(w)
+	encoder := json.NewEncoder(w)
+	for {
+		select {
+		case e, ok := <-watch.GetChannel():
+			if !ok {
+				return nil
+			}
+			if err := encoder.Encode(e); err != nil {
+				// The client went away.
+				glog.V(2).Infof("Stopped streaming events: %v", err)
+				return nil
+			}
+			flush(w)
+		case <-closed:
+			return nil
 		}
-	default:
-		return fmt.Errorf("unknown API request type %q", requestType)
 	}
+}
 
-	return nil
+func flush(w http.ResponseWriter) {
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
 }
 
 func writeResult(res interface{}, w http.ResponseWriter) error {
@@ -0,0 +1,154 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/info"
+	"github.com/google/cadvisor/utils/httpauth"
+)
+
+func TestGetEventRequest(t *testing.T) {
+	r, err := http.NewRequest("GET", "/api/v1.3/events/docker?subcontainers=true&oom_events=true&creation_events=true&max_events=10&start_time=2015-01-02T15:04:05Z", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request, err := getEventRequest("docker", r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request.ContainerName != "/docker" || !request.IncludeSubcontainers || request.MaxEventsReturned != 10 {
+		t.Errorf("unexpected request %+v", request)
+	}
+	if len(request.EventType) != 2 || !request.EventType[info.EventOom] || !request.EventType[info.EventContainerCreation] {
+		t.Errorf("unexpected event types %v", request.EventType)
+	}
+	if !request.StartTime.Equal(time.Date(2015, 1, 2, 15, 4, 5, 0, time.UTC)) || !request.EndTime.IsZero() {
+		t.Errorf("unexpected time range %v to %v", request.StartTime, request.EndTime)
+	}
+
+	// All the types of events.
+	r, _ = http.NewRequest("GET", "/api/v1.3/events/?all_events=true&oom_events=true", nil)
+	if request, err = getEventRequest("", r); err != nil {
+		t.Fatal(err)
+	}
+	if request.ContainerName != "/" || len(request.EventType) != 0 {
+		t.Errorf("unexpected request %+v", request)
+	}
+
+	r, _ = http.NewRequest("GET", "/api/v1.3/events/?start_time=yesterday", nil)
+	if _, err := getEventRequest("", r); err == nil {
+		t.Error("expected an error parsing an invalid start_time")
+	}
+}
+
+func TestRequestScope(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		scope  httpauth.Scope
+	}{
+		{"GET", "/api/v1.3/containers/docker", httpauth.ScopeStats},
+		{"GET", "/api/v2.0/flat/", httpauth.ScopeStats},
+		{"GET", "/api/spec", httpauth.ScopeStats},
+		{"GET", "/containers/", httpauth.ScopeStats},
+		{"GET", "/api/v1.3/events/docker", httpauth.ScopeEvents},
+		{"GET", "/api/v2.0/config", httpauth.ScopeAdmin},
+		{"POST", "/api/v1.0/containers", httpauth.ScopeStats},
+		{"POST", "/api/v1.3/subcontainers/docker", httpauth.ScopeStats},
+		{"POST", "/api/v1.3/docker/abc", httpauth.ScopeStats},
+		{"POST", "/api/v2.0/config", httpauth.ScopeAdmin},
+		{"POST", "/api/v1.3/collectors/nginx", httpauth.ScopeAdmin},
+		{"POST", "/api/v2.0/thresholds/docker", httpauth.ScopeAdmin},
+		{"POST", "/containers/", httpauth.ScopeAdmin},
+		{"DELETE", "/api/v2.0/collectors/nginx", httpauth.ScopeAdmin},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest(test.method, test.path, nil)
+		if scope := RequestScope(r); scope != test.scope {
+			t.Errorf("expected scope %q for %s %s, got %q", test.scope, test.method, test.path, scope)
+		}
+	}
+}
+
+func TestRequestScopeTokens(t *testing.T) {
+	dir, err := ioutil.TempDir("", "api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tokenFile := filepath.Join(dir, "tokens")
+	if err := ioutil.WriteFile(tokenFile, []byte("scraper stats\noperator admin\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler, err := httpauth.New(ok, httpauth.Config{TokenFile: tokenFile, Scope: RequestScope})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		method string
+		path   string
+		token  string
+		code   int
+	}{
+		// Queries of the client library.
+		{"POST", "/api/v1.3/containers/docker", "scraper", http.StatusOK},
+		{"POST", "/api/v1.3/subcontainers/", "scraper", http.StatusOK},
+		{"POST", "/api/v1.3/docker/", "scraper", http.StatusOK},
+		{"GET", "/api/v1.3/machine", "scraper", http.StatusOK},
+		// Changes.
+		{"POST", "/api/v2.0/config", "scraper", http.StatusForbidden},
+		{"POST", "/api/v1.3/collectors/nginx", "scraper", http.StatusForbidden},
+		{"POST", "/api/v1.3/thresholds/memory", "scraper", http.StatusForbidden},
+		{"DELETE", "/api/v1.3/thresholds/memory", "scraper", http.StatusForbidden},
+		{"POST", "/api/v1.3/thresholds/memory", "operator", http.StatusOK},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest(test.method, test.path, nil)
+		r.Header.Set("Authorization", "Bearer "+test.token)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != test.code {
+			t.Errorf("expected %d for %s %s with %q, got %d", test.code, test.method, test.path, test.token, w.Code)
+		}
+	}
+}
+
+func TestRequestContainer(t *testing.T) {
+	tests := map[string]string{
+		"/api/v1.3/containers/docker/abc": "/docker/abc",
+		"/api/v1.3/containers/":           "/",
+		"/api/v1.3/subcontainers":         "/",
+		"/api/v1.3/docker/web":            "web",
+		"/api/v1.3/docker/":               "",
+		"/api/v1.3/machine":               "",
+		"/api/v2.0/thresholds/high_cpu":   "",
+		"/containers/docker":              "",
+	}
+	for p, container := range tests {
+		r, _ := http.NewRequest("GET", p, nil)
+		if c := RequestContainer(r); c != container {
+			t.Errorf("expected container %q for %s, got %q", container, p, c)
+		}
+	}
+}
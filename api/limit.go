@@ -0,0 +1,161 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+var apiRateLimit = flag.Float64("api_rate_limit", 0, "Requests per second each client IP can make to the API on average, beyond --api_rate_burst. Others get a 429. No limit if 0")
+var apiRateBurst = flag.Int("api_rate_burst", 20, "Requests each client IP can make to the API at once, then limited by --api_rate_limit")
+var apiMaxRecursiveRequests = flag.Int("api_max_recursive_requests", 0, "Maximum number of API requests gathering many containers (e.g. subcontainers, snapshot, flat) served at once. Others get a 503. No limit if 0")
+
+// How often the buckets of clients which have not made requests for a while are dropped.
+const rateLimiterSweepInterval = time.Minute
+
+// Limits the rate of the requests of each client with a token bucket.
+type rateLimiter struct {
+	// Tokens added to the buckets per second.
+	rate float64
+	// Size of the buckets.
+	burst float64
+
+	lock      sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens float64
+	// Time the tokens were last counted.
+	last time.Time
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Takes a token from the bucket of the client, returning whether there was one and otherwise
+// how long until there is.
+func (self *rateLimiter) allow(client string, now time.Time) (bool, time.Duration) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if now.Sub(self.lastSweep) >= rateLimiterSweepInterval {
+		self.sweep(now)
+	}
+	b, ok := self.buckets[client]
+	if !ok {
+		b = &bucket{tokens: self.burst, last: now}
+		self.buckets[client] = b
+	}
+	b.tokens = math.Min(self.burst, b.tokens+now.Sub(b.last).Seconds()*self.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / self.rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// Drops the buckets which are full again, as good as new ones.
+func (self *rateLimiter) sweep(now time.Time) {
+	for client, b := range self.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*self.rate >= self.burst {
+			delete(self.buckets, client)
+		}
+	}
+	self.lastSweep = now
+}
+
+// Returns the IP address of the client of the request.
+func clientIp(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// The limits on the API requests, per the flags.
+type limits struct {
+	// Limits the rate of the requests of each client IP, nil if unlimited.
+	rate *rateLimiter
+	// Slots of the recursive requests served at once, nil if unlimited.
+	recursive chan struct{}
+}
+
+func newLimits() *limits {
+	self := &limits{}
+	if *apiRateLimit > 0 {
+		self.rate = newRateLimiter(*apiRateLimit, *apiRateBurst)
+	}
+	if *apiMaxRecursiveRequests > 0 {
+		self.recursive = make(chan struct{}, *apiMaxRecursiveRequests)
+	}
+	return self
+}
+
+// Wraps the handler with the rate limit of each client IP.
+func (self *limits) withRate(handler http.HandlerFunc) http.HandlerFunc {
+	if self.rate == nil {
+		return handler
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		client := clientIp(r)
+		if ok, wait := self.rate.allow(client, time.Now()); !ok {
+			glog.V(2).Infof("Rate limiting API request %s from %s", r.URL.Path, client)
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, fmt.Sprintf("too many requests from %s, limited to %g per second", client, self.rate.rate), http.StatusTooManyRequests)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// Takes a slot to serve a recursive request, returning false if there is none left. The slot is
+// given back with releaseRecursive.
+func (self *limits) acquireRecursive() bool {
+	if self.recursive == nil {
+		return true
+	}
+	select {
+	case self.recursive <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (self *limits) releaseRecursive() {
+	if self.recursive != nil {
+		<-self.recursive
+	}
+}
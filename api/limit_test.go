@@ -0,0 +1,90 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	limiter := newRateLimiter(2, 3)
+	now := time.Unix(1000, 0)
+	for i := 0; i < 3; i++ {
+		if ok, _ := limiter.allow("10.0.0.1", now); !ok {
+			t.Fatalf("expected request %d of the burst to be allowed", i)
+		}
+	}
+	ok, wait := limiter.allow("10.0.0.1", now)
+	if ok || wait != 500*time.Millisecond {
+		t.Errorf("expected to wait 500ms, got %v %v", ok, wait)
+	}
+	if ok, _ := limiter.allow("10.0.0.2", now); !ok {
+		t.Errorf("expected other clients to be allowed")
+	}
+	if ok, _ := limiter.allow("10.0.0.1", now.Add(500*time.Millisecond)); !ok {
+		t.Errorf("expected a request to be allowed once a token was added")
+	}
+
+	// Clients idle long enough to have full buckets are dropped.
+	limiter.allow("10.0.0.1", now.Add(time.Hour))
+	if len(limiter.buckets) != 1 {
+		t.Errorf("expected only the bucket of the active client, got %v", limiter.buckets)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	limits := &limits{
+		rate:      newRateLimiter(1, 1),
+		recursive: make(chan struct{}, 1),
+	}
+	handler := limits.withRate(func(w http.ResponseWriter, r *http.Request) {})
+	serve := func(remoteAddr string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest("GET", "/api/v1.3/machine", nil)
+		r.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w
+	}
+	if w := serve("10.0.0.1:1234"); w.Code != http.StatusOK {
+		t.Errorf("expected the first request to be allowed, got %d", w.Code)
+	}
+	// Clients are identified by their IP, not their port.
+	w := serve("10.0.0.1:1235")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "1" {
+		t.Errorf("expected the second request to be limited, got %d %q", w.Code, w.Header().Get("Retry-After"))
+	}
+
+	if !limits.acquireRecursive() {
+		t.Fatal("expected a recursive request to be allowed")
+	}
+	if limits.acquireRecursive() {
+		t.Errorf("expected a second recursive request to be refused")
+	}
+	limits.releaseRecursive()
+	if !limits.acquireRecursive() {
+		t.Errorf("expected a recursive request to be allowed once the first completed")
+	}
+
+	// No limits.
+	unlimited := newLimits()
+	for i := 0; i < 10; i++ {
+		if !unlimited.acquireRecursive() {
+			t.Fatal("expected no limit on recursive requests")
+		}
+	}
+}
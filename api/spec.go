@@ -0,0 +1,171 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// A JSON object of the API spec.
+type specObject map[string]interface{}
+
+// Generates an OpenAPI (Swagger 2.0) description of all the API versions from apiHandlers.
+func generateSpec() specObject {
+	definitions := specObject{}
+	paths := specObject{}
+	for i, version := range apiVersions {
+		for _, handler := range apiHandlers {
+			if versionIndex(handler.minVersion) > i {
+				continue
+			}
+			resource := fmt.Sprintf("/%s/%s", version, handler.requestType)
+			params := []specObject{}
+			if handler.argument != "" {
+				resource += "/{args}"
+				params = append(params, specObject{
+					"name":        "args",
+					"in":          "path",
+					"required":    true,
+					"type":        "string",
+					"description": handler.argument,
+				})
+			}
+			operation := specObject{
+				"operationId": handler.requestType + strings.Replace(version, ".", "_", -1),
+				"summary":     handler.description,
+				"parameters":  params,
+				"responses": specObject{
+					"200": specObject{
+						"description": "OK",
+						"schema":      schemaOf(reflect.TypeOf(handler.response), definitions),
+					},
+					"500": specObject{
+						"description": "Error processing the request.",
+					},
+				},
+			}
+			item := specObject{"get": operation}
+			if handler.query != nil {
+				// The query may also be sent in the body of a POST.
+				post := specObject{}
+				for k, v := range operation {
+					post[k] = v
+				}
+				post["operationId"] = operation["operationId"].(string) + "Post"
+				post["parameters"] = append(params, specObject{
+					"name":     "query",
+					"in":       "body",
+					"required": false,
+					"schema":   schemaOf(reflect.TypeOf(handler.query), definitions),
+				})
+				item["post"] = post
+			}
+			if handler.deletable {
+				del := specObject{}
+				for k, v := range operation {
+					del[k] = v
+				}
+				del["operationId"] = operation["operationId"].(string) + "Delete"
+				item["delete"] = del
+			}
+			paths[resource] = item
+		}
+	}
+
+	return specObject{
+		"swagger": "2.0",
+		"info": specObject{
+			"title":   "cAdvisor",
+			"version": apiVersions[len(apiVersions)-1],
+		},
+		"basePath":    strings.TrimSuffix(apiResource, "/"),
+		"consumes":    []string{"application/json"},
+		"produces":    []string{"application/json"},
+		"paths":       paths,
+		"definitions": definitions,
+	}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Returns the JSON schema of the specified type as encoded by encoding/json.
+// Structs are added to definitions and referenced by name.
+func schemaOf(t reflect.Type, definitions specObject) specObject {
+	if t == timeType {
+		return specObject{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemaOf(t.Elem(), definitions)
+	case reflect.Bool:
+		return specObject{"type": "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return specObject{"type": "integer", "format": "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return specObject{"type": "integer", "format": "int64"}
+	case reflect.Float32:
+		return specObject{"type": "number", "format": "float"}
+	case reflect.Float64:
+		return specObject{"type": "number", "format": "double"}
+	case reflect.String:
+		return specObject{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return specObject{"type": "array", "items": schemaOf(t.Elem(), definitions)}
+	case reflect.Map:
+		return specObject{"type": "object", "additionalProperties": schemaOf(t.Elem(), definitions)}
+	case reflect.Struct:
+		ref := specObject{"$ref": "#/definitions/" + t.Name()}
+		if _, ok := definitions[t.Name()]; ok {
+			return ref
+		}
+		// Reserve the name before recursing in case the type refers to itself.
+		definitions[t.Name()] = nil
+		properties := specObject{}
+		addProperties(t, properties, definitions)
+		definitions[t.Name()] = specObject{
+			"type":       "object",
+			"properties": properties,
+		}
+		return ref
+	}
+	// Interfaces can hold any value.
+	return specObject{}
+}
+
+// Adds the JSON properties of the fields of the struct type t, including those of embedded structs.
+func addProperties(t reflect.Type, properties specObject, definitions specObject) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			// Unexported.
+			continue
+		}
+		name := field.Name
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		if tagName := strings.Split(tag, ",")[0]; tagName != "" {
+			name = tagName
+		} else if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			addProperties(field.Type, properties, definitions)
+			continue
+		}
+		properties[name] = schemaOf(field.Type, definitions)
+	}
+}
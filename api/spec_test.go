@@ -0,0 +1,69 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSpecCoversAllHandlers(t *testing.T) {
+	spec := generateSpec()
+	if _, err := json.Marshal(spec); err != nil {
+		t.Fatalf("failed to marshal spec: %v", err)
+	}
+
+	paths := spec["paths"].(specObject)
+	for _, handler := range apiHandlers {
+		resource := "/" + apiVersions[len(apiVersions)-1] + "/" + handler.requestType
+		if handler.argument != "" {
+			resource += "/{args}"
+		}
+		item, ok := paths[resource].(specObject)
+		if !ok {
+			t.Errorf("no path %q in spec", resource)
+			continue
+		}
+		if _, ok := item["post"]; ok != (handler.query != nil) {
+			t.Errorf("path %q has POST %v, expected %v", resource, ok, handler.query != nil)
+		}
+	}
+
+	// Request types are not available before their minimum version.
+	if _, ok := paths["/"+version1_0+"/"+dockerApi+"/{args}"]; ok {
+		t.Errorf("docker request type should not be in %s", version1_0)
+	}
+}
+
+func TestSpecSchemas(t *testing.T) {
+	definitions := generateSpec()["definitions"].(specObject)
+	containerInfo, ok := definitions["ContainerInfo"].(specObject)
+	if !ok {
+		t.Fatalf("no ContainerInfo definition in %v", definitions)
+	}
+	properties := containerInfo["properties"].(specObject)
+
+	// Fields of the embedded ContainerReference are inlined.
+	if _, ok := properties["name"]; !ok {
+		t.Errorf("ContainerInfo is missing the embedded name property: %v", properties)
+	}
+	stats, ok := properties["stats"].(specObject)
+	if !ok || stats["type"] != "array" {
+		t.Errorf("expected stats to be an array, got %v", properties["stats"])
+	}
+	if _, ok := definitions["ContainerStats"]; !ok {
+		t.Errorf("no ContainerStats definition")
+	}
+}
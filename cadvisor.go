@@ -15,40 +15,69 @@
 package main
 
 import (
+	"crypto/tls"
 	"flag"
 	"fmt"
+	"net"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
 	"os/signal"
+	"os/user"
+	"path"
 	"runtime"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
-	auth "github.com/abbot/go-http-auth"
 	"github.com/golang/glog"
+	"github.com/google/cadvisor/accelerators"
 	"github.com/google/cadvisor/api"
-	"github.com/google/cadvisor/container/docker"
-	"github.com/google/cadvisor/container/raw"
+	"github.com/google/cadvisor/config"
 	"github.com/google/cadvisor/healthz"
 	"github.com/google/cadvisor/info"
 	"github.com/google/cadvisor/manager"
 	"github.com/google/cadvisor/pages"
 	"github.com/google/cadvisor/pages/static"
+	"github.com/google/cadvisor/storage"
+	"github.com/google/cadvisor/utils"
+	"github.com/google/cadvisor/utils/audit"
+	"github.com/google/cadvisor/utils/graceful"
+	"github.com/google/cadvisor/utils/httpauth"
+	"github.com/google/cadvisor/utils/lsm"
+	"github.com/google/cadvisor/utils/privileges"
+	"github.com/google/cadvisor/utils/selflimit"
 	"github.com/google/cadvisor/utils/sysfs"
+	"github.com/google/cadvisor/utils/systemd"
 	"github.com/google/cadvisor/validate"
 )
 
 var argIp = flag.String("listen_ip", "", "IP to listen on, defaults to all IPs")
 var argPort = flag.Int("port", 8080, "port to listen")
+var listenUnix = flag.String("listen_unix", "", "Unix socket to listen on as well as the TCP port, or instead of it with --port=0")
+var listenUnixMode = flag.String("listen_unix_mode", "0660", "Permissions (octal) of the unix socket of --listen_unix")
+var listenUnixGroup = flag.String("listen_unix_group", "", "Group (name or id) owning the unix socket of --listen_unix. Empty keeps the group of cAdvisor")
 var maxProcs = flag.Int("max_procs", 0, "max number of CPUs that can be used simultaneously. Less than 1 for default (number of cores).")
 
-var argDbDriver = flag.String("storage_driver", "", "storage driver to use. Data is always cached shortly in memory, this controls where data is pushed besides the local cache. Empty means none. Options are: <empty> (default), bigquery, and influxdb")
+var argDbDriver = flag.String("storage_driver", "", "storage driver to use. Data is always cached shortly in memory, this controls where data is pushed besides the local cache. Empty means none, a comma-separated list writes to several drivers. Options are: <empty> (default), bigquery, disk, elasticsearch, graphite, influxdb, kafka, mmap, mqtt, nats, opentsdb, redis, statsd, and any other registered storage driver")
 var versionFlag = flag.Bool("version", false, "print cAdvisor version and exit")
+var shutdownTimeout = flag.Duration("shutdown_timeout", 10*time.Second, "Time to wait on SIGTERM or SIGINT for the HTTP requests in progress to complete, for up to half of it, and for the storage drivers to write the stats they buffer, for the rest of it, before exiting")
 
-var httpAuthFile = flag.String("http_auth_file", "", "HTTP auth file for the web UI")
-var httpAuthRealm = flag.String("http_auth_realm", "localhost", "HTTP auth realm for the web UI")
-var httpDigestFile = flag.String("http_digest_file", "", "HTTP digest file for the web UI")
-var httpDigestRealm = flag.String("http_digest_realm", "localhost", "HTTP digest file for the web UI")
+var httpAuthFile = flag.String("http_auth_file", "", "HTTP auth file (htpasswd format) of the users of the web UI and API, with basic authentication")
+var httpAuthRealm = flag.String("http_auth_realm", "localhost", "HTTP auth realm for the web UI and API")
+var httpDigestFile = flag.String("http_digest_file", "", "HTTP digest file (htdigest format) of the users of the web UI and API, with digest authentication. Ignored with --http_auth_file")
+var httpDigestRealm = flag.String("http_digest_realm", "localhost", "HTTP digest realm for the web UI and API, the realm of the users in --http_digest_file")
+var httpTokenFile = flag.String("http_token_file", "", "File of the bearer tokens allowed to access the web UI and API, one per line followed by its comma-separated scopes: stats (read-only stats and specs), events and admin (everything, including changing the config)")
+
+var runAsUser = flag.String("user", "", "User (user[:group], by name or id) cAdvisor runs as once it did what needs root, e.g. listening and moving to --self_cgroup, keeping only --keep_capabilities. Runs as it was started if empty")
+var keepCapabilities = flag.String("keep_capabilities", "dac_read_search,sys_ptrace", "Comma-separated capabilities kept when running as --user, e.g. dac_read_search to read the files of cgroups and containers and sys_ptrace to read the stats of the processes of other users")
+
+var auditLog = flag.String("audit_log", "", "File the accesses to the web UI and API are appended to as JSON, one per line, or journald to send them to the journal of systemd. Disabled if empty")
+
+var tlsCertFile = flag.String("tls_cert_file", "", "Certificate file (PEM) to serve the web UI and API over HTTPS with, along with --tls_key_file. Empty serves plain HTTP")
+var tlsKeyFile = flag.String("tls_key_file", "", "Private key file (PEM) of --tls_cert_file")
+var tlsClientCaFile = flag.String("tls_client_ca_file", "", "CA certificates file (PEM). If set, HTTPS clients must present a certificate signed by one of them")
 
 func main() {
 	defer glog.Flush()
@@ -59,7 +88,30 @@ func main() {
 		os.Exit(0)
 	}
 
+	if err := config.Load(); err != nil {
+		glog.Fatalf("Failed to load the config file: %v", err)
+	}
+
 	setMaxProcs()
+	if privileges.Dropped() {
+		// The limits were applied before dropping the privileges.
+		selflimit.WatchBudget()
+	} else if err := selflimit.Apply(); err != nil {
+		glog.Fatalf("Failed to limit the resources of cAdvisor: %v", err)
+	}
+
+	if status := lsm.Detect(); status.Confined() {
+		glog.Infof("Running confined: %s. Metrics whose files are denied are not collected, see /validate", status)
+	}
+
+	// Listen before dropping the privileges, e.g. on a privileged port.
+	listeners, err := openListeners()
+	if err != nil {
+		glog.Fatal(err)
+	}
+	if *runAsUser != "" {
+		dropPrivileges(listeners)
+	}
 
 	storageDriver, err := NewStorageDriver(*argDbDriver)
 	if err != nil {
@@ -71,19 +123,25 @@ func main() {
 		glog.Fatalf("Failed to create a system interface: %s", err)
 	}
 
-	containerManager, err := manager.New(storageDriver, sysFs)
+	options := manager.DefaultOptions()
+	options.StorageDriver = storageDriver
+	options.SysFs = sysFs
+	containerManager, err := manager.New(options)
 	if err != nil {
 		glog.Fatalf("Failed to create a Container Manager: %s", err)
 	}
 
-	// Register Docker.
-	if err := docker.Register(containerManager); err != nil {
-		glog.Errorf("Docker registration failed: %v.", err)
-	}
+	// Apply the settings updated on SIGHUP or through the API.
+	config.OnReload(func() error {
+		return containerManager.UpdateOptions(manager.DefaultOptions())
+	})
+	config.OnReload(storage.ReloadFilters)
 
-	// Register the raw driver.
-	if err := raw.Register(containerManager); err != nil {
-		glog.Fatalf("Raw registration failed: %v.", err)
+	registerFactories(containerManager)
+
+	// Find the GPUs of containers.
+	if err := accelerators.Setup(); err != nil {
+		glog.Infof("GPU stats disabled: %v.", err)
 	}
 
 	// Basic health handler.
@@ -107,36 +165,36 @@ func main() {
 	// Redirect / to containers page.
 	http.Handle("/", http.RedirectHandler(pages.ContainersPage, http.StatusTemporaryRedirect))
 
-	var authenticated bool = false
+	// Register the web UI.
+	http.HandleFunc(static.StaticResource, staticHandler)
+	if err := pages.RegisterHandlers(containerManager); err != nil {
+		glog.Fatalf("Failed to register pages handlers: %s", err)
+	}
 
-	// Setup the authenticator object
-	if *httpAuthFile != "" {
-		glog.Infof("Using auth file %s", *httpAuthFile)
-		secrets := auth.HtpasswdFileProvider(*httpAuthFile)
-		authenticator := auth.NewBasicAuthenticator(*httpAuthRealm, secrets)
-		http.HandleFunc(static.StaticResource, authenticator.Wrap(staticHandler))
-		if err := pages.RegisterHandlersBasic(containerManager, authenticator); err != nil {
-			glog.Fatalf("Failed to register pages auth handlers: %s", err)
-		}
-		authenticated = true
-	}
-	if *httpAuthFile == "" && *httpDigestFile != "" {
-		glog.Infof("Using digest file %s", *httpDigestFile)
-		secrets := auth.HtdigestFileProvider(*httpDigestFile)
-		authenticator := auth.NewDigestAuthenticator(*httpDigestRealm, secrets)
-		http.HandleFunc(static.StaticResource, authenticator.Wrap(staticHandler))
-		if err := pages.RegisterHandlersDigest(containerManager, authenticator); err != nil {
-			glog.Fatalf("Failed to register pages digest handlers: %s", err)
-		}
-		authenticated = true
+	// Authenticate the requests to the web UI and API, leaving the health checks open.
+	handler, err := httpauth.New(http.DefaultServeMux, httpauth.Config{
+		AuthFile:    *httpAuthFile,
+		AuthRealm:   *httpAuthRealm,
+		DigestFile:  *httpDigestFile,
+		DigestRealm: *httpDigestRealm,
+		TokenFile:   *httpTokenFile,
+		Scope:       requestScope,
+		Public:      []string{"/healthz"},
+	})
+	if err != nil {
+		glog.Fatalf("Failed to set up HTTP authentication: %v", err)
 	}
 
-	// Change handler based on authenticator initalization
-	if !authenticated {
-		http.HandleFunc(static.StaticResource, staticHandlerNoAuth)
-		if err := pages.RegisterHandlersBasic(containerManager, nil); err != nil {
-			glog.Fatalf("Failed to register pages handlers: %s", err)
-		}
+	// Log the accesses, including those which failed to authenticate.
+	handler, err = audit.New(handler, *auditLog, requestContainer)
+	if err != nil {
+		glog.Fatalf("Failed to set up the audit log: %v", err)
+	}
+
+	// Serve over HTTPS if given a certificate.
+	tlsConfig, err := serverTlsConfig()
+	if err != nil {
+		glog.Fatalf("Failed to set up TLS: %v", err)
 	}
 
 	// Start the manager.
@@ -145,12 +203,183 @@ func main() {
 	}
 
 	// Install signal handler.
-	installSignalHandler(containerManager)
+	server := graceful.New(handler)
+	installSignalHandler(containerManager, storageDriver, server)
+	installReloadHandler()
+
+	errs := make(chan error, len(listeners))
+	for _, listener := range listeners {
+		go func(l net.Listener) {
+			errs <- server.Serve(l)
+		}(withTls(listener, tlsConfig))
+	}
+
+	// Tell systemd we are ready once serving, and keep its watchdog happy while housekeeping
+	// progresses. The listeners were bound by openListeners, so connections made before the
+	// servers accept them wait in their backlog.
+	if _, err := systemd.Notify("READY=1"); err != nil {
+		glog.Errorf("Failed to notify systemd of readiness: %v", err)
+	}
+	if interval := systemd.WatchdogInterval(); interval > 0 {
+		go watchdog(containerManager, interval/2)
+	}
+	serveUntilShutdown(<-errs)
+}
+
+// Returns the listeners inherited from the cAdvisor which dropped its privileges, or passed by
+// systemd through socket activation, or else listens per the flags.
+func openListeners() ([]net.Listener, error) {
+	listeners, err := privileges.Listeners()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the listeners passed before dropping privileges: %v", err)
+	}
+	if len(listeners) > 0 {
+		glog.Infof("Starting cAdvisor version: %q as user %d on %d listeners", info.VERSION, os.Getuid(), len(listeners))
+		return listeners, nil
+	}
+	// Use the sockets passed by systemd if we were socket activated.
+	listeners, err = systemd.Listeners()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get socket activation listeners: %v", err)
+	}
+	if len(listeners) > 0 {
+		glog.Infof("Starting cAdvisor version: %q on %d socket activated listeners", info.VERSION, len(listeners))
+		return listeners, nil
+	}
+	return listen()
+}
+
+// Re-executes cAdvisor as the user of --user with the capabilities of --keep_capabilities, unless
+// it already was or was not started as root.
+func dropPrivileges(listeners []net.Listener) {
+	if privileges.Dropped() {
+		return
+	}
+	if os.Geteuid() != 0 {
+		glog.Warningf("Not dropping privileges to user %q: cAdvisor was not started as root", *runAsUser)
+		return
+	}
+	caps, err := privileges.ParseCapabilities(*keepCapabilities)
+	if err != nil {
+		glog.Fatalf("Invalid --keep_capabilities: %v", err)
+	}
+	glog.Infof("Dropping privileges to user %q with capabilities %q", *runAsUser, *keepCapabilities)
+	glog.Flush()
+	glog.Fatal(privileges.Drop(*runAsUser, caps, listeners))
+}
+
+// Listens on the unix socket and TCP port of the flags.
+func listen() ([]net.Listener, error) {
+	var listeners []net.Listener
+	if *listenUnix != "" {
+		mode, err := strconv.ParseUint(*listenUnixMode, 8, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --listen_unix_mode %q: %v", *listenUnixMode, err)
+		}
+		gid, err := lookupGroup(*listenUnixGroup)
+		if err != nil {
+			return nil, err
+		}
+		l, err := graceful.ListenUnix(*listenUnix, os.FileMode(mode), gid)
+		if err != nil {
+			return nil, err
+		}
+		glog.Infof("Starting cAdvisor version: %q on unix socket %s", info.VERSION, *listenUnix)
+		listeners = append(listeners, l)
+		if *argPort == 0 {
+			return listeners, nil
+		}
+	}
 
 	glog.Infof("Starting cAdvisor version: %q on port %d", info.VERSION, *argPort)
 
 	addr := fmt.Sprintf("%s:%d", *argIp, *argPort)
-	glog.Fatal(http.ListenAndServe(addr, nil))
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		for _, listener := range listeners {
+			listener.Close()
+		}
+		return nil, err
+	}
+	return append(listeners, l), nil
+}
+
+// Returns the id of the group with the name or id, -1 if empty.
+func lookupGroup(name string) (int, error) {
+	if name == "" {
+		return -1, nil
+	}
+	if gid, err := strconv.Atoi(name); err == nil {
+		return gid, nil
+	}
+	group, err := user.LookupGroup(name)
+	if err != nil {
+		return -1, err
+	}
+	return strconv.Atoi(group.Gid)
+}
+
+// Returns the scope a bearer token needs for the request. The profiles of cAdvisor need the admin
+// scope.
+func requestScope(r *http.Request) httpauth.Scope {
+	if strings.HasPrefix(r.URL.Path, "/debug/") {
+		return httpauth.ScopeAdmin
+	}
+	return api.RequestScope(r)
+}
+
+// Returns the container a request to the web UI or API is about, if any.
+func requestContainer(r *http.Request) string {
+	switch {
+	case strings.HasPrefix(r.URL.Path, pages.ContainersPage):
+		return path.Join("/", strings.TrimPrefix(r.URL.Path, pages.ContainersPage))
+	case strings.HasPrefix(r.URL.Path, pages.DockerPage):
+		return strings.TrimPrefix(r.URL.Path, pages.DockerPage)
+	}
+	return api.RequestContainer(r)
+}
+
+// Returns the TLS configuration to serve with, nil to serve plain HTTP.
+func serverTlsConfig() (*tls.Config, error) {
+	if *tlsCertFile == "" && *tlsKeyFile == "" {
+		if *tlsClientCaFile != "" {
+			return nil, fmt.Errorf("--tls_client_ca_file requires --tls_cert_file and --tls_key_file")
+		}
+		return nil, nil
+	}
+	if *tlsCertFile == "" || *tlsKeyFile == "" {
+		return nil, fmt.Errorf("--tls_cert_file and --tls_key_file must be given together")
+	}
+	return utils.NewServerTlsConfig(*tlsCertFile, *tlsKeyFile, *tlsClientCaFile)
+}
+
+func withTls(l net.Listener, config *tls.Config) net.Listener {
+	if config == nil {
+		return l
+	}
+	return tls.NewListener(l, config)
+}
+
+// Exits when serving failed, otherwise waits for the signal handler to exit once shut down.
+func serveUntilShutdown(err error) {
+	if err != nil {
+		glog.Fatal(err)
+	}
+	select {}
+}
+
+// Pings the systemd watchdog every interval unless container housekeeping stalls, in which case
+// systemd restarts cAdvisor.
+func watchdog(containerManager manager.Manager, interval time.Duration) {
+	for range time.Tick(interval) {
+		if err := containerManager.CheckHousekeeping(); err != nil {
+			glog.Errorf("Not notifying the systemd watchdog: %v", err)
+			continue
+		}
+		if _, err := systemd.Notify("WATCHDOG=1"); err != nil {
+			glog.Errorf("Failed to notify the systemd watchdog: %v", err)
+		}
+	}
 }
 
 func setMaxProcs() {
@@ -171,29 +400,62 @@ func setMaxProcs() {
 	}
 }
 
-func installSignalHandler(containerManager manager.Manager) {
+func installSignalHandler(containerManager manager.Manager, storageDriver storage.StorageDriver, server *graceful.Server) {
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, os.Kill, syscall.SIGTERM)
 
 	// Block until a signal is received.
 	go func() {
 		sig := <-c
-		if err := containerManager.Stop(); err != nil {
-			glog.Errorf("Failed to stop container manager: %v", err)
-		}
+		glog.Infof("Shutting down given signal: %v", sig)
+		shutdown(containerManager, storageDriver, server, *shutdownTimeout)
 		glog.Infof("Exiting given signal: %v", sig)
+		glog.Flush()
 		os.Exit(0)
 	}()
 }
 
-func staticHandlerNoAuth(w http.ResponseWriter, r *http.Request) {
-	err := static.HandleRequest(w, r.URL)
-	if err != nil {
-		fmt.Fprintf(w, "%s", err)
+// Reloads the config file on SIGHUP.
+func installReloadHandler() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGHUP)
+	go func() {
+		for range c {
+			glog.Infof("Reloading the config file given SIGHUP")
+			if err := config.Reload(); err != nil {
+				glog.Errorf("Failed to reload the config file: %v", err)
+			}
+		}
+	}()
+}
+
+// Drains the HTTP connections within half the timeout, then stops the housekeeping of containers
+// and writes the stats the storage drivers buffer within the rest of it. Streaming connections,
+// e.g. of events, never complete, so the drain must not take the time of the storage drivers.
+func shutdown(containerManager manager.Manager, storageDriver storage.StorageDriver, server *graceful.Server, timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	if err := server.Shutdown(timeout / 2); err != nil {
+		glog.Warningf("Failed to drain HTTP connections: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := containerManager.Stop(); err != nil {
+			glog.Errorf("Failed to stop container manager: %v", err)
+		}
+		if err := storageDriver.Close(); err != nil {
+			glog.Errorf("Failed to close storage driver: %v", err)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(deadline.Sub(time.Now())):
+		glog.Errorf("Storage drivers did not write the stats they buffer within %v", timeout)
 	}
 }
 
-func staticHandler(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+func staticHandler(w http.ResponseWriter, r *http.Request) {
 	err := static.HandleRequest(w, r.URL)
 	if err != nil {
 		fmt.Fprintf(w, "%s", err)
@@ -12,44 +12,95 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// TODO(cAdvisor): Package comment.
+// Package client is a client of the REST API of cAdvisor, decoding its responses into the
+// structs of the info package.
 package client
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"path"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/google/cadvisor/info"
 )
 
 // Client represents the base URL for a cAdvisor client.
 type Client struct {
+	// URL of the v1.2 API.
 	baseUrl string
+	// URL of the v1.3 API, for the endpoints added by that version.
+	v13Url string
+
+	// Client of the requests, and of the streams which do not time out once started.
+	httpClient   *http.Client
+	streamClient *http.Client
+	timeout      time.Duration
+	// Bearer token sent with the requests, if any.
+	token string
 }
 
-// NewClient returns a new client with the specified base URL.
-func NewClient(url string) (*Client, error) {
+// New returns a new client of the cAdvisor at the specified URL, e.g. "http://localhost:8080/".
+func New(url string) (*Client, error) {
 	if !strings.HasSuffix(url, "/") {
 		url += "/"
 	}
 
 	return &Client{
-		baseUrl: fmt.Sprintf("%sapi/v1.2/", url),
+		baseUrl:      fmt.Sprintf("%sapi/v1.2/", url),
+		v13Url:       fmt.Sprintf("%sapi/v1.3/", url),
+		httpClient:   &http.Client{},
+		streamClient: &http.Client{},
 	}, nil
 }
 
+// Sets the time after which requests fail, including reading their response. Streams fail if
+// they are not started in time. 0, the default, is no timeout. Not safe to call concurrently with
+// requests.
+func (self *Client) SetTimeout(timeout time.Duration) {
+	self.httpClient.Timeout = timeout
+	self.timeout = timeout
+}
+
+// SetToken sets the bearer token sent with the requests, for a cAdvisor started with
+// --http_token_file.
+func (self *Client) SetToken(token string) {
+	self.token = token
+}
+
+// Sets the Authorization header of the request if the client has a token.
+func (self *Client) authorize(req *http.Request) {
+	if self.token != "" {
+		req.Header.Set("Authorization", "Bearer "+self.token)
+	}
+}
+
+// NewClient returns a new client with the specified base URL.
+// Deprecated: use New.
+func NewClient(url string) (*Client, error) {
+	return New(url)
+}
+
 // MachineInfo returns the JSON machine information for this client.
 // A non-nil error result indicates a problem with obtaining
 // the JSON machine information data.
-func (self *Client) MachineInfo() (minfo *info.MachineInfo, err error) {
+func (self *Client) MachineInfo() (*info.MachineInfo, error) {
+	return self.MachineInfoContext(context.Background())
+}
+
+// MachineInfoContext is MachineInfo with a context cancelling the request.
+func (self *Client) MachineInfoContext(ctx context.Context) (minfo *info.MachineInfo, err error) {
 	u := self.machineInfoUrl()
 	ret := new(info.MachineInfo)
-	if err = self.httpGetJsonData(ret, nil, u, "machine info"); err != nil {
+	if err = self.httpGetJsonData(ctx, ret, nil, u, "machine info"); err != nil {
 		return
 	}
 	minfo = ret
@@ -58,10 +109,15 @@ func (self *Client) MachineInfo() (minfo *info.MachineInfo, err error) {
 
 // ContainerInfo returns the JSON container information for the specified
 // container and request.
-func (self *Client) ContainerInfo(name string, query *info.ContainerInfoRequest) (cinfo *info.ContainerInfo, err error) {
+func (self *Client) ContainerInfo(name string, query *info.ContainerInfoRequest) (*info.ContainerInfo, error) {
+	return self.ContainerInfoContext(context.Background(), name, query)
+}
+
+// ContainerInfoContext is ContainerInfo with a context cancelling the request.
+func (self *Client) ContainerInfoContext(ctx context.Context, name string, query *info.ContainerInfoRequest) (cinfo *info.ContainerInfo, err error) {
 	u := self.containerInfoUrl(name)
 	ret := new(info.ContainerInfo)
-	if err = self.httpGetJsonData(ret, query, u, fmt.Sprintf("container info for %q", name)); err != nil {
+	if err = self.httpGetJsonData(ctx, ret, query, u, fmt.Sprintf("container info for %q", name)); err != nil {
 		return
 	}
 	cinfo = ret
@@ -70,9 +126,14 @@ func (self *Client) ContainerInfo(name string, query *info.ContainerInfoRequest)
 
 // Returns the information about all subcontainers (recursive) of the specified container (including itself).
 func (self *Client) SubcontainersInfo(name string, query *info.ContainerInfoRequest) ([]info.ContainerInfo, error) {
+	return self.SubcontainersInfoContext(context.Background(), name, query)
+}
+
+// SubcontainersInfoContext is SubcontainersInfo with a context cancelling the request.
+func (self *Client) SubcontainersInfoContext(ctx context.Context, name string, query *info.ContainerInfoRequest) ([]info.ContainerInfo, error) {
 	var response []info.ContainerInfo
 	url := self.subcontainersInfoUrl(name)
-	err := self.httpGetJsonData(&response, query, url, fmt.Sprintf("subcontainers container info for %q", name))
+	err := self.httpGetJsonData(ctx, &response, query, url, fmt.Sprintf("subcontainers container info for %q", name))
 	if err != nil {
 		return []info.ContainerInfo{}, err
 
@@ -82,10 +143,15 @@ func (self *Client) SubcontainersInfo(name string, query *info.ContainerInfoRequ
 
 // Returns the JSON container information for the specified
 // Docker container and request.
-func (self *Client) DockerContainer(name string, query *info.ContainerInfoRequest) (cinfo info.ContainerInfo, err error) {
+func (self *Client) DockerContainer(name string, query *info.ContainerInfoRequest) (info.ContainerInfo, error) {
+	return self.DockerContainerContext(context.Background(), name, query)
+}
+
+// DockerContainerContext is DockerContainer with a context cancelling the request.
+func (self *Client) DockerContainerContext(ctx context.Context, name string, query *info.ContainerInfoRequest) (cinfo info.ContainerInfo, err error) {
 	u := self.dockerInfoUrl(name)
 	ret := make(map[string]info.ContainerInfo)
-	if err = self.httpGetJsonData(&ret, query, u, fmt.Sprintf("Docker container info for %q", name)); err != nil {
+	if err = self.httpGetJsonData(ctx, &ret, query, u, fmt.Sprintf("Docker container info for %q", name)); err != nil {
 		return
 	}
 	if len(ret) != 1 {
@@ -99,10 +165,15 @@ func (self *Client) DockerContainer(name string, query *info.ContainerInfoReques
 }
 
 // Returns the JSON container information for all Docker containers.
-func (self *Client) AllDockerContainers(query *info.ContainerInfoRequest) (cinfo []info.ContainerInfo, err error) {
+func (self *Client) AllDockerContainers(query *info.ContainerInfoRequest) ([]info.ContainerInfo, error) {
+	return self.AllDockerContainersContext(context.Background(), query)
+}
+
+// AllDockerContainersContext is AllDockerContainers with a context cancelling the request.
+func (self *Client) AllDockerContainersContext(ctx context.Context, query *info.ContainerInfoRequest) (cinfo []info.ContainerInfo, err error) {
 	u := self.dockerInfoUrl("/")
 	ret := make(map[string]info.ContainerInfo)
-	if err = self.httpGetJsonData(&ret, query, u, "all Docker containers info"); err != nil {
+	if err = self.httpGetJsonData(ctx, &ret, query, u, "all Docker containers info"); err != nil {
 		return
 	}
 	cinfo = make([]info.ContainerInfo, 0, len(ret))
@@ -112,6 +183,220 @@ func (self *Client) AllDockerContainers(query *info.ContainerInfoRequest) (cinfo
 	return
 }
 
+// Selects the events returned by Events and EventStream. The zero value selects all the events
+// of the container.
+type EventRequest struct {
+	// Whether to include the events of the subcontainers of the container.
+	Subcontainers bool
+
+	// Types of the events, all of them if empty.
+	Types []info.EventType
+
+	// Time range of the events, unbounded if zero, and the maximum number of events returned, the
+	// latest ones, if not 0. Only for Events: streams return the new events.
+	StartTime time.Time
+	EndTime   time.Time
+	MaxEvents int
+}
+
+// URL parameters selecting the types of events.
+var eventTypeParams = map[info.EventType]string{
+	info.EventContainerCreation: "creation_events",
+	info.EventContainerDeletion: "deletion_events",
+	info.EventOom:               "oom_events",
+	info.EventThreshold:         "threshold_events",
+}
+
+// Returns the past events of the specified container selected by the request, oldest first.
+func (self *Client) Events(name string, request *EventRequest) ([]*info.Event, error) {
+	return self.EventsContext(context.Background(), name, request)
+}
+
+// EventsContext is Events with a context cancelling the request.
+func (self *Client) EventsContext(ctx context.Context, name string, request *EventRequest) ([]*info.Event, error) {
+	u, err := self.eventsUrl(name, request, false)
+	if err != nil {
+		return nil, err
+	}
+	var events []*info.Event
+	if err := self.httpGetJsonData(ctx, &events, nil, u, fmt.Sprintf("events of %q", name)); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// Sends the new events of the specified container selected by the request to events as they
+// happen. It returns when the stream ends, with an error unless cAdvisor closed it.
+func (self *Client) EventStream(name string, request *EventRequest, events chan<- *info.Event) error {
+	return self.EventStreamContext(context.Background(), name, request, events)
+}
+
+// EventStreamContext is EventStream with a context ending the stream, it then returns the error
+// of the context.
+func (self *Client) EventStreamContext(ctx context.Context, name string, request *EventRequest, events chan<- *info.Event) error {
+	u, err := self.eventsUrl(name, request, true)
+	if err != nil {
+		return err
+	}
+	body, err := self.stream(ctx, u, fmt.Sprintf("events of %q", name))
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	decoder := json.NewDecoder(body)
+	for {
+		event := new(info.Event)
+		if err := decoder.Decode(event); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("unable to decode the events of %q: %v", name, err)
+		}
+		select {
+		case events <- event:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Returns a channel of the new events of the specified container selected by the request,
+// closed when the context is done or the stream ends. The error ending the stream, if any, is
+// sent to the error channel before it is closed; it is not when the context is done.
+func (self *Client) WatchEvents(ctx context.Context, name string, request *EventRequest) (<-chan *info.Event, <-chan error) {
+	events := make(chan *info.Event)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(errc)
+		defer close(events)
+		if err := self.EventStreamContext(ctx, name, request, events); err != nil && ctx.Err() == nil {
+			errc <- err
+		}
+	}()
+	return events, errc
+}
+
+// Returns a channel of the new stats of the specified container, polled every interval, closed
+// when the context is done or polling fails. cAdvisor keeps the stats of the last
+// --storage_duration in memory: samples are missed if the interval is longer. The error of
+// polling is sent to the error channel before it is closed; it is not when the context is done.
+func (self *Client) WatchStats(ctx context.Context, name string, interval time.Duration) (<-chan *info.ContainerStats, <-chan error) {
+	stats := make(chan *info.ContainerStats)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(errc)
+		defer close(stats)
+		var last time.Time
+		for {
+			cinfo, err := self.ContainerInfoContext(ctx, name, &info.ContainerInfoRequest{NumStats: statsPolled})
+			if err != nil {
+				if ctx.Err() == nil {
+					errc <- err
+				}
+				return
+			}
+			for _, s := range cinfo.StatsAfter(last) {
+				select {
+				case stats <- s:
+				case <-ctx.Done():
+					return
+				}
+				last = s.Timestamp
+			}
+			select {
+			case <-time.After(interval):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return stats, errc
+}
+
+// Number of stats requested by each poll of WatchStats.
+const statsPolled = 60
+
+// Starts a streaming request, returning the body of its response.
+func (self *Client) stream(ctx context.Context, url, infoName string) (io.ReadCloser, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	// The timeout only applies until the response starts.
+	ctx, cancel := context.WithCancel(ctx)
+	var timer *time.Timer
+	if self.timeout != 0 {
+		timer = time.AfterFunc(self.timeout, cancel)
+	}
+	self.authorize(req)
+	resp, err := self.streamClient.Do(req.WithContext(ctx))
+	if timer != nil && !timer.Stop() && err == nil {
+		resp.Body.Close()
+		err = fmt.Errorf("timed out after %v", self.timeout)
+	}
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("unable to stream the %s: %v", infoName, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		cancel()
+		return nil, fmt.Errorf("request failed with error: %q", strings.TrimSpace(string(body)))
+	}
+	return &streamBody{resp.Body, cancel}, nil
+}
+
+// Body of a streaming response, releasing its context when closed.
+type streamBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (self *streamBody) Close() error {
+	err := self.ReadCloser.Close()
+	self.cancel()
+	return err
+}
+
+func (self *Client) eventsUrl(name string, request *EventRequest, stream bool) (string, error) {
+	params := url.Values{}
+	if request == nil {
+		request = &EventRequest{}
+	}
+	if request.Subcontainers {
+		params.Set("subcontainers", "true")
+	}
+	for _, eventType := range request.Types {
+		param, ok := eventTypeParams[eventType]
+		if !ok {
+			return "", fmt.Errorf("unknown event type %q", eventType)
+		}
+		params.Set(param, "true")
+	}
+	if stream {
+		params.Set("stream", "true")
+	} else {
+		if !request.StartTime.IsZero() {
+			params.Set("start_time", request.StartTime.Format(time.RFC3339Nano))
+		}
+		if !request.EndTime.IsZero() {
+			params.Set("end_time", request.EndTime.Format(time.RFC3339Nano))
+		}
+		if request.MaxEvents != 0 {
+			params.Set("max_events", strconv.Itoa(request.MaxEvents))
+		}
+	}
+	u := self.v13Url + path.Join("events", name)
+	if len(params) != 0 {
+		u += "?" + params.Encode()
+	}
+	return u, nil
+}
+
 func (self *Client) machineInfoUrl() string {
 	return self.baseUrl + path.Join("machine")
 }
@@ -128,8 +413,8 @@ func (self *Client) dockerInfoUrl(name string) string {
 	return self.baseUrl + path.Join("docker", name)
 }
 
-func (self *Client) httpGetJsonData(data, postData interface{}, url, infoName string) error {
-	var resp *http.Response
+func (self *Client) httpGetJsonData(ctx context.Context, data, postData interface{}, url, infoName string) error {
+	var req *http.Request
 	var err error
 
 	if postData != nil {
@@ -137,10 +422,19 @@ func (self *Client) httpGetJsonData(data, postData interface{}, url, infoName st
 		if err != nil {
 			return fmt.Errorf("unable to marshal data: %v", err)
 		}
-		resp, err = http.Post(url, "application/json", bytes.NewBuffer(data))
+		req, err = http.NewRequest("POST", url, bytes.NewBuffer(data))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
 	} else {
-		resp, err = http.Get(url)
+		req, err = http.NewRequest("GET", url, nil)
+		if err != nil {
+			return err
+		}
 	}
+	self.authorize(req)
+	resp, err := self.httpClient.Do(req.WithContext(ctx))
 	if err != nil {
 		return fmt.Errorf("unable to get %q: %v", infoName, err)
 	}
@@ -15,6 +15,7 @@
 package client
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -184,3 +185,200 @@ func TestGetSubcontainersInfo(t *testing.T) {
 		t.Error("received unexpected ContainerInfo")
 	}
 }
+
+func TestEvents(t *testing.T) {
+	start := time.Date(2015, 1, 2, 15, 4, 5, 0, time.UTC)
+	expected := []*info.Event{
+		{ContainerName: "/some/container", Timestamp: start, EventType: info.EventContainerCreation},
+		{ContainerName: "/some/container/sub", Timestamp: start.Add(time.Second), EventType: info.EventOom, EventData: info.EventData{Oom: &info.OomEventData{Kills: 1}}},
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1.3/events/some/container" {
+			http.NotFound(w, r)
+			return
+		}
+		query := r.URL.Query()
+		if query.Get("subcontainers") != "true" || query.Get("oom_events") != "true" || query.Get("creation_events") != "true" || query.Get("start_time") != "2015-01-02T15:04:05Z" || query.Get("max_events") != "10" || query.Get("stream") != "" {
+			t.Errorf("unexpected query %q", r.URL.RawQuery)
+		}
+		json.NewEncoder(w).Encode(expected)
+	}))
+	defer ts.Close()
+	client, err := New(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := client.Events("/some/container", &EventRequest{
+		Subcontainers: true,
+		Types:         []info.EventType{info.EventContainerCreation, info.EventOom},
+		StartTime:     start,
+		MaxEvents:     10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("unexpected events %+v, expected %+v", events, expected)
+	}
+
+	if _, err := client.Events("/some/container", &EventRequest{Types: []info.EventType{"unknown"}}); err == nil {
+		t.Errorf("expected an error for an unknown event type")
+	}
+}
+
+func TestEventStream(t *testing.T) {
+	expected := []*info.Event{
+		{ContainerName: "/some/container", Timestamp: time.Date(2015, 1, 2, 15, 4, 5, 0, time.UTC), EventType: info.EventContainerCreation},
+		{ContainerName: "/some/container", Timestamp: time.Date(2015, 1, 2, 15, 4, 6, 0, time.UTC), EventType: info.EventContainerDeletion},
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1.3/events/some/container" || r.URL.Query().Get("stream") != "true" {
+			http.NotFound(w, r)
+			return
+		}
+		encoder := json.NewEncoder(w)
+		for _, event := range expected {
+			encoder.Encode(event)
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer ts.Close()
+	client, err := New(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := make(chan *info.Event, len(expected))
+	if err := client.EventStream("/some/container", nil, events); err != nil {
+		t.Fatal(err)
+	}
+	close(events)
+	var received []*info.Event
+	for event := range events {
+		received = append(received, event)
+	}
+	if !reflect.DeepEqual(received, expected) {
+		t.Errorf("unexpected events %+v, expected %+v", received, expected)
+	}
+
+	if err := client.EventStream("/missing", nil, events); err == nil {
+		t.Errorf("expected an error for a missing container")
+	}
+}
+
+func TestWatchEvents(t *testing.T) {
+	expected := &info.Event{ContainerName: "/some/container", Timestamp: time.Date(2015, 1, 2, 15, 4, 5, 0, time.UTC), EventType: info.EventOom}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(expected)
+		w.(http.Flusher).Flush()
+		// The stream stays open until the client cancels it.
+		<-r.Context().Done()
+	}))
+	defer ts.Close()
+	client, err := New(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, errc := client.WatchEvents(ctx, "/some/container", nil)
+	if event := <-events; !reflect.DeepEqual(event, expected) {
+		t.Errorf("unexpected event %+v, expected %+v", event, expected)
+	}
+	cancel()
+	if _, ok := <-events; ok {
+		t.Errorf("expected the events to be closed once cancelled")
+	}
+	if err, ok := <-errc; ok {
+		t.Errorf("expected no error once cancelled, got %v", err)
+	}
+}
+
+func TestWatchStats(t *testing.T) {
+	query := &info.ContainerInfoRequest{NumStats: 3}
+	cinfo := itest.GenerateRandomContainerInfo("/some/container", 4, query, time.Second)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(cinfo)
+	}))
+	defer ts.Close()
+	client, err := New(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stats, errc := client.WatchStats(ctx, "/some/container", time.Millisecond)
+	for i := range cinfo.Stats {
+		s := <-stats
+		if !s.Eq(cinfo.Stats[i]) {
+			t.Errorf("unexpected stats %+v, expected %+v", s, cinfo.Stats[i])
+		}
+	}
+	// The same stats are not sent again.
+	select {
+	case s := <-stats:
+		t.Errorf("unexpected stats %+v", s)
+	case <-time.After(20 * time.Millisecond):
+	}
+	cancel()
+	if _, ok := <-stats; ok {
+		t.Errorf("expected the stats to be closed once cancelled")
+	}
+	if err, ok := <-errc; ok {
+		t.Errorf("expected no error once cancelled, got %v", err)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer ts.Close()
+	client, err := New(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetTimeout(50 * time.Millisecond)
+
+	if _, err := client.MachineInfo(); err == nil {
+		t.Errorf("expected the request to time out")
+	}
+	events, errc := client.WatchEvents(context.Background(), "/", nil)
+	if _, ok := <-events; ok {
+		t.Errorf("expected no events from a stream that timed out")
+	}
+	if err := <-errc; err == nil {
+		t.Errorf("expected the stream to time out")
+	}
+}
+
+func TestToken(t *testing.T) {
+	var auth []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = append(auth, r.Header.Get("Authorization"))
+		if r.URL.Query().Get("stream") == "true" {
+			return
+		}
+		fmt.Fprint(w, `{"num_cores":8}`)
+	}))
+	defer ts.Close()
+	client, err := New(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetToken("secret")
+
+	if _, err := client.MachineInfo(); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.EventStream("/", nil, make(chan *info.Event)); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(auth, []string{"Bearer secret", "Bearer secret"}) {
+		t.Errorf("expected the token to be sent, got %q", auth)
+	}
+}
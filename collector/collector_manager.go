@@ -0,0 +1,124 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/cadvisor/info"
+)
+
+const metricLabelPrefix = "io.cadvisor.metric."
+
+// Returns the configuration files of the collectors declared by the labels of a container, by the
+// name of their collector: a label io.cadvisor.metric.<name> holds the path of the configuration
+// of collector <name> in the container.
+func GetCollectorConfigs(labels map[string]string) map[string]string {
+	configs := make(map[string]string)
+	for label, config := range labels {
+		if !strings.HasPrefix(label, metricLabelPrefix) {
+			continue
+		}
+		configs[strings.TrimPrefix(label, metricLabelPrefix)] = config
+	}
+	return configs
+}
+
+type collectorInfo struct {
+	collector Collector
+	// When to collect the metrics of the collector next.
+	nextCollectionTime time.Time
+	// The latest metrics collected.
+	metrics map[string][]info.MetricVal
+}
+
+type GenericCollectorManager struct {
+	lock       sync.Mutex
+	collectors []*collectorInfo
+	// When the next collector is due.
+	nextCollectionTime time.Time
+}
+
+func NewCollectorManager() (CollectorManager, error) {
+	return &GenericCollectorManager{}, nil
+}
+
+func (self *GenericCollectorManager) RegisterCollector(collector Collector) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	for _, c := range self.collectors {
+		if c.collector.Name() == collector.Name() {
+			return fmt.Errorf("collector %q is already registered", collector.Name())
+		}
+	}
+	self.collectors = append(self.collectors, &collectorInfo{collector: collector})
+	return nil
+}
+
+func (self *GenericCollectorManager) UnregisterCollector(name string) bool {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	for i, c := range self.collectors {
+		if c.collector.Name() == name {
+			self.collectors = append(self.collectors[:i], self.collectors[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (self *GenericCollectorManager) GetSpec() ([]info.MetricSpec, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	var specs []info.MetricSpec
+	for _, c := range self.collectors {
+		specs = append(specs, c.collector.GetSpec()...)
+	}
+	return specs, nil
+}
+
+func (self *GenericCollectorManager) Collect() (time.Time, map[string][]info.MetricVal, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	var errors []string
+	// Collect the metrics of the collectors that are due.
+	var next time.Time
+	metrics := make(map[string][]info.MetricVal)
+	for _, c := range self.collectors {
+		if c.nextCollectionTime.Before(time.Now()) {
+			var err error
+			c.nextCollectionTime, c.metrics, err = c.collector.Collect(make(map[string][]info.MetricVal))
+			if err != nil {
+				errors = append(errors, fmt.Sprintf("%s: %v", c.collector.Name(), err))
+			}
+		}
+		for name, values := range c.metrics {
+			metrics[name] = values
+		}
+
+		// Keep track of the next collector that will be due.
+		if next.IsZero() || next.After(c.nextCollectionTime) {
+			next = c.nextCollectionTime
+		}
+	}
+	self.nextCollectionTime = next
+	if len(errors) != 0 {
+		return next, metrics, fmt.Errorf("failed to collect the metrics of %s", strings.Join(errors, ", "))
+	}
+	return next, metrics, nil
+}
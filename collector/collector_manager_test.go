@@ -0,0 +1,120 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/info"
+)
+
+type fakeCollector struct {
+	name      string
+	next      time.Time
+	err       error
+	collected int
+}
+
+func (self *fakeCollector) Collect(metrics map[string][]info.MetricVal) (time.Time, map[string][]info.MetricVal, error) {
+	self.collected++
+	metrics[self.name] = []info.MetricVal{{IntValue: int64(self.collected)}}
+	return self.next, metrics, self.err
+}
+
+func (self *fakeCollector) Name() string {
+	return self.name
+}
+
+func (self *fakeCollector) GetSpec() []info.MetricSpec {
+	return []info.MetricSpec{{Name: self.name, Type: info.MetricGauge, Format: info.IntType}}
+}
+
+func TestCollect(t *testing.T) {
+	cm := &GenericCollectorManager{}
+	soon := &fakeCollector{name: "soon", next: time.Now().Add(time.Second)}
+	later := &fakeCollector{name: "later", next: time.Now().Add(time.Hour), err: fmt.Errorf("unreachable")}
+	if err := cm.RegisterCollector(soon); err != nil {
+		t.Fatal(err)
+	}
+	if err := cm.RegisterCollector(later); err != nil {
+		t.Fatal(err)
+	}
+	if err := cm.RegisterCollector(&fakeCollector{name: "soon"}); err == nil {
+		t.Error("expected registering a collector twice to fail")
+	}
+
+	// Both collectors are due at first.
+	next, metrics, err := cm.Collect()
+	if err == nil {
+		t.Error("expected the error of the later collector")
+	}
+	if next != soon.next {
+		t.Errorf("expected the next collection at %v, got %v", soon.next, next)
+	}
+	if len(metrics) != 2 {
+		t.Errorf("expected the metrics of both collectors, got %+v", metrics)
+	}
+
+	// Then only the one that is due is collected.
+	soon.next = time.Now().Add(time.Minute)
+	cm.collectors[0].nextCollectionTime = time.Time{}
+	if next, metrics, err = cm.Collect(); err != nil {
+		t.Fatal(err)
+	}
+	if next != soon.next || soon.collected != 2 || later.collected != 1 {
+		t.Errorf("expected only the soon collector to be collected, got %d and %d collections", soon.collected, later.collected)
+	}
+	// Along with the latest metrics of the other.
+	if len(metrics) != 2 || metrics["soon"][0].IntValue != 2 || metrics["later"][0].IntValue != 1 {
+		t.Errorf("expected the new metrics of the soon collector and the latest of the later one, got %+v", metrics)
+	}
+
+	spec, err := cm.GetSpec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []info.MetricSpec{
+		{Name: "soon", Type: info.MetricGauge, Format: info.IntType},
+		{Name: "later", Type: info.MetricGauge, Format: info.IntType},
+	}
+	if !reflect.DeepEqual(spec, expected) {
+		t.Errorf("expected %+v, got %+v", expected, spec)
+	}
+
+	// The metrics of unregistered collectors are dropped.
+	if !cm.UnregisterCollector("later") || cm.UnregisterCollector("later") {
+		t.Error("expected the later collector to be unregistered once")
+	}
+	if _, metrics, err = cm.Collect(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := metrics["later"]; ok || len(metrics) != 1 {
+		t.Errorf("expected only the metrics of the soon collector, got %+v", metrics)
+	}
+}
+
+func TestGetCollectorConfigs(t *testing.T) {
+	configs := GetCollectorConfigs(map[string]string{
+		"io.cadvisor.metric.nginx": "/etc/cadvisor/nginx.json",
+		"com.example.team":         "storage",
+	})
+	expected := map[string]string{"nginx": "/etc/cadvisor/nginx.json"}
+	if !reflect.DeepEqual(configs, expected) {
+		t.Errorf("expected %+v, got %+v", expected, configs)
+	}
+}
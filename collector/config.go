@@ -0,0 +1,110 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/cadvisor/info"
+)
+
+// Types of collectors.
+const (
+	jsonCollector       = "json"
+	prometheusCollector = "prometheus"
+)
+
+// Returns the collector described by configFile: a generic collector of a JSON endpoint, or a
+// collector of a Prometheus endpoint when the type of the configuration is prometheus.
+func NewCollectorFromConfig(name string, configFile []byte, ipAddress string) (Collector, error) {
+	var config struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(configFile, &config); err != nil {
+		return nil, fmt.Errorf("invalid configuration of collector %q: %v", name, err)
+	}
+	switch config.Type {
+	case "", jsonCollector:
+		return NewCollector(name, configFile, ipAddress)
+	case prometheusCollector:
+		return NewPrometheusCollector(name, configFile, ipAddress)
+	}
+	return nil, fmt.Errorf("collector %q has an unknown type %q, types are json and prometheus", name, config.Type)
+}
+
+// Configuration of a collector, read from the file the container declares in its labels.
+type Config struct {
+	// json, the default.
+	Type string `json:"type"`
+
+	// URL of the endpoint exposing the metrics, e.g.: http://localhost:8000/status.
+	// localhost is replaced with the address of the container.
+	Endpoint string `json:"endpoint"`
+
+	// The metrics to collect.
+	MetricsConfig []MetricConfig `json:"metrics_config"`
+}
+
+// Configuration of a metric collected from a JSON endpoint.
+type MetricConfig struct {
+	// Name of the metric.
+	Name string `json:"name"`
+
+	// gauge or cumulative.
+	MetricType info.MetricType `json:"metric_type"`
+
+	// Display units of the metric.
+	Units string `json:"units"`
+
+	// int or float.
+	DataType info.DataType `json:"data_type"`
+
+	// Minimum interval between collections of the metric, e.g.: 10s. The metric is collected at
+	// every housekeeping of the container if empty.
+	PollingFrequency string `json:"polling_frequency"`
+
+	// Dot separated path of the value of the metric in the JSON document of the endpoint,
+	// e.g.: connections.active.
+	Path string `json:"path"`
+}
+
+// Configuration of a collector of a Prometheus endpoint.
+type PrometheusConfig struct {
+	// prometheus.
+	Type string `json:"type"`
+
+	// URL of the endpoint exposing the metrics in the Prometheus text format,
+	// e.g.: http://localhost:9100/metrics. localhost is replaced with the address of the container.
+	Endpoint string `json:"endpoint"`
+
+	// Minimum interval between collections, e.g.: 10s. The metrics are collected at every
+	// housekeeping of the container if empty.
+	PollingFrequency string `json:"polling_frequency"`
+
+	// Names of the metrics to collect, e.g.: http_requests_total. Other metrics are ignored.
+	MetricsConfig []string `json:"metrics_config"`
+
+	// Changes to the labels of the collected metrics, applied in order.
+	Relabel []RelabelConfig `json:"relabel"`
+}
+
+// Renames or drops a label of the metrics of a Prometheus endpoint.
+type RelabelConfig struct {
+	SourceLabel string `json:"source_label"`
+
+	// New name of the label, the label is dropped if empty.
+	TargetLabel string `json:"target_label"`
+}
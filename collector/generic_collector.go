@@ -0,0 +1,184 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/cadvisor/info"
+)
+
+// Timeout of the requests to the endpoints of the applications.
+const collectionTimeout = 5 * time.Second
+
+// Collects the metrics of a JSON document served by the application.
+type GenericCollector struct {
+	name      string
+	endpoint  string
+	metrics   []MetricConfig
+	frequency time.Duration
+	client    *http.Client
+}
+
+// Returns a collector of the metrics described by configFile, a JSON Config, from the application
+// in the container at ipAddress.
+func NewCollector(name string, configFile []byte, ipAddress string) (*GenericCollector, error) {
+	var config Config
+	if err := json.Unmarshal(configFile, &config); err != nil {
+		return nil, fmt.Errorf("invalid configuration of collector %q: %v", name, err)
+	}
+	if config.Endpoint == "" {
+		return nil, fmt.Errorf("collector %q has no endpoint", name)
+	}
+	if len(config.MetricsConfig) == 0 {
+		return nil, fmt.Errorf("collector %q has no metrics", name)
+	}
+	// The collector is polled at the shortest frequency of its metrics.
+	var frequency time.Duration
+	for i, metric := range config.MetricsConfig {
+		if metric.Name == "" || metric.Path == "" {
+			return nil, fmt.Errorf("metric %d of collector %q needs a name and a path", i, name)
+		}
+		if err := validateTypes(metric.Name, metric.MetricType, metric.DataType); err != nil {
+			return nil, err
+		}
+		metricFrequency, err := parseFrequency(metric.Name, metric.PollingFrequency)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 || metricFrequency < frequency {
+			frequency = metricFrequency
+		}
+	}
+	return &GenericCollector{
+		name:      name,
+		endpoint:  strings.Replace(config.Endpoint, "localhost", ipAddress, 1),
+		metrics:   config.MetricsConfig,
+		frequency: frequency,
+		client:    &http.Client{Timeout: collectionTimeout},
+	}, nil
+}
+
+// Parses the polling frequency of a metric, 0 if empty.
+func parseFrequency(name, frequency string) (time.Duration, error) {
+	if frequency == "" {
+		return 0, nil
+	}
+	ret, err := time.ParseDuration(frequency)
+	if err != nil {
+		return 0, fmt.Errorf("invalid polling frequency of metric %q: %v", name, err)
+	}
+	return ret, nil
+}
+
+func validateTypes(name string, metricType info.MetricType, dataType info.DataType) error {
+	if metricType != info.MetricGauge && metricType != info.MetricCumulative {
+		return fmt.Errorf("metric %q has an unknown type %q, types are gauge and cumulative", name, metricType)
+	}
+	if dataType != info.IntType && dataType != info.FloatType {
+		return fmt.Errorf("metric %q has an unknown data type %q, data types are int and float", name, dataType)
+	}
+	return nil
+}
+
+func (self *GenericCollector) Name() string {
+	return self.name
+}
+
+func (self *GenericCollector) GetSpec() []info.MetricSpec {
+	specs := make([]info.MetricSpec, 0, len(self.metrics))
+	for _, metric := range self.metrics {
+		specs = append(specs, info.MetricSpec{
+			Name:   metric.Name,
+			Type:   metric.MetricType,
+			Format: metric.DataType,
+			Units:  metric.Units,
+		})
+	}
+	return specs
+}
+
+func (self *GenericCollector) Collect(metrics map[string][]info.MetricVal) (time.Time, map[string][]info.MetricVal, error) {
+	now := time.Now()
+	next := now.Add(self.frequency)
+
+	resp, err := self.client.Get(self.endpoint)
+	if err != nil {
+		return next, metrics, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return next, metrics, fmt.Errorf("%s returned %s", self.endpoint, resp.Status)
+	}
+	var document interface{}
+	decoder := json.NewDecoder(resp.Body)
+	// Keep integers exact.
+	decoder.UseNumber()
+	if err := decoder.Decode(&document); err != nil {
+		return next, metrics, fmt.Errorf("invalid JSON from %s: %v", self.endpoint, err)
+	}
+
+	var errors []string
+	for _, metric := range self.metrics {
+		value, err := lookup(document, metric.Path)
+		if err == nil {
+			var val info.MetricVal
+			val, err = metricValue(value, metric.DataType, now)
+			if err == nil {
+				metrics[metric.Name] = []info.MetricVal{val}
+				continue
+			}
+		}
+		errors = append(errors, fmt.Sprintf("%s: %v", metric.Name, err))
+	}
+	if len(errors) != 0 {
+		return next, metrics, fmt.Errorf("failed to collect %s", strings.Join(errors, ", "))
+	}
+	return next, metrics, nil
+}
+
+// Returns the value at the dot separated path in the document.
+func lookup(document interface{}, path string) (interface{}, error) {
+	value := document
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%q is not in an object", key)
+		}
+		if value, ok = object[key]; !ok {
+			return nil, fmt.Errorf("no %q", key)
+		}
+	}
+	return value, nil
+}
+
+func metricValue(value interface{}, dataType info.DataType, timestamp time.Time) (info.MetricVal, error) {
+	number, ok := value.(json.Number)
+	if !ok {
+		return info.MetricVal{}, fmt.Errorf("%v is not a number", value)
+	}
+	val := info.MetricVal{Timestamp: timestamp}
+	var err error
+	if dataType == info.IntType {
+		val.IntValue, err = number.Int64()
+	} else {
+		val.FloatValue, err = number.Float64()
+	}
+	return val, err
+}
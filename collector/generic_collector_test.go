@@ -0,0 +1,81 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/cadvisor/info"
+)
+
+const nginxConfig = `{
+	"endpoint": "http://localhost:8000/status",
+	"metrics_config": [
+		{"name": "activeConnections", "metric_type": "gauge", "units": "connections", "data_type": "int", "path": "connections.active"},
+		{"name": "requestRate", "metric_type": "gauge", "units": "requests per second", "data_type": "float", "polling_frequency": "10s", "path": "requests.rate"}
+	]
+}`
+
+func TestNewCollector(t *testing.T) {
+	collector, err := NewCollector("nginx", []byte(nginxConfig), "172.17.0.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if collector.endpoint != "http://172.17.0.2:8000/status" {
+		t.Errorf("expected the endpoint to be at the address of the container, got %q", collector.endpoint)
+	}
+	// Metrics without a polling frequency are collected at every housekeeping.
+	if collector.frequency != 0 {
+		t.Errorf("expected no polling frequency, got %v", collector.frequency)
+	}
+
+	for _, config := range []string{
+		`{"metrics_config": [{"name": "a", "metric_type": "gauge", "data_type": "int", "path": "a"}]}`,
+		`{"endpoint": "http://localhost/", "metrics_config": []}`,
+		`{"endpoint": "http://localhost/", "metrics_config": [{"name": "a", "metric_type": "counter", "data_type": "int", "path": "a"}]}`,
+		`{"endpoint": "http://localhost/", "metrics_config": [{"name": "a", "metric_type": "gauge", "data_type": "int", "path": "a", "polling_frequency": "often"}]}`,
+	} {
+		if _, err := NewCollector("invalid", []byte(config), "localhost"); err == nil {
+			t.Errorf("expected configuration %s to be invalid", config)
+		}
+	}
+}
+
+func TestGenericCollect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"connections": {"active": 42, "idle": 3}, "requests": {"rate": 12.5}}`)
+	}))
+	defer server.Close()
+
+	config := strings.Replace(nginxConfig, "http://localhost:8000/status", server.URL, 1)
+	collector, err := NewCollector("nginx", []byte(config), "localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, metrics, err := collector.Collect(make(map[string][]info.MetricVal))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metrics["activeConnections"]) != 1 || metrics["activeConnections"][0].IntValue != 42 {
+		t.Errorf("expected 42 active connections, got %+v", metrics["activeConnections"])
+	}
+	if len(metrics["requestRate"]) != 1 || metrics["requestRate"][0].FloatValue != 12.5 {
+		t.Errorf("expected a request rate of 12.5, got %+v", metrics["requestRate"])
+	}
+}
@@ -0,0 +1,282 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/cadvisor/info"
+)
+
+// Collects the whitelisted metrics of an endpoint in the Prometheus text format.
+type PrometheusCollector struct {
+	name      string
+	endpoint  string
+	frequency time.Duration
+	relabel   []RelabelConfig
+	client    *http.Client
+
+	// Types of the whitelisted metrics, guessed from their name until the endpoint tells them.
+	lock  sync.Mutex
+	types map[string]info.MetricType
+	// The whitelisted metrics, in the order of the configuration.
+	names []string
+}
+
+func NewPrometheusCollector(name string, configFile []byte, ipAddress string) (*PrometheusCollector, error) {
+	var config PrometheusConfig
+	if err := json.Unmarshal(configFile, &config); err != nil {
+		return nil, fmt.Errorf("invalid configuration of collector %q: %v", name, err)
+	}
+	if config.Endpoint == "" {
+		return nil, fmt.Errorf("collector %q has no endpoint", name)
+	}
+	if len(config.MetricsConfig) == 0 {
+		return nil, fmt.Errorf("collector %q has no metrics", name)
+	}
+	frequency, err := parseFrequency(name, config.PollingFrequency)
+	if err != nil {
+		return nil, err
+	}
+	for i, relabel := range config.Relabel {
+		if relabel.SourceLabel == "" {
+			return nil, fmt.Errorf("relabeling %d of collector %q has no source label", i, name)
+		}
+	}
+	types := make(map[string]info.MetricType, len(config.MetricsConfig))
+	for _, metric := range config.MetricsConfig {
+		types[metric] = guessMetricType(metric)
+	}
+	return &PrometheusCollector{
+		name:      name,
+		endpoint:  strings.Replace(config.Endpoint, "localhost", ipAddress, 1),
+		frequency: frequency,
+		relabel:   config.Relabel,
+		client:    &http.Client{Timeout: collectionTimeout},
+		types:     types,
+		names:     config.MetricsConfig,
+	}, nil
+}
+
+// By convention, counters end with _total and the samples of histograms and summaries with
+// _count, _sum and _bucket.
+func guessMetricType(name string) info.MetricType {
+	for _, suffix := range []string{"_total", "_count", "_sum", "_bucket"} {
+		if strings.HasSuffix(name, suffix) {
+			return info.MetricCumulative
+		}
+	}
+	return info.MetricGauge
+}
+
+func (self *PrometheusCollector) Name() string {
+	return self.name
+}
+
+func (self *PrometheusCollector) GetSpec() []info.MetricSpec {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	specs := make([]info.MetricSpec, 0, len(self.names))
+	for _, name := range self.names {
+		specs = append(specs, info.MetricSpec{
+			Name:   name,
+			Type:   self.types[name],
+			Format: info.FloatType,
+		})
+	}
+	return specs
+}
+
+func (self *PrometheusCollector) Collect(metrics map[string][]info.MetricVal) (time.Time, map[string][]info.MetricVal, error) {
+	now := time.Now()
+	next := now.Add(self.frequency)
+
+	resp, err := self.client.Get(self.endpoint)
+	if err != nil {
+		return next, metrics, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return next, metrics, fmt.Errorf("%s returned %s", self.endpoint, resp.Status)
+	}
+	samples, types, err := parsePrometheus(resp.Body)
+	if err != nil {
+		return next, metrics, fmt.Errorf("invalid metrics from %s: %v", self.endpoint, err)
+	}
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	for _, sample := range samples {
+		if _, ok := self.types[sample.name]; !ok {
+			continue
+		}
+		// e.g.: the quantiles of a summary without observations, which JSON cannot encode.
+		if math.IsNaN(sample.value) || math.IsInf(sample.value, 0) {
+			continue
+		}
+		if metricType, ok := types[sample.name]; ok {
+			self.types[sample.name] = metricType
+		}
+		metrics[sample.name] = append(metrics[sample.name], info.MetricVal{
+			// The values of the application are timestamped with the stats of the container.
+			Timestamp:  now,
+			FloatValue: sample.value,
+			Labels:     self.relabelLabels(sample.labels),
+		})
+	}
+	return next, metrics, nil
+}
+
+// Applies the relabeling of the configuration to the labels of a sample.
+func (self *PrometheusCollector) relabelLabels(labels map[string]string) map[string]string {
+	for _, relabel := range self.relabel {
+		value, ok := labels[relabel.SourceLabel]
+		if !ok {
+			continue
+		}
+		delete(labels, relabel.SourceLabel)
+		if relabel.TargetLabel != "" {
+			labels[relabel.TargetLabel] = value
+		}
+	}
+	if len(labels) == 0 {
+		return nil
+	}
+	return labels
+}
+
+// A sample of the Prometheus text format, e.g.: http_requests_total{code="200"} 1027.
+type prometheusSample struct {
+	name   string
+	labels map[string]string
+	value  float64
+}
+
+// Parses the samples of the Prometheus text format, and the types of their metrics declared by
+// TYPE comments. The samples of histograms and summaries (e.g.: _count) are cumulative, but for
+// their quantiles.
+func parsePrometheus(r io.Reader) ([]prometheusSample, map[string]info.MetricType, error) {
+	var samples []prometheusSample
+	types := make(map[string]info.MetricType)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			// e.g.: # TYPE http_requests_total counter
+			fields := strings.Fields(line)
+			if len(fields) == 4 && fields[1] == "TYPE" {
+				name := fields[2]
+				switch fields[3] {
+				case "counter":
+					types[name] = info.MetricCumulative
+				case "gauge", "untyped":
+					types[name] = info.MetricGauge
+				case "histogram", "summary":
+					types[name+"_count"] = info.MetricCumulative
+					types[name+"_sum"] = info.MetricCumulative
+					types[name+"_bucket"] = info.MetricCumulative
+					types[name] = info.MetricGauge
+				}
+			}
+			continue
+		}
+		sample, err := parseSample(line)
+		if err != nil {
+			return nil, nil, err
+		}
+		samples = append(samples, sample)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	return samples, types, nil
+}
+
+// Parses a sample line: its name, optional labels, value and optional timestamp.
+func parseSample(line string) (prometheusSample, error) {
+	sample := prometheusSample{labels: make(map[string]string)}
+	end := strings.IndexAny(line, "{ \t")
+	if end <= 0 {
+		return sample, fmt.Errorf("invalid sample %q", line)
+	}
+	sample.name = line[:end]
+	rest := line[end:]
+	if rest[0] == '{' {
+		var err error
+		rest, err = parseLabels(rest[1:], sample.labels)
+		if err != nil {
+			return sample, fmt.Errorf("invalid labels in %q: %v", line, err)
+		}
+	}
+	fields := strings.Fields(rest)
+	if len(fields) != 1 && len(fields) != 2 {
+		return sample, fmt.Errorf("invalid sample %q", line)
+	}
+	var err error
+	sample.value, err = strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return sample, fmt.Errorf("invalid value in %q: %v", line, err)
+	}
+	return sample, nil
+}
+
+// Parses labels up to their closing brace, e.g.: method="post",code="200"}, and returns what
+// follows them.
+func parseLabels(s string, labels map[string]string) (string, error) {
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		if strings.HasPrefix(s, "}") {
+			return s[1:], nil
+		}
+		eq := strings.Index(s, "=")
+		if eq <= 0 {
+			return "", fmt.Errorf("no label value")
+		}
+		name := strings.TrimSpace(s[:eq])
+		s = strings.TrimLeft(s[eq+1:], " \t")
+		if !strings.HasPrefix(s, "\"") {
+			return "", fmt.Errorf("label %q is not quoted", name)
+		}
+		var value []byte
+		i := 1
+		for ; i < len(s) && s[i] != '"'; i++ {
+			if s[i] == '\\' && i+1 < len(s) {
+				i++
+				if s[i] == 'n' {
+					value = append(value, '\n')
+					continue
+				}
+			}
+			value = append(value, s[i])
+		}
+		if i == len(s) {
+			return "", fmt.Errorf("label %q is not terminated", name)
+		}
+		labels[name] = string(value)
+		s = s[i+1:]
+	}
+}
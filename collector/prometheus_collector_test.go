@@ -0,0 +1,102 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/cadvisor/info"
+)
+
+const prometheusMetrics = `# HELP http_requests_total The total number of HTTP requests.
+# TYPE http_requests_total counter
+http_requests_total{method="post",code="200"} 1027 1395066363000
+http_requests_total{method="post",code="400"}    3 1395066363000
+# TYPE queue_length untyped
+queue_length{queue="a \"quoted\" name"} 5
+# TYPE rpc_duration_seconds summary
+rpc_duration_seconds{quantile="0.5"} NaN
+rpc_duration_seconds_sum 17560473
+rpc_duration_seconds_count 2693
+process_open_fds 12
+`
+
+func TestParsePrometheus(t *testing.T) {
+	samples, types, err := parsePrometheus(strings.NewReader(prometheusMetrics))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 7 {
+		t.Fatalf("expected 7 samples, got %+v", samples)
+	}
+	expected := prometheusSample{name: "http_requests_total", labels: map[string]string{"method": "post", "code": "400"}, value: 3}
+	if !reflect.DeepEqual(samples[1], expected) {
+		t.Errorf("expected %+v, got %+v", expected, samples[1])
+	}
+	if samples[2].labels["queue"] != `a "quoted" name` {
+		t.Errorf("expected an escaped label, got %q", samples[2].labels["queue"])
+	}
+	if types["http_requests_total"] != info.MetricCumulative || types["rpc_duration_seconds_count"] != info.MetricCumulative || types["queue_length"] != info.MetricGauge {
+		t.Errorf("unexpected types %+v", types)
+	}
+
+	for _, line := range []string{`name{label=unquoted} 1`, `name{label="unterminated} 1`, `name`, `name abc`} {
+		if _, _, err := parsePrometheus(strings.NewReader(line)); err == nil {
+			t.Errorf("expected %q to be invalid", line)
+		}
+	}
+}
+
+func TestPrometheusCollect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, prometheusMetrics)
+	}))
+	defer server.Close()
+
+	config := fmt.Sprintf(`{
+		"type": "prometheus",
+		"endpoint": %q,
+		"metrics_config": ["http_requests_total", "rpc_duration_seconds", "process_open_fds"],
+		"relabel": [{"source_label": "code", "target_label": "status"}, {"source_label": "method"}]
+	}`, server.URL)
+	collector, err := NewCollectorFromConfig("app", []byte(config), "localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, metrics, err := collector.Collect(make(map[string][]info.MetricVal))
+	if err != nil {
+		t.Fatal(err)
+	}
+	requests := metrics["http_requests_total"]
+	if len(requests) != 2 || requests[0].FloatValue != 1027 || !reflect.DeepEqual(requests[0].Labels, map[string]string{"status": "200"}) {
+		t.Errorf("unexpected requests %+v", requests)
+	}
+	if _, ok := metrics["rpc_duration_seconds"]; ok {
+		t.Errorf("expected the NaN quantile to be left out, got %+v", metrics["rpc_duration_seconds"])
+	}
+	if len(metrics) != 2 {
+		t.Errorf("expected only the whitelisted metrics, got %+v", metrics)
+	}
+
+	spec := collector.GetSpec()
+	if len(spec) != 3 || spec[0].Type != info.MetricCumulative || spec[2].Type != info.MetricGauge {
+		t.Errorf("unexpected spec %+v", spec)
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+)
+
+// A collector configuration shared by all the containers whose labels match its selector, added at
+// runtime from --collector_config_dir or the API rather than declared by the containers.
+type SharedConfig struct {
+	// Name of the collector, unique among the collectors of a container. Defaults to the name of
+	// the file without extension in --collector_config_dir.
+	Name string `json:"name"`
+
+	// Labels containers must all have, with these values, for the collector to be registered for
+	// them. A value of * matches any value.
+	Selector map[string]string `json:"selector"`
+
+	// Configuration of the collector, as in the files declared by container labels.
+	Config json.RawMessage `json:"config"`
+}
+
+// Checks the name and selector of the shared config and that its collector can be created.
+func (self *SharedConfig) Validate() error {
+	if self.Name == "" || strings.Contains(self.Name, "/") {
+		return fmt.Errorf("invalid collector name %q", self.Name)
+	}
+	if len(self.Selector) == 0 {
+		return fmt.Errorf("collector %q has no selector", self.Name)
+	}
+	_, err := NewCollectorFromConfig(self.Name, self.Config, "localhost")
+	return err
+}
+
+// Returns whether the labels of a container match the selector of the shared config.
+func (self *SharedConfig) Matches(labels map[string]string) bool {
+	for label, value := range self.Selector {
+		actual, ok := labels[label]
+		if !ok || (value != "*" && value != actual) {
+			return false
+		}
+	}
+	return true
+}
+
+// Reads the shared configs in the .json files of a directory, by name.
+func ReadSharedConfigs(dir string) (map[string]*SharedConfig, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	configs := make(map[string]*SharedConfig)
+	for _, file := range files {
+		if file.IsDir() || path.Ext(file.Name()) != ".json" {
+			continue
+		}
+		content, err := ioutil.ReadFile(path.Join(dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		config := &SharedConfig{}
+		if err := json.Unmarshal(content, config); err != nil {
+			return nil, fmt.Errorf("invalid collector configuration %q: %v", file.Name(), err)
+		}
+		if config.Name == "" {
+			config.Name = strings.TrimSuffix(file.Name(), ".json")
+		}
+		if err := config.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid collector configuration %q: %v", file.Name(), err)
+		}
+		if _, ok := configs[config.Name]; ok {
+			return nil, fmt.Errorf("collector %q is configured twice in %q", config.Name, dir)
+		}
+		configs[config.Name] = config
+	}
+	return configs, nil
+}
@@ -0,0 +1,63 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+const sharedStatusConfig = `{"endpoint": "http://localhost:8000/status", "metrics_config": [{"name": "connections", "metric_type": "gauge", "data_type": "int", "path": "connections"}]}`
+
+func TestReadSharedConfigs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "collector_configs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"status.json": `{"selector": {"app": "web"}, "config": ` + sharedStatusConfig + `}`,
+		"other.json":  `{"name": "named", "selector": {"app": "*"}, "config": ` + sharedStatusConfig + `}`,
+		"README":      "ignored",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(path.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	configs, err := ReadSharedConfigs(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(configs) != 2 || configs["status"] == nil || configs["named"] == nil {
+		t.Fatalf("unexpected configs %+v", configs)
+	}
+	if !configs["status"].Matches(map[string]string{"app": "web", "team": "a"}) || configs["status"].Matches(map[string]string{"app": "db"}) {
+		t.Errorf("unexpected matches of selector %v", configs["status"].Selector)
+	}
+	if !configs["named"].Matches(map[string]string{"app": "db"}) || configs["named"].Matches(map[string]string{}) {
+		t.Errorf("unexpected matches of selector %v", configs["named"].Selector)
+	}
+
+	// Configs without selector are invalid.
+	if err := ioutil.WriteFile(path.Join(dir, "invalid.json"), []byte(`{"config": `+sharedStatusConfig+`}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadSharedConfigs(dir); err == nil {
+		t.Error("expected an error reading a config without selector")
+	}
+}
@@ -0,0 +1,292 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/cadvisor/info"
+)
+
+// Returns the name of the container that sent a packet from addr.
+type ContainerResolver func(addr *net.UDPAddr) (string, error)
+
+// Receives the metrics pushed by applications with the StatsD protocol, and attributes them to the
+// container that sent them.
+type StatsdReceiver struct {
+	conn    *net.UDPConn
+	resolve ContainerResolver
+	// Maximum number of metrics of a container, each set of tags of a metric counting as one.
+	limit int
+
+	lock       sync.Mutex
+	containers map[string]map[string]*statsdMetric
+}
+
+// A metric of a container, by name and tags.
+type statsdMetric struct {
+	name       string
+	labels     map[string]string
+	metricType info.MetricType
+	// Total of counters, latest value of gauges, and sum of the timings since the last collection.
+	value float64
+	// Number of timings since the last collection.
+	count int
+	timer bool
+}
+
+func NewStatsdReceiver(address string, resolve ContainerResolver, limit int) (*StatsdReceiver, error) {
+	addr, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &StatsdReceiver{
+		conn:       conn,
+		resolve:    resolve,
+		limit:      limit,
+		containers: make(map[string]map[string]*statsdMetric),
+	}, nil
+}
+
+// Receives metrics until the receiver is closed.
+func (self *StatsdReceiver) Start() {
+	go func() {
+		buf := make([]byte, 65536)
+		for {
+			n, addr, err := self.conn.ReadFromUDP(buf)
+			if err != nil {
+				if errors.Is(err, net.ErrClosed) {
+					return
+				}
+				glog.Warningf("Failed to receive StatsD metrics: %v", err)
+				time.Sleep(time.Second)
+				continue
+			}
+			containerName, err := self.resolve(addr)
+			if err != nil {
+				glog.V(3).Infof("Ignoring StatsD metrics of unknown container at %v: %v", addr, err)
+				continue
+			}
+			for _, line := range strings.Split(string(buf[:n]), "\n") {
+				if line == "" {
+					continue
+				}
+				if err := self.add(containerName, line); err != nil {
+					glog.V(3).Infof("Ignoring StatsD metric %q of %q: %v", line, containerName, err)
+				}
+			}
+		}
+	}()
+}
+
+func (self *StatsdReceiver) Close() error {
+	return self.conn.Close()
+}
+
+// Returns the collector of the metrics of the container.
+func (self *StatsdReceiver) Collector(containerName string) Collector {
+	return &statsdCollector{receiver: self, containerName: containerName}
+}
+
+// Drops the metrics of a container that was destroyed.
+func (self *StatsdReceiver) Forget(containerName string) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	delete(self.containers, containerName)
+}
+
+// Adds a metric line, e.g.: requests:1|c|@0.1|#handler:api.
+func (self *StatsdReceiver) add(containerName, line string) error {
+	sample, err := parseStatsdLine(line)
+	if err != nil {
+		return err
+	}
+	key := metricKey(sample.name, sample.labels)
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	metrics, ok := self.containers[containerName]
+	if !ok {
+		metrics = make(map[string]*statsdMetric)
+		self.containers[containerName] = metrics
+	}
+	metric, ok := metrics[key]
+	if !ok {
+		if len(metrics) >= self.limit {
+			return fmt.Errorf("the container has %d metrics already", len(metrics))
+		}
+		metric = &statsdMetric{name: sample.name, labels: sample.labels}
+		switch sample.kind {
+		case "c":
+			metric.metricType = info.MetricCumulative
+		case "ms", "h":
+			metric.metricType = info.MetricGauge
+			metric.timer = true
+		default:
+			metric.metricType = info.MetricGauge
+		}
+		metrics[key] = metric
+	}
+
+	switch {
+	case sample.kind == "c":
+		metric.value += sample.value
+	case metric.timer:
+		metric.value += sample.value
+		metric.count++
+	case sample.delta:
+		metric.value += sample.value
+	default:
+		metric.value = sample.value
+	}
+	return nil
+}
+
+// A line of the StatsD protocol.
+type statsdSample struct {
+	name  string
+	value float64
+	// c, g, ms or h.
+	kind   string
+	labels map[string]string
+	// Whether the value of a gauge is a change, e.g.: -5.
+	delta bool
+}
+
+// Parses a line of the StatsD protocol: <name>:<value>|<type>[|@<sample rate>][|#<tags>], with
+// the tags of DogStatsD. Counters are scaled by their sample rate. Sets are not supported.
+func parseStatsdLine(line string) (statsdSample, error) {
+	var sample statsdSample
+	colon := strings.Index(line, ":")
+	if colon <= 0 {
+		return sample, fmt.Errorf("no value")
+	}
+	sample.name = line[:colon]
+	fields := strings.Split(line[colon+1:], "|")
+	if len(fields) < 2 {
+		return sample, fmt.Errorf("no type")
+	}
+	var err error
+	sample.value, err = strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return sample, fmt.Errorf("invalid value: %v", err)
+	}
+	sample.kind = fields[1]
+	switch sample.kind {
+	case "c", "g", "ms", "h":
+	default:
+		return sample, fmt.Errorf("unsupported type %q", sample.kind)
+	}
+	sample.delta = sample.kind == "g" && strings.IndexAny(fields[0], "+-") == 0
+	for _, field := range fields[2:] {
+		switch {
+		case strings.HasPrefix(field, "@"):
+			rate, err := strconv.ParseFloat(field[1:], 64)
+			if err != nil || rate <= 0 || rate > 1 {
+				return sample, fmt.Errorf("invalid sample rate %q", field)
+			}
+			if sample.kind == "c" {
+				sample.value /= rate
+			}
+		case strings.HasPrefix(field, "#"):
+			sample.labels = make(map[string]string)
+			for _, tag := range strings.Split(field[1:], ",") {
+				parts := strings.SplitN(tag, ":", 2)
+				if len(parts) == 2 {
+					sample.labels[parts[0]] = parts[1]
+				} else {
+					sample.labels[parts[0]] = ""
+				}
+			}
+		}
+	}
+	return sample, nil
+}
+
+// Identifies a metric by its name and tags.
+func metricKey(name string, labels map[string]string) string {
+	tags := make([]string, 0, len(labels))
+	for label, value := range labels {
+		tags = append(tags, label+":"+value)
+	}
+	sort.Strings(tags)
+	return name + "|" + strings.Join(tags, ",")
+}
+
+// Collects the metrics a container pushed to the receiver.
+type statsdCollector struct {
+	receiver      *StatsdReceiver
+	containerName string
+}
+
+func (self *statsdCollector) Name() string {
+	return "statsd"
+}
+
+func (self *statsdCollector) GetSpec() []info.MetricSpec {
+	self.receiver.lock.Lock()
+	defer self.receiver.lock.Unlock()
+	types := make(map[string]info.MetricType)
+	for _, metric := range self.receiver.containers[self.containerName] {
+		types[metric.name] = metric.metricType
+	}
+	specs := make([]info.MetricSpec, 0, len(types))
+	for name, metricType := range types {
+		specs = append(specs, info.MetricSpec{Name: name, Type: metricType, Format: info.FloatType})
+	}
+	sort.Sort(byName(specs))
+	return specs
+}
+
+// Timers are reported as their mean since the last collection, and left out when there was none.
+func (self *statsdCollector) Collect(metrics map[string][]info.MetricVal) (time.Time, map[string][]info.MetricVal, error) {
+	now := time.Now()
+	self.receiver.lock.Lock()
+	defer self.receiver.lock.Unlock()
+	for _, metric := range self.receiver.containers[self.containerName] {
+		value := metric.value
+		if metric.timer {
+			if metric.count == 0 {
+				continue
+			}
+			value /= float64(metric.count)
+			metric.value, metric.count = 0, 0
+		}
+		metrics[metric.name] = append(metrics[metric.name], info.MetricVal{
+			Timestamp:  now,
+			FloatValue: value,
+			Labels:     metric.labels,
+		})
+	}
+	return now, metrics, nil
+}
+
+type byName []info.MetricSpec
+
+func (s byName) Len() int           { return len(s) }
+func (s byName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byName) Less(i, j int) bool { return s[i].Name < s[j].Name }
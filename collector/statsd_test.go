@@ -0,0 +1,129 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/info"
+)
+
+func TestParseStatsdLine(t *testing.T) {
+	sample, err := parseStatsdLine("requests:3|c|@0.5|#handler:api,canary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := statsdSample{
+		name:   "requests",
+		value:  6,
+		kind:   "c",
+		labels: map[string]string{"handler": "api", "canary": ""},
+	}
+	if !reflect.DeepEqual(sample, expected) {
+		t.Errorf("expected %+v, got %+v", expected, sample)
+	}
+	if sample, err = parseStatsdLine("queue:-2|g"); err != nil || !sample.delta {
+		t.Errorf("expected a change of gauge, got %+v (%v)", sample, err)
+	}
+	for _, line := range []string{"requests", "requests:1", "requests:a|c", "users:42|s", "requests:1|c|@2"} {
+		if _, err := parseStatsdLine(line); err == nil {
+			t.Errorf("expected %q to be invalid", line)
+		}
+	}
+}
+
+func TestStatsdCollect(t *testing.T) {
+	receiver := &StatsdReceiver{limit: 4, containers: make(map[string]map[string]*statsdMetric)}
+	for _, line := range []string{
+		"requests:1|c|#handler:api",
+		"requests:2|c|#handler:api",
+		"requests:5|c|#handler:static",
+		"queue:10|g",
+		"queue:-3|g",
+		"latency:20|ms",
+		"latency:40|ms",
+	} {
+		if err := receiver.add("/docker/abc", line); err != nil {
+			t.Fatalf("failed to add %q: %v", line, err)
+		}
+	}
+	if err := receiver.add("/docker/abc", "errors:1|c"); err == nil {
+		t.Error("expected the metrics of the container to be limited")
+	}
+
+	collector := receiver.Collector("/docker/abc")
+	_, metrics, err := collector.Collect(make(map[string][]info.MetricVal))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metrics["requests"]) != 2 {
+		t.Errorf("expected the requests of both handlers, got %+v", metrics["requests"])
+	}
+	if len(metrics["queue"]) != 1 || metrics["queue"][0].FloatValue != 7 {
+		t.Errorf("expected a queue of 7, got %+v", metrics["queue"])
+	}
+	if len(metrics["latency"]) != 1 || metrics["latency"][0].FloatValue != 30 {
+		t.Errorf("expected a mean latency of 30, got %+v", metrics["latency"])
+	}
+	// Timers are reset by collections.
+	if _, metrics, _ = collector.Collect(make(map[string][]info.MetricVal)); len(metrics["latency"]) != 0 {
+		t.Errorf("expected no latency without timings, got %+v", metrics["latency"])
+	}
+
+	expected := []info.MetricSpec{
+		{Name: "latency", Type: info.MetricGauge, Format: info.FloatType},
+		{Name: "queue", Type: info.MetricGauge, Format: info.FloatType},
+		{Name: "requests", Type: info.MetricCumulative, Format: info.FloatType},
+	}
+	if spec := collector.GetSpec(); !reflect.DeepEqual(spec, expected) {
+		t.Errorf("expected %+v, got %+v", expected, spec)
+	}
+
+	receiver.Forget("/docker/abc")
+	if spec := collector.GetSpec(); len(spec) != 0 {
+		t.Errorf("expected no metrics once the container is forgotten, got %+v", spec)
+	}
+}
+
+func TestStatsdReceiver(t *testing.T) {
+	receiver, err := NewStatsdReceiver("127.0.0.1:0", func(addr *net.UDPAddr) (string, error) {
+		return "/docker/abc", nil
+	}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	receiver.Start()
+	defer receiver.Close()
+
+	conn, err := net.Dial("udp", receiver.conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("requests:1|c\nqueue:5|g\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	collector := receiver.Collector("/docker/abc")
+	for i := 0; i < 100 && len(collector.GetSpec()) < 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if spec := collector.GetSpec(); len(spec) != 2 {
+		t.Errorf("expected the 2 metrics sent, got %+v", spec)
+	}
+}
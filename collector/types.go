@@ -0,0 +1,53 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Collects the custom metrics of the applications running in containers.
+package collector
+
+import (
+	"time"
+
+	"github.com/google/cadvisor/info"
+)
+
+// A collector of some metrics of the application in a container.
+type Collector interface {
+	// Collects the metrics into the specified map, by their name. Returns when to collect them
+	// next, along with the map.
+	Collect(metrics map[string][]info.MetricVal) (time.Time, map[string][]info.MetricVal, error)
+
+	// Name of the collector, unique among the collectors of a container.
+	Name() string
+
+	// Specs of the metrics collected.
+	GetSpec() []info.MetricSpec
+}
+
+// Manages the collectors of a container. Collectors may be registered and unregistered while
+// metrics are collected.
+type CollectorManager interface {
+	// Adds a collector to the ones of the container.
+	RegisterCollector(collector Collector) error
+
+	// Removes the collector of the specified name, returns whether it was registered.
+	UnregisterCollector(name string) bool
+
+	// Collects the metrics of the collectors that are due. Returns when the next collector is
+	// due, along with the metrics of all the collectors: the latest collected for those that were
+	// not due. The map is new at every call.
+	Collect() (time.Time, map[string][]info.MetricVal, error)
+
+	// Specs of the metrics of all the collectors.
+	GetSpec() ([]info.MetricSpec, error)
+}
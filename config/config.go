@@ -0,0 +1,340 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package config reads the settings of cAdvisor from a config file besides its flags, and updates
+// those which can change while it runs, on SIGHUP or from the API, without losing the stats held
+// in memory.
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/golang/glog"
+)
+
+var argConfigFile = flag.String("config", "", "File of settings named as the flags: YAML (.yaml, .yml), TOML (.toml) or name=value lines. Flags set on the command line take precedence. The file is read again on SIGHUP to update the settings which can be reloaded")
+
+// Flags which can be updated while cAdvisor runs.
+var reloadableFlags = map[string]bool{
+	"allow_dynamic_housekeeping":        true,
+	"disable_metrics":                   true,
+	"enable_metrics":                    true,
+	"global_housekeeping_interval":      true,
+	"housekeeping_interval":             true,
+	"max_housekeeping_interval":         true,
+	"storage_driver_docker_only":        true,
+	"storage_driver_exclude_containers": true,
+	"storage_driver_exclude_metrics":    true,
+	"storage_driver_include_containers": true,
+	"v":                                 true,
+	"vmodule":                           true,
+}
+
+var (
+	lock sync.Mutex
+	// Flags set on the command line, which the config file does not override.
+	commandLine = map[string]bool{}
+	// Apply the flags after they are updated.
+	hooks []func() error
+)
+
+// Registers a function applying the current values of the flags, called in order of registration
+// whenever they are updated.
+func OnReload(f func() error) {
+	lock.Lock()
+	defer lock.Unlock()
+	hooks = append(hooks, f)
+}
+
+// Sets the flags of the config file, if any, which are not set on the command line. Called once
+// after flag.Parse, before the flags are used.
+func Load() error {
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	return load(set)
+}
+
+func load(set map[string]bool) error {
+	lock.Lock()
+	defer lock.Unlock()
+	commandLine = set
+	if *argConfigFile == "" {
+		return nil
+	}
+	settings, err := readFile(*argConfigFile)
+	if err != nil {
+		return err
+	}
+	for name, value := range settings {
+		if commandLine[name] {
+			continue
+		}
+		if flag.Lookup(name) == nil {
+			return fmt.Errorf("unknown setting %q in %q", name, *argConfigFile)
+		}
+		if err := flag.Set(name, value); err != nil {
+			return fmt.Errorf("invalid %s=%q in %q: %v", name, value, *argConfigFile, err)
+		}
+	}
+	return nil
+}
+
+// Reads the config file again and applies the settings which can be reloaded. Those it no longer
+// sets get their default values back. The other settings only change on restart.
+func Reload() error {
+	if *argConfigFile == "" {
+		return fmt.Errorf("no config file to reload, see --config")
+	}
+	settings, err := readFile(*argConfigFile)
+	if err != nil {
+		return err
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	for name, value := range settings {
+		if f := flag.Lookup(name); f != nil && !reloadableFlags[name] && !commandLine[name] && f.Value.String() != value {
+			glog.Warningf("The setting %q of %q cannot be reloaded, it changes on restart", name, *argConfigFile)
+		}
+	}
+	updates := make(map[string]string)
+	for name := range reloadableFlags {
+		f := flag.Lookup(name)
+		if f == nil || commandLine[name] {
+			continue
+		}
+		value, ok := settings[name]
+		if !ok {
+			value = f.DefValue
+		}
+		updates[name] = value
+	}
+	return update(updates)
+}
+
+// Sets the specified flags and applies them, all of them or none if one fails. They keep their
+// values until the next reload of the config file.
+func Update(settings map[string]string) error {
+	lock.Lock()
+	defer lock.Unlock()
+	return update(settings)
+}
+
+// Returns the current values of the flags which can be reloaded.
+func Reloadable() map[string]string {
+	lock.Lock()
+	defer lock.Unlock()
+	ret := make(map[string]string)
+	for name := range reloadableFlags {
+		if f := flag.Lookup(name); f != nil {
+			ret[name] = f.Value.String()
+		}
+	}
+	return ret
+}
+
+func update(settings map[string]string) error {
+	old := make(map[string]string, len(settings))
+	for name := range settings {
+		if !reloadableFlags[name] {
+			return fmt.Errorf("%q cannot be reloaded", name)
+		}
+		f := flag.Lookup(name)
+		if f == nil {
+			return fmt.Errorf("unknown setting %q", name)
+		}
+		old[name] = f.Value.String()
+	}
+
+	err := set(settings)
+	if err == nil {
+		err = applyHooks()
+	}
+	if err != nil {
+		// Go back to the settings in use.
+		if err := set(old); err != nil {
+			glog.Errorf("Failed to restore the settings %v: %v", old, err)
+		}
+		if err := applyHooks(); err != nil {
+			glog.Errorf("Failed to apply the restored settings: %v", err)
+		}
+		return err
+	}
+
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		if settings[name] != old[name] {
+			names = append(names, fmt.Sprintf("%s=%q", name, settings[name]))
+		}
+	}
+	sort.Strings(names)
+	glog.Infof("Updated the settings %s", strings.Join(names, " "))
+	return nil
+}
+
+func set(settings map[string]string) error {
+	for name, value := range settings {
+		if err := flag.Set(name, value); err != nil {
+			return fmt.Errorf("invalid %s=%q: %v", name, value, err)
+		}
+	}
+	return nil
+}
+
+func applyHooks() error {
+	for _, f := range hooks {
+		if err := f(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reads the settings of the file, by flag name, in the format of its extension.
+func readFile(filename string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var settings map[string]string
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+		settings, err = parseYaml(data)
+	case ".toml":
+		settings, err = parseToml(data)
+	default:
+		settings, err = parseFlags(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %q: %v", filename, err)
+	}
+	return settings, nil
+}
+
+// Parses name=value lines, skipping blank lines and comments starting with #. Names may start with
+// dashes like flags, values may be quoted.
+func parseFlags(data []byte) (map[string]string, error) {
+	settings := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("line %d: expected name=value, got %q", lineNumber, line)
+		}
+		name := strings.TrimLeft(strings.TrimSpace(parts[0]), "-")
+		value := strings.TrimSpace(parts[1])
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			var err error
+			if value, err = unquote(value); err != nil {
+				return nil, fmt.Errorf("line %d: invalid value of %q: %v", lineNumber, name, err)
+			}
+		}
+		if err := addSetting(settings, name, value); err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+func addSetting(settings map[string]string, name, value string) error {
+	if name == "" {
+		return fmt.Errorf("empty setting name")
+	}
+	if _, ok := settings[name]; ok {
+		return fmt.Errorf("%q is set twice", name)
+	}
+	settings[name] = value
+	return nil
+}
+
+// Returns the value of a string in double quotes, with escape sequences, or in single quotes,
+// without.
+func unquote(value string) (string, error) {
+	if len(value) < 2 || value[len(value)-1] != value[0] {
+		return "", fmt.Errorf("unterminated string %s", value)
+	}
+	if value[0] == '\'' {
+		if strings.Contains(value[1:len(value)-1], "'") {
+			return "", fmt.Errorf("unexpected quote in %s", value)
+		}
+		return value[1 : len(value)-1], nil
+	}
+	return strconv.Unquote(value)
+}
+
+// Returns the index of the first occurrence of c outside quoted strings in s, -1 if there is none.
+func indexUnquoted(s string, c byte) int {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch {
+		case quote == '"' && s[i] == '\\':
+			i++
+		case quote != 0:
+			if s[i] == quote {
+				quote = 0
+			}
+		case s[i] == '"' || s[i] == '\'':
+			quote = s[i]
+		case s[i] == c:
+			return i
+		}
+	}
+	return -1
+}
+
+// Parses the elements of an inline list, e.g. [cpu, "memory"], into a comma-separated value as
+// taken by flags. parseElement parses each element.
+func parseList(value string, parseElement func(string) (string, error)) (string, error) {
+	if !strings.HasSuffix(value, "]") {
+		return "", fmt.Errorf("unterminated list %s", value)
+	}
+	rest := strings.TrimSpace(value[1 : len(value)-1])
+	var elements []string
+	for rest != "" {
+		end := indexUnquoted(rest, ',')
+		if end < 0 {
+			end = len(rest)
+		}
+		element := strings.TrimSpace(rest[:end])
+		if element != "" {
+			parsed, err := parseElement(element)
+			if err != nil {
+				return "", err
+			}
+			elements = append(elements, parsed)
+		}
+		if end == len(rest) {
+			break
+		}
+		rest = rest[end+1:]
+	}
+	return strings.Join(elements, ","), nil
+}
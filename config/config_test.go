@@ -0,0 +1,194 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var testInterval = flag.Duration("test_interval", time.Second, "interval reloaded by the tests")
+var testFilter = flag.String("test_filter", "", "filter reloaded by the tests")
+
+func init() {
+	reloadableFlags["test_interval"] = true
+	reloadableFlags["test_filter"] = true
+}
+
+// Resets the state of the package and the test flags.
+func reset() {
+	hooks = nil
+	commandLine = map[string]bool{}
+	*argConfigFile = ""
+	*testInterval = time.Second
+	*testFilter = ""
+}
+
+func writeConfigFile(t *testing.T, content string) string {
+	file, err := ioutil.TempFile("", "cadvisor-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+	return file.Name()
+}
+
+func TestReadFile(t *testing.T) {
+	filename := writeConfigFile(t, `
+# Collect every 5s.
+--test_interval = 5s
+test_filter="^/docker/ \"a\""
+`)
+	defer os.Remove(filename)
+	settings, err := readFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"test_interval": "5s",
+		"test_filter":   `^/docker/ "a"`,
+	}
+	if !reflect.DeepEqual(settings, expected) {
+		t.Errorf("expected %v, got %v", expected, settings)
+	}
+
+	for _, content := range []string{"test_interval", "test_interval=1s\ntest_interval=2s", `test_filter="a\q"`} {
+		filename := writeConfigFile(t, content)
+		defer os.Remove(filename)
+		if _, err := readFile(filename); err == nil {
+			t.Errorf("expected an error for %q", content)
+		}
+	}
+}
+
+func TestLoadFormats(t *testing.T) {
+	defer reset()
+	dir, err := ioutil.TempDir("", "cadvisor-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := []struct {
+		name    string
+		content string
+	}{
+		{"cadvisor.yaml", "test:\n  interval: 5s\n"},
+		{"cadvisor.toml", "[test]\ninterval = \"5s\"\n"},
+		{"cadvisor.conf", "test_interval=5s\n"},
+	}
+	for _, file := range files {
+		*testInterval = time.Second
+		*argConfigFile = filepath.Join(dir, file.name)
+		if err := ioutil.WriteFile(*argConfigFile, []byte(file.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := load(map[string]bool{}); err != nil {
+			t.Errorf("failed to load %s: %v", file.name, err)
+		}
+		if *testInterval != 5*time.Second {
+			t.Errorf("expected %s to set an interval of 5s, got %v", file.name, *testInterval)
+		}
+	}
+
+	if err := ioutil.WriteFile(*argConfigFile, []byte("test_unknown=5s\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := load(map[string]bool{}); err == nil {
+		t.Error("expected an error for an unknown setting")
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	defer reset()
+	var applied []time.Duration
+	OnReload(func() error {
+		applied = append(applied, *testInterval)
+		if *testInterval > time.Minute {
+			return fmt.Errorf("interval too long")
+		}
+		return nil
+	})
+
+	if err := Update(map[string]string{"test_interval": "10s"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Update(map[string]string{"test_interval": "1h", "test_filter": "a"}); err == nil {
+		t.Error("expected the failure of the hook to be returned")
+	}
+	if *testInterval != 10*time.Second || *testFilter != "" {
+		t.Errorf("expected the settings to be restored, got %v and %q", *testInterval, *testFilter)
+	}
+	expected := []time.Duration{10 * time.Second, time.Hour, 10 * time.Second}
+	if !reflect.DeepEqual(applied, expected) {
+		t.Errorf("expected the hook to apply %v, got %v", expected, applied)
+	}
+
+	if err := Update(map[string]string{"test_interval": "forever"}); err == nil {
+		t.Error("expected an error for an invalid value")
+	}
+	if err := Update(map[string]string{"port": "8080"}); err == nil {
+		t.Error("expected an error for a flag which cannot be reloaded")
+	}
+	if settings := Reloadable(); settings["test_interval"] != "10s" {
+		t.Errorf("expected the current interval of 10s, got %v", settings)
+	}
+}
+
+func TestReload(t *testing.T) {
+	defer reset()
+	reloads := 0
+	OnReload(func() error {
+		reloads++
+		return nil
+	})
+	if err := Reload(); err == nil {
+		t.Error("expected an error without a config file")
+	}
+
+	*argConfigFile = writeConfigFile(t, "test_interval=5s\ntest_filter=a\n")
+	defer os.Remove(*argConfigFile)
+	*testFilter = "command line"
+	if err := load(map[string]bool{"test_filter": true}); err != nil {
+		t.Fatal(err)
+	}
+	if *testInterval != 5*time.Second || *testFilter != "command line" {
+		t.Errorf("expected the file to set the interval but not the filter, got %v and %q", *testInterval, *testFilter)
+	}
+	if reloads != 0 {
+		t.Errorf("expected the settings not to be applied when loaded, got %d reloads", reloads)
+	}
+
+	if err := ioutil.WriteFile(*argConfigFile, []byte("test_filter=b\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if *testInterval != time.Second || *testFilter != "command line" {
+		t.Errorf("expected the default interval and the filter of the command line, got %v and %q", *testInterval, *testFilter)
+	}
+	if reloads != 1 {
+		t.Errorf("expected the settings to be applied once, got %d reloads", reloads)
+	}
+}
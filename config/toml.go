@@ -0,0 +1,117 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Parses the settings of a TOML file. Keys are flag names, those of a table being prefixed by its
+// name and an underscore so that [housekeeping] and interval = "5s" set housekeeping_interval.
+// Arrays are comma-separated values. Multi-line strings, inline tables and arrays of tables are
+// not supported.
+func parseToml(data []byte) (map[string]string, error) {
+	settings := make(map[string]string)
+	prefix := ""
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := scanner.Text()
+		if comment := indexUnquoted(line, '#'); comment >= 0 {
+			line = line[:comment]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if strings.HasPrefix(line, "[[") || !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: unsupported table %s", lineNumber, line)
+			}
+			table, err := parseTomlKey(line[1 : len(line)-1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+			}
+			prefix = table + "_"
+			continue
+		}
+
+		equal := indexUnquoted(line, '=')
+		if equal < 0 {
+			return nil, fmt.Errorf("line %d: expected key = value, got %q", lineNumber, line)
+		}
+		key, err := parseTomlKey(line[:equal])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+		}
+		value, err := parseTomlValue(strings.TrimSpace(line[equal+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid value of %q: %v", lineNumber, key, err)
+		}
+		if err := addSetting(settings, prefix+key, value); err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// Returns the setting name of a possibly dotted and quoted key.
+func parseTomlKey(key string) (string, error) {
+	var parts []string
+	for _, part := range strings.Split(key, ".") {
+		part = strings.TrimSpace(part)
+		if strings.HasPrefix(part, "\"") || strings.HasPrefix(part, "'") {
+			var err error
+			if part, err = unquote(part); err != nil {
+				return "", err
+			}
+		}
+		if part == "" {
+			return "", fmt.Errorf("empty key in %q", key)
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, "_"), nil
+}
+
+func parseTomlValue(value string) (string, error) {
+	switch {
+	case value == "":
+		return "", fmt.Errorf("missing value")
+	case strings.HasPrefix(value, `"""`) || strings.HasPrefix(value, "'''"):
+		return "", fmt.Errorf("multi-line strings are not supported")
+	case value[0] == '"' || value[0] == '\'':
+		return unquote(value)
+	case value[0] == '[':
+		return parseList(value, func(element string) (string, error) {
+			if strings.HasPrefix(element, "[") {
+				return "", fmt.Errorf("nested arrays are not supported")
+			}
+			return parseTomlValue(element)
+		})
+	case value[0] == '{':
+		return "", fmt.Errorf("inline tables are not supported")
+	case strings.ContainsAny(value, " \t"):
+		return "", fmt.Errorf("unexpected space in %q", value)
+	}
+	// Booleans, numbers and dates are taken as written.
+	return value, nil
+}
@@ -0,0 +1,75 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseToml(t *testing.T) {
+	settings, err := parseToml([]byte(`
+# Collect every 5s.
+global_housekeeping_interval = "1m"  # Comment.
+allow_dynamic_housekeeping = false
+max_procs = 4
+storage_driver = 'influxdb'
+enable_metrics = ["cpu", "memory", 'network']
+"docker_only" = true
+
+[housekeeping]
+interval = "10s#1"
+workers = 8
+
+[storage.driver]
+"user" = "root"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"global_housekeeping_interval": "1m",
+		"allow_dynamic_housekeeping":   "false",
+		"max_procs":                    "4",
+		"storage_driver":               "influxdb",
+		"enable_metrics":               "cpu,memory,network",
+		"docker_only":                  "true",
+		"housekeeping_interval":        "10s#1",
+		"housekeeping_workers":         "8",
+		"storage_driver_user":          "root",
+	}
+	if !reflect.DeepEqual(settings, expected) {
+		t.Errorf("expected %v, got %v", expected, settings)
+	}
+}
+
+func TestParseTomlErrors(t *testing.T) {
+	for _, content := range []string{
+		"port",
+		"port =",
+		"port = 8080\nport = 8081",
+		"[[drivers]]",
+		"[storage",
+		"port = \"\"\"8080\"\"\"",
+		"port = {a = 1}",
+		"port = \"8080",
+		"metrics = [[\"cpu\"]]",
+		"driver = influx db",
+	} {
+		if settings, err := parseToml([]byte(content)); err == nil {
+			t.Errorf("expected an error for %q, got %v", content, settings)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Mapping of a YAML file whose keys are being read.
+type yamlMapping struct {
+	// Indentation of its keys, -1 until its first key.
+	indent int
+	prefix string
+}
+
+// Key of a YAML file without a value, whose nested mapping or sequence may follow.
+type yamlKey struct {
+	name     string
+	indent   int
+	sequence bool
+	items    []string
+}
+
+// Parses the settings of a YAML file. Keys are flag names, those of a nested mapping being
+// prefixed by its key and an underscore so that housekeeping: {interval: 5s} written as a block
+// sets housekeeping_interval. Sequences are comma-separated values. Anchors, tags, flow mappings,
+// multi-line scalars and several documents are not supported.
+func parseYaml(data []byte) (map[string]string, error) {
+	// Mappings the current line is in, innermost last.
+	mappings := []yamlMapping{{indent: -1}}
+	var pending *yamlKey
+
+	settings := make(map[string]string)
+	started := false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := scanner.Text()
+		if comment := yamlComment(line); comment >= 0 {
+			line = line[:comment]
+		}
+		content := strings.TrimSpace(line)
+		if content == "" {
+			continue
+		}
+		if content == "---" && !started {
+			started = true
+			continue
+		}
+		if content == "---" || content == "..." {
+			return nil, fmt.Errorf("line %d: several documents are not supported", lineNumber)
+		}
+		started = true
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		if strings.HasPrefix(line[indent:], "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed in indentation", lineNumber)
+		}
+
+		if pending != nil {
+			isItem := content == "-" || strings.HasPrefix(content, "- ")
+			switch {
+			case isItem && indent >= pending.indent:
+				item := strings.TrimSpace(content[1:])
+				if item == "" || item[len(item)-1] == ':' || yamlColon(item) >= 0 || item[0] == '-' {
+					return nil, fmt.Errorf("line %d: only sequences of values are supported", lineNumber)
+				}
+				value, err := parseYamlValue(item)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: invalid value of %q: %v", lineNumber, pending.name, err)
+				}
+				pending.sequence = true
+				pending.items = append(pending.items, value)
+				continue
+			case pending.sequence:
+				if err := addSetting(settings, pending.name, strings.Join(pending.items, ",")); err != nil {
+					return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+				}
+			case indent > pending.indent:
+				mappings = append(mappings, yamlMapping{indent: indent, prefix: pending.name + "_"})
+			default:
+				// A key without a value is null.
+				if err := addSetting(settings, pending.name, ""); err != nil {
+					return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+				}
+			}
+			pending = nil
+		}
+
+		for len(mappings) > 1 && indent < mappings[len(mappings)-1].indent {
+			mappings = mappings[:len(mappings)-1]
+		}
+		current := &mappings[len(mappings)-1]
+		if current.indent < 0 {
+			current.indent = indent
+		}
+		if indent != current.indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", lineNumber)
+		}
+		colon := yamlColon(content)
+		if colon < 0 || strings.HasPrefix(content, "-") {
+			return nil, fmt.Errorf("line %d: expected key: value, got %q", lineNumber, content)
+		}
+		key := strings.TrimSpace(content[:colon])
+		if strings.HasPrefix(key, "\"") || strings.HasPrefix(key, "'") {
+			var err error
+			if key, err = parseYamlValue(key); err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+			}
+		}
+		name := current.prefix + key
+		value := strings.TrimSpace(content[colon+1:])
+		if value == "" {
+			pending = &yamlKey{name: name, indent: indent}
+			continue
+		}
+		parsed, err := parseYamlValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid value of %q: %v", lineNumber, name, err)
+		}
+		if err := addSetting(settings, name, parsed); err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if pending != nil {
+		if err := addSetting(settings, pending.name, strings.Join(pending.items, ",")); err != nil {
+			return nil, err
+		}
+	}
+	return settings, nil
+}
+
+// Returns the index of the # starting a comment in the line, -1 if there is none. Comments start
+// at the beginning of the line or after a space, outside quoted strings.
+func yamlComment(line string) int {
+	for start := 0; start < len(line); {
+		i := indexUnquoted(line[start:], '#')
+		if i < 0 {
+			return -1
+		}
+		i += start
+		if i == 0 || line[i-1] == ' ' || line[i-1] == '\t' {
+			return i
+		}
+		start = i + 1
+	}
+	return -1
+}
+
+// Returns the index of the colon ending the key of a mapping entry, -1 if there is none. The colon
+// is followed by a space or ends the line.
+func yamlColon(content string) int {
+	for start := 0; start < len(content); {
+		i := indexUnquoted(content[start:], ':')
+		if i < 0 {
+			return -1
+		}
+		i += start
+		if i == len(content)-1 || content[i+1] == ' ' {
+			return i
+		}
+		start = i + 1
+	}
+	return -1
+}
+
+func parseYamlValue(value string) (string, error) {
+	switch {
+	case value == "~" || value == "null" || value == "Null" || value == "NULL":
+		return "", nil
+	case value[0] == '|' || value[0] == '>':
+		return "", fmt.Errorf("multi-line scalars are not supported")
+	case value[0] == '&' || value[0] == '*' || value[0] == '!':
+		return "", fmt.Errorf("anchors, aliases and tags are not supported")
+	case value[0] == '{':
+		return "", fmt.Errorf("flow mappings are not supported")
+	case value[0] == '[':
+		return parseList(value, func(element string) (string, error) {
+			if strings.HasPrefix(element, "[") {
+				return "", fmt.Errorf("nested sequences are not supported")
+			}
+			return parseYamlValue(element)
+		})
+	case value[0] == '\'':
+		// Quotes are escaped by doubling them.
+		if len(value) < 2 || value[len(value)-1] != '\'' {
+			return "", fmt.Errorf("unterminated string %s", value)
+		}
+		inner := value[1 : len(value)-1]
+		if strings.Contains(strings.Replace(inner, "''", "", -1), "'") {
+			return "", fmt.Errorf("unexpected quote in %s", value)
+		}
+		return strings.Replace(inner, "''", "'", -1), nil
+	case value[0] == '"':
+		return unquote(value)
+	}
+	return value, nil
+}
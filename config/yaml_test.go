@@ -0,0 +1,82 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseYaml(t *testing.T) {
+	settings, err := parseYaml([]byte(`---
+# Collect every 5s.
+housekeeping_interval: 5s   # Comment.
+allow_dynamic_housekeeping: false
+storage_driver: influxdb
+storage_driver_influxdb:
+  db: cadvisor
+  host: "localhost:8086"
+  secure:
+tags:
+  a: 'it''s'
+enable_metrics: [cpu, "memory", 'network']
+disable_metrics:
+- percpu
+- process
+docker: unix:///var/run/docker.sock
+"docker_tls": ~
+storage_driver_exclude_containers: ^/system#1$
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"housekeeping_interval":             "5s",
+		"allow_dynamic_housekeeping":        "false",
+		"storage_driver":                    "influxdb",
+		"storage_driver_influxdb_db":        "cadvisor",
+		"storage_driver_influxdb_host":      "localhost:8086",
+		"storage_driver_influxdb_secure":    "",
+		"tags_a":                            "it's",
+		"enable_metrics":                    "cpu,memory,network",
+		"disable_metrics":                   "percpu,process",
+		"docker":                            "unix:///var/run/docker.sock",
+		"docker_tls":                        "",
+		"storage_driver_exclude_containers": "^/system#1$",
+	}
+	if !reflect.DeepEqual(settings, expected) {
+		t.Errorf("expected %v, got %v", expected, settings)
+	}
+}
+
+func TestParseYamlErrors(t *testing.T) {
+	for _, content := range []string{
+		"port",
+		"port: 8080\nport: 8081",
+		"storage:\n  db: cadvisor\n    host: localhost",
+		"storage:\n    db: cadvisor\n  host: localhost",
+		"port: |\n  8080",
+		"port: &port 8080",
+		"port: {a: 1}",
+		"port: \"8080",
+		"a: 1\n---\nb: 2",
+		"metrics:\n- cpu: 1",
+		"\tport: 8080",
+	} {
+		if settings, err := parseYaml([]byte(content)); err == nil {
+			t.Errorf("expected an error for %q, got %v", content, settings)
+		}
+	}
+}
@@ -64,6 +64,14 @@ type ContainerHandler interface {
 	// Returns the processes inside this container.
 	ListProcesses(listType ListType) ([]int, error)
 
+	// Returns the absolute path to the cgroup of this container for a
+	// subsystem (e.g.: "perf_event" -> "/sys/fs/cgroup/perf_event/test").
+	GetCgroupPath(subsystem string) (string, error)
+
+	// Returns the IP address the container can be reached at by cAdvisor, e.g.: to collect the
+	// metrics of its application.
+	GetContainerIPAddress() string
+
 	// Registers a channel to listen for events affecting subcontainers (recursively).
 	WatchSubcontainers(events chan SubcontainerEvent) error
 
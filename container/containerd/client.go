@@ -0,0 +1,185 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containerd
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/google/cadvisor/utils/grpc"
+)
+
+// Methods of the containerd API.
+const (
+	listNamespacesMethod = "/containerd.services.namespaces.v1.Namespaces/List"
+	listContainersMethod = "/containerd.services.containers.v1.Containers/List"
+	listTasksMethod      = "/containerd.services.tasks.v1.Tasks/List"
+
+	// Header selecting the containerd namespace of a call.
+	namespaceHeader = "containerd-namespace"
+
+	// Status of running tasks.
+	taskRunning = 2
+)
+
+// A containerd container with a running task.
+type containerdContainer struct {
+	namespace string
+	id        string
+	image     string
+	labels    map[string]string
+	// Name of its cgroup (e.g.: "/k8s.io/<id>").
+	cgroup string
+}
+
+// The part of the OCI runtime spec locating the cgroup of the container.
+type ociSpec struct {
+	Linux *struct {
+		CgroupsPath string `json:"cgroupsPath"`
+	} `json:"linux"`
+}
+
+func listNamespaces(client *grpc.Client) ([]string, error) {
+	response, err := client.Invoke(listNamespacesMethod, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	m, err := grpc.Decode(response)
+	if err != nil {
+		return nil, err
+	}
+	namespaces, err := m.Messages(1)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(namespaces))
+	for _, namespace := range namespaces {
+		names = append(names, namespace.String(1))
+	}
+	return names, nil
+}
+
+// Returns the IDs of the containers of the namespace that have a running task.
+func listRunningTasks(client *grpc.Client, namespace string) (map[string]bool, error) {
+	response, err := client.Invoke(listTasksMethod, nil, map[string]string{namespaceHeader: namespace})
+	if err != nil {
+		return nil, err
+	}
+	m, err := grpc.Decode(response)
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := m.Messages(1)
+	if err != nil {
+		return nil, err
+	}
+	running := make(map[string]bool, len(tasks))
+	for _, task := range tasks {
+		if task.Uint(4) == taskRunning {
+			running[task.String(1)] = true
+		}
+	}
+	return running, nil
+}
+
+func listContainers(client *grpc.Client, namespace string) ([]*containerdContainer, error) {
+	response, err := client.Invoke(listContainersMethod, nil, map[string]string{namespaceHeader: namespace})
+	if err != nil {
+		return nil, err
+	}
+	return parseContainers(response, namespace)
+}
+
+// Decodes a ListContainersResponse.
+func parseContainers(response []byte, namespace string) ([]*containerdContainer, error) {
+	m, err := grpc.Decode(response)
+	if err != nil {
+		return nil, err
+	}
+	containers, err := m.Messages(1)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]*containerdContainer, 0, len(containers))
+	for _, c := range containers {
+		labels, err := c.Map(2)
+		if err != nil {
+			return nil, err
+		}
+		// The spec is an Any holding the JSON encoded OCI spec.
+		spec, err := c.Message(5)
+		if err != nil {
+			return nil, err
+		}
+		cgroupsPath := ""
+		if value := spec.String(2); value != "" {
+			var s ociSpec
+			if err := json.Unmarshal([]byte(value), &s); err != nil {
+				return nil, fmt.Errorf("malformed spec of container %q: %v", c.String(1), err)
+			}
+			if s.Linux != nil {
+				cgroupsPath = s.Linux.CgroupsPath
+			}
+		}
+		ret = append(ret, &containerdContainer{
+			namespace: namespace,
+			id:        c.String(1),
+			image:     c.String(3),
+			labels:    labels,
+			cgroup:    cgroupName(cgroupsPath, namespace, c.String(1)),
+		})
+	}
+	return ret, nil
+}
+
+// Returns the name of the cgroup of the container from the cgroups path of its OCI spec.
+func cgroupName(cgroupsPath, namespace, id string) string {
+	switch {
+	case cgroupsPath == "":
+		// Default of containerd.
+		return path.Join("/", namespace, id)
+	case strings.Count(cgroupsPath, ":") == 2 && !strings.HasPrefix(cgroupsPath, "/"):
+		// Path of the systemd cgroup driver: "slice:prefix:name", e.g.
+		// "system.slice:cri-containerd:<id>" is /system.slice/cri-containerd-<id>.scope.
+		parts := strings.SplitN(cgroupsPath, ":", 3)
+		slice := parts[0]
+		if slice == "" {
+			slice = "system.slice"
+		}
+		scope := parts[2] + ".scope"
+		if parts[1] != "" {
+			scope = parts[1] + "-" + scope
+		}
+		return path.Join("/", expandSlice(slice), scope)
+	}
+	return path.Clean(path.Join("/", cgroupsPath))
+}
+
+// Returns the path of the systemd slice in the cgroup hierarchy: each dash separates a parent slice,
+// e.g. "kubepods-burstable.slice" is "kubepods.slice/kubepods-burstable.slice".
+func expandSlice(slice string) string {
+	name := strings.TrimSuffix(slice, ".slice")
+	if name == "" || name == "-" {
+		return "/"
+	}
+	parts := strings.Split(name, "-")
+	dirs := make([]string, 0, len(parts))
+	for i := range parts {
+		dirs = append(dirs, strings.Join(parts[:i+1], "-")+".slice")
+	}
+	return path.Join(dirs...)
+}
@@ -0,0 +1,136 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containerd
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/cadvisor/utils/grpc"
+	gtest "github.com/google/cadvisor/utils/grpc/test"
+)
+
+func TestCgroupName(t *testing.T) {
+	cases := []struct {
+		cgroupsPath string
+		expected    string
+	}{
+		{"", "/default/abc"},
+		{"/k8s.io/abc", "/k8s.io/abc"},
+		{"kubepods/besteffort/podxyz/abc", "/kubepods/besteffort/podxyz/abc"},
+		{"system.slice:containerd:abc", "/system.slice/containerd-abc.scope"},
+		{"kubepods-besteffort-podxyz.slice:cri-containerd:abc", "/kubepods.slice/kubepods-besteffort.slice/kubepods-besteffort-podxyz.slice/cri-containerd-abc.scope"},
+		{":cri-containerd:abc", "/system.slice/cri-containerd-abc.scope"},
+		{"-.slice::abc", "/abc.scope"},
+	}
+	for _, c := range cases {
+		if name := cgroupName(c.cgroupsPath, "default", "abc"); name != c.expected {
+			t.Errorf("cgroupName(%q) = %q, expected %q", c.cgroupsPath, name, c.expected)
+		}
+	}
+}
+
+func containerMessage(id, image, cgroupsPath string, labels map[string]string) *grpc.Encoder {
+	spec := &grpc.Encoder{}
+	spec.String(1, "types.containerd.io/opencontainers/runtime-spec/1/Spec")
+	spec.String(2, `{"ociVersion":"1.0.0","linux":{"cgroupsPath":"`+cgroupsPath+`"}}`)
+	c := &grpc.Encoder{}
+	c.String(1, id)
+	for k, v := range labels {
+		c.MapEntry(2, k, v)
+	}
+	c.String(3, image)
+	c.Message(5, spec)
+	return c
+}
+
+func task(id string, status uint64) *grpc.Encoder {
+	t := &grpc.Encoder{}
+	t.String(1, id)
+	t.String(2, id)
+	t.Uint(3, 42)
+	t.Uint(4, status)
+	return t
+}
+
+// Serves the namespaces "default" and "k8s.io", with running and stopped containers in the latter.
+func serve(t *testing.T, socket string) *http.Server {
+	return gtest.Serve(t, socket, map[string]gtest.Method{
+		listNamespacesMethod: func(r *http.Request) *grpc.Encoder {
+			response := &grpc.Encoder{}
+			for _, name := range []string{"default", "k8s.io"} {
+				namespace := &grpc.Encoder{}
+				namespace.String(1, name)
+				response.Message(1, namespace)
+			}
+			return response
+		},
+		listTasksMethod: func(r *http.Request) *grpc.Encoder {
+			response := &grpc.Encoder{}
+			if r.Header.Get(namespaceHeader) == "k8s.io" {
+				response.Message(1, task("running", taskRunning))
+				response.Message(1, task("stopped", 3))
+			}
+			return response
+		},
+		listContainersMethod: func(r *http.Request) *grpc.Encoder {
+			response := &grpc.Encoder{}
+			if r.Header.Get(namespaceHeader) == "k8s.io" {
+				response.Message(1, containerMessage("running", "docker.io/library/redis:latest", "/k8s.io/running", map[string]string{"app": "redis"}))
+				response.Message(1, containerMessage("stopped", "docker.io/library/nginx:latest", "", nil))
+			}
+			return response
+		},
+	})
+}
+
+func TestLookup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "containerd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "containerd.sock")
+	server := serve(t, socket)
+	defer server.Close()
+
+	factory := newFactory(socket, "", nil, nil)
+	c, err := factory.lookup("/k8s.io/running")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c == nil {
+		t.Fatalf("running container not found")
+	}
+	if c.id != "running" || c.namespace != "k8s.io" || c.image != "docker.io/library/redis:latest" || c.labels["app"] != "redis" {
+		t.Errorf("unexpected container %+v", c)
+	}
+	// Containers without a running task are not handled.
+	ok, err := factory.CanHandle("/k8s.io/stopped")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Errorf("stopped container should not be handled")
+	}
+
+	factory = newFactory(socket, "default", nil, nil)
+	if ok, err := factory.CanHandle("/k8s.io/running"); err != nil || ok {
+		t.Errorf("container outside the monitored namespaces should not be handled: %v, %v", ok, err)
+	}
+}
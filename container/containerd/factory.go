@@ -0,0 +1,172 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package containerd implements a container handler factory for the containers run by containerd.
+package containerd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/cadvisor/container"
+	"github.com/google/cadvisor/container/libcontainer"
+	"github.com/google/cadvisor/info"
+	"github.com/google/cadvisor/utils/grpc"
+)
+
+var ArgContainerdEndpoint = flag.String("containerd", "/run/containerd/containerd.sock", "containerd endpoint")
+var ArgContainerdNamespaces = flag.String("containerd_namespaces", "", "Comma separated list of the containerd namespaces to monitor, all of them if empty")
+
+// The namespace under which containerd aliases are unique.
+const ContainerdNamespace = "containerd"
+
+// Timeout of the calls to containerd.
+const callTimeout = 10 * time.Second
+
+// Minimum time between two listings of the containers of containerd.
+const minRefreshInterval = time.Second
+
+type containerdFactory struct {
+	machineInfoFactory info.MachineInfoFactory
+
+	// Information about the mounted cgroup subsystems.
+	cgroupSubsystems *libcontainer.CgroupSubsystems
+
+	client *grpc.Client
+
+	// The namespaces to monitor, all of them if empty.
+	namespaces []string
+
+	// Running containers keyed by the name of their cgroup.
+	lock        sync.Mutex
+	containers  map[string]*containerdContainer
+	lastRefresh time.Time
+}
+
+func (self *containerdFactory) String() string {
+	return ContainerdNamespace
+}
+
+func (self *containerdFactory) NewContainerHandler(name string) (container.ContainerHandler, error) {
+	c, err := self.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		return nil, fmt.Errorf("no containerd container for %q", name)
+	}
+	return newContainerdContainerHandler(name, c, self.cgroupSubsystems, self.machineInfoFactory)
+}
+
+// Handles the cgroups of the containers with a running task.
+func (self *containerdFactory) CanHandle(name string) (bool, error) {
+	c, err := self.lookup(name)
+	if err != nil {
+		return false, err
+	}
+	return c != nil, nil
+}
+
+// Returns the running container with the specified cgroup, nil if there is none. The containers
+// are listed again when the cgroup is unknown, at most once per minRefreshInterval.
+func (self *containerdFactory) lookup(name string) (*containerdContainer, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if c, ok := self.containers[name]; ok {
+		return c, nil
+	}
+	if time.Since(self.lastRefresh) < minRefreshInterval {
+		return nil, nil
+	}
+	if err := self.refresh(); err != nil {
+		return nil, err
+	}
+	return self.containers[name], nil
+}
+
+// Lists the running containers of the monitored namespaces. Must be called with the lock held.
+func (self *containerdFactory) refresh() error {
+	self.lastRefresh = time.Now()
+	namespaces := self.namespaces
+	if len(namespaces) == 0 {
+		var err error
+		namespaces, err = listNamespaces(self.client)
+		if err != nil {
+			return fmt.Errorf("failed to list the containerd namespaces: %v", err)
+		}
+	}
+	containers := make(map[string]*containerdContainer)
+	for _, namespace := range namespaces {
+		running, err := listRunningTasks(self.client, namespace)
+		if err != nil {
+			return fmt.Errorf("failed to list the tasks of namespace %q: %v", namespace, err)
+		}
+		if len(running) == 0 {
+			continue
+		}
+		list, err := listContainers(self.client, namespace)
+		if err != nil {
+			return fmt.Errorf("failed to list the containers of namespace %q: %v", namespace, err)
+		}
+		for _, c := range list {
+			if running[c.id] {
+				containers[c.cgroup] = c
+			}
+		}
+	}
+	self.containers = containers
+	return nil
+}
+
+func newFactory(endpoint, namespaces string, cgroupSubsystems *libcontainer.CgroupSubsystems, machineInfoFactory info.MachineInfoFactory) *containerdFactory {
+	factory := &containerdFactory{
+		machineInfoFactory: machineInfoFactory,
+		cgroupSubsystems:   cgroupSubsystems,
+		client:             grpc.NewClient(endpoint, callTimeout),
+		containers:         make(map[string]*containerdContainer),
+	}
+	for _, namespace := range strings.Split(namespaces, ",") {
+		if namespace = strings.TrimSpace(namespace); namespace != "" {
+			factory.namespaces = append(factory.namespaces, namespace)
+		}
+	}
+	return factory
+}
+
+func Register(machineInfoFactory info.MachineInfoFactory) error {
+	if _, err := os.Stat(*ArgContainerdEndpoint); err != nil {
+		return fmt.Errorf("containerd does not seem to be running: %v", err)
+	}
+	cgroupSubsystems, err := libcontainer.GetCgroupSubsystems()
+	if err != nil {
+		return fmt.Errorf("failed to get cgroup subsystems: %v", err)
+	}
+
+	factory := newFactory(*ArgContainerdEndpoint, *ArgContainerdNamespaces, &cgroupSubsystems, machineInfoFactory)
+	factory.lock.Lock()
+	err = factory.refresh()
+	factory.lock.Unlock()
+	if err != nil {
+		return fmt.Errorf("unable to communicate with containerd: %v", err)
+	}
+
+	glog.Infof("Registering containerd factory")
+	container.RegisterContainerHandlerFactory(factory, container.PriorityRuntime)
+	return nil
+}
@@ -0,0 +1,71 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containerd
+
+import (
+	"github.com/google/cadvisor/container"
+	"github.com/google/cadvisor/container/libcontainer"
+	"github.com/google/cadvisor/container/raw"
+	"github.com/google/cadvisor/info"
+)
+
+// Collects the stats of containerd containers from their cgroups, and their metadata from containerd.
+type containerdContainerHandler struct {
+	container.ContainerHandler
+
+	name    string
+	aliases []string
+	image   string
+	labels  map[string]string
+}
+
+func newContainerdContainerHandler(name string, c *containerdContainer, cgroupSubsystems *libcontainer.CgroupSubsystems, machineInfoFactory info.MachineInfoFactory) (container.ContainerHandler, error) {
+	rawHandler, err := raw.NewContainerHandler(name, cgroupSubsystems, machineInfoFactory)
+	if err != nil {
+		return nil, err
+	}
+	labels := make(map[string]string, len(c.labels)+1)
+	for k, v := range c.labels {
+		labels[k] = v
+	}
+	labels["containerd.namespace"] = c.namespace
+	return &containerdContainerHandler{
+		ContainerHandler: rawHandler,
+		name:             name,
+		aliases:          []string{c.id},
+		image:            c.image,
+		labels:           labels,
+	}, nil
+}
+
+func (self *containerdContainerHandler) ContainerReference() (info.ContainerReference, error) {
+	return info.ContainerReference{
+		Name:      self.name,
+		Aliases:   self.aliases,
+		Namespace: ContainerdNamespace,
+		Labels:    self.labels,
+		Image:     self.image,
+	}, nil
+}
+
+func (self *containerdContainerHandler) GetSpec() (info.ContainerSpec, error) {
+	spec, err := self.ContainerHandler.GetSpec()
+	if err != nil {
+		return spec, err
+	}
+	spec.Labels = self.labels
+	spec.Image = self.image
+	return spec, nil
+}
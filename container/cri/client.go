@@ -0,0 +1,120 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cri
+
+import (
+	"github.com/google/cadvisor/utils/grpc"
+)
+
+// Methods of the Kubernetes Container Runtime Interface.
+const (
+	listPodSandboxMethod = "/runtime.v1.RuntimeService/ListPodSandbox"
+	listContainersMethod = "/runtime.v1.RuntimeService/ListContainers"
+
+	// State of running containers.
+	containerRunning = 1
+)
+
+// A pod sandbox of the runtime.
+type podSandbox struct {
+	id        string
+	name      string
+	uid       string
+	namespace string
+	labels    map[string]string
+}
+
+// A container of a pod sandbox.
+type criContainer struct {
+	id        string
+	sandboxId string
+	name      string
+	image     string
+	running   bool
+	labels    map[string]string
+}
+
+func listPodSandboxes(client *grpc.Client) ([]*podSandbox, error) {
+	response, err := client.Invoke(listPodSandboxMethod, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	m, err := grpc.Decode(response)
+	if err != nil {
+		return nil, err
+	}
+	items, err := m.Messages(1)
+	if err != nil {
+		return nil, err
+	}
+	sandboxes := make([]*podSandbox, 0, len(items))
+	for _, item := range items {
+		metadata, err := item.Message(2)
+		if err != nil {
+			return nil, err
+		}
+		labels, err := item.Map(5)
+		if err != nil {
+			return nil, err
+		}
+		sandboxes = append(sandboxes, &podSandbox{
+			id:        item.String(1),
+			name:      metadata.String(1),
+			uid:       metadata.String(2),
+			namespace: metadata.String(3),
+			labels:    labels,
+		})
+	}
+	return sandboxes, nil
+}
+
+func listContainers(client *grpc.Client) ([]*criContainer, error) {
+	response, err := client.Invoke(listContainersMethod, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	m, err := grpc.Decode(response)
+	if err != nil {
+		return nil, err
+	}
+	items, err := m.Messages(1)
+	if err != nil {
+		return nil, err
+	}
+	containers := make([]*criContainer, 0, len(items))
+	for _, item := range items {
+		metadata, err := item.Message(3)
+		if err != nil {
+			return nil, err
+		}
+		image, err := item.Message(4)
+		if err != nil {
+			return nil, err
+		}
+		labels, err := item.Map(8)
+		if err != nil {
+			return nil, err
+		}
+		containers = append(containers, &criContainer{
+			id:        item.String(1),
+			sandboxId: item.String(2),
+			name:      metadata.String(1),
+			image:     image.String(1),
+			running:   item.Uint(6) == containerRunning,
+			labels:    labels,
+		})
+	}
+	return containers, nil
+}
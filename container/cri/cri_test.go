@@ -0,0 +1,186 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cri
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/cadvisor/container/libcontainer"
+	"github.com/google/cadvisor/utils/grpc"
+	gtest "github.com/google/cadvisor/utils/grpc/test"
+)
+
+var (
+	sandboxId   = strings.Repeat("a", 64)
+	containerId = strings.Repeat("b", 64)
+	exitedId    = strings.Repeat("c", 64)
+	podUid      = "0f1e2d3c-4b5a-6978-8796-a5b4c3d2e1f0"
+)
+
+func TestParseCgroupName(t *testing.T) {
+	cases := []struct {
+		name        string
+		containerId string
+		podUid      string
+	}{
+		{"/kubepods.slice/kubepods-besteffort.slice/kubepods-besteffort-pod0f1e2d3c_4b5a_6978_8796_a5b4c3d2e1f0.slice/crio-" + containerId + ".scope", containerId, ""},
+		{"/kubepods.slice/kubepods-besteffort.slice/kubepods-besteffort-pod0f1e2d3c_4b5a_6978_8796_a5b4c3d2e1f0.slice/cri-containerd-" + containerId + ".scope", containerId, ""},
+		{"/kubepods/burstable/pod" + podUid + "/" + containerId, containerId, ""},
+		{"/kubepods.slice/kubepods-besteffort.slice/kubepods-besteffort-pod0f1e2d3c_4b5a_6978_8796_a5b4c3d2e1f0.slice", "", podUid},
+		{"/kubepods/burstable/pod" + podUid, "", podUid},
+		{"/kubepods.slice/kubepods-besteffort.slice/kubepods-besteffort-pod0f1e2d3c_4b5a_6978_8796_a5b4c3d2e1f0.slice/crio-conmon-" + containerId + ".scope", "", ""},
+		{"/kubepods.slice/kubepods-besteffort.slice", "", ""},
+		{"/system.slice/docker.service", "", ""},
+	}
+	for _, c := range cases {
+		containerId, podUid := parseCgroupName(c.name)
+		if containerId != c.containerId || podUid != c.podUid {
+			t.Errorf("parseCgroupName(%q) = %q, %q, expected %q, %q", c.name, containerId, podUid, c.containerId, c.podUid)
+		}
+	}
+}
+
+func containerMessage(id, name string, state uint64) *grpc.Encoder {
+	metadata := &grpc.Encoder{}
+	metadata.String(1, name)
+	image := &grpc.Encoder{}
+	image.String(1, "docker.io/library/redis:latest")
+	c := &grpc.Encoder{}
+	c.String(1, id)
+	c.String(2, sandboxId)
+	c.Message(3, metadata)
+	c.Message(4, image)
+	c.Uint(6, state)
+	c.MapEntry(8, containerNameLabel, name)
+	return c
+}
+
+// Serves a pod with a running and an exited container.
+func serve(t *testing.T, socket string) *http.Server {
+	return gtest.Serve(t, socket, map[string]gtest.Method{
+		listPodSandboxMethod: func(r *http.Request) *grpc.Encoder {
+			metadata := &grpc.Encoder{}
+			metadata.String(1, "redis-0")
+			metadata.String(2, podUid)
+			metadata.String(3, "default")
+			sandbox := &grpc.Encoder{}
+			sandbox.String(1, sandboxId)
+			sandbox.Message(2, metadata)
+			sandbox.MapEntry(5, "app", "redis")
+			response := &grpc.Encoder{}
+			response.Message(1, sandbox)
+			return response
+		},
+		listContainersMethod: func(r *http.Request) *grpc.Encoder {
+			response := &grpc.Encoder{}
+			response.Message(1, containerMessage(containerId, "redis", containerRunning))
+			response.Message(1, containerMessage(exitedId, "init", 2))
+			return response
+		},
+	})
+}
+
+func TestLookup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cri")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "cri.sock")
+	server := serve(t, socket)
+	defer server.Close()
+
+	factory := newFactory(socket, nil, nil)
+	pod := "/kubepods/burstable/pod" + podUid
+	sandbox, c, err := factory.lookup(pod + "/" + containerId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sandbox == nil || c == nil {
+		t.Fatalf("running container not found")
+	}
+	if sandbox.name != "redis-0" || sandbox.namespace != "default" || sandbox.labels["app"] != "redis" {
+		t.Errorf("unexpected pod sandbox %+v", sandbox)
+	}
+	if c.name != "redis" || c.image != "docker.io/library/redis:latest" {
+		t.Errorf("unexpected container %+v", c)
+	}
+
+	cases := []struct {
+		name      string
+		canHandle bool
+	}{
+		{pod, true},
+		{pod + "/" + sandboxId, true},
+		{pod + "/" + exitedId, false},
+		{pod + "/" + strings.Repeat("d", 64), false},
+		{"/kubepods/burstable", false},
+	}
+	for _, c := range cases {
+		ok, err := factory.CanHandle(c.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != c.canHandle {
+			t.Errorf("CanHandle(%q) = %v, expected %v", c.name, ok, c.canHandle)
+		}
+	}
+}
+
+func TestContainerReference(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cri")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "cri.sock")
+	server := serve(t, socket)
+	defer server.Close()
+
+	factory := newFactory(socket, &libcontainer.CgroupSubsystems{}, nil)
+	pod := "/kubepods/burstable/pod" + podUid
+	cases := []struct {
+		name          string
+		aliases       []string
+		containerName string
+	}{
+		{pod, []string{"default/redis-0", podUid}, ""},
+		// The sandbox has its own aliases, distinct from those of its pod.
+		{pod + "/" + sandboxId, []string{"default/redis-0/POD", sandboxId}, "POD"},
+		{pod + "/" + containerId, []string{"default/redis-0/redis", containerId}, "redis"},
+	}
+	for _, c := range cases {
+		handler, err := factory.NewContainerHandler(c.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ref, err := handler.ContainerReference()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(ref.Aliases, c.aliases) || ref.Namespace != CriNamespace {
+			t.Errorf("expected the aliases %v of %q in the %q namespace, got %v in %q", c.aliases, c.name, CriNamespace, ref.Aliases, ref.Namespace)
+		}
+		if ref.Labels[containerNameLabel] != c.containerName || ref.Labels[podUidLabel] != podUid {
+			t.Errorf("unexpected labels of %q: %v", c.name, ref.Labels)
+		}
+	}
+}
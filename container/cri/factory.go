@@ -0,0 +1,228 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cri implements a container handler factory for the pods and containers of any runtime
+// implementing the Kubernetes Container Runtime Interface.
+package cri
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/cadvisor/container"
+	"github.com/google/cadvisor/container/libcontainer"
+	"github.com/google/cadvisor/info"
+	"github.com/google/cadvisor/utils/grpc"
+)
+
+var ArgCriEndpoint = flag.String("cri", "", "CRI runtime endpoint, the sockets of CRI-O and containerd are tried if empty")
+
+// The namespace under which CRI aliases are unique.
+const CriNamespace = "cri"
+
+// Sockets of the CRI runtimes tried when no endpoint is specified.
+var defaultEndpoints = []string{
+	"/var/run/crio/crio.sock",
+	"/run/containerd/containerd.sock",
+}
+
+// Timeout of the calls to the runtime.
+const callTimeout = 10 * time.Second
+
+// Minimum time between two listings of the pods and containers of the runtime.
+const minRefreshInterval = time.Second
+
+// Cgroup of a container, named after its ID by the runtime:
+// e.g.: crio-<ID>.scope, cri-containerd-<ID>.scope or <ID> for the cgroupfs driver.
+var containerCgroup = regexp.MustCompile(`^(?:[a-z-]+-)?([0-9a-f]{64})(?:\.scope)?$`)
+
+// Cgroup of a pod, named after its UID by the kubelet:
+// e.g.: pod<UID> or kubepods-burstable-pod<UID>.slice with the dashes of the UID as underscores.
+var podCgroup = regexp.MustCompile(`pod([0-9a-f]{8}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{12})(?:\.slice)?$`)
+
+// Returns the container ID or pod UID the cgroup is named after, both empty if neither.
+func parseCgroupName(name string) (containerId, podUid string) {
+	base := path.Base(name)
+	// The monitor process of CRI-O runs in its own cgroup, e.g.: crio-conmon-<ID>.scope.
+	if strings.Contains(base, "conmon") {
+		return "", ""
+	}
+	if matches := containerCgroup.FindStringSubmatch(base); matches != nil {
+		return matches[1], ""
+	}
+	if matches := podCgroup.FindStringSubmatch(base); matches != nil {
+		return "", strings.Replace(matches[1], "_", "-", -1)
+	}
+	return "", ""
+}
+
+type criFactory struct {
+	machineInfoFactory info.MachineInfoFactory
+
+	// Information about the mounted cgroup subsystems.
+	cgroupSubsystems *libcontainer.CgroupSubsystems
+
+	client *grpc.Client
+
+	lock sync.Mutex
+	// Pod sandboxes keyed by their ID, and by the UID of their pod.
+	sandboxes map[string]*podSandbox
+	pods      map[string]*podSandbox
+	// Containers keyed by their ID.
+	containers  map[string]*criContainer
+	lastRefresh time.Time
+}
+
+func (self *criFactory) String() string {
+	return CriNamespace
+}
+
+func (self *criFactory) NewContainerHandler(name string) (container.ContainerHandler, error) {
+	sandbox, c, err := self.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	if sandbox == nil {
+		return nil, fmt.Errorf("no CRI pod or container for %q", name)
+	}
+	return newCriContainerHandler(name, sandbox, c, self.cgroupSubsystems, self.machineInfoFactory)
+}
+
+// Handles the cgroups of pods and of their running containers.
+func (self *criFactory) CanHandle(name string) (bool, error) {
+	sandbox, _, err := self.lookup(name)
+	if err != nil {
+		return false, err
+	}
+	return sandbox != nil, nil
+}
+
+// Returns the pod sandbox the cgroup is for, along with the container for the cgroup of a
+// container. The runtime is listed again when they are unknown, at most once per
+// minRefreshInterval.
+func (self *criFactory) lookup(name string) (*podSandbox, *criContainer, error) {
+	containerId, podUid := parseCgroupName(name)
+	if containerId == "" && podUid == "" {
+		return nil, nil, nil
+	}
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	sandbox, c := self.find(containerId, podUid)
+	if sandbox != nil || time.Since(self.lastRefresh) < minRefreshInterval {
+		return sandbox, c, nil
+	}
+	if err := self.refresh(); err != nil {
+		return nil, nil, err
+	}
+	sandbox, c = self.find(containerId, podUid)
+	return sandbox, c, nil
+}
+
+// Must be called with the lock held.
+func (self *criFactory) find(containerId, podUid string) (*podSandbox, *criContainer) {
+	if podUid != "" {
+		return self.pods[podUid], nil
+	}
+	// The infrastructure container of a pod has the ID of its sandbox.
+	if sandbox, ok := self.sandboxes[containerId]; ok {
+		return sandbox, nil
+	}
+	c, ok := self.containers[containerId]
+	if !ok || !c.running {
+		return nil, nil
+	}
+	sandbox, ok := self.sandboxes[c.sandboxId]
+	if !ok {
+		return nil, nil
+	}
+	return sandbox, c
+}
+
+// Lists the pod sandboxes and containers of the runtime. Must be called with the lock held.
+func (self *criFactory) refresh() error {
+	self.lastRefresh = time.Now()
+	sandboxes, err := listPodSandboxes(self.client)
+	if err != nil {
+		return fmt.Errorf("failed to list the pod sandboxes: %v", err)
+	}
+	containers, err := listContainers(self.client)
+	if err != nil {
+		return fmt.Errorf("failed to list the containers: %v", err)
+	}
+	self.sandboxes = make(map[string]*podSandbox, len(sandboxes))
+	self.pods = make(map[string]*podSandbox, len(sandboxes))
+	for _, sandbox := range sandboxes {
+		self.sandboxes[sandbox.id] = sandbox
+		self.pods[sandbox.uid] = sandbox
+	}
+	self.containers = make(map[string]*criContainer, len(containers))
+	for _, c := range containers {
+		self.containers[c.id] = c
+	}
+	return nil
+}
+
+func newFactory(endpoint string, cgroupSubsystems *libcontainer.CgroupSubsystems, machineInfoFactory info.MachineInfoFactory) *criFactory {
+	return &criFactory{
+		machineInfoFactory: machineInfoFactory,
+		cgroupSubsystems:   cgroupSubsystems,
+		client:             grpc.NewClient(endpoint, callTimeout),
+	}
+}
+
+// Returns the endpoint of the runtime: the specified one, else the first default one that exists.
+func findEndpoint() (string, error) {
+	if *ArgCriEndpoint != "" {
+		if _, err := os.Stat(*ArgCriEndpoint); err != nil {
+			return "", err
+		}
+		return *ArgCriEndpoint, nil
+	}
+	for _, endpoint := range defaultEndpoints {
+		if _, err := os.Stat(endpoint); err == nil {
+			return endpoint, nil
+		}
+	}
+	return "", fmt.Errorf("none of %v exists", defaultEndpoints)
+}
+
+func Register(machineInfoFactory info.MachineInfoFactory) error {
+	endpoint, err := findEndpoint()
+	if err != nil {
+		return fmt.Errorf("no CRI runtime seems to be running: %v", err)
+	}
+	cgroupSubsystems, err := libcontainer.GetCgroupSubsystems()
+	if err != nil {
+		return fmt.Errorf("failed to get cgroup subsystems: %v", err)
+	}
+
+	factory := newFactory(endpoint, &cgroupSubsystems, machineInfoFactory)
+	factory.lock.Lock()
+	err = factory.refresh()
+	factory.lock.Unlock()
+	if err != nil {
+		return fmt.Errorf("unable to communicate with the CRI runtime at %q: %v", endpoint, err)
+	}
+
+	glog.Infof("Registering CRI factory for %q", endpoint)
+	container.RegisterContainerHandlerFactory(factory, container.PriorityRuntime)
+	return nil
+}
@@ -0,0 +1,99 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cri
+
+import (
+	"github.com/google/cadvisor/container"
+	"github.com/google/cadvisor/container/libcontainer"
+	"github.com/google/cadvisor/container/raw"
+	"github.com/google/cadvisor/info"
+)
+
+// Labels identifying the pod and container, the same as the kubelet's.
+const (
+	podNameLabel       = "io.kubernetes.pod.name"
+	podNamespaceLabel  = "io.kubernetes.pod.namespace"
+	podUidLabel        = "io.kubernetes.pod.uid"
+	containerNameLabel = "io.kubernetes.container.name"
+
+	// Name of the infrastructure container of pods, after the one given by the kubelet.
+	sandboxContainerName = "POD"
+)
+
+// Collects the stats of pods and their containers from their cgroups, and their metadata from the
+// CRI runtime.
+type criContainerHandler struct {
+	container.ContainerHandler
+
+	name    string
+	aliases []string
+	image   string
+	labels  map[string]string
+}
+
+// Creates the handler of the cgroup of a pod, of its sandbox (the infrastructure container), or of
+// one of its containers if c is not nil.
+func newCriContainerHandler(name string, sandbox *podSandbox, c *criContainer, cgroupSubsystems *libcontainer.CgroupSubsystems, machineInfoFactory info.MachineInfoFactory) (container.ContainerHandler, error) {
+	rawHandler, err := raw.NewContainerHandler(name, cgroupSubsystems, machineInfoFactory)
+	if err != nil {
+		return nil, err
+	}
+	handler := &criContainerHandler{
+		ContainerHandler: rawHandler,
+		name:             name,
+		labels:           make(map[string]string),
+	}
+	for k, v := range sandbox.labels {
+		handler.labels[k] = v
+	}
+	podName := sandbox.namespace + "/" + sandbox.name
+	if containerId, _ := parseCgroupName(name); containerId == sandbox.id {
+		handler.aliases = []string{podName + "/" + sandboxContainerName, sandbox.id}
+		handler.labels[containerNameLabel] = sandboxContainerName
+	} else if c == nil {
+		handler.aliases = []string{podName, sandbox.uid}
+	} else {
+		for k, v := range c.labels {
+			handler.labels[k] = v
+		}
+		handler.aliases = []string{podName + "/" + c.name, c.id}
+		handler.image = c.image
+		handler.labels[containerNameLabel] = c.name
+	}
+	handler.labels[podNameLabel] = sandbox.name
+	handler.labels[podNamespaceLabel] = sandbox.namespace
+	handler.labels[podUidLabel] = sandbox.uid
+	return handler, nil
+}
+
+func (self *criContainerHandler) ContainerReference() (info.ContainerReference, error) {
+	return info.ContainerReference{
+		Name:      self.name,
+		Aliases:   self.aliases,
+		Namespace: CriNamespace,
+		Labels:    self.labels,
+		Image:     self.image,
+	}, nil
+}
+
+func (self *criContainerHandler) GetSpec() (info.ContainerSpec, error) {
+	spec, err := self.ContainerHandler.GetSpec()
+	if err != nil {
+		return spec, err
+	}
+	spec.Labels = self.labels
+	spec.Image = self.image
+	return spec, nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package docker
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/google/cadvisor/utils"
+)
+
+var ArgDockerEndpoint = flag.String("docker", "unix:///var/run/docker.sock", "docker endpoint")
+var argDockerTls = flag.Bool("docker-tls", false, "use TLS to connect to docker, --docker must then be a tcp:// or https:// endpoint")
+var argDockerCert = flag.String("docker-tls-cert", "", "path to the client certificate presented to docker")
+var argDockerKey = flag.String("docker-tls-key", "", "path to the private key of the client certificate")
+var argDockerCa = flag.String("docker-tls-ca", "", "path to the CA certificates trusted to verify docker, the system's if empty")
+
+// Returns an error if Docker does not seem to be running: the socket of a unix endpoint does not
+// exist. Other endpoints are only checked when connecting to them.
+func Detect() error {
+	if !strings.HasPrefix(*ArgDockerEndpoint, "unix://") {
+		return nil
+	}
+	if _, err := os.Stat(strings.TrimPrefix(*ArgDockerEndpoint, "unix://")); err != nil {
+		return fmt.Errorf("docker does not seem to be running: %v", err)
+	}
+	return nil
+}
+
+// Returns a client of the Docker daemon at --docker, over TLS if --docker-tls is set.
+func NewClient() (*docker.Client, error) {
+	if !*argDockerTls {
+		return docker.NewClient(*ArgDockerEndpoint)
+	}
+	// The Docker client only uses its HTTP client, and thus TLS, for https endpoints.
+	endpoint := *ArgDockerEndpoint
+	if strings.HasPrefix(endpoint, "tcp://") {
+		endpoint = "https://" + strings.TrimPrefix(endpoint, "tcp://")
+	}
+	if !strings.HasPrefix(endpoint, "https://") {
+		return nil, fmt.Errorf("TLS requires a tcp:// or https:// Docker endpoint, not %q", *ArgDockerEndpoint)
+	}
+	config, err := utils.NewTlsConfig(*argDockerCa, *argDockerCert, *argDockerKey, false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Docker TLS configuration: %v", err)
+	}
+	client, err := docker.NewClient(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	client.HTTPClient = &http.Client{
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: config,
+		},
+	}
+	return client, nil
+}
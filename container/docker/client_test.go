@@ -0,0 +1,100 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package docker
+
+import (
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+// Sets the Docker client flags for the duration of a test.
+func setClientFlags(endpoint string, tls bool, ca string) func() {
+	oldEndpoint, oldTls, oldCa := *ArgDockerEndpoint, *argDockerTls, *argDockerCa
+	*ArgDockerEndpoint, *argDockerTls, *argDockerCa = endpoint, tls, ca
+	return func() {
+		*ArgDockerEndpoint, *argDockerTls, *argDockerCa = oldEndpoint, oldTls, oldCa
+	}
+}
+
+func TestNewClientTls(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"Version":"1.6.0"}`)
+	}))
+	defer server.Close()
+
+	caFile, err := ioutil.TempFile("", "ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(caFile.Name())
+	pem.Encode(caFile, &pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	caFile.Close()
+
+	endpoint := "tcp://" + strings.TrimPrefix(server.URL, "https://")
+	defer setClientFlags(endpoint, true, caFile.Name())()
+	client, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	version, err := client.Version()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := version.Get("Version"); v != "1.6.0" {
+		t.Errorf("expected version 1.6.0, got %q", v)
+	}
+}
+
+func TestDetect(t *testing.T) {
+	dir, err := ioutil.TempDir("", "docker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := path.Join(dir, "docker.sock")
+
+	defer setClientFlags("unix://"+socket, false, "")()
+	if err := Detect(); err == nil {
+		t.Error("expected Docker not to be detected without its socket")
+	}
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	if err := Detect(); err != nil {
+		t.Errorf("expected Docker to be detected: %v", err)
+	}
+
+	*ArgDockerEndpoint = "tcp://127.0.0.1:2376"
+	if err := Detect(); err != nil {
+		t.Errorf("expected TCP endpoints not to be probed: %v", err)
+	}
+}
+
+func TestNewClientTlsRequiresTcp(t *testing.T) {
+	defer setClientFlags("unix:///var/run/docker.sock", true, "")()
+	if _, err := NewClient(); err == nil {
+		t.Error("expected TLS over a unix socket to fail")
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package docker
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// The header of the ioctls of device-mapper, struct dm_ioctl of linux/dm-ioctl.h.
+type dmIoctl struct {
+	Version     [3]uint32
+	DataSize    uint32
+	DataStart   uint32
+	TargetCount uint32
+	OpenCount   int32
+	Flags       uint32
+	EventNr     uint32
+	Padding     uint32
+	Dev         uint64
+	Name        [128]byte
+	Uuid        [129]byte
+	Data        [7]byte
+}
+
+// The targets following the header in the results of DM_TABLE_STATUS, struct dm_target_spec.
+type dmTargetSpec struct {
+	SectorStart uint64
+	Length      uint64
+	Status      int32
+	Next        uint32
+	TargetType  [16]byte
+}
+
+const (
+	dmControl = "/dev/mapper/control"
+
+	// _IOWR(0xfd, 12, struct dm_ioctl)
+	dmTableStatus = 0xc138fd0c
+
+	// The results did not fit in the buffer.
+	dmBufferFullFlag = 1 << 8
+
+	dmBufferSize = 16 * 1024
+)
+
+// Returns the status of the device-mapper device like "dmsetup status", without running it:
+// one "<start> <length> <target type> <status>" line per target.
+func dmStatus(name string) (string, error) {
+	if len(name) >= len(dmIoctl{}.Name) {
+		return "", fmt.Errorf("device-mapper device name %q too long", name)
+	}
+	control, err := os.Open(dmControl)
+	if err != nil {
+		return "", err
+	}
+	defer control.Close()
+
+	buf := make([]byte, dmBufferSize)
+	header := (*dmIoctl)(unsafe.Pointer(&buf[0]))
+	header.Version = [3]uint32{4, 0, 0}
+	header.DataSize = uint32(len(buf))
+	header.DataStart = uint32(unsafe.Sizeof(*header))
+	copy(header.Name[:], name)
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, control.Fd(), dmTableStatus, uintptr(unsafe.Pointer(&buf[0])))
+	if errno != 0 {
+		return "", fmt.Errorf("failed to get the status of the device-mapper device %q: %v", name, errno)
+	}
+	if header.Flags&dmBufferFullFlag != 0 {
+		return "", fmt.Errorf("status of the device-mapper device %q too large", name)
+	}
+	return parseDmTargets(buf, header.TargetCount)
+}
+
+// Formats the targets in the results of DM_TABLE_STATUS.
+func parseDmTargets(buf []byte, count uint32) (string, error) {
+	header := (*dmIoctl)(unsafe.Pointer(&buf[0]))
+	specSize := uint32(unsafe.Sizeof(dmTargetSpec{}))
+	status := ""
+	offset := header.DataStart
+	for i := uint32(0); i < count; i++ {
+		if offset+specSize > uint32(len(buf)) {
+			return "", fmt.Errorf("truncated device-mapper status")
+		}
+		spec := (*dmTargetSpec)(unsafe.Pointer(&buf[offset]))
+		params := cString(buf[offset+specSize:])
+		status += fmt.Sprintf("%d %d %s %s\n", spec.SectorStart, spec.Length, cString(spec.TargetType[:]), params)
+		// The offset of the next target is relative to the data.
+		offset = header.DataStart + spec.Next
+	}
+	return status, nil
+}
+
+// Returns the NUL-terminated string at the start of b.
+func cString(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+	return string(b)
+}
@@ -12,6 +12,9 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
+//go:build linux
+// +build linux
+
 package docker
 
 import (
@@ -32,10 +35,9 @@ import (
 	"github.com/google/cadvisor/utils"
 )
 
-var ArgDockerEndpoint = flag.String("docker", "unix:///var/run/docker.sock", "docker endpoint")
+var argEnvWhitelist = flag.String("docker_env_metadata_whitelist", "", "Comma-separated list of prefixes of the environment variables of Docker containers exported as metadata of the containers. None if empty")
 
-// The namespace under which Docker aliases are unique.
-var DockerNamespace = "docker"
+var argDockerEvents = flag.Bool("docker_events", true, "Discover Docker containers from the Docker events as they start and die, rather than only from their cgroups")
 
 // Basepath to all container specific information that libcontainer stores.
 var dockerRootDir = flag.String("docker_root", "/var/lib/docker", "Absolute path to the Docker state root directory (default: /var/lib/docker)")
@@ -65,13 +67,20 @@ func UseSystemd() bool {
 type dockerFactory struct {
 	machineInfoFactory info.MachineInfoFactory
 
-	// Whether docker is running with AUFS storage driver.
-	usesAufsDriver bool
+	// Storage driver of the Docker daemon.
+	storage *storageDriver
 
 	client *docker.Client
 
 	// Information about the mounted cgroup subsystems.
 	cgroupSubsystems libcontainer.CgroupSubsystems
+
+	// Docker daemons running inside Docker containers, nil if --docker_nested is not set.
+	nested *nestedDaemons
+
+	// Events of the Docker daemon, nil if --docker_events is not set.
+	events     chan *docker.APIEvents
+	stopEvents chan bool
 }
 
 func (self *dockerFactory) String() string {
@@ -79,7 +88,28 @@ func (self *dockerFactory) String() string {
 }
 
 func (self *dockerFactory) NewContainerHandler(name string) (handler container.ContainerHandler, err error) {
-	client, err := docker.NewClient(*ArgDockerEndpoint)
+	if self.nested != nil {
+		if outerName, _, ok := splitNestedDockerName(name); ok {
+			daemon, err := self.nested.get(outerName)
+			if err != nil {
+				return nil, err
+			}
+			if daemon == nil {
+				return nil, fmt.Errorf("no Docker daemon found in container %q", outerName)
+			}
+			return newDockerContainerHandler(
+				daemon.client,
+				name,
+				self.machineInfoFactory,
+				daemon.rootDir,
+				daemon.storage,
+				&self.cgroupSubsystems,
+				self.nested,
+			)
+		}
+	}
+
+	client, err := NewClient()
 	if err != nil {
 		return
 	}
@@ -88,42 +118,13 @@ func (self *dockerFactory) NewContainerHandler(name string) (handler container.C
 		name,
 		self.machineInfoFactory,
 		*dockerRootDir,
-		self.usesAufsDriver,
+		self.storage,
 		&self.cgroupSubsystems,
+		self.nested,
 	)
 	return
 }
 
-// Returns whether the specified full container name corresponds to a Docker container.
-func IsDockerContainerName(name string) bool {
-	if useSystemd {
-		// In systemd systems the containers are: /system.slice/docker-{ID}
-		return strings.HasPrefix(name, "/system.slice/docker-")
-	} else {
-		return strings.HasPrefix(name, "/docker/")
-	}
-}
-
-// Returns the Docker ID from the full container name.
-func ContainerNameToDockerId(name string) string {
-	id := path.Base(name)
-
-	// Turn systemd cgroup name into Docker ID.
-	if useSystemd {
-		const systemdDockerPrefix = "docker-"
-		if strings.HasPrefix(id, systemdDockerPrefix) {
-			id = id[len(systemdDockerPrefix):]
-		}
-
-		const systemdScopeSuffix = ".scope"
-		if strings.HasSuffix(id, systemdScopeSuffix) {
-			id = id[:len(id)-len(systemdScopeSuffix)]
-		}
-	}
-
-	return id
-}
-
 // Returns a full container name for the specified Docker ID.
 func FullContainerName(dockerId string) string {
 	// Add the full container name.
@@ -136,20 +137,93 @@ func FullContainerName(dockerId string) string {
 
 // Docker handles all containers under /docker
 func (self *dockerFactory) CanHandle(name string) (bool, error) {
-	if !IsDockerContainerName(name) {
-		return false, nil
-	}
+	return IsDockerContainerName(name), nil
+}
 
-	// Check if the container is known to docker and it is active.
+// Only the containers known to Docker and running are monitored, others are left out rather than
+// handled as raw containers. Containers that start later are reported by the Docker events, or
+// detected again.
+func (self *dockerFactory) CanAccept(name string) (bool, error) {
 	id := ContainerNameToDockerId(name)
+	client := self.client
+
+	// Containers of nested Docker daemons are known to the daemon of their outer container.
+	if self.nested != nil {
+		if outerName, nestedId, ok := splitNestedDockerName(name); ok {
+			daemon, err := self.nested.get(outerName)
+			if err != nil || daemon == nil {
+				return false, fmt.Errorf("no Docker daemon found in container %q: %v", outerName, err)
+			}
+			client = daemon.client
+			id = nestedId
+		}
+	}
 
-	// We assume that if Inspect fails then the container is not known to docker.
-	ctnr, err := self.client.InspectContainer(id)
-	if err != nil || !ctnr.State.Running {
+	ctnr, err := client.InspectContainer(id)
+	if _, ok := err.(*docker.NoSuchContainer); ok {
+		return false, nil
+	}
+	if err != nil {
 		return false, fmt.Errorf("error inspecting container: %v", err)
 	}
+	return ctnr.State.Running, nil
+}
+
+// Returns the event of the container corresponding to the Docker event, ok is false if there is none.
+func containerEvent(event *docker.APIEvents) (e container.SubcontainerEvent, ok bool) {
+	switch event.Status {
+	case "start":
+		e.EventType = container.SubcontainerAdd
+	case "die", "destroy":
+		e.EventType = container.SubcontainerDelete
+	default:
+		return e, false
+	}
+	e.Name = FullContainerName(event.ID)
+	return e, true
+}
+
+// Reports the containers of the Docker daemon as they start and die.
+func (self *dockerFactory) WatchContainers(events chan container.SubcontainerEvent) error {
+	if self.events == nil {
+		return nil
+	}
+	if err := self.client.AddEventListener(self.events); err != nil {
+		return err
+	}
+	go func() {
+		for {
+			select {
+			case event := <-self.events:
+				if e, ok := containerEvent(event); ok {
+					glog.V(2).Infof("Docker container %q: %s", event.ID, event.Status)
+					if e.EventType == container.SubcontainerDelete && self.nested != nil {
+						// The daemon of the container is gone with it, its containers too.
+						self.nested.forget(e.Name)
+					}
+					select {
+					case events <- e:
+					case <-self.stopEvents:
+						return
+					}
+				}
+			case <-self.stopEvents:
+				return
+			}
+		}
+	}()
+	return nil
+}
 
-	return true, nil
+func (self *dockerFactory) StopWatchingContainers() error {
+	if self.events == nil {
+		return nil
+	}
+	if err := self.client.RemoveEventListener(self.events); err != nil {
+		return err
+	}
+	self.stopEvents <- true
+	return nil
 }
 
 func parseDockerVersion(full_version_string string) ([]int, error) {
@@ -173,7 +247,7 @@ func parseDockerVersion(full_version_string string) ([]int, error) {
 
 // Register root container before running this function!
 func Register(factory info.MachineInfoFactory) error {
-	client, err := docker.NewClient(*ArgDockerEndpoint)
+	client, err := NewClient()
 	if err != nil {
 		return fmt.Errorf("unable to communicate with docker daemon: %v", err)
 	}
@@ -211,14 +285,6 @@ func Register(factory info.MachineInfoFactory) error {
 		return fmt.Errorf("Docker found, but not using native exec driver")
 	}
 
-	usesAufsDriver := false
-	for _, val := range *information {
-		if strings.Contains(val, "Driver=") && strings.Contains(val, "aufs") {
-			usesAufsDriver = true
-			break
-		}
-	}
-
 	if useSystemd {
 		glog.Infof("System is using systemd")
 	}
@@ -232,9 +298,19 @@ func Register(factory info.MachineInfoFactory) error {
 	f := &dockerFactory{
 		machineInfoFactory: factory,
 		client:             client,
-		usesAufsDriver:     usesAufsDriver,
+		storage:            newStorageDriver(information, *dockerRootDir),
 		cgroupSubsystems:   cgroupSubsystems,
 	}
-	container.RegisterContainerHandlerFactory(f)
+	if *argDockerNested {
+		f.nested = newNestedDaemons(client)
+	}
+	// The Docker client streams events over plain connections only.
+	if *argDockerEvents && *argDockerTls {
+		glog.Warningf("Docker events are not available over TLS, Docker containers are only found through their cgroups")
+	} else if *argDockerEvents {
+		f.events = make(chan *docker.APIEvents, 16)
+		f.stopEvents = make(chan bool)
+	}
+	container.RegisterContainerHandlerFactory(f, container.PriorityRuntime)
 	return nil
 }
@@ -0,0 +1,189 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package docker
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/google/cadvisor/container"
+)
+
+func TestDockerContainerNames(t *testing.T) {
+	cases := []struct {
+		name   string
+		docker bool
+		id     string
+	}{
+		{"/docker/abc123", true, "abc123"},
+		{"/system.slice/docker-abc123.scope", true, "abc123"},
+		{"/machine.slice/docker-abc123.scope", true, "abc123"},
+		{"/system.slice/docker.service", false, "docker.service"},
+		{"/system.slice/sshd.service", false, "sshd.service"},
+		{"/docker", false, "docker"},
+	}
+	for _, c := range cases {
+		if IsDockerContainerName(c.name) != c.docker {
+			t.Errorf("expected %q to be a Docker container: %v", c.name, c.docker)
+		}
+		if id := ContainerNameToDockerId(c.name); id != c.id {
+			t.Errorf("expected ID %q for %q, got %q", c.id, c.name, id)
+		}
+	}
+}
+
+func TestWhitelistedEnvs(t *testing.T) {
+	env := []string{"APP_NAME=web", "APP_TIER=frontend", "PATH=/usr/bin", "EMPTY=", "MALFORMED"}
+	envs := whitelistedEnvs(env, "APP_, EMPTY")
+	expected := map[string]string{"APP_NAME": "web", "APP_TIER": "frontend", "EMPTY": ""}
+	if !reflect.DeepEqual(envs, expected) {
+		t.Errorf("unexpected envs %v, expected %v", envs, expected)
+	}
+	if envs := whitelistedEnvs(env, ""); envs != nil {
+		t.Errorf("expected no envs without a whitelist, got %v", envs)
+	}
+}
+
+// Serves a running and a stopped container, and the events of a container starting and dying.
+func newFakeDockerServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/events":
+			now := time.Now().Unix()
+			for _, status := range []string{"create", "start", "die"} {
+				fmt.Fprintf(w, `{"status":%q,"id":"abc123","from":"busybox","time":%d}`, status, now)
+				w.(http.Flusher).Flush()
+			}
+		case strings.HasPrefix(r.URL.Path, "/containers/running/"):
+			fmt.Fprint(w, `{"Id":"running","State":{"Running":true}}`)
+		case strings.HasPrefix(r.URL.Path, "/containers/stopped/"):
+			fmt.Fprint(w, `{"Id":"stopped","State":{"Running":false}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestCanAccept(t *testing.T) {
+	server := newFakeDockerServer()
+	defer server.Close()
+	client, err := docker.NewClient(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	factory := &dockerFactory{client: client}
+
+	cases := map[string]bool{
+		"/docker/running": true,
+		"/docker/stopped": false,
+		"/docker/missing": false,
+	}
+	for name, expected := range cases {
+		accept, err := factory.CanAccept(name)
+		if err != nil {
+			t.Errorf("CanAccept(%q) failed: %v", name, err)
+		} else if accept != expected {
+			t.Errorf("CanAccept(%q) = %v, expected %v", name, accept, expected)
+		}
+	}
+}
+
+func TestWatchContainers(t *testing.T) {
+	server := newFakeDockerServer()
+	defer server.Close()
+	client, err := docker.NewClient(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	factory := &dockerFactory{
+		client:     client,
+		events:     make(chan *docker.APIEvents, 16),
+		stopEvents: make(chan bool),
+		nested:     newNestedDaemons(client),
+	}
+	factory.nested.daemons[FullContainerName("abc123")] = &nestedDaemon{}
+
+	events := make(chan container.SubcontainerEvent, 16)
+	if err := factory.WatchContainers(events); err != nil {
+		t.Fatal(err)
+	}
+	// The creation of the container is not reported, it does not run yet. Docker events may be
+	// delivered out of order.
+	expected := map[container.SubcontainerEvent]bool{
+		{EventType: container.SubcontainerAdd, Name: FullContainerName("abc123")}:    true,
+		{EventType: container.SubcontainerDelete, Name: FullContainerName("abc123")}: true,
+	}
+	for len(expected) > 0 {
+		select {
+		case event := <-events:
+			if !expected[event] {
+				t.Errorf("unexpected event %+v", event)
+			}
+			delete(expected, event)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for events %+v", expected)
+		}
+	}
+	if err := factory.StopWatchingContainers(); err != nil {
+		t.Fatal(err)
+	}
+	// The nested daemon of the container that died is forgotten.
+	factory.nested.lock.Lock()
+	defer factory.nested.lock.Unlock()
+	if _, ok := factory.nested.daemons[FullContainerName("abc123")]; ok {
+		t.Error("expected the nested daemon of the dead container to be forgotten")
+	}
+}
+
+func TestStopWatchingBlockedContainers(t *testing.T) {
+	server := newFakeDockerServer()
+	defer server.Close()
+	client, err := docker.NewClient(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	factory := &dockerFactory{
+		client:     client,
+		events:     make(chan *docker.APIEvents, 16),
+		stopEvents: make(chan bool),
+	}
+
+	// Nothing reads the events, the watcher blocks reporting the first one.
+	if err := factory.WatchContainers(make(chan container.SubcontainerEvent)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	stopped := make(chan error)
+	go func() {
+		stopped <- factory.StopWatchingContainers()
+	}()
+	select {
+	case err := <-stopped:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out stopping the watcher blocked on an event")
+	}
+}
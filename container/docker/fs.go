@@ -0,0 +1,311 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package docker
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/golang/glog"
+	"github.com/google/cadvisor/fs"
+	"github.com/google/cadvisor/info"
+)
+
+var argDiskUsageInterval = flag.Duration("docker_disk_usage_interval", time.Minute, "Interval between measurements of the disk usage of the writable layer and logs of Docker containers")
+var argVolumeUsage = flag.Bool("docker_volume_usage", true, "Whether to also measure the disk usage of the volumes and bind mounts of Docker containers, every --docker_disk_usage_interval")
+
+// The storage drivers of Docker whose writable layers are measured.
+const (
+	aufsDriver         = "aufs"
+	overlayDriver      = "overlay"
+	overlay2Driver     = "overlay2"
+	btrfsDriver        = "btrfs"
+	vfsDriver          = "vfs"
+	zfsDriver          = "zfs"
+	devicemapperDriver = "devicemapper"
+)
+
+// f_type of the ZFS filesystems.
+const zfsSuperMagic = 0x2fc12fc1
+
+// The storage driver of a Docker daemon.
+type storageDriver struct {
+	name string
+
+	// Docker state root directory.
+	rootDir string
+
+	// Status reported by the driver (e.g.: "Pool Name" of devicemapper, "Parent Dataset" of zfs).
+	status map[string]string
+}
+
+func newStorageDriver(information *docker.Env, rootDir string) *storageDriver {
+	driver := &storageDriver{
+		name:    information.Get("Driver"),
+		rootDir: rootDir,
+		status:  make(map[string]string),
+	}
+	var status [][2]string
+	if err := information.GetJSON("DriverStatus", &status); err == nil {
+		for _, kv := range status {
+			driver.status[kv[0]] = kv[1]
+		}
+	}
+	return driver
+}
+
+// Returns whether the usage of the writable layers of the driver can be measured.
+func (self *storageDriver) supported() bool {
+	switch self.name {
+	case aufsDriver, overlayDriver, overlay2Driver, btrfsDriver, vfsDriver, zfsDriver, devicemapperDriver:
+		return true
+	}
+	return false
+}
+
+// Returns the ID of the writable layer of the container. Since Docker 1.10 layers are not named
+// after their container, the ID is in the mount-id file of the container's layer.
+func (self *storageDriver) layerId(containerId string) string {
+	mountId, err := ioutil.ReadFile(path.Join(self.rootDir, "image", self.name, "layerdb/mounts", containerId, "mount-id"))
+	if err != nil {
+		return containerId
+	}
+	return strings.TrimSpace(string(mountId))
+}
+
+// Returns the directory holding the writable layer, empty for drivers not storing it in a
+// directory.
+func (self *storageDriver) layerDir(layerId string) string {
+	switch self.name {
+	case aufsDriver:
+		return path.Join(self.rootDir, pathToAufsDir, layerId)
+	case overlayDriver:
+		return path.Join(self.rootDir, "overlay", layerId, "upper")
+	case overlay2Driver:
+		return path.Join(self.rootDir, "overlay2", layerId, "diff")
+	case btrfsDriver:
+		return path.Join(self.rootDir, "btrfs/subvolumes", layerId)
+	case vfsDriver:
+		return path.Join(self.rootDir, "vfs/dir", layerId)
+	}
+	return ""
+}
+
+// Measures the usage of the writable layer of the container, and the size of its log file.
+func (self *storageDriver) measure(fsInfo fs.FsInfo, mi *info.MachineInfo, containerId string) ([]info.FsStats, error) {
+	layerId := self.layerId(containerId)
+	var layer info.FsStats
+	var err error
+	switch self.name {
+	case zfsDriver:
+		layer, err = zfsUsage(path.Join(self.status["Parent Dataset"], layerId), path.Join(self.rootDir, "zfs/graph", layerId))
+	case devicemapperDriver:
+		// Thin devices are named after their pool: docker-<major>:<minor>-<inode>-<layer ID>.
+		pool := self.status["Pool Name"]
+		if !strings.HasSuffix(pool, "-pool") {
+			return nil, fmt.Errorf("unknown thin pool %q", pool)
+		}
+		layer, err = thinDeviceUsage(strings.TrimSuffix(pool, "pool") + layerId)
+	default:
+		layer, err = dirUsage(fsInfo, mi, self.layerDir(layerId))
+	}
+	if err != nil {
+		return nil, err
+	}
+	stats := []info.FsStats{layer}
+
+	// Logs of the json-file log driver.
+	logFile := path.Join(self.rootDir, pathToContainersDir, containerId, containerId+"-json.log")
+	if fi, err := os.Stat(logFile); err == nil {
+		device, err := fsInfo.GetDirFsDevice(path.Dir(logFile))
+		if err != nil {
+			return nil, err
+		}
+		if device.Device == layer.Device {
+			stats[0].Usage += uint64(fi.Size())
+		} else {
+			stats = append(stats, info.FsStats{
+				Device: device.Device,
+				Limit:  deviceCapacity(mi, device.Device),
+				Usage:  uint64(fi.Size()),
+			})
+		}
+	}
+	return stats, nil
+}
+
+// Measures the usage of the volumes of a container, by their path in the container and on the host.
+func volumeUsage(fsInfo fs.FsInfo, mi *info.MachineInfo, volumes map[string]string) ([]info.VolumeStats, error) {
+	stats := make([]info.VolumeStats, 0, len(volumes))
+	for mountPoint, source := range volumes {
+		usage, err := dirUsage(fsInfo, mi, source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to measure the volume %q of %q: %v", mountPoint, source, err)
+		}
+		stats = append(stats, info.VolumeStats{
+			MountPoint: mountPoint,
+			Source:     source,
+			Device:     usage.Device,
+			Limit:      usage.Limit,
+			Usage:      usage.Usage,
+		})
+	}
+	sort.Sort(byMountPoint(stats))
+	return stats, nil
+}
+
+type byMountPoint []info.VolumeStats
+
+func (s byMountPoint) Len() int           { return len(s) }
+func (s byMountPoint) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byMountPoint) Less(i, j int) bool { return s[i].MountPoint < s[j].MountPoint }
+
+// Docker does not impose any filesystem limits for containers. So use capacity as limit.
+func deviceCapacity(mi *info.MachineInfo, device string) uint64 {
+	for _, fs := range mi.Filesystems {
+		if fs.Device == device {
+			return fs.Capacity
+		}
+	}
+	return 0
+}
+
+func dirUsage(fsInfo fs.FsInfo, mi *info.MachineInfo, dir string) (info.FsStats, error) {
+	device, err := fsInfo.GetDirFsDevice(dir)
+	if err != nil {
+		return info.FsStats{}, err
+	}
+	usage, err := fsInfo.GetDirUsage(dir)
+	if err != nil {
+		return info.FsStats{}, err
+	}
+	return info.FsStats{
+		Device: device.Device,
+		Limit:  deviceCapacity(mi, device.Device),
+		Usage:  usage,
+	}, nil
+}
+
+// Measures the usage of the ZFS dataset of a container from the filesystem mounted at mountPoint
+// while the container runs, without running "zfs get".
+func zfsUsage(dataset string, mountPoint string) (info.FsStats, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(mountPoint, &st); err != nil {
+		return info.FsStats{}, fmt.Errorf("statfs failed on %s - %s", mountPoint, err)
+	}
+	if st.Type != zfsSuperMagic {
+		return info.FsStats{}, fmt.Errorf("the dataset %s is not mounted at %s", dataset, mountPoint)
+	}
+	usage, limit := statfsUsage(&st)
+	return info.FsStats{Device: dataset, Limit: limit, Usage: usage}, nil
+}
+
+// Returns the used and total bytes of a filesystem. The blocks of ZFS filesystems are counted in
+// units of their fragment size, the used ones are those referenced by the dataset.
+func statfsUsage(st *syscall.Statfs_t) (usage, limit uint64) {
+	size := uint64(st.Frsize)
+	if size == 0 {
+		size = uint64(st.Bsize)
+	}
+	return (st.Blocks - st.Bfree) * size, st.Blocks * size
+}
+
+func thinDeviceUsage(device string) (info.FsStats, error) {
+	out, err := dmStatus(device)
+	if err != nil {
+		return info.FsStats{}, err
+	}
+	usage, limit, err := parseThinStatus(out)
+	if err != nil {
+		return info.FsStats{}, err
+	}
+	return info.FsStats{Device: path.Join("/dev/mapper", device), Limit: limit, Usage: usage}, nil
+}
+
+// Parses the status of a thin device from the thin-pool metadata, e.g.: "0 20971520 thin 1024
+// 20971519": the device is 20971520 sectors long and 1024 sectors are mapped.
+func parseThinStatus(out string) (usage, limit uint64, err error) {
+	fields := strings.Fields(out)
+	if len(fields) < 4 || fields[2] != "thin" {
+		return 0, 0, fmt.Errorf("cannot parse the status of the thin device %q", out)
+	}
+	length, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("cannot parse the status of the thin device %q - %s", out, err)
+	}
+	mapped, err := strconv.ParseUint(fields[3], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("cannot parse the status of the thin device %q - %s", out, err)
+	}
+	return mapped * 512, length * 512, nil
+}
+
+// Measures the disk usage of a container in the background, as walking its writable layer may
+// take long. The latest measurement is reported with the stats of the container.
+type diskUsageTracker struct {
+	lock    sync.Mutex
+	stats   []info.FsStats
+	volumes []info.VolumeStats
+}
+
+// Measures the usage every --docker_disk_usage_interval while the container exists.
+func (self *diskUsageTracker) start(handler *dockerContainerHandler) {
+	go func() {
+		for handler.Exists() {
+			mi, err := handler.machineInfoFactory.GetMachineInfo()
+			if err == nil {
+				var stats []info.FsStats
+				start := time.Now()
+				stats, err = handler.storage.measure(handler.fsInfo, mi, handler.id)
+				var volumes []info.VolumeStats
+				if err == nil && *argVolumeUsage {
+					volumes, err = volumeUsage(handler.fsInfo, mi, handler.volumes)
+				}
+				if err == nil {
+					glog.V(4).Infof("Measured the disk usage of %q in %v", handler.name, time.Since(start))
+					self.lock.Lock()
+					self.stats = stats
+					self.volumes = volumes
+					self.lock.Unlock()
+				}
+			}
+			if err != nil {
+				glog.V(2).Infof("Failed to measure the disk usage of %q: %v", handler.name, err)
+			}
+			time.Sleep(*argDiskUsageInterval)
+		}
+	}()
+}
+
+// Returns the latest measurement of the writable layer and logs and of the volumes, nil if there is
+// none yet.
+func (self *diskUsageTracker) latest() ([]info.FsStats, []info.VolumeStats) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.stats, self.volumes
+}
@@ -0,0 +1,184 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package docker
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+	"unsafe"
+
+	"github.com/google/cadvisor/fs"
+	"github.com/google/cadvisor/info"
+)
+
+func TestParseThinStatus(t *testing.T) {
+	usage, limit, err := parseThinStatus("0 20971520 thin 1024 20971519\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage != 1024*512 || limit != 20971520*512 {
+		t.Errorf("unexpected usage %d and limit %d", usage, limit)
+	}
+	if _, _, err := parseThinStatus("0 20971520 linear 8:1 0"); err == nil {
+		t.Errorf("expected an error for a device that is not thin")
+	}
+}
+
+func TestZfsUsage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "docker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if _, err := zfsUsage("tank/docker/abc123", dir); err == nil {
+		t.Errorf("expected an error for a dataset that is not mounted")
+	}
+	if _, err := zfsUsage("tank/docker/abc123", filepath.Join(dir, "missing")); err == nil {
+		t.Errorf("expected an error for a missing mount point")
+	}
+}
+
+func TestStatfsUsage(t *testing.T) {
+	usage, limit := statfsUsage(&syscall.Statfs_t{Bsize: 131072, Frsize: 512, Blocks: 1000, Bfree: 600})
+	if usage != 400*512 || limit != 1000*512 {
+		t.Errorf("unexpected usage %d and limit %d", usage, limit)
+	}
+}
+
+func TestParseDmTargets(t *testing.T) {
+	buf := make([]byte, dmBufferSize)
+	header := (*dmIoctl)(unsafe.Pointer(&buf[0]))
+	header.DataStart = uint32(unsafe.Sizeof(*header))
+	spec := (*dmTargetSpec)(unsafe.Pointer(&buf[header.DataStart]))
+	spec.Length = 20971520
+	copy(spec.TargetType[:], "thin")
+	copy(buf[header.DataStart+uint32(unsafe.Sizeof(*spec)):], "1024 20971519\x00")
+
+	status, err := parseDmTargets(buf, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != "0 20971520 thin 1024 20971519\n" {
+		t.Errorf("unexpected status %q", status)
+	}
+	if _, _, err := parseThinStatus(status); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := parseDmTargets(buf[:header.DataStart+8], 1); err == nil {
+		t.Errorf("expected an error for a truncated status")
+	}
+}
+
+func TestLayerDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "docker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Layers of newer Docker versions are named after their content.
+	mounts := filepath.Join(dir, "image/overlay2/layerdb/mounts/abc123")
+	if err := os.MkdirAll(mounts, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(mounts, "mount-id"), []byte("def456"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	driver := &storageDriver{name: overlay2Driver, rootDir: dir}
+	if layerDir := driver.layerDir(driver.layerId("abc123")); layerDir != filepath.Join(dir, "overlay2/def456/diff") {
+		t.Errorf("unexpected overlay2 layer directory %q", layerDir)
+	}
+
+	// Layers of older ones after their container.
+	driver = &storageDriver{name: aufsDriver, rootDir: dir}
+	if layerDir := driver.layerDir(driver.layerId("abc123")); layerDir != filepath.Join(dir, "aufs/diff/abc123") {
+		t.Errorf("unexpected aufs layer directory %q", layerDir)
+	}
+
+	driver = &storageDriver{name: devicemapperDriver, rootDir: dir}
+	if layerDir := driver.layerDir("abc123"); layerDir != "" {
+		t.Errorf("expected no layer directory for devicemapper, got %q", layerDir)
+	}
+}
+
+type fakeFsInfo struct {
+	fs.FsInfo
+	usage map[string]uint64
+}
+
+func (self *fakeFsInfo) GetDirUsage(dir string) (uint64, error) {
+	return self.usage[dir], nil
+}
+
+func (self *fakeFsInfo) GetDirFsDevice(dir string) (*fs.DeviceInfo, error) {
+	return &fs.DeviceInfo{Device: "/dev/sda1"}, nil
+}
+
+func TestMeasure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "docker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	logDir := filepath.Join(dir, "containers/abc123")
+	if err := os.MkdirAll(logDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(logDir, "abc123-json.log"), make([]byte, 100), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	driver := &storageDriver{name: vfsDriver, rootDir: dir}
+	fsInfo := &fakeFsInfo{usage: map[string]uint64{filepath.Join(dir, "vfs/dir/abc123"): 4096}}
+	mi := &info.MachineInfo{Filesystems: []info.FsInfo{{Device: "/dev/sda1", Capacity: 1 << 30}}}
+	stats, err := driver.measure(fsInfo, mi, "abc123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []info.FsStats{{Device: "/dev/sda1", Limit: 1 << 30, Usage: 4096 + 100}}
+	if !reflect.DeepEqual(stats, expected) {
+		t.Errorf("unexpected stats %+v, expected %+v", stats, expected)
+	}
+}
+
+func TestVolumeUsage(t *testing.T) {
+	fsInfo := &fakeFsInfo{usage: map[string]uint64{
+		"/var/lib/docker/volumes/0123/_data": 8192,
+		"/srv/logs":                          1024,
+	}}
+	mi := &info.MachineInfo{Filesystems: []info.FsInfo{{Device: "/dev/sda1", Capacity: 1 << 30}}}
+	stats, err := volumeUsage(fsInfo, mi, map[string]string{
+		"/var/lib/mysql": "/var/lib/docker/volumes/0123/_data",
+		"/logs":          "/srv/logs",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []info.VolumeStats{
+		{MountPoint: "/logs", Source: "/srv/logs", Device: "/dev/sda1", Limit: 1 << 30, Usage: 1024},
+		{MountPoint: "/var/lib/mysql", Source: "/var/lib/docker/volumes/0123/_data", Device: "/dev/sda1", Limit: 1 << 30, Usage: 8192},
+	}
+	if !reflect.DeepEqual(stats, expected) {
+		t.Errorf("unexpected stats %+v, expected %+v", stats, expected)
+	}
+}
@@ -12,6 +12,9 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
+//go:build linux
+// +build linux
+
 // Handler for Docker containers.
 package docker
 
@@ -37,6 +40,9 @@ import (
 // Relative path from Docker root to the libcontainer per-container state.
 const pathToLibcontainerState = "execdriver/native"
 
+// Path to the directory where Docker keeps the configuration of each container.
+const pathToContainersDir = "containers"
+
 // Path to aufs dir where all the files exist.
 // aufs/layers is ignored here since it does not hold a lot of data.
 // aufs/mnt contains the mount points used to compose the rootfs. Hence it is also ignored.
@@ -63,10 +69,76 @@ type dockerContainerHandler struct {
 	// (e.g.: "cpu" -> "/sys/fs/cgroup/cpu/test")
 	cgroupPaths map[string]string
 
-	cgroup         cgroups.Cgroup
-	usesAufsDriver bool
-	fsInfo         fs.FsInfo
-	storageDirs    []string
+	cgroup  cgroups.Cgroup
+	storage *storageDriver
+	fsInfo  fs.FsInfo
+
+	// Disk usage of the writable layer and logs, nil if the storage driver is not supported.
+	diskUsage *diskUsageTracker
+
+	// Metadata labels of the container.
+	labels map[string]string
+
+	// Paths of the volumes on the host, by their path in the container.
+	volumes map[string]string
+
+	// IP address of the container on its Docker network, empty with the host network.
+	ipAddress string
+
+	// Image the container runs.
+	image string
+
+	// Environment variables of the container whitelisted by --docker_env_metadata_whitelist.
+	envs map[string]string
+
+	// Docker daemons running inside Docker containers, nil if they are not monitored.
+	nested *nestedDaemons
+}
+
+// Returns the environment variables ("KEY=value") whose key starts with one of the comma-separated
+// prefixes of the whitelist, nil if there is none.
+func whitelistedEnvs(env []string, whitelist string) map[string]string {
+	var envs map[string]string
+	for _, prefix := range strings.Split(whitelist, ",") {
+		prefix = strings.TrimSpace(prefix)
+		if prefix == "" {
+			continue
+		}
+		for _, kv := range env {
+			parts := strings.SplitN(kv, "=", 2)
+			if len(parts) != 2 || !strings.HasPrefix(parts[0], prefix) {
+				continue
+			}
+			if envs == nil {
+				envs = make(map[string]string)
+			}
+			envs[parts[0]] = parts[1]
+		}
+	}
+	return envs
+}
+
+// Docker's on-disk configuration of a container. Only the fields we use are decoded.
+type dockerContainerConfig struct {
+	Config struct {
+		Labels map[string]string `json:"Labels,omitempty"`
+	} `json:"Config"`
+}
+
+// Reads the configuration Docker persists for the specified container.
+func readDockerContainerConfig(dockerRootDir, id string) (*dockerContainerConfig, error) {
+	configPath := path.Join(dockerRootDir, pathToContainersDir, id, "config.json")
+	f, err := os.Open(configPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	config := new(dockerContainerConfig)
+	err = json.NewDecoder(f).Decode(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %q: %v", configPath, err)
+	}
+	return config, nil
 }
 
 func newDockerContainerHandler(
@@ -74,8 +146,9 @@ func newDockerContainerHandler(
 	name string,
 	machineInfoFactory info.MachineInfoFactory,
 	dockerRootDir string,
-	usesAufsDriver bool,
+	storage *storageDriver,
 	cgroupSubsystems *containerLibcontainer.CgroupSubsystems,
+	nested *nestedDaemons,
 ) (container.ContainerHandler, error) {
 	fsInfo, err := fs.NewFsInfo()
 	if err != nil {
@@ -102,10 +175,10 @@ func newDockerContainerHandler(
 			Parent: "/",
 			Name:   name,
 		},
-		usesAufsDriver: usesAufsDriver,
-		fsInfo:         fsInfo,
+		storage: storage,
+		fsInfo:  fsInfo,
+		nested:  nested,
 	}
-	handler.storageDirs = append(handler.storageDirs, path.Join(dockerRootDir, pathToAufsDir, path.Base(name)))
 
 	// We assume that if Inspect fails then the container is not known to docker.
 	ctnr, err := client.InspectContainer(id)
@@ -117,6 +190,34 @@ func newDockerContainerHandler(
 	handler.aliases = append(handler.aliases, strings.TrimPrefix(ctnr.Name, "/"))
 	handler.aliases = append(handler.aliases, id)
 	handler.aliases = append(handler.aliases, ctnr.Config.Hostname)
+	handler.image = ctnr.Config.Image
+	handler.envs = whitelistedEnvs(ctnr.Config.Env, *argEnvWhitelist)
+	// Volumes and bind mounts, by their path in the container.
+	handler.volumes = ctnr.Volumes
+	if ctnr.NetworkSettings != nil {
+		handler.ipAddress = ctnr.NetworkSettings.IPAddress
+	}
+
+	// Aliases of nested containers are only unique within their outer container.
+	if outerName, _, ok := splitNestedDockerName(name); ok {
+		outerId := ContainerNameToDockerId(outerName)
+		for i := range handler.aliases {
+			handler.aliases[i] = path.Join(outerId, handler.aliases[i])
+		}
+	}
+
+	// Labels are only available in newer versions of Docker.
+	config, err := readDockerContainerConfig(dockerRootDir, id)
+	if err == nil {
+		handler.labels = config.Config.Labels
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if storage.supported() && container.HasMetric(container.DiskUsageMetrics) {
+		handler.diskUsage = &diskUsageTracker{}
+		handler.diskUsage.start(handler)
+	}
 
 	return handler, nil
 }
@@ -126,6 +227,9 @@ func (self *dockerContainerHandler) ContainerReference() (info.ContainerReferenc
 		Name:      self.name,
 		Aliases:   self.aliases,
 		Namespace: DockerNamespace,
+		Labels:    self.labels,
+		Image:     self.image,
+		Envs:      self.envs,
 	}, nil
 }
 
@@ -177,6 +281,12 @@ func (self *dockerContainerHandler) readLibcontainerState() (state *libcontainer
 	if len(state.CgroupPaths) == 0 {
 		state.CgroupPaths = self.cgroupPaths
 	}
+	// Subsystems unknown to the runtime are read where they are mounted (e.g.: hugetlb).
+	for subsystem, cgroupPath := range self.cgroupPaths {
+		if _, ok := state.CgroupPaths[subsystem]; !ok {
+			state.CgroupPaths[subsystem] = cgroupPath
+		}
+	}
 
 	return
 }
@@ -205,8 +315,19 @@ func libcontainerConfigToContainerSpec(config *libcontainer.Config, mi *info.Mac
 	} else {
 		spec.Cpu.Mask = config.Cgroups.CpusetCpus
 	}
+	spec.Cpu.EffectiveCpus = spec.Cpu.Mask
+	spec.Cpu.Mems = config.Cgroups.CpusetMems
+	if config.Cgroups.CpuQuota > 0 {
+		spec.Cpu.Quota = uint64(config.Cgroups.CpuQuota)
+		// The default CFS period of the kernel.
+		spec.Cpu.Period = 100000
+	}
+	if config.Cgroups.CpuPeriod > 0 {
+		spec.Cpu.Period = uint64(config.Cgroups.CpuPeriod)
+	}
 
-	spec.HasNetwork = true
+	spec.HasNetwork = container.HasMetric(container.NetworkUsageMetrics)
+	spec.HasDiskIo = container.HasMetric(container.DiskIoMetrics)
 	return spec
 }
 
@@ -221,54 +342,39 @@ func (self *dockerContainerHandler) GetSpec() (spec info.ContainerSpec, err erro
 	}
 
 	spec = libcontainerConfigToContainerSpec(libcontainerConfig, mi)
+	if pidsPath, ok := self.cgroupPaths["pids"]; ok && utils.FileExists(pidsPath) {
+		spec.HasProcesses = true
+		spec.Processes.Limit, err = containerLibcontainer.ReadPidsLimit(pidsPath)
+		if err != nil {
+			return
+		}
+	}
+	// The cpuset the container is in, which may have changed since it was created.
+	if cpusetPath, ok := self.cgroupPaths["cpuset"]; ok {
+		cpu := spec.Cpu
+		if err := containerLibcontainer.ReadCpuset(cpusetPath, &cpu); err == nil && cpu.Mask != "" {
+			spec.Cpu = cpu
+		}
+	}
+	spec.Labels = self.labels
+	spec.Image = self.image
+	spec.Envs = self.envs
 
-	if self.usesAufsDriver {
+	if self.diskUsage != nil {
 		spec.HasFilesystem = true
 	}
 
 	return
 }
 
+// Reports the latest measurement of the disk usage, measuring it takes too long to be done with
+// each stats.
 func (self *dockerContainerHandler) getFsStats(stats *info.ContainerStats) error {
-	// No support for non-aufs storage drivers.
-	if !self.usesAufsDriver {
-		return nil
+	if self.diskUsage != nil {
+		filesystems, volumes := self.diskUsage.latest()
+		stats.Filesystem = append(stats.Filesystem, filesystems...)
+		stats.Volumes = volumes
 	}
-
-	// As of now we assume that all the storage dirs are on the same device.
-	// The first storage dir will be that of the image layers.
-	deviceInfo, err := self.fsInfo.GetDirFsDevice(self.storageDirs[0])
-	if err != nil {
-		return err
-	}
-
-	mi, err := self.machineInfoFactory.GetMachineInfo()
-	if err != nil {
-		return err
-	}
-	var limit uint64 = 0
-	// Docker does not impose any filesystem limits for containers. So use capacity as limit.
-	for _, fs := range mi.Filesystems {
-		if fs.Device == deviceInfo.Device {
-			limit = fs.Capacity
-			break
-		}
-	}
-
-	fsStat := info.FsStats{Device: deviceInfo.Device, Limit: limit}
-
-	var usage uint64 = 0
-	for _, dir := range self.storageDirs {
-		// TODO(Vishh): Add support for external mounts.
-		dirUsage, err := self.fsInfo.GetDirUsage(dir)
-		if err != nil {
-			return err
-		}
-		usage += dirUsage
-	}
-	fsStat.Usage = usage
-	stats.Filesystem = append(stats.Filesystem, fsStat)
-
 	return nil
 }
 
@@ -278,7 +384,7 @@ func (self *dockerContainerHandler) GetStats() (stats *info.ContainerStats, err
 		return
 	}
 
-	stats, err = containerLibcontainer.GetStats(state)
+	stats, err = containerLibcontainer.GetStats(self.name, state)
 	if err != nil {
 		return
 	}
@@ -292,7 +398,7 @@ func (self *dockerContainerHandler) GetStats() (stats *info.ContainerStats, err
 
 func (self *dockerContainerHandler) ListContainers(listType container.ListType) ([]info.ContainerReference, error) {
 	if self.name != "/docker" {
-		return []info.ContainerReference{}, nil
+		return self.listNestedContainers()
 	}
 	opt := docker.ListContainersOptions{
 		All: true,
@@ -319,14 +425,73 @@ func (self *dockerContainerHandler) ListContainers(listType container.ListType)
 	return ret, nil
 }
 
+// Lists the containers of the Docker daemon running inside this container, if any.
+func (self *dockerContainerHandler) listNestedContainers() ([]info.ContainerReference, error) {
+	if self.nested == nil {
+		return []info.ContainerReference{}, nil
+	}
+	if _, _, ok := splitNestedDockerName(self.name); ok {
+		// Only one level of nesting is supported.
+		return []info.ContainerReference{}, nil
+	}
+	daemon, err := self.nested.get(self.name)
+	if err != nil || daemon == nil {
+		return []info.ContainerReference{}, err
+	}
+	containers, err := daemon.client.ListContainers(docker.ListContainersOptions{})
+	if err != nil {
+		// The daemon may have been restarted, look for it again next time.
+		self.nested.forget(self.name)
+		return nil, err
+	}
+
+	ret := make([]info.ContainerReference, 0, len(containers))
+	for _, c := range containers {
+		aliases := make([]string, 0, len(c.Names)+1)
+		for _, name := range append(c.Names, c.ID) {
+			aliases = append(aliases, path.Join(self.id, name))
+		}
+		ret = append(ret, info.ContainerReference{
+			Name:      path.Join(self.name, nestedDockerCgroup, c.ID),
+			Aliases:   aliases,
+			Namespace: DockerNamespace,
+		})
+	}
+	return ret, nil
+}
+
 func (self *dockerContainerHandler) ListThreads(listType container.ListType) ([]int, error) {
-	return nil, nil
+	state, err := self.readLibcontainerState()
+	if err != nil {
+		return nil, err
+	}
+	cpuRoot, ok := state.CgroupPaths["cpu"]
+	if !ok {
+		return nil, nil
+	}
+	return containerLibcontainer.GetThreads(cpuRoot)
 }
 
 func (self *dockerContainerHandler) ListProcesses(listType container.ListType) ([]int, error) {
 	return cgroup_fs.GetPids(&self.cgroup)
 }
 
+func (self *dockerContainerHandler) GetCgroupPath(subsystem string) (string, error) {
+	cgroupPath, ok := self.cgroupPaths[subsystem]
+	if !ok {
+		return "", fmt.Errorf("the %s cgroup hierarchy is not mounted", subsystem)
+	}
+	return cgroupPath, nil
+}
+
+// Containers using the network of the machine have no address of their own.
+func (self *dockerContainerHandler) GetContainerIPAddress() string {
+	if self.ipAddress == "" {
+		return "localhost"
+	}
+	return self.ipAddress
+}
+
 func (self *dockerContainerHandler) WatchSubcontainers(events chan container.SubcontainerEvent) error {
 	return fmt.Errorf("watch is unimplemented in the Docker container driver")
 }
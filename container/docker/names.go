@@ -0,0 +1,46 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package docker
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+// The namespace under which Docker aliases are unique.
+var DockerNamespace = "docker"
+
+// Cgroup of a container created by Docker's systemd cgroup driver: a docker-{ID}.scope unit, in
+// /system.slice by default or in the slice given as cgroup parent.
+var systemdDockerScope = regexp.MustCompile("^docker-([0-9a-f]+)\\.scope$")
+
+// Returns whether the specified full container name corresponds to a Docker container.
+// Docker may use either cgroup driver on systemd systems, both namings are recognized.
+func IsDockerContainerName(name string) bool {
+	return strings.HasPrefix(name, "/docker/") || systemdDockerScope.MatchString(path.Base(name))
+}
+
+// Returns the Docker ID from the full container name.
+func ContainerNameToDockerId(name string) string {
+	id := path.Base(name)
+
+	// Turn systemd cgroup name into Docker ID.
+	if matches := systemdDockerScope.FindStringSubmatch(id); matches != nil {
+		id = matches[1]
+	}
+
+	return id
+}
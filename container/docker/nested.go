@@ -0,0 +1,146 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package docker
+
+import (
+	"flag"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/golang/glog"
+	"github.com/google/cadvisor/utils"
+)
+
+var argDockerNested = flag.Bool("docker_nested", false, "Detect Docker daemons running inside Docker containers (Docker-in-Docker) and monitor their containers as children of the outer container")
+
+// Cgroup directory under which a nested Docker daemon creates its containers.
+const nestedDockerCgroup = "/docker/"
+
+// A Docker daemon running inside a Docker container.
+type nestedDaemon struct {
+	client *docker.Client
+
+	// Docker state root directory of the nested daemon as seen from the host.
+	rootDir string
+
+	// Storage driver of the nested daemon.
+	storage *storageDriver
+}
+
+// How long to wait before looking again for a daemon in a container that had none.
+// The nested daemon may start well after its container.
+const nestedDaemonRecheckInterval = time.Minute
+
+// Nested Docker daemons, keyed by the name of the container they run in.
+type nestedDaemons struct {
+	// Client of the host's Docker daemon.
+	client *docker.Client
+
+	lock    sync.Mutex
+	daemons map[string]*nestedDaemon
+
+	// Time at which containers without a daemon were last checked.
+	checked map[string]time.Time
+}
+
+func newNestedDaemons(client *docker.Client) *nestedDaemons {
+	return &nestedDaemons{
+		client:  client,
+		daemons: make(map[string]*nestedDaemon),
+		checked: make(map[string]time.Time),
+	}
+}
+
+// Splits the name of a container created by a nested Docker daemon into the name of the outer
+// container and the Docker ID of the nested one.
+// e.g.: /docker/<outer ID>/docker/<nested ID>
+func splitNestedDockerName(name string) (outerName string, id string, ok bool) {
+	i := strings.LastIndex(name, nestedDockerCgroup)
+	if i <= 0 {
+		return "", "", false
+	}
+	outerName = name[:i]
+	id = name[i+len(nestedDockerCgroup):]
+	if id == "" || strings.Contains(id, "/") || !IsDockerContainerName(outerName) {
+		return "", "", false
+	}
+	// Only one level of nesting is supported.
+	if _, _, nested := splitNestedDockerName(outerName); nested {
+		return "", "", false
+	}
+	return outerName, id, true
+}
+
+// Returns the Docker daemon running inside the specified Docker container or nil if there is none.
+func (self *nestedDaemons) get(outerName string) (*nestedDaemon, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if daemon, ok := self.daemons[outerName]; ok {
+		return daemon, nil
+	}
+	if time.Since(self.checked[outerName]) < nestedDaemonRecheckInterval {
+		return nil, nil
+	}
+	self.checked[outerName] = time.Now()
+
+	ctnr, err := self.client.InspectContainer(ContainerNameToDockerId(outerName))
+	if err != nil {
+		return nil, err
+	}
+	if !ctnr.State.Running || ctnr.State.Pid == 0 {
+		return nil, nil
+	}
+
+	// Look for the daemon's socket in the container's root filesystem.
+	containerRoot := fmt.Sprintf("/proc/%d/root", ctnr.State.Pid)
+	socket := path.Join(containerRoot, "var/run/docker.sock")
+	if !utils.FileExists(socket) {
+		return nil, nil
+	}
+	client, err := docker.NewClient("unix://" + socket)
+	if err != nil {
+		return nil, err
+	}
+	information, err := client.Info()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get info of the Docker daemon in container %q: %v", outerName, err)
+	}
+
+	rootDir := path.Join(containerRoot, "var/lib/docker")
+	daemon := &nestedDaemon{
+		client:  client,
+		rootDir: rootDir,
+		storage: newStorageDriver(information, rootDir),
+	}
+	glog.Infof("Found nested Docker daemon in container %q", outerName)
+	delete(self.checked, outerName)
+	self.daemons[outerName] = daemon
+	return daemon, nil
+}
+
+// Forgets the daemon of the specified container, it will be detected again on next use.
+func (self *nestedDaemons) forget(outerName string) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	delete(self.daemons, outerName)
+	delete(self.checked, outerName)
+}
@@ -0,0 +1,127 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package docker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
+func TestSplitNestedDockerName(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		ok        bool
+		outerName string
+		id        string
+	}{
+		{"/docker/outer/docker/inner", true, "/docker/outer", "inner"},
+		{"/system.slice/docker-abc123.scope/docker/inner", true, "/system.slice/docker-abc123.scope", "inner"},
+		{"/docker/outer", false, "", ""},
+		{"/docker/outer/docker/", false, "", ""},
+		{"/docker/outer/docker/inner/sub", false, "", ""},
+		{"/user/outer/docker/inner", false, "", ""},
+		// Only one level of nesting is supported.
+		{"/docker/outer/docker/middle/docker/inner", false, "", ""},
+	} {
+		outerName, id, ok := splitNestedDockerName(test.name)
+		if ok != test.ok || outerName != test.outerName || id != test.id {
+			t.Errorf("splitNestedDockerName(%q) = %q, %q, %v, expected %q, %q, %v", test.name, outerName, id, ok, test.outerName, test.id, test.ok)
+		}
+	}
+}
+
+func TestNestedDaemonsGet(t *testing.T) {
+	server := newFakeDockerServer()
+	defer server.Close()
+	client, err := docker.NewClient(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	daemons := newNestedDaemons(client)
+
+	for _, test := range []struct {
+		outerName string
+		err       bool
+	}{
+		// Not running.
+		{"/docker/stopped", false},
+		// Running without a pid, so there is no root filesystem to find a daemon in.
+		{"/docker/running", false},
+		{"/docker/missing", true},
+	} {
+		daemon, err := daemons.get(test.outerName)
+		if (err != nil) != test.err || daemon != nil {
+			t.Errorf("get(%q) = %v, %v, expected no daemon and an error: %v", test.outerName, daemon, err, test.err)
+		}
+		if daemons.checked[test.outerName].IsZero() {
+			t.Errorf("expected %q to be checked", test.outerName)
+		}
+	}
+
+	// Not checked again until the recheck interval elapses.
+	server.Close()
+	if daemon, err := daemons.get("/docker/missing"); daemon != nil || err != nil {
+		t.Errorf("expected %q not to be checked again, got %v, %v", "/docker/missing", daemon, err)
+	}
+	daemons.checked["/docker/missing"] = time.Now().Add(-nestedDaemonRecheckInterval)
+	if _, err := daemons.get("/docker/missing"); err == nil {
+		t.Errorf("expected %q to be checked again", "/docker/missing")
+	}
+
+	// Known daemons are returned until forgotten.
+	known := &nestedDaemon{client: client}
+	daemons.daemons["/docker/outer"] = known
+	if daemon, err := daemons.get("/docker/outer"); daemon != known || err != nil {
+		t.Errorf("expected the known daemon, got %v, %v", daemon, err)
+	}
+	daemons.forget("/docker/outer")
+	if _, ok := daemons.daemons["/docker/outer"]; ok {
+		t.Error("expected the daemon to be forgotten")
+	}
+}
+
+func TestCanAcceptNested(t *testing.T) {
+	server := newFakeDockerServer()
+	defer server.Close()
+	client, err := docker.NewClient(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	factory := &dockerFactory{client: client, nested: newNestedDaemons(client)}
+	// The fake server also serves as the daemon nested in /docker/outer.
+	factory.nested.daemons["/docker/outer"] = &nestedDaemon{client: client}
+
+	for _, test := range []struct {
+		name     string
+		expected bool
+		err      bool
+	}{
+		{"/docker/outer/docker/running", true, false},
+		{"/docker/outer/docker/stopped", false, false},
+		{"/docker/outer/docker/missing", false, false},
+		// No daemon runs in the stopped container.
+		{"/docker/stopped/docker/running", false, true},
+	} {
+		accept, err := factory.CanAccept(test.name)
+		if accept != test.expected || (err != nil) != test.err {
+			t.Errorf("CanAccept(%q) = %v, %v, expected %v and an error: %v", test.name, accept, err, test.expected, test.err)
+		}
+	}
+}
@@ -15,12 +15,26 @@
 package container
 
 import (
+	"flag"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 
 	"github.com/golang/glog"
 )
 
+var argDisabledFactories = flag.String("disable_container_factories", "", "Comma separated list of container handler factories not to register (e.g.: rkt,lxc)")
+
+// Priorities of the factories. Factories with a higher priority are asked first whether they can
+// handle a container, factories with the same priority in the order they were registered.
+const (
+	// Factories of container runtimes, handling the containers they know.
+	PriorityRuntime = 100
+	// The raw factory, handling any container.
+	PriorityRaw = 0
+)
+
 type ContainerHandlerFactory interface {
 	// Create a new ContainerHandler using this factory. CanHandle() must have returned true.
 	NewContainerHandler(name string) (ContainerHandler, error)
@@ -32,42 +46,149 @@ type ContainerHandlerFactory interface {
 	String() string
 }
 
+// A factory that may handle containers that should not be monitored (e.g.: helper cgroups of a
+// runtime). Containers it handles but does not accept are not monitored, nor asked to the
+// following factories.
+type AcceptingContainerHandlerFactory interface {
+	ContainerHandlerFactory
+
+	// Returns whether the specified container should be monitored. CanHandle() must have returned true.
+	CanAccept(name string) (bool, error)
+}
+
+// A factory that reports the containers of its runtime as they start and stop, ahead of the watch
+// of their cgroups and of the periodic detection of containers.
+type WatchingContainerHandlerFactory interface {
+	ContainerHandlerFactory
+
+	// Registers a channel to listen for the containers of the runtime being added and deleted.
+	WatchContainers(events chan SubcontainerEvent) error
+
+	// Stops watching for containers.
+	StopWatchingContainers() error
+}
+
+type registeredFactory struct {
+	factory  ContainerHandlerFactory
+	priority int
+}
+
 // TODO(vmarmol): Consider not making this global.
-// Global list of factories.
+// Global list of factories, by decreasing priority.
 var (
-	factories     []ContainerHandlerFactory
+	factories     []registeredFactory
 	factoriesLock sync.RWMutex
 )
 
-// Register a ContainerHandlerFactory. These should be registered from least general to most general
-// as they will be asked in order whether they can handle a particular container.
-func RegisterContainerHandlerFactory(factory ContainerHandlerFactory) {
+// Returns whether the factory with the specified name was disabled by --disable_container_factories.
+func FactoryDisabled(name string) bool {
+	for _, disabled := range strings.Split(*argDisabledFactories, ",") {
+		if strings.TrimSpace(disabled) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Register a ContainerHandlerFactory with the specified priority, unless it is disabled. Factories
+// of runtimes built outside of cAdvisor can be registered the same way as the built-in ones.
+func RegisterContainerHandlerFactory(factory ContainerHandlerFactory, priority int) {
+	if FactoryDisabled(factory.String()) {
+		glog.Infof("Factory %q is disabled", factory)
+		return
+	}
+
 	factoriesLock.Lock()
 	defer factoriesLock.Unlock()
 
-	factories = append(factories, factory)
+	i := sort.Search(len(factories), func(i int) bool {
+		return factories[i].priority < priority
+	})
+	factories = append(factories, registeredFactory{})
+	copy(factories[i+1:], factories[i:])
+	factories[i] = registeredFactory{factory, priority}
 }
 
-// Create a new ContainerHandler for the specified container.
-func NewContainerHandler(name string) (ContainerHandler, error) {
+// Returns the names of the registered factories, by decreasing priority.
+func RegisteredFactories() []string {
+	factoriesLock.RLock()
+	defer factoriesLock.RUnlock()
+
+	names := make([]string, 0, len(factories))
+	for _, registered := range factories {
+		names = append(names, registered.factory.String())
+	}
+	return names
+}
+
+// Create a new ContainerHandler for the specified container. accept is false if the first factory
+// that can handle the container does not accept it, the container is then not to be monitored.
+func NewContainerHandler(name string) (handler ContainerHandler, accept bool, err error) {
 	factoriesLock.RLock()
 	defer factoriesLock.RUnlock()
 
 	// Create the ContainerHandler with the first factory that supports it.
-	for _, factory := range factories {
+	for _, registered := range factories {
+		factory := registered.factory
 		canHandle, err := factory.CanHandle(name)
 		if err != nil {
 			glog.V(1).Infof("Error trying to work out if we can hande %s: %v", name, err)
 		}
-		if canHandle {
-			glog.V(1).Infof("Using factory %q for container %q", factory, name)
-			return factory.NewContainerHandler(name)
-		} else {
+		if !canHandle {
 			glog.V(1).Infof("Factory %q was unable to handle container %q", factory, name)
+			continue
+		}
+		if accepting, ok := factory.(AcceptingContainerHandlerFactory); ok {
+			canAccept, err := accepting.CanAccept(name)
+			if err != nil {
+				return nil, false, fmt.Errorf("factory %q failed to work out if it accepts container %q: %v", factory, name, err)
+			}
+			if !canAccept {
+				glog.V(1).Infof("Factory %q does not accept container %q", factory, name)
+				return nil, false, nil
+			}
 		}
+		glog.V(1).Infof("Using factory %q for container %q", factory, name)
+		handler, err := factory.NewContainerHandler(name)
+		return handler, true, err
 	}
 
-	return nil, fmt.Errorf("no known factory can handle creation of container")
+	return nil, false, fmt.Errorf("no known factory can handle creation of container")
+}
+
+// Watches for the containers of the factories that report them. Tries all the factories, returns the
+// first error.
+func WatchContainers(events chan SubcontainerEvent) error {
+	factoriesLock.RLock()
+	defer factoriesLock.RUnlock()
+
+	var firstErr error
+	for _, registered := range factories {
+		if watching, ok := registered.factory.(WatchingContainerHandlerFactory); ok {
+			err := watching.WatchContainers(events)
+			if err != nil && firstErr == nil {
+				firstErr = fmt.Errorf("factory %q failed to watch for containers: %v", watching, err)
+			}
+		}
+	}
+	return firstErr
+}
+
+// Stops watching for the containers of the factories.
+func StopWatchingContainers() error {
+	factoriesLock.RLock()
+	defer factoriesLock.RUnlock()
+
+	var firstErr error
+	for _, registered := range factories {
+		if watching, ok := registered.factory.(WatchingContainerHandlerFactory); ok {
+			err := watching.StopWatchingContainers()
+			if err != nil && firstErr == nil {
+				firstErr = fmt.Errorf("factory %q failed to stop watching for containers: %v", watching, err)
+			}
+		}
+	}
+	return firstErr
 }
 
 // Clear the known factories.
@@ -75,5 +196,5 @@ func ClearContainerHandlerFactories() {
 	factoriesLock.Lock()
 	defer factoriesLock.Unlock()
 
-	factories = make([]ContainerHandlerFactory, 0, 4)
+	factories = make([]registeredFactory, 0, 4)
 }
@@ -15,6 +15,7 @@
 package container
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/stretchr/testify/mock"
@@ -51,7 +52,7 @@ func TestNewContainerHandler_FirstMatches(t *testing.T) {
 		Name:           "yes",
 		CanHandleValue: true,
 	}
-	RegisterContainerHandlerFactory(allwaysYes)
+	RegisterContainerHandlerFactory(allwaysYes, PriorityRuntime)
 
 	// The yes factory should be asked to create the ContainerHandler.
 	mockContainer, err := mockFactory.NewContainerHandler(testContainerName)
@@ -60,10 +61,13 @@ func TestNewContainerHandler_FirstMatches(t *testing.T) {
 	}
 	allwaysYes.On("NewContainerHandler", testContainerName).Return(mockContainer, nil)
 
-	cont, err := NewContainerHandler(testContainerName)
+	cont, accept, err := NewContainerHandler(testContainerName)
 	if err != nil {
 		t.Error(err)
 	}
+	if !accept {
+		t.Error("Expected container to be accepted")
+	}
 	if cont == nil {
 		t.Error("Expected container to not be nil")
 	}
@@ -77,12 +81,12 @@ func TestNewContainerHandler_SecondMatches(t *testing.T) {
 		Name:           "no",
 		CanHandleValue: false,
 	}
-	RegisterContainerHandlerFactory(allwaysNo)
+	RegisterContainerHandlerFactory(allwaysNo, PriorityRuntime)
 	allwaysYes := &mockContainerHandlerFactory{
 		Name:           "yes",
 		CanHandleValue: true,
 	}
-	RegisterContainerHandlerFactory(allwaysYes)
+	RegisterContainerHandlerFactory(allwaysYes, PriorityRuntime)
 
 	// The yes factory should be asked to create the ContainerHandler.
 	mockContainer, err := mockFactory.NewContainerHandler(testContainerName)
@@ -91,10 +95,13 @@ func TestNewContainerHandler_SecondMatches(t *testing.T) {
 	}
 	allwaysYes.On("NewContainerHandler", testContainerName).Return(mockContainer, nil)
 
-	cont, err := NewContainerHandler(testContainerName)
+	cont, accept, err := NewContainerHandler(testContainerName)
 	if err != nil {
 		t.Error(err)
 	}
+	if !accept {
+		t.Error("Expected container to be accepted")
+	}
 	if cont == nil {
 		t.Error("Expected container to not be nil")
 	}
@@ -108,14 +115,112 @@ func TestNewContainerHandler_NoneMatch(t *testing.T) {
 		Name:           "no",
 		CanHandleValue: false,
 	}
-	RegisterContainerHandlerFactory(allwaysNo1)
+	RegisterContainerHandlerFactory(allwaysNo1, PriorityRuntime)
 	allwaysNo2 := &mockContainerHandlerFactory{
 		Name:           "no",
 		CanHandleValue: false,
 	}
-	RegisterContainerHandlerFactory(allwaysNo2)
+	RegisterContainerHandlerFactory(allwaysNo2, PriorityRuntime)
+
+	_, _, err := NewContainerHandler(testContainerName)
+	if err == nil {
+		t.Error("Expected NewContainerHandler to fail")
+	}
+}
+
+func TestNewContainerHandler_Priority(t *testing.T) {
+	ClearContainerHandlerFactories()
+
+	// Register an allways yes factory with a low priority before another one.
+	low := &mockContainerHandlerFactory{
+		Name:           "low",
+		CanHandleValue: true,
+	}
+	RegisterContainerHandlerFactory(low, PriorityRaw)
+	high := &mockContainerHandlerFactory{
+		Name:           "high",
+		CanHandleValue: true,
+	}
+	RegisterContainerHandlerFactory(high, PriorityRuntime)
+
+	// The factory with the highest priority should be asked to create the ContainerHandler.
+	mockContainer, err := mockFactory.NewContainerHandler(testContainerName)
+	if err != nil {
+		t.Error(err)
+	}
+	high.On("NewContainerHandler", testContainerName).Return(mockContainer, nil)
+
+	cont, _, err := NewContainerHandler(testContainerName)
+	if err != nil {
+		t.Error(err)
+	}
+	if cont == nil {
+		t.Error("Expected container to not be nil")
+	}
+	high.AssertExpectations(t)
+	low.AssertNotCalled(t, "NewContainerHandler", testContainerName)
+}
+
+type mockAcceptingContainerHandlerFactory struct {
+	mockContainerHandlerFactory
+	CanAcceptValue bool
+}
+
+func (self *mockAcceptingContainerHandlerFactory) CanAccept(name string) (bool, error) {
+	return self.CanAcceptValue, nil
+}
+
+func TestNewContainerHandler_NotAccepted(t *testing.T) {
+	ClearContainerHandlerFactories()
+
+	// Register a factory handling but not accepting containers, before an allways yes one.
+	notAccepting := &mockAcceptingContainerHandlerFactory{
+		mockContainerHandlerFactory: mockContainerHandlerFactory{
+			Name:           "not accepting",
+			CanHandleValue: true,
+		},
+	}
+	RegisterContainerHandlerFactory(notAccepting, PriorityRuntime)
+	allwaysYes := &mockContainerHandlerFactory{
+		Name:           "yes",
+		CanHandleValue: true,
+	}
+	RegisterContainerHandlerFactory(allwaysYes, PriorityRaw)
+
+	cont, accept, err := NewContainerHandler(testContainerName)
+	if err != nil {
+		t.Error(err)
+	}
+	if accept || cont != nil {
+		t.Error("Expected container not to be accepted")
+	}
+	allwaysYes.AssertNotCalled(t, "NewContainerHandler", testContainerName)
+}
+
+func TestRegisteredFactories(t *testing.T) {
+	ClearContainerHandlerFactories()
+	RegisterContainerHandlerFactory(&mockContainerHandlerFactory{Name: "raw"}, PriorityRaw)
+	RegisterContainerHandlerFactory(&mockContainerHandlerFactory{Name: "docker"}, PriorityRuntime)
+
+	if names := RegisteredFactories(); !reflect.DeepEqual(names, []string{"docker", "raw"}) {
+		t.Errorf("unexpected factories: %v", names)
+	}
+}
+
+func TestRegisterContainerHandlerFactory_Disabled(t *testing.T) {
+	ClearContainerHandlerFactories()
+	defer func(disabled string) {
+		*argDisabledFactories = disabled
+	}(*argDisabledFactories)
+	*argDisabledFactories = "other, yes"
+
+	allwaysYes := &mockContainerHandlerFactory{
+		Name:           "yes",
+		CanHandleValue: true,
+	}
+	RegisterContainerHandlerFactory(allwaysYes, PriorityRuntime)
 
-	_, err := NewContainerHandler(testContainerName)
+	_, _, err := NewContainerHandler(testContainerName)
 	if err == nil {
 		t.Error("Expected NewContainerHandler to fail")
 	}
@@ -0,0 +1,220 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package hcs implements a container handler factory for Windows Server Containers, whose stats
+// are collected through the Host Compute Service (HCS) rather than cgroups.
+package hcs
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/cadvisor/container"
+	"github.com/google/cadvisor/info"
+)
+
+var argPollInterval = flag.Duration("hcs_poll_interval", 5*time.Second, "Interval between listings of the HCS compute systems for started and stopped Windows containers")
+
+// The namespace under which the aliases of HCS containers are unique.
+const HcsNamespace = "hcs"
+
+// Containers are named /hcs/<ID> as they have no cgroups.
+const namePrefix = "/hcs/"
+
+// Compute systems of the type of containers, others are utility and Hyper-V virtual machines.
+const systemTypeContainer = "Container"
+
+// A compute system as listed by the HCS.
+type computeSystem struct {
+	Id         string
+	Name       string
+	Owner      string
+	SystemType string
+	State      string
+}
+
+// Source of the compute systems and of their statistics.
+type computeSystems interface {
+	// Lists the compute systems, running or not.
+	list() ([]computeSystem, error)
+
+	// Returns the statistics of the compute system with the specified ID.
+	statistics(id string) (*statistics, error)
+
+	// Returns the CPU and memory usage of the machine.
+	host() (*statistics, error)
+}
+
+// Returns the ID of the container, ok is false if name is not the one of an HCS container.
+func parseName(name string) (id string, ok bool) {
+	if !strings.HasPrefix(name, namePrefix) {
+		return "", false
+	}
+	id = strings.TrimPrefix(name, namePrefix)
+	if id == "" || strings.Contains(id, "/") {
+		return "", false
+	}
+	return id, true
+}
+
+type hcsFactory struct {
+	machineInfoFactory info.MachineInfoFactory
+
+	systems computeSystems
+
+	// Running containers, by name, as of the latest listing.
+	lock       sync.Mutex
+	containers map[string]computeSystem
+
+	stopWatcher chan chan bool
+}
+
+func (self *hcsFactory) String() string {
+	return HcsNamespace
+}
+
+func (self *hcsFactory) NewContainerHandler(name string) (container.ContainerHandler, error) {
+	if name == "/" {
+		return newRootHandler(self, self.machineInfoFactory), nil
+	}
+	system, ok := self.container(name)
+	if !ok {
+		return nil, fmt.Errorf("no HCS container %q", name)
+	}
+	return newHcsContainerHandler(name, system, self.systems, self.machineInfoFactory), nil
+}
+
+// The root container, the machine, is handled too as it has no cgroups either.
+func (self *hcsFactory) CanHandle(name string) (bool, error) {
+	if name == "/" {
+		return true, nil
+	}
+	_, ok := parseName(name)
+	return ok, nil
+}
+
+// Only running containers are monitored.
+func (self *hcsFactory) CanAccept(name string) (bool, error) {
+	if name == "/" {
+		return true, nil
+	}
+	if _, err := self.refresh(); err != nil {
+		return false, err
+	}
+	_, ok := self.container(name)
+	return ok, nil
+}
+
+func (self *hcsFactory) container(name string) (computeSystem, bool) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	system, ok := self.containers[name]
+	return system, ok
+}
+
+// Lists the running containers and returns them by name.
+func (self *hcsFactory) refresh() (map[string]computeSystem, error) {
+	systems, err := self.systems.list()
+	if err != nil {
+		return nil, err
+	}
+	containers := make(map[string]computeSystem)
+	for _, system := range systems {
+		if system.SystemType == systemTypeContainer && system.State == "Running" {
+			containers[namePrefix+system.Id] = system
+		}
+	}
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.containers = containers
+	return containers, nil
+}
+
+// Reports the containers started and stopped since the previous listing, known holds the
+// containers of the previous listing.
+func (self *hcsFactory) pollContainers(known map[string]computeSystem, events chan container.SubcontainerEvent) (map[string]computeSystem, error) {
+	current, err := self.refresh()
+	if err != nil {
+		return known, err
+	}
+	for name := range current {
+		if _, ok := known[name]; !ok {
+			events <- container.SubcontainerEvent{EventType: container.SubcontainerAdd, Name: name}
+		}
+	}
+	for name := range known {
+		if _, ok := current[name]; !ok {
+			events <- container.SubcontainerEvent{EventType: container.SubcontainerDelete, Name: name}
+		}
+	}
+	return current, nil
+}
+
+// Windows containers have no cgroups to watch, they are listed every --hcs_poll_interval.
+func (self *hcsFactory) WatchContainers(events chan container.SubcontainerEvent) error {
+	go func() {
+		ticker := time.NewTicker(*argPollInterval)
+		defer ticker.Stop()
+		var known map[string]computeSystem
+		for {
+			var err error
+			known, err = self.pollContainers(known, events)
+			if err != nil {
+				glog.Warningf("Error while listing the HCS compute systems: %v", err)
+			}
+			select {
+			case <-ticker.C:
+			case done := <-self.stopWatcher:
+				done <- true
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (self *hcsFactory) StopWatchingContainers() error {
+	done := make(chan bool)
+	self.stopWatcher <- done
+	<-done
+	return nil
+}
+
+func newFactory(systems computeSystems, machineInfoFactory info.MachineInfoFactory) *hcsFactory {
+	return &hcsFactory{
+		machineInfoFactory: machineInfoFactory,
+		systems:            systems,
+		containers:         make(map[string]computeSystem),
+		stopWatcher:        make(chan chan bool),
+	}
+}
+
+// Registers the HCS factory, only available on Windows.
+func Register(machineInfoFactory info.MachineInfoFactory) error {
+	systems, err := newComputeSystems()
+	if err != nil {
+		return err
+	}
+	f := newFactory(systems, machineInfoFactory)
+	if _, err := f.refresh(); err != nil {
+		return fmt.Errorf("unable to list the HCS compute systems: %v", err)
+	}
+	glog.Infof("Registering HCS factory")
+	container.RegisterContainerHandlerFactory(f, container.PriorityRuntime)
+	return nil
+}
@@ -0,0 +1,188 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hcs
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/cadvisor/container"
+	"github.com/google/cadvisor/info"
+)
+
+// Statistics of a compute system, as reported by the HCS (times in units of 100ns).
+type statistics struct {
+	Timestamp time.Time
+	Processor struct {
+		TotalRuntime100ns  uint64
+		RuntimeUser100ns   uint64
+		RuntimeKernel100ns uint64
+	}
+	Memory struct {
+		UsageCommitBytes            uint64
+		UsageCommitPeakBytes        uint64
+		UsagePrivateWorkingSetBytes uint64
+	}
+	Storage struct {
+		ReadCountNormalized  uint64
+		ReadSizeBytes        uint64
+		WriteCountNormalized uint64
+		WriteSizeBytes       uint64
+	}
+	Network []struct {
+		EndpointId             string
+		BytesReceived          uint64
+		BytesSent              uint64
+		PacketsReceived        uint64
+		PacketsSent            uint64
+		DroppedPacketsIncoming uint64
+		DroppedPacketsOutgoing uint64
+	}
+}
+
+// Converts the statistics of the HCS to the ones of cAdvisor.
+func (self *statistics) containerStats() *info.ContainerStats {
+	stats := &info.ContainerStats{Timestamp: self.Timestamp}
+	stats.Cpu.Usage.Total = self.Processor.TotalRuntime100ns * 100
+	stats.Cpu.Usage.User = self.Processor.RuntimeUser100ns * 100
+	stats.Cpu.Usage.System = self.Processor.RuntimeKernel100ns * 100
+
+	stats.Memory.Usage = self.Memory.UsageCommitBytes
+	stats.Memory.WorkingSet = self.Memory.UsagePrivateWorkingSetBytes
+
+	// The HCS does not report per device stats, they are reported as the ones of device 0:0.
+	storage := self.Storage
+	stats.DiskIo.IoServiceBytes = []info.PerDiskStats{{
+		Stats: map[string]uint64{
+			"Read":  storage.ReadSizeBytes,
+			"Write": storage.WriteSizeBytes,
+			"Total": storage.ReadSizeBytes + storage.WriteSizeBytes,
+		},
+	}}
+	stats.DiskIo.IoServiced = []info.PerDiskStats{{
+		Stats: map[string]uint64{
+			"Read":  storage.ReadCountNormalized,
+			"Write": storage.WriteCountNormalized,
+			"Total": storage.ReadCountNormalized + storage.WriteCountNormalized,
+		},
+	}}
+
+	for _, endpoint := range self.Network {
+		stats.Network.RxBytes += endpoint.BytesReceived
+		stats.Network.RxPackets += endpoint.PacketsReceived
+		stats.Network.RxDropped += endpoint.DroppedPacketsIncoming
+		stats.Network.TxBytes += endpoint.BytesSent
+		stats.Network.TxPackets += endpoint.PacketsSent
+		stats.Network.TxDropped += endpoint.DroppedPacketsOutgoing
+	}
+	return stats
+}
+
+// Collects the stats of a Windows container from the HCS.
+type hcsContainerHandler struct {
+	name               string
+	system             computeSystem
+	systems            computeSystems
+	machineInfoFactory info.MachineInfoFactory
+}
+
+func newHcsContainerHandler(name string, system computeSystem, systems computeSystems, machineInfoFactory info.MachineInfoFactory) container.ContainerHandler {
+	return &hcsContainerHandler{
+		name:               name,
+		system:             system,
+		systems:            systems,
+		machineInfoFactory: machineInfoFactory,
+	}
+}
+
+func (self *hcsContainerHandler) ContainerReference() (info.ContainerReference, error) {
+	aliases := []string{self.system.Id}
+	if self.system.Name != "" && self.system.Name != self.system.Id {
+		aliases = append(aliases, self.system.Name)
+	}
+	return info.ContainerReference{
+		Name:      self.name,
+		Aliases:   aliases,
+		Namespace: HcsNamespace,
+		Labels:    self.labels(),
+	}, nil
+}
+
+func (self *hcsContainerHandler) labels() map[string]string {
+	if self.system.Owner == "" {
+		return nil
+	}
+	return map[string]string{"hcs.owner": self.system.Owner}
+}
+
+// The HCS does not report the limits of containers, they are the ones of the machine.
+func (self *hcsContainerHandler) GetSpec() (info.ContainerSpec, error) {
+	var spec info.ContainerSpec
+	mi, err := self.machineInfoFactory.GetMachineInfo()
+	if err != nil {
+		return spec, err
+	}
+	spec.HasCpu = true
+	spec.Cpu.Limit = 1024
+	spec.Cpu.Mask = fmt.Sprintf("0-%d", mi.NumCores-1)
+	spec.HasMemory = true
+	spec.Memory.Limit = uint64(mi.MemoryCapacity)
+	spec.HasNetwork = true
+	spec.Labels = self.labels()
+	return spec, nil
+}
+
+func (self *hcsContainerHandler) GetStats() (*info.ContainerStats, error) {
+	stats, err := self.systems.statistics(self.system.Id)
+	if err != nil {
+		return nil, err
+	}
+	return stats.containerStats(), nil
+}
+
+// Windows containers have no subcontainers.
+func (self *hcsContainerHandler) ListContainers(listType container.ListType) ([]info.ContainerReference, error) {
+	return nil, nil
+}
+
+func (self *hcsContainerHandler) ListThreads(listType container.ListType) ([]int, error) {
+	return nil, nil
+}
+
+func (self *hcsContainerHandler) ListProcesses(listType container.ListType) ([]int, error) {
+	return nil, nil
+}
+
+func (self *hcsContainerHandler) GetCgroupPath(subsystem string) (string, error) {
+	return "", fmt.Errorf("Windows containers have no cgroups")
+}
+
+// The HCS does not report the addresses of the endpoints of the compute systems.
+func (self *hcsContainerHandler) GetContainerIPAddress() string {
+	return "localhost"
+}
+
+func (self *hcsContainerHandler) WatchSubcontainers(events chan container.SubcontainerEvent) error {
+	return nil
+}
+
+func (self *hcsContainerHandler) StopWatchingSubcontainers() error {
+	return nil
+}
+
+func (self *hcsContainerHandler) Exists() bool {
+	_, err := self.systems.statistics(self.system.Id)
+	return err == nil
+}
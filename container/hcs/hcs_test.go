@@ -0,0 +1,191 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hcs
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/google/cadvisor/container"
+	"github.com/google/cadvisor/info"
+)
+
+type fakeSystems struct {
+	systems   []computeSystem
+	stats     map[string]*statistics
+	hostStats statistics
+}
+
+func (self *fakeSystems) list() ([]computeSystem, error) {
+	return self.systems, nil
+}
+
+func (self *fakeSystems) statistics(id string) (*statistics, error) {
+	stats, ok := self.stats[id]
+	if !ok {
+		return nil, fmt.Errorf("no compute system %q", id)
+	}
+	return stats, nil
+}
+
+func (self *fakeSystems) host() (*statistics, error) {
+	return &self.hostStats, nil
+}
+
+func TestParseName(t *testing.T) {
+	for name, expected := range map[string]string{
+		"/hcs/abc123":     "abc123",
+		"/hcs/":           "",
+		"/hcs/abc123/foo": "",
+		"/docker/abc123":  "",
+	} {
+		id, ok := parseName(name)
+		if id != expected || ok != (expected != "") {
+			t.Errorf("%q: expected %q, got %q (%v)", name, expected, id, ok)
+		}
+	}
+}
+
+func TestContainerStats(t *testing.T) {
+	var stats statistics
+	err := json.Unmarshal([]byte(`{
+		"Processor": {"TotalRuntime100ns": 30, "RuntimeUser100ns": 20, "RuntimeKernel100ns": 10},
+		"Memory": {"UsageCommitBytes": 4096, "UsagePrivateWorkingSetBytes": 1024},
+		"Storage": {"ReadCountNormalized": 2, "ReadSizeBytes": 200, "WriteCountNormalized": 1, "WriteSizeBytes": 100},
+		"Network": [
+			{"EndpointId": "a", "BytesReceived": 10, "BytesSent": 20, "PacketsReceived": 1, "PacketsSent": 2},
+			{"EndpointId": "b", "BytesReceived": 5, "BytesSent": 5, "DroppedPacketsIncoming": 3}
+		]
+	}`), &stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := stats.containerStats()
+	if s.Cpu.Usage.Total != 3000 || s.Cpu.Usage.User != 2000 || s.Cpu.Usage.System != 1000 {
+		t.Errorf("unexpected CPU usage: %+v", s.Cpu.Usage)
+	}
+	if s.Memory.Usage != 4096 || s.Memory.WorkingSet != 1024 {
+		t.Errorf("unexpected memory usage: %+v", s.Memory)
+	}
+	if bytes := s.DiskIo.IoServiceBytes[0].Stats; bytes["Read"] != 200 || bytes["Write"] != 100 || bytes["Total"] != 300 {
+		t.Errorf("unexpected disk bytes: %v", bytes)
+	}
+	if ops := s.DiskIo.IoServiced[0].Stats; ops["Total"] != 3 {
+		t.Errorf("unexpected disk operations: %v", ops)
+	}
+	if s.Network.RxBytes != 15 || s.Network.TxBytes != 25 || s.Network.RxPackets != 1 || s.Network.RxDropped != 3 {
+		t.Errorf("unexpected network stats: %+v", s.Network)
+	}
+}
+
+func TestPollContainers(t *testing.T) {
+	systems := &fakeSystems{systems: []computeSystem{
+		{Id: "running", SystemType: "Container", State: "Running"},
+		{Id: "stopped", SystemType: "Container", State: "Stopped"},
+		{Id: "vm", SystemType: "VirtualMachine", State: "Running"},
+	}}
+	f := newFactory(systems, nil)
+	events := make(chan container.SubcontainerEvent, 10)
+	known, err := f.pollContainers(nil, events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := <-events; !reflect.DeepEqual(e, container.SubcontainerEvent{EventType: container.SubcontainerAdd, Name: "/hcs/running"}) {
+		t.Errorf("unexpected event: %+v", e)
+	}
+	if len(events) != 0 {
+		t.Errorf("unexpected events: %d", len(events))
+	}
+	for name, expected := range map[string]bool{"/hcs/running": true, "/hcs/stopped": false, "/hcs/vm": false} {
+		if ok, err := f.CanAccept(name); err != nil || ok != expected {
+			t.Errorf("%q: expected %v, got %v (%v)", name, expected, ok, err)
+		}
+	}
+
+	systems.systems = nil
+	if _, err := f.pollContainers(known, events); err != nil {
+		t.Fatal(err)
+	}
+	if e := <-events; !reflect.DeepEqual(e, container.SubcontainerEvent{EventType: container.SubcontainerDelete, Name: "/hcs/running"}) {
+		t.Errorf("unexpected event: %+v", e)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	systems := &fakeSystems{
+		systems: []computeSystem{{Id: "abc123", Name: "web", Owner: "docker", SystemType: "Container", State: "Running"}},
+		stats:   map[string]*statistics{"abc123": {}},
+	}
+	f := newFactory(systems, nil)
+	if _, err := f.refresh(); err != nil {
+		t.Fatal(err)
+	}
+	handler, err := f.NewContainerHandler("/hcs/abc123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := handler.ContainerReference()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ref.Aliases, []string{"abc123", "web"}) || ref.Namespace != HcsNamespace || ref.Labels["hcs.owner"] != "docker" {
+		t.Errorf("unexpected reference: %+v", ref)
+	}
+	if !handler.Exists() {
+		t.Error("expected the container to exist")
+	}
+	delete(systems.stats, "abc123")
+	if handler.Exists() {
+		t.Error("expected the container not to exist")
+	}
+	if _, err := f.NewContainerHandler("/hcs/other"); err == nil {
+		t.Error("expected an error for an unknown container")
+	}
+}
+
+func TestRootHandler(t *testing.T) {
+	systems := &fakeSystems{systems: []computeSystem{
+		{Id: "running", SystemType: "Container", State: "Running"},
+		{Id: "stopped", SystemType: "Container", State: "Stopped"},
+	}}
+	systems.hostStats.Memory.UsageCommitBytes = 4096
+	f := newFactory(systems, nil)
+	if ok, err := f.CanHandle("/"); err != nil || !ok {
+		t.Errorf("expected the root container to be handled, got %v (%v)", ok, err)
+	}
+	if ok, err := f.CanAccept("/"); err != nil || !ok {
+		t.Errorf("expected the root container to be accepted, got %v (%v)", ok, err)
+	}
+	handler, err := f.NewContainerHandler("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	refs, err := handler.ListContainers(container.ListRecursive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(refs, []info.ContainerReference{{Name: "/hcs/running"}}) {
+		t.Errorf("unexpected subcontainers: %+v", refs)
+	}
+	stats, err := handler.GetStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Memory.Usage != 4096 {
+		t.Errorf("expected the memory usage of the machine, got %d", stats.Memory.Usage)
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+// +build windows
+
+package hcs
+
+import (
+	"encoding/json"
+	"fmt"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/google/cadvisor/utils/winsys"
+)
+
+// The HCS API of vmcompute.dll, whose strings are JSON documents allocated with CoTaskMemAlloc.
+var (
+	vmcompute = syscall.NewLazyDLL("vmcompute.dll")
+	ole32     = syscall.NewLazyDLL("ole32.dll")
+
+	procHcsEnumerateComputeSystems    = vmcompute.NewProc("HcsEnumerateComputeSystems")
+	procHcsOpenComputeSystem          = vmcompute.NewProc("HcsOpenComputeSystem")
+	procHcsGetComputeSystemProperties = vmcompute.NewProc("HcsGetComputeSystemProperties")
+	procHcsCloseComputeSystem         = vmcompute.NewProc("HcsCloseComputeSystem")
+	procCoTaskMemFree                 = ole32.NewProc("CoTaskMemFree")
+)
+
+const statisticsQuery = `{"PropertyTypes":["Statistics"]}`
+
+type hcsSystems struct{}
+
+func newComputeSystems() (computeSystems, error) {
+	if err := procHcsEnumerateComputeSystems.Find(); err != nil {
+		return nil, fmt.Errorf("the HCS is not available: %v", err)
+	}
+	return &hcsSystems{}, nil
+}
+
+// Returns the string allocated by the HCS, and frees it.
+func takeString(p *uint16) string {
+	if p == nil {
+		return ""
+	}
+	defer procCoTaskMemFree.Call(uintptr(unsafe.Pointer(p)))
+	n := 0
+	for ptr := unsafe.Pointer(p); *(*uint16)(ptr) != 0; ptr = unsafe.Add(ptr, 2) {
+		n++
+	}
+	return syscall.UTF16ToString(unsafe.Slice(p, n))
+}
+
+// Calls an HCS function whose last argument is the JSON details of its result, and returns its
+// error if it failed.
+func call(proc *syscall.LazyProc, args ...uintptr) error {
+	var result *uint16
+	hr, _, _ := proc.Call(append(args, uintptr(unsafe.Pointer(&result)))...)
+	details := takeString(result)
+	if int32(hr) < 0 {
+		return fmt.Errorf("%s failed with HRESULT 0x%x: %s", proc.Name, uint32(hr), details)
+	}
+	return nil
+}
+
+func (self *hcsSystems) list() ([]computeSystem, error) {
+	query, err := syscall.UTF16PtrFromString("{}")
+	if err != nil {
+		return nil, err
+	}
+	var systems *uint16
+	if err := call(procHcsEnumerateComputeSystems, uintptr(unsafe.Pointer(query)), uintptr(unsafe.Pointer(&systems))); err != nil {
+		return nil, err
+	}
+	var ret []computeSystem
+	if err := json.Unmarshal([]byte(takeString(systems)), &ret); err != nil {
+		return nil, fmt.Errorf("unable to parse the compute systems: %v", err)
+	}
+	return ret, nil
+}
+
+func (self *hcsSystems) statistics(id string) (*statistics, error) {
+	utf16Id, err := syscall.UTF16PtrFromString(id)
+	if err != nil {
+		return nil, err
+	}
+	query, err := syscall.UTF16PtrFromString(statisticsQuery)
+	if err != nil {
+		return nil, err
+	}
+	var handle uintptr
+	if err := call(procHcsOpenComputeSystem, uintptr(unsafe.Pointer(utf16Id)), uintptr(unsafe.Pointer(&handle))); err != nil {
+		return nil, err
+	}
+	defer procHcsCloseComputeSystem.Call(handle)
+
+	var properties *uint16
+	if err := call(procHcsGetComputeSystemProperties, handle, uintptr(unsafe.Pointer(query)), uintptr(unsafe.Pointer(&properties))); err != nil {
+		return nil, err
+	}
+	var ret struct {
+		Statistics *statistics
+	}
+	if err := json.Unmarshal([]byte(takeString(properties)), &ret); err != nil {
+		return nil, fmt.Errorf("unable to parse the properties of %q: %v", id, err)
+	}
+	if ret.Statistics == nil {
+		return nil, fmt.Errorf("no statistics for %q", id)
+	}
+	return ret.Statistics, nil
+}
+
+// The CPU time of the machine is the one its processors were not idle.
+func (self *hcsSystems) host() (*statistics, error) {
+	idle, kernel, user, err := winsys.CpuTimes()
+	if err != nil {
+		return nil, err
+	}
+	total, available, err := winsys.Memory()
+	if err != nil {
+		return nil, err
+	}
+	stats := &statistics{Timestamp: time.Now()}
+	stats.Processor.RuntimeKernel100ns = uint64((kernel - idle) / 100)
+	stats.Processor.RuntimeUser100ns = uint64(user / 100)
+	stats.Processor.TotalRuntime100ns = stats.Processor.RuntimeKernel100ns + stats.Processor.RuntimeUser100ns
+	stats.Memory.UsageCommitBytes = total - available
+	stats.Memory.UsagePrivateWorkingSetBytes = total - available
+	return stats, nil
+}
@@ -0,0 +1,104 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hcs
+
+import (
+	"fmt"
+
+	"github.com/google/cadvisor/container"
+	"github.com/google/cadvisor/info"
+)
+
+// Handles the root container, whose stats are the CPU and memory usage of the machine and whose
+// subcontainers are the running Windows containers.
+type hcsRootHandler struct {
+	factory            *hcsFactory
+	machineInfoFactory info.MachineInfoFactory
+}
+
+func newRootHandler(factory *hcsFactory, machineInfoFactory info.MachineInfoFactory) container.ContainerHandler {
+	return &hcsRootHandler{
+		factory:            factory,
+		machineInfoFactory: machineInfoFactory,
+	}
+}
+
+func (self *hcsRootHandler) ContainerReference() (info.ContainerReference, error) {
+	return info.ContainerReference{Name: "/"}, nil
+}
+
+func (self *hcsRootHandler) GetSpec() (info.ContainerSpec, error) {
+	var spec info.ContainerSpec
+	mi, err := self.machineInfoFactory.GetMachineInfo()
+	if err != nil {
+		return spec, err
+	}
+	spec.HasCpu = true
+	spec.Cpu.Limit = 1024
+	spec.Cpu.Mask = fmt.Sprintf("0-%d", mi.NumCores-1)
+	spec.HasMemory = true
+	spec.Memory.Limit = uint64(mi.MemoryCapacity)
+	return spec, nil
+}
+
+func (self *hcsRootHandler) GetStats() (*info.ContainerStats, error) {
+	stats, err := self.factory.systems.host()
+	if err != nil {
+		return nil, err
+	}
+	return stats.containerStats(), nil
+}
+
+// Windows containers are not nested, the running ones are listed whatever the list type.
+func (self *hcsRootHandler) ListContainers(listType container.ListType) ([]info.ContainerReference, error) {
+	containers, err := self.factory.refresh()
+	if err != nil {
+		return nil, err
+	}
+	refs := make([]info.ContainerReference, 0, len(containers))
+	for name := range containers {
+		refs = append(refs, info.ContainerReference{Name: name})
+	}
+	return refs, nil
+}
+
+func (self *hcsRootHandler) ListThreads(listType container.ListType) ([]int, error) {
+	return nil, nil
+}
+
+func (self *hcsRootHandler) ListProcesses(listType container.ListType) ([]int, error) {
+	return nil, nil
+}
+
+func (self *hcsRootHandler) GetCgroupPath(subsystem string) (string, error) {
+	return "", fmt.Errorf("the root container has no cgroups on Windows")
+}
+
+func (self *hcsRootHandler) GetContainerIPAddress() string {
+	return "localhost"
+}
+
+// The factory reports the containers started and stopped, see WatchContainers.
+func (self *hcsRootHandler) WatchSubcontainers(events chan container.SubcontainerEvent) error {
+	return nil
+}
+
+func (self *hcsRootHandler) StopWatchingSubcontainers() error {
+	return nil
+}
+
+func (self *hcsRootHandler) Exists() bool {
+	return true
+}
@@ -0,0 +1,83 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package libcontainer
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/docker/libcontainer/cgroups"
+)
+
+// Reads the throttling stats of the blkio cgroup: the I/O of the cgroup is counted there for all
+// devices, including the ones whose scheduler does not count it in the recursive CFQ stats.
+func getThrottleStats(blkioPath string) (serviceBytes, serviced []cgroups.BlkioStatEntry, err error) {
+	serviceBytes, err = readBlkioStats(path.Join(blkioPath, "blkio.throttle.io_service_bytes"))
+	if err != nil {
+		return nil, nil, err
+	}
+	serviced, err = readBlkioStats(path.Join(blkioPath, "blkio.throttle.io_serviced"))
+	if err != nil {
+		return nil, nil, err
+	}
+	return serviceBytes, serviced, nil
+}
+
+// Reads a blkio stats file: "<major>:<minor> <operation> <value>" lines and a total line. Missing
+// files have no stats.
+func readBlkioStats(file string) ([]cgroups.BlkioStatEntry, error) {
+	out, err := readCgroupFile(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []cgroups.BlkioStatEntry
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "Total" {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("invalid line %q in %q", scanner.Text(), file)
+		}
+		device := strings.Split(fields[0], ":")
+		if len(device) != 2 {
+			return nil, fmt.Errorf("invalid device %q in %q", fields[0], file)
+		}
+		major, err := strconv.ParseUint(device[0], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		minor, err := strconv.ParseUint(device[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		value, err := strconv.ParseUint(fields[2], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, cgroups.BlkioStatEntry{Major: major, Minor: minor, Op: fields[1], Value: value})
+	}
+	return ret, scanner.Err()
+}
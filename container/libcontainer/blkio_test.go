@@ -0,0 +1,71 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package libcontainer
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/docker/libcontainer/cgroups"
+)
+
+func TestGetThrottleStats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blkio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Without the throttling stats, e.g. when the blkio cgroup is not mounted.
+	serviceBytes, serviced, err := getThrottleStats(dir)
+	if err != nil || serviceBytes != nil || serviced != nil {
+		t.Errorf("expected no stats, got %v %v (%v)", serviceBytes, serviced, err)
+	}
+
+	err = ioutil.WriteFile(path.Join(dir, "blkio.throttle.io_service_bytes"), []byte("8:0 Read 4096\n8:0 Write 8192\n259:0 Read 512\nTotal 12800\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(path.Join(dir, "blkio.throttle.io_serviced"), []byte("8:0 Read 1\nTotal 1\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serviceBytes, serviced, err = getThrottleStats(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []cgroups.BlkioStatEntry{
+		{Major: 8, Minor: 0, Op: "Read", Value: 4096},
+		{Major: 8, Minor: 0, Op: "Write", Value: 8192},
+		{Major: 259, Minor: 0, Op: "Read", Value: 512},
+	}
+	if !reflect.DeepEqual(serviceBytes, expected) {
+		t.Errorf("expected %v, got %v", expected, serviceBytes)
+	}
+	if len(serviced) != 1 || serviced[0].Value != 1 {
+		t.Errorf("unexpected serviced stats: %v", serviced)
+	}
+
+	err = ioutil.WriteFile(path.Join(dir, "blkio.throttle.io_serviced"), []byte("8 Read 1\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := getThrottleStats(dir); err == nil {
+		t.Error("expected an invalid device to fail")
+	}
+}
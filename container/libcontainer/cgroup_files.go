@@ -0,0 +1,154 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package libcontainer
+
+import (
+	"flag"
+	"io/ioutil"
+	"math"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+var maxOpenCgroupFiles = flag.Int("max_open_cgroup_files", -1, "Maximum number of cgroup files kept open to be read again at every housekeeping without opening them, at most half the soft limit of open files of cAdvisor (RLIMIT_NOFILE). -1 uses that half, 0 disables")
+
+const (
+	// Cgroup files not read for that long are closed, their cgroup was likely removed.
+	openCgroupFileAge = 5 * time.Minute
+	// Limit of the open cgroup files when the limit of open files cannot be read, half the usual
+	// soft limit.
+	defaultOpenCgroupFilesLimit = 512
+)
+
+// Half the soft limit of open files, leaving the other half to the rest of cAdvisor.
+var openCgroupFilesRlimit = struct {
+	once  sync.Once
+	limit int
+}{}
+
+// Returns the maximum number of cgroup files kept open, --max_open_cgroup_files lowered to half the
+// soft limit of open files.
+func openCgroupFilesLimit() int {
+	openCgroupFilesRlimit.once.Do(func() {
+		openCgroupFilesRlimit.limit = defaultOpenCgroupFilesLimit
+		var rlimit syscall.Rlimit
+		if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rlimit); err != nil {
+			glog.Warningf("Failed to get the limit of open files, keeping at most %d cgroup files open: %v", defaultOpenCgroupFilesLimit, err)
+		} else if rlimit.Cur/2 < math.MaxInt32 {
+			openCgroupFilesRlimit.limit = int(rlimit.Cur / 2)
+		} else {
+			openCgroupFilesRlimit.limit = math.MaxInt32
+		}
+		if *maxOpenCgroupFiles > openCgroupFilesRlimit.limit {
+			glog.Warningf("--max_open_cgroup_files=%d is above half the limit of open files, keeping at most %d cgroup files open", *maxOpenCgroupFiles, openCgroupFilesRlimit.limit)
+		}
+	})
+	if *maxOpenCgroupFiles < 0 || *maxOpenCgroupFiles > openCgroupFilesRlimit.limit {
+		return openCgroupFilesRlimit.limit
+	}
+	return *maxOpenCgroupFiles
+}
+
+// A cgroup file kept open.
+type openCgroupFile struct {
+	lock     sync.Mutex
+	file     *os.File
+	lastRead time.Time
+}
+
+// The cgroup files kept open, by path.
+var openCgroupFiles = struct {
+	lock      sync.Mutex
+	files     map[string]*openCgroupFile
+	lastSweep time.Time
+}{files: make(map[string]*openCgroupFile)}
+
+// Reads the cgroup file at name. The file is kept open and read again from its start the next
+// times, which saves the lookup of its path and the open and close syscalls.
+func readCgroupFile(name string) ([]byte, error) {
+	f, err := getCgroupFile(name)
+	if err != nil {
+		return nil, err
+	}
+	if f == nil {
+		return ioutil.ReadFile(name)
+	}
+	out, err := f.read()
+	if err != nil {
+		// The cgroup may have been removed, its file is opened again next time.
+		closeCgroupFile(name, f)
+		return nil, err
+	}
+	return out, nil
+}
+
+func (self *openCgroupFile) read() ([]byte, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.lastRead = time.Now()
+	if _, err := self.file.Seek(0, os.SEEK_SET); err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(self.file)
+}
+
+// Returns the open cgroup file at name, nil if no more files are kept open.
+func getCgroupFile(name string) (*openCgroupFile, error) {
+	openCgroupFiles.lock.Lock()
+	defer openCgroupFiles.lock.Unlock()
+	if time.Since(openCgroupFiles.lastSweep) > openCgroupFileAge {
+		sweepCgroupFiles()
+	}
+	if f, ok := openCgroupFiles.files[name]; ok {
+		return f, nil
+	}
+	if len(openCgroupFiles.files) >= openCgroupFilesLimit() {
+		return nil, nil
+	}
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	f := &openCgroupFile{file: file, lastRead: time.Now()}
+	openCgroupFiles.files[name] = f
+	return f, nil
+}
+
+func closeCgroupFile(name string, f *openCgroupFile) {
+	openCgroupFiles.lock.Lock()
+	defer openCgroupFiles.lock.Unlock()
+	if openCgroupFiles.files[name] == f {
+		delete(openCgroupFiles.files, name)
+	}
+	f.file.Close()
+}
+
+// Closes the cgroup files not read recently, must hold the lock of openCgroupFiles. The lock of a
+// file is never held while taking the one of openCgroupFiles.
+func sweepCgroupFiles() {
+	openCgroupFiles.lastSweep = time.Now()
+	for name, f := range openCgroupFiles.files {
+		f.lock.Lock()
+		if time.Since(f.lastRead) > openCgroupFileAge {
+			delete(openCgroupFiles.files, name)
+			f.file.Close()
+		}
+		f.lock.Unlock()
+	}
+}
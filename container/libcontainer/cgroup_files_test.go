@@ -0,0 +1,91 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package libcontainer
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"syscall"
+	"testing"
+)
+
+func TestReadCgroupFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cgroup_files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err := readCgroupFile(path.Join(dir, "missing")); !os.IsNotExist(err) {
+		t.Errorf("expected a missing file not to exist, got %v", err)
+	}
+
+	// The file kept open is read again from its start.
+	name := path.Join(dir, "memory.oom_control")
+	for _, value := range []string{"oom_kill 1\n", "oom_kill 12\n", "oom_kill 3\n"} {
+		if err := ioutil.WriteFile(name, []byte(value), 0644); err != nil {
+			t.Fatal(err)
+		}
+		out, err := readCgroupFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(out) != value {
+			t.Errorf("expected %q, got %q", value, out)
+		}
+	}
+	if _, ok := openCgroupFiles.files[name]; !ok {
+		t.Errorf("%q not kept open", name)
+	}
+
+	// Files are read without being kept open past the limit.
+	defer func(max int) { *maxOpenCgroupFiles = max }(*maxOpenCgroupFiles)
+	*maxOpenCgroupFiles = 0
+	other := path.Join(dir, "pids.max")
+	if err := ioutil.WriteFile(other, []byte("max\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := readCgroupFile(other); err != nil || string(out) != "max\n" {
+		t.Errorf("expected %q, got %q (%v)", "max\n", out, err)
+	}
+	if _, ok := openCgroupFiles.files[other]; ok {
+		t.Errorf("%q kept open past the limit", other)
+	}
+}
+
+func TestOpenCgroupFilesLimit(t *testing.T) {
+	var rlimit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rlimit); err != nil {
+		t.Skipf("cannot get the limit of open files: %v", err)
+	}
+	half := int(rlimit.Cur / 2)
+
+	defer func(max int) { *maxOpenCgroupFiles = max }(*maxOpenCgroupFiles)
+	for _, test := range []struct {
+		flag     int
+		expected int
+	}{
+		{-1, half},
+		{0, 0},
+		{half - 1, half - 1},
+		{half + 1, half},
+	} {
+		*maxOpenCgroupFiles = test.flag
+		if limit := openCgroupFilesLimit(); limit != test.expected {
+			t.Errorf("expected a limit of %d for --max_open_cgroup_files=%d, got %d", test.expected, test.flag, limit)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package libcontainer
+
+import (
+	"os"
+	"path"
+	"strings"
+
+	"github.com/google/cadvisor/info"
+)
+
+// Reads the CPUs and memory nodes of the cpuset cgroup at cpusetPath into spec. The effective CPUs,
+// those the container can actually run on, are only known to kernels with cpuset.effective_cpus
+// and are the requested ones otherwise.
+func ReadCpuset(cpusetPath string, spec *info.CpuSpec) error {
+	cpus, err := readCpusetFile(cpusetPath, "cpuset.cpus")
+	if err != nil {
+		return err
+	}
+	mems, err := readCpusetFile(cpusetPath, "cpuset.mems")
+	if err != nil {
+		return err
+	}
+	effectiveCpus, err := readCpusetFile(cpusetPath, "cpuset.effective_cpus")
+	if os.IsNotExist(err) {
+		effectiveCpus, err = cpus, nil
+	}
+	if err != nil {
+		return err
+	}
+	spec.Mask = cpus
+	spec.Mems = mems
+	spec.EffectiveCpus = effectiveCpus
+	return nil
+}
+
+func readCpusetFile(cpusetPath, file string) (string, error) {
+	out, err := readCgroupFile(path.Join(cpusetPath, file))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
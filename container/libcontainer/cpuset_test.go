@@ -0,0 +1,60 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package libcontainer
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/google/cadvisor/info"
+)
+
+func TestReadCpuset(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cpuset")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for file, content := range map[string]string{
+		"cpuset.cpus": "0-3,8\n",
+		"cpuset.mems": "0\n",
+	} {
+		if err := ioutil.WriteFile(path.Join(dir, file), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var spec info.CpuSpec
+	if err := ReadCpuset(dir, &spec); err != nil {
+		t.Fatal(err)
+	}
+	expected := info.CpuSpec{Mask: "0-3,8", Mems: "0", EffectiveCpus: "0-3,8"}
+	if spec != expected {
+		t.Errorf("expected %+v, got %+v", expected, spec)
+	}
+
+	// CPU 8 went offline.
+	if err := ioutil.WriteFile(path.Join(dir, "cpuset.effective_cpus"), []byte("0-3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReadCpuset(dir, &spec); err != nil {
+		t.Fatal(err)
+	}
+	if spec.EffectiveCpus != "0-3" {
+		t.Errorf("expected the effective CPUs 0-3, got %q", spec.EffectiveCpus)
+	}
+}
@@ -16,12 +16,18 @@ package libcontainer
 
 import (
 	"fmt"
+	"io/ioutil"
+	"path"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/docker/libcontainer"
 	"github.com/docker/libcontainer/cgroups"
 	cgroupfs "github.com/docker/libcontainer/cgroups/fs"
 	"github.com/docker/libcontainer/network"
+	"github.com/google/cadvisor/accelerators"
+	"github.com/google/cadvisor/container"
 	"github.com/google/cadvisor/info"
 )
 
@@ -58,6 +64,11 @@ func GetCgroupSubsystems() (CgroupSubsystems, error) {
 		}
 	}
 
+	unifiedMountpoint = findUnifiedMountpoint()
+	if unifiedMountpoint != "" {
+		mountPoints["unified"] = unifiedMountpoint
+	}
+
 	return CgroupSubsystems{
 		Mounts:      supportedCgroups,
 		MountPoints: mountPoints,
@@ -66,29 +77,135 @@ func GetCgroupSubsystems() (CgroupSubsystems, error) {
 
 // Cgroup subsystems we support listing (should be the minimal set we need stats from).
 var supportedSubsystems map[string]struct{} = map[string]struct{}{
-	"cpu":     {},
-	"cpuacct": {},
-	"memory":  {},
-	"cpuset":  {},
+	"cpu":        {},
+	"cpuacct":    {},
+	"memory":     {},
+	"cpuset":     {},
+	"blkio":      {},
+	"hugetlb":    {},
+	"devices":    {},
+	"perf_event": {},
+	"pids":       {},
 }
 
 // Get stats of the specified container
-func GetStats(state *libcontainer.State) (*info.ContainerStats, error) {
+func GetStats(name string, state *libcontainer.State) (*info.ContainerStats, error) {
 	// TODO(vmarmol): Use libcontainer's Stats() in the new API when that is ready.
 	stats := &libcontainer.ContainerStats{}
 
+	// Subsystems of the disabled metrics are not read.
+	cgroupPaths := state.CgroupPaths
+	if !container.HasMetric(container.DiskIoMetrics) {
+		cgroupPaths = make(map[string]string, len(state.CgroupPaths))
+		for subsystem, cgroupPath := range state.CgroupPaths {
+			if subsystem != "blkio" {
+				cgroupPaths[subsystem] = cgroupPath
+			}
+		}
+	}
+
 	var err error
-	stats.CgroupStats, err = cgroupfs.GetStats(state.CgroupPaths)
+	stats.CgroupStats, err = cgroupfs.GetStats(cgroupPaths)
 	if err != nil {
 		return &info.ContainerStats{}, err
 	}
 
-	stats.NetworkStats, err = network.GetStats(&state.NetworkState)
-	if err != nil {
-		return &info.ContainerStats{}, err
+	// Without the host side of its veth pair, the interfaces of the network namespace of the
+	// container are read from one of its processes, which may have exited meanwhile.
+	if container.CollectsMetric(name, container.NetworkUsageMetrics) {
+		if state.NetworkState.VethHost == "" && state.InitPid != 0 {
+			stats.NetworkStats, err = namespaceNetworkStats(state.InitPid)
+			collectFailed(name, container.NetworkUsageMetrics, err)
+		} else {
+			stats.NetworkStats, err = network.GetStats(&state.NetworkState)
+			if collectFailed(name, container.NetworkUsageMetrics, err) {
+				return &info.ContainerStats{}, err
+			}
+		}
+	}
+
+	ret := toContainerStats(stats)
+	if !container.HasMetric(container.PerCpuUsageMetrics) {
+		ret.Cpu.Usage.PerCpu = nil
+	}
+	if blkioPath, ok := cgroupPaths["blkio"]; ok {
+		serviceBytes, serviced, err := getThrottleStats(blkioPath)
+		if err != nil {
+			return &info.ContainerStats{}, err
+		}
+		ret.DiskIo.ThrottleServiceBytes = DiskStatsCopy(serviceBytes)
+		ret.DiskIo.ThrottleServiced = DiskStatsCopy(serviced)
+	}
+	if memoryPath, ok := state.CgroupPaths["memory"]; ok {
+		if container.CollectsMetric(name, container.MemoryNumaMetrics) {
+			ret.Memory.NumaStats, err = getNumaStats(memoryPath)
+			if collectFailed(name, container.MemoryNumaMetrics, err) {
+				return &info.ContainerStats{}, err
+			}
+		}
+		ret.Memory.OomKills, err = getOomKills(memoryPath)
+		if err != nil {
+			return &info.ContainerStats{}, err
+		}
+	}
+	if devicesPath, ok := state.CgroupPaths["devices"]; ok && container.CollectsMetric(name, container.AcceleratorMetrics) {
+		ret.Accelerators, err = accelerators.GetStats(devicesPath)
+		if collectFailed(name, container.AcceleratorMetrics, err) {
+			return &info.ContainerStats{}, err
+		}
+	}
+	if hugetlbPath, ok := state.CgroupPaths["hugetlb"]; ok && container.CollectsMetric(name, container.HugetlbMetrics) {
+		ret.Hugetlb, err = getHugetlbStats(hugetlbPath)
+		if collectFailed(name, container.HugetlbMetrics, err) {
+			return &info.ContainerStats{}, err
+		}
 	}
+	if unifiedPath, ok := state.CgroupPaths["unified"]; ok && container.CollectsMetric(name, container.PressureMetrics) {
+		if err := getPsiStats(unifiedPath, ret); collectFailed(name, container.PressureMetrics, err) {
+			return &info.ContainerStats{}, err
+		}
+	}
+	if cpuPath, ok := state.CgroupPaths["cpu"]; ok && container.CollectsMetric(name, container.ProcessMetrics) {
+		ret.Processes, err = getProcessStats(cpuPath, state.CgroupPaths["pids"], container.HasMetric(container.FdMetrics))
+		if collectFailed(name, container.ProcessMetrics, err) {
+			return &info.ContainerStats{}, err
+		}
+	}
+	if cpuPath, ok := state.CgroupPaths["cpu"]; ok && container.CollectsMetric(name, container.ContextSwitchMetrics) {
+		ret.Cpu.ContextSwitches, err = getContextSwitches(cpuPath)
+		if collectFailed(name, container.ContextSwitchMetrics, err) {
+			return &info.ContainerStats{}, err
+		}
+	}
+	return ret, nil
+}
 
-	return toContainerStats(stats), nil
+// Returns whether the error of the collection of a kind of metrics of the container fails the
+// collection of its stats: when access is denied, the metrics are skipped instead.
+func collectFailed(name string, kind container.MetricKind, err error) bool {
+	if err == nil {
+		container.AllowMetric(name, kind)
+		return false
+	}
+	return !container.BlockDeniedMetric(name, kind, err)
+}
+
+// Returns the threads of the cgroup at the specified path.
+func GetThreads(cgroupPath string) ([]int, error) {
+	out, err := ioutil.ReadFile(path.Join(cgroupPath, "tasks"))
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(string(out))
+	threads := make([]int, 0, len(fields))
+	for _, field := range fields {
+		tid, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid thread %q in the tasks of %q: %v", field, cgroupPath, err)
+		}
+		threads = append(threads, tid)
+	}
+	return threads, nil
 }
 
 func DiskStatsCopy(blkio_stats []cgroups.BlkioStatEntry) (stat []info.PerDiskStats) {
@@ -132,6 +249,30 @@ func DiskStatsCopy(blkio_stats []cgroups.BlkioStatEntry) (stat []info.PerDiskSta
 	return
 }
 
+// Converts the usage and memory.stat of the memory cgroup. The total_ stats of memory.stat are the
+// ones of the container and its subcontainers, like the usage.
+func toMemoryStats(s *cgroups.MemoryStats, ret *info.MemoryStats) {
+	ret.Usage = s.Usage
+	ret.Failcnt = s.Failcnt
+	ret.ContainerData.Pgfault = s.Stats["pgfault"]
+	ret.ContainerData.Pgmajfault = s.Stats["pgmajfault"]
+	ret.HierarchicalData.Pgfault = s.Stats["total_pgfault"]
+	ret.HierarchicalData.Pgmajfault = s.Stats["total_pgmajfault"]
+
+	ret.WorkingSet = ret.Usage
+	if v := s.Stats["total_inactive_file"]; v < ret.WorkingSet {
+		ret.WorkingSet -= v
+	} else {
+		ret.WorkingSet = 0
+	}
+	ret.Cache = s.Stats["total_cache"]
+	ret.RSS = s.Stats["total_rss"]
+	ret.Swap = s.Stats["total_swap"]
+	ret.MappedFile = s.Stats["total_mapped_file"]
+	ret.HierarchicalLimit = s.Stats["hierarchical_memory_limit"]
+	ret.HierarchicalSwapLimit = s.Stats["hierarchical_memsw_limit"]
+}
+
 // Convert libcontainer stats to info.ContainerStats.
 func toContainerStats(libcontainerStats *libcontainer.ContainerStats) *info.ContainerStats {
 	s := libcontainerStats.CgroupStats
@@ -149,6 +290,9 @@ func toContainerStats(libcontainerStats *libcontainer.ContainerStats) *info.Cont
 			ret.Cpu.Usage.PerCpu[i] = s.CpuStats.CpuUsage.PercpuUsage[i]
 			ret.Cpu.Usage.Total += s.CpuStats.CpuUsage.PercpuUsage[i]
 		}
+		ret.Cpu.CFS.Periods = s.CpuStats.ThrottlingData.Periods
+		ret.Cpu.CFS.ThrottledPeriods = s.CpuStats.ThrottlingData.ThrottledPeriods
+		ret.Cpu.CFS.ThrottledTime = s.CpuStats.ThrottlingData.ThrottledTime
 
 		ret.DiskIo.IoServiceBytes = DiskStatsCopy(s.BlkioStats.IoServiceBytesRecursive)
 		ret.DiskIo.IoServiced = DiskStatsCopy(s.BlkioStats.IoServicedRecursive)
@@ -159,21 +303,7 @@ func toContainerStats(libcontainerStats *libcontainer.ContainerStats) *info.Cont
 		ret.DiskIo.IoMerged = DiskStatsCopy(s.BlkioStats.IoMergedRecursive)
 		ret.DiskIo.IoTime = DiskStatsCopy(s.BlkioStats.IoTimeRecursive)
 
-		ret.Memory.Usage = s.MemoryStats.Usage
-		if v, ok := s.MemoryStats.Stats["pgfault"]; ok {
-			ret.Memory.ContainerData.Pgfault = v
-			ret.Memory.HierarchicalData.Pgfault = v
-		}
-		if v, ok := s.MemoryStats.Stats["pgmajfault"]; ok {
-			ret.Memory.ContainerData.Pgmajfault = v
-			ret.Memory.HierarchicalData.Pgmajfault = v
-		}
-		if v, ok := s.MemoryStats.Stats["total_inactive_anon"]; ok {
-			ret.Memory.WorkingSet = ret.Memory.Usage - v
-			if v, ok := s.MemoryStats.Stats["total_active_file"]; ok {
-				ret.Memory.WorkingSet -= v
-			}
-		}
+		toMemoryStats(&s.MemoryStats, &ret.Memory)
 	}
 	// TODO(vishh): Perform a deep copy or alias libcontainer network stats.
 	if libcontainerStats.NetworkStats != nil {
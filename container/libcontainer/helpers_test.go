@@ -0,0 +1,141 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package libcontainer
+
+import (
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/docker/libcontainer"
+	"github.com/docker/libcontainer/cgroups"
+	"github.com/google/cadvisor/container"
+	"github.com/google/cadvisor/info"
+)
+
+func TestToMemoryStats(t *testing.T) {
+	s := &cgroups.MemoryStats{
+		Usage:   10000,
+		Failcnt: 7,
+		Stats: map[string]uint64{
+			"pgfault":                   10,
+			"pgmajfault":                1,
+			"total_pgfault":             30,
+			"total_pgmajfault":          3,
+			"total_inactive_file":       4000,
+			"total_cache":               6000,
+			"total_rss":                 3500,
+			"total_swap":                100,
+			"total_mapped_file":         500,
+			"hierarchical_memory_limit": 1 << 30,
+			"hierarchical_memsw_limit":  2 << 30,
+		},
+	}
+	var stats info.MemoryStats
+	toMemoryStats(s, &stats)
+	expected := info.MemoryStats{
+		Usage:                 10000,
+		WorkingSet:            6000,
+		Cache:                 6000,
+		RSS:                   3500,
+		Swap:                  100,
+		MappedFile:            500,
+		HierarchicalLimit:     1 << 30,
+		HierarchicalSwapLimit: 2 << 30,
+		Failcnt:               7,
+		ContainerData:         info.MemoryStatsMemoryData{Pgfault: 10, Pgmajfault: 1},
+		HierarchicalData:      info.MemoryStatsMemoryData{Pgfault: 30, Pgmajfault: 3},
+	}
+	if !reflect.DeepEqual(stats, expected) {
+		t.Errorf("expected %+v, got %+v", expected, stats)
+	}
+
+	// The usage and memory.stat are not read at once, the working set does not underflow.
+	s.Stats["total_inactive_file"] = 20000
+	toMemoryStats(s, &stats)
+	if stats.WorkingSet != 0 {
+		t.Errorf("expected no working set, got %d", stats.WorkingSet)
+	}
+}
+
+// Returns the state of the cgroups of the benchmark, whose stats are read like those of a container.
+func benchmarkState(b *testing.B) *libcontainer.State {
+	subsystems, err := GetCgroupSubsystems()
+	if err != nil {
+		b.Skipf("no cgroups: %v", err)
+	}
+	state := &libcontainer.State{CgroupPaths: make(map[string]string, len(subsystems.MountPoints))}
+	for subsystem, mountPoint := range subsystems.MountPoints {
+		dir, err := cgroups.GetThisCgroupDir(subsystem)
+		if err != nil {
+			dir = "/"
+		}
+		state.CgroupPaths[subsystem] = path.Join(mountPoint, dir)
+	}
+	return state
+}
+
+// The stats of a container are read from its cgroup files only, the target is under 100µs. The
+// process, process_fds and context_switches metrics read the stats of each process or thread of the
+// cgroup, they are benchmarked apart.
+func BenchmarkGetStats(b *testing.B) {
+	metrics := container.Metrics()
+	defer container.SetMetrics(metrics)
+	withoutProcesses := container.MetricSet{}
+	for kind := range metrics {
+		if kind != container.ProcessMetrics && kind != container.FdMetrics && kind != container.ContextSwitchMetrics {
+			withoutProcesses[kind] = struct{}{}
+		}
+	}
+	container.SetMetrics(withoutProcesses)
+	benchmarkGetStats(b)
+}
+
+func BenchmarkGetStatsWithProcesses(b *testing.B) {
+	metrics := container.Metrics()
+	defer container.SetMetrics(metrics)
+	metrics[container.ProcessMetrics] = struct{}{}
+	metrics[container.FdMetrics] = struct{}{}
+	metrics[container.ContextSwitchMetrics] = struct{}{}
+	container.SetMetrics(metrics)
+	benchmarkGetStats(b)
+}
+
+func benchmarkGetStats(b *testing.B) {
+	state := benchmarkState(b)
+	if _, err := GetStats("/", state); err != nil {
+		b.Skipf("cannot read the stats of the cgroups: %v", err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := GetStats("/", state); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetThreads(b *testing.B) {
+	state := benchmarkState(b)
+	cpuPath, ok := state.CgroupPaths["cpu"]
+	if !ok {
+		b.Skip("no cpu cgroup")
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := GetThreads(cpuPath); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
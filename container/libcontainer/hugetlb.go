@@ -0,0 +1,66 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package libcontainer
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/google/cadvisor/info"
+)
+
+// Reads the usage of the hugetlb cgroup, keyed by page size (e.g.: 2MB, 1GB).
+// e.g.: hugetlb.2MB.usage_in_bytes
+func getHugetlbStats(hugetlbPath string) (map[string]info.HugetlbStats, error) {
+	entries, err := ioutil.ReadDir(hugetlbPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var ret map[string]info.HugetlbStats
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, "hugetlb.") || !strings.HasSuffix(name, ".usage_in_bytes") {
+			continue
+		}
+		pageSize := strings.TrimSuffix(strings.TrimPrefix(name, "hugetlb."), ".usage_in_bytes")
+		prefix := path.Join(hugetlbPath, "hugetlb."+pageSize)
+		var stats info.HugetlbStats
+		for file, value := range map[string]*uint64{
+			".usage_in_bytes":     &stats.Usage,
+			".max_usage_in_bytes": &stats.MaxUsage,
+			".limit_in_bytes":     &stats.Limit,
+			".failcnt":            &stats.Failcnt,
+		} {
+			out, err := ioutil.ReadFile(prefix + file)
+			if err != nil {
+				return nil, err
+			}
+			if *value, err = strconv.ParseUint(strings.TrimSpace(string(out)), 10, 64); err != nil {
+				return nil, err
+			}
+		}
+		if ret == nil {
+			ret = make(map[string]info.HugetlbStats)
+		}
+		ret[pageSize] = stats
+	}
+	return ret, nil
+}
@@ -0,0 +1,68 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package libcontainer
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/google/cadvisor/info"
+)
+
+func TestGetHugetlbStats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hugetlb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	stats, err := getHugetlbStats(path.Join(dir, "missing"))
+	if err != nil || stats != nil {
+		t.Errorf("expected no stats without the hugetlb cgroup, got %v (%v)", stats, err)
+	}
+
+	for file, value := range map[string]string{
+		"hugetlb.2MB.usage_in_bytes":     "4194304\n",
+		"hugetlb.2MB.max_usage_in_bytes": "8388608\n",
+		"hugetlb.2MB.limit_in_bytes":     "18446744073709551615\n",
+		"hugetlb.2MB.failcnt":            "3\n",
+		"cgroup.procs":                   "1\n",
+	} {
+		if err := ioutil.WriteFile(path.Join(dir, file), []byte(value), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stats, err = getHugetlbStats(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]info.HugetlbStats{
+		"2MB": {Usage: 4194304, MaxUsage: 8388608, Limit: 18446744073709551615, Failcnt: 3},
+	}
+	if !reflect.DeepEqual(stats, expected) {
+		t.Errorf("expected %+v, got %+v", expected, stats)
+	}
+
+	// Every file of a page size is required.
+	if err := os.Remove(path.Join(dir, "hugetlb.2MB.failcnt")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := getHugetlbStats(dir); err == nil {
+		t.Error("expected a missing failcnt to fail")
+	}
+}
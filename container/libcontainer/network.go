@@ -0,0 +1,96 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package libcontainer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/docker/libcontainer/network"
+)
+
+// Mount point of procfs, overridden in tests.
+var procRoot = "/proc"
+
+// Returns whether the process is in another network namespace than cAdvisor.
+func HasNetworkNamespace(pid int) (bool, error) {
+	processNs, err := os.Readlink(path.Join(procRoot, strconv.Itoa(pid), "ns/net"))
+	if err != nil {
+		return false, err
+	}
+	selfNs, err := os.Readlink(path.Join(procRoot, "self/ns/net"))
+	if err != nil {
+		return false, err
+	}
+	return processNs != selfNs, nil
+}
+
+// Returns the network stats of the network namespace of the process, summed over its interfaces
+// but the loopback one. The stats are nil if the process is in the network namespace of cAdvisor,
+// whose interfaces are not the ones of a container.
+func namespaceNetworkStats(pid int) (*network.NetworkStats, error) {
+	if namespaced, err := HasNetworkNamespace(pid); !namespaced {
+		return nil, err
+	}
+	file, err := os.Open(path.Join(procRoot, strconv.Itoa(pid), "net/dev"))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return parseNetDev(file)
+}
+
+// Sums the stats of the interfaces of /proc/<pid>/net/dev but the loopback one.
+func parseNetDev(r io.Reader) (*network.NetworkStats, error) {
+	stats := &network.NetworkStats{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		i := strings.Index(line, ":")
+		// The first two lines are headers, without an interface.
+		if i < 0 || strings.Contains(line, "|") {
+			continue
+		}
+		if strings.TrimSpace(line[:i]) == "lo" {
+			continue
+		}
+		fields := strings.Fields(line[i+1:])
+		if len(fields) < 16 {
+			return nil, fmt.Errorf("invalid network interface stats %q", line)
+		}
+		values := make([]uint64, len(fields))
+		for j, field := range fields {
+			value, err := strconv.ParseUint(field, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid network interface stats %q: %v", line, err)
+			}
+			values[j] = value
+		}
+		stats.RxBytes += values[0]
+		stats.RxPackets += values[1]
+		stats.RxErrors += values[2]
+		stats.RxDropped += values[3]
+		stats.TxBytes += values[8]
+		stats.TxPackets += values[9]
+		stats.TxErrors += values[10]
+		stats.TxDropped += values[11]
+	}
+	return stats, scanner.Err()
+}
@@ -0,0 +1,85 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package libcontainer
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+const testNetDev = `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo:    1000      10    0    0    0     0          0         0     1000      10    0    0    0     0       0          0
+  eth0:    2000      20    1    2    0     0          0         0     3000      30    3    4    0     0       0          0
+  eth1:     500       5    0    1    0     0          0         0      500       5    0    0    0     0       0          0
+`
+
+func TestParseNetDev(t *testing.T) {
+	stats, err := parseNetDev(strings.NewReader(testNetDev))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.RxBytes != 2500 || stats.RxPackets != 25 || stats.RxErrors != 1 || stats.RxDropped != 3 {
+		t.Errorf("unexpected receive stats: %+v", stats)
+	}
+	if stats.TxBytes != 3500 || stats.TxPackets != 35 || stats.TxErrors != 3 || stats.TxDropped != 4 {
+		t.Errorf("unexpected transmit stats: %+v", stats)
+	}
+	if _, err := parseNetDev(strings.NewReader("eth0: 1 2 3\n")); err == nil {
+		t.Error("expected truncated stats to fail")
+	}
+}
+
+func TestNamespaceNetworkStats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "proc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(root string) { procRoot = root }(procRoot)
+	procRoot = dir
+	for _, pid := range []string{"self", "1", "2"} {
+		if err := os.MkdirAll(path.Join(dir, pid, "ns"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.MkdirAll(path.Join(dir, pid, "net"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path.Join(dir, pid, "net/dev"), []byte(testNetDev), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.Symlink("net:[1]", path.Join(dir, "self/ns/net"))
+	os.Symlink("net:[1]", path.Join(dir, "1/ns/net"))
+	os.Symlink("net:[2]", path.Join(dir, "2/ns/net"))
+
+	// The interfaces of the namespace of cAdvisor are not the ones of the container.
+	if stats, err := namespaceNetworkStats(1); err != nil || stats != nil {
+		t.Errorf("expected no stats in the namespace of cAdvisor, got %+v (%v)", stats, err)
+	}
+	stats, err := namespaceNetworkStats(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats == nil || stats.RxBytes != 2500 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	if _, err := namespaceNetworkStats(3); err == nil {
+		t.Error("expected a missing process to fail")
+	}
+}
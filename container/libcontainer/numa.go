@@ -0,0 +1,76 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package libcontainer
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/google/cadvisor/info"
+)
+
+// Reads the pages of the memory cgroup on each NUMA node from memory.numa_stat.
+// The hierarchical counters, which include the subcontainers, are preferred when available.
+// e.g.: file=120 N0=100 N1=20
+func getNumaStats(memoryPath string) (info.MemoryNumaStats, error) {
+	ret := info.MemoryNumaStats{}
+	out, err := readCgroupFile(path.Join(memoryPath, "memory.numa_stat"))
+	if os.IsNotExist(err) {
+		return ret, nil
+	}
+	if err != nil {
+		return ret, err
+	}
+
+	counters := map[string]map[uint8]uint64{}
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		// The first field is the total of all nodes.
+		name := strings.SplitN(fields[0], "=", 2)[0]
+		nodes := make(map[uint8]uint64, len(fields)-1)
+		for _, field := range fields[1:] {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 || !strings.HasPrefix(kv[0], "N") {
+				return ret, fmt.Errorf("invalid memory.numa_stat field %q", field)
+			}
+			node, err := strconv.ParseUint(kv[0][1:], 10, 8)
+			if err != nil {
+				return ret, err
+			}
+			pages, err := strconv.ParseUint(kv[1], 10, 64)
+			if err != nil {
+				return ret, err
+			}
+			nodes[uint8(node)] = pages
+		}
+		counters[name] = nodes
+	}
+	pick := func(name string) map[uint8]uint64 {
+		if nodes, ok := counters["hierarchical_"+name]; ok {
+			return nodes
+		}
+		return counters[name]
+	}
+	ret.File = pick("file")
+	ret.Anon = pick("anon")
+	ret.Unevictable = pick("unevictable")
+	return ret, nil
+}
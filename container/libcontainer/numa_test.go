@@ -0,0 +1,71 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package libcontainer
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/google/cadvisor/info"
+)
+
+func TestGetNumaStats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "numa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Kernels without NUMA support have no memory.numa_stat.
+	stats, err := getNumaStats(dir)
+	if err != nil || !reflect.DeepEqual(stats, info.MemoryNumaStats{}) {
+		t.Errorf("expected no stats, got %+v (%v)", stats, err)
+	}
+
+	numaStat := `total=300 N0=200 N1=100
+file=120 N0=100 N1=20
+anon=180 N0=100 N1=80
+unevictable=0 N0=0 N1=0
+hierarchical_total=310 N0=210 N1=100
+hierarchical_file=130 N0=110 N1=20
+hierarchical_anon=180 N0=100 N1=80
+hierarchical_unevictable=0 N0=0 N1=0
+`
+	if err := ioutil.WriteFile(path.Join(dir, "memory.numa_stat"), []byte(numaStat), 0644); err != nil {
+		t.Fatal(err)
+	}
+	stats, err = getNumaStats(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := info.MemoryNumaStats{
+		File:        map[uint8]uint64{0: 110, 1: 20},
+		Anon:        map[uint8]uint64{0: 100, 1: 80},
+		Unevictable: map[uint8]uint64{0: 0, 1: 0},
+	}
+	if !reflect.DeepEqual(stats, expected) {
+		t.Errorf("expected %+v, got %+v", expected, stats)
+	}
+
+	if err := ioutil.WriteFile(path.Join(dir, "memory.numa_stat"), []byte("file=1 0=1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := getNumaStats(dir); err == nil {
+		t.Error("expected an invalid node to fail")
+	}
+}
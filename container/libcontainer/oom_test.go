@@ -0,0 +1,46 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package libcontainer
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestGetOomKills(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for oomControl, expected := range map[string]uint64{
+		"oom_kill_disable 0\nunder_oom 0\n":             0,
+		"oom_kill_disable 0\nunder_oom 0\noom_kill 2\n": 2,
+	} {
+		if err := ioutil.WriteFile(path.Join(dir, "memory.oom_control"), []byte(oomControl), 0644); err != nil {
+			t.Fatal(err)
+		}
+		oomKills, err := getOomKills(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if oomKills != expected {
+			t.Errorf("expected %d OOM kills from %q, got %d", expected, oomControl, oomKills)
+		}
+	}
+}
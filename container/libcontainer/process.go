@@ -0,0 +1,178 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package libcontainer
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/google/cadvisor/info"
+)
+
+// Ulimits of /proc/<pid>/limits reported by their name in ProcessStats.
+var reportedLimits = map[string]string{
+	"Max open files": "max_open_files",
+	"Max processes":  "max_processes",
+}
+
+// Counts the processes and threads of the cgroup at cgroupPath, the limit of its pids cgroup at
+// pidsPath if any, and reads the ulimits of its first process. With countFds, also counts the file
+// descriptors and sockets of its processes, reading the fd directory of each of them.
+func getProcessStats(cgroupPath, pidsPath string, countFds bool) (info.ProcessStats, error) {
+	var ret info.ProcessStats
+	pids, err := readIds(path.Join(cgroupPath, "cgroup.procs"))
+	if err != nil {
+		return ret, err
+	}
+	threads, err := readIds(path.Join(cgroupPath, "tasks"))
+	if err != nil {
+		return ret, err
+	}
+	ret.ProcessCount = uint64(len(pids))
+	ret.ThreadsCurrent = uint64(len(threads))
+
+	if pidsPath != "" {
+		ret.ThreadsMax, err = ReadPidsLimit(pidsPath)
+		if err != nil {
+			return ret, err
+		}
+		out, err := readCgroupFile(path.Join(pidsPath, "pids.current"))
+		if err != nil && !os.IsNotExist(err) {
+			return ret, err
+		}
+		ret.PidsCurrent, _ = strconv.ParseUint(strings.TrimSpace(string(out)), 10, 64)
+	}
+
+	if countFds {
+		ret.FdCount, ret.SocketCount = countFileDescriptors(pids)
+	}
+	if len(pids) != 0 {
+		ret.Ulimits, _ = readUlimits(pids[0])
+	}
+	return ret, nil
+}
+
+// Counts the file descriptors of the processes, and those that are sockets.
+func countFileDescriptors(pids []string) (fdCount, socketCount uint64) {
+	// Processes may exit while they are read, or be out of reach of cAdvisor.
+	for _, pid := range pids {
+		fdPath := path.Join(procRoot, pid, "fd")
+		fds, err := ioutil.ReadDir(fdPath)
+		if err != nil {
+			continue
+		}
+		fdCount += uint64(len(fds))
+		for _, fd := range fds {
+			target, err := os.Readlink(path.Join(fdPath, fd.Name()))
+			if err == nil && strings.HasPrefix(target, "socket:") {
+				socketCount++
+			}
+		}
+	}
+	return fdCount, socketCount
+}
+
+// Sums the context switches of the threads of the cgroup at cgroupPath.
+func getContextSwitches(cgroupPath string) (info.ContextSwitchStats, error) {
+	var ret info.ContextSwitchStats
+	threads, err := readIds(path.Join(cgroupPath, "tasks"))
+	if err != nil {
+		return ret, err
+	}
+	for _, tid := range threads {
+		out, err := ioutil.ReadFile(path.Join(procRoot, tid, "status"))
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(out), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) != 2 {
+				continue
+			}
+			value, err := strconv.ParseUint(fields[1], 10, 64)
+			if err != nil {
+				continue
+			}
+			switch fields[0] {
+			case "voluntary_ctxt_switches:":
+				ret.Voluntary += value
+			case "nonvoluntary_ctxt_switches:":
+				ret.Involuntary += value
+			}
+		}
+	}
+	return ret, nil
+}
+
+// Reads the maximum number of threads of the pids cgroup at pidsPath, 0 when unlimited.
+func ReadPidsLimit(pidsPath string) (uint64, error) {
+	out, err := readCgroupFile(path.Join(pidsPath, "pids.max"))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	// "max" when unlimited.
+	limit, _ := strconv.ParseUint(strings.TrimSpace(string(out)), 10, 64)
+	return limit, nil
+}
+
+// Reads the ids of a cgroup.procs or tasks file.
+func readIds(idsPath string) ([]string, error) {
+	out, err := readCgroupFile(idsPath)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(out)), nil
+}
+
+// Reads the ulimits of a process, -1 when unlimited.
+// e.g.: Max open files            1024                 4096                 files
+func readUlimits(pid string) ([]info.UlimitSpec, error) {
+	out, err := ioutil.ReadFile(path.Join(procRoot, pid, "limits"))
+	if err != nil {
+		return nil, err
+	}
+	var ulimits []info.UlimitSpec
+	for _, line := range strings.Split(string(out), "\n") {
+		for limit, name := range reportedLimits {
+			if !strings.HasPrefix(line, limit) {
+				continue
+			}
+			fields := strings.Fields(strings.TrimPrefix(line, limit))
+			if len(fields) < 2 {
+				continue
+			}
+			ulimits = append(ulimits, info.UlimitSpec{
+				Name:      name,
+				SoftLimit: parseLimit(fields[0]),
+				HardLimit: parseLimit(fields[1]),
+			})
+		}
+	}
+	return ulimits, nil
+}
+
+func parseLimit(value string) int64 {
+	limit, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return -1
+	}
+	return limit
+}
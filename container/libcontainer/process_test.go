@@ -0,0 +1,109 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package libcontainer
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/google/cadvisor/info"
+)
+
+func TestGetProcessStats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "process")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(root string) { procRoot = root }(procRoot)
+	procRoot = path.Join(dir, "proc")
+	cgroupPath := path.Join(dir, "cpu")
+	pidsPath := path.Join(dir, "pids")
+
+	files := map[string]string{
+		"cpu/cgroup.procs":  "10\n20\n30\n",
+		"cpu/tasks":         "10\n11\n20\n30\n",
+		"pids/pids.max":     "max\n",
+		"pids/pids.current": "5\n",
+		"proc/10/limits": `Limit                     Soft Limit           Hard Limit           Units
+Max processes             63504                63504                processes
+Max open files            1024                 unlimited            files
+`,
+		"proc/10/status": "Name:\tnginx\nvoluntary_ctxt_switches:\t150\nnonvoluntary_ctxt_switches:\t7\n",
+		"proc/11/status": "Name:\tnginx\nvoluntary_ctxt_switches:\t50\nnonvoluntary_ctxt_switches:\t3\n",
+	}
+	for file, content := range files {
+		if err := os.MkdirAll(path.Dir(path.Join(dir, file)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path.Join(dir, file), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Process 30 exited, or cannot be read.
+	links := map[string]string{
+		"proc/10/fd/0": "/dev/null",
+		"proc/10/fd/3": "socket:[1234]",
+		"proc/20/fd/0": "pipe:[5678]",
+	}
+	for link, target := range links {
+		if err := os.MkdirAll(path.Dir(path.Join(dir, link)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(target, path.Join(dir, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, err := getProcessStats(cgroupPath, pidsPath, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := info.ProcessStats{
+		ProcessCount:   3,
+		ThreadsCurrent: 4,
+		PidsCurrent:    5,
+		FdCount:        3,
+		SocketCount:    1,
+		Ulimits: []info.UlimitSpec{
+			{Name: "max_processes", SoftLimit: 63504, HardLimit: 63504},
+			{Name: "max_open_files", SoftLimit: 1024, HardLimit: -1},
+		},
+	}
+	if !reflect.DeepEqual(stats, expected) {
+		t.Errorf("expected %+v, got %+v", expected, stats)
+	}
+
+	if err := ioutil.WriteFile(path.Join(pidsPath, "pids.max"), []byte("100\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if stats, err = getProcessStats(cgroupPath, pidsPath, true); err != nil || stats.ThreadsMax != 100 {
+		t.Errorf("expected a limit of 100 threads, got %d (%v)", stats.ThreadsMax, err)
+	}
+	if stats, err = getProcessStats(cgroupPath, pidsPath, false); err != nil || stats.FdCount != 0 || stats.SocketCount != 0 || stats.ProcessCount != 3 {
+		t.Errorf("expected the processes to be counted without their file descriptors, got %+v (%v)", stats, err)
+	}
+
+	switches, err := getContextSwitches(cgroupPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := (info.ContextSwitchStats{Voluntary: 200, Involuntary: 10}); switches != expected {
+		t.Errorf("expected %+v, got %+v", expected, switches)
+	}
+}
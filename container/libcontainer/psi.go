@@ -0,0 +1,113 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package libcontainer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/pkg/mount"
+	"github.com/google/cadvisor/info"
+)
+
+// Mount point of the unified (cgroup v2) hierarchy, empty when it is not mounted.
+// Along the v1 hierarchies, it only serves the pressure of cgroups.
+var unifiedMountpoint string
+
+func findUnifiedMountpoint() string {
+	mounts, err := mount.GetMounts()
+	if err != nil {
+		return ""
+	}
+	for _, m := range mounts {
+		if m.Fstype == "cgroup2" {
+			return m.Mountpoint
+		}
+	}
+	return ""
+}
+
+// Reads the cpu, memory and io pressure of the cgroup at unifiedPath into the stats.
+// The pressure of the root cgroup, when the kernel has none, is the one of the whole machine.
+// Kernels without PSI have no pressure files.
+func getPsiStats(unifiedPath string, stats *info.ContainerStats) error {
+	for resource, psi := range map[string]*info.PSIStats{
+		"cpu":    &stats.Cpu.PSI,
+		"memory": &stats.Memory.PSI,
+		"io":     &stats.DiskIo.PSI,
+	} {
+		out, err := readCgroupFile(path.Join(unifiedPath, resource+".pressure"))
+		if os.IsNotExist(err) && path.Clean(unifiedPath) == path.Clean(unifiedMountpoint) {
+			out, err = ioutil.ReadFile(path.Join(procRoot, "pressure", resource))
+		}
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if *psi, err = parsePsi(string(out)); err != nil {
+			return fmt.Errorf("invalid %s pressure of %q: %v", resource, unifiedPath, err)
+		}
+	}
+	return nil
+}
+
+// e.g.:
+// some avg10=1.70 avg60=5.56 avg300=5.20 total=235842066
+// full avg10=0.00 avg60=0.00 avg300=0.00 total=0
+func parsePsi(pressure string) (info.PSIStats, error) {
+	var ret info.PSIStats
+	for _, line := range strings.Split(pressure, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		var data *info.PSIData
+		switch fields[0] {
+		case "some":
+			data = &ret.Some
+		case "full":
+			data = &ret.Full
+		default:
+			continue
+		}
+		for _, field := range fields[1:] {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 {
+				return ret, fmt.Errorf("invalid field %q", field)
+			}
+			var err error
+			switch kv[0] {
+			case "avg10":
+				data.Avg10, err = strconv.ParseFloat(kv[1], 64)
+			case "avg60":
+				data.Avg60, err = strconv.ParseFloat(kv[1], 64)
+			case "avg300":
+				data.Avg300, err = strconv.ParseFloat(kv[1], 64)
+			case "total":
+				data.Total, err = strconv.ParseUint(kv[1], 10, 64)
+			}
+			if err != nil {
+				return ret, err
+			}
+		}
+	}
+	return ret, nil
+}
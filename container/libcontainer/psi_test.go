@@ -0,0 +1,73 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package libcontainer
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/google/cadvisor/info"
+)
+
+func TestGetPsiStats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "psi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(root, unified string) { procRoot, unifiedMountpoint = root, unified }(procRoot, unifiedMountpoint)
+	procRoot = path.Join(dir, "proc")
+	unifiedMountpoint = path.Join(dir, "unified")
+
+	// The root cgroup has no cpu.pressure, a subcontainer no pressure at all.
+	files := map[string]string{
+		"proc/pressure/cpu":       "some avg10=1.70 avg60=5.56 avg300=5.20 total=235842066\n",
+		"unified/memory.pressure": "some avg10=0.50 avg60=0.25 avg300=0.10 total=1000\nfull avg10=0.20 avg60=0.10 avg300=0.05 total=400\n",
+		"unified/test/.keep":      "",
+	}
+	for file, content := range files {
+		if err := os.MkdirAll(path.Dir(path.Join(dir, file)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path.Join(dir, file), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var stats info.ContainerStats
+	if err := getPsiStats(unifiedMountpoint, &stats); err != nil {
+		t.Fatal(err)
+	}
+	if expected := (info.PSIData{Avg10: 1.70, Avg60: 5.56, Avg300: 5.20, Total: 235842066}); stats.Cpu.PSI.Some != expected {
+		t.Errorf("expected the cpu pressure of the machine %+v, got %+v", expected, stats.Cpu.PSI.Some)
+	}
+	if expected := (info.PSIData{Avg10: 0.20, Avg60: 0.10, Avg300: 0.05, Total: 400}); stats.Memory.PSI.Full != expected {
+		t.Errorf("expected the full memory pressure %+v, got %+v", expected, stats.Memory.PSI.Full)
+	}
+
+	stats = info.ContainerStats{}
+	if err := getPsiStats(path.Join(unifiedMountpoint, "test"), &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Cpu.PSI != (info.PSIStats{}) {
+		t.Errorf("expected no cpu pressure of a subcontainer, got %+v", stats.Cpu.PSI)
+	}
+
+	if _, err := parsePsi("some avg10=a\n"); err == nil {
+		t.Error("expected an invalid average to fail")
+	}
+}
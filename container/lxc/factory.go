@@ -0,0 +1,157 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package lxc implements a container handler factory for LXC and LXD containers.
+package lxc
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/google/cadvisor/container"
+	"github.com/google/cadvisor/container/libcontainer"
+	"github.com/google/cadvisor/info"
+)
+
+var ArgLxcPath = flag.String("lxc_path", "/var/lib/lxc", "Absolute path to the LXC containers directory, their configuration is read from it")
+var ArgLxdSocket = flag.String("lxd_socket", "", "LXD endpoint, the sockets of the LXD packages are tried if empty. LXD is preferred to the LXC containers directory when running")
+
+// The namespaces under which the aliases of LXC and LXD containers are unique.
+const (
+	LxcNamespace = "lxc"
+	LxdNamespace = "lxd"
+)
+
+// Sockets of LXD tried when no endpoint is specified.
+var defaultLxdSockets = []string{
+	"/var/lib/lxd/unix.socket",
+	"/var/snap/lxd/common/lxd/unix.socket",
+}
+
+// The configuration of a container that is reported in its spec.
+type containerConfig struct {
+	// Limits, zero when not configured.
+	memoryLimit uint64
+	swapLimit   uint64
+	cpuShares   uint64
+	cpuMask     string
+
+	image  string
+	labels map[string]string
+}
+
+// Source of the configuration of the containers.
+type configSource interface {
+	// Returns the configuration of the container, nil if there is no such container.
+	config(name string) (*containerConfig, error)
+
+	// The namespace of the aliases of the containers.
+	namespace() string
+}
+
+// Returns the name of the container the cgroup is for. ok is false if the cgroup is not the one of
+// a container: e.g. /lxc/<name> for LXC 1-3, /lxc.payload/<name> or /lxc.payload.<name> for LXC 4
+// and later.
+func parseLxcName(name string) (container string, ok bool) {
+	base := path.Base(name)
+	if strings.HasPrefix(base, "lxc.payload.") {
+		return strings.TrimPrefix(base, "lxc.payload."), true
+	}
+	if parent := path.Base(path.Dir(name)); parent == "lxc" || parent == "lxc.payload" {
+		return base, true
+	}
+	return "", false
+}
+
+type lxcFactory struct {
+	machineInfoFactory info.MachineInfoFactory
+
+	// Information about the mounted cgroup subsystems.
+	cgroupSubsystems *libcontainer.CgroupSubsystems
+
+	source configSource
+}
+
+func (self *lxcFactory) String() string {
+	return self.source.namespace()
+}
+
+func (self *lxcFactory) NewContainerHandler(name string) (container.ContainerHandler, error) {
+	lxcName, _ := parseLxcName(name)
+	config, err := self.source.config(lxcName)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return nil, fmt.Errorf("no %s container %q", self.source.namespace(), lxcName)
+	}
+	return newLxcContainerHandler(name, lxcName, self.source.namespace(), config, self.cgroupSubsystems, self.machineInfoFactory)
+}
+
+// Handles the cgroups of the containers that are configured.
+func (self *lxcFactory) CanHandle(name string) (bool, error) {
+	lxcName, ok := parseLxcName(name)
+	if !ok {
+		return false, nil
+	}
+	config, err := self.source.config(lxcName)
+	if err != nil {
+		return false, err
+	}
+	return config != nil, nil
+}
+
+// Returns the socket of LXD: the specified one, else the first default one that exists.
+func findLxdSocket() (string, error) {
+	if *ArgLxdSocket != "" {
+		if _, err := os.Stat(*ArgLxdSocket); err != nil {
+			return "", err
+		}
+		return *ArgLxdSocket, nil
+	}
+	for _, socket := range defaultLxdSockets {
+		if _, err := os.Stat(socket); err == nil {
+			return socket, nil
+		}
+	}
+	return "", fmt.Errorf("none of %v exists", defaultLxdSockets)
+}
+
+func Register(machineInfoFactory info.MachineInfoFactory) error {
+	var source configSource
+	if socket, err := findLxdSocket(); err == nil {
+		source = newLxdSource(socket, machineInfoFactory)
+	} else if _, err := os.Stat(*ArgLxcPath); err == nil {
+		source = &lxcSource{*ArgLxcPath}
+	} else {
+		return fmt.Errorf("neither LXD nor LXC seem to be installed: %v", err)
+	}
+	cgroupSubsystems, err := libcontainer.GetCgroupSubsystems()
+	if err != nil {
+		return fmt.Errorf("failed to get cgroup subsystems: %v", err)
+	}
+
+	glog.Infof("Registering %s factory", source.namespace())
+	factory := &lxcFactory{
+		machineInfoFactory: machineInfoFactory,
+		cgroupSubsystems:   &cgroupSubsystems,
+		source:             source,
+	}
+	container.RegisterContainerHandlerFactory(factory, container.PriorityRuntime)
+	return nil
+}
@@ -0,0 +1,84 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lxc
+
+import (
+	"github.com/google/cadvisor/container"
+	"github.com/google/cadvisor/container/libcontainer"
+	"github.com/google/cadvisor/container/raw"
+	"github.com/google/cadvisor/info"
+)
+
+// Collects the stats of LXC and LXD containers from their cgroups, and reports the limits of their
+// configuration.
+type lxcContainerHandler struct {
+	container.ContainerHandler
+
+	name      string
+	aliases   []string
+	namespace string
+	config    *containerConfig
+}
+
+func newLxcContainerHandler(name, lxcName, namespace string, config *containerConfig, cgroupSubsystems *libcontainer.CgroupSubsystems, machineInfoFactory info.MachineInfoFactory) (container.ContainerHandler, error) {
+	rawHandler, err := raw.NewContainerHandler(name, cgroupSubsystems, machineInfoFactory)
+	if err != nil {
+		return nil, err
+	}
+	return &lxcContainerHandler{
+		ContainerHandler: rawHandler,
+		name:             name,
+		aliases:          []string{lxcName},
+		namespace:        namespace,
+		config:           config,
+	}, nil
+}
+
+func (self *lxcContainerHandler) ContainerReference() (info.ContainerReference, error) {
+	return info.ContainerReference{
+		Name:      self.name,
+		Aliases:   self.aliases,
+		Namespace: self.namespace,
+		Labels:    self.config.labels,
+		Image:     self.config.image,
+	}, nil
+}
+
+// The limits of the configuration take precedence over the ones of the cgroups.
+func (self *lxcContainerHandler) GetSpec() (info.ContainerSpec, error) {
+	spec, err := self.ContainerHandler.GetSpec()
+	if err != nil {
+		return spec, err
+	}
+	if self.config.memoryLimit != 0 {
+		spec.HasMemory = true
+		spec.Memory.Limit = self.config.memoryLimit
+	}
+	if self.config.swapLimit != 0 {
+		spec.HasMemory = true
+		spec.Memory.SwapLimit = self.config.swapLimit
+	}
+	if self.config.cpuShares != 0 {
+		spec.HasCpu = true
+		spec.Cpu.Limit = self.config.cpuShares
+	}
+	if self.config.cpuMask != "" {
+		spec.HasCpu = true
+		spec.Cpu.Mask = self.config.cpuMask
+	}
+	spec.Labels = self.config.labels
+	spec.Image = self.config.image
+	return spec, nil
+}
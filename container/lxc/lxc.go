@@ -0,0 +1,107 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lxc
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Reads the configuration of LXC containers from their config file.
+type lxcSource struct {
+	// The LXC containers directory.
+	lxcPath string
+}
+
+func (self *lxcSource) namespace() string {
+	return LxcNamespace
+}
+
+func (self *lxcSource) config(name string) (*containerConfig, error) {
+	f, err := os.Open(path.Join(self.lxcPath, name, "config"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseLxcConfig(bufio.NewScanner(f))
+}
+
+// Parses the "key = value" lines of an LXC config file.
+func parseLxcConfig(scanner *bufio.Scanner) (*containerConfig, error) {
+	config := &containerConfig{labels: make(map[string]string)}
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key, value := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		var err error
+		switch key {
+		case "lxc.cgroup.memory.limit_in_bytes", "lxc.cgroup2.memory.max":
+			config.memoryLimit, err = parseCgroupBytes(value)
+		case "lxc.cgroup.memory.memsw.limit_in_bytes", "lxc.cgroup2.memory.swap.max":
+			config.swapLimit, err = parseCgroupBytes(value)
+		case "lxc.cgroup.cpu.shares":
+			config.cpuShares, err = strconv.ParseUint(value, 10, 64)
+		case "lxc.cgroup.cpuset.cpus", "lxc.cgroup2.cpuset.cpus":
+			config.cpuMask = value
+		case "lxc.arch", "lxc.uts.name", "lxc.rootfs.path":
+			config.labels[key] = value
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", key, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Parses a byte size as written to a cgroup file: a number with an optional K, M or G suffix, or
+// "max" (unlimited, returned as zero like an empty value).
+func parseCgroupBytes(value string) (uint64, error) {
+	if value == "" || value == "max" || value == "-1" {
+		return 0, nil
+	}
+	multiplier := uint64(1)
+	switch strings.ToUpper(value[len(value)-1:]) {
+	case "K":
+		multiplier = 1 << 10
+	case "M":
+		multiplier = 1 << 20
+	case "G":
+		multiplier = 1 << 30
+	}
+	if multiplier != 1 {
+		value = value[:len(value)-1]
+	}
+	n, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return n * multiplier, nil
+}
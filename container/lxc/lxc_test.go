@@ -0,0 +1,216 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lxc
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/cadvisor/info"
+)
+
+func TestParseLxcName(t *testing.T) {
+	cases := []struct {
+		name      string
+		container string
+		ok        bool
+	}{
+		{"/lxc/web", "web", true},
+		{"/lxc.payload/web", "web", true},
+		{"/lxc.payload.web", "web", true},
+		{"/lxc.monitor.web", "", false},
+		{"/lxc.payload.web/system.slice", "", false},
+		{"/lxc", "", false},
+		{"/docker/abc", "", false},
+	}
+	for _, c := range cases {
+		container, ok := parseLxcName(c.name)
+		if container != c.container || ok != c.ok {
+			t.Errorf("parseLxcName(%q) = %q, %v, expected %q, %v", c.name, container, ok, c.container, c.ok)
+		}
+	}
+}
+
+func TestParseLxcConfig(t *testing.T) {
+	config, err := parseLxcConfig(bufio.NewScanner(strings.NewReader(`
+# Distribution configuration
+lxc.include = /usr/share/lxc/config/common.conf
+lxc.arch = x86_64
+lxc.uts.name = web
+lxc.cgroup.memory.limit_in_bytes = 512M
+lxc.cgroup2.memory.swap.max = max
+lxc.cgroup.cpu.shares = 512
+lxc.cgroup.cpuset.cpus = 0-1
+`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.memoryLimit != 512<<20 || config.swapLimit != 0 || config.cpuShares != 512 || config.cpuMask != "0-1" {
+		t.Errorf("unexpected limits %+v", config)
+	}
+	if config.labels["lxc.arch"] != "x86_64" || config.labels["lxc.uts.name"] != "web" || len(config.labels) != 2 {
+		t.Errorf("unexpected labels %v", config.labels)
+	}
+
+	if _, err := parseLxcConfig(bufio.NewScanner(strings.NewReader("lxc.cgroup.cpu.shares = many"))); err == nil {
+		t.Errorf("expected an error for invalid shares")
+	}
+}
+
+func TestParseLxdBytes(t *testing.T) {
+	cases := map[string]uint64{
+		"1024":   1024,
+		"512MiB": 512 << 20,
+		"2GB":    2e9,
+		"1.5GiB": 3 << 29,
+		"10 kB":  1e4,
+	}
+	for value, expected := range cases {
+		n, err := parseLxdBytes(value)
+		if err != nil {
+			t.Errorf("parseLxdBytes(%q) failed: %v", value, err)
+		} else if n != expected {
+			t.Errorf("parseLxdBytes(%q) = %d, expected %d", value, n, expected)
+		}
+	}
+	for _, value := range []string{"", "GB", "12 parsecs"} {
+		if _, err := parseLxdBytes(value); err == nil {
+			t.Errorf("expected an error for %q", value)
+		}
+	}
+}
+
+type fakeMachineInfoFactory struct{}
+
+func (self fakeMachineInfoFactory) GetMachineInfo() (*info.MachineInfo, error) {
+	return &info.MachineInfo{MemoryCapacity: 8 << 30}, nil
+}
+
+func (self fakeMachineInfoFactory) GetVersionInfo() (*info.VersionInfo, error) {
+	return &info.VersionInfo{}, nil
+}
+
+// Serves the instance "web" of the default project and "db" of the project "shop".
+func serveLxd(t *testing.T, socket string) *http.Server {
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	instances := map[string]lxdInstance{
+		"default/web": {
+			Name:    "web",
+			Project: "default",
+			Type:    "container",
+			ExpandedConfig: map[string]string{
+				"limits.memory":        "25%",
+				"limits.cpu":           "2",
+				"limits.cpu.allowance": "50%",
+				"image.description":    "Ubuntu jammy amd64",
+				"image.os":             "Ubuntu",
+				"volatile.base_image":  "abcdef",
+				"user.team":            "frontend",
+				"volatile.eth0.hwaddr": "00:16:3e:00:00:00",
+			},
+		},
+		"shop/db": {
+			Name:    "db",
+			Project: "shop",
+			Type:    "container",
+			ExpandedConfig: map[string]string{
+				"limits.memory":       "1GiB",
+				"limits.cpu":          "0,2-3",
+				"volatile.base_image": "fedcba",
+			},
+		},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/1.0/instances/", func(w http.ResponseWriter, r *http.Request) {
+		key := r.URL.Query().Get("project") + "/" + strings.TrimPrefix(r.URL.Path, "/1.0/instances/")
+		instance, ok := instances[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(lxdResponse{Type: "error", Error: "Instance not found"})
+			return
+		}
+		json.NewEncoder(w).Encode(lxdResponse{Type: "sync", Metadata: instance})
+	})
+	server := &http.Server{Handler: mux}
+	go server.Serve(l)
+	return server
+}
+
+func TestLxdSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "unix.socket")
+	server := serveLxd(t, socket)
+	defer server.Close()
+	source := newLxdSource(socket, fakeMachineInfoFactory{})
+
+	config, err := source.config("web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config == nil {
+		t.Fatalf("instance web not found")
+	}
+	if config.memoryLimit != 2<<30 || config.cpuShares != 512 || config.cpuMask != "" {
+		t.Errorf("unexpected limits %+v", config)
+	}
+	if config.image != "Ubuntu jammy amd64" {
+		t.Errorf("unexpected image %q", config.image)
+	}
+	expectedLabels := map[string]string{
+		"lxd.project":       "default",
+		"lxd.type":          "container",
+		"image.description": "Ubuntu jammy amd64",
+		"image.os":          "Ubuntu",
+		"user.team":         "frontend",
+	}
+	if len(config.labels) != len(expectedLabels) {
+		t.Errorf("unexpected labels %v", config.labels)
+	}
+	for k, v := range expectedLabels {
+		if config.labels[k] != v {
+			t.Errorf("label %q is %q, expected %q", k, config.labels[k], v)
+		}
+	}
+
+	config, err = source.config("shop_db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config == nil {
+		t.Fatalf("instance db of project shop not found")
+	}
+	if config.memoryLimit != 1<<30 || config.cpuMask != "0,2-3" || config.image != "fedcba" {
+		t.Errorf("unexpected config %+v", config)
+	}
+
+	config, err = source.config("missing")
+	if err != nil || config != nil {
+		t.Errorf("expected no config for a missing instance, got %+v, %v", config, err)
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lxc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/cadvisor/info"
+)
+
+// Timeout of the requests to LXD.
+const lxdTimeout = 10 * time.Second
+
+// Reads the configuration of LXD instances from the LXD REST API.
+type lxdSource struct {
+	client             *http.Client
+	machineInfoFactory info.MachineInfoFactory
+}
+
+// The parts of an LXD instance used for its spec.
+type lxdInstance struct {
+	Name           string            `json:"name"`
+	Project        string            `json:"project"`
+	Type           string            `json:"type"`
+	ExpandedConfig map[string]string `json:"expanded_config"`
+}
+
+type lxdResponse struct {
+	Type     string      `json:"type"`
+	Error    string      `json:"error"`
+	Metadata lxdInstance `json:"metadata"`
+}
+
+func newLxdSource(socket string, machineInfoFactory info.MachineInfoFactory) *lxdSource {
+	return &lxdSource{
+		client: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+					var dialer net.Dialer
+					return dialer.DialContext(ctx, "unix", socket)
+				},
+			},
+			Timeout: lxdTimeout,
+		},
+		machineInfoFactory: machineInfoFactory,
+	}
+}
+
+func (self *lxdSource) namespace() string {
+	return LxdNamespace
+}
+
+// The cgroups of the instances of a project other than the default one are named <project>_<name>.
+func (self *lxdSource) config(name string) (*containerConfig, error) {
+	project := "default"
+	if parts := strings.SplitN(name, "_", 2); len(parts) == 2 {
+		project, name = parts[0], parts[1]
+	}
+	query := url.Values{"project": {project}}
+	resp, err := self.client.Get("http://lxd/1.0/instances/" + url.PathEscape(name) + "?" + query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	var response lxdResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("malformed response from LXD: %v", err)
+	}
+	if response.Type == "error" || resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get instance %q of project %q: %s", name, project, response.Error)
+	}
+	return self.parseInstance(&response.Metadata)
+}
+
+func (self *lxdSource) parseInstance(instance *lxdInstance) (*containerConfig, error) {
+	config := &containerConfig{
+		labels: map[string]string{
+			"lxd.project": instance.Project,
+			"lxd.type":    instance.Type,
+		},
+	}
+	for key, value := range instance.ExpandedConfig {
+		var err error
+		switch {
+		case key == "limits.memory":
+			config.memoryLimit, err = self.parseMemoryLimit(value)
+		case key == "limits.cpu":
+			// A number of CPUs is balanced by LXD, only a set of CPUs is a mask.
+			if _, err := strconv.Atoi(value); err != nil {
+				config.cpuMask = value
+			}
+		case key == "limits.cpu.allowance":
+			// Only a percentage is a share of the CPU time, a time slice is a quota.
+			if strings.HasSuffix(value, "%") {
+				var percent uint64
+				percent, err = strconv.ParseUint(strings.TrimSuffix(value, "%"), 10, 64)
+				config.cpuShares = percent * 1024 / 100
+			}
+		case key == "volatile.base_image":
+			if config.image == "" {
+				config.image = value
+			}
+		case key == "image.description":
+			config.image = value
+			config.labels[key] = value
+		case strings.HasPrefix(key, "image.") || strings.HasPrefix(key, "user."):
+			config.labels[key] = value
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s of instance %q: %v", key, instance.Name, err)
+		}
+	}
+	return config, nil
+}
+
+// Parses a memory limit of LXD: a size with an optional unit, or a percentage of the memory of the
+// machine.
+func (self *lxdSource) parseMemoryLimit(value string) (uint64, error) {
+	if !strings.HasSuffix(value, "%") {
+		return parseLxdBytes(value)
+	}
+	percent, err := strconv.ParseUint(strings.TrimSuffix(value, "%"), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	mi, err := self.machineInfoFactory.GetMachineInfo()
+	if err != nil {
+		return 0, err
+	}
+	return uint64(mi.MemoryCapacity) * percent / 100, nil
+}
+
+var lxdBytes = regexp.MustCompile(`^([0-9]+(?:\.[0-9]+)?)\s*([a-zA-Z]*)$`)
+
+var lxdUnits = map[string]float64{
+	"":    1,
+	"B":   1,
+	"kB":  1e3,
+	"MB":  1e6,
+	"GB":  1e9,
+	"TB":  1e12,
+	"PB":  1e15,
+	"EB":  1e18,
+	"KiB": 1 << 10,
+	"MiB": 1 << 20,
+	"GiB": 1 << 30,
+	"TiB": 1 << 40,
+	"PiB": 1 << 50,
+	"EiB": 1 << 60,
+}
+
+// Parses a size of LXD, e.g.: "512MiB" or "2GB".
+func parseLxdBytes(value string) (uint64, error) {
+	matches := lxdBytes.FindStringSubmatch(value)
+	if matches == nil {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+	unit, ok := lxdUnits[matches[2]]
+	if !ok {
+		return 0, fmt.Errorf("unknown unit %q", matches[2])
+	}
+	n, err := strconv.ParseFloat(matches[1], 64)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(n * unit), nil
+}
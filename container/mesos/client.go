@@ -0,0 +1,135 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mesos
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// The parts of the state of a Mesos agent describing the containers of its executors.
+type agentState struct {
+	Frameworks []struct {
+		Id        string `json:"id"`
+		Name      string `json:"name"`
+		Executors []struct {
+			Id        string      `json:"id"`
+			Name      string      `json:"name"`
+			Source    string      `json:"source"`
+			Container string      `json:"container"`
+			Tasks     []agentTask `json:"tasks"`
+		} `json:"executors"`
+	} `json:"frameworks"`
+}
+
+type agentTask struct {
+	Id     string `json:"id"`
+	Name   string `json:"name"`
+	Labels []struct {
+		Key   string `json:"key"`
+		Value string `json:"value"`
+	} `json:"labels"`
+	Container struct {
+		Docker struct {
+			Image string `json:"image"`
+		} `json:"docker"`
+		Mesos struct {
+			Image struct {
+				Docker struct {
+					Name string `json:"name"`
+				} `json:"docker"`
+				Appc struct {
+					Name string `json:"name"`
+				} `json:"appc"`
+			} `json:"image"`
+		} `json:"mesos"`
+	} `json:"container"`
+}
+
+// Returns the image of the task, empty if it has none.
+func (self *agentTask) image() string {
+	container := self.Container
+	switch {
+	case container.Docker.Image != "":
+		return container.Docker.Image
+	case container.Mesos.Image.Docker.Name != "":
+		return container.Mesos.Image.Docker.Name
+	}
+	return container.Mesos.Image.Appc.Name
+}
+
+// The container of an executor of the agent.
+type mesosContainer struct {
+	id     string
+	image  string
+	labels map[string]string
+	// The tasks run by the executor, used as aliases along with the ID of the container.
+	tasks []string
+}
+
+// Returns the containers of the executors of the agent, keyed by their ID.
+func getContainers(client *http.Client, agent string) (map[string]*mesosContainer, error) {
+	resp, err := client.Get(strings.TrimSuffix(agent, "/") + "/state")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("request for the agent state failed: %s", resp.Status)
+	}
+	var state agentState
+	if err := json.NewDecoder(resp.Body).Decode(&state); err != nil {
+		return nil, fmt.Errorf("unable to decode the agent state: %v", err)
+	}
+
+	containers := make(map[string]*mesosContainer)
+	for _, framework := range state.Frameworks {
+		for _, executor := range framework.Executors {
+			if executor.Container == "" {
+				continue
+			}
+			c := &mesosContainer{
+				id:     executor.Container,
+				labels: make(map[string]string),
+			}
+			for _, task := range executor.Tasks {
+				c.tasks = append(c.tasks, task.Id)
+				if c.image == "" {
+					c.image = task.image()
+				}
+			}
+			// The name and labels of tasks are reported when the executor runs a single task.
+			if len(executor.Tasks) == 1 {
+				task := executor.Tasks[0]
+				for _, label := range task.Labels {
+					c.labels[label.Key] = label.Value
+				}
+				c.labels["mesos.task_id"] = task.Id
+				c.labels["mesos.task_name"] = task.Name
+			}
+			c.labels["mesos.framework_id"] = framework.Id
+			c.labels["mesos.framework_name"] = framework.Name
+			c.labels["mesos.executor_id"] = executor.Id
+			c.labels["mesos.executor_name"] = executor.Name
+			if executor.Source != "" {
+				c.labels["mesos.source"] = executor.Source
+			}
+			containers[c.id] = c
+		}
+	}
+	return containers, nil
+}
@@ -0,0 +1,167 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package mesos implements a container handler factory for the containers of the Mesos
+// containerizer, whose metadata is read from the HTTP API of the Mesos agent.
+package mesos
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/cadvisor/container"
+	"github.com/google/cadvisor/container/libcontainer"
+	"github.com/google/cadvisor/info"
+)
+
+var ArgMesosAgent = flag.String("mesos_agent", "http://127.0.0.1:5051", "Mesos agent endpoint")
+var argMesosCgroupsRoot = flag.String("mesos_cgroups_root", "mesos", "Name of the cgroup under which the Mesos containerizer creates the cgroups of containers (--cgroups_root of the agent)")
+
+// The namespace under which Mesos aliases are unique.
+const MesosNamespace = "mesos"
+
+// Timeout of the requests to the agent.
+const requestTimeout = 10 * time.Second
+
+// Minimum time between two requests for the state of the agent.
+const minRefreshInterval = time.Second
+
+// Returns the ID of the container the cgroup is for, ok is false if the cgroup is not the one of
+// a top-level container of the Mesos containerizer: /<cgroups root>/<ID>.
+func parseContainerId(name, cgroupsRoot string) (id string, ok bool) {
+	if path.Dir(name) != path.Join("/", cgroupsRoot) {
+		return "", false
+	}
+	return path.Base(name), true
+}
+
+type mesosFactory struct {
+	machineInfoFactory info.MachineInfoFactory
+
+	// Information about the mounted cgroup subsystems.
+	cgroupSubsystems *libcontainer.CgroupSubsystems
+
+	client      *http.Client
+	agent       string
+	cgroupsRoot string
+
+	// Containers of the executors of the agent keyed by their ID.
+	lock        sync.Mutex
+	containers  map[string]*mesosContainer
+	lastRefresh time.Time
+}
+
+func (self *mesosFactory) String() string {
+	return MesosNamespace
+}
+
+func (self *mesosFactory) NewContainerHandler(name string) (container.ContainerHandler, error) {
+	c, err := self.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		return nil, fmt.Errorf("no Mesos container for %q", name)
+	}
+	return newMesosContainerHandler(name, c, self.cgroupSubsystems, self.machineInfoFactory)
+}
+
+// Handles the cgroups of the containers of the executors known to the agent.
+func (self *mesosFactory) CanHandle(name string) (bool, error) {
+	c, err := self.lookup(name)
+	if err != nil {
+		return false, err
+	}
+	return c != nil, nil
+}
+
+// Returns the container with the specified cgroup, nil if there is none. The state of the agent
+// is requested again when the container is unknown, at most once per minRefreshInterval: the
+// container is only known not to be a Mesos one once a state requested after the lookup started
+// does not list it, e.g. when containers are launched in a burst.
+func (self *mesosFactory) lookup(name string) (*mesosContainer, error) {
+	id, ok := parseContainerId(name, self.cgroupsRoot)
+	if !ok {
+		return nil, nil
+	}
+	start := time.Now()
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	for {
+		if c, ok := self.containers[id]; ok {
+			return c, nil
+		}
+		if self.lastRefresh.After(start) {
+			return nil, nil
+		}
+		wait := minRefreshInterval - time.Since(self.lastRefresh)
+		if wait <= 0 {
+			break
+		}
+		self.lock.Unlock()
+		time.Sleep(wait)
+		self.lock.Lock()
+	}
+	if err := self.refresh(); err != nil {
+		return nil, err
+	}
+	return self.containers[id], nil
+}
+
+// Requests the containers of the agent. Must be called with the lock held.
+func (self *mesosFactory) refresh() error {
+	self.lastRefresh = time.Now()
+	containers, err := getContainers(self.client, self.agent)
+	if err != nil {
+		return fmt.Errorf("failed to get the state of the Mesos agent: %v", err)
+	}
+	self.containers = containers
+	return nil
+}
+
+func newFactory(agent, cgroupsRoot string, cgroupSubsystems *libcontainer.CgroupSubsystems, machineInfoFactory info.MachineInfoFactory) *mesosFactory {
+	return &mesosFactory{
+		machineInfoFactory: machineInfoFactory,
+		cgroupSubsystems:   cgroupSubsystems,
+		client:             &http.Client{Timeout: requestTimeout},
+		agent:              agent,
+		cgroupsRoot:        strings.Trim(cgroupsRoot, "/"),
+		containers:         make(map[string]*mesosContainer),
+	}
+}
+
+func Register(machineInfoFactory info.MachineInfoFactory) error {
+	cgroupSubsystems, err := libcontainer.GetCgroupSubsystems()
+	if err != nil {
+		return fmt.Errorf("failed to get cgroup subsystems: %v", err)
+	}
+
+	factory := newFactory(*ArgMesosAgent, *argMesosCgroupsRoot, &cgroupSubsystems, machineInfoFactory)
+	factory.lock.Lock()
+	err = factory.refresh()
+	factory.lock.Unlock()
+	if err != nil {
+		return fmt.Errorf("unable to communicate with the Mesos agent: %v", err)
+	}
+
+	glog.Infof("Registering Mesos factory")
+	container.RegisterContainerHandlerFactory(factory, container.PriorityRuntime)
+	return nil
+}
@@ -0,0 +1,66 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mesos
+
+import (
+	"github.com/google/cadvisor/container"
+	"github.com/google/cadvisor/container/libcontainer"
+	"github.com/google/cadvisor/container/raw"
+	"github.com/google/cadvisor/info"
+)
+
+// Collects the stats of Mesos containers from their cgroups, and their metadata from the agent.
+type mesosContainerHandler struct {
+	container.ContainerHandler
+
+	name    string
+	aliases []string
+	image   string
+	labels  map[string]string
+}
+
+func newMesosContainerHandler(name string, c *mesosContainer, cgroupSubsystems *libcontainer.CgroupSubsystems, machineInfoFactory info.MachineInfoFactory) (container.ContainerHandler, error) {
+	rawHandler, err := raw.NewContainerHandler(name, cgroupSubsystems, machineInfoFactory)
+	if err != nil {
+		return nil, err
+	}
+	return &mesosContainerHandler{
+		ContainerHandler: rawHandler,
+		name:             name,
+		aliases:          append([]string{c.id}, c.tasks...),
+		image:            c.image,
+		labels:           c.labels,
+	}, nil
+}
+
+func (self *mesosContainerHandler) ContainerReference() (info.ContainerReference, error) {
+	return info.ContainerReference{
+		Name:      self.name,
+		Aliases:   self.aliases,
+		Namespace: MesosNamespace,
+		Labels:    self.labels,
+		Image:     self.image,
+	}, nil
+}
+
+func (self *mesosContainerHandler) GetSpec() (info.ContainerSpec, error) {
+	spec, err := self.ContainerHandler.GetSpec()
+	if err != nil {
+		return spec, err
+	}
+	spec.Labels = self.labels
+	spec.Image = self.image
+	return spec, nil
+}
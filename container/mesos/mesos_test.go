@@ -0,0 +1,160 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mesos
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const testState = `{
+	"frameworks": [{
+		"id": "fw-1",
+		"name": "marathon",
+		"executors": [{
+			"id": "web.1",
+			"name": "Command Executor",
+			"source": "web.1",
+			"container": "4b3e2bb5-7a4e-4d43-a2de-7d6b8e1e26a3",
+			"tasks": [{
+				"id": "web.1",
+				"name": "web",
+				"labels": [{"key": "team", "value": "frontend"}],
+				"container": {"type": "MESOS", "mesos": {"image": {"type": "DOCKER", "docker": {"name": "nginx:1.9"}}}}
+			}]
+		}, {
+			"id": "group.1",
+			"name": "default-executor",
+			"container": "d1a7b4c2-9a1e-4cb2-8f55-0e2a4f1c9d8e",
+			"tasks": [{"id": "group.1.a", "name": "a"}, {"id": "group.1.b", "name": "b"}]
+		}, {
+			"id": "pending",
+			"name": "pending"
+		}]
+	}]
+}`
+
+func newFakeAgent() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/state" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, testState)
+	}))
+}
+
+func TestParseContainerId(t *testing.T) {
+	for name, expected := range map[string]string{
+		"/mesos/4b3e2bb5":             "4b3e2bb5",
+		"/mesos":                      "",
+		"/mesos/4b3e2bb5/mesos/child": "",
+		"/docker/4b3e2bb5":            "",
+	} {
+		id, ok := parseContainerId(name, "mesos")
+		if id != expected || ok != (expected != "") {
+			t.Errorf("%q: expected %q, got %q (%v)", name, expected, id, ok)
+		}
+	}
+}
+
+func TestGetContainers(t *testing.T) {
+	server := newFakeAgent()
+	defer server.Close()
+
+	containers, err := getContainers(http.DefaultClient, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(containers) != 2 {
+		t.Fatalf("expected 2 containers, got %d", len(containers))
+	}
+	web := containers["4b3e2bb5-7a4e-4d43-a2de-7d6b8e1e26a3"]
+	expected := map[string]string{
+		"team":                 "frontend",
+		"mesos.task_id":        "web.1",
+		"mesos.task_name":      "web",
+		"mesos.framework_id":   "fw-1",
+		"mesos.framework_name": "marathon",
+		"mesos.executor_id":    "web.1",
+		"mesos.executor_name":  "Command Executor",
+		"mesos.source":         "web.1",
+	}
+	if !reflect.DeepEqual(web.labels, expected) {
+		t.Errorf("expected labels %v, got %v", expected, web.labels)
+	}
+	if web.image != "nginx:1.9" {
+		t.Errorf("expected image nginx:1.9, got %q", web.image)
+	}
+	group := containers["d1a7b4c2-9a1e-4cb2-8f55-0e2a4f1c9d8e"]
+	if !reflect.DeepEqual(group.tasks, []string{"group.1.a", "group.1.b"}) {
+		t.Errorf("unexpected tasks: %v", group.tasks)
+	}
+	if _, ok := group.labels["mesos.task_id"]; ok {
+		t.Errorf("unexpected task label for several tasks: %v", group.labels)
+	}
+}
+
+func TestCanHandle(t *testing.T) {
+	server := newFakeAgent()
+	defer server.Close()
+
+	factory := newFactory(server.URL, "/mesos/", nil, nil)
+	for name, expected := range map[string]bool{
+		"/mesos/4b3e2bb5-7a4e-4d43-a2de-7d6b8e1e26a3": true,
+		"/mesos/unknown": false,
+		"/docker/4b3e2bb5-7a4e-4d43-a2de-7d6b8e1e26a3": false,
+	} {
+		if ok, err := factory.CanHandle(name); err != nil || ok != expected {
+			t.Errorf("%q: expected %v, got %v (%v)", name, expected, ok, err)
+		}
+	}
+}
+
+func TestLookupLaunchedTogether(t *testing.T) {
+	// The agent lists the containers launched so far.
+	var lock sync.Mutex
+	var launched []string
+	launch := func(id string) {
+		lock.Lock()
+		defer lock.Unlock()
+		launched = append(launched, fmt.Sprintf(`{"id": %q, "name": %q, "container": %q}`, id, id, id))
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		fmt.Fprintf(w, `{"frameworks": [{"id": "fw-1", "name": "marathon", "executors": [%s]}]}`, strings.Join(launched, ","))
+	}))
+	defer server.Close()
+
+	factory := newFactory(server.URL, "/mesos/", nil, nil)
+	launch("first")
+	if ok, err := factory.CanHandle("/mesos/first"); err != nil || !ok {
+		t.Fatalf("expected the first container to be handled, got %v (%v)", ok, err)
+	}
+	// Launched within minRefreshInterval of the first one.
+	launch("second")
+	if ok, err := factory.CanHandle("/mesos/second"); err != nil || !ok {
+		t.Errorf("expected the second container to be handled, got %v (%v)", ok, err)
+	}
+	if ok, err := factory.CanHandle("/mesos/unknown"); err != nil || ok {
+		t.Errorf("expected an unknown container not to be handled, got %v (%v)", ok, err)
+	}
+}
@@ -0,0 +1,315 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package container
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// Kinds of metrics that can be disabled, to save the cost of collecting them and their storage.
+type MetricKind string
+
+const (
+	NetworkUsageMetrics MetricKind = "network"
+	DiskUsageMetrics    MetricKind = "disk"
+	DiskIoMetrics       MetricKind = "diskIO"
+	PerCpuUsageMetrics  MetricKind = "percpu"
+	ProcessMetrics      MetricKind = "process"
+	MemoryNumaMetrics   MetricKind = "memory_numa"
+	HugetlbMetrics      MetricKind = "hugetlb"
+	AcceleratorMetrics  MetricKind = "accelerator"
+	PressureMetrics     MetricKind = "pressure"
+	EnergyMetrics       MetricKind = "energy"
+	AppMetrics          MetricKind = "app"
+
+	// Read from /proc for every process or thread of every container, rather than from its
+	// cgroup files. On hosts running many processes, they cost far more CPU than the other
+	// metrics, so they are disabled by default.
+	FdMetrics            MetricKind = "process_fds"
+	ContextSwitchMetrics MetricKind = "context_switches"
+)
+
+var allMetrics = MetricSet{
+	NetworkUsageMetrics: {},
+	DiskUsageMetrics:    {},
+	DiskIoMetrics:       {},
+	PerCpuUsageMetrics:  {},
+	ProcessMetrics:      {},
+	MemoryNumaMetrics:   {},
+	HugetlbMetrics:      {},
+	AcceleratorMetrics:  {},
+	PressureMetrics:     {},
+	EnergyMetrics:       {},
+	AppMetrics:          {},
+
+	FdMetrics:            {},
+	ContextSwitchMetrics: {},
+}
+
+// A set of kinds of metrics, a flag.Value taking a comma separated list.
+type MetricSet map[MetricKind]struct{}
+
+func (self MetricSet) Has(kind MetricKind) bool {
+	_, ok := self[kind]
+	return ok
+}
+
+func (self MetricSet) String() string {
+	kinds := make([]string, 0, len(self))
+	for kind := range self {
+		kinds = append(kinds, string(kind))
+	}
+	sort.Strings(kinds)
+	return strings.Join(kinds, ",")
+}
+
+func (self MetricSet) Set(value string) error {
+	for kind := range self {
+		delete(self, kind)
+	}
+	for _, kind := range strings.Split(value, ",") {
+		if kind == "" {
+			continue
+		}
+		if !allMetrics.Has(MetricKind(kind)) {
+			return fmt.Errorf("unknown metric %q, metrics are %s", kind, allMetrics)
+		}
+		self[MetricKind(kind)] = struct{}{}
+	}
+	return nil
+}
+
+var (
+	metricsLock     sync.RWMutex
+	enabledMetrics  = MetricSet{}
+	disabledMetrics = MetricSet{FdMetrics: {}, ContextSwitchMetrics: {}}
+	// Whether disabledMetrics holds the default, which does not apply to the metrics of
+	// --enable_metrics. Reloading the config file sets it back to the default.
+	defaultDisabledMetrics = true
+	defaultDisabled        = disabledMetrics.String()
+)
+
+// Flag replacing one of the sets of metrics, which can be set while the metrics are collected.
+type metricsFlag struct {
+	metrics *MetricSet
+	// Whether the set is the default one, if not nil.
+	isDefault *bool
+	defValue  string
+}
+
+func (self metricsFlag) String() string {
+	metricsLock.RLock()
+	defer metricsLock.RUnlock()
+	return self.metrics.String()
+}
+
+func (self metricsFlag) Set(value string) error {
+	metrics := MetricSet{}
+	if err := metrics.Set(value); err != nil {
+		return err
+	}
+	metricsLock.Lock()
+	defer metricsLock.Unlock()
+	*self.metrics = metrics
+	if self.isDefault != nil {
+		*self.isDefault = metrics.String() == self.defValue
+	}
+	return nil
+}
+
+func init() {
+	flag.Var(metricsFlag{&enabledMetrics, nil, ""}, "enable_metrics", fmt.Sprintf("Comma separated list of the only metrics to collect, all of them if empty. Metrics are %s. They are collected even if disabled by default, unless --disable_metrics is changed", allMetrics))
+	flag.Var(metricsFlag{&disabledMetrics, &defaultDisabledMetrics, defaultDisabled}, "disable_metrics", "Comma separated list of metrics not to collect (e.g.: percpu,process), see --enable_metrics. process_fds reads the file descriptors of every process and context_switches the status of every thread of every container at every housekeeping, which costs a lot of CPU on hosts running many processes: they are only collected when removed from this list or listed in --enable_metrics")
+}
+
+// Returns whether the specified kind of metrics is collected.
+func HasMetric(kind MetricKind) bool {
+	metricsLock.RLock()
+	defer metricsLock.RUnlock()
+	if len(enabledMetrics) != 0 {
+		if !enabledMetrics.Has(kind) {
+			return false
+		}
+		if defaultDisabledMetrics {
+			return true
+		}
+	}
+	return !disabledMetrics.Has(kind)
+}
+
+// Bounds of the time the collection of metrics denied to a container is skipped for, doubling
+// while access is still denied.
+const (
+	minDeniedBackoff = time.Minute
+	maxDeniedBackoff = time.Hour
+)
+
+// The metrics of a container cAdvisor was denied access to.
+type DeniedMetric struct {
+	Container string
+	Kind      MetricKind
+	// Error of the latest collection.
+	Err error
+	// Time the collection is retried at.
+	Retry time.Time
+
+	backoff time.Duration
+}
+
+var (
+	deniedLock sync.Mutex
+	// Metrics denied, by container and kind.
+	deniedMetrics = map[string]map[MetricKind]*DeniedMetric{}
+	// Kinds of metrics whose denial was logged as a warning, later ones are logged at V(1).
+	deniedLogged = map[MetricKind]bool{}
+)
+
+// Returns whether the specified kind of metrics of the container is to be collected: it is, and
+// access to it was not denied, or the collection is to be retried.
+func CollectsMetric(containerName string, kind MetricKind) bool {
+	if !HasMetric(kind) {
+		return false
+	}
+	deniedLock.Lock()
+	defer deniedLock.Unlock()
+	denied, ok := deniedMetrics[containerName][kind]
+	return !ok || !time.Now().Before(denied.Retry)
+}
+
+// Skips the collection of the kind of metrics of the container if the error of their collection
+// is a permission error, e.g. of an SELinux or AppArmor policy confining cAdvisor, rather than
+// failing every collection. The collection is retried after a backoff, as the policy may change
+// or only deny the files of some containers. Returns whether it is skipped.
+func BlockDeniedMetric(containerName string, kind MetricKind, err error) bool {
+	if !errors.Is(err, os.ErrPermission) {
+		return false
+	}
+	deniedLock.Lock()
+	defer deniedLock.Unlock()
+	kinds, ok := deniedMetrics[containerName]
+	if !ok {
+		kinds = make(map[MetricKind]*DeniedMetric)
+		deniedMetrics[containerName] = kinds
+	}
+	denied, ok := kinds[kind]
+	if !ok {
+		denied = &DeniedMetric{Container: containerName, Kind: kind}
+		kinds[kind] = denied
+		if !deniedLogged[kind] {
+			glog.Warningf("Not collecting the %s metrics of %q, access is denied: %v. See /validate", kind, containerName, err)
+			deniedLogged[kind] = true
+		} else {
+			glog.V(1).Infof("Not collecting the %s metrics of %q, access is denied: %v", kind, containerName, err)
+		}
+	}
+	switch {
+	case denied.backoff == 0:
+		denied.backoff = minDeniedBackoff
+	case denied.backoff < maxDeniedBackoff:
+		denied.backoff *= 2
+		if denied.backoff > maxDeniedBackoff {
+			denied.backoff = maxDeniedBackoff
+		}
+	}
+	denied.Err = err
+	denied.Retry = time.Now().Add(denied.backoff)
+	return true
+}
+
+// Records that the kind of metrics of the container was collected, access is no longer denied.
+func AllowMetric(containerName string, kind MetricKind) {
+	deniedLock.Lock()
+	defer deniedLock.Unlock()
+	if kinds, ok := deniedMetrics[containerName]; ok {
+		if _, ok := kinds[kind]; ok {
+			glog.Infof("Collecting the %s metrics of %q again, access is no longer denied", kind, containerName)
+			delete(kinds, kind)
+		}
+		if len(kinds) == 0 {
+			delete(deniedMetrics, containerName)
+		}
+	}
+}
+
+// Forgets the metrics denied to a container, when it is gone.
+func ForgetDeniedMetrics(containerName string) {
+	deniedLock.Lock()
+	defer deniedLock.Unlock()
+	delete(deniedMetrics, containerName)
+}
+
+// Returns the metrics whose collection is skipped because access was denied, by container and
+// kind.
+func DeniedMetrics() []DeniedMetric {
+	deniedLock.Lock()
+	defer deniedLock.Unlock()
+	var ret []DeniedMetric
+	for _, kinds := range deniedMetrics {
+		for _, denied := range kinds {
+			ret = append(ret, *denied)
+		}
+	}
+	sort.Sort(byContainerAndKind(ret))
+	return ret
+}
+
+type byContainerAndKind []DeniedMetric
+
+func (s byContainerAndKind) Len() int      { return len(s) }
+func (s byContainerAndKind) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byContainerAndKind) Less(i, j int) bool {
+	if s[i].Container != s[j].Container {
+		return s[i].Container < s[j].Container
+	}
+	return s[i].Kind < s[j].Kind
+}
+
+// Returns the kinds of metrics collected.
+func Metrics() MetricSet {
+	ret := MetricSet{}
+	for kind := range allMetrics {
+		if HasMetric(kind) {
+			ret[kind] = struct{}{}
+		}
+	}
+	return ret
+}
+
+// Collects only the specified kinds of metrics, in place of those of --enable_metrics and
+// --disable_metrics.
+func SetMetrics(metrics MetricSet) {
+	enabled := MetricSet{}
+	disabled := MetricSet{}
+	for kind := range allMetrics {
+		if metrics.Has(kind) {
+			enabled[kind] = struct{}{}
+		} else {
+			disabled[kind] = struct{}{}
+		}
+	}
+	metricsLock.Lock()
+	defer metricsLock.Unlock()
+	enabledMetrics, disabledMetrics = enabled, disabled
+	defaultDisabledMetrics = false
+}
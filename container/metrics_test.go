@@ -0,0 +1,147 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package container
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestHasMetric(t *testing.T) {
+	defer func() {
+		enabledMetrics.Set("")
+		disabledMetrics.Set("process_fds,context_switches")
+		defaultDisabledMetrics = true
+	}()
+
+	if !HasMetric(PerCpuUsageMetrics) || !HasMetric(NetworkUsageMetrics) || !HasMetric(ProcessMetrics) {
+		t.Error("expected the metrics read from cgroups to be collected by default")
+	}
+	if HasMetric(FdMetrics) || HasMetric(ContextSwitchMetrics) {
+		t.Error("expected the metrics read for every process not to be collected by default")
+	}
+
+	if err := flag.Set("disable_metrics", "percpu,process"); err != nil {
+		t.Fatal(err)
+	}
+	if HasMetric(PerCpuUsageMetrics) || HasMetric(ProcessMetrics) || !HasMetric(NetworkUsageMetrics) {
+		t.Errorf("expected only %s to be disabled", disabledMetrics)
+	}
+
+	if err := flag.Set("enable_metrics", "network,percpu"); err != nil {
+		t.Fatal(err)
+	}
+	if !HasMetric(NetworkUsageMetrics) || HasMetric(PerCpuUsageMetrics) || HasMetric(DiskIoMetrics) {
+		t.Errorf("expected only network to be collected, got %s enabled and %s disabled", enabledMetrics, disabledMetrics)
+	}
+
+	if err := disabledMetrics.Set("tcp"); err == nil {
+		t.Error("expected an unknown metric to be rejected")
+	}
+}
+
+func TestEnableMetricsFlag(t *testing.T) {
+	defer func() {
+		enabledMetrics.Set("")
+		disabledMetrics.Set("process_fds,context_switches")
+		defaultDisabledMetrics = true
+	}()
+
+	// Metrics disabled by default are collected when explicitly enabled.
+	if err := flag.Set("enable_metrics", "process,process_fds"); err != nil {
+		t.Fatal(err)
+	}
+	if !HasMetric(ProcessMetrics) || !HasMetric(FdMetrics) || HasMetric(ContextSwitchMetrics) || HasMetric(NetworkUsageMetrics) {
+		t.Errorf("expected only process and process_fds to be collected, got %s", Metrics())
+	}
+
+	// Even when --disable_metrics is set back to its default, e.g. on reloads of the config file.
+	if err := flag.Set("disable_metrics", "process_fds,context_switches"); err != nil {
+		t.Fatal(err)
+	}
+	if !HasMetric(FdMetrics) {
+		t.Errorf("expected process_fds to be collected, got %s", Metrics())
+	}
+
+	// Unless --disable_metrics is changed.
+	if err := flag.Set("disable_metrics", "process_fds"); err != nil {
+		t.Fatal(err)
+	}
+	if !HasMetric(ProcessMetrics) || HasMetric(FdMetrics) {
+		t.Errorf("expected only process to be collected, got %s", Metrics())
+	}
+}
+
+func TestSetMetrics(t *testing.T) {
+	defer func() {
+		enabledMetrics.Set("")
+		disabledMetrics.Set("process_fds,context_switches")
+		defaultDisabledMetrics = true
+	}()
+
+	SetMetrics(MetricSet{NetworkUsageMetrics: {}, ProcessMetrics: {}})
+	if !HasMetric(NetworkUsageMetrics) || !HasMetric(ProcessMetrics) || HasMetric(PerCpuUsageMetrics) {
+		t.Errorf("expected only network and process to be collected, got %s", Metrics())
+	}
+	SetMetrics(allMetrics)
+	if metrics := Metrics(); len(metrics) != len(allMetrics) {
+		t.Errorf("expected all metrics to be collected, got %s", metrics)
+	}
+}
+
+func TestBlockDeniedMetric(t *testing.T) {
+	defer func() {
+		deniedMetrics = map[string]map[MetricKind]*DeniedMetric{}
+	}()
+
+	if BlockDeniedMetric("/a", HugetlbMetrics, fmt.Errorf("invalid hugetlb stats")) || !CollectsMetric("/a", HugetlbMetrics) {
+		t.Error("expected other errors not to block the metrics")
+	}
+	denied := &os.PathError{Op: "open", Path: "/sys/fs/cgroup/hugetlb/a", Err: syscall.EACCES}
+	if !BlockDeniedMetric("/a", HugetlbMetrics, denied) || CollectsMetric("/a", HugetlbMetrics) {
+		t.Error("expected a permission error to block the metrics of the container")
+	}
+	if !CollectsMetric("/b", HugetlbMetrics) || !CollectsMetric("/a", NetworkUsageMetrics) {
+		t.Error("expected the metrics of other containers and the other metrics to be collected")
+	}
+	denials := DeniedMetrics()
+	if len(denials) != 1 || denials[0].Container != "/a" || denials[0].Kind != HugetlbMetrics || denials[0].Err != denied {
+		t.Fatalf("unexpected denied metrics %+v", denials)
+	}
+
+	// Retried after the backoff, which doubles while access is still denied.
+	deniedMetrics["/a"][HugetlbMetrics].Retry = time.Now()
+	if !CollectsMetric("/a", HugetlbMetrics) {
+		t.Error("expected the metrics to be retried after the backoff")
+	}
+	BlockDeniedMetric("/a", HugetlbMetrics, denied)
+	if backoff := deniedMetrics["/a"][HugetlbMetrics].backoff; backoff != 2*minDeniedBackoff {
+		t.Errorf("expected a backoff of %v, got %v", 2*minDeniedBackoff, backoff)
+	}
+	AllowMetric("/a", HugetlbMetrics)
+	if !CollectsMetric("/a", HugetlbMetrics) || len(DeniedMetrics()) != 0 {
+		t.Error("expected the metrics to be collected once access is allowed")
+	}
+
+	BlockDeniedMetric("/a", HugetlbMetrics, denied)
+	ForgetDeniedMetrics("/a")
+	if len(DeniedMetrics()) != 0 {
+		t.Error("expected the denials of a gone container to be forgotten")
+	}
+}
@@ -55,6 +55,11 @@ func (self *MockContainerHandler) GetSpec() (info.ContainerSpec, error) {
 	return args.Get(0).(info.ContainerSpec), args.Error(1)
 }
 
+func (self *MockContainerHandler) GetContainerIPAddress() string {
+	args := self.Called()
+	return args.String(0)
+}
+
 func (self *MockContainerHandler) GetStats() (*info.ContainerStats, error) {
 	args := self.Called()
 	return args.Get(0).(*info.ContainerStats), args.Error(1)
@@ -75,6 +80,11 @@ func (self *MockContainerHandler) ListProcesses(listType ListType) ([]int, error
 	return args.Get(0).([]int), args.Error(1)
 }
 
+func (self *MockContainerHandler) GetCgroupPath(subsystem string) (string, error) {
+	args := self.Called(subsystem)
+	return args.Get(0).(string), args.Error(1)
+}
+
 func (self *MockContainerHandler) WatchSubcontainers(events chan SubcontainerEvent) error {
 	args := self.Called(events)
 	return args.Error(0)
@@ -12,6 +12,9 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
+//go:build linux
+// +build linux
+
 // Unmarshal's a Containers description json file. The json file contains
 // an array of ContainerHint structs, each with a container's id and networkInterface
 // This allows collecting stats about network interfaces configured outside docker
@@ -35,6 +38,7 @@ type containerHint struct {
 	FullName         string            `json:"full_path,omitempty"`
 	NetworkInterface *networkInterface `json:"network_interface,omitempty"`
 	Mounts           []mount           `json:"mounts,omitempty"`
+	Labels           map[string]string `json:"labels,omitempty"`
 }
 
 type mount struct {
@@ -12,6 +12,9 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
+//go:build linux
+// +build linux
+
 package raw
 
 import (
@@ -29,6 +32,12 @@ type rawFactory struct {
 
 	// Information about the cgroup subsystems.
 	cgroupSubsystems *libcontainer.CgroupSubsystems
+
+	// Cgroup subtrees turned into containers.
+	subtrees *subtreeFilter
+
+	// Whether only the root container is monitored as a raw container.
+	rootOnly bool
 }
 
 func (self *rawFactory) String() string {
@@ -36,7 +45,7 @@ func (self *rawFactory) String() string {
 }
 
 func (self *rawFactory) NewContainerHandler(name string) (container.ContainerHandler, error) {
-	return newRawContainerHandler(name, self.cgroupSubsystems, self.machineInfoFactory)
+	return newRawContainerHandler(name, self.cgroupSubsystems, self.machineInfoFactory, self.subtrees)
 }
 
 // The raw factory can handle any container.
@@ -44,7 +53,22 @@ func (self *rawFactory) CanHandle(name string) (bool, error) {
 	return true, nil
 }
 
+// With --docker_only, cgroups are still listed and watched for the containers of the runtimes
+// but only the root container is monitored.
+func (self *rawFactory) CanAccept(name string) (bool, error) {
+	return name == "/" || !self.rootOnly, nil
+}
+
+// Creates a handler for the cgroups of the container, without restricting its subcontainers. Used
+// by other factories to collect the stats of the containers they handle.
+func NewContainerHandler(name string, cgroupSubsystems *libcontainer.CgroupSubsystems, machineInfoFactory info.MachineInfoFactory) (container.ContainerHandler, error) {
+	return newRawContainerHandler(name, cgroupSubsystems, machineInfoFactory, &subtreeFilter{})
+}
+
 func Register(machineInfoFactory info.MachineInfoFactory) error {
+	if container.FactoryDisabled("raw") {
+		return fmt.Errorf("the raw factory monitors the machine and cannot be disabled")
+	}
 	cgroupSubsystems, err := libcontainer.GetCgroupSubsystems()
 	if err != nil {
 		return fmt.Errorf("failed to get cgroup subsystems: %v", err)
@@ -52,12 +76,24 @@ func Register(machineInfoFactory info.MachineInfoFactory) error {
 	if len(cgroupSubsystems.Mounts) == 0 {
 		return fmt.Errorf("failed to find supported cgroup mounts for the raw factory")
 	}
+	subtrees, err := newSubtreeFilter(*argPrefixWhitelist)
+	if err != nil {
+		return fmt.Errorf("invalid --raw_cgroup_prefix_whitelist: %v", err)
+	}
+	if len(subtrees.prefixes) > 0 {
+		glog.Infof("Only monitoring the cgroup subtrees %v", subtrees.prefixes)
+	}
+	if *argDockerOnly {
+		glog.Infof("Only monitoring the containers of the container runtimes besides the root container")
+	}
 
 	glog.Infof("Registering Raw factory")
 	factory := &rawFactory{
 		machineInfoFactory: machineInfoFactory,
 		cgroupSubsystems:   &cgroupSubsystems,
+		subtrees:           subtrees,
+		rootOnly:           *argDockerOnly,
 	}
-	container.RegisterContainerHandlerFactory(factory)
+	container.RegisterContainerHandlerFactory(factory, container.PriorityRaw)
 	return nil
 }
@@ -12,6 +12,9 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
+//go:build linux
+// +build linux
+
 // Handler for "raw" containers.
 package raw
 
@@ -42,9 +45,12 @@ type rawContainerHandler struct {
 	cgroupSubsystems   *libcontainer.CgroupSubsystems
 	machineInfoFactory info.MachineInfoFactory
 
-	// Inotify event watcher.
+	// Inotify event watcher, nil when scanning the cgroups for subcontainers instead.
 	watcher *inotify.Watcher
 
+	// Whether subcontainers are being watched.
+	watching bool
+
 	// Signal for watcher thread to stop.
 	stopWatcher chan error
 
@@ -54,6 +60,9 @@ type rawContainerHandler struct {
 	// Cgroup paths being watchd for new subcontainers
 	cgroupWatches map[string]struct{}
 
+	// Cgroup subtrees turned into containers.
+	subtrees *subtreeFilter
+
 	// Absolute path to the cgroup hierarchies of this container.
 	// (e.g.: "cpu" -> "/sys/fs/cgroup/cpu/test")
 	cgroupPaths map[string]string
@@ -61,9 +70,10 @@ type rawContainerHandler struct {
 	fsInfo           fs.FsInfo
 	networkInterface *networkInterface
 	externalMounts   []mount
+	labels           map[string]string
 }
 
-func newRawContainerHandler(name string, cgroupSubsystems *libcontainer.CgroupSubsystems, machineInfoFactory info.MachineInfoFactory) (container.ContainerHandler, error) {
+func newRawContainerHandler(name string, cgroupSubsystems *libcontainer.CgroupSubsystems, machineInfoFactory info.MachineInfoFactory, subtrees *subtreeFilter) (container.ContainerHandler, error) {
 	fsInfo, err := fs.NewFsInfo()
 	if err != nil {
 		return nil, err
@@ -74,13 +84,16 @@ func newRawContainerHandler(name string, cgroupSubsystems *libcontainer.CgroupSu
 	}
 	var networkInterface *networkInterface
 	var externalMounts []mount
+	var labels map[string]string
 	for _, container := range cHints.AllHosts {
 		if name == container.FullName {
 			networkInterface = container.NetworkInterface
 			externalMounts = container.Mounts
+			labels = container.Labels
 			break
 		}
 	}
+	labels = systemdLabels(name, labels)
 
 	// Create the cgroup paths.
 	cgroupPaths := make(map[string]string, len(cgroupSubsystems.MountPoints))
@@ -99,18 +112,26 @@ func newRawContainerHandler(name string, cgroupSubsystems *libcontainer.CgroupSu
 		stopWatcher:        make(chan error),
 		watches:            make(map[string]struct{}),
 		cgroupWatches:      make(map[string]struct{}),
+		subtrees:           subtrees,
 		cgroupPaths:        cgroupPaths,
 		fsInfo:             fsInfo,
 		networkInterface:   networkInterface,
 		externalMounts:     externalMounts,
+		labels:             labels,
 	}, nil
 }
 
 func (self *rawContainerHandler) ContainerReference() (info.ContainerReference, error) {
-	// We only know the container by its one name.
-	return info.ContainerReference{
-		Name: self.name,
-	}, nil
+	ref := info.ContainerReference{
+		Name:   self.name,
+		Labels: self.labels,
+	}
+	// Systemd units are also known by their unit name.
+	if unit, _, ok := systemdUnit(self.name); ok {
+		ref.Aliases = []string{unit}
+		ref.Namespace = SystemdNamespace
+	}
+	return ref, nil
 }
 
 func readString(dirpath string, file string) string {
@@ -145,6 +166,23 @@ func readInt64(dirpath string, file string) uint64 {
 	return val
 }
 
+// Reads the CFS quota of the cgroup, 0 when unlimited (-1 in the cgroup).
+func readQuota(cpuRoot string) uint64 {
+	out := readString(cpuRoot, "cpu.cfs_quota_us")
+	if out == "" {
+		return 0
+	}
+	quota, err := strconv.ParseInt(out, 10, 64)
+	if err != nil {
+		glog.Errorf("raw driver: Failed to parse CFS quota %q of %q: %s", out, cpuRoot, err)
+		return 0
+	}
+	if quota < 0 {
+		return 0
+	}
+	return uint64(quota)
+}
+
 func (self *rawContainerHandler) GetSpec() (info.ContainerSpec, error) {
 	var spec info.ContainerSpec
 
@@ -162,6 +200,8 @@ func (self *rawContainerHandler) GetSpec() (info.ContainerSpec, error) {
 		if utils.FileExists(cpuRoot) {
 			spec.HasCpu = true
 			spec.Cpu.Limit = readInt64(cpuRoot, "cpu.shares")
+			spec.Cpu.Period = readInt64(cpuRoot, "cpu.cfs_period_us")
+			spec.Cpu.Quota = readQuota(cpuRoot)
 		}
 	}
 
@@ -171,9 +211,12 @@ func (self *rawContainerHandler) GetSpec() (info.ContainerSpec, error) {
 	if ok {
 		if utils.FileExists(cpusetRoot) {
 			spec.HasCpu = true
-			spec.Cpu.Mask = readString(cpusetRoot, "cpuset.cpus")
+			if err := libcontainer.ReadCpuset(cpusetRoot, &spec.Cpu); err != nil {
+				glog.Errorf("raw driver: Failed to read the cpuset of %q: %s", cpusetRoot, err)
+			}
 			if spec.Cpu.Mask == "" {
 				spec.Cpu.Mask = fmt.Sprintf("0-%d", mi.NumCores-1)
+				spec.Cpu.EffectiveCpus = spec.Cpu.Mask
 			}
 		}
 	}
@@ -188,15 +231,35 @@ func (self *rawContainerHandler) GetSpec() (info.ContainerSpec, error) {
 		}
 	}
 
+	// Processes.
+	if pidsRoot, ok := self.cgroupPaths["pids"]; ok && utils.FileExists(pidsRoot) {
+		spec.HasProcesses = true
+		spec.Processes.Limit, err = libcontainer.ReadPidsLimit(pidsRoot)
+		if err != nil {
+			glog.Errorf("raw driver: Failed to read the pids limit of %q: %s", pidsRoot, err)
+		}
+	}
+
+	// Disk I/O.
+	if blkioRoot, ok := self.cgroupPaths["blkio"]; ok && utils.FileExists(blkioRoot) {
+		spec.HasDiskIo = container.HasMetric(container.DiskIoMetrics)
+	}
+
 	// Fs.
 	if self.name == "/" || self.externalMounts != nil {
-		spec.HasFilesystem = true
+		spec.HasFilesystem = container.HasMetric(container.DiskUsageMetrics)
 	}
 
 	//Network
-	if self.networkInterface != nil {
-		spec.HasNetwork = true
+	if container.HasMetric(container.NetworkUsageMetrics) {
+		if self.networkInterface != nil {
+			spec.HasNetwork = true
+		} else if pid := self.firstPid(); pid != 0 {
+			spec.HasNetwork, _ = libcontainer.HasNetworkNamespace(pid)
+		}
 	}
+
+	spec.Labels = self.labels
 	return spec, nil
 }
 
@@ -222,6 +285,8 @@ func (self *rawContainerHandler) getFsStats(stats *info.ContainerStats) error {
 					fs.DiskStats.IoInProgress,
 					fs.DiskStats.IoTime,
 					fs.DiskStats.WeightedIoTime,
+					fs.Inodes,
+					fs.InodesFree,
 				})
 		}
 	} else if len(self.externalMounts) > 0 {
@@ -251,6 +316,8 @@ func (self *rawContainerHandler) getFsStats(stats *info.ContainerStats) error {
 					fs.DiskStats.IoInProgress,
 					fs.DiskStats.IoTime,
 					fs.DiskStats.WeightedIoTime,
+					fs.Inodes,
+					fs.InodesFree,
 				})
 		}
 	}
@@ -263,29 +330,41 @@ func (self *rawContainerHandler) GetStats() (*info.ContainerStats, error) {
 		CgroupPaths: self.cgroupPaths,
 	}
 	if self.networkInterface != nil {
-		state = dockerlibcontainer.State{
-			NetworkState: network.NetworkState{
-				VethHost:  self.networkInterface.VethHost,
-				VethChild: self.networkInterface.VethChild,
-			},
+		state.NetworkState = network.NetworkState{
+			VethHost:  self.networkInterface.VethHost,
+			VethChild: self.networkInterface.VethChild,
 		}
+	} else if pid := self.firstPid(); pid != 0 {
+		// Containers in their own network namespace have its stats.
+		state.InitPid = pid
 	}
 
-	stats, err := libcontainer.GetStats(&state)
+	stats, err := libcontainer.GetStats(self.name, &state)
 	if err != nil {
 		return nil, err
 	}
 
-	err = self.getFsStats(stats)
-	if err != nil {
-		return nil, err
+	if container.HasMetric(container.DiskUsageMetrics) {
+		if err := self.getFsStats(stats); err != nil {
+			return nil, err
+		}
 	}
 
 	return stats, nil
 }
 
+// Returns a process of the container, 0 if it has none.
+func (self *rawContainerHandler) firstPid() int {
+	pids, err := cgroup_fs.GetPids(self.cgroup)
+	if err != nil || len(pids) == 0 {
+		return 0
+	}
+	return pids[0]
+}
+
 // Lists all directories under "path" and outputs the results as children of "parent".
-func listDirectories(dirpath string, parent string, recursive bool, output map[string]struct{}) error {
+// Only the directories of monitored subtrees are output.
+func listDirectories(dirpath string, parent string, recursive bool, subtrees *subtreeFilter, output map[string]struct{}) error {
 	// Ignore if this hierarchy does not exist.
 	if !utils.FileExists(dirpath) {
 		return nil
@@ -299,11 +378,13 @@ func listDirectories(dirpath string, parent string, recursive bool, output map[s
 		// We only grab directories.
 		if entry.IsDir() {
 			name := path.Join(parent, entry.Name())
-			output[name] = struct{}{}
+			if subtrees.monitored(name) {
+				output[name] = struct{}{}
+			}
 
 			// List subcontainers if asked to.
-			if recursive {
-				err := listDirectories(path.Join(dirpath, entry.Name()), name, true, output)
+			if recursive && subtrees.traversed(name) {
+				err := listDirectories(path.Join(dirpath, entry.Name()), name, true, subtrees, output)
 				if err != nil {
 					return err
 				}
@@ -316,7 +397,7 @@ func listDirectories(dirpath string, parent string, recursive bool, output map[s
 func (self *rawContainerHandler) ListContainers(listType container.ListType) ([]info.ContainerReference, error) {
 	containers := make(map[string]struct{})
 	for _, cgroupPath := range self.cgroupPaths {
-		err := listDirectories(cgroupPath, self.name, listType == container.ListRecursive, containers)
+		err := listDirectories(cgroupPath, self.name, listType == container.ListRecursive, self.subtrees, containers)
 		if err != nil {
 			return nil, err
 		}
@@ -333,9 +414,13 @@ func (self *rawContainerHandler) ListContainers(listType container.ListType) ([]
 	return ret, nil
 }
 
+// Lists the threads of the container itself, whatever the list type.
 func (self *rawContainerHandler) ListThreads(listType container.ListType) ([]int, error) {
-	// TODO(vmarmol): Implement
-	return nil, nil
+	cpuRoot, ok := self.cgroupPaths["cpu"]
+	if !ok {
+		return nil, nil
+	}
+	return libcontainer.GetThreads(cpuRoot)
 }
 
 func (self *rawContainerHandler) ListProcesses(listType container.ListType) ([]int, error) {
@@ -357,7 +442,8 @@ func (self *rawContainerHandler) watchDirectory(dir string, containerName string
 		return err
 	}
 	for _, entry := range entries {
-		if entry.IsDir() {
+		// Directories outside the monitored subtrees are not watched.
+		if entry.IsDir() && self.subtrees.traversed(path.Join(containerName, entry.Name())) {
 			err = self.watchDirectory(path.Join(dir, entry.Name()), path.Join(containerName, entry.Name()))
 			if err != nil {
 				return err
@@ -396,6 +482,9 @@ func (self *rawContainerHandler) processEvent(event *inotify.Event, events chan
 	if containerName == "" {
 		return fmt.Errorf("unable to detect container from watch event on directory %q", event.Name)
 	}
+	if !self.subtrees.traversed(containerName) {
+		return nil
+	}
 
 	// Maintain the watch for the new or deleted container.
 	switch {
@@ -405,6 +494,10 @@ func (self *rawContainerHandler) processEvent(event *inotify.Event, events chan
 		// New container was created, watch it.
 		err := self.watchDirectory(event.Name, containerName)
 		if err != nil {
+			// Not reported, so that it is when watched again or by the scans of the cgroups.
+			if !alreadyWatched {
+				self.forgetWatches(containerName)
+			}
 			return err
 		}
 
@@ -431,6 +524,11 @@ func (self *rawContainerHandler) processEvent(event *inotify.Event, events chan
 		return fmt.Errorf("unknown event type %v", eventType)
 	}
 
+	// Directories leading to the monitored subtrees are watched but not containers.
+	if !self.subtrees.monitored(containerName) {
+		return nil
+	}
+
 	// Deliver the event.
 	events <- container.SubcontainerEvent{
 		EventType: eventType,
@@ -440,11 +538,44 @@ func (self *rawContainerHandler) processEvent(event *inotify.Event, events chan
 	return nil
 }
 
+// Forgets the watches of a container and of its subcontainers.
+func (self *rawContainerHandler) forgetWatches(containerName string) {
+	for name := range self.watches {
+		if name == containerName || strings.HasPrefix(name, containerName+"/") {
+			delete(self.watches, name)
+		}
+	}
+}
+
+// Returns the monitored subcontainers watched, those reported.
+func (self *rawContainerHandler) knownSubcontainers() map[string]struct{} {
+	known := make(map[string]struct{}, len(self.watches))
+	for name := range self.watches {
+		if name != self.name && self.subtrees.monitored(name) {
+			known[name] = struct{}{}
+		}
+	}
+	return known
+}
+
+func (self *rawContainerHandler) GetCgroupPath(subsystem string) (string, error) {
+	cgroupPath, ok := self.cgroupPaths[subsystem]
+	if !ok {
+		return "", fmt.Errorf("the %s cgroup hierarchy is not mounted", subsystem)
+	}
+	return cgroupPath, nil
+}
+
+// Raw containers share the network of the machine.
+func (self *rawContainerHandler) GetContainerIPAddress() string {
+	return "localhost"
+}
+
 func (self *rawContainerHandler) WatchSubcontainers(events chan container.SubcontainerEvent) error {
 	// Lazily initialize the watcher so we don't use it when not asked to.
 	if self.watcher == nil {
 		w, err := inotify.NewWatcher()
-		if err != nil {
+		if err != nil && !watchesExhausted(err) {
 			return err
 		}
 		self.watcher = w
@@ -452,45 +583,71 @@ func (self *rawContainerHandler) WatchSubcontainers(events chan container.Subcon
 
 	// Watch this container (all its cgroups) and all subdirectories.
 	for _, cgroupPath := range self.cgroupPaths {
+		if self.watcher == nil {
+			break
+		}
 		err := self.watchDirectory(cgroupPath, self.name)
-		if err != nil {
+		if watchesExhausted(err) {
+			self.watcher.Close()
+			self.watcher = nil
+		} else if err != nil {
 			return err
 		}
 	}
+	self.watching = true
 
-	// Process the events received from the kernel.
 	go func() {
-		for {
-			select {
-			case event := <-self.watcher.Event:
-				err := self.processEvent(event, events)
-				if err != nil {
-					glog.Warningf("Error while processing event (%+v): %v", event, err)
-				}
-			case err := <-self.watcher.Error:
-				glog.Warningf("Error while watching %q:", self.name, err)
-			case <-self.stopWatcher:
-				err := self.watcher.Close()
-				if err == nil {
-					self.stopWatcher <- err
-					self.watcher = nil
-					return
-				}
-			}
+		// Fall back to scanning the cgroups when inotify can't watch them all.
+		if self.watcher != nil && !self.processEvents(events) {
+			return
 		}
+		glog.Warningf("Out of inotify watches, scanning the subcontainers of %q every %v", self.name, *argPollInterval)
+		self.pollForSubcontainers(self.knownSubcontainers(), events)
 	}()
 
 	return nil
 }
 
+// Processes the events received from the kernel until asked to stop, or until inotify runs out of
+// watches in which case the watcher is closed and true is returned.
+func (self *rawContainerHandler) processEvents(events chan container.SubcontainerEvent) bool {
+	for {
+		select {
+		case event := <-self.watcher.Event:
+			err := self.processEvent(event, events)
+			if watchesExhausted(err) {
+				self.watcher.Close()
+				self.watcher = nil
+				return true
+			}
+			if err != nil {
+				glog.Warningf("Error while processing event (%+v): %v", event, err)
+			}
+		case err := <-self.watcher.Error:
+			glog.Warningf("Error while watching %q:", self.name, err)
+		case <-self.stopWatcher:
+			err := self.watcher.Close()
+			if err == nil {
+				self.stopWatcher <- err
+				self.watcher = nil
+				return false
+			}
+		}
+	}
+}
+
 func (self *rawContainerHandler) StopWatchingSubcontainers() error {
-	if self.watcher == nil {
+	if !self.watching {
 		return fmt.Errorf("can't stop watch that has not started for container %q", self.name)
 	}
 
 	// Rendezvous with the watcher thread.
 	self.stopWatcher <- nil
-	return <-self.stopWatcher
+	err := <-self.stopWatcher
+	if err == nil {
+		self.watching = false
+	}
+	return err
 }
 
 func (self *rawContainerHandler) Exists() bool {
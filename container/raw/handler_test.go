@@ -0,0 +1,45 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package raw
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestReadQuota(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cpu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if quota := readQuota(dir); quota != 0 {
+		t.Errorf("expected no quota without CFS bandwidth control, got %d", quota)
+	}
+	for content, expected := range map[string]uint64{"-1\n": 0, "50000\n": 50000} {
+		if err := ioutil.WriteFile(path.Join(dir, "cpu.cfs_quota_us"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if quota := readQuota(dir); quota != expected {
+			t.Errorf("%q: expected %d, got %d", content, expected, quota)
+		}
+	}
+}
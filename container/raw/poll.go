@@ -0,0 +1,85 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package raw
+
+import (
+	"flag"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/cadvisor/container"
+)
+
+var argPollInterval = flag.Duration("raw_cgroup_poll_interval", 10*time.Second, "Interval between scans of the cgroup hierarchy for new and deleted containers when inotify watches are exhausted")
+
+// Returns whether the error is inotify running out of watches or instances, e.g. when
+// fs.inotify.max_user_watches is reached.
+func watchesExhausted(err error) bool {
+	switch e := err.(type) {
+	case *os.PathError:
+		err = e.Err
+	case *os.SyscallError:
+		err = e.Err
+	}
+	return err == syscall.ENOSPC || err == syscall.EMFILE
+}
+
+// Reports the subcontainers added and deleted since the previous scan of the cgroups, known holds
+// the subcontainers of the previous scan.
+func (self *rawContainerHandler) pollSubcontainers(known map[string]struct{}, events chan container.SubcontainerEvent) (map[string]struct{}, error) {
+	current := make(map[string]struct{})
+	for _, cgroupPath := range self.cgroupPaths {
+		err := listDirectories(cgroupPath, self.name, true, self.subtrees, current)
+		if err != nil {
+			return known, err
+		}
+	}
+	for name := range current {
+		if _, ok := known[name]; !ok {
+			events <- container.SubcontainerEvent{EventType: container.SubcontainerAdd, Name: name}
+		}
+	}
+	for name := range known {
+		if _, ok := current[name]; !ok {
+			events <- container.SubcontainerEvent{EventType: container.SubcontainerDelete, Name: name}
+		}
+	}
+	return current, nil
+}
+
+// Scans the cgroups for subcontainers every --raw_cgroup_poll_interval until asked to stop, known
+// holds the subcontainers already reported.
+func (self *rawContainerHandler) pollForSubcontainers(known map[string]struct{}, events chan container.SubcontainerEvent) {
+	ticker := time.NewTicker(*argPollInterval)
+	defer ticker.Stop()
+	for {
+		var err error
+		known, err = self.pollSubcontainers(known, events)
+		if err != nil {
+			glog.Warningf("Error while scanning the subcontainers of %q: %v", self.name, err)
+		}
+		select {
+		case <-ticker.C:
+		case <-self.stopWatcher:
+			self.stopWatcher <- nil
+			return
+		}
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package raw
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+
+	"code.google.com/p/go.exp/inotify"
+	"github.com/docker/libcontainer/cgroups"
+	"github.com/google/cadvisor/container"
+	"github.com/google/cadvisor/container/libcontainer"
+)
+
+func TestWatchesExhausted(t *testing.T) {
+	cases := []struct {
+		err       error
+		exhausted bool
+	}{
+		{&os.PathError{Op: "inotify_add_watch", Path: "/sys/fs/cgroup/cpu", Err: syscall.ENOSPC}, true},
+		{os.NewSyscallError("inotify_init", syscall.EMFILE), true},
+		{&os.PathError{Op: "inotify_add_watch", Path: "/sys/fs/cgroup/cpu", Err: syscall.ENOENT}, false},
+		{nil, false},
+	}
+	for _, c := range cases {
+		if watchesExhausted(c.err) != c.exhausted {
+			t.Errorf("expected %v to be exhausted watches: %v", c.err, c.exhausted)
+		}
+	}
+}
+
+func TestPollForSubcontainers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cgroup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, d := range []string{"a", "b/c"} {
+		if err := os.MkdirAll(filepath.Join(dir, d), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	handler := &rawContainerHandler{
+		name:        "/",
+		cgroupPaths: map[string]string{"cpu": dir},
+		subtrees:    &subtreeFilter{},
+		stopWatcher: make(chan error),
+	}
+	defer func(interval time.Duration) {
+		*argPollInterval = interval
+	}(*argPollInterval)
+	*argPollInterval = 10 * time.Millisecond
+
+	// Only the subcontainers that are not known yet are reported.
+	events := make(chan container.SubcontainerEvent, 16)
+	go handler.pollForSubcontainers(map[string]struct{}{"/a": {}}, events)
+	expectEvents := func(expected ...container.SubcontainerEvent) {
+		remaining := make(map[container.SubcontainerEvent]bool)
+		for _, e := range expected {
+			remaining[e] = true
+		}
+		for len(remaining) > 0 {
+			select {
+			case event := <-events:
+				if !remaining[event] {
+					t.Errorf("unexpected event %+v", event)
+				}
+				delete(remaining, event)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("timed out waiting for events %+v", remaining)
+			}
+		}
+	}
+	expectEvents(
+		container.SubcontainerEvent{EventType: container.SubcontainerAdd, Name: "/b"},
+		container.SubcontainerEvent{EventType: container.SubcontainerAdd, Name: "/b/c"},
+	)
+
+	if err := os.Remove(filepath.Join(dir, "a")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "d"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	expectEvents(
+		container.SubcontainerEvent{EventType: container.SubcontainerDelete, Name: "/a"},
+		container.SubcontainerEvent{EventType: container.SubcontainerAdd, Name: "/d"},
+	)
+
+	handler.watching = true
+	if err := handler.StopWatchingSubcontainers(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFailedWatchNotKnown(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cgroup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.Mkdir(filepath.Join(dir, "a"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// Watched but not listed: its watch fails once recorded, as when the watches of its
+	// subdirectories are exhausted.
+	if err := ioutil.WriteFile(filepath.Join(dir, "b"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	watcher, err := inotify.NewWatcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Close()
+	handler := &rawContainerHandler{
+		name:             "/",
+		cgroupPaths:      map[string]string{"cpu": dir},
+		cgroupSubsystems: &libcontainer.CgroupSubsystems{Mounts: []cgroups.Mount{{Mountpoint: dir}}},
+		subtrees:         &subtreeFilter{},
+		watcher:          watcher,
+		watches:          make(map[string]struct{}),
+		cgroupWatches:    make(map[string]struct{}),
+	}
+
+	events := make(chan container.SubcontainerEvent, 16)
+	for _, name := range []string{"a", "b"} {
+		handler.processEvent(&inotify.Event{Mask: inotify.IN_CREATE, Name: filepath.Join(dir, name)}, events)
+	}
+	if len(events) != 1 {
+		t.Fatalf("expected only /a to be reported, got %d events", len(events))
+	}
+	if event := <-events; event.Name != "/a" {
+		t.Errorf("expected /a to be reported, got %+v", event)
+	}
+	// The scans of the cgroups report the subcontainer whose watch failed.
+	expected := map[string]struct{}{"/a": {}}
+	if known := handler.knownSubcontainers(); !reflect.DeepEqual(known, expected) {
+		t.Errorf("expected the known subcontainers %v, got %v", expected, known)
+	}
+}
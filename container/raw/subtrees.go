@@ -0,0 +1,85 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package raw
+
+import (
+	"flag"
+	"fmt"
+	"path"
+	"strings"
+)
+
+var argPrefixWhitelist = flag.String("raw_cgroup_prefix_whitelist", "", "comma-separated list of cgroup subtrees (e.g.: /docker,/system.slice) turned into containers along with their subcontainers. Empty monitors the whole cgroup hierarchy. The root container is always monitored")
+var argDockerOnly = flag.Bool("docker_only", false, "Only report the containers of Docker and the other container runtimes besides the root container, other cgroups are not turned into raw containers")
+
+// Cgroup subtrees monitored by the raw containers.
+type subtreeFilter struct {
+	// Roots of the monitored subtrees, empty to monitor the whole hierarchy.
+	prefixes []string
+}
+
+func newSubtreeFilter(whitelist string) (*subtreeFilter, error) {
+	ret := &subtreeFilter{}
+	for _, prefix := range strings.Split(whitelist, ",") {
+		prefix = strings.TrimSpace(prefix)
+		if prefix == "" {
+			continue
+		}
+		if !strings.HasPrefix(prefix, "/") {
+			return nil, fmt.Errorf("cgroup subtree %q is not an absolute path", prefix)
+		}
+		prefix = path.Clean(prefix)
+		if prefix == "/" {
+			// The whole hierarchy.
+			return &subtreeFilter{}, nil
+		}
+		ret.prefixes = append(ret.prefixes, prefix)
+	}
+	return ret, nil
+}
+
+func isWithin(name, prefix string) bool {
+	return name == prefix || strings.HasPrefix(name, prefix+"/")
+}
+
+// Returns whether the container is in a monitored subtree.
+func (self *subtreeFilter) monitored(name string) bool {
+	if len(self.prefixes) == 0 || name == "/" {
+		return true
+	}
+	for _, prefix := range self.prefixes {
+		if isWithin(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns whether monitored containers may be below the container, i.e. whether it has to be
+// listed and watched for subcontainers.
+func (self *subtreeFilter) traversed(name string) bool {
+	if self.monitored(name) {
+		return true
+	}
+	for _, prefix := range self.prefixes {
+		if isWithin(prefix, name) {
+			return true
+		}
+	}
+	return false
+}
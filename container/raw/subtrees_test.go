@@ -0,0 +1,115 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package raw
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestSubtreeFilter(t *testing.T) {
+	filter, err := newSubtreeFilter("/docker, /system.slice/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name      string
+		monitored bool
+		traversed bool
+	}{
+		{"/", true, true},
+		{"/docker", true, true},
+		{"/docker/abc", true, true},
+		{"/dockerd", false, false},
+		{"/system.slice/sshd.service", true, true},
+		{"/user", false, false},
+	}
+	for _, c := range cases {
+		if filter.monitored(c.name) != c.monitored || filter.traversed(c.name) != c.traversed {
+			t.Errorf("expected %q to be monitored: %v, traversed: %v", c.name, c.monitored, c.traversed)
+		}
+	}
+
+	nested, err := newSubtreeFilter("/user/1000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nested.monitored("/user") || !nested.traversed("/user") {
+		t.Errorf("the parent of a subtree should only be traversed")
+	}
+
+	all, err := newSubtreeFilter("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !all.monitored("/any/cgroup") {
+		t.Errorf("an empty whitelist should monitor everything")
+	}
+
+	if _, err := newSubtreeFilter("docker"); err == nil {
+		t.Errorf("expected an error for a relative subtree")
+	}
+}
+
+func TestListDirectoriesInSubtrees(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cgroup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, d := range []string{"docker/abc", "user/1000/session", "user/1001", "system.slice"} {
+		if err := os.MkdirAll(filepath.Join(dir, d), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	filter, err := newSubtreeFilter("/docker,/user/1000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := make(map[string]struct{})
+	if err := listDirectories(dir, "/", true, filter, output); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for name := range output {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	expected := []string{"/docker", "/docker/abc", "/user/1000", "/user/1000/session"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected containers %v, got %v", expected, names)
+	}
+}
+
+func TestRootOnly(t *testing.T) {
+	factory := &rawFactory{rootOnly: true}
+	for name, expected := range map[string]bool{"/": true, "/docker": false, "/system.slice/sshd.service": false} {
+		if accept, err := factory.CanAccept(name); err != nil || accept != expected {
+			t.Errorf("%q: expected %v, got %v (%v)", name, expected, accept, err)
+		}
+	}
+	factory.rootOnly = false
+	if accept, _ := factory.CanAccept("/docker"); !accept {
+		t.Error("expected all containers to be accepted")
+	}
+}
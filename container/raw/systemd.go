@@ -0,0 +1,63 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raw
+
+import (
+	"path"
+	"strings"
+)
+
+// The namespace under which systemd unit names are unique.
+const SystemdNamespace = "systemd"
+
+// Types of the systemd units that have a cgroup named after them.
+var systemdUnitSuffixes = []string{".service", ".scope", ".slice", ".socket", ".mount", ".swap"}
+
+// Returns the systemd unit of the container (e.g.: "sshd.service" for
+// "/system.slice/sshd.service") and the slice it is in. ok is false if the container is not the
+// cgroup of a systemd unit.
+func systemdUnit(name string) (unit, slice string, ok bool) {
+	unit = path.Base(name)
+	for _, suffix := range systemdUnitSuffixes {
+		if strings.HasSuffix(unit, suffix) && len(unit) > len(suffix) {
+			ok = true
+			break
+		}
+	}
+	if !ok {
+		return "", "", false
+	}
+	slice = path.Base(path.Dir(name))
+	if !strings.HasSuffix(slice, ".slice") {
+		// Units directly in the root cgroup are in the root slice.
+		slice = "-.slice"
+	}
+	return unit, slice, true
+}
+
+// Returns the labels of the container with the systemd unit and slice it belongs to.
+func systemdLabels(name string, labels map[string]string) map[string]string {
+	unit, slice, ok := systemdUnit(name)
+	if !ok {
+		return labels
+	}
+	ret := make(map[string]string, len(labels)+2)
+	for k, v := range labels {
+		ret[k] = v
+	}
+	ret["systemd.unit"] = unit
+	ret["systemd.slice"] = slice
+	return ret
+}
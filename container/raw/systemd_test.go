@@ -0,0 +1,54 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package raw
+
+import "testing"
+
+func TestSystemdUnit(t *testing.T) {
+	cases := []struct {
+		name  string
+		unit  string
+		slice string
+		ok    bool
+	}{
+		{"/system.slice/sshd.service", "sshd.service", "system.slice", true},
+		{"/user.slice/user-1000.slice/session-3.scope", "session-3.scope", "user-1000.slice", true},
+		{"/system.slice", "system.slice", "-.slice", true},
+		{"/init.scope", "init.scope", "-.slice", true},
+		{"/docker/abc", "", "", false},
+		{"/", "", "", false},
+		{"/.service", "", "", false},
+	}
+	for _, c := range cases {
+		unit, slice, ok := systemdUnit(c.name)
+		if unit != c.unit || slice != c.slice || ok != c.ok {
+			t.Errorf("expected unit %q in slice %q (%v) for %q, got %q in %q (%v)", c.unit, c.slice, c.ok, c.name, unit, slice, ok)
+		}
+	}
+}
+
+func TestSystemdLabels(t *testing.T) {
+	hints := map[string]string{"team": "infra"}
+	labels := systemdLabels("/system.slice/sshd.service", hints)
+	if labels["systemd.unit"] != "sshd.service" || labels["systemd.slice"] != "system.slice" || labels["team"] != "infra" {
+		t.Errorf("unexpected labels %v", labels)
+	}
+	if len(hints) != 1 {
+		t.Errorf("the labels of the container hints should not be modified: %v", hints)
+	}
+	if labels := systemdLabels("/docker/abc", hints); len(labels) != 1 {
+		t.Errorf("unexpected labels for a container that is not a systemd unit: %v", labels)
+	}
+}
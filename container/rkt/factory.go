@@ -0,0 +1,116 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package rkt implements a container handler factory for rkt pods and their apps.
+package rkt
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/google/cadvisor/container"
+	"github.com/google/cadvisor/container/libcontainer"
+	"github.com/google/cadvisor/info"
+)
+
+// The pods are read from the data directory of rkt, which is also what the rkt API service serves.
+var ArgRktDir = flag.String("rkt_dir", "/var/lib/rkt", "Absolute path to the rkt data directory, running pods are discovered from it")
+
+// The namespace under which rkt aliases are unique.
+const RktNamespace = "rkt"
+
+// Cgroup of a pod, registered with systemd-machined: machine-rkt-<UUID>.scope with the dashes
+// escaped by systemd.
+var podScope = regexp.MustCompile(`^machine-rkt((?:\\x2d[0-9a-f]+)+)\.scope$`)
+
+// Returns the UUID of the pod and the name of the app the container is for, an empty app for the
+// pod itself. ok is false if the container is neither a pod nor an app.
+// e.g.: /machine.slice/machine-rkt\x2d<UUID>.scope/system.slice/<app>.service
+func parseRktName(name string) (uuid, app string, ok bool) {
+	parts := strings.Split(strings.Trim(name, "/"), "/")
+	for i, part := range parts {
+		matches := podScope.FindStringSubmatch(part)
+		if matches == nil {
+			continue
+		}
+		uuid = strings.Replace(matches[1], `\x2d`, "-", -1)[1:]
+		rest := parts[i+1:]
+		switch {
+		case len(rest) == 0:
+			return uuid, "", true
+		case len(rest) == 2 && rest[0] == "system.slice" && strings.HasSuffix(rest[1], ".service"):
+			return uuid, strings.TrimSuffix(rest[1], ".service"), true
+		}
+		return "", "", false
+	}
+	return "", "", false
+}
+
+type rktFactory struct {
+	machineInfoFactory info.MachineInfoFactory
+
+	// Information about the mounted cgroup subsystems.
+	cgroupSubsystems *libcontainer.CgroupSubsystems
+
+	// Data directory of rkt.
+	rktDir string
+}
+
+func (self *rktFactory) String() string {
+	return RktNamespace
+}
+
+func (self *rktFactory) NewContainerHandler(name string) (container.ContainerHandler, error) {
+	return newRktContainerHandler(name, self.rktDir, self.cgroupSubsystems, self.machineInfoFactory)
+}
+
+// Handles the pods running in rkt and their apps.
+func (self *rktFactory) CanHandle(name string) (bool, error) {
+	uuid, app, ok := parseRktName(name)
+	if !ok {
+		return false, nil
+	}
+	manifest, err := readPodManifest(self.rktDir, uuid)
+	if err != nil {
+		return false, fmt.Errorf("error reading the manifest of pod %q: %v", uuid, err)
+	}
+	if app != "" && manifest.app(app) == nil {
+		return false, fmt.Errorf("no app %q in pod %q", app, uuid)
+	}
+	return true, nil
+}
+
+func Register(machineInfoFactory info.MachineInfoFactory) error {
+	if _, err := os.Stat(path.Join(*ArgRktDir, podsRunDir)); err != nil {
+		return fmt.Errorf("rkt does not seem to be installed: %v", err)
+	}
+	cgroupSubsystems, err := libcontainer.GetCgroupSubsystems()
+	if err != nil {
+		return fmt.Errorf("failed to get cgroup subsystems: %v", err)
+	}
+
+	glog.Infof("Registering rkt factory")
+	factory := &rktFactory{
+		machineInfoFactory: machineInfoFactory,
+		cgroupSubsystems:   &cgroupSubsystems,
+		rktDir:             *ArgRktDir,
+	}
+	container.RegisterContainerHandlerFactory(factory, container.PriorityRuntime)
+	return nil
+}
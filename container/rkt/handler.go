@@ -0,0 +1,147 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rkt
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path"
+	"strings"
+
+	"github.com/google/cadvisor/container"
+	"github.com/google/cadvisor/container/libcontainer"
+	"github.com/google/cadvisor/container/raw"
+	"github.com/google/cadvisor/info"
+)
+
+// Directory of the running pods, relative to the rkt data directory.
+const podsRunDir = "pods/run"
+
+type annotation struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type podApp struct {
+	Name  string `json:"name"`
+	Image struct {
+		Name string `json:"name"`
+		Id   string `json:"id"`
+	} `json:"image"`
+	Annotations []annotation `json:"annotations"`
+}
+
+// The parts of the App Container pod manifest used for the metadata of the containers.
+type podManifest struct {
+	Apps        []podApp     `json:"apps"`
+	Annotations []annotation `json:"annotations"`
+}
+
+func readPodManifest(rktDir, uuid string) (*podManifest, error) {
+	data, err := ioutil.ReadFile(path.Join(rktDir, podsRunDir, uuid, "pod"))
+	if err != nil {
+		return nil, err
+	}
+	manifest := &podManifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// Returns the app with the specified name, nil if the pod has no such app.
+func (self *podManifest) app(name string) *podApp {
+	for i := range self.Apps {
+		if self.Apps[i].Name == name {
+			return &self.Apps[i]
+		}
+	}
+	return nil
+}
+
+// Collects the stats of pods and apps from their cgroups, and their metadata from the pod manifest.
+type rktContainerHandler struct {
+	container.ContainerHandler
+
+	name    string
+	aliases []string
+	image   string
+	labels  map[string]string
+}
+
+func newRktContainerHandler(name, rktDir string, cgroupSubsystems *libcontainer.CgroupSubsystems, machineInfoFactory info.MachineInfoFactory) (container.ContainerHandler, error) {
+	uuid, appName, _ := parseRktName(name)
+	manifest, err := readPodManifest(rktDir, uuid)
+	if err != nil {
+		return nil, err
+	}
+	rawHandler, err := raw.NewContainerHandler(name, cgroupSubsystems, machineInfoFactory)
+	if err != nil {
+		return nil, err
+	}
+
+	handler := &rktContainerHandler{
+		ContainerHandler: rawHandler,
+		name:             name,
+		labels: map[string]string{
+			"rkt.pod.uuid": uuid,
+		},
+	}
+	annotations := manifest.Annotations
+	if appName == "" {
+		handler.aliases = []string{uuid}
+		appNames := make([]string, 0, len(manifest.Apps))
+		for _, app := range manifest.Apps {
+			appNames = append(appNames, app.Name)
+		}
+		handler.labels["rkt.pod.apps"] = strings.Join(appNames, ",")
+		// The image of a single app pod is the image of the pod.
+		if len(manifest.Apps) == 1 {
+			handler.image = manifest.Apps[0].Image.Name
+		}
+	} else {
+		handler.aliases = []string{uuid + "/" + appName}
+		handler.labels["rkt.app.name"] = appName
+		if app := manifest.app(appName); app != nil {
+			handler.image = app.Image.Name
+			handler.labels["rkt.app.image.id"] = app.Image.Id
+			annotations = append(annotations, app.Annotations...)
+		}
+	}
+	for _, a := range annotations {
+		handler.labels[a.Name] = a.Value
+	}
+	return handler, nil
+}
+
+func (self *rktContainerHandler) ContainerReference() (info.ContainerReference, error) {
+	return info.ContainerReference{
+		Name:      self.name,
+		Aliases:   self.aliases,
+		Namespace: RktNamespace,
+		Labels:    self.labels,
+		Image:     self.image,
+	}, nil
+}
+
+func (self *rktContainerHandler) GetSpec() (info.ContainerSpec, error) {
+	spec, err := self.ContainerHandler.GetSpec()
+	if err != nil {
+		return spec, err
+	}
+	spec.Labels = self.labels
+	spec.Image = self.image
+	return spec, nil
+}
@@ -0,0 +1,88 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rkt
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testUuid = "4d8a7d9e-1b2c-4f5e-8a9b-0c1d2e3f4a5b"
+
+const testPodScope = `/machine.slice/machine-rkt\x2d4d8a7d9e\x2d1b2c\x2d4f5e\x2d8a9b\x2d0c1d2e3f4a5b.scope`
+
+func TestParseRktName(t *testing.T) {
+	cases := []struct {
+		name string
+		uuid string
+		app  string
+		ok   bool
+	}{
+		{testPodScope, testUuid, "", true},
+		{testPodScope + "/system.slice/etcd.service", testUuid, "etcd", true},
+		{testPodScope + "/system.slice", "", "", false},
+		{"/machine.slice/machine-qemu\\x2d1.scope", "", "", false},
+		{"/system.slice/docker-abc.scope", "", "", false},
+	}
+	for _, c := range cases {
+		uuid, app, ok := parseRktName(c.name)
+		if uuid != c.uuid || app != c.app || ok != c.ok {
+			t.Errorf("expected pod %q app %q (%v) for %q, got %q %q (%v)", c.uuid, c.app, c.ok, c.name, uuid, app, ok)
+		}
+	}
+}
+
+func TestCanHandle(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rkt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	podDir := filepath.Join(dir, podsRunDir, testUuid)
+	if err := os.MkdirAll(podDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	manifest := `{
+		"acKind": "PodManifest",
+		"apps": [{"name": "etcd", "image": {"name": "coreos.com/etcd", "id": "sha512-abc"}, "annotations": [{"name": "tier", "value": "db"}]}],
+		"annotations": [{"name": "owner", "value": "infra"}]
+	}`
+	if err := ioutil.WriteFile(filepath.Join(podDir, "pod"), []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	factory := &rktFactory{rktDir: dir}
+	for name, expected := range map[string]bool{
+		testPodScope: true,
+		testPodScope + "/system.slice/etcd.service": true,
+		testPodScope + "/system.slice/web.service":  false,
+		"/docker/abc": false,
+	} {
+		if canHandle, _ := factory.CanHandle(name); canHandle != expected {
+			t.Errorf("expected CanHandle(%q) to be %v", name, expected)
+		}
+	}
+
+	pod, err := readPodManifest(dir, testUuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := pod.app("etcd")
+	if app == nil || app.Image.Name != "coreos.com/etcd" || len(app.Annotations) != 1 || len(pod.Annotations) != 1 {
+		t.Errorf("unexpected manifest %+v", pod)
+	}
+}
@@ -0,0 +1,255 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Records the events of containers and delivers them to watchers.
+package events
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/cadvisor/info"
+)
+
+// Events waiting to be read by a watcher before new ones are dropped.
+const watchBufferSize = 100
+
+// A query of past events or a watch of new ones.
+type Request struct {
+	// Events from StartTime to EndTime, inclusive. Ignored if zero.
+	StartTime time.Time
+	EndTime   time.Time
+
+	// The types of events to return, all types if empty.
+	EventType map[info.EventType]bool
+
+	// The latest events are returned, up to this number. All if 0 or negative.
+	MaxEventsReturned int
+
+	// The container the events are about, all containers if empty.
+	ContainerName string
+
+	// Whether to include the events of the subcontainers of ContainerName.
+	IncludeSubcontainers bool
+}
+
+// Returns whether an event is requested.
+func (self *Request) matches(e *info.Event) bool {
+	if !self.StartTime.IsZero() && e.Timestamp.Before(self.StartTime) {
+		return false
+	}
+	if !self.EndTime.IsZero() && e.Timestamp.After(self.EndTime) {
+		return false
+	}
+	if len(self.EventType) != 0 && !self.EventType[e.EventType] {
+		return false
+	}
+	if self.ContainerName == "" || self.ContainerName == e.ContainerName {
+		return true
+	}
+	if !self.IncludeSubcontainers {
+		return false
+	}
+	return self.ContainerName == "/" || strings.HasPrefix(e.ContainerName, self.ContainerName+"/")
+}
+
+// Delivers the new events of a watch.
+type EventChannel struct {
+	watchId int
+	channel chan *info.Event
+}
+
+func (self *EventChannel) GetChannel() <-chan *info.Event {
+	return self.channel
+}
+
+func (self *EventChannel) GetWatchId() int {
+	return self.watchId
+}
+
+type EventManager interface {
+	// Returns a channel of the new events matching the request until the watch is stopped. The
+	// events of watchers reading too slowly are dropped.
+	WatchEvents(request *Request) (*EventChannel, error)
+
+	// Returns the past events matching the request, oldest first.
+	GetEvents(request *Request) ([]*info.Event, error)
+
+	// Records an event and delivers it to the watchers.
+	AddEvent(e *info.Event) error
+
+	// Stops a watch, closing its channel.
+	StopWatch(watchId int)
+}
+
+type watch struct {
+	request *Request
+	channel *EventChannel
+}
+
+type events struct {
+	lock sync.RWMutex
+	// Past events, sorted by timestamp.
+	history []*info.Event
+	watches map[int]*watch
+	lastId  int
+
+	// Events older than maxAge are forgotten, as are the oldest when there are more than
+	// maxEvents. No limit if 0.
+	maxAge    time.Duration
+	maxEvents int
+
+	// Where the events are persisted, nil if they are only kept in memory.
+	log *eventLog
+}
+
+func NewEventManager(maxAge time.Duration, maxEvents int) EventManager {
+	return &events{
+		watches:   make(map[int]*watch),
+		maxAge:    maxAge,
+		maxEvents: maxEvents,
+	}
+}
+
+// Returns an event manager persisting the events in a directory, starting with the events
+// persisted there within the limits.
+func NewPersistentEventManager(dir string, maxAge time.Duration, maxEvents int) (EventManager, error) {
+	log, history, err := openEventLog(dir)
+	if err != nil {
+		return nil, err
+	}
+	self := &events{
+		watches:   make(map[int]*watch),
+		maxAge:    maxAge,
+		maxEvents: maxEvents,
+		log:       log,
+	}
+	sort.Sort(byTimestamp(history))
+	self.history = history
+	self.evict()
+	if err := log.rewrite(self.history); err != nil {
+		return nil, err
+	}
+	glog.Infof("Loaded %d events from %q", len(self.history), dir)
+	return self, nil
+}
+
+type byTimestamp []*info.Event
+
+func (s byTimestamp) Len() int           { return len(s) }
+func (s byTimestamp) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byTimestamp) Less(i, j int) bool { return s[i].Timestamp.Before(s[j].Timestamp) }
+
+func (self *events) WatchEvents(request *Request) (*EventChannel, error) {
+	if request == nil {
+		return nil, fmt.Errorf("nil request")
+	}
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.lastId++
+	channel := &EventChannel{
+		watchId: self.lastId,
+		channel: make(chan *info.Event, watchBufferSize),
+	}
+	self.watches[channel.watchId] = &watch{request: request, channel: channel}
+	return channel, nil
+}
+
+func (self *events) GetEvents(request *Request) ([]*info.Event, error) {
+	if request == nil {
+		return nil, fmt.Errorf("nil request")
+	}
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	result := []*info.Event{}
+	for _, e := range self.history {
+		if request.matches(e) {
+			result = append(result, e)
+		}
+	}
+	if request.MaxEventsReturned > 0 && len(result) > request.MaxEventsReturned {
+		result = result[len(result)-request.MaxEventsReturned:]
+	}
+	return result, nil
+}
+
+func (self *events) AddEvent(e *info.Event) error {
+	if e == nil {
+		return fmt.Errorf("nil event")
+	}
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	// Events are mostly added in order.
+	i := sort.Search(len(self.history), func(i int) bool {
+		return self.history[i].Timestamp.After(e.Timestamp)
+	})
+	self.history = append(self.history, nil)
+	copy(self.history[i+1:], self.history[i:])
+	self.history[i] = e
+	self.evict()
+	if self.log != nil {
+		err := self.log.append(e)
+		if err == nil {
+			err = self.log.compact(self.history)
+		}
+		if err != nil {
+			glog.Warningf("Failed to persist the %s event of %q: %v", e.EventType, e.ContainerName, err)
+		}
+	}
+
+	for id, w := range self.watches {
+		if !w.request.matches(e) {
+			continue
+		}
+		select {
+		case w.channel.channel <- e:
+		default:
+			glog.Warningf("Dropped a %s event of %q, watch %d is not reading its events", e.EventType, e.ContainerName, id)
+		}
+	}
+	return nil
+}
+
+// Forgets the events beyond the limits. Must hold the lock.
+func (self *events) evict() {
+	start := 0
+	if self.maxAge > 0 {
+		cutoff := time.Now().Add(-self.maxAge)
+		start = sort.Search(len(self.history), func(i int) bool {
+			return !self.history[i].Timestamp.Before(cutoff)
+		})
+	}
+	if self.maxEvents > 0 && len(self.history)-start > self.maxEvents {
+		start = len(self.history) - self.maxEvents
+	}
+	if start > 0 {
+		self.history = append(self.history[:0], self.history[start:]...)
+	}
+}
+
+func (self *events) StopWatch(watchId int) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	w, ok := self.watches[watchId]
+	if !ok {
+		return
+	}
+	delete(self.watches, watchId)
+	close(w.channel.channel)
+}
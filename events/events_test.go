@@ -0,0 +1,174 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/info"
+)
+
+func newEvent(containerName string, eventType info.EventType, timestamp time.Time) *info.Event {
+	return &info.Event{
+		ContainerName: containerName,
+		EventType:     eventType,
+		Timestamp:     timestamp,
+	}
+}
+
+func TestGetEvents(t *testing.T) {
+	m := NewEventManager(0, 0)
+	now := time.Now()
+	for _, e := range []*info.Event{
+		newEvent("/docker/a", info.EventContainerCreation, now.Add(-3*time.Minute)),
+		newEvent("/docker/a", info.EventOom, now.Add(-time.Minute)),
+		newEvent("/docker", info.EventContainerCreation, now.Add(-4*time.Minute)),
+		newEvent("/docker/a", info.EventContainerDeletion, now),
+		newEvent("/dockerd", info.EventContainerCreation, now),
+	} {
+		if err := m.AddEvent(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	all, err := m.GetEvents(&Request{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 5 || all[0].ContainerName != "/docker" || all[2].EventType != info.EventOom {
+		t.Errorf("expected all the events by timestamp, got %+v", all)
+	}
+
+	for i, test := range []struct {
+		request  Request
+		expected int
+	}{
+		{Request{ContainerName: "/docker"}, 1},
+		{Request{ContainerName: "/docker", IncludeSubcontainers: true}, 4},
+		{Request{ContainerName: "/", IncludeSubcontainers: true}, 5},
+		{Request{EventType: map[info.EventType]bool{info.EventOom: true}}, 1},
+		{Request{StartTime: now.Add(-2 * time.Minute), EndTime: now.Add(-time.Minute)}, 1},
+		{Request{MaxEventsReturned: 2}, 2},
+	} {
+		events, err := m.GetEvents(&test.request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != test.expected {
+			t.Errorf("request %d: expected %d events, got %+v", i, test.expected, events)
+		}
+	}
+}
+
+func TestEviction(t *testing.T) {
+	m := NewEventManager(time.Hour, 2)
+	now := time.Now()
+	m.AddEvent(newEvent("/a", info.EventContainerCreation, now.Add(-2*time.Hour)))
+	m.AddEvent(newEvent("/b", info.EventContainerCreation, now.Add(-2*time.Minute)))
+	m.AddEvent(newEvent("/c", info.EventContainerCreation, now.Add(-time.Minute)))
+	m.AddEvent(newEvent("/d", info.EventContainerCreation, now))
+	events, err := m.GetEvents(&Request{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].ContainerName != "/c" || events[1].ContainerName != "/d" {
+		t.Errorf("expected the 2 latest events, got %+v", events)
+	}
+}
+
+func TestWatchEvents(t *testing.T) {
+	m := NewEventManager(0, 0)
+	watch, err := m.WatchEvents(&Request{EventType: map[info.EventType]bool{info.EventOom: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.AddEvent(newEvent("/a", info.EventContainerCreation, time.Now()))
+	m.AddEvent(newEvent("/a", info.EventOom, time.Now()))
+	select {
+	case e := <-watch.GetChannel():
+		if e.EventType != info.EventOom {
+			t.Errorf("expected the OOM event, got %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no event delivered")
+	}
+
+	m.StopWatch(watch.GetWatchId())
+	if _, ok := <-watch.GetChannel(); ok {
+		t.Error("expected the channel to be closed")
+	}
+	// Stopping twice is harmless.
+	m.StopWatch(watch.GetWatchId())
+}
+
+func TestPersistentEventManager(t *testing.T) {
+	dir, err := ioutil.TempDir("", "events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	m, err := NewPersistentEventManager(dir, time.Hour, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for i, name := range []string{"/a", "/b", "/c", "/d"} {
+		m.AddEvent(newEvent(name, info.EventContainerCreation, now.Add(time.Duration(i)*time.Second)))
+	}
+	m.AddEvent(&info.Event{
+		ContainerName: "/d",
+		EventType:     info.EventOom,
+		Timestamp:     now.Add(time.Minute),
+		EventData:     info.EventData{Oom: &info.OomEventData{Kills: 1, Pid: 42}},
+	})
+	// An event cut short by a crash is skipped.
+	file, err := os.OpenFile(path.Join(dir, eventLogName), os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"container_name": "/e", "timest`)
+	file.Close()
+
+	// The latest events are replayed after a restart, within the new limits.
+	m, err = NewPersistentEventManager(dir, time.Hour, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err := m.GetEvents(&Request{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].ContainerName != "/d" || events[1].EventData.Oom == nil || events[1].EventData.Oom.Pid != 42 {
+		t.Errorf("expected the 2 latest events, got %+v", events)
+	}
+
+	// Events added after the restart are appended.
+	m.AddEvent(newEvent("/f", info.EventContainerDeletion, now.Add(2*time.Minute)))
+	m, err = NewPersistentEventManager(dir, time.Hour, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if events, err = m.GetEvents(&Request{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 3 || events[2].ContainerName != "/f" {
+		t.Errorf("expected the events of both runs, got %+v", events)
+	}
+}
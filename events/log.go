@@ -0,0 +1,145 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path"
+
+	"github.com/golang/glog"
+	"github.com/google/cadvisor/info"
+)
+
+const eventLogName = "events.log"
+
+// Events in a file as JSON objects, one per line, so the events outlive cAdvisor. Events are
+// appended as they are added, and the file is rewritten with the events kept once most of its
+// events were forgotten.
+type eventLog struct {
+	path string
+	file *os.File
+
+	// Number of events in the file.
+	count int
+}
+
+// Opens the event log of a directory, creating it if needed. Returns the log and the events in it.
+// The log must be rewritten before events are appended, in case its last line is incomplete.
+func openEventLog(dir string) (*eventLog, []*info.Event, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, nil, err
+	}
+	self := &eventLog{path: path.Join(dir, eventLogName)}
+	events, err := self.read()
+	if err != nil {
+		return nil, nil, err
+	}
+	self.file, err = os.OpenFile(self.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, nil, err
+	}
+	self.count = len(events)
+	return self, events, nil
+}
+
+// Reads the events of the log, skipping those that cannot be decoded, e.g.: the last one when
+// cAdvisor died while writing it.
+func (self *eventLog) read() ([]*info.Event, error) {
+	file, err := os.Open(self.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var events []*info.Event
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) != 0 {
+			e := &info.Event{}
+			if err := json.Unmarshal(line, e); err != nil {
+				glog.Warningf("Skipping an invalid event of %q: %v", self.path, err)
+			} else {
+				events = append(events, e)
+			}
+		}
+		if err == io.EOF {
+			return events, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (self *eventLog) append(e *info.Event) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if _, err := self.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	self.count++
+	return nil
+}
+
+// Rewrites the log with the specified events once it holds more than twice as many.
+func (self *eventLog) compact(events []*info.Event) error {
+	if self.count <= 2*len(events)+100 {
+		return nil
+	}
+	return self.rewrite(events)
+}
+
+// Replaces the events of the log with the specified ones.
+func (self *eventLog) rewrite(events []*info.Event) error {
+	tmpPath := self.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(writer)
+	for _, e := range events {
+		if err := encoder.Encode(e); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, self.path); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(self.path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	self.file.Close()
+	self.file = file
+	self.count = len(events)
+	return nil
+}
@@ -0,0 +1,185 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/cadvisor/info"
+)
+
+// How long a sink may take to deliver an event.
+const sinkTimeout = 30 * time.Second
+
+// Events waiting to be delivered to a sink before new ones are dropped.
+const sinkQueueSize = 100
+
+// Somewhere events are delivered to, e.g.: a webhook.
+type Sink interface {
+	// Delivers an event.
+	Send(e *info.Event) error
+
+	// Description of the sink for logs.
+	String() string
+}
+
+// Posts events in JSON to a URL.
+type webhookSink struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookSink(url string) Sink {
+	return &webhookSink{
+		url:    url,
+		client: &http.Client{Timeout: sinkTimeout},
+	}
+}
+
+func (self *webhookSink) Send(e *info.Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	resp, err := self.client.Post(self.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %q", resp.Status)
+	}
+	return nil
+}
+
+func (self *webhookSink) String() string {
+	return fmt.Sprintf("webhook %q", self.url)
+}
+
+// Runs a command for every event, with the event in JSON on its standard input and its type and
+// container in the CADVISOR_EVENT_TYPE and CADVISOR_CONTAINER_NAME environment variables.
+type execSink struct {
+	command string
+}
+
+func NewExecSink(command string) Sink {
+	return &execSink{command: command}
+}
+
+func (self *execSink) Send(e *info.Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(self.command)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(),
+		"CADVISOR_EVENT_TYPE="+string(e.EventType),
+		"CADVISOR_CONTAINER_NAME="+e.ContainerName)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	timer := time.AfterFunc(sinkTimeout, func() {
+		cmd.Process.Kill()
+	})
+	err = cmd.Wait()
+	timer.Stop()
+	if err != nil {
+		return fmt.Errorf("%v: %s", err, output.String())
+	}
+	return nil
+}
+
+func (self *execSink) String() string {
+	return fmt.Sprintf("command %q", self.command)
+}
+
+// Token bucket allowing a number of events per minute, in bursts of up to that many.
+type rateLimiter struct {
+	perMinute float64
+	tokens    float64
+	last      time.Time
+}
+
+func newRateLimiter(perMinute int) *rateLimiter {
+	return &rateLimiter{
+		perMinute: float64(perMinute),
+		tokens:    float64(perMinute),
+	}
+}
+
+// Returns whether an event may be delivered at the specified time.
+func (self *rateLimiter) allow(now time.Time) bool {
+	if !self.last.IsZero() {
+		self.tokens += now.Sub(self.last).Minutes() * self.perMinute
+		if self.tokens > self.perMinute {
+			self.tokens = self.perMinute
+		}
+	}
+	self.last = now
+	if self.tokens < 1 {
+		return false
+	}
+	self.tokens--
+	return true
+}
+
+// Delivers the events of a watch to sinks until its channel is closed. Every sink delivers its
+// events in order, at most rateLimit per minute (no limit if 0), dropping the others.
+func Notify(channel *EventChannel, sinks []Sink, rateLimit int) {
+	queues := make([]chan *info.Event, len(sinks))
+	limiters := make([]*rateLimiter, len(sinks))
+	for i, sink := range sinks {
+		queues[i] = make(chan *info.Event, sinkQueueSize)
+		if rateLimit > 0 {
+			limiters[i] = newRateLimiter(rateLimit)
+		}
+		go deliver(sink, queues[i])
+	}
+	for e := range channel.GetChannel() {
+		for i, sink := range sinks {
+			if limiters[i] != nil && !limiters[i].allow(time.Now()) {
+				glog.Warningf("Dropped the %s event of %q, %s is over its rate limit", e.EventType, e.ContainerName, sink)
+				continue
+			}
+			select {
+			case queues[i] <- e:
+			default:
+				glog.Warningf("Dropped the %s event of %q, %s is not keeping up", e.EventType, e.ContainerName, sink)
+			}
+		}
+	}
+	for _, queue := range queues {
+		close(queue)
+	}
+}
+
+func deliver(sink Sink, queue chan *info.Event) {
+	for e := range queue {
+		if err := sink.Send(e); err != nil {
+			glog.Warningf("Failed to deliver the %s event of %q to %s: %v", e.EventType, e.ContainerName, sink, err)
+		}
+	}
+}
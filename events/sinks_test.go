@@ -0,0 +1,138 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/info"
+)
+
+func TestRateLimiter(t *testing.T) {
+	limiter := newRateLimiter(2)
+	now := time.Now()
+	if !limiter.allow(now) || !limiter.allow(now) || limiter.allow(now) {
+		t.Error("expected a burst of 2 events")
+	}
+	// A token every 30s.
+	if limiter.allow(now.Add(20*time.Second)) || !limiter.allow(now.Add(35*time.Second)) {
+		t.Error("expected an event after 30s")
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	received := make(chan *info.Event, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		e := &info.Event{}
+		if err := json.NewDecoder(r.Body).Decode(e); err != nil {
+			t.Error(err)
+		}
+		received <- e
+	}))
+	defer server.Close()
+
+	if err := NewWebhookSink(server.URL).Send(newEvent("/a", info.EventOom, time.Now())); err != nil {
+		t.Fatal(err)
+	}
+	if e := <-received; e.ContainerName != "/a" || e.EventType != info.EventOom {
+		t.Errorf("unexpected event %+v", e)
+	}
+}
+
+func TestExecSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "exec_sink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	script := path.Join(dir, "notify.sh")
+	output := path.Join(dir, "output")
+	err = ioutil.WriteFile(script, []byte("#!/bin/sh\necho $CADVISOR_EVENT_TYPE $CADVISOR_CONTAINER_NAME > "+output+"\ncat >> "+output+"\n"), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := NewExecSink(script).Send(newEvent("/a", info.EventOom, time.Now())); err != nil {
+		t.Fatal(err)
+	}
+	out, err := ioutil.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := string(out)
+	if len(lines) < 8 || lines[:8] != "oom /a\n{" {
+		t.Errorf("unexpected output of the command %q", lines)
+	}
+
+	if err := NewExecSink(path.Join(dir, "missing")).Send(newEvent("/a", info.EventOom, time.Now())); err == nil {
+		t.Error("expected an error running a missing command")
+	}
+}
+
+type fakeSink struct {
+	events chan *info.Event
+}
+
+func (self *fakeSink) Send(e *info.Event) error {
+	self.events <- e
+	return nil
+}
+
+func (self *fakeSink) String() string {
+	return "fake"
+}
+
+func TestNotify(t *testing.T) {
+	m := NewEventManager(0, 0)
+	watch, err := m.WatchEvents(&Request{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := &fakeSink{events: make(chan *info.Event, 10)}
+	done := make(chan bool)
+	go func() {
+		Notify(watch, []Sink{sink}, 2)
+		done <- true
+	}()
+	for _, name := range []string{"/a", "/b", "/c"} {
+		m.AddEvent(newEvent(name, info.EventContainerCreation, time.Now()))
+	}
+	m.StopWatch(watch.GetWatchId())
+	<-done
+
+	// The third event is over the rate limit.
+	for _, expected := range []string{"/a", "/b"} {
+		select {
+		case e := <-sink.events:
+			if e.ContainerName != expected {
+				t.Errorf("expected the event of %q, got %+v", expected, e)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("the event of %q was not delivered", expected)
+		}
+	}
+	select {
+	case e := <-sink.events:
+		t.Errorf("unexpected event %+v", e)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"github.com/golang/glog"
+	"github.com/google/cadvisor/container"
+	"github.com/google/cadvisor/container/containerd"
+	"github.com/google/cadvisor/container/cri"
+	"github.com/google/cadvisor/container/docker"
+	"github.com/google/cadvisor/container/lxc"
+	"github.com/google/cadvisor/container/mesos"
+	"github.com/google/cadvisor/container/raw"
+	"github.com/google/cadvisor/container/rkt"
+	"github.com/google/cadvisor/info"
+)
+
+// Registers the factories of the container runtimes, the raw one handling the other cgroups.
+func registerFactories(containerManager info.MachineInfoFactory) {
+	// Register Docker, only reporting failures when it is running.
+	if err := docker.Detect(); err != nil {
+		glog.Infof("Docker registration skipped: %v.", err)
+	} else if err := docker.Register(containerManager); err != nil {
+		glog.Errorf("Docker registration failed: %v.", err)
+	}
+
+	// Register the CRI runtime.
+	if err := cri.Register(containerManager); err != nil {
+		glog.Infof("CRI registration skipped: %v.", err)
+	}
+
+	// Register rkt.
+	if err := rkt.Register(containerManager); err != nil {
+		glog.Infof("rkt registration skipped: %v.", err)
+	}
+
+	// Register containerd.
+	if err := containerd.Register(containerManager); err != nil {
+		glog.Infof("containerd registration skipped: %v.", err)
+	}
+
+	// Register LXC and LXD.
+	if err := lxc.Register(containerManager); err != nil {
+		glog.Infof("LXC registration skipped: %v.", err)
+	}
+
+	// Register Mesos.
+	if err := mesos.Register(containerManager); err != nil {
+		glog.Infof("Mesos registration skipped: %v.", err)
+	}
+
+	// Register the raw driver.
+	if err := raw.Register(containerManager); err != nil {
+		glog.Fatalf("Raw registration failed: %v.", err)
+	}
+	glog.Infof("Monitoring containers with factories %v (systemd: %v)", container.RegisteredFactories(), docker.UseSystemd())
+}
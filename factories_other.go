@@ -0,0 +1,34 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !linux
+// +build !linux
+
+package main
+
+import (
+	"github.com/golang/glog"
+	"github.com/google/cadvisor/container"
+	"github.com/google/cadvisor/container/hcs"
+	"github.com/google/cadvisor/info"
+)
+
+// Without cgroups, only the Windows containers of the HCS are monitored, the HCS factory handling
+// the root container too.
+func registerFactories(containerManager info.MachineInfoFactory) {
+	if err := hcs.Register(containerManager); err != nil {
+		glog.Fatalf("HCS registration failed: %v.", err)
+	}
+	glog.Infof("Monitoring containers with factories %v", container.RegisteredFactories())
+}
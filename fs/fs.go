@@ -6,15 +6,17 @@ package fs
 /*
  extern int getBytesFree(const char *path, unsigned long long *bytes);
  extern int getBytesTotal(const char *path, unsigned long long *bytes);
+ extern int getInodes(const char *path, unsigned long long *total, unsigned long long *free);
 */
 import "C"
 
 import (
 	"bufio"
 	"fmt"
+	"io/ioutil"
 	"os"
-	"os/exec"
 	"path"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
@@ -67,7 +69,7 @@ func (self *RealFsInfo) GetFsInfoForPath(mountSet map[string]struct{}) ([]Fs, er
 		_, hasMount := mountSet[partition.mountpoint]
 		_, hasDevice := deviceSet[device]
 		if mountSet == nil || hasMount && !hasDevice {
-			total, free, err := getVfsStats(partition.mountpoint)
+			total, free, inodes, inodesFree, err := getVfsStats(partition.mountpoint)
 			if err != nil {
 				glog.Errorf("Statvfs failed. Error: %v", err)
 			} else {
@@ -77,7 +79,7 @@ func (self *RealFsInfo) GetFsInfoForPath(mountSet map[string]struct{}) ([]Fs, er
 					Major:  uint(partition.major),
 					Minor:  uint(partition.minor),
 				}
-				fs := Fs{deviceInfo, total, free, diskStatsMap[device]}
+				fs := Fs{deviceInfo, total, free, inodes, inodesFree, diskStatsMap[device]}
 				filesystems = append(filesystems, fs)
 			}
 		}
@@ -107,33 +109,66 @@ func getDiskStatsMap(diskStatsFile string) (map[string]DiskStats, error) {
 		}
 		// 8      50 sdd2 40 0 280 223 7 0 22 108 0 330 330
 		deviceName := path.Join("/dev", words[2])
-		wordLength := len(words)
-		offset := 3
-		var stats = make([]uint64, wordLength-offset)
-		if len(stats) < 11 {
-			return nil, fmt.Errorf("could not parse all 11 columns of /proc/diskstats")
+		diskStats, err := parseDiskStats(words)
+		if err != nil {
+			return nil, err
 		}
-		var error error
-		for i := offset; i < wordLength; i++ {
-			stats[i-offset], error = strconv.ParseUint(words[i], 10, 64)
-			if error != nil {
-				return nil, error
-			}
+		diskStatsMap[deviceName] = diskStats
+	}
+	return diskStatsMap, nil
+}
+
+// Parses the stats of a device from its line of /proc/diskstats, split in words.
+func parseDiskStats(words []string) (DiskStats, error) {
+	wordLength := len(words)
+	offset := 3
+	if wordLength-offset < 11 {
+		return DiskStats{}, fmt.Errorf("could not parse all 11 columns of /proc/diskstats")
+	}
+	var stats = make([]uint64, wordLength-offset)
+	var error error
+	for i := offset; i < wordLength; i++ {
+		stats[i-offset], error = strconv.ParseUint(words[i], 10, 64)
+		if error != nil {
+			return DiskStats{}, error
+		}
+	}
+	return DiskStats{
+		ReadsCompleted:  stats[0],
+		ReadsMerged:     stats[1],
+		SectorsRead:     stats[2],
+		ReadTime:        stats[3],
+		WritesCompleted: stats[4],
+		WritesMerged:    stats[5],
+		SectorsWritten:  stats[6],
+		WriteTime:       stats[7],
+		IoInProgress:    stats[8],
+		IoTime:          stats[9],
+		WeightedIoTime:  stats[10],
+	}, nil
+}
+
+// Returns the stats of all the block devices of the machine, partitions included, by major:minor.
+func GetDeviceDiskStats() (map[string]DiskStats, error) {
+	return getDeviceDiskStats("/proc/diskstats")
+}
+
+func getDeviceDiskStats(diskStatsFile string) (map[string]DiskStats, error) {
+	out, err := ioutil.ReadFile(diskStatsFile)
+	if err != nil {
+		return nil, err
+	}
+	diskStatsMap := make(map[string]DiskStats)
+	for _, line := range strings.Split(string(out), "\n") {
+		words := strings.Fields(line)
+		if len(words) == 0 {
+			continue
 		}
-		diskStats := DiskStats{
-			ReadsCompleted:  stats[0],
-			ReadsMerged:     stats[1],
-			SectorsRead:     stats[2],
-			ReadTime:        stats[3],
-			WritesCompleted: stats[4],
-			WritesMerged:    stats[5],
-			SectorsWritten:  stats[6],
-			WriteTime:       stats[7],
-			IoInProgress:    stats[8],
-			IoTime:          stats[9],
-			WeightedIoTime:  stats[10],
+		diskStats, err := parseDiskStats(words)
+		if err != nil {
+			return nil, err
 		}
-		diskStatsMap[deviceName] = diskStats
+		diskStatsMap[words[0]+":"+words[1]] = diskStats
 	}
 	return diskStatsMap, nil
 }
@@ -166,30 +201,64 @@ func (self *RealFsInfo) GetDirFsDevice(dir string) (*DeviceInfo, error) {
 	return nil, fmt.Errorf("could not find device with major: %d, minor: %d in cached partitions map", major, minor)
 }
 
+// Returns the disk usage of the files under dir like "du -s", without running it: the blocks
+// allocated to each file are counted, those of hard links once.
 func (self *RealFsInfo) GetDirUsage(dir string) (uint64, error) {
-	out, err := exec.Command("du", "-s", dir).CombinedOutput()
-	if err != nil {
-		return 0, fmt.Errorf("du command failed on %s with output %s - %s", dir, out, err)
+	return dirUsage(dir)
+}
+
+func dirUsage(dir string) (uint64, error) {
+	type inode struct {
+		dev uint64
+		ino uint64
 	}
-	usageInKb, err := strconv.ParseUint(strings.Fields(string(out))[0], 10, 64)
+	var usage uint64
+	seen := make(map[inode]struct{})
+	err := filepath.Walk(dir, func(name string, fi os.FileInfo, err error) error {
+		if err != nil {
+			// Files removed during the walk are not counted.
+			if os.IsNotExist(err) && name != dir {
+				return nil
+			}
+			return err
+		}
+		st, ok := fi.Sys().(*syscall.Stat_t)
+		if !ok {
+			return fmt.Errorf("cannot stat %q", name)
+		}
+		if st.Nlink > 1 && !fi.IsDir() {
+			key := inode{uint64(st.Dev), uint64(st.Ino)}
+			if _, ok := seen[key]; ok {
+				return nil
+			}
+			seen[key] = struct{}{}
+		}
+		// st_blocks is in 512-byte units whatever the block size of the filesystem.
+		usage += uint64(st.Blocks) * 512
+		return nil
+	})
 	if err != nil {
-		return 0, fmt.Errorf("cannot parse 'du' output %s - %s", out, err)
+		return 0, fmt.Errorf("failed to measure the disk usage of %s - %s", dir, err)
 	}
-	return usageInKb * 1024, nil
+	return usage, nil
 }
 
-func getVfsStats(path string) (total uint64, free uint64, err error) {
+func getVfsStats(path string) (total uint64, free uint64, inodes uint64, inodesFree uint64, err error) {
 	_p0, err := syscall.BytePtrFromString(path)
 	if err != nil {
-		return 0, 0, err
+		return 0, 0, 0, 0, err
+	}
+	res, err := C.getBytesFree((*C.char)(unsafe.Pointer(_p0)), (*C.ulonglong)(unsafe.Pointer(&free)))
+	if res != 0 {
+		return 0, 0, 0, 0, err
 	}
-	res, err := C.getBytesFree((*C.char)(unsafe.Pointer(_p0)), (*_Ctype_ulonglong)(unsafe.Pointer(&free)))
+	res, err = C.getBytesTotal((*C.char)(unsafe.Pointer(_p0)), (*C.ulonglong)(unsafe.Pointer(&total)))
 	if res != 0 {
-		return 0, 0, err
+		return 0, 0, 0, 0, err
 	}
-	res, err = C.getBytesTotal((*C.char)(unsafe.Pointer(_p0)), (*_Ctype_ulonglong)(unsafe.Pointer(&total)))
+	res, err = C.getInodes((*C.char)(unsafe.Pointer(_p0)), (*C.ulonglong)(unsafe.Pointer(&inodes)), (*C.ulonglong)(unsafe.Pointer(&inodesFree)))
 	if res != 0 {
-		return 0, 0, err
+		return 0, 0, 0, 0, err
 	}
-	return total, free, nil
+	return total, free, inodes, inodesFree, nil
 }
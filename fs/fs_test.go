@@ -1,6 +1,10 @@
 package fs
 
 import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
 	"testing"
 )
 
@@ -52,3 +56,73 @@ func TestFileNotExist(t *testing.T) {
 		t.Fatalf("getDiskStatsMap must not error for absent file: %s", err)
 	}
 }
+
+func TestGetDeviceDiskStats(t *testing.T) {
+	diskStats, err := getDeviceDiskStats("test_resources/diskstats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diskStats) != 52 {
+		t.Errorf("expected the stats of 52 devices, got %d", len(diskStats))
+	}
+	expected := DiskStats{40, 0, 280, 223, 7, 0, 22, 108, 0, 330, 330}
+	if stats := diskStats["8:50"]; stats != expected {
+		t.Errorf("expected %+v for sdd2, got %+v", expected, stats)
+	}
+}
+
+func TestDirUsage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dir_usage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.Mkdir(path.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path.Join(dir, "sub/file"), make([]byte, 64*1024), 0644); err != nil {
+		t.Fatal(err)
+	}
+	usage, err := dirUsage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage < 64*1024 {
+		t.Errorf("expected a usage of at least 64KiB, got %d", usage)
+	}
+
+	// Hard links are counted once.
+	if err := os.Link(path.Join(dir, "sub/file"), path.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	linked, err := dirUsage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if linked != usage {
+		t.Errorf("expected the hard link not to change the usage %d, got %d", usage, linked)
+	}
+
+	if _, err := dirUsage(path.Join(dir, "missing")); err == nil {
+		t.Errorf("expected an error for a missing directory")
+	}
+}
+
+func BenchmarkDirUsage(b *testing.B) {
+	dir, err := ioutil.TempDir("", "dir_usage")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for i := 0; i < 100; i++ {
+		if err := ioutil.WriteFile(path.Join(dir, fmt.Sprintf("file%d", i)), []byte("data"), 0644); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := dirUsage(dir); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
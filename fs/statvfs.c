@@ -21,3 +21,14 @@ int getBytesTotal(const char *path, unsigned long long *bytes) {
 	*bytes = buf.f_frsize * buf.f_blocks;
 	return 0;
 }
+
+int getInodes(const char *path, unsigned long long *total, unsigned long long *free) {
+	struct statvfs buf;
+	int res;
+	if ((res = statvfs(path, &buf)) && res != 0) {
+		return -1;
+	}
+	*total = buf.f_files;
+	*free = buf.f_ffree;
+	return 0;
+}
@@ -8,9 +8,11 @@ type DeviceInfo struct {
 
 type Fs struct {
 	DeviceInfo
-	Capacity  uint64
-	Free      uint64
-	DiskStats DiskStats
+	Capacity   uint64
+	Free       uint64
+	Inodes     uint64
+	InodesFree uint64
+	DiskStats  DiskStats
 }
 
 type DiskStats struct {
@@ -28,7 +30,7 @@ type DiskStats struct {
 }
 
 type FsInfo interface {
-	// Returns capacity and free space, in bytes, of all the ext2, ext3, ext4 filesystems on the host.
+	// Returns capacity and free space, in bytes, and the total and free inodes, of all the ext2, ext3, ext4 filesystems on the host.
 	GetGlobalFsInfo() ([]Fs, error)
 
 	// Returns capacity and free space, in bytes, of the set of mounts passed.
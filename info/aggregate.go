@@ -0,0 +1,43 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package info
+
+// Usage summed across all the containers that share a label value.
+type AggregateStats struct {
+	// Names of the containers included in the aggregate.
+	Containers []string `json:"containers"`
+
+	// Sum of the cumulative CPU usage of the containers.
+	// Units: nanoseconds.
+	CpuUsageTotal uint64 `json:"cpu_usage_total"`
+
+	// Sum of the most recent CPU usage rate of the containers.
+	// Units: millicores.
+	CpuUsageRate uint64 `json:"cpu_usage_rate"`
+
+	// Sum of the current memory usage of the containers.
+	// Units: Bytes.
+	MemoryUsage uint64 `json:"memory_usage"`
+
+	// Sum of the current working set of the containers.
+	// Units: Bytes.
+	MemoryWorkingSet uint64 `json:"memory_working_set"`
+
+	// Sum of the cumulative bytes received by the containers.
+	RxBytes uint64 `json:"rx_bytes"`
+
+	// Sum of the cumulative bytes transmitted by the containers.
+	TxBytes uint64 `json:"tx_bytes"`
+}
@@ -15,6 +15,7 @@
 package info
 
 import (
+	"math"
 	"reflect"
 	"time"
 )
@@ -22,7 +23,25 @@ import (
 type CpuSpec struct {
 	Limit    uint64 `json:"limit"`
 	MaxLimit uint64 `json:"max_limit"`
-	Mask     string `json:"mask,omitempty"`
+	// CPUs of the cpuset of the container, e.g.: 0-3,8.
+	Mask string `json:"mask,omitempty"`
+	// Memory nodes of the cpuset of the container.
+	Mems string `json:"mems,omitempty"`
+	// CPUs the container can actually run on: the CPUs of its cpuset that are online and
+	// allowed by its parents.
+	EffectiveCpus string `json:"effective_cpus,omitempty"`
+
+	// CPU time the container can use in each CFS period, 0 if unlimited.
+	// Units: microseconds.
+	Quota uint64 `json:"quota,omitempty"`
+	// Length of the CFS periods.
+	// Units: microseconds.
+	Period uint64 `json:"period,omitempty"`
+}
+
+type ProcessSpec struct {
+	// Maximum number of threads of the pids cgroup of the container, 0 when unlimited.
+	Limit uint64 `json:"limit,omitempty"`
 }
 
 type MemorySpec struct {
@@ -49,6 +68,36 @@ type ContainerSpec struct {
 	HasNetwork bool `json:"has_network"`
 
 	HasFilesystem bool `json:"has_filesystem"`
+
+	HasDiskIo bool `json:"has_diskio"`
+
+	HasProcesses bool        `json:"has_processes"`
+	Processes    ProcessSpec `json:"processes,omitempty"`
+
+	// Metadata labels associated with this container.
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Image the container runs, if known.
+	Image string `json:"image,omitempty"`
+
+	// Environment variables of the container that are exported as metadata.
+	Envs map[string]string `json:"envs,omitempty"`
+
+	HasCustomMetrics bool         `json:"has_custom_metrics"`
+	CustomMetrics    []MetricSpec `json:"custom_metrics,omitempty"`
+
+	// The last changes of the cpuset of the container since cAdvisor started monitoring it,
+	// oldest first.
+	CpusetChanges []CpusetChange `json:"cpuset_changes,omitempty"`
+}
+
+// A cpuset the container was moved to.
+type CpusetChange struct {
+	// When the change was noticed, up to 30 seconds after it happened.
+	Timestamp     time.Time `json:"timestamp"`
+	Cpus          string    `json:"cpus"`
+	Mems          string    `json:"mems"`
+	EffectiveCpus string    `json:"effective_cpus"`
 }
 
 // Container reference contains enough information to uniquely identify a container
@@ -63,6 +112,16 @@ type ContainerReference struct {
 	// Namespace under which the aliases of a container are unique.
 	// An example of a namespace is "docker" for Docker containers.
 	Namespace string `json:"namespace,omitempty"`
+
+	// Metadata labels of the container, made available to storage drivers.
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Image the container runs, if known (e.g.: for Docker containers).
+	Image string `json:"image,omitempty"`
+
+	// Environment variables of the container that are exported as metadata, made available to
+	// storage drivers.
+	Envs map[string]string `json:"envs,omitempty"`
 }
 
 // ContainerInfoQuery is used when users check a container info from the REST api.
@@ -70,6 +129,15 @@ type ContainerReference struct {
 type ContainerInfoRequest struct {
 	// Max number of stats to return.
 	NumStats int `json:"num_stats,omitempty"`
+
+	// Only the stats timestamped from Start to End are returned, the latest NumStats of them. A
+	// zero Start or End leaves the range open on that side.
+	Start time.Time `json:"start,omitempty"`
+	End   time.Time `json:"end,omitempty"`
+
+	// Thins out the stats returned to at most one per Resolution (in nanoseconds), keeping the
+	// latest, to bound the stats of a long time range. All are returned if zero.
+	Resolution time.Duration `json:"resolution,omitempty"`
 }
 
 type ContainerInfo struct {
@@ -83,6 +151,22 @@ type ContainerInfo struct {
 
 	// Historical statistics gathered from the container.
 	Stats []*ContainerStats `json:"stats,omitempty"`
+
+	// Failures to collect the stats of the container, nil if the last collection succeeded.
+	Errors *CollectionErrors `json:"errors,omitempty"`
+}
+
+// Failures to collect the stats of a container.
+type CollectionErrors struct {
+	// Error of the last failed collection and its time.
+	LastError     string    `json:"last_error"`
+	LastErrorTime time.Time `json:"last_error_time"`
+
+	// Number of collections that failed since the last successful one.
+	ConsecutiveFailures uint64 `json:"consecutive_failures"`
+
+	// Number of collections that failed since the container is monitored.
+	TotalFailures uint64 `json:"total_failures"`
 }
 
 // ContainerInfo may be (un)marshaled by json or other en/decoder. In that
@@ -176,7 +260,67 @@ type CpuStats struct {
 		// Unit: nanoseconds
 		System uint64 `json:"system"`
 	} `json:"usage"`
+
+	// Throttling of the container by its CFS quota.
+	CFS CpuCFS `json:"cfs"`
+
+	// Average number of runnable and uninterruptible threads x 1000, over the last 10 seconds.
+	// Only reported with --enable_load_reader.
 	Load int32 `json:"load"`
+
+	// Pressure stall information of the CPU.
+	PSI PSIStats `json:"psi"`
+
+	// Context switches of the threads of the container.
+	ContextSwitches ContextSwitchStats `json:"context_switches"`
+}
+
+// Context switches of the live threads of a container, from /proc/<tid>/status.
+// The switches of the threads that exited are not counted.
+type ContextSwitchStats struct {
+	// Switches of threads that blocked or yielded.
+	Voluntary uint64 `json:"voluntary"`
+	// Switches of threads that were preempted, e.g.: when their time slice ran out or an
+	// interrupt or softirq ran on their CPU.
+	Involuntary uint64 `json:"involuntary"`
+}
+
+// Pressure stall information: the share of time some or all of the
+// non-idle threads were stalled waiting for a resource.
+type PSIStats struct {
+	Some PSIData `json:"some,omitempty"`
+	Full PSIData `json:"full,omitempty"`
+}
+
+type PSIData struct {
+	// Percent of time stalled over the last 10, 60 and 300 seconds.
+	Avg10  float64 `json:"avg10"`
+	Avg60  float64 `json:"avg60"`
+	Avg300 float64 `json:"avg300"`
+
+	// Cumulative time stalled.
+	// Units: Microseconds.
+	Total uint64 `json:"total"`
+}
+
+type CpuCFS struct {
+	// Number of CFS periods in which the container was runnable.
+	Periods uint64 `json:"periods"`
+
+	// Number of those periods in which the container used its whole quota and was throttled.
+	ThrottledPeriods uint64 `json:"throttled_periods"`
+
+	// Time the container was throttled for.
+	// Units: nanoseconds
+	ThrottledTime uint64 `json:"throttled_time"`
+}
+
+// Number of threads of a container by state, when the stats were collected.
+type LoadStats struct {
+	NrRunning         uint64 `json:"nr_running"`
+	NrSleeping        uint64 `json:"nr_sleeping"`
+	NrStopped         uint64 `json:"nr_stopped"`
+	NrUninterruptible uint64 `json:"nr_uninterruptible"`
 }
 
 type PerDiskStats struct {
@@ -194,6 +338,14 @@ type DiskIoStats struct {
 	IoWaitTime     []PerDiskStats `json:"io_wait_time,omitempty"`
 	IoMerged       []PerDiskStats `json:"io_merged,omitempty"`
 	IoTime         []PerDiskStats `json:"io_time,omitempty"`
+
+	// The I/O counted by the throttling policy of the blkio cgroup, for all devices whatever their
+	// I/O scheduler.
+	ThrottleServiceBytes []PerDiskStats `json:"throttle_io_service_bytes,omitempty"`
+	ThrottleServiced     []PerDiskStats `json:"throttle_io_serviced,omitempty"`
+
+	// Pressure stall information of the I/O.
+	PSI PSIStats `json:"psi"`
 }
 
 type MemoryStats struct {
@@ -203,12 +355,47 @@ type MemoryStats struct {
 	Usage uint64 `json:"usage"`
 
 	// The amount of working set memory, this includes recently accessed memory,
-	// dirty memory, and kernel memory. Working set is <= "usage".
+	// dirty memory, and kernel memory: the usage minus the inactive page cache,
+	// which the kernel reclaims first. Working set is <= "usage".
 	// Units: Bytes.
 	WorkingSet uint64 `json:"working_set"`
 
+	// Breakdown of the usage of the container and its subcontainers, from
+	// memory.stat: page cache, anonymous memory, swap used, and page cache
+	// mapped in processes.
+	// Units: Bytes.
+	Cache      uint64 `json:"cache"`
+	RSS        uint64 `json:"rss"`
+	Swap       uint64 `json:"swap"`
+	MappedFile uint64 `json:"mapped_file"`
+
+	// Limits of the memory and memory+swap usage of the container, the
+	// lowest ones of the container and its ancestors.
+	// Units: Bytes.
+	HierarchicalLimit     uint64 `json:"hierarchical_limit,omitempty"`
+	HierarchicalSwapLimit uint64 `json:"hierarchical_swap_limit,omitempty"`
+
+	// Cumulative number of times the usage hit the limit, and of processes
+	// killed by the OOM killer.
+	Failcnt  uint64 `json:"failcnt"`
+	OomKills uint64 `json:"oom_kills"`
+
 	ContainerData    MemoryStatsMemoryData `json:"container_data,omitempty"`
 	HierarchicalData MemoryStatsMemoryData `json:"hierarchical_data,omitempty"`
+
+	// Pages of the container and its subcontainers on each NUMA node.
+	NumaStats MemoryNumaStats `json:"numa_stats,omitempty"`
+
+	// Pressure stall information of the memory.
+	PSI PSIStats `json:"psi"`
+}
+
+// Pages of memory by NUMA node id, from memory.numa_stat.
+// Units: Pages.
+type MemoryNumaStats struct {
+	File        map[uint8]uint64 `json:"file,omitempty"`
+	Anon        map[uint8]uint64 `json:"anon,omitempty"`
+	Unevictable map[uint8]uint64 `json:"unevictable,omitempty"`
 }
 
 type MemoryStatsMemoryData struct {
@@ -216,6 +403,89 @@ type MemoryStatsMemoryData struct {
 	Pgmajfault uint64 `json:"pgmajfault"`
 }
 
+type ProcessStats struct {
+	// Number of processes and threads of the container.
+	ProcessCount   uint64 `json:"process_count"`
+	ThreadsCurrent uint64 `json:"threads_current"`
+
+	// Maximum number of threads of the pids cgroup of the container, 0 when unlimited.
+	ThreadsMax uint64 `json:"threads_max"`
+
+	// Number of threads counted against ThreadsMax by the pids cgroup, including those of
+	// the cgroups nested in the one of the container.
+	PidsCurrent uint64 `json:"pids_current"`
+
+	// Number of open file descriptors of the processes, and of those that are sockets.
+	FdCount     uint64 `json:"fd_count"`
+	SocketCount uint64 `json:"socket_count"`
+
+	// Ulimits of the first process of the container.
+	Ulimits []UlimitSpec `json:"ulimits,omitempty"`
+}
+
+type UlimitSpec struct {
+	// e.g.: max_open_files
+	Name string `json:"name"`
+
+	// Limits, -1 when unlimited.
+	SoftLimit int64 `json:"soft_limit"`
+	HardLimit int64 `json:"hard_limit"`
+}
+
+type HugetlbStats struct {
+	// Current, maximum and limit of the usage of the hugepages of a size.
+	// Units: Bytes.
+	Usage    uint64 `json:"usage"`
+	MaxUsage uint64 `json:"max_usage"`
+	Limit    uint64 `json:"limit"`
+
+	// Number of allocations that failed because of the limit.
+	Failcnt uint64 `json:"failcnt"`
+}
+
+type AcceleratorStats struct {
+	// Make of the accelerator (e.g.: nvidia), its model and its unique id.
+	Make  string `json:"make"`
+	Model string `json:"model"`
+	Id    string `json:"id"`
+
+	// Total and used memory of the accelerator, by all its users.
+	// Units: Bytes.
+	MemoryTotal uint64 `json:"memory_total"`
+	MemoryUsed  uint64 `json:"memory_used"`
+
+	// Percent of time the accelerator was busy over the last sample period
+	// of the driver (e.g.: 1s).
+	DutyCycle uint64 `json:"duty_cycle"`
+}
+
+type PerfStat struct {
+	// Name of the event, e.g.: cycles.
+	Name string `json:"name"`
+
+	// Count of the event on all CPUs since the container is monitored.
+	Value uint64 `json:"value"`
+
+	// Fraction of the time the event was counted, the value is scaled up
+	// from it when the hardware counters are shared by too many events.
+	ScalingRatio float64 `json:"scaling_ratio"`
+}
+
+type ResctrlStats struct {
+	// Id of the L3 cache, e.g.: 00 for the first socket.
+	Domain string `json:"domain"`
+
+	// Occupancy of the last level cache.
+	// Units: Bytes.
+	LlcOccupancy uint64 `json:"llc_occupancy"`
+
+	// Cumulative memory traffic to the memory of the local and of the
+	// remote NUMA nodes.
+	// Units: Bytes.
+	MemoryBandwidthLocal  uint64 `json:"memory_bandwidth_local"`
+	MemoryBandwidthRemote uint64 `json:"memory_bandwidth_remote"`
+}
+
 type NetworkStats struct {
 	// Cumulative count of bytes received.
 	RxBytes uint64 `json:"rx_bytes"`
@@ -298,6 +568,29 @@ type FsStats struct {
 	// last update of this field.  This can provide an easy measure of both
 	// I/O completion time and the backlog that may be accumulating.
 	WeightedIoTime uint64 `json:"weighted_io_time"`
+
+	// Number of inodes of the filesystem, and of those that are free.
+	Inodes     uint64 `json:"inodes,omitempty"`
+	InodesFree uint64 `json:"inodes_free,omitempty"`
+}
+
+// Usage of a volume or bind mount of a container.
+type VolumeStats struct {
+	// Path of the volume in the container, e.g.: /var/lib/mysql.
+	MountPoint string `json:"mount_point"`
+
+	// Path of the volume on the host.
+	Source string `json:"source"`
+
+	// The block device of the filesystem holding the volume.
+	Device string `json:"device,omitempty"`
+
+	// Capacity of the filesystem holding the volume.
+	// Units: Bytes.
+	Limit uint64 `json:"capacity"`
+
+	// Bytes used by the volume.
+	Usage uint64 `json:"usage"`
 }
 
 type ContainerStats struct {
@@ -310,6 +603,39 @@ type ContainerStats struct {
 
 	// Filesystem statistics
 	Filesystem []FsStats `json:"filesystem,omitempty"`
+
+	// Usage of the volumes and bind mounts of the container.
+	Volumes []VolumeStats `json:"volumes,omitempty"`
+
+	// Metrics of the application in the container, by their name.
+	CustomMetrics map[string][]MetricVal `json:"custom_metrics,omitempty"`
+
+	// Processes, threads and file descriptors of the container.
+	Processes ProcessStats `json:"processes,omitempty"`
+
+	// Hugepages usage, keyed by page size (e.g.: 2MB, 1GB).
+	Hugetlb map[string]HugetlbStats `json:"hugetlb,omitempty"`
+
+	// Usage of the GPUs the container has access to.
+	Accelerators []AcceleratorStats `json:"accelerators,omitempty"`
+
+	// Counts of the hardware events of --perf_events_config.
+	PerfStats []PerfStat `json:"perf_stats,omitempty"`
+
+	// Cache and memory bandwidth usage by L3 cache, only reported with --enable_resctrl.
+	Resctrl []ResctrlStats `json:"resctrl,omitempty"`
+
+	// Processes using the most CPU and memory, only reported with --top_processes.
+	TopCpuProcesses    []TopProcess `json:"top_cpu_processes,omitempty"`
+	TopMemoryProcesses []TopProcess `json:"top_memory_processes,omitempty"`
+
+	// Threads by state, only reported with --enable_load_reader.
+	TaskStats LoadStats `json:"task_stats,omitempty"`
+
+	// Cumulative energy consumed by the CPU packages on behalf of the container, estimated from its
+	// share of the CPU time of the machine. Only reported on machines with RAPL counters.
+	// Units: Microjoules.
+	Energy uint64 `json:"energy,omitempty"`
 }
 
 func timeEq(t1, t2 time.Time, tolerance time.Duration) bool {
@@ -370,6 +696,110 @@ func (a *ContainerStats) StatsEq(b *ContainerStats) bool {
 	return true
 }
 
+// Like StatsEq but comparing all the stats, except the timestamp. The values of gauges are equal
+// when their relative difference is at most epsilon, e.g. 0.01 for 1%, cumulative counters must be
+// equal.
+func (a *ContainerStats) StatsApproxEq(b *ContainerStats, epsilon float64) bool {
+	va, vb := reflect.ValueOf(a).Elem(), reflect.ValueOf(b).Elem()
+	for i := 0; i < va.NumField(); i++ {
+		if va.Type().Field(i).Name == "Timestamp" {
+			continue
+		}
+		if !approxEq(va.Field(i), vb.Field(i), epsilon, false) {
+			return false
+		}
+	}
+	return true
+}
+
+// The fields of the stats which are gauges rather than cumulative counters, by type. Values in
+// the maps and slices of a gauge field are gauges too.
+var gaugeFields = map[reflect.Type]map[string]bool{
+	reflect.TypeOf(CpuStats{}):         {"Load": true},
+	reflect.TypeOf(PSIData{}):          {"Avg10": true, "Avg60": true, "Avg300": true},
+	reflect.TypeOf(LoadStats{}):        {"NrRunning": true, "NrSleeping": true, "NrStopped": true, "NrUninterruptible": true},
+	reflect.TypeOf(MemoryStats{}):      {"Usage": true, "WorkingSet": true, "Cache": true, "RSS": true, "Swap": true, "MappedFile": true},
+	reflect.TypeOf(MemoryNumaStats{}):  {"File": true, "Anon": true, "Unevictable": true},
+	reflect.TypeOf(ProcessStats{}):     {"ProcessCount": true, "ThreadsCurrent": true, "PidsCurrent": true, "FdCount": true, "SocketCount": true},
+	reflect.TypeOf(HugetlbStats{}):     {"Usage": true},
+	reflect.TypeOf(AcceleratorStats{}): {"MemoryUsed": true, "DutyCycle": true},
+	reflect.TypeOf(PerfStat{}):         {"ScalingRatio": true},
+	reflect.TypeOf(ResctrlStats{}):     {"LlcOccupancy": true},
+	reflect.TypeOf(FsStats{}):          {"Usage": true, "IoInProgress": true, "InodesFree": true},
+	reflect.TypeOf(VolumeStats{}):      {"Usage": true},
+	reflect.TypeOf(TopProcess{}):       {"PercentCpu": true, "Rss": true},
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Numbers of gauges are compared with epsilon, others must be equal.
+func approxEq(a, b reflect.Value, epsilon float64, gauge bool) bool {
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if !gauge {
+			return a.Int() == b.Int()
+		}
+		return numbersApproxEq(float64(a.Int()), float64(b.Int()), epsilon)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		// Counters are compared as integers, they may exceed the precision of a float64.
+		if !gauge {
+			return a.Uint() == b.Uint()
+		}
+		return numbersApproxEq(float64(a.Uint()), float64(b.Uint()), epsilon)
+	case reflect.Float32, reflect.Float64:
+		if !gauge {
+			return a.Float() == b.Float()
+		}
+		return numbersApproxEq(a.Float(), b.Float(), epsilon)
+	case reflect.Bool:
+		return a.Bool() == b.Bool()
+	case reflect.String:
+		return a.String() == b.String()
+	case reflect.Ptr, reflect.Interface:
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() == b.IsNil()
+		}
+		return approxEq(a.Elem(), b.Elem(), epsilon, gauge)
+	case reflect.Slice, reflect.Array:
+		if a.Len() != b.Len() {
+			return false
+		}
+		for i := 0; i < a.Len(); i++ {
+			if !approxEq(a.Index(i), b.Index(i), epsilon, gauge) {
+				return false
+			}
+		}
+		return true
+	case reflect.Map:
+		if a.Len() != b.Len() {
+			return false
+		}
+		for _, key := range a.MapKeys() {
+			value := b.MapIndex(key)
+			if !value.IsValid() || !approxEq(a.MapIndex(key), value, epsilon, gauge) {
+				return false
+			}
+		}
+		return true
+	case reflect.Struct:
+		if a.Type() == timeType {
+			return a.Interface().(time.Time).Equal(b.Interface().(time.Time))
+		}
+		gauges := gaugeFields[a.Type()]
+		for i := 0; i < a.NumField(); i++ {
+			if !approxEq(a.Field(i), b.Field(i), epsilon, gauges[a.Type().Field(i).Name]) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a.Interface(), b.Interface())
+}
+
+func numbersApproxEq(a, b, epsilon float64) bool {
+	return math.Abs(a-b) <= epsilon*math.Max(math.Abs(a), math.Abs(b))
+}
+
 // Saturate CPU usage to 0.
 func calculateCpuUsage(prev, cur uint64) uint64 {
 	if prev > cur {
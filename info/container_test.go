@@ -77,3 +77,76 @@ func createStats(cpuUsage, memUsage uint64, timestamp time.Time) *ContainerStats
 	stats.Timestamp = timestamp
 	return stats
 }
+
+func TestNewMachineStats(t *testing.T) {
+	machine := &MachineInfo{
+		NumCores:       4,
+		MemoryCapacity: 8192,
+		Filesystems:    []FsInfo{{Device: "/dev/sda1", Capacity: 100}, {Device: "/dev/sdb1", Capacity: 200}},
+	}
+	root := &ContainerStats{
+		Timestamp:  time.Unix(1400000000, 0),
+		Filesystem: []FsStats{{Device: "/dev/sda1", Usage: 10}, {Device: "/dev/sdb1", Usage: 20}},
+	}
+	root.Cpu.Usage.Total = 1000
+	root.Memory.Usage = 2048
+	root.Network.RxBytes = 5
+
+	stats := NewMachineStats(machine, root)
+	if stats.NumCores != 4 || stats.MemoryCapacity != 8192 || stats.FsCapacity != 300 {
+		t.Errorf("unexpected capacity in %+v", stats)
+	}
+	if stats.CpuUsage != 1000 || stats.MemoryUsage != 2048 || stats.FsUsage != 30 || stats.Network.RxBytes != 5 {
+		t.Errorf("unexpected usage in %+v", stats)
+	}
+	if !stats.Timestamp.Equal(root.Timestamp) {
+		t.Errorf("expected timestamp %v, got %v", root.Timestamp, stats.Timestamp)
+	}
+}
+
+func TestStatsApproxEq(t *testing.T) {
+	newStats := func() *ContainerStats {
+		s := &ContainerStats{}
+		s.Cpu.Usage.Total = 1000000
+		s.Cpu.Usage.PerCpu = []uint64{400000, 600000}
+		s.Memory.Usage = 1000
+		s.DiskIo.IoServiceBytes = []PerDiskStats{{Major: 8, Stats: map[string]uint64{"Read": 1000}}}
+		s.Network.RxBytes = 1000
+		s.Filesystem = []FsStats{{Device: "/dev/sda1", Usage: 100, ReadsCompleted: 1000}}
+		s.Processes.FdCount = 1000
+		s.TopCpuProcesses = []TopProcess{{Pid: 1, PercentCpu: 10, Rss: 1000}}
+		return s
+	}
+	for _, c := range []struct {
+		name   string
+		change func(s *ContainerStats)
+		equal  bool
+	}{
+		{"memory usage within 1%", func(s *ContainerStats) { s.Memory.Usage = 1005 }, true},
+		{"open files within 1%", func(s *ContainerStats) { s.Processes.FdCount = 1005 }, true},
+		{"RSS of a process within 1%", func(s *ContainerStats) { s.TopCpuProcesses[0].Rss = 1005 }, true},
+		{"doubled memory usage", func(s *ContainerStats) { s.Memory.Usage = 2000 }, false},
+		{"CPU usage", func(s *ContainerStats) { s.Cpu.Usage.Total++ }, false},
+		{"CPU usage of a core", func(s *ContainerStats) { s.Cpu.Usage.PerCpu[1]++ }, false},
+		{"bytes read from a disk", func(s *ContainerStats) { s.DiskIo.IoServiceBytes[0].Stats["Read"]++ }, false},
+		{"bytes received", func(s *ContainerStats) { s.Network.RxBytes++ }, false},
+		{"reads of a filesystem", func(s *ContainerStats) { s.Filesystem[0].ReadsCompleted++ }, false},
+		{"device of a filesystem", func(s *ContainerStats) { s.Filesystem[0].Device = "/dev/sdb1" }, false},
+		{"pid of a process", func(s *ContainerStats) { s.TopCpuProcesses[0].Pid = 2 }, false},
+		{"energy", func(s *ContainerStats) { s.Energy++ }, false},
+		{"timestamp", func(s *ContainerStats) { s.Timestamp = time.Unix(1, 0) }, true},
+	} {
+		a, b := newStats(), newStats()
+		c.change(b)
+		if eq := a.StatsApproxEq(b, 0.01); eq != c.equal {
+			t.Errorf("%s: expected equal to be %v with 1%% epsilon", c.name, c.equal)
+		}
+		if c.equal && c.name != "timestamp" && a.StatsApproxEq(b, 0) {
+			t.Errorf("%s: expected different stats to differ without epsilon", c.name)
+		}
+	}
+	a := newStats()
+	if !a.StatsApproxEq(a, 0) {
+		t.Errorf("expected stats to equal themselves")
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package info
+
+import "time"
+
+// Type of an event of a container.
+type EventType string
+
+const (
+	EventContainerCreation EventType = "containerCreation"
+	EventContainerDeletion EventType = "containerDeletion"
+	EventOom               EventType = "oom"
+	EventThreshold         EventType = "threshold"
+)
+
+// An event of a container, e.g.: its creation, a process of it killed by the OOM killer or its usage
+// crossing a threshold.
+type Event struct {
+	// Absolute name of the container the event is about.
+	ContainerName string `json:"container_name"`
+
+	// When the event happened, or was noticed.
+	Timestamp time.Time `json:"timestamp"`
+
+	EventType EventType `json:"event_type"`
+
+	// Details of the event, depending on its type.
+	EventData EventData `json:"event_data,omitempty"`
+}
+
+// Details of an event, only the field of the type of the event is set.
+type EventData struct {
+	Oom       *OomEventData       `json:"oom,omitempty"`
+	Threshold *ThresholdEventData `json:"threshold,omitempty"`
+}
+
+// Details of an OOM event.
+type OomEventData struct {
+	// Number of processes of the container killed by the OOM killer since the previous sample.
+	Kills uint64 `json:"kills"`
+
+	// The process killed, when read from the kernel log.
+	Pid         int    `json:"pid,omitempty"`
+	ProcessName string `json:"process_name,omitempty"`
+
+	// The container whose memory limit was reached, the container of the process or one of its
+	// parents, "/" if the whole machine ran out of memory. Only when read from the kernel log.
+	LimitContainerName string `json:"limit_container_name,omitempty"`
+
+	// Memory usage of LimitContainerName when the process was killed, in bytes. Only when read
+	// from the kernel log.
+	MemoryUsage uint64 `json:"memory_usage,omitempty"`
+}
+
+// Details of a threshold event: a metric of the container went above a threshold, or back below.
+type ThresholdEventData struct {
+	// Name and expression of the threshold, e.g.: "cpu.usage > 90% for 60s".
+	Name       string `json:"name"`
+	Expression string `json:"expression"`
+
+	// Value of the metric compared to the threshold.
+	Value float64 `json:"value"`
+
+	// Whether the value went above the threshold, or back below.
+	Firing bool `json:"firing"`
+
+	// The sample of the container that crossed the threshold.
+	Stats *ContainerStats `json:"stats,omitempty"`
+}
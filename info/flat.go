@@ -0,0 +1,98 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package info
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Fields naming the elements of lists in flat stats, in order of preference, e.g.: the mount
+// point of volumes and the device of filesystems.
+var flatElementKeys = []string{"name", "mount_point", "device", "id", "domain", "pid"}
+
+// Returns the stats as flat key/value pairs, keyed by the path of their JSON fields joined by
+// dots, e.g.: "cpu.usage.total". The elements of lists are keyed by their name, device or the
+// like, e.g.: "filesystem./dev/sda1.usage", and by their index if they have none. Values
+// are numbers, strings or booleans.
+func (self *ContainerStats) Flatten() (map[string]interface{}, error) {
+	data, err := json.Marshal(self)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	// Keeps the precision of 64-bit counters.
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	flat := make(map[string]interface{})
+	flatten("", value, flat)
+	return flat, nil
+}
+
+func flatten(key string, value interface{}, flat map[string]interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			flatten(joinKey(key, k), e, flat)
+		}
+	case []interface{}:
+		for i, e := range v {
+			flatten(joinKey(key, elementKey(i, e)), e, flat)
+		}
+	case nil:
+	default:
+		flat[key] = v
+	}
+}
+
+// Returns the key of the element of a list at index i.
+func elementKey(i int, element interface{}) string {
+	fields, ok := element.(map[string]interface{})
+	if !ok {
+		return strconv.Itoa(i)
+	}
+	for _, k := range flatElementKeys {
+		if s, ok := fields[k]; ok && fmt.Sprint(s) != "" {
+			return fmt.Sprint(s)
+		}
+	}
+	// Disks are identified by their major and minor numbers.
+	if major, ok := fields["major"]; ok {
+		if minor, ok := fields["minor"]; ok {
+			return fmt.Sprintf("%v:%v", major, minor)
+		}
+	}
+	return strconv.Itoa(i)
+}
+
+func joinKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+// The latest stats of a container as flat key/value pairs, see ContainerStats.Flatten.
+type FlatStats struct {
+	// Absolute name of the container.
+	Name string `json:"name"`
+
+	Stats map[string]interface{} `json:"stats"`
+}
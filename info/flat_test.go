@@ -0,0 +1,63 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package info
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestFlatten(t *testing.T) {
+	stats := &ContainerStats{
+		Timestamp: time.Date(2015, 1, 2, 15, 4, 5, 0, time.UTC),
+	}
+	stats.Cpu.Usage.Total = 1<<63 + 1
+	stats.Cpu.Usage.PerCpu = []uint64{10, 20}
+	stats.Network.RxBytes = 100
+	stats.Filesystem = []FsStats{{Device: "/dev/sda1", Usage: 2048}}
+	stats.DiskIo.IoServiceBytes = []PerDiskStats{{Major: 8, Minor: 0, Stats: map[string]uint64{"Read": 4096}}}
+	stats.Volumes = []VolumeStats{{MountPoint: "/data", Device: "/dev/sda1", Usage: 1024}}
+
+	flat, err := stats.Flatten()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"timestamp":                              "2015-01-02T15:04:05Z",
+		"cpu.usage.total":                        "9223372036854775809",
+		"cpu.usage.per_cpu_usage.1":              "20",
+		"network.rx_bytes":                       "100",
+		"diskio.io_service_bytes.8:0.stats.Read": "4096",
+		"filesystem./dev/sda1.usage":             "2048",
+		"volumes./data.usage":                    "1024",
+	}
+	for key, value := range expected {
+		v, ok := flat[key]
+		if !ok {
+			t.Errorf("missing %q in %v", key, flat)
+			continue
+		}
+		if s, _ := json.Marshal(v); string(s) != value && string(s) != `"`+value+`"` {
+			t.Errorf("expected %s for %q, got %s", value, key, s)
+		}
+	}
+	for key, value := range flat {
+		switch value.(type) {
+		case map[string]interface{}, []interface{}:
+			t.Errorf("unexpected nested value %v for %q", value, key)
+		}
+	}
+}
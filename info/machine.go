@@ -14,12 +14,17 @@
 
 package info
 
+import "time"
+
 type FsInfo struct {
 	// Block device associated with the filesystem.
 	Device string `json:"device"`
 
 	// Total number of bytes available on the filesystem.
 	Capacity uint64 `json:"capacity"`
+
+	// Total number of inodes of the filesystem.
+	Inodes uint64 `json:"inodes"`
 }
 
 type DiskInfo struct {
@@ -36,6 +41,28 @@ type DiskInfo struct {
 	Size uint64 `json:"size"`
 }
 
+type HugePagesInfo struct {
+	// Size of the pages.
+	// Units: kB.
+	PageSize uint64 `json:"page_size"`
+
+	// Number of pages reserved in the pool, and free.
+	NumPages  uint64 `json:"num_pages"`
+	FreePages uint64 `json:"free_pages"`
+}
+
+type Node struct {
+	// NUMA node id.
+	Id int `json:"node_id"`
+
+	// Memory of the node.
+	// Units: Bytes.
+	Memory uint64 `json:"memory"`
+
+	// Logical CPUs of the node.
+	Cpus []int `json:"cpus"`
+}
+
 type MachineInfo struct {
 	// The number of cores in this machine.
 	NumCores int `json:"num_cores"`
@@ -48,6 +75,118 @@ type MachineInfo struct {
 
 	// Disk map
 	DiskMap map[string]DiskInfo `json:"disk_map"`
+
+	// Hugepages pools, by page size.
+	HugePages []HugePagesInfo `json:"hugepages,omitempty"`
+
+	// NUMA topology: the memory and CPUs of each node.
+	Topology []Node `json:"topology,omitempty"`
+}
+
+// Stats of the whole machine, exported to the storage drivers as their own series.
+type MachineStats struct {
+	// The time of this stat point.
+	Timestamp time.Time `json:"timestamp"`
+
+	// Capacity of the machine.
+	NumCores       int    `json:"num_cores"`
+	MemoryCapacity int64  `json:"memory_capacity"`
+	FsCapacity     uint64 `json:"fs_capacity"`
+
+	// Cumulative CPU time consumed by the machine, in nanoseconds.
+	CpuUsage uint64 `json:"cpu_usage"`
+
+	MemoryUsage      uint64 `json:"memory_usage"`
+	MemoryWorkingSet uint64 `json:"memory_working_set"`
+
+	// Bytes used on all the filesystems of the machine.
+	FsUsage uint64 `json:"fs_usage"`
+
+	Network NetworkStats `json:"network"`
+
+	// I/O of the block devices of the machine.
+	Disks []MachineDiskStats `json:"disks,omitempty"`
+
+	// Energy consumed by the RAPL zones of the machine.
+	Energy []EnergyStats `json:"energy,omitempty"`
+
+	// Temperature of the thermal zones of the machine.
+	Thermal []ThermalStats `json:"thermal,omitempty"`
+
+	Interrupts InterruptStats `json:"interrupts"`
+}
+
+// Interrupts handled by the machine since it booted, from /proc/stat and /proc/softirqs.
+type InterruptStats struct {
+	// Cumulative number of hardware interrupts.
+	Interrupts uint64 `json:"interrupts"`
+
+	// Cumulative number of softirqs by type, e.g.: NET_RX, TIMER.
+	Softirqs map[string]uint64 `json:"softirqs,omitempty"`
+
+	// Cumulative number of context switches.
+	ContextSwitches uint64 `json:"context_switches"`
+}
+
+// Energy consumed by a RAPL zone, from /sys/class/powercap.
+type EnergyStats struct {
+	// Id of the zone, e.g.: 0 for the first package and 0:0 for its first subzone.
+	Id string `json:"id"`
+	// Name of the zone, e.g.: package-0, core, dram.
+	Zone string `json:"zone"`
+
+	// Cumulative energy consumed, which wraps to 0 past MaxEnergy.
+	// Units: Microjoules.
+	Energy    uint64 `json:"energy"`
+	MaxEnergy uint64 `json:"max_energy"`
+}
+
+// Temperature of a thermal zone, from /sys/class/thermal.
+type ThermalStats struct {
+	// Type of the zone, e.g.: x86_pkg_temp, acpitz.
+	Zone string `json:"zone"`
+	// Units: Millidegrees Celsius.
+	Temperature int64 `json:"temperature"`
+}
+
+// I/O of a block device, from /proc/diskstats.
+type MachineDiskStats struct {
+	// Name of the device, e.g.: sda.
+	Device string `json:"device"`
+	Major  uint64 `json:"major"`
+	Minor  uint64 `json:"minor"`
+
+	ReadsCompleted  uint64 `json:"reads_completed"`
+	WritesCompleted uint64 `json:"writes_completed"`
+
+	// Units: 512 bytes sectors.
+	SectorsRead    uint64 `json:"sectors_read"`
+	SectorsWritten uint64 `json:"sectors_written"`
+
+	// Time spent doing I/O, and weighted by the number of I/O in progress.
+	// Units: Milliseconds.
+	IoTime         uint64 `json:"io_time"`
+	WeightedIoTime uint64 `json:"weighted_io_time"`
+}
+
+// Builds the stats of the machine from the stats of its root container.
+func NewMachineStats(machine *MachineInfo, root *ContainerStats) *MachineStats {
+	ret := &MachineStats{
+		Timestamp:        root.Timestamp,
+		NumCores:         machine.NumCores,
+		MemoryCapacity:   machine.MemoryCapacity,
+		CpuUsage:         root.Cpu.Usage.Total,
+		MemoryUsage:      root.Memory.Usage,
+		MemoryWorkingSet: root.Memory.WorkingSet,
+		Network:          root.Network,
+	}
+	for _, fs := range machine.Filesystems {
+		ret.FsCapacity += fs.Capacity
+	}
+	for _, fs := range root.Filesystem {
+		ret.FsUsage += fs.Usage
+	}
+	return ret
 }
 
 type VersionInfo struct {
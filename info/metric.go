@@ -0,0 +1,65 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package info
+
+import "time"
+
+// Type of a custom metric.
+type MetricType string
+
+const (
+	// A value that may go up and down, e.g.: active connections.
+	MetricGauge MetricType = "gauge"
+
+	// A value that only increases, e.g.: requests served.
+	MetricCumulative MetricType = "cumulative"
+)
+
+// Type of the values of a custom metric.
+type DataType string
+
+const (
+	IntType   DataType = "int"
+	FloatType DataType = "float"
+)
+
+// Spec of a custom metric of the application in a container.
+type MetricSpec struct {
+	// The name of the metric.
+	Name string `json:"name"`
+
+	// Type of the metric.
+	Type MetricType `json:"type"`
+
+	// Data type of the values of the metric.
+	Format DataType `json:"format"`
+
+	// Display units of the metric.
+	Units string `json:"units"`
+}
+
+// A value of a custom metric.
+type MetricVal struct {
+	// Time at which the metric was collected.
+	Timestamp time.Time `json:"timestamp"`
+
+	// Value of the metric, depending on its format.
+	IntValue   int64   `json:"int_value,omitempty"`
+	FloatValue float64 `json:"float_value,omitempty"`
+
+	// Labels telling apart the values of a metric, e.g.: the handler of a Prometheus request
+	// counter.
+	Labels map[string]string `json:"labels,omitempty"`
+}
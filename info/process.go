@@ -0,0 +1,61 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package info
+
+import "time"
+
+// A process running inside a container.
+type ProcessInfo struct {
+	Pid       int    `json:"pid"`
+	ParentPid int    `json:"parent_pid"`
+	User      string `json:"user"`
+
+	// State of the process (e.g.: R, S, D, Z).
+	Status string `json:"status"`
+
+	// Average CPU usage since the process started.
+	// Units: percent of a single core.
+	PercentCpu float32 `json:"percent_cpu"`
+
+	// Total CPU time consumed by the process.
+	CpuTime time.Duration `json:"cpu_time"`
+
+	// Resident set size and virtual memory size.
+	// Units: Bytes.
+	Rss         uint64 `json:"rss"`
+	VirtualSize uint64 `json:"virtual_size"`
+
+	// Full command line of the process.
+	Cmd string `json:"cmd"`
+
+	// Cgroup the process belongs to in the cpu hierarchy.
+	CgroupPath string `json:"cgroup_path"`
+}
+
+// Usage of one of the processes using the most resources in a container.
+type TopProcess struct {
+	Pid int `json:"pid"`
+
+	// Name of the executable.
+	Command string `json:"command"`
+
+	// CPU usage since the previous stats of the container, 0 in the first ones.
+	// Units: percent of a single core.
+	PercentCpu float32 `json:"percent_cpu"`
+
+	// Resident set size.
+	// Units: Bytes.
+	Rss uint64 `json:"rss"`
+}
@@ -0,0 +1,33 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package info
+
+import "time"
+
+// Latest stats of all the containers, from the same housekeeping interval.
+type Snapshot struct {
+	// Sequence number of the snapshot. Every new snapshot has a larger number.
+	Sequence uint64 `json:"sequence"`
+
+	// Time of the oldest stats of the snapshot.
+	Timestamp time.Time `json:"timestamp"`
+
+	// Time between the oldest and newest stats of the snapshot, at most the housekeeping interval
+	// unless the housekeeping of a container is late.
+	Duration time.Duration `json:"duration"`
+
+	// Stats of each container, keyed by absolute container name.
+	Stats map[string]*ContainerStats `json:"stats"`
+}
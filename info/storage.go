@@ -0,0 +1,43 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package info
+
+import "time"
+
+// Health of a storage driver, as seen from the stats written to it.
+type StorageDriverStatus struct {
+	// Name of the storage driver (e.g.: "influxdb").
+	Name string `json:"name"`
+
+	// Whether the last write to the driver succeeded.
+	Connected bool `json:"connected"`
+
+	// Time of the last successful write, zero if there was none.
+	LastSuccess time.Time `json:"last_success"`
+
+	// Error of the last failed write and its time, empty if there was none.
+	LastError     string    `json:"last_error,omitempty"`
+	LastErrorTime time.Time `json:"last_error_time"`
+
+	// Number of writes that failed since the last successful one.
+	ConsecutiveFailures uint64 `json:"consecutive_failures"`
+
+	// Number of samples lost because the driver failed to write them. With a spill queue,
+	// samples are only lost when the queue overflows.
+	Dropped uint64 `json:"dropped"`
+
+	// Number of samples waiting in the spill queue.
+	Queued uint64 `json:"queued"`
+}
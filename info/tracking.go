@@ -0,0 +1,36 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package info
+
+// Number of containers monitored and of those evicted to stay under the maximum.
+type TrackingStatus struct {
+	// Containers monitored.
+	Containers int `json:"containers"`
+
+	// Maximum number of containers monitored, no limit if 0.
+	MaxContainers int `json:"max_containers"`
+
+	// Policy choosing the containers evicted beyond the maximum.
+	EvictionPolicy string `json:"eviction_policy"`
+
+	// Containers evicted which still exist, not monitored until they are removed.
+	Evicted int `json:"evicted"`
+
+	// Containers evicted since cAdvisor started.
+	Evictions uint64 `json:"evictions"`
+
+	// Times new containers were not monitored since there was no container to evict.
+	Rejections uint64 `json:"rejections"`
+}
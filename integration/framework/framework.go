@@ -125,7 +125,7 @@ func (self *realFramework) Cleanup() {
 // Gets a client to the cAdvisor being tested.
 func (self *realFramework) Client() *client.Client {
 	if self.cadvisorClient == nil {
-		cadvisorClient, err := client.NewClient(self.Host().FullHost())
+		cadvisorClient, err := client.New(self.Host().FullHost())
 		if err != nil {
 			self.t.Fatalf("Failed to instantiate the cAdvisor client: %v", err)
 		}
@@ -0,0 +1,64 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"sort"
+
+	"github.com/google/cadvisor/info"
+)
+
+// Sums the latest usage of the containers grouped by the value of the specified label.
+// Containers without the label are skipped. The CPU usage rate is derived from the
+// last two stats of each container.
+func aggregateByLabel(label string, containers []*info.ContainerInfo) map[string]*info.AggregateStats {
+	aggregates := make(map[string]*info.AggregateStats)
+	for _, cont := range containers {
+		value, ok := cont.Spec.Labels[label]
+		if !ok {
+			continue
+		}
+		agg, ok := aggregates[value]
+		if !ok {
+			agg = &info.AggregateStats{}
+			aggregates[value] = agg
+		}
+		agg.Containers = append(agg.Containers, cont.Name)
+
+		if len(cont.Stats) == 0 {
+			continue
+		}
+		latest := cont.Stats[len(cont.Stats)-1]
+		agg.CpuUsageTotal += latest.Cpu.Usage.Total
+		agg.MemoryUsage += latest.Memory.Usage
+		agg.MemoryWorkingSet += latest.Memory.WorkingSet
+		agg.RxBytes += latest.Network.RxBytes
+		agg.TxBytes += latest.Network.TxBytes
+
+		if len(cont.Stats) < 2 {
+			continue
+		}
+		prev := cont.Stats[len(cont.Stats)-2]
+		elapsed := latest.Timestamp.Sub(prev.Timestamp).Nanoseconds()
+		if elapsed > 0 && latest.Cpu.Usage.Total >= prev.Cpu.Usage.Total {
+			agg.CpuUsageRate += (latest.Cpu.Usage.Total - prev.Cpu.Usage.Total) * 1000 / uint64(elapsed)
+		}
+	}
+
+	for _, agg := range aggregates {
+		sort.Strings(agg.Containers)
+	}
+	return aggregates
+}
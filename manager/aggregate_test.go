@@ -0,0 +1,85 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/info"
+)
+
+func makeLabeledContainer(name string, labels map[string]string, cpu []uint64, memory uint64) *info.ContainerInfo {
+	start := time.Unix(1000, 0)
+	cinfo := &info.ContainerInfo{
+		ContainerReference: info.ContainerReference{
+			Name: name,
+		},
+		Spec: info.ContainerSpec{
+			Labels: labels,
+		},
+	}
+	for i, usage := range cpu {
+		stats := &info.ContainerStats{
+			Timestamp: start.Add(time.Duration(i) * time.Second),
+		}
+		stats.Cpu.Usage.Total = usage
+		stats.Memory.Usage = memory
+		stats.Memory.WorkingSet = memory / 2
+		cinfo.Stats = append(cinfo.Stats, stats)
+	}
+	return cinfo
+}
+
+func TestAggregateByLabel(t *testing.T) {
+	containers := []*info.ContainerInfo{
+		makeLabeledContainer("/c2", map[string]string{"service": "web"}, []uint64{0, 500000000}, 2048),
+		makeLabeledContainer("/c1", map[string]string{"service": "web"}, []uint64{1000000000, 2000000000}, 1024),
+		makeLabeledContainer("/c3", map[string]string{"service": "db"}, []uint64{42}, 4096),
+		makeLabeledContainer("/c4", map[string]string{"team": "infra"}, []uint64{0, 1}, 8192),
+	}
+
+	aggregates := aggregateByLabel("service", containers)
+	expected := map[string]*info.AggregateStats{
+		"web": {
+			Containers:       []string{"/c1", "/c2"},
+			CpuUsageTotal:    2500000000,
+			CpuUsageRate:     1500,
+			MemoryUsage:      3072,
+			MemoryWorkingSet: 1536,
+		},
+		"db": {
+			Containers:       []string{"/c3"},
+			CpuUsageTotal:    42,
+			MemoryUsage:      4096,
+			MemoryWorkingSet: 2048,
+		},
+	}
+	if !reflect.DeepEqual(aggregates, expected) {
+		t.Errorf("unexpected aggregates %+v, expected %+v", aggregates, expected)
+	}
+}
+
+func TestAggregateByMissingLabel(t *testing.T) {
+	containers := []*info.ContainerInfo{
+		makeLabeledContainer("/c1", nil, []uint64{0, 1}, 1024),
+	}
+
+	aggregates := aggregateByLabel("service", containers)
+	if len(aggregates) != 0 {
+		t.Errorf("expected no aggregates, got %+v", aggregates)
+	}
+}
@@ -22,9 +22,16 @@ import (
 
 	"github.com/docker/docker/pkg/units"
 	"github.com/golang/glog"
+	"github.com/google/cadvisor/alert"
+	"github.com/google/cadvisor/collector"
 	"github.com/google/cadvisor/container"
+	"github.com/google/cadvisor/events"
 	"github.com/google/cadvisor/info"
+	"github.com/google/cadvisor/perf"
+	"github.com/google/cadvisor/power"
+	"github.com/google/cadvisor/resctrl"
 	"github.com/google/cadvisor/storage"
+	"github.com/google/cadvisor/utils/selflimit"
 )
 
 // Housekeeping interval.
@@ -32,6 +39,22 @@ var HousekeepingInterval = flag.Duration("housekeeping_interval", 1*time.Second,
 var maxHousekeepingInterval = flag.Duration("max_housekeeping_interval", 60*time.Second, "Largest interval to allow between container housekeepings")
 var allowDynamicHousekeeping = flag.Bool("allow_dynamic_housekeeping", true, "Whether to allow the housekeeping interval to be dynamic")
 
+// Interval between the updates of the spec of containers by housekeeping, to notice changes of
+// their cpuset even when their info is not requested.
+const specUpdateInterval = 30 * time.Second
+
+// Interval between the updates of the spec of containers whose cgroups are watched, to notice the
+// changes not written to their own cgroup files, e.g. of their effective CPUs after the cpuset of
+// a parent cgroup or the online CPUs changed.
+const watchedSpecUpdateInterval = 5 * time.Minute
+
+// Number of cpuset changes kept in the spec of containers.
+const maxCpusetChanges = 10
+
+// Number of consecutive failures to collect the stats of a container after which its housekeeping
+// backs off, doubling its interval at each further failure up to --max_housekeeping_interval.
+const failureBackoffThreshold = 3
+
 type containerInfo struct {
 	info.ContainerReference
 	Subcontainers []info.ContainerReference
@@ -45,23 +68,99 @@ type containerData struct {
 	lock                 sync.Mutex
 	housekeepingInterval time.Duration
 	lastUpdatedTime      time.Time
+	lastSpecUpdate       time.Time
+	// Whether the cgroups of the container are watched for changes of its spec, which is then
+	// updated every watchedSpecUpdateInterval, and otherwise every specUpdateInterval.
+	specWatched bool
+	// Whether the spec changed since it was last updated.
+	specChanged bool
 
+	// Time the last housekeeping completed.
+	lastHousekeepingTime time.Time
+	// Time the CPU usage of the container last changed, or it was created, and that usage.
+	lastActiveTime time.Time
+	lastCpuUsage   uint64
+	// Failures to collect the stats of the container.
+	collectionErrors info.CollectionErrors
 
 	// Whether to log the usage of this container when it is updated.
 	logUsage bool
 
-	// Tells the container to stop.
-	stop chan bool
+	// Load average of the container, nil unless --enable_load_reader.
+	load *loadAverage
+
+	// CPU time of the processes of the container, nil unless --top_processes.
+	processes *processTracker
+
+	// Energy attributed to the container, nil if the machine has no RAPL counters.
+	energy *energyTracker
+
+	// Counters of hardware events, nil unless --perf_events_config.
+	perfCollector *perf.Collector
+
+	// Monitoring group of the container, nil unless --enable_resctrl.
+	resctrlCollector *resctrl.Collector
+
+	// Collectors of the custom metrics of the application in the container.
+	collectorManager collector.CollectorManager
+	// Names of the collectors registered from shared configs, guarded by lock.
+	sharedCollectors map[string]bool
+
+	// Checks the stats against the alert thresholds of the container.
+	alerts *alert.Checker
+	// Names of the thresholds added from triggers, guarded by lock.
+	triggers map[string]bool
+	// Where alerts are posted, nil if they are only logged.
+	alertWebhook *alert.Webhook
+
+	// Records the events of the container, nil if they are not recorded.
+	eventHandler events.EventManager
+	// Whether to detect OOM events from the count of OOM kills, when they are not read from the
+	// kernel log.
+	countOomKills bool
+	// Processes killed by the OOM killer as of the previous stats, if oomKillsRead.
+	oomKills     uint64
+	oomKillsRead bool
+
+	// Does the housekeeping of the container.
+	pool *housekeepingPool
+	// Options of the manager of the container.
+	options *currentOptions
 }
 
 func (c *containerData) Start() error {
-	go c.housekeeping()
-	return nil
+	glog.Infof("Start housekeeping for container %q\n", c.info.Name)
+	return c.pool.add(c)
 }
 
 func (c *containerData) Stop() error {
-	c.stop <- true
-	return nil
+	return c.pool.remove(c)
+}
+
+// Returns the time the usage of the container last changed.
+func (c *containerData) LastActive() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.lastActiveTime
+}
+
+// Returns the failures to collect the stats of the container, nil if the last collection
+// succeeded.
+func (c *containerData) CollectionErrors() *info.CollectionErrors {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.collectionErrors.ConsecutiveFailures == 0 {
+		return nil
+	}
+	errors := c.collectionErrors
+	return &errors
+}
+
+// Returns the time the last housekeeping of the container completed.
+func (c *containerData) LastHousekeeping() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.lastHousekeepingTime
 }
 
 func (c *containerData) GetInfo() (*containerInfo, error) {
@@ -83,7 +182,7 @@ func (c *containerData) GetInfo() (*containerInfo, error) {
 	return &c.info, nil
 }
 
-func newContainerData(containerName string, driver storage.StorageDriver, handler container.ContainerHandler, logUsage bool) (*containerData, error) {
+func newContainerData(containerName string, driver storage.StorageDriver, handler container.ContainerHandler, logUsage bool, options *currentOptions) (*containerData, error) {
 	if driver == nil {
 		return nil, fmt.Errorf("nil storage driver")
 	}
@@ -98,9 +197,40 @@ func newContainerData(containerName string, driver storage.StorageDriver, handle
 	cont := &containerData{
 		handler:              handler,
 		storageDriver:        driver,
-		housekeepingInterval: *HousekeepingInterval,
+		housekeepingInterval: options.get().HousekeepingInterval,
+		options:              options,
 		logUsage:             logUsage,
-		stop:                 make(chan bool, 1),
+		alerts:               alert.NewChecker(nil),
+		lastActiveTime:       time.Now(),
+	}
+	if *enableLoadReader {
+		cont.load = &loadAverage{}
+	}
+	if *topProcesses > 0 && container.HasMetric(container.ProcessMetrics) {
+		cont.processes = &processTracker{}
+	}
+	if container.HasMetric(container.EnergyMetrics) && power.Available() {
+		cont.energy = &energyTracker{}
+	}
+	if perf.Enabled() {
+		cgroupPath, err := handler.GetCgroupPath("perf_event")
+		if err == nil {
+			cont.perfCollector, err = perf.NewCollector(cgroupPath)
+		}
+		if err != nil {
+			glog.Infof("Not counting perf events of %q: %v", ref.Name, err)
+		}
+	}
+	// The root container is the whole machine, it has no monitoring group of its own.
+	if resctrl.Enabled() && ref.Name != "/" {
+		cont.resctrlCollector, err = resctrl.NewCollector(ref.Name)
+		if err != nil {
+			glog.Infof("Not monitoring %q with resctrl: %v", ref.Name, err)
+		}
+	}
+	cont.collectorManager, err = collector.NewCollectorManager()
+	if err != nil {
+		return nil, err
 	}
 	cont.info.ContainerReference = ref
 
@@ -109,90 +239,153 @@ func newContainerData(containerName string, driver storage.StorageDriver, handle
 
 // Determine when the next housekeeping should occur.
 func (self *containerData) nextHousekeeping(lastHousekeeping time.Time) time.Time {
-	if *allowDynamicHousekeeping {
+	options := self.options.get()
+	if options.AllowDynamicHousekeeping {
 		stats, err := self.storageDriver.RecentStats(self.info.Name, 2)
 		if err != nil {
 			glog.Warningf("Failed to get RecentStats(%q) while determining the next housekeeping: %v", self.info.Name, err)
 		} else if len(stats) == 2 {
 			// TODO(vishnuk): Use no processes as a signal.
 			// Raise the interval if usage hasn't changed in the last housekeeping.
-			if stats[0].StatsEq(stats[1]) && (self.housekeepingInterval < *maxHousekeepingInterval) {
+			if stats[0].StatsEq(stats[1]) && (self.housekeepingInterval < options.MaxHousekeepingInterval) {
 				self.housekeepingInterval *= 2
-				if self.housekeepingInterval > *maxHousekeepingInterval {
-					self.housekeepingInterval = *maxHousekeepingInterval
+				if self.housekeepingInterval > options.MaxHousekeepingInterval {
+					self.housekeepingInterval = options.MaxHousekeepingInterval
 				}
 				glog.V(3).Infof("Raising housekeeping interval for %q to %v", self.info.Name, self.housekeepingInterval)
-			} else if self.housekeepingInterval != *HousekeepingInterval {
+			} else if self.housekeepingInterval != options.HousekeepingInterval {
 				// Lower interval back to the baseline.
-				self.housekeepingInterval = *HousekeepingInterval
+				self.housekeepingInterval = options.HousekeepingInterval
 				glog.V(3).Infof("Lowering housekeeping interval for %q to %v", self.info.Name, self.housekeepingInterval)
 			}
 		}
+	} else {
+		// The interval may have been updated since the last housekeeping.
+		self.housekeepingInterval = options.HousekeepingInterval
 	}
 
-	return lastHousekeeping.Add(self.housekeepingInterval)
+	interval := self.housekeepingInterval
+	self.lock.Lock()
+	failures := self.collectionErrors.ConsecutiveFailures
+	self.lock.Unlock()
+	if failures >= failureBackoffThreshold {
+		interval = backoff(interval, failures-failureBackoffThreshold+1, options.MaxHousekeepingInterval)
+	}
+	return lastHousekeeping.Add(interval)
 }
 
-func (c *containerData) housekeeping() {
+// Returns interval doubled the specified number of times, up to max.
+func backoff(interval time.Duration, times uint64, max time.Duration) time.Duration {
+	for i := uint64(0); i < times && interval < max; i++ {
+		interval *= 2
+	}
+	if interval > max {
+		return max
+	}
+	return interval
+}
+
+// Does one housekeeping of the container.
+func (c *containerData) housekeepingOnce() {
 	// Long housekeeping is either 100ms or half of the housekeeping interval.
 	longHousekeeping := 100 * time.Millisecond
-	if *HousekeepingInterval/2 < longHousekeeping {
-		longHousekeeping = *HousekeepingInterval / 2
+	if interval := c.options.get().HousekeepingInterval; interval/2 < longHousekeeping {
+		longHousekeeping = interval / 2
 	}
 
-	// Housekeep every second.
-	glog.Infof("Start housekeeping for container %q\n", c.info.Name)
-	lastHousekeeping := time.Now()
-	for {
-		select {
-		case <-c.stop:
-			// Stop housekeeping when signaled.
-			return
-		default:
-			// Perform housekeeping.
-			start := time.Now()
-			c.housekeepingTick()
-
-			// Log if housekeeping took too long.
-			duration := time.Since(start)
-			if duration >= longHousekeeping {
-				glog.V(2).Infof("[%s] Housekeeping took %s", c.info.Name, duration)
-			}
-		}
+	// Perform housekeeping.
+	start := time.Now()
+	c.housekeepingTick()
+	c.lock.Lock()
+	c.lastHousekeepingTime = time.Now()
+	c.lock.Unlock()
 
-		// Log usage if asked to do so.
-		if c.logUsage {
-			stats, err := c.storageDriver.RecentStats(c.info.Name, 2)
-			if err != nil {
-				glog.Infof("[%s] Failed to get recent stats for logging usage: %v", c.info.Name, err)
-			} else if len(stats) < 2 {
-				// Ignore, not enough stats yet.
-			} else {
-				usageCpuNs := stats[1].Cpu.Usage.Total - stats[0].Cpu.Usage.Total
-				usageMemory := stats[1].Memory.Usage
-
-				usageInCores := float64(usageCpuNs) / float64(stats[1].Timestamp.Sub(stats[0].Timestamp).Nanoseconds())
-				usageInHuman := units.HumanSize(int64(usageMemory))
-				glog.Infof("[%s] %.3f cores, %s of memory", c.info.Name, usageInCores, usageInHuman)
-			}
+	// Log if housekeeping took too long.
+	duration := time.Since(start)
+	if duration >= longHousekeeping {
+		glog.V(2).Infof("[%s] Housekeeping took %s", c.info.Name, duration)
+	}
+
+	// Log usage if asked to do so.
+	if c.logUsage {
+		stats, err := c.storageDriver.RecentStats(c.info.Name, 2)
+		if err != nil {
+			glog.Infof("[%s] Failed to get recent stats for logging usage: %v", c.info.Name, err)
+		} else if len(stats) < 2 {
+			// Ignore, not enough stats yet.
+		} else {
+			usageCpuNs := stats[1].Cpu.Usage.Total - stats[0].Cpu.Usage.Total
+			usageMemory := stats[1].Memory.Usage
+
+			usageInCores := float64(usageCpuNs) / float64(stats[1].Timestamp.Sub(stats[0].Timestamp).Nanoseconds())
+			usageInHuman := units.HumanSize(int64(usageMemory))
+			glog.Infof("[%s] %.3f cores, %s of memory", c.info.Name, usageInCores, usageInHuman)
 		}
+	}
+}
 
-		// Schedule the next housekeeping. Sleep until that time.
-		nextHousekeeping := c.nextHousekeeping(lastHousekeeping)
-		if time.Now().Before(nextHousekeeping) {
-			time.Sleep(nextHousekeeping.Sub(time.Now()))
+// Releases what the container holds once it is no longer housekept.
+func (c *containerData) destroy() {
+	if c.perfCollector != nil {
+		c.perfCollector.Destroy()
+	}
+	if c.resctrlCollector != nil {
+		if err := c.resctrlCollector.Destroy(); err != nil {
+			glog.Warningf("Failed to remove the resctrl monitoring group of %q: %v", c.info.Name, err)
 		}
-		lastHousekeeping = nextHousekeeping
 	}
 }
 
 func (c *containerData) housekeepingTick() {
 	err := c.updateStats()
-	if err != nil {
+	c.recordCollection(err)
+	c.lock.Lock()
+	interval := specUpdateInterval
+	if c.specWatched {
+		interval = watchedSpecUpdateInterval
+	}
+	updateSpec := c.specChanged || time.Since(c.lastSpecUpdate) > interval
+	c.specChanged = false
+	c.lock.Unlock()
+	if updateSpec {
+		err = c.updateSpec()
+		if err != nil {
+			glog.Infof("Failed to update spec for container \"%s\": %s", c.info.Name, err)
+			c.markSpecChanged()
+		}
+	}
+}
+
+// Records whether the stats of the container were collected. Only the first of consecutive
+// failures is logged: the cgroups of containers being destroyed vanish mid-read.
+func (c *containerData) recordCollection(err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if err == nil {
+		if c.collectionErrors.ConsecutiveFailures > 0 {
+			glog.V(2).Infof("Updated stats for container %q after %d failures", c.info.Name, c.collectionErrors.ConsecutiveFailures)
+			c.collectionErrors.ConsecutiveFailures = 0
+		}
+		return
+	}
+	c.collectionErrors.LastError = err.Error()
+	c.collectionErrors.LastErrorTime = time.Now()
+	c.collectionErrors.ConsecutiveFailures++
+	c.collectionErrors.TotalFailures++
+	if c.collectionErrors.ConsecutiveFailures == 1 {
 		glog.Infof("Failed to update stats for container \"%s\": %s", c.info.Name, err)
+	} else {
+		glog.V(4).Infof("Failed to update stats for container %q (%d consecutive failures): %s", c.info.Name, c.collectionErrors.ConsecutiveFailures, err)
 	}
 }
 
+// Has the spec of the container updated at the next housekeeping.
+func (c *containerData) markSpecChanged() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.specChanged = true
+}
+
 func (c *containerData) updateSpec() error {
 	spec, err := c.handler.GetSpec()
 	if err != nil {
@@ -204,6 +397,30 @@ func (c *containerData) updateSpec() error {
 	}
 	c.lock.Lock()
 	defer c.lock.Unlock()
+	c.lastSpecUpdate = time.Now()
+	customMetrics, err := c.collectorManager.GetSpec()
+	if err != nil {
+		return err
+	}
+	if len(customMetrics) != 0 {
+		spec.HasCustomMetrics = true
+		spec.CustomMetrics = customMetrics
+	}
+	spec.CpusetChanges = c.info.Spec.CpusetChanges
+	last := c.info.Spec.Cpu
+	// The first spec is the initial cpuset, not a change.
+	if c.info.Spec.HasCpu && (spec.Cpu.Mask != last.Mask || spec.Cpu.Mems != last.Mems || spec.Cpu.EffectiveCpus != last.EffectiveCpus) {
+		glog.Infof("Cpuset of %q changed from CPUs %q and memory nodes %q to CPUs %q (effective %q) and memory nodes %q", c.info.Name, last.Mask, last.Mems, spec.Cpu.Mask, spec.Cpu.EffectiveCpus, spec.Cpu.Mems)
+		spec.CpusetChanges = append(spec.CpusetChanges, info.CpusetChange{
+			Timestamp:     time.Now(),
+			Cpus:          spec.Cpu.Mask,
+			Mems:          spec.Cpu.Mems,
+			EffectiveCpus: spec.Cpu.EffectiveCpus,
+		})
+		if len(spec.CpusetChanges) > maxCpusetChanges {
+			spec.CpusetChanges = spec.CpusetChanges[len(spec.CpusetChanges)-maxCpusetChanges:]
+		}
+	}
 	c.info.Spec = spec
 	return nil
 }
@@ -220,6 +437,63 @@ func (c *containerData) updateStats() error {
 	if stats == nil {
 		return nil
 	}
+	c.lock.Lock()
+	if stats.Cpu.Usage.Total != c.lastCpuUsage {
+		c.lastCpuUsage = stats.Cpu.Usage.Total
+		c.lastActiveTime = time.Now()
+	}
+	c.lock.Unlock()
+	// Expensive collections are skipped while cAdvisor uses more than its budget.
+	overBudget := selflimit.OverBudget()
+	if c.load != nil && !overBudget {
+		threads, err := c.handler.ListThreads(container.ListSelf)
+		if err != nil {
+			glog.V(3).Infof("Failed to list the threads of %q: %v", c.info.Name, err)
+		} else {
+			c.load.add(stats, countThreadStates(threads))
+		}
+	}
+	if c.processes != nil && !overBudget {
+		pids, err := c.handler.ListProcesses(container.ListSelf)
+		if err != nil {
+			glog.V(3).Infof("Failed to list the processes of %q: %v", c.info.Name, err)
+		} else {
+			c.processes.add(stats, pids, *topProcesses)
+		}
+	}
+	// The latest metrics of the collectors that are not due are reported again.
+	if !overBudget {
+		_, customMetrics, err := c.collectorManager.Collect()
+		if err != nil {
+			glog.V(3).Infof("Failed to collect the custom metrics of %q: %v", c.info.Name, err)
+		}
+		if len(customMetrics) != 0 {
+			stats.CustomMetrics = customMetrics
+		}
+	}
+	if c.energy != nil {
+		if err := c.energy.add(stats); err != nil {
+			glog.V(3).Infof("Failed to estimate the energy of %q: %v", c.info.Name, err)
+		}
+	}
+	if c.perfCollector != nil {
+		if err := c.perfCollector.UpdateStats(stats); err != nil {
+			glog.V(3).Infof("Failed to count the perf events of %q: %v", c.info.Name, err)
+		}
+	}
+	if c.resctrlCollector != nil && !overBudget {
+		threads, err := c.handler.ListThreads(container.ListSelf)
+		if err == nil {
+			err = c.resctrlCollector.UpdateStats(stats, threads)
+		}
+		if err != nil {
+			glog.V(3).Infof("Failed to read the resctrl stats of %q: %v", c.info.Name, err)
+		}
+	}
+	c.checkAlerts(stats)
+	if c.eventHandler != nil && c.countOomKills {
+		c.checkOomKills(stats)
+	}
 	ref, err := c.handler.ContainerReference()
 	if err != nil {
 		// Ignore errors if the container is dead.
@@ -235,6 +509,69 @@ func (c *containerData) updateStats() error {
 	return nil
 }
 
+// Logs the alerts that fired or resolved with the new stats, records their events and posts them
+// to the webhook.
+func (c *containerData) checkAlerts(stats *info.ContainerStats) {
+	c.lock.Lock()
+	spec := c.info.Spec
+	c.lock.Unlock()
+	alerts := c.alerts.Check(c.info.Name, stats, &spec)
+	if len(alerts) == 0 {
+		return
+	}
+	for _, a := range alerts {
+		if a.Firing {
+			glog.Warningf("The %s", a.String())
+		} else {
+			glog.Infof("The %s", a.String())
+		}
+		if c.eventHandler == nil {
+			continue
+		}
+		err := c.eventHandler.AddEvent(&info.Event{
+			ContainerName: a.ContainerName,
+			Timestamp:     a.Timestamp,
+			EventType:     info.EventThreshold,
+			EventData: info.EventData{
+				Threshold: &info.ThresholdEventData{
+					Name:       a.Name,
+					Expression: a.Expression,
+					Value:      a.Value,
+					Firing:     a.Firing,
+					Stats:      stats,
+				},
+			},
+		})
+		if err != nil {
+			glog.Warningf("Failed to add the threshold event of %q: %v", a.ContainerName, err)
+		}
+	}
+	if c.alertWebhook != nil {
+		c.alertWebhook.Notify(alerts)
+	}
+}
+
+// Records an OOM event if processes of the container were killed since the previous stats. Those
+// killed before cAdvisor read the first stats are not reported.
+func (c *containerData) checkOomKills(stats *info.ContainerStats) {
+	kills := stats.Memory.OomKills
+	if c.oomKillsRead && kills > c.oomKills {
+		err := c.eventHandler.AddEvent(&info.Event{
+			ContainerName: c.info.Name,
+			Timestamp:     stats.Timestamp,
+			EventType:     info.EventOom,
+			EventData: info.EventData{
+				Oom: &info.OomEventData{Kills: kills - c.oomKills},
+			},
+		})
+		if err != nil {
+			glog.Warningf("Failed to add the OOM event of %q: %v", c.info.Name, err)
+		}
+	}
+	c.oomKills = kills
+	c.oomKillsRead = true
+}
+
 func (c *containerData) updateSubcontainers() error {
 	subcontainers, err := c.handler.ListContainers(container.ListSelf)
 	if err != nil {
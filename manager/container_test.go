@@ -23,6 +23,7 @@ import (
 	"time"
 
 	"github.com/google/cadvisor/container"
+	"github.com/google/cadvisor/events"
 	"github.com/google/cadvisor/info"
 	itest "github.com/google/cadvisor/info/test"
 	stest "github.com/google/cadvisor/storage/test"
@@ -35,7 +36,8 @@ const containerName = "/container"
 func newTestContainerData(t *testing.T) (*containerData, *container.MockContainerHandler, *stest.MockStorageDriver) {
 	mockHandler := container.NewMockContainerHandler(containerName)
 	mockDriver := &stest.MockStorageDriver{}
-	ret, err := newContainerData(containerName, mockDriver, mockHandler, false)
+	options := DefaultOptions()
+	ret, err := newContainerData(containerName, mockDriver, mockHandler, false, &currentOptions{options: options})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -123,6 +125,70 @@ func TestUpdateStats(t *testing.T) {
 	mockHandler.AssertExpectations(t)
 }
 
+func TestCollectionErrors(t *testing.T) {
+	cd, _, _ := newTestContainerData(t)
+	options := DefaultOptions()
+	options.AllowDynamicHousekeeping = false
+	options.HousekeepingInterval = time.Second
+	options.MaxHousekeepingInterval = 10 * time.Second
+	cd.options = &currentOptions{options: options}
+	now := time.Now()
+
+	for i := 0; i < failureBackoffThreshold-1; i++ {
+		cd.recordCollection(fmt.Errorf("cgroup removed"))
+	}
+	errors := cd.CollectionErrors()
+	if errors == nil || errors.ConsecutiveFailures != failureBackoffThreshold-1 || errors.LastError != "cgroup removed" {
+		t.Fatalf("unexpected collection errors %+v", errors)
+	}
+	if next := cd.nextHousekeeping(now); next != now.Add(time.Second) {
+		t.Errorf("expected no backoff before %d failures, next housekeeping in %v", failureBackoffThreshold, next.Sub(now))
+	}
+
+	// The interval doubles at each further failure, up to the max.
+	cd.recordCollection(fmt.Errorf("cgroup removed"))
+	if next := cd.nextHousekeeping(now); next != now.Add(2*time.Second) {
+		t.Errorf("expected a backoff to 2s, next housekeeping in %v", next.Sub(now))
+	}
+	for i := 0; i < 10; i++ {
+		cd.recordCollection(fmt.Errorf("cgroup removed"))
+	}
+	if next := cd.nextHousekeeping(now); next != now.Add(10*time.Second) {
+		t.Errorf("expected a backoff to 10s, next housekeeping in %v", next.Sub(now))
+	}
+
+	cd.recordCollection(nil)
+	if errors := cd.CollectionErrors(); errors != nil {
+		t.Errorf("expected no collection errors after a success, got %+v", errors)
+	}
+	if cd.collectionErrors.TotalFailures != failureBackoffThreshold+10 {
+		t.Errorf("expected %d failures in total, got %d", failureBackoffThreshold+10, cd.collectionErrors.TotalFailures)
+	}
+	if next := cd.nextHousekeeping(now); next != now.Add(time.Second) {
+		t.Errorf("expected no backoff after a success, next housekeeping in %v", next.Sub(now))
+	}
+}
+
+func TestCheckOomKills(t *testing.T) {
+	cd, _, _ := newTestContainerData(t)
+	cd.info.Name = containerName
+	cd.eventHandler = events.NewEventManager(0, 0)
+
+	// Kills before the first stats are not reported.
+	for _, kills := range []uint64{2, 2, 5} {
+		stats := &info.ContainerStats{Timestamp: time.Now()}
+		stats.Memory.OomKills = kills
+		cd.checkOomKills(stats)
+	}
+	oomEvents, err := cd.eventHandler.GetEvents(&events.Request{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(oomEvents) != 1 || oomEvents[0].EventType != info.EventOom || oomEvents[0].ContainerName != containerName || oomEvents[0].EventData.Oom.Kills != 3 {
+		t.Errorf("expected an OOM event of 3 kills, got %+v", oomEvents)
+	}
+}
+
 func TestUpdateSpec(t *testing.T) {
 	spec := itest.GenerateRandomContainerSpec(4)
 	cd, mockHandler, _ := newTestContainerData(t)
@@ -139,6 +205,34 @@ func TestUpdateSpec(t *testing.T) {
 	mockHandler.AssertExpectations(t)
 }
 
+func TestUpdateSpecCpusetChanges(t *testing.T) {
+	cd, mockHandler, _ := newTestContainerData(t)
+	spec := info.ContainerSpec{HasCpu: true}
+	spec.Cpu = info.CpuSpec{Mask: "0-3", Mems: "0", EffectiveCpus: "0-3"}
+	mockHandler.On("GetSpec").Return(spec, nil).Once()
+	moved := spec
+	moved.Cpu = info.CpuSpec{Mask: "4-7", Mems: "1", EffectiveCpus: "4-7"}
+	mockHandler.On("GetSpec").Return(moved, nil)
+
+	// The initial cpuset is not a change.
+	if err := cd.updateSpec(); err != nil {
+		t.Fatal(err)
+	}
+	if len(cd.info.Spec.CpusetChanges) != 0 {
+		t.Errorf("expected no cpuset changes, got %+v", cd.info.Spec.CpusetChanges)
+	}
+	for i := 0; i < 2; i++ {
+		if err := cd.updateSpec(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	changes := cd.info.Spec.CpusetChanges
+	if len(changes) != 1 || changes[0].Cpus != "4-7" || changes[0].Mems != "1" || changes[0].EffectiveCpus != "4-7" {
+		t.Errorf("expected a change to CPUs 4-7 and memory node 1, got %+v", changes)
+	}
+	mockHandler.AssertExpectations(t)
+}
+
 func TestGetInfo(t *testing.T) {
 	spec := itest.GenerateRandomContainerSpec(4)
 	subcontainers := []info.ContainerReference{
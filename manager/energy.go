@@ -0,0 +1,61 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"time"
+
+	"github.com/google/cadvisor/info"
+	"github.com/google/cadvisor/power"
+	"github.com/google/cadvisor/utils/procfs"
+)
+
+// Estimates the energy consumed by a container: RAPL only counts the energy of the whole machine,
+// which is split between containers in proportion to the CPU time they used.
+type energyTracker struct {
+	lastEnergy     []info.EnergyStats
+	lastCpuUsage   uint64
+	lastMachineCpu time.Duration
+	// Energy attributed to the container so far, in microjoules.
+	energy uint64
+}
+
+func (self *energyTracker) add(stats *info.ContainerStats) error {
+	energy, err := power.ReadEnergy()
+	if err != nil {
+		return err
+	}
+	machineCpu, err := procfs.ReadCpuTime()
+	if err != nil {
+		return err
+	}
+	self.update(stats, energy, machineCpu)
+	return nil
+}
+
+func (self *energyTracker) update(stats *info.ContainerStats, energy []info.EnergyStats, machineCpu time.Duration) {
+	if self.lastEnergy != nil && machineCpu > self.lastMachineCpu && stats.Cpu.Usage.Total >= self.lastCpuUsage {
+		share := float64(stats.Cpu.Usage.Total-self.lastCpuUsage) / float64(machineCpu-self.lastMachineCpu)
+		// The CPU times are not read at the same time.
+		if share > 1 {
+			share = 1
+		}
+		self.energy += uint64(float64(power.PackageEnergyDelta(self.lastEnergy, energy)) * share)
+	}
+	self.lastEnergy = energy
+	self.lastCpuUsage = stats.Cpu.Usage.Total
+	self.lastMachineCpu = machineCpu
+	stats.Energy = self.energy
+}
@@ -0,0 +1,53 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/info"
+)
+
+func TestEnergyTracker(t *testing.T) {
+	var tracker energyTracker
+	energyAt := func(energy uint64) []info.EnergyStats {
+		return []info.EnergyStats{{Id: "0", Zone: "package-0", Energy: energy, MaxEnergy: 1 << 32}}
+	}
+
+	// Nothing to attribute before the second sample.
+	stats := &info.ContainerStats{}
+	stats.Cpu.Usage.Total = uint64(time.Second)
+	tracker.update(stats, energyAt(1000000), 10*time.Second)
+	if stats.Energy != 0 {
+		t.Errorf("expected no energy, got %d", stats.Energy)
+	}
+
+	// The container used a quarter of the CPU time of the machine.
+	stats = &info.ContainerStats{}
+	stats.Cpu.Usage.Total = uint64(2 * time.Second)
+	tracker.update(stats, energyAt(5000000), 14*time.Second)
+	if stats.Energy != 1000000 {
+		t.Errorf("expected 1000000µJ, got %d", stats.Energy)
+	}
+
+	// The energy is cumulative.
+	stats = &info.ContainerStats{}
+	stats.Cpu.Usage.Total = uint64(4 * time.Second)
+	tracker.update(stats, energyAt(7000000), 16*time.Second)
+	if stats.Energy != 3000000 {
+		t.Errorf("expected 3000000µJ, got %d", stats.Energy)
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/cadvisor/container/raw"
+	"github.com/google/cadvisor/info"
+	"github.com/google/cadvisor/storage"
+)
+
+var maxContainers = flag.Int("max_containers", 0, "Maximum number of containers monitored, no limit if 0. Beyond it, containers are evicted following --container_eviction_policy")
+var containerEvictionPolicy = flag.String("container_eviction_policy", EvictLeastActiveRaw, "Containers evicted to make room for new ones beyond --max_containers: least_active_raw evicts the raw cgroups whose usage changed the longest ago, least_active any container whose usage changed the longest ago, none monitors no new containers")
+
+// Policies evicting containers to stay under the maximum number of containers.
+const (
+	// Evicts the raw cgroups, e.g. not containers of Docker, whose usage changed the longest ago.
+	EvictLeastActiveRaw = "least_active_raw"
+	// Evicts the containers whose usage changed the longest ago.
+	EvictLeastActive = "least_active"
+	// Evicts no container, new containers are not monitored.
+	EvictNone = "none"
+)
+
+var evictionPolicies = map[string]bool{
+	EvictLeastActiveRaw: true,
+	EvictLeastActive:    true,
+	EvictNone:           true,
+}
+
+// Returns an error if there is no room for a new container, without evicting containers. Must be
+// called with containersLock held.
+func (m *manager) checkRoom(containerName string) error {
+	if m.evicted[containerName] {
+		return fmt.Errorf("container %q was evicted", containerName)
+	}
+	max := m.options.get().MaxContainers
+	if max <= 0 || containerName == "/" {
+		return nil
+	}
+	if m.numContainers >= max && m.evictionCandidate() == nil {
+		m.rejections++
+		return fmt.Errorf("%d containers are monitored, the maximum", m.numContainers)
+	}
+	return nil
+}
+
+// Makes room for a new container when the number of containers is capped, evicting containers
+// following the eviction policy. Returns an error if the container is not to be monitored. Must
+// be called with containersLock held, by the caller adding the container.
+func (m *manager) makeRoom(containerName string) error {
+	if _, ok := m.containers[namespacedContainerName{Name: containerName}]; ok {
+		return nil
+	}
+	if err := m.checkRoom(containerName); err != nil {
+		return err
+	}
+	max := m.options.get().MaxContainers
+	if max <= 0 || containerName == "/" {
+		return nil
+	}
+	for m.numContainers >= max {
+		victim := m.evictionCandidate()
+		if victim == nil {
+			m.rejections++
+			return fmt.Errorf("%d containers are monitored, the maximum", m.numContainers)
+		}
+		if err := victim.Stop(); err != nil {
+			return err
+		}
+		m.forgetContainer(victim)
+		m.evicted[victim.info.Name] = true
+		m.evictions++
+		if remover, ok := m.storageDriver.(storage.RemovingDriver); ok {
+			remover.RemoveContainer(victim.info.Name)
+		}
+		glog.Infof("Evicted container %q, last active %v ago, to monitor at most %d containers", victim.info.Name, time.Since(victim.LastActive()), max)
+	}
+	return nil
+}
+
+// Returns the container to evict following the eviction policy, nil if there is none. Neither the
+// root container nor that of cAdvisor are evicted. Must be called with containersLock held.
+func (m *manager) evictionCandidate() *containerData {
+	policy := m.options.get().EvictionPolicy
+	if policy == EvictNone {
+		return nil
+	}
+	var victim *containerData
+	var victimActive time.Time
+	for name, cont := range m.containers {
+		// Only consider the canonical name of each container.
+		if name.Namespace != "" || name.Name != cont.info.Name {
+			continue
+		}
+		if name.Name == "/" || name.Name == m.cadvisorContainer {
+			continue
+		}
+		if policy == EvictLeastActiveRaw && cont.info.Namespace != "" && cont.info.Namespace != raw.SystemdNamespace {
+			continue
+		}
+		if active := cont.LastActive(); victim == nil || active.Before(victimActive) {
+			victim = cont
+			victimActive = active
+		}
+	}
+	return victim
+}
+
+// Whether the container is the parent container or one of its subcontainers.
+func isSubcontainer(name, parent string) bool {
+	return parent == "/" || name == parent || strings.HasPrefix(name, parent+"/")
+}
+
+func (m *manager) GetTrackingStatus() info.TrackingStatus {
+	m.containersLock.RLock()
+	defer m.containersLock.RUnlock()
+	options := m.options.get()
+	return info.TrackingStatus{
+		Containers:     m.numContainers,
+		MaxContainers:  options.MaxContainers,
+		EvictionPolicy: options.EvictionPolicy,
+		Evicted:        len(m.evicted),
+		Evictions:      m.evictions,
+		Rejections:     m.rejections,
+	}
+}
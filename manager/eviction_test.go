@@ -0,0 +1,210 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/container"
+	"github.com/google/cadvisor/container/docker"
+	"github.com/google/cadvisor/info"
+	"github.com/google/cadvisor/storage/memory"
+	"github.com/google/cadvisor/utils/sysfs/fakesysfs"
+)
+
+// Container monitored by the manager of a test, whose usage last changed some time ago.
+type trackedContainer struct {
+	name      string
+	namespace string
+	idle      time.Duration
+}
+
+func newEvictingManager(t *testing.T, maxContainers int, policy string, containers []trackedContainer) (*manager, *memory.InMemoryStorage) {
+	driver := memory.New(10, nil, 0)
+	options := DefaultOptions()
+	options.StorageDriver = driver
+	options.SysFs = &fakesysfs.FakeSysFs{}
+	options.MaxContainers = maxContainers
+	options.EvictionPolicy = policy
+	mif, err := New(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := mif.(*manager)
+	for _, c := range containers {
+		cont, err := newContainerData(c.name, driver, container.NewMockContainerHandler(c.name), false, &m.options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cont.info.Namespace = c.namespace
+		cont.lastActiveTime = time.Now().Add(-c.idle)
+		cont.pool = m.housekeepingPool
+		if err := cont.Start(); err != nil {
+			t.Fatal(err)
+		}
+		m.containers[namespacedContainerName{Name: c.name}] = cont
+		m.numContainers++
+		driver.AddStats(info.ContainerReference{Name: c.name}, &info.ContainerStats{Timestamp: time.Now()})
+	}
+	return m, driver
+}
+
+var trackedContainers = []trackedContainer{
+	{name: "/", idle: time.Hour},
+	{name: "/docker/a", namespace: docker.DockerNamespace, idle: 30 * time.Minute},
+	{name: "/system.slice/a.service", namespace: "systemd", idle: 10 * time.Minute},
+	{name: "/user/b", idle: time.Minute},
+}
+
+func TestMakeRoomEvictsLeastActiveRaw(t *testing.T) {
+	m, driver := newEvictingManager(t, 4, EvictLeastActiveRaw, trackedContainers)
+	if err := m.makeRoom("/user/c"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.containers[namespacedContainerName{Name: "/system.slice/a.service"}]; ok {
+		t.Errorf("expected the least active raw container to be evicted")
+	}
+	if stats, _ := driver.RecentStats("/system.slice/a.service", -1); len(stats) != 0 {
+		t.Errorf("expected the stats of the evicted container to be dropped, got %d", len(stats))
+	}
+	status := m.GetTrackingStatus()
+	if status.Containers != 3 || status.Evicted != 1 || status.Evictions != 1 || status.Rejections != 0 {
+		t.Errorf("unexpected tracking status %+v", status)
+	}
+
+	// Evicted containers are not monitored again until they are removed.
+	if err := m.makeRoom("/system.slice/a.service"); err == nil {
+		t.Errorf("expected the evicted container not to be monitored again")
+	}
+	if err := m.destroyContainer("/system.slice/a.service"); err != nil {
+		t.Fatal(err)
+	}
+	if status := m.GetTrackingStatus(); status.Evicted != 0 {
+		t.Errorf("expected the removed container to be forgotten, got %+v", status)
+	}
+}
+
+func TestMakeRoomEvictsLeastActive(t *testing.T) {
+	m, _ := newEvictingManager(t, 4, EvictLeastActive, trackedContainers)
+	if err := m.makeRoom("/user/c"); err != nil {
+		t.Fatal(err)
+	}
+	// The root container is never evicted.
+	if _, ok := m.containers[namespacedContainerName{Name: "/docker/a"}]; ok {
+		t.Errorf("expected the least active container to be evicted")
+	}
+}
+
+func TestMakeRoomRejects(t *testing.T) {
+	m, _ := newEvictingManager(t, 4, EvictNone, trackedContainers)
+	if err := m.makeRoom("/user/c"); err == nil {
+		t.Errorf("expected no room for a new container")
+	}
+	if err := m.makeRoom("/user/b"); err != nil {
+		t.Errorf("expected a monitored container to be accepted: %v", err)
+	}
+	status := m.GetTrackingStatus()
+	if status.Containers != 4 || status.Evictions != 0 || status.Rejections != 1 {
+		t.Errorf("unexpected tracking status %+v", status)
+	}
+
+	m, _ = newEvictingManager(t, 0, EvictNone, trackedContainers)
+	if err := m.makeRoom("/user/c"); err != nil {
+		t.Errorf("expected no limit without a maximum: %v", err)
+	}
+}
+
+// Creates mock handlers, whose references cannot be read if failing, or are read after a delay.
+type evictionTestFactory struct {
+	failing bool
+	delay   time.Duration
+}
+
+type slowHandler struct {
+	*container.MockContainerHandler
+	delay time.Duration
+}
+
+func (self slowHandler) ContainerReference() (info.ContainerReference, error) {
+	time.Sleep(self.delay)
+	return self.MockContainerHandler.ContainerReference()
+}
+
+func (self *evictionTestFactory) String() string {
+	return "eviction_test"
+}
+
+func (self *evictionTestFactory) CanHandle(name string) (bool, error) {
+	return true, nil
+}
+
+func (self *evictionTestFactory) NewContainerHandler(name string) (container.ContainerHandler, error) {
+	if self.failing {
+		handler := &container.MockContainerHandler{}
+		handler.On("ContainerReference").Return(info.ContainerReference{}, fmt.Errorf("container %q is gone", name))
+		return handler, nil
+	}
+	return slowHandler{container.NewMockContainerHandler(name), self.delay}, nil
+}
+
+func TestCreateContainerFailureEvictsNothing(t *testing.T) {
+	container.ClearContainerHandlerFactories()
+	defer container.ClearContainerHandlerFactories()
+	container.RegisterContainerHandlerFactory(&evictionTestFactory{failing: true}, container.PriorityRuntime)
+
+	m, _ := newEvictingManager(t, 4, EvictLeastActiveRaw, trackedContainers)
+	if err := m.createContainer("/user/c"); err == nil {
+		t.Fatal("expected the creation of the container to fail")
+	}
+	status := m.GetTrackingStatus()
+	if status.Containers != 4 || status.Evictions != 0 {
+		t.Errorf("expected no container to be evicted for a container that failed, got %+v", status)
+	}
+}
+
+func TestCreateContainersConcurrently(t *testing.T) {
+	container.ClearContainerHandlerFactories()
+	defer container.ClearContainerHandlerFactories()
+	container.RegisterContainerHandlerFactory(&evictionTestFactory{delay: 10 * time.Millisecond}, container.PriorityRuntime)
+
+	m, _ := newEvictingManager(t, 6, EvictNone, trackedContainers)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := m.createContainer(fmt.Sprintf("/user/new%d", i)); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	status := m.GetTrackingStatus()
+	if status.Containers != 6 || status.Rejections != 8 {
+		t.Errorf("expected 2 of the new containers to be monitored, got %+v", status)
+	}
+	count := 0
+	for name := range m.containers {
+		if name.Namespace == "" {
+			count++
+		}
+	}
+	if count != 6 {
+		t.Errorf("expected 6 containers, got %d", count)
+	}
+}
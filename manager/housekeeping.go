@@ -0,0 +1,253 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"container/heap"
+	"flag"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+var housekeepingWorkers = flag.Int("housekeeping_workers", 0, "Number of goroutines doing the housekeeping of all containers, twice the number of cores if 0")
+var housekeepingDeadline = flag.Duration("housekeeping_deadline", 10*time.Second, "Time after which the housekeeping of a container is considered stuck: its worker goes on with other containers, and the container is housekept again once it completes")
+
+// The housekeeping of a container, scheduled in a housekeepingPool.
+type scheduledHousekeeping struct {
+	cont *containerData
+	// When the housekeeping is due.
+	next time.Time
+	// Position in the queue, -1 when not queued.
+	index int
+	// Whether the housekeeping is being done.
+	running bool
+	// Whether the container was removed.
+	removed bool
+}
+
+// Housekeepings by due time.
+type housekeepingQueue []*scheduledHousekeeping
+
+func (q housekeepingQueue) Len() int           { return len(q) }
+func (q housekeepingQueue) Less(i, j int) bool { return q[i].next.Before(q[j].next) }
+func (q housekeepingQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *housekeepingQueue) Push(x interface{}) {
+	item := x.(*scheduledHousekeeping)
+	item.index = len(*q)
+	*q = append(*q, item)
+}
+
+func (q *housekeepingQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	item.index = -1
+	*q = old[:len(old)-1]
+	return item
+}
+
+// Does the housekeeping of containers with a fixed number of workers, each container when it is
+// due. A container is never housekept by two workers at once.
+type housekeepingPool struct {
+	lock  sync.Mutex
+	queue housekeepingQueue
+	items map[*containerData]*scheduledHousekeeping
+
+	workers  int
+	deadline time.Duration
+	// Does one housekeeping of a container.
+	housekeep func(*containerData)
+
+	// Wakes the scheduler up when the queue changes.
+	wake chan struct{}
+}
+
+func newHousekeepingPool(workers int, deadline time.Duration) *housekeepingPool {
+	if workers <= 0 {
+		workers = 2 * runtime.NumCPU()
+	}
+	return &housekeepingPool{
+		items:     make(map[*containerData]*scheduledHousekeeping),
+		workers:   workers,
+		deadline:  deadline,
+		housekeep: (*containerData).housekeepingOnce,
+		wake:      make(chan struct{}, 1),
+	}
+}
+
+// Starts the workers and the scheduler handing them the housekeepings that are due, until asked
+// to quit. Quitting waits for the housekeepings being done, up to the deadline. The pool can be
+// started again once it quit.
+func (self *housekeepingPool) Start(quit chan error) {
+	glog.Infof("Housekeeping containers with %d workers", self.workers)
+	work := make(chan *scheduledHousekeeping)
+	workers := &sync.WaitGroup{}
+	workers.Add(self.workers)
+	for i := 0; i < self.workers; i++ {
+		go func() {
+			defer workers.Done()
+			self.worker(work)
+		}()
+	}
+	go func() {
+		self.schedule(work, quit)
+		close(work)
+		workers.Wait()
+		quit <- nil
+	}()
+}
+
+// Schedules the housekeeping of a container, due at a random time within its housekeeping
+// interval. Their phases are kept, so the housekeepings of containers created together, e.g. at
+// startup, are spread over the interval rather than all done at once.
+func (self *housekeepingPool) add(cont *containerData) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if _, ok := self.items[cont]; ok {
+		return fmt.Errorf("container %q is already housekept", cont.info.Name)
+	}
+	next := time.Now()
+	if cont.housekeepingInterval > 0 {
+		next = next.Add(time.Duration(rand.Int63n(int64(cont.housekeepingInterval))))
+	}
+	item := &scheduledHousekeeping{cont: cont, next: next}
+	self.items[cont] = item
+	heap.Push(&self.queue, item)
+	self.signal()
+	return nil
+}
+
+// Stops the housekeeping of a container, destroying it once its housekeeping is not being done.
+func (self *housekeepingPool) remove(cont *containerData) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	item, ok := self.items[cont]
+	if !ok {
+		return fmt.Errorf("container %q is not housekept", cont.info.Name)
+	}
+	delete(self.items, cont)
+	item.removed = true
+	if item.index >= 0 {
+		heap.Remove(&self.queue, item.index)
+	}
+	if !item.running {
+		go cont.destroy()
+	}
+	self.signal()
+	return nil
+}
+
+// Wakes the scheduler up, must hold the lock.
+func (self *housekeepingPool) signal() {
+	select {
+	case self.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Hands the housekeepings that are due to the workers.
+func (self *housekeepingPool) schedule(work chan *scheduledHousekeeping, quit chan error) {
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	for {
+		// Wait for the next housekeeping to be due.
+		self.lock.Lock()
+		var item *scheduledHousekeeping
+		wait := time.Hour
+		if len(self.queue) != 0 {
+			wait = self.queue[0].next.Sub(time.Now())
+			if wait <= 0 {
+				item = heap.Pop(&self.queue).(*scheduledHousekeeping)
+				item.running = true
+			}
+		}
+		self.lock.Unlock()
+
+		if item != nil {
+			// Waits for a worker to be free.
+			select {
+			case work <- item:
+			case <-quit:
+				// Due again when the pool starts again.
+				self.lock.Lock()
+				item.running = false
+				if item.removed {
+					go item.cont.destroy()
+				} else {
+					heap.Push(&self.queue, item)
+				}
+				self.lock.Unlock()
+				return
+			}
+			continue
+		}
+		timer.Reset(wait)
+		select {
+		case <-timer.C:
+		case <-self.wake:
+		case <-quit:
+			return
+		}
+	}
+}
+
+func (self *housekeepingPool) worker(work chan *scheduledHousekeeping) {
+	for item := range work {
+		done := make(chan struct{})
+		go func(item *scheduledHousekeeping) {
+			self.run(item)
+			close(done)
+		}(item)
+		timer := time.NewTimer(self.deadline)
+		select {
+		case <-done:
+		case <-timer.C:
+			glog.Warningf("Housekeeping of %q is taking longer than %v, going on with other containers", item.cont.info.Name, self.deadline)
+		}
+		timer.Stop()
+	}
+}
+
+// Does the housekeeping of a container and schedules the next one.
+func (self *housekeepingPool) run(item *scheduledHousekeeping) {
+	self.housekeep(item.cont)
+	next := item.cont.nextHousekeeping(item.next)
+	// The housekeepings missed, e.g. while one overran its deadline, are skipped rather than done
+	// back to back to catch up. The phase of the container is kept.
+	now := time.Now()
+	if interval := next.Sub(item.next); interval > 0 && next.Before(now) {
+		next = next.Add((now.Sub(next)/interval + 1) * interval)
+	}
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	item.running = false
+	if item.removed {
+		go item.cont.destroy()
+		return
+	}
+	item.next = next
+	heap.Push(&self.queue, item)
+	self.signal()
+}
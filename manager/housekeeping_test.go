@@ -0,0 +1,226 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"container/heap"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/info"
+	stest "github.com/google/cadvisor/storage/test"
+)
+
+// Counts the housekeepings of the containers of a pool.
+type housekeepingCounter struct {
+	lock   sync.Mutex
+	counts map[string]int
+}
+
+func (self *housekeepingCounter) housekeep(cont *containerData) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.counts[cont.info.Name]++
+}
+
+func (self *housekeepingCounter) count(name string) int {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.counts[name]
+}
+
+func newTestHousekeepingPool(workers int, deadline time.Duration) (*housekeepingPool, *housekeepingCounter) {
+	counter := &housekeepingCounter{counts: make(map[string]int)}
+	pool := newHousekeepingPool(workers, deadline)
+	pool.housekeep = counter.housekeep
+	return pool, counter
+}
+
+func newHousekeptContainer(name string) *containerData {
+	driver := &stest.MockStorageDriver{}
+	driver.On("RecentStats", name, 2).Return([]*info.ContainerStats{}, nil)
+	options := DefaultOptions()
+	options.HousekeepingInterval = 10 * time.Millisecond
+	cont := &containerData{
+		storageDriver:        driver,
+		housekeepingInterval: options.HousekeepingInterval,
+		options:              &currentOptions{options: options},
+	}
+	cont.info.Name = name
+	return cont
+}
+
+// Waits for the condition to hold, for up to a second.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	for i := 0; i < 100; i++ {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %s", what)
+}
+
+func stopHousekeepingPool(t *testing.T, quit chan error) {
+	quit <- nil
+	if err := <-quit; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHousekeepingPool(t *testing.T) {
+	pool, counter := newTestHousekeepingPool(1, time.Second)
+	quit := make(chan error)
+	pool.Start(quit)
+	defer stopHousekeepingPool(t, quit)
+
+	a := newHousekeptContainer("/a")
+	b := newHousekeptContainer("/b")
+	for _, cont := range []*containerData{a, b} {
+		if err := pool.add(cont); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := pool.add(a); err == nil {
+		t.Errorf("added %q twice", a.info.Name)
+	}
+	waitFor(t, "both containers to be housekept", func() bool {
+		return counter.count("/a") >= 3 && counter.count("/b") >= 3
+	})
+
+	// No housekeeping of a container once removed.
+	if err := pool.remove(a); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.remove(a); err == nil {
+		t.Errorf("removed %q twice", a.info.Name)
+	}
+	removedCount := counter.count("/a")
+	bCount := counter.count("/b")
+	waitFor(t, "the other container to be housekept", func() bool {
+		return counter.count("/b") >= bCount+3
+	})
+	// The removal may have happened during a housekeeping.
+	if count := counter.count("/a"); count > removedCount+1 {
+		t.Errorf("%q housekept %d times after its removal", a.info.Name, count-removedCount)
+	}
+}
+
+func TestHousekeepingPoolDeadline(t *testing.T) {
+	pool, counter := newTestHousekeepingPool(1, 20*time.Millisecond)
+	stuck := make(chan struct{})
+	pool.housekeep = func(cont *containerData) {
+		if cont.info.Name == "/stuck" {
+			<-stuck
+		}
+		counter.housekeep(cont)
+	}
+	quit := make(chan error)
+	pool.Start(quit)
+	defer stopHousekeepingPool(t, quit)
+
+	if err := pool.add(newHousekeptContainer("/stuck")); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.add(newHousekeptContainer("/other")); err != nil {
+		t.Fatal(err)
+	}
+
+	// The only worker goes on with the other container past the deadline.
+	waitFor(t, "the other container to be housekept", func() bool {
+		return counter.count("/other") >= 3
+	})
+	if count := counter.count("/stuck"); count != 0 {
+		t.Errorf("stuck container housekept %d times", count)
+	}
+
+	// The stuck container is housekept again once its housekeeping completes.
+	close(stuck)
+	waitFor(t, "the stuck container to be housekept", func() bool {
+		return counter.count("/stuck") >= 2
+	})
+}
+
+func TestHousekeepingPoolSkipsMissed(t *testing.T) {
+	pool, counter := newTestHousekeepingPool(1, time.Second)
+	cont := newHousekeptContainer("/late")
+	interval := cont.housekeepingInterval
+	// The housekeeping overruns several intervals.
+	pool.housekeep = func(cont *containerData) {
+		time.Sleep(5 * interval)
+		counter.housekeep(cont)
+	}
+	if err := pool.add(cont); err != nil {
+		t.Fatal(err)
+	}
+	item := heap.Pop(&pool.queue).(*scheduledHousekeeping)
+	due := item.next
+	item.running = true
+	pool.run(item)
+	done := time.Now()
+
+	if item.next.Before(done) {
+		t.Errorf("next housekeeping due at %v, before the late one completed at %v", item.next, done)
+	}
+	if item.next.After(done.Add(interval)) {
+		t.Errorf("next housekeeping due at %v, more than %v after the late one completed at %v", item.next, interval, done)
+	}
+	if elapsed := item.next.Sub(due); elapsed%interval != 0 {
+		t.Errorf("next housekeeping due %v after the previous one, not a multiple of %v", elapsed, interval)
+	}
+}
+
+func TestHousekeepingPoolStaggers(t *testing.T) {
+	pool, _ := newTestHousekeepingPool(1, time.Second)
+	start := time.Now()
+	phases := make(map[time.Time]bool)
+	for i := 0; i < 10; i++ {
+		cont := newHousekeptContainer(fmt.Sprintf("/%d", i))
+		if err := pool.add(cont); err != nil {
+			t.Fatal(err)
+		}
+		next := pool.items[cont].next
+		if next.Before(start) || !next.Before(time.Now().Add(cont.housekeepingInterval)) {
+			t.Errorf("housekeeping of %q due at %v, not within its interval from %v", cont.info.Name, next, start)
+		}
+		phases[next] = true
+	}
+	if len(phases) == 1 {
+		t.Errorf("housekeepings all due at once")
+	}
+}
+
+func TestHousekeepingPoolRestart(t *testing.T) {
+	pool, counter := newTestHousekeepingPool(1, time.Second)
+	if err := pool.add(newHousekeptContainer("/a")); err != nil {
+		t.Fatal(err)
+	}
+	quit := make(chan error)
+	pool.Start(quit)
+	waitFor(t, "the container to be housekept", func() bool {
+		return counter.count("/a") >= 2
+	})
+	stopHousekeepingPool(t, quit)
+
+	// The container is housekept again once the pool starts again.
+	count := counter.count("/a")
+	pool.Start(quit)
+	defer stopHousekeepingPool(t, quit)
+	waitFor(t, "the container to be housekept again", func() bool {
+		return counter.count("/a") >= count+2
+	})
+}
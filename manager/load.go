@@ -0,0 +1,72 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"flag"
+	"math"
+	"time"
+
+	"github.com/google/cadvisor/info"
+	"github.com/google/cadvisor/utils/procfs"
+)
+
+var enableLoadReader = flag.Bool("enable_load_reader", false, "Whether to report the load average of containers, sampling the state of their threads at every housekeeping")
+
+// Period over which the load is averaged.
+const loadAverageWindow = 10 * time.Second
+
+// Counts the threads by state, ignoring the ones that exited meanwhile.
+func countThreadStates(threads []int) info.LoadStats {
+	var stats info.LoadStats
+	for _, tid := range threads {
+		stat, err := procfs.ReadProcessStat(tid)
+		if err != nil {
+			continue
+		}
+		switch stat.State {
+		case "R":
+			stats.NrRunning++
+		case "D":
+			stats.NrUninterruptible++
+		case "T", "t":
+			stats.NrStopped++
+		case "S", "I":
+			stats.NrSleeping++
+		}
+	}
+	return stats
+}
+
+// Exponentially decaying average of the runnable and uninterruptible threads of a container, like
+// the load average of the machine.
+type loadAverage struct {
+	load float64
+	last time.Time
+}
+
+// Adds the threads of the container at the time of its stats, and reports its load in them.
+func (self *loadAverage) add(stats *info.ContainerStats, threads info.LoadStats) {
+	current := float64(threads.NrRunning + threads.NrUninterruptible)
+	if self.last.IsZero() {
+		self.load = current
+	} else {
+		decay := math.Exp(-float64(stats.Timestamp.Sub(self.last)) / float64(loadAverageWindow))
+		self.load = self.load*decay + current*(1-decay)
+	}
+	self.last = stats.Timestamp
+	stats.Cpu.Load = int32(self.load*1000 + 0.5)
+	stats.TaskStats = threads
+}
@@ -0,0 +1,70 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"code.google.com/p/gomock/gomock"
+	"github.com/google/cadvisor/info"
+	"github.com/google/cadvisor/utils/fs"
+	"github.com/google/cadvisor/utils/fs/mockfs"
+)
+
+func TestCountThreadStates(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mfs := mockfs.NewMockFileSystem(mockCtrl)
+	for tid, state := range map[int]string{1: "R", 2: "R", 3: "D", 4: "S", 5: "T"} {
+		content := fmt.Sprintf("%d (worker) %s 1 1 1 0 -1 0 0 0 0 0 10 10 0 0 20 0 1 0 100 4096 10 0 0 0 0 0 0 0 0 0 0 0 0 17 0 0 0 0 0 0\n", tid, state)
+		mockfs.AddTextFile(mfs, fmt.Sprintf("/proc/%d/stat", tid), content)
+	}
+	fs.ChangeFileSystem(mfs)
+
+	stats := countThreadStates([]int{1, 2, 3, 4, 5})
+	expected := info.LoadStats{NrRunning: 2, NrUninterruptible: 1, NrSleeping: 1, NrStopped: 1}
+	if stats != expected {
+		t.Errorf("expected %+v, got %+v", expected, stats)
+	}
+}
+
+func TestLoadAverage(t *testing.T) {
+	var load loadAverage
+	start := time.Unix(1000, 0)
+
+	// The first sample is the load.
+	stats := &info.ContainerStats{Timestamp: start}
+	load.add(stats, info.LoadStats{NrRunning: 2, NrUninterruptible: 1, NrSleeping: 5})
+	if stats.Cpu.Load != 3000 || stats.TaskStats.NrSleeping != 5 {
+		t.Errorf("unexpected load %d and threads %+v", stats.Cpu.Load, stats.TaskStats)
+	}
+
+	// The load decays towards the current number of threads over the window.
+	stats = &info.ContainerStats{Timestamp: start.Add(loadAverageWindow)}
+	load.add(stats, info.LoadStats{})
+	if stats.Cpu.Load != 1104 {
+		t.Errorf("expected a load of 1104 after a window without threads, got %d", stats.Cpu.Load)
+	}
+	for i := 2; i < 20; i++ {
+		stats = &info.ContainerStats{Timestamp: start.Add(time.Duration(i) * loadAverageWindow)}
+		load.add(stats, info.LoadStats{NrRunning: 1})
+	}
+	if stats.Cpu.Load != 1000 {
+		t.Errorf("expected the load to converge to 1000, got %d", stats.Cpu.Load)
+	}
+}
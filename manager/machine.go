@@ -15,78 +15,18 @@
 package manager
 
 import (
-	"bytes"
-	"fmt"
 	"io/ioutil"
-	"regexp"
-	"strconv"
 	"strings"
-	"syscall"
 
-	dclient "github.com/fsouza/go-dockerclient"
 	"github.com/google/cadvisor/container/docker"
-	"github.com/google/cadvisor/fs"
 	"github.com/google/cadvisor/info"
-	"github.com/google/cadvisor/utils/sysfs"
 )
 
-var numCpuRegexp = regexp.MustCompile("processor\\t*: +[0-9]+")
-var memoryCapacityRegexp = regexp.MustCompile("MemTotal: *([0-9]+) kB")
+type byDevice []info.MachineDiskStats
 
-func getMachineInfo(sysFs sysfs.SysFs) (*info.MachineInfo, error) {
-	// Get the number of CPUs from /proc/cpuinfo.
-	out, err := ioutil.ReadFile("/proc/cpuinfo")
-	if err != nil {
-		return nil, err
-	}
-	numCores := len(numCpuRegexp.FindAll(out, -1))
-	if numCores == 0 {
-		return nil, fmt.Errorf("failed to count cores in output: %s", string(out))
-	}
-
-	// Get the amount of usable memory from /proc/meminfo.
-	out, err = ioutil.ReadFile("/proc/meminfo")
-	if err != nil {
-		return nil, err
-	}
-	matches := memoryCapacityRegexp.FindSubmatch(out)
-	if len(matches) != 2 {
-		return nil, fmt.Errorf("failed to find memory capacity in output: %s", string(out))
-	}
-	memoryCapacity, err := strconv.ParseInt(string(matches[1]), 10, 64)
-	if err != nil {
-		return nil, err
-	}
-
-	// Capacity is in KB, convert it to bytes.
-	memoryCapacity = memoryCapacity * 1024
-
-	fsInfo, err := fs.NewFsInfo()
-	if err != nil {
-		return nil, err
-	}
-	filesystems, err := fsInfo.GetGlobalFsInfo()
-	if err != nil {
-		return nil, err
-	}
-
-	diskMap, err := sysfs.GetBlockDeviceInfo(sysFs)
-	if err != nil {
-		return nil, err
-	}
-
-	machineInfo := &info.MachineInfo{
-		NumCores:       numCores,
-		MemoryCapacity: memoryCapacity,
-		DiskMap:        diskMap,
-	}
-
-	for _, fs := range filesystems {
-		machineInfo.Filesystems = append(machineInfo.Filesystems, info.FsInfo{fs.Device, fs.Capacity})
-	}
-
-	return machineInfo, nil
-}
+func (s byDevice) Len() int           { return len(s) }
+func (s byDevice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byDevice) Less(i, j int) bool { return s[i].Device < s[j].Device }
 
 func getVersionInfo() (*info.VersionInfo, error) {
 
@@ -121,7 +61,10 @@ func getContainerOsVersion() string {
 
 func getDockerVersion() string {
 	docker_version := "Unknown"
-	client, err := dclient.NewClient(*docker.ArgDockerEndpoint)
+	if docker.Detect() != nil {
+		return docker_version
+	}
+	client, err := docker.NewClient()
 	if err == nil {
 		version, err := client.Version()
 		if err == nil {
@@ -130,21 +73,3 @@ func getDockerVersion() string {
 	}
 	return docker_version
 }
-
-func getKernelVersion() string {
-	uname := &syscall.Utsname{}
-
-	if err := syscall.Uname(uname); err != nil {
-		return "Unknown"
-	}
-
-	release := make([]byte, len(uname.Release))
-	i := 0
-	for _, c := range uname.Release {
-		release[i] = byte(c)
-		i++
-	}
-	release = release[:bytes.IndexByte(release, 0)]
-
-	return string(release)
-}
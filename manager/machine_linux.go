@@ -0,0 +1,168 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package manager
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+	"syscall"
+
+	"github.com/docker/libcontainer/cgroups"
+	"github.com/google/cadvisor/fs"
+	"github.com/google/cadvisor/info"
+	"github.com/google/cadvisor/utils/procfs"
+	"github.com/google/cadvisor/utils/sysfs"
+)
+
+var numCpuRegexp = regexp.MustCompile("processor\\t*: +[0-9]+")
+var memoryCapacityRegexp = regexp.MustCompile("MemTotal: *([0-9]+) kB")
+
+func getMachineInfo(sysFs sysfs.SysFs) (*info.MachineInfo, error) {
+	// Get the number of CPUs from /proc/cpuinfo.
+	out, err := ioutil.ReadFile("/proc/cpuinfo")
+	if err != nil {
+		return nil, err
+	}
+	numCores := len(numCpuRegexp.FindAll(out, -1))
+	if numCores == 0 {
+		return nil, fmt.Errorf("failed to count cores in output: %s", string(out))
+	}
+
+	// Get the amount of usable memory from /proc/meminfo.
+	out, err = ioutil.ReadFile("/proc/meminfo")
+	if err != nil {
+		return nil, err
+	}
+	matches := memoryCapacityRegexp.FindSubmatch(out)
+	if len(matches) != 2 {
+		return nil, fmt.Errorf("failed to find memory capacity in output: %s", string(out))
+	}
+	memoryCapacity, err := strconv.ParseInt(string(matches[1]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	// Capacity is in KB, convert it to bytes.
+	memoryCapacity = memoryCapacity * 1024
+
+	fsInfo, err := fs.NewFsInfo()
+	if err != nil {
+		return nil, err
+	}
+	filesystems, err := fsInfo.GetGlobalFsInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	diskMap, err := sysfs.GetBlockDeviceInfo(sysFs)
+	if err != nil {
+		return nil, err
+	}
+
+	hugePages, err := sysfs.GetHugePagesInfo(sysFs)
+	if err != nil {
+		return nil, err
+	}
+
+	topology, err := sysfs.GetNodesInfo(sysFs)
+	if err != nil {
+		return nil, err
+	}
+
+	machineInfo := &info.MachineInfo{
+		NumCores:       numCores,
+		MemoryCapacity: memoryCapacity,
+		DiskMap:        diskMap,
+		HugePages:      hugePages,
+		Topology:       topology,
+	}
+
+	for _, fs := range filesystems {
+		machineInfo.Filesystems = append(machineInfo.Filesystems, info.FsInfo{fs.Device, fs.Capacity, fs.Inodes})
+	}
+
+	return machineInfo, nil
+}
+
+// Returns the I/O of the block devices of the machine, those of its disk map.
+func getMachineDiskStats(diskMap map[string]info.DiskInfo) ([]info.MachineDiskStats, error) {
+	diskStats, err := fs.GetDeviceDiskStats()
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]info.MachineDiskStats, 0, len(diskMap))
+	for device, disk := range diskMap {
+		stats, ok := diskStats[device]
+		if !ok {
+			continue
+		}
+		ret = append(ret, info.MachineDiskStats{
+			Device:          disk.Name,
+			Major:           disk.Major,
+			Minor:           disk.Minor,
+			ReadsCompleted:  stats.ReadsCompleted,
+			WritesCompleted: stats.WritesCompleted,
+			SectorsRead:     stats.SectorsRead,
+			SectorsWritten:  stats.SectorsWritten,
+			IoTime:          stats.IoTime,
+			WeightedIoTime:  stats.WeightedIoTime,
+		})
+	}
+	sort.Sort(byDevice(ret))
+	return ret, nil
+}
+
+// Returns the interrupts, softirqs and context switches of the machine.
+func getMachineInterruptStats() (info.InterruptStats, error) {
+	interrupts, err := procfs.ReadInterrupts()
+	if err != nil {
+		return info.InterruptStats{}, err
+	}
+	return info.InterruptStats{
+		Interrupts:      interrupts.Hardware,
+		Softirqs:        interrupts.Softirqs,
+		ContextSwitches: interrupts.ContextSwitches,
+	}, nil
+}
+
+func getKernelVersion() string {
+	uname := &syscall.Utsname{}
+
+	if err := syscall.Uname(uname); err != nil {
+		return "Unknown"
+	}
+
+	release := make([]byte, len(uname.Release))
+	i := 0
+	for _, c := range uname.Release {
+		release[i] = byte(c)
+		i++
+	}
+	release = release[:bytes.IndexByte(release, 0)]
+
+	return string(release)
+}
+
+// Returns the cgroup cAdvisor runs in.
+func getSelfContainer() (string, error) {
+	return cgroups.GetThisCgroupDir("cpu")
+}
@@ -0,0 +1,60 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+// +build windows
+
+package manager
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/google/cadvisor/info"
+	"github.com/google/cadvisor/utils/sysfs"
+	"github.com/google/cadvisor/utils/winsys"
+)
+
+// The disks, huge pages and topology of the machine are read from sysfs, only on Linux.
+func getMachineInfo(sysFs sysfs.SysFs) (*info.MachineInfo, error) {
+	memory, _, err := winsys.Memory()
+	if err != nil {
+		return nil, err
+	}
+	return &info.MachineInfo{
+		NumCores:       runtime.NumCPU(),
+		MemoryCapacity: int64(memory),
+	}, nil
+}
+
+func getMachineDiskStats(diskMap map[string]info.DiskInfo) ([]info.MachineDiskStats, error) {
+	return nil, fmt.Errorf("the disk stats of the machine are only read on Linux")
+}
+
+func getMachineInterruptStats() (info.InterruptStats, error) {
+	return info.InterruptStats{}, fmt.Errorf("the interrupts of the machine are only read on Linux")
+}
+
+func getKernelVersion() string {
+	version, err := winsys.KernelVersion()
+	if err != nil {
+		return "Unknown"
+	}
+	return version
+}
+
+// cAdvisor does not run in a cgroup on Windows.
+func getSelfContainer() (string, error) {
+	return "", nil
+}
@@ -18,23 +18,46 @@ package manager
 import (
 	"flag"
 	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
 	"path"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/docker/libcontainer/cgroups"
 	"github.com/golang/glog"
+	"github.com/google/cadvisor/alert"
+	"github.com/google/cadvisor/collector"
 	"github.com/google/cadvisor/container"
 	"github.com/google/cadvisor/container/docker"
+	"github.com/google/cadvisor/events"
 	"github.com/google/cadvisor/info"
+	"github.com/google/cadvisor/perf"
+	"github.com/google/cadvisor/power"
+	"github.com/google/cadvisor/resctrl"
 	"github.com/google/cadvisor/storage"
+	"github.com/google/cadvisor/utils/oomparser"
+	"github.com/google/cadvisor/utils/procfs"
 	"github.com/google/cadvisor/utils/sysfs"
 )
 
 var globalHousekeepingInterval = flag.Duration("global_housekeeping_interval", 1*time.Minute, "Interval between global housekeepings")
 var logCadvisorUsage = flag.Bool("log_cadvisor_usage", false, "Whether to log the usage of the cAdvisor container")
+var statsdReceiverAddress = flag.String("statsd_receiver_address", "", "UDP address to receive the metrics applications push with the StatsD protocol at (e.g.: :8125), reported as the custom metrics of their container. Disabled if empty")
+var alertThresholds = flag.String("alert_thresholds", "", "Thresholds on the metrics of all containers, as comma-separated name=expression (e.g.: high_memory=memory.working_set > 90% for 60s). Containers add their own with io.cadvisor.alert.<name> labels")
+var alertWebhook = flag.String("alert_webhook", "", "URL to post the alerts that fire or resolve to, in JSON. Alerts are only logged if empty")
+var eventStorageAgeLimit = flag.Duration("event_storage_age_limit", 24*time.Hour, "Maximum age of the events of containers kept in memory for the events API. No limit if 0")
+var eventStorageEventLimit = flag.Int("event_storage_event_limit", 100000, "Maximum number of events of containers kept in memory for the events API. No limit if 0")
+var eventStorageDir = flag.String("event_storage_dir", "", "Directory to persist the events of containers in, so the events API serves them across restarts within the event storage limits. Events are only kept in memory if empty")
+var eventWebhook = flag.String("event_webhook", "", "URL to post the events of containers to, in JSON. Disabled if empty")
+var eventExec = flag.String("event_exec", "", "Command to run for every event of containers, with the event in JSON on its standard input. Disabled if empty")
+var eventSinkTypes = flag.String("event_sink_types", "", "Comma-separated types of the events delivered to --event_webhook and --event_exec (containerCreation, containerDeletion, oom, threshold). All types if empty")
+var eventSinkRateLimit = flag.Int("event_sink_rate_limit", 60, "Maximum number of events delivered per minute to each of --event_webhook and --event_exec, the others are dropped. No limit if 0")
+var watchSpecs = flag.Bool("watch_container_specs", false, "Whether to update the spec of containers when their cgroup files are written to, watched with inotify, rather than every 30s. Each container takes up to 5 inotify watches, out of fs.inotify.max_user_watches. Their spec is still updated every 5m to notice changes inherited from their parent cgroups. Only supported on Linux")
+var applicationMetricsCountLimit = flag.Int("application_metrics_count_limit", 100, "Maximum number of custom metrics collected from the application of a container")
 
 // The Manager interface defines operations for starting a manager and getting
 // container and machine information.
@@ -60,18 +83,76 @@ type Manager interface {
 	// Get information about the machine.
 	GetMachineInfo() (*info.MachineInfo, error)
 
+	// Get the number of containers monitored and of those evicted to stay under the maximum.
+	GetTrackingStatus() info.TrackingStatus
+
 	// Get version information about different components we depend on.
 	GetVersionInfo() (*info.VersionInfo, error)
+
+	// Sums the latest usage of all containers grouped by the value of the specified label.
+	// Returns a map from label value to the aggregated usage.
+	AggregateByLabel(label string) (map[string]*info.AggregateStats, error)
+
+	// Lists the processes running in the specified container.
+	GetProcessList(containerName string) ([]info.ProcessInfo, error)
+
+	// Returns an error if container housekeeping has stalled.
+	CheckHousekeeping() error
+
+	// Get the latest stats of all containers, from the same housekeeping interval.
+	GetSnapshot() (*info.Snapshot, error)
+
+	// Get the health of the storage drivers stats are exported to.
+	GetStorageStatus() []info.StorageDriverStatus
+
+	// Returns the collector configs shared by containers, sorted by name.
+	GetCollectorConfigs() []collector.SharedConfig
+
+	// Adds or replaces a collector config shared by the containers matching its selector.
+	SetCollectorConfig(config *collector.SharedConfig) error
+
+	// Removes a collector config shared by containers.
+	RemoveCollectorConfig(name string) error
+
+	// Get the past events of containers matching the request, oldest first.
+	GetPastEvents(request *events.Request) ([]*info.Event, error)
+
+	// Get a channel of the new events of containers matching the request.
+	WatchForEvents(request *events.Request) (*events.EventChannel, error)
+
+	// Stops the watch of the channel returned by WatchForEvents.
+	CloseEventChannel(watchId int)
+
+	// Returns the thresholds registered through the API, sorted by name.
+	GetTriggers() []alert.Trigger
+
+	// Adds or replaces a threshold of a container, recording threshold events when it is crossed.
+	SetTrigger(trigger *alert.Trigger) error
+
+	// Removes a threshold registered through the API.
+	RemoveTrigger(name string) error
+
+	// Replaces the metrics collected and the housekeeping intervals of the running manager. The
+	// other options only apply when the manager is created.
+	UpdateOptions(options Options) error
 }
 
-// New takes a driver and returns a new manager.
-func New(driver storage.StorageDriver, sysfs sysfs.SysFs) (Manager, error) {
-	if driver == nil {
-		return nil, fmt.Errorf("nil storage driver!")
+// Creates a manager with the specified options, see DefaultOptions.
+func New(options Options) (Manager, error) {
+	if err := options.validate(); err != nil {
+		return nil, err
 	}
+	if options.SysFs == nil {
+		var err error
+		options.SysFs, err = sysfs.NewRealSysFs()
+		if err != nil {
+			return nil, err
+		}
+	}
+	container.SetMetrics(options.Metrics)
 
 	// Detect the container we are running on.
-	selfContainer, err := cgroups.GetThisCgroupDir("cpu")
+	selfContainer, err := getSelfContainer()
 	if err != nil {
 		return nil, err
 	}
@@ -79,26 +160,60 @@ func New(driver storage.StorageDriver, sysfs sysfs.SysFs) (Manager, error) {
 
 	newManager := &manager{
 		containers:        make(map[namespacedContainerName]*containerData),
+		sharedCollectors:  make(map[string]*collector.SharedConfig),
+		triggers:          make(map[string]*alert.Trigger),
 		quitChannels:      make([]chan error, 0, 2),
-		storageDriver:     driver,
+		storageDriver:     options.StorageDriver,
 		cadvisorContainer: selfContainer,
+		housekeepingPool:  newHousekeepingPool(options.HousekeepingWorkers, options.HousekeepingDeadline),
+		snapshotter:       snapshotter{interval: options.HousekeepingInterval},
+		options:           currentOptions{options: options},
+		evicted:           make(map[string]bool),
 	}
 
-	machineInfo, err := getMachineInfo(sysfs)
+	machineInfo, err := getMachineInfo(options.SysFs)
 	if err != nil {
 		return nil, err
 	}
 	newManager.machineInfo = *machineInfo
 	glog.Infof("Machine: %+v", newManager.machineInfo)
 
+	if err := perf.Setup(machineInfo.NumCores); err != nil {
+		return nil, err
+	}
+	if err := resctrl.Setup(); err != nil {
+		return nil, err
+	}
+
 	versionInfo, err := getVersionInfo()
 	if err != nil {
 		return nil, err
 	}
 	newManager.versionInfo = *versionInfo
 	glog.Infof("Version: %+v", newManager.versionInfo)
-	newManager.storageDriver = driver
 
+	if *eventStorageDir != "" {
+		newManager.eventHandler, err = events.NewPersistentEventManager(*eventStorageDir, *eventStorageAgeLimit, *eventStorageEventLimit)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the events of %q: %v", *eventStorageDir, err)
+		}
+	} else {
+		newManager.eventHandler = events.NewEventManager(*eventStorageAgeLimit, *eventStorageEventLimit)
+	}
+
+	newManager.alertThresholds, err = alert.ParseThresholds(*alertThresholds)
+	if err != nil {
+		return nil, err
+	}
+	if *alertWebhook != "" {
+		newManager.alertWebhook = alert.NewWebhook(*alertWebhook)
+	}
+
+	for _, register := range options.Factories {
+		if err := register(newManager); err != nil {
+			glog.Infof("Container handler factory registration skipped: %v", err)
+		}
+	}
 	return newManager, nil
 }
 
@@ -120,12 +235,91 @@ type manager struct {
 	quitChannels           []chan error
 	cadvisorContainer      string
 	dockerContainersRegexp *regexp.Regexp
+	snapshotter            snapshotter
+	// Receiver of the StatsD metrics of applications, nil unless --statsd_receiver_address.
+	statsdReceiver *collector.StatsdReceiver
+	// Thresholds on the metrics of all containers, from --alert_thresholds.
+	alertThresholds []*alert.Threshold
+	// Where alerts are posted, nil unless --alert_webhook.
+	alertWebhook *alert.Webhook
+	// Collector configs shared by the containers matching their selector, by name.
+	sharedCollectors     map[string]*collector.SharedConfig
+	sharedCollectorsLock sync.Mutex
+	// Events of the containers.
+	eventHandler events.EventManager
+	// Whether OOM kills are read from the kernel log, rather than counted by memory cgroups.
+	oomFromKernelLog bool
+	// Thresholds registered through the API, by name.
+	triggers     map[string]*alert.Trigger
+	triggersLock sync.Mutex
+	// Does the housekeeping of all containers.
+	housekeepingPool *housekeepingPool
+	// Watches the changes of the specs of containers, nil unless --watch_container_specs.
+	specWatcher *specWatcher
+	options     currentOptions
+	// Number of containers monitored, guarded by containersLock.
+	numContainers int
+	// Containers evicted to stay under MaxContainers, not monitored again until they are removed,
+	// guarded by containersLock.
+	evicted map[string]bool
+	// Containers evicted and not monitored for lack of room, guarded by containersLock.
+	evictions  uint64
+	rejections uint64
 }
 
 // Start the container manager.
 func (self *manager) Start() error {
+	// Receive the StatsD metrics first, their collectors are registered as containers are created.
+	var err error
+	if *statsdReceiverAddress != "" && container.HasMetric(container.AppMetrics) {
+		self.statsdReceiver, err = collector.NewStatsdReceiver(*statsdReceiverAddress, self.containerByAddress, *applicationMetricsCountLimit)
+		if err != nil {
+			return fmt.Errorf("failed to receive StatsD metrics at %q: %v", *statsdReceiverAddress, err)
+		}
+		self.statsdReceiver.Start()
+		quitStatsd := make(chan error)
+		self.quitChannels = append(self.quitChannels, quitStatsd)
+		go func() {
+			<-quitStatsd
+			quitStatsd <- self.statsdReceiver.Close()
+		}()
+	}
+
+	// Read the OOM kills from the kernel log if possible, they tell the process killed.
+	oomParser, err := oomparser.New()
+	if err != nil {
+		glog.Infof("Counting the OOM kills of containers from their memory cgroup, the kernel log is not readable: %v", err)
+	} else {
+		self.oomFromKernelLog = true
+		quitOoms := make(chan error)
+		self.quitChannels = append(self.quitChannels, quitOoms)
+		go self.watchForOoms(oomParser, quitOoms)
+	}
+
+	// Deliver the events to the sinks from the creation of the first container.
+	if err := self.startEventSinks(); err != nil {
+		return err
+	}
+
+	// Watch the specs of the containers as they are created.
+	if *watchSpecs {
+		self.specWatcher, err = newSpecWatcher()
+		if err != nil {
+			glog.Warningf("Updating the spec of containers every %v, failed to watch their cgroups: %v", specUpdateInterval, err)
+		} else {
+			quitSpecs := make(chan error)
+			self.quitChannels = append(self.quitChannels, quitSpecs)
+			self.specWatcher.Start(quitSpecs)
+		}
+	}
+
+	// Housekeep the containers as they are created.
+	quitHousekeeping := make(chan error)
+	self.quitChannels = append(self.quitChannels, quitHousekeeping)
+	self.housekeepingPool.Start(quitHousekeeping)
+
 	// Create root and then recover all containers.
-	err := self.createContainer("/")
+	err = self.createContainer("/")
 	if err != nil {
 		return err
 	}
@@ -136,6 +330,15 @@ func (self *manager) Start() error {
 	}
 	glog.Infof("Recovery completed")
 
+	// Load the shared collector configs once the containers they apply to exist.
+	if *collectorConfigDir != "" && container.HasMetric(container.AppMetrics) {
+		quitCollectorConfigs := make(chan error)
+		if err := self.watchCollectorConfigDir(quitCollectorConfigs); err != nil {
+			return fmt.Errorf("failed to watch the collector configurations of %q: %v", *collectorConfigDir, err)
+		}
+		self.quitChannels = append(self.quitChannels, quitCollectorConfigs)
+	}
+
 	// Watch for new container.
 	quitWatcher := make(chan error)
 	err = self.watchForNewContainers(quitWatcher)
@@ -149,6 +352,13 @@ func (self *manager) Start() error {
 	self.quitChannels = append(self.quitChannels, quitGlobalHousekeeping)
 	go self.globalHousekeeping(quitGlobalHousekeeping)
 
+	// Export the stats of the machine if the storage driver supports it.
+	if machineDriver, ok := self.storageDriver.(storage.MachineStatsDriver); ok {
+		quitMachineStats := make(chan error)
+		self.quitChannels = append(self.quitChannels, quitMachineStats)
+		go self.exportMachineStats(machineDriver, quitMachineStats)
+	}
+
 	return nil
 }
 
@@ -165,20 +375,39 @@ func (self *manager) Stop() error {
 		}
 	}
 	self.quitChannels = make([]chan error, 0, 2)
+	self.specWatcher = nil
+
+	// Stop the housekeeping of the containers, they are found again if the manager starts again.
+	self.containersLock.Lock()
+	defer self.containersLock.Unlock()
+	stopped := make(map[*containerData]bool, len(self.containers))
+	for _, cont := range self.containers {
+		if stopped[cont] {
+			continue
+		}
+		stopped[cont] = true
+		if err := cont.Stop(); err != nil {
+			glog.Warningf("Failed to stop the housekeeping of %q: %v", cont.info.Name, err)
+		}
+	}
+	self.containers = make(map[namespacedContainerName]*containerData)
+	self.numContainers = 0
+	self.evicted = make(map[string]bool)
 	return nil
 }
 
 func (self *manager) globalHousekeeping(quit chan error) {
-	// Long housekeeping is either 100ms or half of the housekeeping interval.
-	longHousekeeping := 100 * time.Millisecond
-	if *globalHousekeepingInterval/2 < longHousekeeping {
-		longHousekeeping = *globalHousekeepingInterval / 2
-	}
-
-	ticker := time.Tick(*globalHousekeepingInterval)
 	for {
+		// The interval may be updated while running.
+		interval := self.options.get().GlobalHousekeepingInterval
+		// Long housekeeping is either 100ms or half of the housekeeping interval.
+		longHousekeeping := 100 * time.Millisecond
+		if interval/2 < longHousekeeping {
+			longHousekeeping = interval / 2
+		}
+
 		select {
-		case t := <-ticker:
+		case t := <-time.After(interval):
 			start := time.Now()
 
 			// Check for new containers.
@@ -201,6 +430,47 @@ func (self *manager) globalHousekeeping(quit chan error) {
 	}
 }
 
+// Writes the stats of the machine to the storage driver whenever the root container has new stats.
+func (self *manager) exportMachineStats(driver storage.MachineStatsDriver, quit chan error) {
+	var last time.Time
+	for {
+		select {
+		case <-time.After(self.options.get().HousekeepingInterval):
+			stats, err := self.storageDriver.RecentStats("/", 1)
+			if err != nil || len(stats) == 0 || !stats[0].Timestamp.After(last) {
+				continue
+			}
+			last = stats[0].Timestamp
+			machineStats := info.NewMachineStats(&self.machineInfo, stats[0])
+			machineStats.Disks, err = getMachineDiskStats(self.machineInfo.DiskMap)
+			if err != nil {
+				glog.V(2).Infof("Failed to get the disk stats of the machine: %v", err)
+			}
+			machineStats.Interrupts, err = getMachineInterruptStats()
+			if err != nil {
+				glog.V(2).Infof("Failed to get the interrupts of the machine: %v", err)
+			}
+			machineStats.Energy, err = power.ReadEnergy()
+			if err != nil {
+				glog.V(2).Infof("Failed to get the energy consumed by the machine: %v", err)
+			}
+			machineStats.Thermal, err = power.ReadThermal()
+			if err != nil {
+				glog.V(2).Infof("Failed to get the temperature of the machine: %v", err)
+			}
+			err = driver.AddMachineStats(machineStats)
+			if err != nil {
+				glog.Errorf("Failed to export machine stats: %v", err)
+			}
+		case <-quit:
+			// Quit if asked to do so.
+			quit <- nil
+			glog.Infof("Exiting machine stats export thread")
+			return
+		}
+	}
+}
+
 // Get a container by name.
 func (self *manager) GetContainerInfo(containerName string, query *info.ContainerInfoRequest) (*info.ContainerInfo, error) {
 	var cont *containerData
@@ -221,6 +491,27 @@ func (self *manager) GetContainerInfo(containerName string, query *info.Containe
 	return self.containerDataToContainerInfo(cont, query)
 }
 
+// Keeps the latest stats and, going back in time, the stats at least resolution older than the
+// last kept. The stats are in time increasing order.
+func thinStats(stats []*info.ContainerStats, resolution time.Duration) []*info.ContainerStats {
+	if resolution <= 0 || len(stats) < 2 {
+		return stats
+	}
+	thinned := make([]*info.ContainerStats, 0, len(stats))
+	last := stats[len(stats)-1].Timestamp
+	thinned = append(thinned, stats[len(stats)-1])
+	for i := len(stats) - 2; i >= 0; i-- {
+		if last.Sub(stats[i].Timestamp) >= resolution {
+			last = stats[i].Timestamp
+			thinned = append(thinned, stats[i])
+		}
+	}
+	for i, j := 0, len(thinned)-1; i < j; i, j = i+1, j-1 {
+		thinned[i], thinned[j] = thinned[j], thinned[i]
+	}
+	return thinned
+}
+
 func (self *manager) containerDataToContainerInfo(cont *containerData, query *info.ContainerInfoRequest) (*info.ContainerInfo, error) {
 	// Get the info from the container.
 	cinfo, err := cont.GetInfo()
@@ -228,10 +519,19 @@ func (self *manager) containerDataToContainerInfo(cont *containerData, query *in
 		return nil, err
 	}
 
-	stats, err := self.storageDriver.RecentStats(cinfo.Name, query.NumStats)
+	var stats []*info.ContainerStats
+	rangeDriver, ok := self.storageDriver.(storage.TimeRangeDriver)
+	if ok && (!query.Start.IsZero() || !query.End.IsZero()) {
+		stats, err = rangeDriver.StatsInRange(cinfo.Name, query.Start, query.End, query.NumStats)
+	} else if history, ok := self.storageDriver.(storage.HistoryDriver); ok {
+		stats, err = history.History(cinfo.Name, query.NumStats)
+	} else {
+		stats, err = self.storageDriver.RecentStats(cinfo.Name, query.NumStats)
+	}
 	if err != nil {
 		return nil, err
 	}
+	stats = thinStats(stats, query.Resolution)
 
 	// Make a copy of the info for the user.
 	ret := &info.ContainerInfo{
@@ -239,6 +539,7 @@ func (self *manager) containerDataToContainerInfo(cont *containerData, query *in
 		Subcontainers:      cinfo.Subcontainers,
 		Spec:               cinfo.Spec,
 		Stats:              stats,
+		Errors:             cont.CollectionErrors(),
 	}
 
 	// Set default value to an actual value
@@ -342,6 +643,131 @@ func (self *manager) containerDataSliceToContainerInfoSlice(containers []*contai
 	return output, nil
 }
 
+func (self *manager) AggregateByLabel(label string) (map[string]*info.AggregateStats, error) {
+	var containers []*containerData
+	func() {
+		self.containersLock.RLock()
+		defer self.containersLock.RUnlock()
+		containers = make([]*containerData, 0, len(self.containers))
+
+		// Only use the canonical name of each container so none is counted twice.
+		for name, cont := range self.containers {
+			if name.Namespace == "" {
+				containers = append(containers, cont)
+			}
+		}
+	}()
+
+	// Two stats are needed to compute the usage rate.
+	query := &info.ContainerInfoRequest{
+		NumStats: 2,
+	}
+	infos, err := self.containerDataSliceToContainerInfoSlice(containers, query)
+	if err != nil {
+		return nil, err
+	}
+	return aggregateByLabel(label, infos), nil
+}
+
+func (self *manager) GetProcessList(containerName string) ([]info.ProcessInfo, error) {
+	var cont *containerData
+	var ok bool
+	func() {
+		self.containersLock.RLock()
+		defer self.containersLock.RUnlock()
+		cont, ok = self.containers[namespacedContainerName{
+			Name: containerName,
+		}]
+	}()
+	if !ok {
+		return nil, fmt.Errorf("unknown container %q", containerName)
+	}
+
+	pids, err := cont.handler.ListProcesses(container.ListSelf)
+	if err != nil {
+		return nil, err
+	}
+	uptime, err := procfs.ReadUptime()
+	if err != nil {
+		return nil, err
+	}
+	users := make(map[int]string)
+	processes := make([]info.ProcessInfo, 0, len(pids))
+	for _, pid := range pids {
+		process, err := getProcessInfo(pid, uptime, users)
+		if err != nil {
+			// The process may have exited since the container was listed.
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		processes = append(processes, *process)
+	}
+	return processes, nil
+}
+
+func (self *manager) GetSnapshot() (*info.Snapshot, error) {
+	var containers []*containerData
+	func() {
+		self.containersLock.RLock()
+		defer self.containersLock.RUnlock()
+		containers = make([]*containerData, 0, len(self.containers))
+
+		// Only use the canonical name of each container so none is collected twice.
+		for name, cont := range self.containers {
+			if name.Namespace == "" {
+				containers = append(containers, cont)
+			}
+		}
+	}()
+	return self.snapshotter.snapshot(containers), nil
+}
+
+func (self *manager) UpdateOptions(options Options) error {
+	self.options.lock.Lock()
+	defer self.options.lock.Unlock()
+	updated := self.options.options
+	updated.Metrics = options.Metrics
+	updated.HousekeepingInterval = options.HousekeepingInterval
+	updated.AllowDynamicHousekeeping = options.AllowDynamicHousekeeping
+	updated.MaxHousekeepingInterval = options.MaxHousekeepingInterval
+	updated.GlobalHousekeepingInterval = options.GlobalHousekeepingInterval
+	if err := updated.validate(); err != nil {
+		return err
+	}
+	self.options.options = updated
+	container.SetMetrics(updated.Metrics)
+	self.snapshotter.setInterval(updated.HousekeepingInterval)
+	glog.Infof("Updated the options of the manager: collecting %v every %v", updated.Metrics, updated.HousekeepingInterval)
+	return nil
+}
+
+func (self *manager) GetStorageStatus() []info.StorageDriverStatus {
+	if statusDriver, ok := self.storageDriver.(storage.StatusDriver); ok {
+		return statusDriver.Status()
+	}
+	return nil
+}
+
+func (self *manager) CheckHousekeeping() error {
+	self.containersLock.RLock()
+	root, ok := self.containers[namespacedContainerName{
+		Name: "/",
+	}]
+	self.containersLock.RUnlock()
+	if !ok {
+		return fmt.Errorf("root container is not being housekept")
+	}
+
+	// The root container always exists, it should be housekept at least every max interval.
+	lastHousekeeping := root.LastHousekeeping()
+	if since := time.Since(lastHousekeeping); since > 2*self.options.get().MaxHousekeepingInterval {
+		return fmt.Errorf("last housekeeping of the root container was %v ago (at %v)", since, lastHousekeeping)
+	}
+	return nil
+}
+
 func (m *manager) GetMachineInfo() (*info.MachineInfo, error) {
 	// Copy and return the MachineInfo.
 	return &m.machineInfo, nil
@@ -353,18 +779,51 @@ func (m *manager) GetVersionInfo() (*info.VersionInfo, error) {
 
 // Create a container.
 func (m *manager) createContainer(containerName string) error {
-	handler, err := container.NewContainerHandler(containerName)
+	handler, accept, err := container.NewContainerHandler(containerName)
 	if err != nil {
 		return err
 	}
+	if !accept {
+		// The container is not to be monitored.
+		glog.V(4).Infof("Ignoring container %q", containerName)
+		return nil
+	}
+	// Containers are only evicted when the new one is added, this saves creating containers that
+	// cannot be monitored.
+	m.containersLock.Lock()
+	err = m.checkRoom(containerName)
+	m.containersLock.Unlock()
+	if err != nil {
+		glog.V(3).Infof("Not monitoring container %q: %v", containerName, err)
+		return nil
+	}
 	logUsage := *logCadvisorUsage && containerName == m.cadvisorContainer
-	cont, err := newContainerData(containerName, m.storageDriver, handler, logUsage)
+	cont, err := newContainerData(containerName, m.storageDriver, handler, logUsage, &m.options)
 	if err != nil {
 		return err
 	}
+	if container.HasMetric(container.AppMetrics) {
+		if err := m.registerCollectors(cont); err != nil {
+			glog.Warningf("Failed to register the collectors of %q: %v", containerName, err)
+		}
+		if m.statsdReceiver != nil {
+			if err := cont.collectorManager.RegisterCollector(m.statsdReceiver.Collector(containerName)); err != nil {
+				glog.Warningf("Failed to register the StatsD collector of %q: %v", containerName, err)
+			}
+		}
+	}
+	thresholds, err := alert.GetThresholds(cont.info.Labels)
+	if err != nil {
+		glog.Warningf("Failed to parse the alert thresholds of %q: %v", containerName, err)
+	}
+	thresholds = append(thresholds, m.alertThresholds...)
+	cont.alerts = alert.NewChecker(thresholds)
+	cont.alertWebhook = m.alertWebhook
+	cont.eventHandler = m.eventHandler
+	cont.countOomKills = !m.oomFromKernelLog
 
 	// Add to the containers map.
-	alreadyExists := func() bool {
+	added, err := func() (bool, error) {
 		m.containersLock.Lock()
 		defer m.containersLock.Unlock()
 
@@ -375,11 +834,15 @@ func (m *manager) createContainer(containerName string) error {
 		// Check that the container didn't already exist.
 		_, ok := m.containers[namespacedName]
 		if ok {
-			return true
+			return false, nil
+		}
+		if err := m.makeRoom(containerName); err != nil {
+			return false, err
 		}
 
 		// Add the container name and all its aliases. The aliases must be within the namespace of the factory.
 		m.containers[namespacedName] = cont
+		m.numContainers++
 		for _, alias := range cont.info.Aliases {
 			m.containers[namespacedContainerName{
 				Namespace: cont.info.Namespace,
@@ -387,18 +850,160 @@ func (m *manager) createContainer(containerName string) error {
 			}] = cont
 		}
 
-		return false
+		return true, nil
 	}()
-	if alreadyExists {
+	if !added {
+		if err != nil {
+			glog.V(3).Infof("Not monitoring container %q: %v", containerName, err)
+		}
+		cont.destroy()
 		return nil
 	}
 	glog.Infof("Added container: %q (aliases: %v, namespace: %q)", containerName, cont.info.Aliases, cont.info.Namespace)
+	err = m.eventHandler.AddEvent(&info.Event{
+		ContainerName: containerName,
+		Timestamp:     time.Now(),
+		EventType:     info.EventContainerCreation,
+	})
+	if err != nil {
+		glog.Warningf("Failed to add the creation event of %q: %v", containerName, err)
+	}
+	if container.HasMetric(container.AppMetrics) {
+		m.registerSharedCollectors(cont)
+	}
+	m.addTriggers(cont)
 
 	// Start the container's housekeeping.
+	if m.specWatcher != nil {
+		cont.specWatched = m.specWatcher.watch(cont)
+	}
+	cont.pool = m.housekeepingPool
 	cont.Start()
 	return nil
 }
 
+// Registers the collectors of the custom metrics declared by the labels of the container. Their
+// configuration files are read from the filesystem of the container.
+func (m *manager) registerCollectors(cont *containerData) error {
+	configs := collector.GetCollectorConfigs(cont.info.Labels)
+	if len(configs) == 0 {
+		return nil
+	}
+	pids, err := cont.handler.ListProcesses(container.ListSelf)
+	if err != nil {
+		return err
+	}
+	if len(pids) == 0 {
+		return fmt.Errorf("no process to read the configuration of the collectors from")
+	}
+	metricsCount := 0
+	for name, configFile := range configs {
+		config, err := ioutil.ReadFile(path.Join("/proc", strconv.Itoa(pids[0]), "root", configFile))
+		if err != nil {
+			return fmt.Errorf("failed to read the configuration of collector %q: %v", name, err)
+		}
+		c, err := collector.NewCollectorFromConfig(name, config, cont.handler.GetContainerIPAddress())
+		if err != nil {
+			return err
+		}
+		metricsCount += len(c.GetSpec())
+		if metricsCount > *applicationMetricsCountLimit {
+			return fmt.Errorf("too many custom metrics, the limit is %d", *applicationMetricsCountLimit)
+		}
+		if err := cont.collectorManager.RegisterCollector(c); err != nil {
+			return err
+		}
+		glog.V(1).Infof("Registered collector %q of %q", name, cont.info.Name)
+	}
+	return nil
+}
+
+// Returns the container that sent a packet from addr: the container with that IP address, or the
+// one of the process that sent it for packets of the machine itself, e.g.: from containers using
+// the network of the machine.
+func (m *manager) containerByAddress(addr *net.UDPAddr) (string, error) {
+	if !addr.IP.IsLoopback() {
+		m.containersLock.RLock()
+		defer m.containersLock.RUnlock()
+		for name, cont := range m.containers {
+			if name.Namespace == "" && cont.handler.GetContainerIPAddress() == addr.IP.String() {
+				return name.Name, nil
+			}
+		}
+		return "", fmt.Errorf("no container has the address %v", addr.IP)
+	}
+	pid, err := procfs.FindUdpSocketOwner(addr.Port)
+	if err != nil {
+		return "", err
+	}
+	cgroups, err := procfs.ReadProcessCgroups(pid)
+	if err != nil {
+		return "", err
+	}
+	cgroup, ok := cgroups["cpu"]
+	if !ok {
+		return "", fmt.Errorf("process %d has no cpu cgroup", pid)
+	}
+	name, ok := m.closestContainer(cgroup)
+	if !ok {
+		return "", fmt.Errorf("the cgroup %q of process %d is not monitored", cgroup, pid)
+	}
+	return name, nil
+}
+
+// Returns the monitored container of a cgroup: the container itself or its closest monitored
+// parent.
+func (m *manager) closestContainer(cgroup string) (string, bool) {
+	m.containersLock.RLock()
+	defer m.containersLock.RUnlock()
+	for name := cgroup; ; name = path.Dir(name) {
+		if _, ok := m.containers[namespacedContainerName{Name: name}]; ok {
+			return name, true
+		}
+		if name == "/" || name == "." {
+			return "", false
+		}
+	}
+}
+
+// Records an event for every process killed by the OOM killer, attributed to the container of the
+// process, until asked to quit.
+func (m *manager) watchForOoms(parser *oomparser.OomParser, quit chan error) {
+	ooms := make(chan *oomparser.OomInstance, 10)
+	go parser.StreamOoms(ooms)
+	for {
+		select {
+		case oom := <-ooms:
+			containerName, ok := m.closestContainer(oom.VictimContainerName)
+			if !ok {
+				containerName = "/"
+			}
+			glog.V(1).Infof("Process %d (%s) of %q killed by the OOM killer", oom.Pid, oom.ProcessName, containerName)
+			err := m.eventHandler.AddEvent(&info.Event{
+				ContainerName: containerName,
+				Timestamp:     oom.TimeOfDeath,
+				EventType:     info.EventOom,
+				EventData: info.EventData{
+					Oom: &info.OomEventData{
+						Kills:              1,
+						Pid:                oom.Pid,
+						ProcessName:        oom.ProcessName,
+						LimitContainerName: oom.ContainerName,
+						MemoryUsage:        oom.MemoryUsage,
+					},
+				},
+			})
+			if err != nil {
+				glog.Warningf("Failed to add the OOM event of %q: %v", containerName, err)
+			}
+		case <-quit:
+			// Closing the kernel log stops the parser.
+			quit <- parser.Close()
+			return
+		}
+	}
+}
+
 func (m *manager) destroyContainer(containerName string) error {
 	m.containersLock.Lock()
 	defer m.containersLock.Unlock()
@@ -409,6 +1014,7 @@ func (m *manager) destroyContainer(containerName string) error {
 	cont, ok := m.containers[namespacedName]
 	if !ok {
 		// Already destroyed, done.
+		delete(m.evicted, containerName)
 		return nil
 	}
 
@@ -417,23 +1023,91 @@ func (m *manager) destroyContainer(containerName string) error {
 	if err != nil {
 		return err
 	}
+	m.forgetContainer(cont)
+	glog.Infof("Destroyed container: %q (aliases: %v, namespace: %q)", containerName, cont.info.Aliases, cont.info.Namespace)
+	err = m.eventHandler.AddEvent(&info.Event{
+		ContainerName: containerName,
+		Timestamp:     time.Now(),
+		EventType:     info.EventContainerDeletion,
+	})
+	if err != nil {
+		glog.Warningf("Failed to add the deletion event of %q: %v", containerName, err)
+	}
+	return nil
+}
 
-	// Remove the container from our records (and all its aliases).
-	delete(m.containers, namespacedName)
+// Removes the container from our records (and all its aliases). Must be called with
+// containersLock held.
+func (m *manager) forgetContainer(cont *containerData) {
+	if m.specWatcher != nil {
+		m.specWatcher.unwatch(cont)
+	}
+	delete(m.containers, namespacedContainerName{
+		Name: cont.info.Name,
+	})
 	for _, alias := range cont.info.Aliases {
 		delete(m.containers, namespacedContainerName{
 			Namespace: cont.info.Namespace,
 			Name:      alias,
 		})
 	}
-	glog.Infof("Destroyed container: %q (aliases: %v, namespace: %q)", containerName, cont.info.Aliases, cont.info.Namespace)
+	m.numContainers--
+	container.ForgetDeniedMetrics(cont.info.Name)
+	if m.statsdReceiver != nil {
+		m.statsdReceiver.Forget(cont.info.Name)
+	}
+}
+
+// Delivers the events of the types of --event_sink_types to --event_webhook and --event_exec.
+func (m *manager) startEventSinks() error {
+	var sinks []events.Sink
+	if *eventWebhook != "" {
+		sinks = append(sinks, events.NewWebhookSink(*eventWebhook))
+	}
+	if *eventExec != "" {
+		sinks = append(sinks, events.NewExecSink(*eventExec))
+	}
+	if len(sinks) == 0 {
+		return nil
+	}
+	request := &events.Request{EventType: make(map[info.EventType]bool)}
+	for _, eventType := range strings.Split(*eventSinkTypes, ",") {
+		if eventType = strings.TrimSpace(eventType); eventType != "" {
+			request.EventType[info.EventType(eventType)] = true
+		}
+	}
+	watch, err := m.eventHandler.WatchEvents(request)
+	if err != nil {
+		return err
+	}
+	go events.Notify(watch, sinks, *eventSinkRateLimit)
+	quitSinks := make(chan error)
+	m.quitChannels = append(m.quitChannels, quitSinks)
+	go func() {
+		<-quitSinks
+		m.eventHandler.StopWatch(watch.GetWatchId())
+		quitSinks <- nil
+	}()
 	return nil
 }
 
-// Detect all containers that have been added or deleted from the specified container.
+func (m *manager) GetPastEvents(request *events.Request) ([]*info.Event, error) {
+	return m.eventHandler.GetEvents(request)
+}
+
+func (m *manager) WatchForEvents(request *events.Request) (*events.EventChannel, error) {
+	return m.eventHandler.WatchEvents(request)
+}
+
+func (m *manager) CloseEventChannel(watchId int) {
+	m.eventHandler.StopWatch(watchId)
+}
+
+// Detect all containers that have been added or deleted from the specified container. Evicted
+// containers are not added, and forgotten once they are no longer listed.
 func (m *manager) getContainersDiff(containerName string) (added []info.ContainerReference, removed []info.ContainerReference, err error) {
-	m.containersLock.RLock()
-	defer m.containersLock.RUnlock()
+	m.containersLock.Lock()
+	defer m.containersLock.Unlock()
 
 	// Get all subcontainers recursively.
 	cont, ok := m.containers[namespacedContainerName{
@@ -458,8 +1132,13 @@ func (m *manager) getContainersDiff(containerName string) (added []info.Containe
 	}
 
 	// Added containers
+	stillEvicted := make(map[string]bool, len(m.evicted))
 	for _, c := range allContainers {
 		delete(allContainersSet, c.Name)
+		if m.evicted[c.Name] {
+			stillEvicted[c.Name] = true
+			continue
+		}
 		_, ok := m.containers[namespacedContainerName{
 			Name: c.Name,
 		}]
@@ -467,6 +1146,11 @@ func (m *manager) getContainersDiff(containerName string) (added []info.Containe
 			added = append(added, c)
 		}
 	}
+	for name := range m.evicted {
+		if !stillEvicted[name] && isSubcontainer(name, containerName) {
+			delete(m.evicted, name)
+		}
+	}
 
 	// Removed ones are no longer in the container listing.
 	for _, d := range allContainersSet {
@@ -524,6 +1208,11 @@ func (self *manager) watchForNewContainers(quit chan error) error {
 		return err
 	}
 
+	// Containers reported by their runtime are added without waiting for the detection of their cgroups.
+	if err := container.WatchContainers(events); err != nil {
+		glog.Warningf("Failed to watch for the containers of the runtimes: %v", err)
+	}
+
 	// There is a race between starting the watch and new container creation so we do a detection before we read new containers.
 	err = self.detectSubcontainers("/")
 	if err != nil {
@@ -546,6 +1235,9 @@ func (self *manager) watchForNewContainers(quit chan error) error {
 				}
 			case <-quit:
 				// Stop processing events if asked to quit.
+				if err := container.StopWatchingContainers(); err != nil {
+					glog.Warningf("Failed to stop watching for the containers of the runtimes: %v", err)
+				}
 				err := root.handler.StopWatchingSubcontainers()
 				quit <- err
 				if err == nil {
@@ -17,13 +17,17 @@
 package manager
 
 import (
+	"fmt"
 	"reflect"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/google/cadvisor/alert"
+	"github.com/google/cadvisor/collector"
 	"github.com/google/cadvisor/container"
 	"github.com/google/cadvisor/container/docker"
+	"github.com/google/cadvisor/events"
 	"github.com/google/cadvisor/info"
 	itest "github.com/google/cadvisor/info/test"
 	stest "github.com/google/cadvisor/storage/test"
@@ -43,14 +47,17 @@ func createManagerAndAddContainers(
 		driver = &stest.MockStorageDriver{}
 	}
 	container.ClearContainerHandlerFactories()
-	mif, err := New(driver, sysfs)
+	options := DefaultOptions()
+	options.StorageDriver = driver
+	options.SysFs = sysfs
+	mif, err := New(options)
 	if err != nil {
 		t.Fatal(err)
 	}
 	if ret, ok := mif.(*manager); ok {
 		for _, name := range containers {
 			mockHandler := container.NewMockContainerHandler(name)
-			cont, err := newContainerData(name, driver, mockHandler, false)
+			cont, err := newContainerData(name, driver, mockHandler, false, &ret.options)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -150,6 +157,26 @@ func TestGetContainerInfo(t *testing.T) {
 
 }
 
+func TestThinStats(t *testing.T) {
+	start := time.Unix(1000, 0)
+	stats := make([]*info.ContainerStats, 10)
+	for i := range stats {
+		stats[i] = &info.ContainerStats{Timestamp: start.Add(time.Duration(i) * time.Second)}
+	}
+
+	if thinned := thinStats(stats, 0); len(thinned) != len(stats) {
+		t.Errorf("expected all %d stats without a resolution, got %d", len(stats), len(thinned))
+	}
+	thinned := thinStats(stats, 3*time.Second)
+	expected := []*info.ContainerStats{stats[0], stats[3], stats[6], stats[9]}
+	if !reflect.DeepEqual(thinned, expected) {
+		t.Errorf("expected stats %+v, got %+v", expected, thinned)
+	}
+	if thinned := thinStats(stats, time.Minute); len(thinned) != 1 || thinned[0] != stats[9] {
+		t.Errorf("expected only the latest stats, got %+v", thinned)
+	}
+}
+
 func TestSubcontainersInfo(t *testing.T) {
 	containers := []string{
 		"/c1",
@@ -204,7 +231,10 @@ func TestDockerContainersInfo(t *testing.T) {
 }
 
 func TestNew(t *testing.T) {
-	manager, err := New(&stest.MockStorageDriver{}, &fakesysfs.FakeSysFs{})
+	options := DefaultOptions()
+	options.StorageDriver = &stest.MockStorageDriver{}
+	options.SysFs = &fakesysfs.FakeSysFs{}
+	manager, err := New(options)
 	if err != nil {
 		t.Fatalf("Expected manager.New to succeed: %s", err)
 	}
@@ -213,9 +243,181 @@ func TestNew(t *testing.T) {
 	}
 }
 
+func TestNewRegistersFactories(t *testing.T) {
+	var registered []string
+	options := DefaultOptions()
+	options.StorageDriver = &stest.MockStorageDriver{}
+	options.SysFs = &fakesysfs.FakeSysFs{}
+	options.Factories = []FactoryRegisterFunc{
+		func(info.MachineInfoFactory) error {
+			return fmt.Errorf("runtime not running")
+		},
+		func(f info.MachineInfoFactory) error {
+			if _, err := f.GetMachineInfo(); err != nil {
+				return err
+			}
+			registered = append(registered, "raw")
+			return nil
+		},
+	}
+	if _, err := New(options); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(registered, []string{"raw"}) {
+		t.Errorf("expected the raw factory to be registered, got %v", registered)
+	}
+
+	options.HousekeepingInterval = 0
+	if _, err := New(options); err == nil {
+		t.Error("expected a zero housekeeping interval to be rejected")
+	}
+}
+
+func TestUpdateOptions(t *testing.T) {
+	defer container.SetMetrics(container.Metrics())
+	options := DefaultOptions()
+	options.StorageDriver = &stest.MockStorageDriver{}
+	options.SysFs = &fakesysfs.FakeSysFs{}
+	m, err := New(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updated := DefaultOptions()
+	updated.Metrics = container.MetricSet{container.NetworkUsageMetrics: struct{}{}}
+	updated.HousekeepingInterval = 2 * time.Second
+	updated.HousekeepingWorkers = 42
+	if err := m.UpdateOptions(updated); err != nil {
+		t.Fatal(err)
+	}
+	current := m.(*manager).options.get()
+	if current.HousekeepingInterval != 2*time.Second {
+		t.Errorf("expected a housekeeping interval of 2s, got %v", current.HousekeepingInterval)
+	}
+	if current.HousekeepingWorkers != options.HousekeepingWorkers {
+		t.Errorf("expected the number of workers not to be updated, got %d", current.HousekeepingWorkers)
+	}
+	if current.StorageDriver != options.StorageDriver {
+		t.Error("expected the storage driver not to be updated")
+	}
+	if !container.HasMetric(container.NetworkUsageMetrics) || container.HasMetric(container.PerCpuUsageMetrics) {
+		t.Errorf("expected only the network metrics to be collected, got %v", container.Metrics())
+	}
+	if interval := m.(*manager).snapshotter.interval; interval != 2*time.Second {
+		t.Errorf("expected snapshots to be reused for 2s, got %v", interval)
+	}
+
+	updated.HousekeepingInterval = 0
+	if err := m.UpdateOptions(updated); err == nil {
+		t.Error("expected a zero housekeeping interval to be rejected")
+	}
+	if interval := m.(*manager).options.get().HousekeepingInterval; interval != 2*time.Second {
+		t.Errorf("expected the rejected options not to be applied, got a housekeeping interval of %v", interval)
+	}
+}
+
 func TestNewNilManager(t *testing.T) {
-	_, err := New(nil, nil)
+	_, err := New(DefaultOptions())
 	if err == nil {
 		t.Fatalf("Expected nil manager to return error")
 	}
 }
+
+func TestSetCollectorConfig(t *testing.T) {
+	m := createManagerAndAddContainers(nil, &fakesysfs.FakeSysFs{}, []string{"/docker/web", "/docker/db"}, func(h *container.MockContainerHandler) {
+		h.On("GetContainerIPAddress").Return("10.0.0.2")
+	}, t)
+	web := m.containers[namespacedContainerName{Name: "/docker/web"}]
+	db := m.containers[namespacedContainerName{Name: "/docker/db"}]
+	web.info.Labels = map[string]string{"app": "web"}
+	db.info.Labels = map[string]string{"app": "db"}
+
+	config := &collector.SharedConfig{
+		Name:     "status",
+		Selector: map[string]string{"app": "web"},
+		Config:   []byte(`{"endpoint": "http://localhost:8000/status", "metrics_config": [{"name": "connections", "metric_type": "gauge", "data_type": "int", "path": "connections"}]}`),
+	}
+	if err := m.SetCollectorConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	if !web.sharedCollectors["status"] || db.sharedCollectors["status"] {
+		t.Errorf("expected the collector to be registered for web only, got %v and %v", web.sharedCollectors, db.sharedCollectors)
+	}
+	if configs := m.GetCollectorConfigs(); len(configs) != 1 || configs[0].Name != "status" {
+		t.Errorf("unexpected configs %+v", configs)
+	}
+
+	// Changing the selector moves the collector.
+	config = &collector.SharedConfig{Name: config.Name, Selector: map[string]string{"app": "*"}, Config: config.Config}
+	if err := m.SetCollectorConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	if !web.sharedCollectors["status"] || !db.sharedCollectors["status"] {
+		t.Errorf("expected the collector to be registered for both, got %v and %v", web.sharedCollectors, db.sharedCollectors)
+	}
+
+	if err := m.RemoveCollectorConfig("status"); err != nil {
+		t.Fatal(err)
+	}
+	if web.sharedCollectors["status"] || db.sharedCollectors["status"] {
+		t.Errorf("expected the collector to be unregistered, got %v and %v", web.sharedCollectors, db.sharedCollectors)
+	}
+	if spec, _ := web.collectorManager.GetSpec(); len(spec) != 0 {
+		t.Errorf("expected no custom metrics, got %+v", spec)
+	}
+	if err := m.RemoveCollectorConfig("status"); err == nil {
+		t.Error("expected an error removing an unknown collector")
+	}
+	if err := m.SetCollectorConfig(&collector.SharedConfig{Name: "status", Config: config.Config}); err == nil {
+		t.Error("expected an error setting a collector without selector")
+	}
+}
+
+func TestSetTrigger(t *testing.T) {
+	m := createManagerAndAddContainers(nil, &fakesysfs.FakeSysFs{}, []string{"/docker/a", "/other"}, func(h *container.MockContainerHandler) {}, t)
+	a := m.containers[namespacedContainerName{Name: "/docker/a"}]
+	other := m.containers[namespacedContainerName{Name: "/other"}]
+	a.eventHandler = m.eventHandler
+
+	trigger := &alert.Trigger{
+		Name:                 "big",
+		Expression:           "memory.usage > 10",
+		ContainerName:        "/docker",
+		IncludeSubcontainers: true,
+	}
+	if err := m.SetTrigger(trigger); err != nil {
+		t.Fatal(err)
+	}
+	if !a.triggers["big"] || other.triggers["big"] {
+		t.Errorf("expected the threshold to be added to /docker/a only, got %v and %v", a.triggers, other.triggers)
+	}
+	if triggers := m.GetTriggers(); len(triggers) != 1 || triggers[0].Name != "big" {
+		t.Errorf("unexpected thresholds %+v", triggers)
+	}
+
+	// Crossing the threshold records an event with the sample.
+	stats := &info.ContainerStats{Timestamp: time.Now()}
+	stats.Memory.Usage = 20
+	a.checkAlerts(stats)
+	thresholdEvents, err := m.GetPastEvents(&events.Request{EventType: map[info.EventType]bool{info.EventThreshold: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(thresholdEvents) != 1 || thresholdEvents[0].ContainerName != "/docker/a" {
+		t.Fatalf("expected a threshold event of /docker/a, got %+v", thresholdEvents)
+	}
+	data := thresholdEvents[0].EventData.Threshold
+	if data == nil || data.Name != "big" || !data.Firing || data.Value != 20 || data.Stats != stats {
+		t.Errorf("unexpected threshold event %+v", data)
+	}
+
+	if err := m.RemoveTrigger("big"); err != nil {
+		t.Fatal(err)
+	}
+	if a.triggers["big"] || a.alerts.Remove("big") {
+		t.Errorf("expected the threshold to be removed from /docker/a")
+	}
+	if err := m.SetTrigger(&alert.Trigger{Name: "invalid", Expression: "memory.usage >", ContainerName: "/"}); err == nil {
+		t.Error("expected an error setting an invalid threshold")
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/cadvisor/container"
+	"github.com/google/cadvisor/info"
+	"github.com/google/cadvisor/storage"
+	"github.com/google/cadvisor/utils/sysfs"
+)
+
+// Registers a container handler factory with the manager, e.g. raw.Register.
+type FactoryRegisterFunc func(info.MachineInfoFactory) error
+
+// Options of a manager. Programs embedding cAdvisor without its HTTP server start from
+// DefaultOptions, those of the flags, and set at least the storage driver. The other settings of
+// cAdvisor are still read from its flags.
+type Options struct {
+	// Stores the stats of the containers, e.g. an in-memory storage. Required.
+	StorageDriver storage.StorageDriver
+	// Reads the hardware of the machine, its sysfs if nil.
+	SysFs sysfs.SysFs
+
+	// Kinds of metrics collected. They apply to the whole process.
+	Metrics container.MetricSet
+
+	// Interval between the housekeepings of containers, which collect their stats.
+	HousekeepingInterval time.Duration
+	// Whether the housekeeping interval of containers whose stats do not change grows, up to
+	// MaxHousekeepingInterval.
+	AllowDynamicHousekeeping bool
+	MaxHousekeepingInterval  time.Duration
+	// Interval between the global housekeepings, which look for missed containers.
+	GlobalHousekeepingInterval time.Duration
+	// Number of goroutines doing the housekeeping of containers, twice the number of cores if 0.
+	HousekeepingWorkers int
+	// Time after which the housekeeping of a container is considered stuck.
+	HousekeepingDeadline time.Duration
+
+	// Maximum number of containers monitored, no limit if 0. Beyond it, containers are evicted
+	// following EvictionPolicy, e.g. EvictLeastActiveRaw.
+	MaxContainers  int
+	EvictionPolicy string
+
+	// Register the container handler factories when the manager is created, in order. Factories
+	// failing to register, e.g. when their runtime is not running, are skipped. The raw factory,
+	// accepting any cgroup, comes last.
+	Factories []FactoryRegisterFunc
+}
+
+// Returns the options of the flags, without a storage driver.
+func DefaultOptions() Options {
+	return Options{
+		Metrics:                    container.Metrics(),
+		HousekeepingInterval:       *HousekeepingInterval,
+		AllowDynamicHousekeeping:   *allowDynamicHousekeeping,
+		MaxHousekeepingInterval:    *maxHousekeepingInterval,
+		GlobalHousekeepingInterval: *globalHousekeepingInterval,
+		HousekeepingWorkers:        *housekeepingWorkers,
+		HousekeepingDeadline:       *housekeepingDeadline,
+		MaxContainers:              *maxContainers,
+		EvictionPolicy:             *containerEvictionPolicy,
+	}
+}
+
+// Options of a running manager, of which UpdateOptions replaces some.
+type currentOptions struct {
+	lock    sync.RWMutex
+	options Options
+}
+
+func (self *currentOptions) get() Options {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	return self.options
+}
+
+func (self *Options) validate() error {
+	if self.StorageDriver == nil {
+		return fmt.Errorf("nil storage driver!")
+	}
+	if self.HousekeepingInterval <= 0 {
+		return fmt.Errorf("housekeeping interval must be positive, got %v", self.HousekeepingInterval)
+	}
+	if self.MaxHousekeepingInterval < self.HousekeepingInterval {
+		return fmt.Errorf("max housekeeping interval %v is shorter than the housekeeping interval %v", self.MaxHousekeepingInterval, self.HousekeepingInterval)
+	}
+	if self.GlobalHousekeepingInterval <= 0 {
+		return fmt.Errorf("global housekeeping interval must be positive, got %v", self.GlobalHousekeepingInterval)
+	}
+	if self.HousekeepingDeadline <= 0 {
+		return fmt.Errorf("housekeeping deadline must be positive, got %v", self.HousekeepingDeadline)
+	}
+	if self.MaxContainers < 0 {
+		return fmt.Errorf("maximum number of containers must not be negative, got %d", self.MaxContainers)
+	}
+	if !evictionPolicies[self.EvictionPolicy] {
+		return fmt.Errorf("unknown eviction policy %q", self.EvictionPolicy)
+	}
+	return nil
+}
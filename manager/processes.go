@@ -0,0 +1,82 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/cadvisor/info"
+	"github.com/google/cadvisor/utils/procfs"
+)
+
+// Builds the process information for the specified pid. uptime is the time since boot and
+// users caches the names of already seen user IDs.
+func getProcessInfo(pid int, uptime time.Duration, users map[int]string) (*info.ProcessInfo, error) {
+	stat, err := procfs.ReadProcessStat(pid)
+	if err != nil {
+		return nil, err
+	}
+	uid, err := procfs.ReadProcessUid(pid)
+	if err != nil {
+		return nil, err
+	}
+	cmdline, err := procfs.ReadProcessCmdline(pid)
+	if err != nil {
+		return nil, err
+	}
+	cgroups, err := procfs.ReadProcessCgroups(pid)
+	if err != nil {
+		return nil, err
+	}
+
+	userName, ok := users[uid]
+	if !ok {
+		userName = strconv.Itoa(uid)
+		if u, err := user.LookupId(userName); err == nil {
+			userName = u.Username
+		}
+		users[uid] = userName
+	}
+
+	// Kernel threads have no command line, show their name like ps does.
+	cmd := "[" + stat.Command + "]"
+	if len(cmdline) > 0 {
+		cmd = strings.Join(cmdline, " ")
+	}
+
+	cpuTime := procfs.JiffiesToDuration(stat.UserTime + stat.SystemTime)
+	var percentCpu float32
+	running := uptime - procfs.JiffiesToDuration(stat.StartTime)
+	if running > 0 {
+		percentCpu = float32(float64(cpuTime) / float64(running) * 100)
+	}
+
+	return &info.ProcessInfo{
+		Pid:         pid,
+		ParentPid:   stat.ParentPid,
+		User:        userName,
+		Status:      stat.State,
+		PercentCpu:  percentCpu,
+		CpuTime:     cpuTime,
+		Rss:         stat.Rss * uint64(os.Getpagesize()),
+		VirtualSize: stat.VirtualSize,
+		Cmd:         cmd,
+		CgroupPath:  cgroups["cpu"],
+	}, nil
+}
@@ -0,0 +1,162 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/golang/glog"
+	"github.com/google/cadvisor/collector"
+)
+
+var collectorConfigDir = flag.String("collector_config_dir", "", "Directory of collector configurations shared by the containers matching their selector, reloaded when its files change. Disabled if empty")
+
+// Returns the collector configs shared by containers, sorted by name.
+func (m *manager) GetCollectorConfigs() []collector.SharedConfig {
+	m.sharedCollectorsLock.Lock()
+	defer m.sharedCollectorsLock.Unlock()
+	configs := make([]collector.SharedConfig, 0, len(m.sharedCollectors))
+	for _, config := range m.sharedCollectors {
+		configs = append(configs, *config)
+	}
+	sort.Sort(byCollectorName(configs))
+	return configs
+}
+
+type byCollectorName []collector.SharedConfig
+
+func (s byCollectorName) Len() int           { return len(s) }
+func (s byCollectorName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byCollectorName) Less(i, j int) bool { return s[i].Name < s[j].Name }
+
+// Adds or replaces a collector config shared by containers, registering its collector for the
+// containers matching its selector and unregistering it from those that no longer match.
+func (m *manager) SetCollectorConfig(config *collector.SharedConfig) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	m.sharedCollectorsLock.Lock()
+	defer m.sharedCollectorsLock.Unlock()
+	m.sharedCollectors[config.Name] = config
+	for _, cont := range m.allContainerData() {
+		m.applySharedCollector(cont, config.Name)
+	}
+	glog.Infof("Set shared collector %q", config.Name)
+	return nil
+}
+
+// Removes a collector config shared by containers and unregisters its collector.
+func (m *manager) RemoveCollectorConfig(name string) error {
+	m.sharedCollectorsLock.Lock()
+	defer m.sharedCollectorsLock.Unlock()
+	if _, ok := m.sharedCollectors[name]; !ok {
+		return fmt.Errorf("unknown collector %q", name)
+	}
+	delete(m.sharedCollectors, name)
+	for _, cont := range m.allContainerData() {
+		m.applySharedCollector(cont, name)
+	}
+	glog.Infof("Removed shared collector %q", name)
+	return nil
+}
+
+// Returns each container once, the containers map holding them under their aliases too.
+func (m *manager) allContainerData() []*containerData {
+	m.containersLock.RLock()
+	defer m.containersLock.RUnlock()
+	seen := make(map[*containerData]bool, len(m.containers))
+	containers := make([]*containerData, 0, len(m.containers))
+	for _, cont := range m.containers {
+		if !seen[cont] {
+			seen[cont] = true
+			containers = append(containers, cont)
+		}
+	}
+	return containers
+}
+
+// Registers the collectors of the shared configs matching a new container.
+func (m *manager) registerSharedCollectors(cont *containerData) {
+	m.sharedCollectorsLock.Lock()
+	defer m.sharedCollectorsLock.Unlock()
+	for name := range m.sharedCollectors {
+		m.applySharedCollector(cont, name)
+	}
+}
+
+// Brings the collector of the named shared config of a container up to date: unregisters the
+// previous one, and registers the current one if the config exists and matches the container.
+// Collectors the container declares itself take precedence. Must hold sharedCollectorsLock.
+func (m *manager) applySharedCollector(cont *containerData, name string) {
+	cont.lock.Lock()
+	defer cont.lock.Unlock()
+	if cont.sharedCollectors[name] {
+		cont.collectorManager.UnregisterCollector(name)
+		delete(cont.sharedCollectors, name)
+	}
+	config, ok := m.sharedCollectors[name]
+	if !ok || !config.Matches(cont.info.Labels) {
+		return
+	}
+	c, err := collector.NewCollectorFromConfig(name, config.Config, cont.handler.GetContainerIPAddress())
+	if err != nil {
+		glog.Warningf("Failed to create the shared collector %q of %q: %v", name, cont.info.Name, err)
+		return
+	}
+	if err := cont.collectorManager.RegisterCollector(c); err != nil {
+		glog.Warningf("Failed to register the shared collector %q of %q: %v", name, cont.info.Name, err)
+		return
+	}
+	spec, err := cont.collectorManager.GetSpec()
+	if err == nil && len(spec) > *applicationMetricsCountLimit {
+		cont.collectorManager.UnregisterCollector(name)
+		glog.Warningf("Not registering the shared collector %q of %q, the limit of %d custom metrics would be exceeded", name, cont.info.Name, *applicationMetricsCountLimit)
+		return
+	}
+	if cont.sharedCollectors == nil {
+		cont.sharedCollectors = make(map[string]bool)
+	}
+	cont.sharedCollectors[name] = true
+	glog.V(1).Infof("Registered shared collector %q of %q", name, cont.info.Name)
+}
+
+// Applies the shared configs of --collector_config_dir that changed since the previous load, whose
+// configs are loaded, and removes those whose file was deleted. Returns the configs now loaded.
+func (m *manager) loadCollectorConfigDir(loaded map[string]*collector.SharedConfig) (map[string]*collector.SharedConfig, error) {
+	configs, err := collector.ReadSharedConfigs(*collectorConfigDir)
+	if err != nil {
+		// Keep the previous configs until the directory is valid again.
+		return loaded, err
+	}
+	for name, config := range configs {
+		if previous, ok := loaded[name]; ok && reflect.DeepEqual(previous, config) {
+			continue
+		}
+		if err := m.SetCollectorConfig(config); err != nil {
+			glog.Warningf("Failed to set the shared collector %q: %v", name, err)
+		}
+	}
+	for name := range loaded {
+		if _, ok := configs[name]; !ok {
+			if err := m.RemoveCollectorConfig(name); err != nil {
+				glog.Warningf("Failed to remove the shared collector %q: %v", name, err)
+			}
+		}
+	}
+	return configs, nil
+}
@@ -0,0 +1,59 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package manager
+
+import (
+	"code.google.com/p/go.exp/inotify"
+	"github.com/golang/glog"
+)
+
+// Loads the shared configs of --collector_config_dir and reloads them when its files change, until
+// asked to quit.
+func (m *manager) watchCollectorConfigDir(quit chan error) error {
+	watcher, err := inotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	err = watcher.AddWatch(*collectorConfigDir, inotify.IN_CLOSE_WRITE|inotify.IN_DELETE|inotify.IN_MOVE)
+	if err != nil {
+		watcher.Close()
+		return err
+	}
+	loaded, err := m.loadCollectorConfigDir(nil)
+	if err != nil {
+		watcher.Close()
+		return err
+	}
+	go func() {
+		for {
+			select {
+			case <-watcher.Event:
+				loaded, err = m.loadCollectorConfigDir(loaded)
+				if err != nil {
+					glog.Warningf("Failed to reload the collector configurations of %q: %v", *collectorConfigDir, err)
+				}
+			case err := <-watcher.Error:
+				glog.Warningf("Error while watching %q: %v", *collectorConfigDir, err)
+			case <-quit:
+				quit <- watcher.Close()
+				return
+			}
+		}
+	}()
+	return nil
+}
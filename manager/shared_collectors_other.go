@@ -0,0 +1,53 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !linux
+// +build !linux
+
+package manager
+
+import (
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// Interval between the reads of --collector_config_dir where its changes are not notified.
+const collectorConfigDirInterval = 30 * time.Second
+
+// Loads the shared configs of --collector_config_dir and reloads them every
+// collectorConfigDirInterval, until asked to quit.
+func (m *manager) watchCollectorConfigDir(quit chan error) error {
+	loaded, err := m.loadCollectorConfigDir(nil)
+	if err != nil {
+		return err
+	}
+	go func() {
+		ticker := time.NewTicker(collectorConfigDirInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				loaded, err = m.loadCollectorConfigDir(loaded)
+				if err != nil {
+					glog.Warningf("Failed to reload the collector configurations of %q: %v", *collectorConfigDir, err)
+				}
+			case <-quit:
+				quit <- nil
+				return
+			}
+		}
+	}()
+	return nil
+}
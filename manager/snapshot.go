@@ -0,0 +1,88 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/cadvisor/info"
+)
+
+// Takes snapshots of the stats of all containers.
+type snapshotter struct {
+	lock sync.Mutex
+
+	// Last snapshot taken, nil if there is none.
+	last *info.Snapshot
+	// When the last snapshot was taken.
+	taken time.Time
+	// How long snapshots are reused.
+	interval time.Duration
+}
+
+func (self *snapshotter) setInterval(interval time.Duration) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.interval = interval
+}
+
+// Returns a snapshot of the stats of the specified containers. Snapshots are reused for the
+// housekeeping interval so that concurrent users get the same view.
+func (self *snapshotter) snapshot(containers []*containerData) *info.Snapshot {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	var sequence uint64
+	if self.last != nil {
+		if time.Since(self.taken) < self.interval {
+			return self.last
+		}
+		sequence = self.last.Sequence
+	}
+
+	// Use the stats of the last housekeeping of all containers, which are done once per
+	// housekeeping interval, rather than reading the cgroups of all containers at once. Those
+	// include the stats only collected by the housekeeping, e.g. custom metrics and processes.
+	self.taken = time.Now()
+	snapshot := &info.Snapshot{
+		Sequence:  sequence + 1,
+		Timestamp: self.taken,
+		Stats:     make(map[string]*info.ContainerStats, len(containers)),
+	}
+	var oldest, newest time.Time
+	for _, cont := range containers {
+		stats, err := cont.storageDriver.RecentStats(cont.info.Name, 1)
+		if err != nil || len(stats) == 0 {
+			// Not housekept yet.
+			glog.V(3).Infof("No stats of container %q for snapshot: %v", cont.info.Name, err)
+			continue
+		}
+		snapshot.Stats[cont.info.Name] = stats[0]
+		if oldest.IsZero() || stats[0].Timestamp.Before(oldest) {
+			oldest = stats[0].Timestamp
+		}
+		if stats[0].Timestamp.After(newest) {
+			newest = stats[0].Timestamp
+		}
+	}
+	if !oldest.IsZero() {
+		snapshot.Timestamp = oldest
+		snapshot.Duration = newest.Sub(oldest)
+	}
+
+	self.last = snapshot
+	return snapshot
+}
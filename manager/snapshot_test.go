@@ -0,0 +1,60 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/info"
+	itest "github.com/google/cadvisor/info/test"
+)
+
+func TestSnapshot(t *testing.T) {
+	stats := itest.GenerateRandomStats(2, 4, 1*time.Second)
+	cd, mockHandler, mockDriver := newTestContainerData(t)
+	cd.info.Name = containerName
+	// The latest stats housekept, the cgroups are not read.
+	mockDriver.On("RecentStats", containerName, 1).Return(stats[1:], nil)
+	notHousekept, _, notHousekeptDriver := newTestContainerData(t)
+	notHousekept.info.Name = "/not_housekept"
+	notHousekeptDriver.On("RecentStats", "/not_housekept", 1).Return([]*info.ContainerStats{}, nil)
+	containers := []*containerData{cd, notHousekept}
+
+	s := snapshotter{interval: *HousekeepingInterval}
+	snapshot := s.snapshot(containers)
+	if snapshot.Sequence != 1 {
+		t.Errorf("expected first snapshot to have sequence 1, got %d", snapshot.Sequence)
+	}
+	if len(snapshot.Stats) != 1 || snapshot.Stats[containerName] != stats[1] {
+		t.Errorf("unexpected stats in snapshot: %+v", snapshot.Stats)
+	}
+	if !snapshot.Timestamp.Equal(stats[1].Timestamp) || snapshot.Duration != 0 {
+		t.Errorf("expected the snapshot to start at %v and last 0, got %v and %v", stats[1].Timestamp, snapshot.Timestamp, snapshot.Duration)
+	}
+
+	// Snapshots taken within the housekeeping interval are the same.
+	if again := s.snapshot(containers); again != snapshot {
+		t.Errorf("expected snapshot to be reused, got sequence %d", again.Sequence)
+	}
+
+	// Older snapshots are replaced.
+	s.taken = s.taken.Add(-2 * *HousekeepingInterval)
+	if next := s.snapshot(containers); next.Sequence != 2 {
+		t.Errorf("expected a new snapshot with sequence 2, got %d", next.Sequence)
+	}
+	mockHandler.AssertExpectations(t)
+	mockDriver.AssertExpectations(t)
+}
@@ -0,0 +1,130 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package manager
+
+import (
+	"path"
+	"sync"
+
+	"code.google.com/p/go.exp/inotify"
+	"github.com/golang/glog"
+)
+
+// Subsystems of the cgroup files the spec of containers is read from.
+var specSubsystems = []string{"cpu", "cpuset", "memory", "blkio", "pids"}
+
+// Watches the cgroup directories of containers for writes, e.g. of their limits, which change
+// their spec.
+type specWatcher struct {
+	watcher *inotify.Watcher
+	lock    sync.Mutex
+	// Containers by cgroup directory.
+	dirs map[string]*containerData
+}
+
+func newSpecWatcher() (*specWatcher, error) {
+	watcher, err := inotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	return &specWatcher{
+		watcher: watcher,
+		dirs:    make(map[string]*containerData),
+	}, nil
+}
+
+// Watches the cgroup directories of a container. Returns whether they are all watched, when the
+// spec of the container is only updated once they are written to.
+func (self *specWatcher) watch(cont *containerData) bool {
+	var dirs []string
+	seen := make(map[string]bool, len(specSubsystems))
+	for _, subsystem := range specSubsystems {
+		// The subsystems share a single directory with cgroup v2.
+		dir, err := cont.handler.GetCgroupPath(subsystem)
+		if err == nil && dir != "" && !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	if len(dirs) == 0 {
+		return false
+	}
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	for i, dir := range dirs {
+		if err := self.watcher.AddWatch(dir, inotify.IN_MODIFY); err != nil {
+			glog.V(2).Infof("Updating the spec of %q every %v, failed to watch %q: %v", cont.info.Name, specUpdateInterval, dir, err)
+			self.remove(cont, dirs[:i])
+			return false
+		}
+		self.dirs[dir] = cont
+	}
+	return true
+}
+
+// Stops watching the cgroup directories of a container.
+func (self *specWatcher) unwatch(cont *containerData) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	var dirs []string
+	for dir, c := range self.dirs {
+		if c == cont {
+			dirs = append(dirs, dir)
+		}
+	}
+	self.remove(cont, dirs)
+}
+
+// Removes the watches of the directories of a container, must hold the lock.
+func (self *specWatcher) remove(cont *containerData, dirs []string) {
+	for _, dir := range dirs {
+		if self.dirs[dir] != cont {
+			continue
+		}
+		delete(self.dirs, dir)
+		// The watch is already gone if the cgroup was removed.
+		self.watcher.RemoveWatch(dir)
+	}
+}
+
+// Tells the containers their spec changed when their cgroup files are written to, until asked to
+// quit.
+func (self *specWatcher) Start(quit chan error) {
+	go func() {
+		for {
+			select {
+			case event := <-self.watcher.Event:
+				if event.Mask&inotify.IN_MODIFY == 0 {
+					continue
+				}
+				self.lock.Lock()
+				cont, ok := self.dirs[path.Dir(event.Name)]
+				self.lock.Unlock()
+				if ok {
+					cont.markSpecChanged()
+				}
+			case err := <-self.watcher.Error:
+				glog.Warningf("Error while watching the cgroups of containers: %v", err)
+			case <-quit:
+				quit <- self.watcher.Close()
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,38 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !linux
+// +build !linux
+
+package manager
+
+import "fmt"
+
+// The cgroups of containers are only watched on Linux, the spec of containers is updated every
+// specUpdateInterval otherwise.
+type specWatcher struct{}
+
+func newSpecWatcher() (*specWatcher, error) {
+	return nil, fmt.Errorf("watching the cgroups of containers is only supported on Linux")
+}
+
+func (self *specWatcher) watch(cont *containerData) bool {
+	return false
+}
+
+func (self *specWatcher) unwatch(cont *containerData) {
+}
+
+func (self *specWatcher) Start(quit chan error) {
+}
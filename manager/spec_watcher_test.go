@@ -0,0 +1,78 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package manager
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestSpecWatcher(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spec_watcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	limit := path.Join(dir, "memory.limit_in_bytes")
+	if err := ioutil.WriteFile(limit, []byte("1048576\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	watcher, err := newSpecWatcher()
+	if err != nil {
+		t.Skipf("inotify is not available: %v", err)
+	}
+	quit := make(chan error)
+	watcher.Start(quit)
+	defer func() {
+		quit <- nil
+		if err := <-quit; err != nil {
+			t.Error(err)
+		}
+	}()
+
+	cd, mockHandler, _ := newTestContainerData(t)
+	for _, subsystem := range specSubsystems {
+		if subsystem == "memory" {
+			mockHandler.On("GetCgroupPath", subsystem).Return(dir, nil)
+		} else {
+			mockHandler.On("GetCgroupPath", subsystem).Return("", fmt.Errorf("no %s cgroup", subsystem))
+		}
+	}
+	if !watcher.watch(cd) {
+		t.Fatalf("cgroups of %q not watched", cd.info.Name)
+	}
+
+	// Writing a limit changes the spec.
+	if err := ioutil.WriteFile(limit, []byte("2097152\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the spec to change", func() bool {
+		cd.lock.Lock()
+		defer cd.lock.Unlock()
+		return cd.specChanged
+	})
+
+	watcher.unwatch(cd)
+	if len(watcher.dirs) != 0 {
+		t.Errorf("expected no watched directories, got %v", watcher.dirs)
+	}
+}
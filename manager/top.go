@@ -0,0 +1,92 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"flag"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/google/cadvisor/info"
+	"github.com/google/cadvisor/utils/procfs"
+)
+
+var topProcesses = flag.Int("top_processes", 0, "Number of processes using the most CPU and memory to report in the stats of each container, reading the stats of all their processes at every housekeeping. 0 disables")
+
+// Identifies a process across samples, pids being reused.
+type processKey struct {
+	pid       int
+	startTime uint64
+}
+
+// Tracks the CPU time of the processes of a container between samples, to find the processes
+// using the most CPU since the last sample rather than since they started.
+type processTracker struct {
+	cpuTimes map[processKey]time.Duration
+	last     time.Time
+}
+
+// Reports the n processes using the most CPU and the n using the most memory in the stats,
+// ignoring the ones that exited meanwhile.
+func (self *processTracker) add(stats *info.ContainerStats, pids []int, n int) {
+	cpuTimes := make(map[processKey]time.Duration, len(pids))
+	elapsed := stats.Timestamp.Sub(self.last)
+	processes := make([]info.TopProcess, 0, len(pids))
+	for _, pid := range pids {
+		stat, err := procfs.ReadProcessStat(pid)
+		if err != nil {
+			continue
+		}
+		key := processKey{pid, stat.StartTime}
+		cpuTime := procfs.JiffiesToDuration(stat.UserTime + stat.SystemTime)
+		cpuTimes[key] = cpuTime
+		process := info.TopProcess{
+			Pid:     pid,
+			Command: stat.Command,
+			Rss:     stat.Rss * uint64(os.Getpagesize()),
+		}
+		// Processes that started since the last sample have used all of their CPU time since then.
+		if last, ok := self.cpuTimes[key]; ok {
+			cpuTime -= last
+		}
+		if !self.last.IsZero() && elapsed > 0 {
+			process.PercentCpu = float32(float64(cpuTime) / float64(elapsed) * 100)
+		}
+		processes = append(processes, process)
+	}
+	self.cpuTimes = cpuTimes
+	self.last = stats.Timestamp
+
+	if n > len(processes) {
+		n = len(processes)
+	}
+	sort.Sort(byCpu(processes))
+	stats.TopCpuProcesses = append([]info.TopProcess(nil), processes[:n]...)
+	sort.Sort(byRss(processes))
+	stats.TopMemoryProcesses = append([]info.TopProcess(nil), processes[:n]...)
+}
+
+type byCpu []info.TopProcess
+
+func (s byCpu) Len() int           { return len(s) }
+func (s byCpu) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byCpu) Less(i, j int) bool { return s[i].PercentCpu > s[j].PercentCpu }
+
+type byRss []info.TopProcess
+
+func (s byRss) Len() int           { return len(s) }
+func (s byRss) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byRss) Less(i, j int) bool { return s[i].Rss > s[j].Rss }
@@ -0,0 +1,75 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"code.google.com/p/gomock/gomock"
+	"github.com/google/cadvisor/info"
+	"github.com/google/cadvisor/utils/fs"
+	"github.com/google/cadvisor/utils/fs/mockfs"
+	"github.com/google/cadvisor/utils/procfs"
+)
+
+// Serves the stat of processes with their CPU time and RSS (in pages), the ones that exited have none.
+func mockProcesses(t *testing.T, processes map[int][2]uint64, exited ...int) *gomock.Controller {
+	mockCtrl := gomock.NewController(t)
+	mfs := mockfs.NewMockFileSystem(mockCtrl)
+	for pid, usage := range processes {
+		content := fmt.Sprintf("%d (worker%d) S 1 1 1 0 -1 0 0 0 0 0 %d 0 0 0 20 0 1 0 100 4096 %d 0 0 0 0 0 0 0 0 0 0 0 0 17 0 0 0 0 0 0\n", pid, pid, usage[0], usage[1])
+		mockfs.AddTextFile(mfs, fmt.Sprintf("/proc/%d/stat", pid), content)
+	}
+	for _, pid := range exited {
+		mfs.EXPECT().Open(fmt.Sprintf("/proc/%d/stat", pid)).Return(nil, os.ErrNotExist).AnyTimes()
+	}
+	fs.ChangeFileSystem(mfs)
+	return mockCtrl
+}
+
+func TestProcessTracker(t *testing.T) {
+	var tracker processTracker
+	start := time.Unix(1000, 0)
+
+	// The first sample has no CPU usage yet.
+	mockCtrl := mockProcesses(t, map[int][2]uint64{1: {100, 10}, 2: {100, 30}, 3: {100, 20}}, 4)
+	stats := &info.ContainerStats{Timestamp: start}
+	tracker.add(stats, []int{1, 2, 3, 4}, 2)
+	mockCtrl.Finish()
+	if len(stats.TopCpuProcesses) != 2 || stats.TopCpuProcesses[0].PercentCpu != 0 {
+		t.Errorf("unexpected top CPU processes %+v", stats.TopCpuProcesses)
+	}
+	pageSize := uint64(os.Getpagesize())
+	if len(stats.TopMemoryProcesses) != 2 || stats.TopMemoryProcesses[0].Pid != 2 || stats.TopMemoryProcesses[1].Rss != 20*pageSize {
+		t.Errorf("unexpected top memory processes %+v", stats.TopMemoryProcesses)
+	}
+
+	// Process 3 used a core over the last second, process 1 half of one.
+	elapsed := procfs.JiffiesToDuration(100)
+	mockCtrl = mockProcesses(t, map[int][2]uint64{1: {150, 10}, 2: {100, 30}, 3: {200, 20}})
+	stats = &info.ContainerStats{Timestamp: start.Add(elapsed)}
+	tracker.add(stats, []int{1, 2, 3}, 2)
+	mockCtrl.Finish()
+	expected := []info.TopProcess{
+		{Pid: 3, Command: "worker3", PercentCpu: 100, Rss: 20 * pageSize},
+		{Pid: 1, Command: "worker1", PercentCpu: 50, Rss: 10 * pageSize},
+	}
+	if len(stats.TopCpuProcesses) != 2 || stats.TopCpuProcesses[0] != expected[0] || stats.TopCpuProcesses[1] != expected[1] {
+		t.Errorf("expected top CPU processes %+v, got %+v", expected, stats.TopCpuProcesses)
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/google/cadvisor/alert"
+)
+
+// Returns the thresholds registered through the API, sorted by name.
+func (m *manager) GetTriggers() []alert.Trigger {
+	m.triggersLock.Lock()
+	defer m.triggersLock.Unlock()
+	triggers := make([]alert.Trigger, 0, len(m.triggers))
+	for _, trigger := range m.triggers {
+		triggers = append(triggers, *trigger)
+	}
+	sort.Sort(byTriggerName(triggers))
+	return triggers
+}
+
+type byTriggerName []alert.Trigger
+
+func (s byTriggerName) Len() int           { return len(s) }
+func (s byTriggerName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byTriggerName) Less(i, j int) bool { return s[i].Name < s[j].Name }
+
+// Adds or replaces a threshold of the containers the trigger matches.
+func (m *manager) SetTrigger(trigger *alert.Trigger) error {
+	if trigger.Name == "" {
+		return fmt.Errorf("the threshold has no name")
+	}
+	if !strings.HasPrefix(trigger.ContainerName, "/") {
+		return fmt.Errorf("invalid container name %q, it must be absolute", trigger.ContainerName)
+	}
+	if _, err := trigger.Threshold(); err != nil {
+		return err
+	}
+	m.triggersLock.Lock()
+	defer m.triggersLock.Unlock()
+	m.triggers[trigger.Name] = trigger
+	for _, cont := range m.allContainerData() {
+		m.applyTrigger(cont, trigger.Name)
+	}
+	glog.Infof("Set threshold %q (%s) of %q", trigger.Name, trigger.Expression, trigger.ContainerName)
+	return nil
+}
+
+// Removes a threshold registered through the API from the containers.
+func (m *manager) RemoveTrigger(name string) error {
+	m.triggersLock.Lock()
+	defer m.triggersLock.Unlock()
+	if _, ok := m.triggers[name]; !ok {
+		return fmt.Errorf("unknown threshold %q", name)
+	}
+	delete(m.triggers, name)
+	for _, cont := range m.allContainerData() {
+		m.applyTrigger(cont, name)
+	}
+	glog.Infof("Removed threshold %q", name)
+	return nil
+}
+
+// Adds the thresholds of the triggers matching a new container.
+func (m *manager) addTriggers(cont *containerData) {
+	m.triggersLock.Lock()
+	defer m.triggersLock.Unlock()
+	for name := range m.triggers {
+		m.applyTrigger(cont, name)
+	}
+}
+
+// Brings the threshold of the named trigger of a container up to date: removes the previous one,
+// and adds the current one if the trigger exists and matches the container. The thresholds of the
+// flags and labels take precedence. Must hold triggersLock.
+func (m *manager) applyTrigger(cont *containerData, name string) {
+	cont.lock.Lock()
+	defer cont.lock.Unlock()
+	if cont.triggers[name] {
+		cont.alerts.Remove(name)
+		delete(cont.triggers, name)
+	}
+	trigger, ok := m.triggers[name]
+	if !ok || !trigger.Matches(cont.info.Name) {
+		return
+	}
+	threshold, err := trigger.Threshold()
+	if err == nil {
+		err = cont.alerts.Add(threshold)
+	}
+	if err != nil {
+		glog.Warningf("Failed to add threshold %q to %q: %v", name, cont.info.Name, err)
+		return
+	}
+	if cont.triggers == nil {
+		cont.triggers = make(map[string]bool)
+	}
+	cont.triggers[name] = true
+}
@@ -101,6 +101,14 @@ const containersHtmlTemplate = `
       <div class="col-sm-12">
 	<div class="page-header">
 	  <h3>Usage</h3>
+	  <div class="btn-group btn-group-sm" id="time-range">
+	    <button type="button" class="btn btn-default active" data-range="60">1m</button>
+	    <button type="button" class="btn btn-default" data-range="300">5m</button>
+	    <button type="button" class="btn btn-default" data-range="3600">1h</button>
+	    <button type="button" class="btn btn-default" data-range="21600">6h</button>
+	    <button type="button" class="btn btn-default" data-range="86400">24h</button>
+	  </div>
+	  <span class="text-muted" id="time-range-note"></span>
 	</div>
 	<div class="panel panel-primary">
           <div class="panel-heading">
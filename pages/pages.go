@@ -5,7 +5,6 @@ import (
 	"html/template"
 	"net/http"
 
-	auth "github.com/abbot/go-http-auth"
 	"github.com/golang/glog"
 	"github.com/google/cadvisor/info"
 	"github.com/google/cadvisor/manager"
@@ -45,7 +44,7 @@ func init() {
 	}
 }
 
-func containerHandlerNoAuth(containerManager manager.Manager) http.HandlerFunc {
+func containerHandler(containerManager manager.Manager) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		err := serveContainersPage(containerManager, w, r.URL)
 		if err != nil {
@@ -54,16 +53,7 @@ func containerHandlerNoAuth(containerManager manager.Manager) http.HandlerFunc {
 	}
 }
 
-func containerHandler(containerManager manager.Manager) auth.AuthenticatedHandlerFunc {
-	return func(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
-		err := serveContainersPage(containerManager, w, r.URL)
-		if err != nil {
-			fmt.Fprintf(w, "%s", err)
-		}
-	}
-}
-
-func dockerHandlerNoAuth(containerManager manager.Manager) http.HandlerFunc {
+func dockerHandler(containerManager manager.Manager) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		err := serveDockerPage(containerManager, w, r.URL)
 		if err != nil {
@@ -72,37 +62,11 @@ func dockerHandlerNoAuth(containerManager manager.Manager) http.HandlerFunc {
 	}
 }
 
-func dockerHandler(containerManager manager.Manager) auth.AuthenticatedHandlerFunc {
-	return func(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
-		err := serveDockerPage(containerManager, w, r.URL)
-		if err != nil {
-			fmt.Fprintf(w, "%s", err)
-		}
-	}
-}
-
-// Register http handlers
-func RegisterHandlersDigest(containerManager manager.Manager, authenticator *auth.DigestAuth) error {
-	// Register the handler for the containers page.
-	if authenticator != nil {
-		http.HandleFunc(ContainersPage, authenticator.Wrap(containerHandler(containerManager)))
-		http.HandleFunc(DockerPage, authenticator.Wrap(dockerHandler(containerManager)))
-	} else {
-		http.HandleFunc(ContainersPage, containerHandlerNoAuth(containerManager))
-		http.HandleFunc(DockerPage, dockerHandlerNoAuth(containerManager))
-	}
-	return nil
-}
-
-func RegisterHandlersBasic(containerManager manager.Manager, authenticator *auth.BasicAuth) error {
-	// Register the handler for the containers and docker age.
-	if authenticator != nil {
-		http.HandleFunc(ContainersPage, authenticator.Wrap(containerHandler(containerManager)))
-		http.HandleFunc(DockerPage, authenticator.Wrap(dockerHandler(containerManager)))
-	} else {
-		http.HandleFunc(ContainersPage, containerHandlerNoAuth(containerManager))
-		http.HandleFunc(DockerPage, dockerHandlerNoAuth(containerManager))
-	}
+// Registers the handlers of the pages. They are authenticated with the rest of the web UI and
+// API by the server, see --http_auth_file.
+func RegisterHandlers(containerManager manager.Manager) error {
+	http.HandleFunc(ContainersPage, containerHandler(containerManager))
+	http.HandleFunc(DockerPage, dockerHandler(containerManager))
 	return nil
 }
 
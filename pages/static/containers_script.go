@@ -1517,15 +1517,26 @@ function getMachineInfo(callback) {
 	});
 }
 
-// Get the container stats for the specified container.
-function getStats(containerName, callback) {
+// Maximum number of stats drawn in a graph, the stats of longer time ranges are thinned out.
+var maxPoints = 300;
+
+// Get the container stats of the last timeRange seconds for the specified container.
+function getStats(containerName, timeRange, callback) {
 	// Request 60s of container history and no samples.
-	var request = JSON.stringify({
+	var request = {
                 // Update main.statsRequestedByUI while updating "num_stats" here.
 		"num_stats": 60,
 		"num_samples": 0
-	});
-	$.post("/api/v1.0/containers" + containerName, request, function(data) {
+	};
+	if (timeRange > 60) {
+		// Longer ranges are read from the storage driver when it can read them back.
+		request = {
+			"num_stats": -1,
+			"start": new Date(Date.now() - timeRange * 1000).toISOString(),
+			"resolution": Math.floor(timeRange * 1000000000 / maxPoints)
+		};
+	}
+	$.post("/api/v1.0/containers" + containerName, JSON.stringify(request), function(data) {
 		callback(data);
 	}, "json");
 }
@@ -1858,19 +1869,54 @@ function startPage(containerName, hasCpu, hasMemory) {
 	window.charts = {};
 	window.cadvisor = {};
 	window.cadvisor.firstRun = true;
+	window.cadvisor.timeRange = 60;
 
 	// Get machine info, then get the stats every 1s.
 	getMachineInfo(function(machineInfo) {
-		setInterval(function() {
-			getStats(containerName, function(containerInfo){
-				if (window.cadvisor.firstRun && containerInfo.spec.has_filesystem) {
-					window.cadvisor.firstRun = false;
-					startFileSystemUsage("filesystem-usage", machineInfo, containerInfo);
-				}
-
-				drawCharts(machineInfo, containerInfo);
-			});
-		}, 1000);
+		$("#time-range button").click(function() {
+			$("#time-range button").removeClass("active");
+			$(this).addClass("active");
+			window.cadvisor.timeRange = parseInt($(this).data("range"), 10);
+			refreshStats(containerName, machineInfo);
+		});
+		refreshStats(containerName, machineInfo);
+	});
+}
+
+// Get the stats of the selected time range and draw them, then schedule the next refresh. The
+// stats of longer ranges are refreshed less often, at the resolution they are thinned out to.
+function refreshStats(containerName, machineInfo) {
+	clearTimeout(window.cadvisor.refreshTimer);
+	var timeRange = window.cadvisor.timeRange;
+	window.cadvisor.refreshTimer = setTimeout(function() {
+		refreshStats(containerName, machineInfo);
+	}, Math.max(1000, timeRange * 1000 / maxPoints));
+
+	getStats(containerName, timeRange, function(containerInfo){
+		// Drop the stats of a range no longer selected.
+		if (timeRange != window.cadvisor.timeRange || !containerInfo.stats || containerInfo.stats.length == 0) {
+			return;
+		}
+		drawTimeRangeNote("time-range-note", timeRange, containerInfo);
+
+		if (window.cadvisor.firstRun && containerInfo.spec.has_filesystem) {
+			window.cadvisor.firstRun = false;
+			startFileSystemUsage("filesystem-usage", machineInfo, containerInfo);
+		}
+
+		drawCharts(machineInfo, containerInfo);
 	});
 }
+
+// Tell when the stats do not cover the selected time range, e.g. when the storage driver does not
+// keep them or the container is younger.
+function drawTimeRangeNote(elementId, timeRange, containerInfo) {
+	var oldest = new Date(containerInfo.stats[0].timestamp);
+	var covered = (Date.now() - oldest.getTime()) / 1000;
+	var note = "";
+	if (timeRange > 60 && covered < timeRange * 0.95) {
+		note = "Stats available since " + oldest.toLocaleString();
+	}
+	$("#" + elementId).text(note);
+}
 `
@@ -0,0 +1,138 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package perf
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+
+	"github.com/google/cadvisor/info"
+)
+
+// perf_event_attr, up to PERF_ATTR_SIZE_VER5.
+type perfEventAttr struct {
+	Type             uint32
+	Size             uint32
+	Config           uint64
+	SamplePeriod     uint64
+	SampleType       uint64
+	ReadFormat       uint64
+	Bits             uint64
+	WakeupEvents     uint32
+	BpType           uint32
+	Config1          uint64
+	Config2          uint64
+	BranchSampleType uint64
+	SampleRegsUser   uint64
+	SampleStackUser  uint32
+	ClockId          int32
+	SampleRegsIntr   uint64
+	AuxWatermark     uint32
+	SampleMaxStack   uint16
+	reserved         uint16
+}
+
+const (
+	formatTotalTimeEnabled = 1 << 0
+	formatTotalTimeRunning = 1 << 1
+
+	flagPidCgroup = 1 << 2
+	flagFdCloexec = 1 << 3
+)
+
+// Counts the events of the perf_event cgroup of a container on every CPU.
+type Collector struct {
+	// File descriptors of the counters, by event and CPU.
+	fds [][]int
+}
+
+// Opens the counters of the events on the perf_event cgroup at the specified path.
+func NewCollector(cgroupPath string) (*Collector, error) {
+	cgroup, err := os.Open(cgroupPath)
+	if err != nil {
+		return nil, err
+	}
+	defer cgroup.Close()
+
+	self := &Collector{fds: make([][]int, 0, len(events))}
+	for _, e := range events {
+		attr := perfEventAttr{
+			Type:       e.eventType,
+			Config:     e.config,
+			ReadFormat: formatTotalTimeEnabled | formatTotalTimeRunning,
+		}
+		attr.Size = uint32(unsafe.Sizeof(attr))
+		fds := make([]int, 0, numCpus)
+		for cpu := 0; cpu < numCpus; cpu++ {
+			fd, _, errno := syscall.Syscall6(syscall.SYS_PERF_EVENT_OPEN, uintptr(unsafe.Pointer(&attr)), cgroup.Fd(), uintptr(cpu), ^uintptr(0), flagPidCgroup|flagFdCloexec, 0)
+			if errno != 0 {
+				self.fds = append(self.fds, fds)
+				self.Destroy()
+				return nil, fmt.Errorf("failed to open perf event %q on cpu %d: %v", e.name, cpu, errno)
+			}
+			fds = append(fds, int(fd))
+		}
+		self.fds = append(self.fds, fds)
+	}
+	return self, nil
+}
+
+// Adds the counts of the events since the collector was created to the stats.
+// Counts are scaled up when the events were multiplexed with other events.
+func (self *Collector) UpdateStats(stats *info.ContainerStats) error {
+	stats.PerfStats = make([]info.PerfStat, 0, len(self.fds))
+	for i, fds := range self.fds {
+		var value, enabled, running uint64
+		for _, fd := range fds {
+			// The count, the time enabled and the time running.
+			var counts [3]uint64
+			if _, err := syscall.Read(fd, (*[24]byte)(unsafe.Pointer(&counts))[:]); err != nil {
+				return fmt.Errorf("failed to read perf event %q: %v", events[i].name, err)
+			}
+			value += scale(counts[0], counts[1], counts[2])
+			enabled += counts[1]
+			running += counts[2]
+		}
+		stat := info.PerfStat{Name: events[i].name, Value: value, ScalingRatio: 1}
+		if enabled != 0 {
+			stat.ScalingRatio = float64(running) / float64(enabled)
+		}
+		stats.PerfStats = append(stats.PerfStats, stat)
+	}
+	return nil
+}
+
+// Estimates the count of an event over the time it was enabled from the time it was counted.
+func scale(count, enabled, running uint64) uint64 {
+	if running == 0 || running >= enabled {
+		return count
+	}
+	return uint64(float64(count) * float64(enabled) / float64(running))
+}
+
+// Closes the counters.
+func (self *Collector) Destroy() {
+	for _, fds := range self.fds {
+		for _, fd := range fds {
+			syscall.Close(fd)
+		}
+	}
+	self.fds = nil
+}
@@ -0,0 +1,37 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !linux
+// +build !linux
+
+package perf
+
+import (
+	"fmt"
+
+	"github.com/google/cadvisor/info"
+)
+
+// perf_event is only available on Linux.
+type Collector struct{}
+
+func NewCollector(cgroupPath string) (*Collector, error) {
+	return nil, fmt.Errorf("perf events are only counted on Linux")
+}
+
+func (self *Collector) UpdateStats(stats *info.ContainerStats) error {
+	return nil
+}
+
+func (self *Collector) Destroy() {}
@@ -0,0 +1,123 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Counts hardware events of containers with perf_event, e.g. for noisy neighbor analysis.
+package perf
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+)
+
+var perfEventsConfig = flag.String("perf_events_config", "", "Path to a JSON file listing the hardware events to count for each container with perf_event, e.g. {\"events\": [\"cycles\", \"instructions\", \"cache-misses\"]}. Empty disables perf_event.")
+
+// Events to count, as read from --perf_events_config.
+type EventsConfig struct {
+	// Generalized hardware events, e.g.: cycles, instructions, cache-misses.
+	Events []string `json:"events"`
+
+	// Events specific to a processor, e.g.: {"name": "l3_misses", "config": "0x412e"}.
+	RawEvents []RawEvent `json:"raw_events"`
+}
+
+type RawEvent struct {
+	Name string `json:"name"`
+
+	// Event selector and unit mask of the event, in decimal or hexadecimal.
+	Config string `json:"config"`
+}
+
+// perf_event_attr types.
+const (
+	typeHardware = 0
+	typeRaw      = 4
+)
+
+// Configs of the generalized hardware events of perf_event.
+var hardwareEvents = map[string]uint64{
+	"cycles":                  0,
+	"instructions":            1,
+	"cache-references":        2,
+	"cache-misses":            3,
+	"branch-instructions":     4,
+	"branch-misses":           5,
+	"bus-cycles":              6,
+	"stalled-cycles-frontend": 7,
+	"stalled-cycles-backend":  8,
+	"ref-cycles":              9,
+}
+
+type event struct {
+	name      string
+	eventType uint32
+	config    uint64
+}
+
+// Events counted for every container, none when perf_event is disabled.
+var events []event
+
+// Number of CPUs every event is counted on.
+var numCpus int
+
+func parseConfig(data []byte) ([]event, error) {
+	var config EventsConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+	var ret []event
+	for _, name := range config.Events {
+		eventConfig, ok := hardwareEvents[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown perf event %q", name)
+		}
+		ret = append(ret, event{name, typeHardware, eventConfig})
+	}
+	for _, raw := range config.RawEvents {
+		if raw.Name == "" {
+			return nil, fmt.Errorf("raw perf event %q has no name", raw.Config)
+		}
+		eventConfig, err := strconv.ParseUint(raw.Config, 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid config of raw perf event %q: %v", raw.Name, err)
+		}
+		ret = append(ret, event{raw.Name, typeRaw, eventConfig})
+	}
+	return ret, nil
+}
+
+// Reads the events to count from --perf_events_config, if any, on the specified number of CPUs.
+func Setup(cpus int) error {
+	if *perfEventsConfig == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(*perfEventsConfig)
+	if err != nil {
+		return err
+	}
+	parsed, err := parseConfig(data)
+	if err != nil {
+		return fmt.Errorf("invalid perf events config %q: %v", *perfEventsConfig, err)
+	}
+	events = parsed
+	numCpus = cpus
+	return nil
+}
+
+// Returns whether perf events are counted for containers.
+func Enabled() bool {
+	return len(events) != 0
+}
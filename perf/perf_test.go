@@ -0,0 +1,66 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package perf
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseConfig(t *testing.T) {
+	config := `{
+		"events": ["cycles", "cache-misses"],
+		"raw_events": [{"name": "l3_misses", "config": "0x412e"}]
+	}`
+	parsed, err := parseConfig([]byte(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []event{
+		{"cycles", typeHardware, 0},
+		{"cache-misses", typeHardware, 3},
+		{"l3_misses", typeRaw, 0x412e},
+	}
+	if !reflect.DeepEqual(parsed, expected) {
+		t.Errorf("expected %+v, got %+v", expected, parsed)
+	}
+
+	for _, invalid := range []string{
+		`{"events": ["unknown"]}`,
+		`{"raw_events": [{"config": "1"}]}`,
+		`{"raw_events": [{"name": "l3_misses", "config": "l3"}]}`,
+	} {
+		if _, err := parseConfig([]byte(invalid)); err == nil {
+			t.Errorf("expected %s to be invalid", invalid)
+		}
+	}
+}
+
+func TestScale(t *testing.T) {
+	for _, c := range []struct {
+		count, enabled, running, expected uint64
+	}{
+		{100, 10, 10, 100},
+		{100, 10, 5, 200},
+		{100, 10, 0, 100},
+	} {
+		if value := scale(c.count, c.enabled, c.running); value != c.expected {
+			t.Errorf("expected %d scaling %d over %d/%d, got %d", c.expected, c.count, c.running, c.enabled, value)
+		}
+	}
+}
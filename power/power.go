@@ -0,0 +1,160 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Reads the energy consumed by the machine from its RAPL counters, and the temperature of its
+// thermal zones.
+package power
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/cadvisor/info"
+)
+
+// Directories of the power capping and thermal classes, overridden in tests.
+var (
+	powercapDir = "/sys/class/powercap"
+	thermalDir  = "/sys/class/thermal"
+)
+
+var (
+	availableOnce sync.Once
+	available     bool
+)
+
+// Whether the machine has RAPL counters readable by cAdvisor.
+func Available() bool {
+	availableOnce.Do(func() {
+		energy, err := ReadEnergy()
+		available = err == nil && len(energy) != 0
+	})
+	return available
+}
+
+// Reads the energy counters of the RAPL zones (e.g.: package-0) and their subzones (e.g.: dram).
+// Machines without RAPL, or whose counters are only readable by root, have none.
+func ReadEnergy() ([]info.EnergyStats, error) {
+	entries, err := ioutil.ReadDir(powercapDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var ret []info.EnergyStats
+	for _, entry := range entries {
+		// e.g.: intel-rapl:0, intel-rapl:0:0
+		if !strings.HasPrefix(entry.Name(), "intel-rapl:") {
+			continue
+		}
+		zonePath := path.Join(powercapDir, entry.Name())
+		name, err := ioutil.ReadFile(path.Join(zonePath, "name"))
+		if err != nil {
+			return nil, err
+		}
+		energy, err := readUint(path.Join(zonePath, "energy_uj"))
+		if os.IsPermission(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		maxEnergy, err := readUint(path.Join(zonePath, "max_energy_range_uj"))
+		if err != nil && !os.IsNotExist(err) && !os.IsPermission(err) {
+			return nil, err
+		}
+		ret = append(ret, info.EnergyStats{
+			Id:        strings.TrimPrefix(entry.Name(), "intel-rapl:"),
+			Zone:      strings.TrimSpace(string(name)),
+			Energy:    energy,
+			MaxEnergy: maxEnergy,
+		})
+	}
+	return ret, nil
+}
+
+// Reads the temperature of the thermal zones.
+func ReadThermal() ([]info.ThermalStats, error) {
+	entries, err := ioutil.ReadDir(thermalDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var ret []info.ThermalStats
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), "thermal_zone") {
+			continue
+		}
+		zonePath := path.Join(thermalDir, entry.Name())
+		zoneType, err := ioutil.ReadFile(path.Join(zonePath, "type"))
+		if err != nil {
+			return nil, err
+		}
+		out, err := ioutil.ReadFile(path.Join(zonePath, "temp"))
+		if err != nil {
+			// Some zones fail to be read when their sensor is off.
+			continue
+		}
+		temperature, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid temperature of %s: %v", entry.Name(), err)
+		}
+		ret = append(ret, info.ThermalStats{
+			Zone:        strings.TrimSpace(string(zoneType)),
+			Temperature: temperature,
+		})
+	}
+	return ret, nil
+}
+
+// Returns the energy consumed by the packages of the machine (e.g.: the package-0 and package-1
+// zones, which include the CPU cores) between two readings, accounting for counters that wrapped.
+func PackageEnergyDelta(last, current []info.EnergyStats) uint64 {
+	lastEnergy := make(map[string]uint64, len(last))
+	for _, zone := range last {
+		lastEnergy[zone.Id] = zone.Energy
+	}
+	var delta uint64
+	for _, zone := range current {
+		if !strings.HasPrefix(zone.Zone, "package-") {
+			continue
+		}
+		previous, ok := lastEnergy[zone.Id]
+		if !ok {
+			continue
+		}
+		if zone.Energy >= previous {
+			delta += zone.Energy - previous
+		} else if zone.MaxEnergy > previous {
+			delta += zone.MaxEnergy - previous + zone.Energy
+		}
+	}
+	return delta
+}
+
+func readUint(file string) (uint64, error) {
+	out, err := ioutil.ReadFile(file)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(out)), 10, 64)
+}
@@ -0,0 +1,101 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package power
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/google/cadvisor/info"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	for file, content := range files {
+		if err := os.MkdirAll(path.Dir(path.Join(root, file)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path.Join(root, file), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReadEnergyAndThermal(t *testing.T) {
+	root, err := ioutil.TempDir("", "power")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	powercapDir = path.Join(root, "powercap")
+	thermalDir = path.Join(root, "thermal")
+	writeFiles(t, root, map[string]string{
+		"powercap/intel-rapl/enabled":                 "1\n",
+		"powercap/intel-rapl:0/name":                  "package-0\n",
+		"powercap/intel-rapl:0/energy_uj":             "123456\n",
+		"powercap/intel-rapl:0/max_energy_range_uj":   "262143328850\n",
+		"powercap/intel-rapl:0:0/name":                "dram\n",
+		"powercap/intel-rapl:0:0/energy_uj":           "789\n",
+		"powercap/intel-rapl:0:0/max_energy_range_uj": "65712999613\n",
+		"thermal/cooling_device0/type":                "Processor\n",
+		"thermal/thermal_zone0/type":                  "acpitz\n",
+		"thermal/thermal_zone0/temp":                  "27800\n",
+		"thermal/thermal_zone1/type":                  "x86_pkg_temp\n",
+		"thermal/thermal_zone1/temp":                  "45000\n",
+	})
+
+	energy, err := ReadEnergy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedEnergy := []info.EnergyStats{
+		{Id: "0", Zone: "package-0", Energy: 123456, MaxEnergy: 262143328850},
+		{Id: "0:0", Zone: "dram", Energy: 789, MaxEnergy: 65712999613},
+	}
+	if !reflect.DeepEqual(energy, expectedEnergy) {
+		t.Errorf("expected %+v, got %+v", expectedEnergy, energy)
+	}
+
+	thermal, err := ReadThermal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedThermal := []info.ThermalStats{
+		{Zone: "acpitz", Temperature: 27800},
+		{Zone: "x86_pkg_temp", Temperature: 45000},
+	}
+	if !reflect.DeepEqual(thermal, expectedThermal) {
+		t.Errorf("expected %+v, got %+v", expectedThermal, thermal)
+	}
+}
+
+func TestPackageEnergyDelta(t *testing.T) {
+	last := []info.EnergyStats{
+		{Id: "0", Zone: "package-0", Energy: 1000, MaxEnergy: 10000},
+		{Id: "0:0", Zone: "dram", Energy: 100, MaxEnergy: 10000},
+		{Id: "1", Zone: "package-1", Energy: 9500, MaxEnergy: 10000},
+	}
+	current := []info.EnergyStats{
+		{Id: "0", Zone: "package-0", Energy: 1500, MaxEnergy: 10000},
+		{Id: "0:0", Zone: "dram", Energy: 300, MaxEnergy: 10000},
+		// Wrapped.
+		{Id: "1", Zone: "package-1", Energy: 200, MaxEnergy: 10000},
+	}
+	if delta := PackageEnergyDelta(last, current); delta != 500+700 {
+		t.Errorf("expected 1200µJ, got %d", delta)
+	}
+}
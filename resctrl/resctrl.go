@@ -0,0 +1,166 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Monitors the last level cache occupancy and memory bandwidth of containers
+// with resctrl, on processors supporting Intel RDT (CMT and MBM).
+package resctrl
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/google/cadvisor/info"
+)
+
+var enableResctrl = flag.Bool("enable_resctrl", false, "Whether to monitor the cache occupancy and memory bandwidth of containers with resctrl (Intel RDT). Each container takes one of the few monitoring IDs of the processor")
+
+// Mount point of the resctrl filesystem.
+var resctrlRoot = "/sys/fs/resctrl"
+
+// Prefix of the monitoring groups of containers, to tell them from the groups of other tools.
+const groupPrefix = "cadvisor"
+
+var enabled bool
+
+// Checks that resctrl supports monitoring when --enable_resctrl.
+func Setup() error {
+	if !*enableResctrl {
+		return nil
+	}
+	if _, err := os.Stat(path.Join(resctrlRoot, "info", "L3_MON")); err != nil {
+		return fmt.Errorf("resctrl monitoring is not available, is resctrl mounted at %s? %v", resctrlRoot, err)
+	}
+	enabled = true
+	return nil
+}
+
+// Returns whether containers are monitored with resctrl.
+func Enabled() bool {
+	return enabled
+}
+
+// Monitors the threads of a container in its own monitoring group.
+type Collector struct {
+	groupPath string
+
+	// Threads moved to the monitoring group.
+	threads map[int]struct{}
+}
+
+// Returns the monitoring group of the specified container.
+// e.g.: /docker/abc -> cadvisor-docker-abc
+func groupName(containerName string) string {
+	return groupPrefix + strings.Replace(containerName, "/", "-", -1)
+}
+
+// Creates the monitoring group of the specified container.
+func NewCollector(containerName string) (*Collector, error) {
+	groupPath := path.Join(resctrlRoot, "mon_groups", groupName(containerName))
+	if err := os.Mkdir(groupPath, 0755); err != nil && !os.IsExist(err) {
+		// ENOSPC when the monitoring IDs are exhausted.
+		return nil, err
+	}
+	return &Collector{
+		groupPath: groupPath,
+		threads:   make(map[int]struct{}),
+	}, nil
+}
+
+// Moves the specified threads of the container to its monitoring group and
+// adds the usage of the group to the stats.
+func (self *Collector) UpdateStats(stats *info.ContainerStats, threads []int) error {
+	if err := self.addThreads(threads); err != nil {
+		return err
+	}
+	domains, err := ioutil.ReadDir(path.Join(self.groupPath, "mon_data"))
+	if err != nil {
+		return err
+	}
+	stats.Resctrl = make([]info.ResctrlStats, 0, len(domains))
+	for _, domain := range domains {
+		// e.g.: mon_L3_00
+		domainPath := path.Join(self.groupPath, "mon_data", domain.Name())
+		domainStats := info.ResctrlStats{Domain: strings.TrimPrefix(domain.Name(), "mon_L3_")}
+		var total uint64
+		for file, value := range map[string]*uint64{
+			"llc_occupancy":   &domainStats.LlcOccupancy,
+			"mbm_local_bytes": &domainStats.MemoryBandwidthLocal,
+			"mbm_total_bytes": &total,
+		} {
+			if *value, err = readCounter(path.Join(domainPath, file)); err != nil {
+				return err
+			}
+		}
+		if total > domainStats.MemoryBandwidthLocal {
+			domainStats.MemoryBandwidthRemote = total - domainStats.MemoryBandwidthLocal
+		}
+		stats.Resctrl = append(stats.Resctrl, domainStats)
+	}
+	return nil
+}
+
+// Reads a counter of a monitoring group, 0 when the processor does not support it.
+func readCounter(counterPath string) (uint64, error) {
+	out, err := ioutil.ReadFile(counterPath)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	value := strings.TrimSpace(string(out))
+	if value == "Unavailable" {
+		return 0, nil
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
+
+// Moves the threads that are not in the monitoring group yet, one at a time as resctrl requires.
+func (self *Collector) addThreads(threads []int) error {
+	tasks, err := os.OpenFile(path.Join(self.groupPath, "tasks"), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return err
+	}
+	defer tasks.Close()
+
+	current := make(map[int]struct{}, len(threads))
+	for _, thread := range threads {
+		current[thread] = struct{}{}
+		if _, ok := self.threads[thread]; ok {
+			continue
+		}
+		_, err := tasks.Write([]byte(strconv.Itoa(thread) + "\n"))
+		if err != nil {
+			// The thread exited meanwhile.
+			if pathErr, ok := err.(*os.PathError); ok && pathErr.Err == syscall.ESRCH {
+				delete(current, thread)
+				continue
+			}
+			return err
+		}
+	}
+	self.threads = current
+	return nil
+}
+
+// Removes the monitoring group, releasing its monitoring ID.
+func (self *Collector) Destroy() error {
+	return os.Remove(self.groupPath)
+}
@@ -0,0 +1,86 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resctrl
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/google/cadvisor/info"
+)
+
+func TestCollector(t *testing.T) {
+	root, err := ioutil.TempDir("", "resctrl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	resctrlRoot = root
+	if err := os.Mkdir(path.Join(root, "mon_groups"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	collector, err := NewCollector("/docker/abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	groupPath := path.Join(root, "mon_groups", "cadvisor-docker-abc")
+	if collector.groupPath != groupPath {
+		t.Errorf("expected the monitoring group %s, got %s", groupPath, collector.groupPath)
+	}
+
+	// What resctrl creates along with the group, on a processor with two L3 caches, the second without MBM.
+	for file, value := range map[string]string{
+		"tasks":                              "",
+		"mon_data/mon_L3_00/llc_occupancy":   "1048576\n",
+		"mon_data/mon_L3_00/mbm_local_bytes": "3000\n",
+		"mon_data/mon_L3_00/mbm_total_bytes": "4000\n",
+		"mon_data/mon_L3_01/llc_occupancy":   "2048\n",
+	} {
+		if err := os.MkdirAll(path.Dir(path.Join(groupPath, file)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path.Join(groupPath, file), []byte(value), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats := &info.ContainerStats{}
+	if err := collector.UpdateStats(stats, []int{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	expected := []info.ResctrlStats{
+		{Domain: "00", LlcOccupancy: 1048576, MemoryBandwidthLocal: 3000, MemoryBandwidthRemote: 1000},
+		{Domain: "01", LlcOccupancy: 2048},
+	}
+	if !reflect.DeepEqual(stats.Resctrl, expected) {
+		t.Errorf("expected %+v, got %+v", expected, stats.Resctrl)
+	}
+
+	// Threads are only moved once.
+	if err := collector.UpdateStats(stats, []int{2, 3}); err != nil {
+		t.Fatal(err)
+	}
+	tasks, err := ioutil.ReadFile(path.Join(groupPath, "tasks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(tasks) != "1\n2\n3\n" {
+		t.Errorf("unexpected threads moved to the group: %q", tasks)
+	}
+}
@@ -15,8 +15,13 @@
 package bigquery
 
 import (
+	"flag"
 	"fmt"
+	"os"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	bigquery "code.google.com/p/google-api-go-client/bigquery/v2"
@@ -25,9 +30,26 @@ import (
 	"github.com/google/cadvisor/storage/bigquery/client"
 )
 
+var argTableExpiration = flag.Duration("bq_table_expiration", 0, "how long stats are kept in BigQuery with -bq_daily_tables. Daily tables are deleted by BigQuery once they are older than this. Zero keeps them forever")
+var argDailyTables = flag.Bool("bq_daily_tables", false, "write stats to date-sharded daily tables (<table>_YYYYMMDD), created automatically, instead of a single table. Required by -bq_table_expiration")
+var argBatchSize = flag.Int("bq_batch_size", 500, "maximum number of rows streamed to BigQuery in a single request, rows are sent earlier when the buffer duration elapses")
+
 type bigqueryStorage struct {
-	client      *client.Client
-	machineName string
+	client         *client.Client
+	machineName    string
+	tableName      string
+	dailyTables    bool
+	expiration     time.Duration
+	batchSize      int
+	bufferDuration time.Duration
+
+	lock sync.Mutex
+	// Day of the table stats are currently written to, only used with daily tables.
+	tableDay time.Time
+	// Rows waiting to be streamed, with their insert identifiers.
+	rows      []map[string]interface{}
+	insertIds []string
+	lastFlush time.Time
 }
 
 const (
@@ -35,217 +57,243 @@ const (
 	typeTimestamp string = "TIMESTAMP"
 	typeString    string = "STRING"
 	typeInteger   string = "INTEGER"
-
-	colTimestamp          string = "timestamp"
-	colMachineName        string = "machine"
-	colContainerName      string = "container_name"
-	colCpuCumulativeUsage string = "cpu_cumulative_usage"
-	// Cumulative Cpu usage in system and user mode
-	colCpuCumulativeUsageSystem string = "cpu_cumulative_usage_system"
-	colCpuCumulativeUsageUser   string = "cpu_cumulative_usage_user"
-	// Memory usage
-	colMemoryUsage string = "memory_usage"
-	// Working set size
-	colMemoryWorkingSet string = "memory_working_set"
-	// Container page fault
-	colMemoryContainerPgfault string = "memory_container_pgfault"
-	// Constainer major page fault
-	colMemoryContainerPgmajfault string = "memory_container_pgmajfault"
-	// Hierarchical page fault
-	colMemoryHierarchicalPgfault string = "memory_hierarchical_pgfault"
-	// Hierarchical major page fault
-	colMemoryHierarchicalPgmajfault string = "memory_hierarchical_pgmajfault"
-	// Cumulative count of bytes received.
-	colRxBytes string = "rx_bytes"
-	// Cumulative count of receive errors encountered.
-	colRxErrors string = "rx_errors"
-	// Cumulative count of bytes transmitted.
-	colTxBytes string = "tx_bytes"
-	// Cumulative count of transmit errors encountered.
-	colTxErrors string = "tx_errors"
-	// Filesystem device.
-	colFsDevice = "fs_device"
-	// Filesystem limit.
-	colFsLimit = "fs_limit"
-	// Filesystem available space.
-	colFsUsage = "fs_usage"
+	typeRecord    string = "RECORD"
+
+	// Bigquery field modes
+	modeRequired string = "REQUIRED"
+	modeNullable string = "NULLABLE"
+	modeRepeated string = "REPEATED"
+
+	colTimestamp     string = "timestamp"
+	colMachineName   string = "machine"
+	colContainerName string = "container_name"
+	// Cpu usage, with the cumulative usage of each cpu.
+	colCpu string = "cpu"
+	// Memory usage and page faults.
+	colMemory string = "memory"
+	// One record per network interface.
+	colNetwork string = "network"
+	// One record per block device.
+	colDiskIo string = "diskio"
+	// One record per filesystem.
+	colFilesystem string = "filesystem"
 )
 
-// TODO(jnagal): Infer schema through reflection. (See bigquery/client/example)
-func (self *bigqueryStorage) GetSchema() *bigquery.TableSchema {
-	fields := make([]*bigquery.TableFieldSchema, 18)
-	i := 0
-	fields[i] = &bigquery.TableFieldSchema{
-		Type: typeTimestamp,
-		Name: colTimestamp,
-		Mode: "REQUIRED",
-	}
-	i++
-	fields[i] = &bigquery.TableFieldSchema{
-		Type: typeString,
-		Name: colMachineName,
-		Mode: "REQUIRED",
-	}
-	i++
-	fields[i] = &bigquery.TableFieldSchema{
-		Type: typeString,
-		Name: colContainerName,
-		Mode: "REQUIRED",
-	}
-	i++
-	fields[i] = &bigquery.TableFieldSchema{
-		Type: typeInteger,
-		Name: colCpuCumulativeUsage,
-	}
-	i++
-	fields[i] = &bigquery.TableFieldSchema{
-		Type: typeInteger,
-		Name: colCpuCumulativeUsageSystem,
-	}
-	i++
-	fields[i] = &bigquery.TableFieldSchema{
-		Type: typeInteger,
-		Name: colCpuCumulativeUsageUser,
-	}
-	i++
-	fields[i] = &bigquery.TableFieldSchema{
-		Type: typeInteger,
-		Name: colMemoryUsage,
-	}
-	i++
-	fields[i] = &bigquery.TableFieldSchema{
-		Type: typeInteger,
-		Name: colMemoryWorkingSet,
-	}
-	i++
-	fields[i] = &bigquery.TableFieldSchema{
-		Type: typeInteger,
-		Name: colMemoryContainerPgfault,
-	}
-	i++
-	fields[i] = &bigquery.TableFieldSchema{
-		Type: typeInteger,
-		Name: colMemoryContainerPgmajfault,
-	}
-	i++
-	fields[i] = &bigquery.TableFieldSchema{
-		Type: typeInteger,
-		Name: colMemoryHierarchicalPgfault,
-	}
-	i++
-	fields[i] = &bigquery.TableFieldSchema{
-		Type: typeInteger,
-		Name: colMemoryHierarchicalPgmajfault,
-	}
-	i++
-	fields[i] = &bigquery.TableFieldSchema{
-		Type: typeInteger,
-		Name: colRxBytes,
-	}
-	i++
-	fields[i] = &bigquery.TableFieldSchema{
-		Type: typeInteger,
-		Name: colRxErrors,
-	}
-	i++
-	fields[i] = &bigquery.TableFieldSchema{
-		Type: typeInteger,
-		Name: colTxBytes,
-	}
-	i++
-	fields[i] = &bigquery.TableFieldSchema{
-		Type: typeInteger,
-		Name: colTxErrors,
-	}
-	i++
-	fields[i] = &bigquery.TableFieldSchema{
-		Type: typeString,
-		Name: colFsDevice,
-	}
-	i++
-	fields[i] = &bigquery.TableFieldSchema{
-		Type: typeInteger,
-		Name: colFsLimit,
-	}
-	i++
-	fields[i] = &bigquery.TableFieldSchema{
-		Type: typeInteger,
-		Name: colFsUsage,
+func field(name, fieldType string) *bigquery.TableFieldSchema {
+	return &bigquery.TableFieldSchema{
+		Type: fieldType,
+		Name: name,
 	}
-	return &bigquery.TableSchema{
+}
+
+func record(name, mode string, fields ...*bigquery.TableFieldSchema) *bigquery.TableFieldSchema {
+	return &bigquery.TableFieldSchema{
+		Type:   typeRecord,
+		Name:   name,
+		Mode:   mode,
 		Fields: fields,
 	}
 }
 
-func (self *bigqueryStorage) containerStatsToRows(
-	ref info.ContainerReference,
-	stats *info.ContainerStats,
-) (row map[string]interface{}) {
-	row = make(map[string]interface{})
-
-	// Timestamp
-	row[colTimestamp] = stats.Timestamp
-
-	// Machine name
-	row[colMachineName] = self.machineName
-
-	// Container name
-	name := ref.Name
-	if len(ref.Aliases) > 0 {
-		name = ref.Aliases[0]
+func integers(names ...string) []*bigquery.TableFieldSchema {
+	fields := make([]*bigquery.TableFieldSchema, 0, len(names))
+	for _, name := range names {
+		fields = append(fields, field(name, typeInteger))
 	}
-	row[colContainerName] = name
-
-	// Cumulative Cpu Usage
-	row[colCpuCumulativeUsage] = stats.Cpu.Usage.Total
-
-	// Cumulative Cpu Usage in system mode
-	row[colCpuCumulativeUsageSystem] = stats.Cpu.Usage.System
+	return fields
+}
 
-	// Cumulative Cpu Usage in user mode
-	row[colCpuCumulativeUsageUser] = stats.Cpu.Usage.User
+// Keys of the blkio stats, see info.PerDiskStats.
+var diskIoOperations = []string{"Read", "Write", "Sync", "Async", "Total"}
 
-	// Memory Usage
-	row[colMemoryUsage] = stats.Memory.Usage
+func diskIoRecord(name string) *bigquery.TableFieldSchema {
+	names := make([]string, 0, len(diskIoOperations))
+	for _, op := range diskIoOperations {
+		names = append(names, strings.ToLower(op))
+	}
+	return record(name, modeNullable, integers(names...)...)
+}
 
-	// Working set size
-	row[colMemoryWorkingSet] = stats.Memory.WorkingSet
+func memoryDataRecord(name string) *bigquery.TableFieldSchema {
+	return record(name, modeNullable, integers("pgfault", "pgmajfault")...)
+}
 
-	// container page fault
-	row[colMemoryContainerPgfault] = stats.Memory.ContainerData.Pgfault
+func (self *bigqueryStorage) GetSchema() *bigquery.TableSchema {
+	perCpu := field("per_cpu", typeInteger)
+	perCpu.Mode = modeRepeated
+	fields := []*bigquery.TableFieldSchema{
+		{Type: typeTimestamp, Name: colTimestamp, Mode: modeRequired},
+		{Type: typeString, Name: colMachineName, Mode: modeRequired},
+		{Type: typeString, Name: colContainerName, Mode: modeRequired},
+		record(colCpu, modeNullable, append(integers("total", "user", "system", "load"), perCpu)...),
+		record(colMemory, modeNullable,
+			field("usage", typeInteger),
+			field("working_set", typeInteger),
+			memoryDataRecord("container_data"),
+			memoryDataRecord("hierarchical_data"),
+		),
+		record(colNetwork, modeRepeated, append(
+			[]*bigquery.TableFieldSchema{field("interface", typeString)},
+			integers("rx_bytes", "rx_packets", "rx_errors", "rx_dropped", "tx_bytes", "tx_packets", "tx_errors", "tx_dropped")...)...),
+		record(colDiskIo, modeRepeated,
+			field("major", typeInteger),
+			field("minor", typeInteger),
+			diskIoRecord("service_bytes"),
+			diskIoRecord("serviced"),
+		),
+		record(colFilesystem, modeRepeated, append(
+			[]*bigquery.TableFieldSchema{field("device", typeString)},
+			integers("limit", "usage", "reads_completed", "writes_completed", "read_time", "write_time", "io_in_progress", "io_time", "weighted_io_time")...)...),
+	}
+	return &bigquery.TableSchema{
+		Fields: fields,
+	}
+}
 
-	// container major page fault
-	row[colMemoryContainerPgmajfault] = stats.Memory.ContainerData.Pgmajfault
+func memoryDataToRecord(data info.MemoryStatsMemoryData) map[string]interface{} {
+	return map[string]interface{}{
+		"pgfault":    data.Pgfault,
+		"pgmajfault": data.Pgmajfault,
+	}
+}
 
-	// hierarchical page fault
-	row[colMemoryHierarchicalPgfault] = stats.Memory.HierarchicalData.Pgfault
+func diskIoToRecord(stats map[string]uint64) map[string]interface{} {
+	ret := make(map[string]interface{}, len(diskIoOperations))
+	for _, op := range diskIoOperations {
+		ret[strings.ToLower(op)] = stats[op]
+	}
+	return ret
+}
 
-	// hierarchical major page fault
-	row[colMemoryHierarchicalPgmajfault] = stats.Memory.HierarchicalData.Pgmajfault
+type device struct {
+	major uint64
+	minor uint64
+}
 
-	// Network stats.
-	row[colRxBytes] = stats.Network.RxBytes
-	row[colRxErrors] = stats.Network.RxErrors
-	row[colTxBytes] = stats.Network.TxBytes
-	row[colTxErrors] = stats.Network.TxErrors
+type byDeviceNumber []device
 
-	// TODO(jnagal): Handle per-cpu stats.
+func (self byDeviceNumber) Len() int      { return len(self) }
+func (self byDeviceNumber) Swap(i, j int) { self[i], self[j] = self[j], self[i] }
+func (self byDeviceNumber) Less(i, j int) bool {
+	if self[i].major != self[j].major {
+		return self[i].major < self[j].major
+	}
+	return self[i].minor < self[j].minor
+}
 
-	return
+// Returns one record per device, sorted by device number.
+func diskIoToRecords(diskIo *info.DiskIoStats) []interface{} {
+	devices := make(map[device]map[string]interface{})
+	get := func(stats info.PerDiskStats) map[string]interface{} {
+		d := device{stats.Major, stats.Minor}
+		if _, ok := devices[d]; !ok {
+			devices[d] = map[string]interface{}{
+				"major": stats.Major,
+				"minor": stats.Minor,
+			}
+		}
+		return devices[d]
+	}
+	for _, stats := range diskIo.IoServiceBytes {
+		get(stats)["service_bytes"] = diskIoToRecord(stats.Stats)
+	}
+	for _, stats := range diskIo.IoServiced {
+		get(stats)["serviced"] = diskIoToRecord(stats.Stats)
+	}
+	keys := make([]device, 0, len(devices))
+	for d := range devices {
+		keys = append(keys, d)
+	}
+	sort.Sort(byDeviceNumber(keys))
+	records := make([]interface{}, 0, len(keys))
+	for _, d := range keys {
+		records = append(records, devices[d])
+	}
+	return records
 }
 
-func (self *bigqueryStorage) containerFilesystemStatsToRows(
+func (self *bigqueryStorage) containerStatsToRow(
 	ref info.ContainerReference,
 	stats *info.ContainerStats,
-) (rows []map[string]interface{}) {
-	for _, fsStat := range stats.Filesystem {
-		row := make(map[string]interface{}, 0)
-		row[colFsDevice] = fsStat.Device
-		row[colFsLimit] = fsStat.Limit
-		row[colFsUsage] = fsStat.Usage
-		rows = append(rows, row)
-	}
-	return rows
+) map[string]interface{} {
+	name := ref.Name
+	if len(ref.Aliases) > 0 {
+		name = ref.Aliases[0]
+	}
+	perCpu := make([]interface{}, 0, len(stats.Cpu.Usage.PerCpu))
+	for _, usage := range stats.Cpu.Usage.PerCpu {
+		perCpu = append(perCpu, usage)
+	}
+	filesystems := make([]interface{}, 0, len(stats.Filesystem))
+	for _, fs := range stats.Filesystem {
+		filesystems = append(filesystems, map[string]interface{}{
+			"device":           fs.Device,
+			"limit":            fs.Limit,
+			"usage":            fs.Usage,
+			"reads_completed":  fs.ReadsCompleted,
+			"writes_completed": fs.WritesCompleted,
+			"read_time":        fs.ReadTime,
+			"write_time":       fs.WriteTime,
+			"io_in_progress":   fs.IoInProgress,
+			"io_time":          fs.IoTime,
+			"weighted_io_time": fs.WeightedIoTime,
+		})
+	}
+	return map[string]interface{}{
+		colTimestamp:     stats.Timestamp,
+		colMachineName:   self.machineName,
+		colContainerName: name,
+		colCpu: map[string]interface{}{
+			"total":   stats.Cpu.Usage.Total,
+			"user":    stats.Cpu.Usage.User,
+			"system":  stats.Cpu.Usage.System,
+			"load":    stats.Cpu.Load,
+			"per_cpu": perCpu,
+		},
+		colMemory: map[string]interface{}{
+			"usage":             stats.Memory.Usage,
+			"working_set":       stats.Memory.WorkingSet,
+			"container_data":    memoryDataToRecord(stats.Memory.ContainerData),
+			"hierarchical_data": memoryDataToRecord(stats.Memory.HierarchicalData),
+		},
+		// The stats cover all the interfaces of the container, they are reported as a single
+		// record without interface name.
+		colNetwork: []interface{}{
+			map[string]interface{}{
+				"rx_bytes":   stats.Network.RxBytes,
+				"rx_packets": stats.Network.RxPackets,
+				"rx_errors":  stats.Network.RxErrors,
+				"rx_dropped": stats.Network.RxDropped,
+				"tx_bytes":   stats.Network.TxBytes,
+				"tx_packets": stats.Network.TxPackets,
+				"tx_errors":  stats.Network.TxErrors,
+				"tx_dropped": stats.Network.TxDropped,
+			},
+		},
+		colDiskIo:     diskIoToRecords(&stats.DiskIo),
+		colFilesystem: filesystems,
+	}
+}
+
+// Columns read back by RecentStats: the query flattens the nested fields into these columns.
+var readColumns = []struct {
+	name string
+	expr string
+	set  func(stats *info.ContainerStats, v uint64)
+}{
+	{"cpu_total", "cpu.total", func(s *info.ContainerStats, v uint64) { s.Cpu.Usage.Total = v }},
+	{"cpu_user", "cpu.user", func(s *info.ContainerStats, v uint64) { s.Cpu.Usage.User = v }},
+	{"cpu_system", "cpu.system", func(s *info.ContainerStats, v uint64) { s.Cpu.Usage.System = v }},
+	{"memory_usage", "memory.usage", func(s *info.ContainerStats, v uint64) { s.Memory.Usage = v }},
+	{"memory_working_set", "memory.working_set", func(s *info.ContainerStats, v uint64) { s.Memory.WorkingSet = v }},
+	{"memory_container_pgfault", "memory.container_data.pgfault", func(s *info.ContainerStats, v uint64) { s.Memory.ContainerData.Pgfault = v }},
+	{"memory_container_pgmajfault", "memory.container_data.pgmajfault", func(s *info.ContainerStats, v uint64) { s.Memory.ContainerData.Pgmajfault = v }},
+	{"memory_hierarchical_pgfault", "memory.hierarchical_data.pgfault", func(s *info.ContainerStats, v uint64) { s.Memory.HierarchicalData.Pgfault = v }},
+	{"memory_hierarchical_pgmajfault", "memory.hierarchical_data.pgmajfault", func(s *info.ContainerStats, v uint64) { s.Memory.HierarchicalData.Pgmajfault = v }},
+	{"rx_bytes", "SUM(network.rx_bytes) WITHIN RECORD", func(s *info.ContainerStats, v uint64) { s.Network.RxBytes = v }},
+	{"rx_errors", "SUM(network.rx_errors) WITHIN RECORD", func(s *info.ContainerStats, v uint64) { s.Network.RxErrors = v }},
+	{"tx_bytes", "SUM(network.tx_bytes) WITHIN RECORD", func(s *info.ContainerStats, v uint64) { s.Network.TxBytes = v }},
+	{"tx_errors", "SUM(network.tx_errors) WITHIN RECORD", func(s *info.ContainerStats, v uint64) { s.Network.TxErrors = v }},
 }
 
 func convertToUint64(v interface{}) (uint64, error) {
@@ -284,19 +332,33 @@ func convertToUint64(v interface{}) (uint64, error) {
 	return 0, fmt.Errorf("unknown type")
 }
 
-func (self *bigqueryStorage) valuesToContainerStats(columns []string, values []interface{}) (*info.ContainerStats, error) {
-	stats := &info.ContainerStats{
-		Filesystem: make([]info.FsStats, 0),
+// Query results hold timestamps as seconds since the epoch, in floating point notation.
+func convertToTime(v interface{}) (time.Time, error) {
+	switch x := v.(type) {
+	case time.Time:
+		return x, nil
+	case string:
+		seconds, err := strconv.ParseFloat(x, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Unix(0, int64(seconds*1e9)).UTC(), nil
 	}
-	var err error
+	return time.Time{}, fmt.Errorf("unknown type")
+}
+
+func (self *bigqueryStorage) valuesToContainerStats(columns []string, values []interface{}) (*info.ContainerStats, error) {
+	stats := &info.ContainerStats{}
 	for i, col := range columns {
 		v := values[i]
-		switch {
-		case col == colTimestamp:
-			if t, ok := v.(time.Time); ok {
-				stats.Timestamp = t
+		switch col {
+		case colTimestamp:
+			t, err := convertToTime(v)
+			if err != nil {
+				return nil, fmt.Errorf("column %v has invalid value %v: %v", col, v, err)
 			}
-		case col == colMachineName:
+			stats.Timestamp = t
+		case colMachineName:
 			if m, ok := v.(string); ok {
 				if m != self.machineName {
 					return nil, fmt.Errorf("different machine")
@@ -304,106 +366,117 @@ func (self *bigqueryStorage) valuesToContainerStats(columns []string, values []i
 			} else {
 				return nil, fmt.Errorf("machine name field is not a string: %v", v)
 			}
-		// Cumulative Cpu Usage
-		case col == colCpuCumulativeUsage:
-			stats.Cpu.Usage.Total, err = convertToUint64(v)
-		// Cumulative Cpu used by the system
-		case col == colCpuCumulativeUsageSystem:
-			stats.Cpu.Usage.System, err = convertToUint64(v)
-		// Cumulative Cpu Usage in user mode
-		case col == colCpuCumulativeUsageUser:
-			stats.Cpu.Usage.User, err = convertToUint64(v)
-		// Memory Usage
-		case col == colMemoryUsage:
-			stats.Memory.Usage, err = convertToUint64(v)
-		// Working set size
-		case col == colMemoryWorkingSet:
-			stats.Memory.WorkingSet, err = convertToUint64(v)
-		// container page fault
-		case col == colMemoryContainerPgfault:
-			stats.Memory.ContainerData.Pgfault, err = convertToUint64(v)
-		// container major page fault
-		case col == colMemoryContainerPgmajfault:
-			stats.Memory.ContainerData.Pgmajfault, err = convertToUint64(v)
-		// hierarchical page fault
-		case col == colMemoryHierarchicalPgfault:
-			stats.Memory.HierarchicalData.Pgfault, err = convertToUint64(v)
-		// hierarchical major page fault
-		case col == colMemoryHierarchicalPgmajfault:
-			stats.Memory.HierarchicalData.Pgmajfault, err = convertToUint64(v)
-		case col == colRxBytes:
-			stats.Network.RxBytes, err = convertToUint64(v)
-		case col == colRxErrors:
-			stats.Network.RxErrors, err = convertToUint64(v)
-		case col == colTxBytes:
-			stats.Network.TxBytes, err = convertToUint64(v)
-		case col == colTxErrors:
-			stats.Network.TxErrors, err = convertToUint64(v)
-		case col == colFsDevice:
-			device, ok := v.(string)
-			if !ok {
-				return nil, fmt.Errorf("filesystem name field is not a string: %+v", v)
-			}
-			if len(stats.Filesystem) == 0 {
-				stats.Filesystem = append(stats.Filesystem, info.FsStats{Device: device})
-			} else {
-				stats.Filesystem[0].Device = device
-			}
-		case col == colFsLimit:
-			limit, err := convertToUint64(v)
-			if err != nil {
-				return nil, fmt.Errorf("filesystem limit field %+v invalid: %s", v, err)
-			}
-			if len(stats.Filesystem) == 0 {
-				stats.Filesystem = append(stats.Filesystem, info.FsStats{Limit: limit})
-			} else {
-				stats.Filesystem[0].Limit = limit
-			}
-		case col == colFsUsage:
-			usage, err := convertToUint64(v)
-			if err != nil {
-				return nil, fmt.Errorf("filesystem usage field %+v invalid: %s", v, err)
-			}
-			if len(stats.Filesystem) == 0 {
-				stats.Filesystem = append(stats.Filesystem, info.FsStats{Usage: usage})
-			} else {
-				stats.Filesystem[0].Usage = usage
+		default:
+			for _, c := range readColumns {
+				if c.name != col {
+					continue
+				}
+				value, err := convertToUint64(v)
+				if err != nil {
+					return nil, fmt.Errorf("column %v has invalid value %v: %v", col, v, err)
+				}
+				c.set(stats, value)
 			}
 		}
-		if err != nil {
-			return nil, fmt.Errorf("column %v has invalid value %v: %v", col, v, err)
-		}
 	}
 	return stats, nil
 }
 
+// Identifies the row of a stats sample, so BigQuery drops duplicates of retried inserts.
+func insertId(ref info.ContainerReference, stats *info.ContainerStats) string {
+	return fmt.Sprintf("%s/%d", ref.Name, stats.Timestamp.UnixNano())
+}
+
 func (self *bigqueryStorage) AddStats(ref info.ContainerReference, stats *info.ContainerStats) error {
 	if stats == nil {
 		return nil
 	}
-	rows := make([]map[string]interface{}, 0)
-	rows = append(rows, self.containerStatsToRows(ref, stats))
-	rows = append(rows, self.containerFilesystemStatsToRows(ref, stats)...)
-	for _, row := range rows {
-		err := self.client.InsertRow(row)
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.rows = append(self.rows, self.containerStatsToRow(ref, stats))
+	self.insertIds = append(self.insertIds, insertId(ref, stats))
+	if len(self.rows) < self.batchSize && time.Since(self.lastFlush) < self.bufferDuration {
+		return nil
+	}
+	return self.flush()
+}
+
+// Streams the buffered rows to BigQuery. Must be called with the lock held.
+func (self *bigqueryStorage) flush() error {
+	rows, insertIds := self.rows, self.insertIds
+	self.rows, self.insertIds = nil, nil
+	self.lastFlush = time.Now()
+	err := self.rotateTable(self.lastFlush)
+	if err != nil {
+		return err
+	}
+	for len(rows) > 0 {
+		n := len(rows)
+		if n > self.batchSize {
+			n = self.batchSize
+		}
+		err := self.client.InsertRows(rows[:n], insertIds[:n])
 		if err != nil {
 			return err
 		}
+		rows, insertIds = rows[n:], insertIds[n:]
 	}
 	return nil
 }
 
-func (self *bigqueryStorage) getRecentRows(containerName string, numRows int) ([]string, [][]interface{}, error) {
-	tableName, err := self.client.GetTableName()
+// Switches to the daily table of the given time, creating it if needed. Must be called with the
+// lock held.
+func (self *bigqueryStorage) rotateTable(now time.Time) error {
+	if !self.dailyTables {
+		return nil
+	}
+	day := now.UTC().Truncate(24 * time.Hour)
+	if day.Equal(self.tableDay) {
+		return nil
+	}
+	var expiration time.Time
+	if self.expiration > 0 {
+		expiration = day.Add(24 * time.Hour).Add(self.expiration)
+	}
+	err := self.client.CreateTableWithExpiration(dailyTableName(self.tableName, day), self.GetSchema(), expiration)
 	if err != nil {
-		return nil, nil, err
+		return err
 	}
+	self.tableDay = day
+	return nil
+}
 
-	query := fmt.Sprintf("SELECT * FROM %v WHERE %v='%v' and %v='%v'", tableName, colContainerName, containerName, colMachineName, self.machineName)
+func dailyTableName(tableName string, day time.Time) string {
+	return fmt.Sprintf("%s_%s", tableName, day.Format("20060102"))
+}
+
+// Returns the query of the most recent rows of a container.
+func (self *bigqueryStorage) recentRowsQuery(tableName, containerName string, numRows int) string {
+	columns := []string{colTimestamp, colMachineName}
+	for _, c := range readColumns {
+		columns = append(columns, fmt.Sprintf("%s AS %s", c.expr, c.name))
+	}
+	from := tableName
+	if self.dailyTables {
+		// Also read yesterday's table, so recent stats are available right after midnight.
+		prefix := strings.TrimSuffix(tableName, self.tableDay.Format("20060102"))
+		from = fmt.Sprintf("(TABLE_DATE_RANGE(%s, DATE_ADD(CURRENT_TIMESTAMP(), -1, 'DAY'), CURRENT_TIMESTAMP()))", prefix)
+	}
+	query := fmt.Sprintf("SELECT %s FROM %v WHERE %v='%v' and %v='%v' ORDER BY %v DESC", strings.Join(columns, ", "), from, colContainerName, containerName, colMachineName, self.machineName, colTimestamp)
 	if numRows > 0 {
 		query = fmt.Sprintf("%v LIMIT %v", query, numRows)
 	}
+	return query
+}
 
+func (self *bigqueryStorage) getRecentRows(containerName string, numRows int) ([]string, [][]interface{}, error) {
+	self.lock.Lock()
+	tableName, err := self.client.GetTableName()
+	query := self.recentRowsQuery(tableName, containerName, numRows)
+	self.lock.Unlock()
+	if err != nil {
+		return nil, nil, err
+	}
 	return self.client.Query(query)
 }
 
@@ -415,24 +488,44 @@ func (self *bigqueryStorage) RecentStats(containerName string, numStats int) ([]
 	if err != nil {
 		return nil, err
 	}
-	statsList := make([]*info.ContainerStats, 0, len(rows))
-	for _, row := range rows {
+	// Rows are the most recent first.
+	statsList := make([]*info.ContainerStats, len(rows))
+	for i, row := range rows {
 		stats, err := self.valuesToContainerStats(header, row)
 		if err != nil {
 			return nil, err
 		}
-		if stats == nil {
-			continue
-		}
-		statsList = append(statsList, stats)
+		statsList[len(rows)-1-i] = stats
 	}
 	return statsList, nil
 }
 
 func (self *bigqueryStorage) Close() error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	var err error
+	if len(self.rows) > 0 {
+		err = self.flush()
+	}
 	self.client.Close()
 	self.client = nil
-	return nil
+	return err
+}
+
+func init() {
+	storage.RegisterStorageDriver("bigquery", newStorage)
+}
+
+func newStorage() (storage.StorageDriver, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+	return New(
+		hostname,
+		*storage.ArgDbTable,
+		*storage.ArgDbName,
+	)
 }
 
 // Create a new bigquery storage driver.
@@ -443,6 +536,9 @@ func New(machineName,
 	datasetId,
 	tableName string,
 ) (storage.StorageDriver, error) {
+	if *argTableExpiration > 0 && !*argDailyTables {
+		return nil, fmt.Errorf("-bq_table_expiration requires -bq_daily_tables")
+	}
 	bqClient, err := client.NewClient()
 	if err != nil {
 		return nil, err
@@ -452,14 +548,31 @@ func New(machineName,
 		return nil, err
 	}
 
-	ret := &bigqueryStorage{
-		client:      bqClient,
-		machineName: machineName,
+	ret := newBigqueryStorage(bqClient, machineName, tableName)
+	if ret.dailyTables {
+		err = ret.rotateTable(time.Now())
+	} else {
+		err = bqClient.CreateTable(tableName, ret.GetSchema())
 	}
-	schema := ret.GetSchema()
-	err = bqClient.CreateTable(tableName, schema)
 	if err != nil {
 		return nil, err
 	}
 	return ret, nil
 }
+
+func newBigqueryStorage(bqClient *client.Client, machineName, tableName string) *bigqueryStorage {
+	batchSize := *argBatchSize
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	return &bigqueryStorage{
+		client:         bqClient,
+		machineName:    machineName,
+		tableName:      tableName,
+		dailyTables:    *argDailyTables,
+		expiration:     *argTableExpiration,
+		batchSize:      batchSize,
+		bufferDuration: *storage.ArgDbBufferDuration,
+		lastFlush:      time.Now(),
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bigquery
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/info"
+)
+
+func testStorage() *bigqueryStorage {
+	return newBigqueryStorage(nil, "host", "stats")
+}
+
+// Checks every key of the row is in the schema, recursing into records.
+func checkRowMatchesSchema(t *testing.T, path string, row map[string]interface{}, fields map[string]bool) {
+	for key := range row {
+		if !fields[path+key] {
+			t.Errorf("field %q of the row is not in the schema", path+key)
+		}
+		switch v := row[key].(type) {
+		case map[string]interface{}:
+			checkRowMatchesSchema(t, path+key+".", v, fields)
+		case []interface{}:
+			for _, elem := range v {
+				if record, ok := elem.(map[string]interface{}); ok {
+					checkRowMatchesSchema(t, path+key+".", record, fields)
+				}
+			}
+		}
+	}
+}
+
+func TestContainerStatsToRow(t *testing.T) {
+	self := testStorage()
+	stats := &info.ContainerStats{
+		Timestamp: time.Unix(1400000000, 0),
+		Filesystem: []info.FsStats{
+			{Device: "/dev/sda1", Limit: 100, Usage: 10},
+		},
+	}
+	stats.Cpu.Usage.Total = 300
+	stats.Cpu.Usage.PerCpu = []uint64{100, 200}
+	stats.Memory.Usage = 1024
+	stats.Network.RxBytes = 5
+	stats.DiskIo.IoServiceBytes = []info.PerDiskStats{
+		{Major: 8, Minor: 16, Stats: map[string]uint64{"Read": 1, "Total": 1}},
+		{Major: 8, Minor: 0, Stats: map[string]uint64{"Write": 2, "Total": 2}},
+	}
+	stats.DiskIo.IoServiced = []info.PerDiskStats{
+		{Major: 8, Minor: 0, Stats: map[string]uint64{"Write": 1, "Total": 1}},
+	}
+	ref := info.ContainerReference{Name: "/docker/abc", Aliases: []string{"web"}}
+	row := self.containerStatsToRow(ref, stats)
+
+	fields := make(map[string]bool)
+	for _, f := range self.GetSchema().Fields {
+		fields[f.Name] = true
+		for _, sub := range f.Fields {
+			fields[f.Name+"."+sub.Name] = true
+			for _, subsub := range sub.Fields {
+				fields[f.Name+"."+sub.Name+"."+subsub.Name] = true
+			}
+		}
+	}
+	checkRowMatchesSchema(t, "", row, fields)
+
+	if row[colContainerName] != "web" || row[colMachineName] != "host" {
+		t.Errorf("unexpected names in row %+v", row)
+	}
+	cpu := row[colCpu].(map[string]interface{})
+	if !reflect.DeepEqual(cpu["per_cpu"], []interface{}{uint64(100), uint64(200)}) {
+		t.Errorf("unexpected per-cpu usage %+v", cpu["per_cpu"])
+	}
+	diskIo := row[colDiskIo].([]interface{})
+	if len(diskIo) != 2 {
+		t.Fatalf("expected 2 devices, got %+v", diskIo)
+	}
+	first := diskIo[0].(map[string]interface{})
+	if first["minor"] != uint64(0) || first["serviced"].(map[string]interface{})["write"] != uint64(1) || first["service_bytes"].(map[string]interface{})["write"] != uint64(2) {
+		t.Errorf("unexpected first device %+v", first)
+	}
+	second := diskIo[1].(map[string]interface{})
+	if _, ok := second["serviced"]; ok {
+		t.Errorf("unexpected serviced stats for the second device %+v", second)
+	}
+	if fs := row[colFilesystem].([]interface{}); len(fs) != 1 || fs[0].(map[string]interface{})["limit"] != uint64(100) {
+		t.Errorf("unexpected filesystems %+v", fs)
+	}
+}
+
+func TestValuesToContainerStats(t *testing.T) {
+	self := testStorage()
+	columns := []string{colTimestamp, colMachineName, "cpu_total", "memory_usage", "rx_bytes"}
+	stats, err := self.valuesToContainerStats(columns, []interface{}{"1.4E9", "host", "300", "1024", "5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stats.Timestamp.Equal(time.Unix(1400000000, 0)) {
+		t.Errorf("unexpected timestamp %v", stats.Timestamp)
+	}
+	if stats.Cpu.Usage.Total != 300 || stats.Memory.Usage != 1024 || stats.Network.RxBytes != 5 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	_, err = self.valuesToContainerStats(columns, []interface{}{"1.4E9", "other", "300", "1024", "5"})
+	if err == nil {
+		t.Error("expected an error for stats of another machine")
+	}
+}
+
+func TestRecentRowsQuery(t *testing.T) {
+	self := testStorage()
+	self.dailyTables = true
+	self.tableDay = time.Date(2014, 5, 13, 0, 0, 0, 0, time.UTC)
+	query := self.recentRowsQuery("dataset.stats_20140513", "/docker/abc", 10)
+	for _, expected := range []string{
+		"cpu.total AS cpu_total",
+		"TABLE_DATE_RANGE(dataset.stats_,",
+		"container_name='/docker/abc'",
+		"ORDER BY timestamp DESC LIMIT 10",
+	} {
+		if !strings.Contains(query, expected) {
+			t.Errorf("expected %q in query %q", expected, query)
+		}
+	}
+
+	self.dailyTables = false
+	query = self.recentRowsQuery("dataset.stats", "/docker/abc", 0)
+	if !strings.Contains(query, "FROM dataset.stats WHERE") || strings.Contains(query, "LIMIT") {
+		t.Errorf("unexpected query %q", query)
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"strings"
+	"time"
 
 	"code.google.com/p/goauth2/oauth"
 	"code.google.com/p/goauth2/oauth/jwt"
@@ -170,46 +171,111 @@ func (c *Client) CreateDataset(datasetId string) error {
 // Create a table with provided table ID and schema.
 // Schema is currently not updated if the table already exists.
 func (c *Client) CreateTable(tableId string, schema *bigquery.TableSchema) error {
-	if c.service == nil || c.datasetId == "" {
+	return c.CreateTableWithExpiration(tableId, schema, time.Time{})
+}
+
+// Create a table that BigQuery deletes at the given time. A zero time keeps the table forever.
+// The expiration is only set when the table is created.
+func (c *Client) CreateTableWithExpiration(tableId string, schema *bigquery.TableSchema, expiration time.Time) error {
+	service, err := c.getService()
+	if err != nil || c.datasetId == "" {
 		return fmt.Errorf("No dataset created")
 	}
-	_, err := c.service.Tables.Get(*projectId, c.datasetId, tableId).Do()
-	if err != nil {
+	existing, err := service.Tables.Get(*projectId, c.datasetId, tableId).Do()
+	if err == nil {
+		// Fields can only be added to an existing schema, e.g. by a newer version of the driver.
+		if existing.Schema != nil && extendSchema(existing.Schema, schema) {
+			_, err = service.Tables.Patch(*projectId, c.datasetId, tableId, &bigquery.Table{Schema: existing.Schema}).Do()
+			if err != nil {
+				return fmt.Errorf("failed to extend the schema of table %q: %v", tableId, err)
+			}
+		}
+	} else {
 		// Create a new table.
-		_, err := c.service.Tables.Insert(*projectId, c.datasetId, &bigquery.Table{
+		table := &bigquery.Table{
 			Schema: schema,
 			TableReference: &bigquery.TableReference{
 				DatasetId: c.datasetId,
 				ProjectId: *projectId,
 				TableId:   tableId,
 			},
-		}).Do()
+		}
+		if !expiration.IsZero() {
+			table.ExpirationTime = expiration.UnixNano() / int64(time.Millisecond)
+		}
+		_, err := service.Tables.Insert(*projectId, c.datasetId, table).Do()
 		if err != nil {
 			return err
 		}
 	}
-	// TODO(jnagal): Update schema if it has changed. We can only extend existing schema.
 	c.tableId = tableId
 	return nil
 }
 
+// Adds the fields of wanted missing from schema to it, recursing into records. Returns whether
+// fields were added. Added fields must not be required, as existing rows do not have them.
+func extendSchema(schema *bigquery.TableSchema, wanted *bigquery.TableSchema) bool {
+	var changed bool
+	schema.Fields, changed = extendFields(schema.Fields, wanted.Fields)
+	return changed
+}
+
+func extendFields(fields, wanted []*bigquery.TableFieldSchema) ([]*bigquery.TableFieldSchema, bool) {
+	changed := false
+	for _, w := range wanted {
+		var existing *bigquery.TableFieldSchema
+		for _, f := range fields {
+			if f.Name == w.Name {
+				existing = f
+				break
+			}
+		}
+		if existing == nil {
+			added := *w
+			if added.Mode == "REQUIRED" {
+				added.Mode = "NULLABLE"
+			}
+			fields = append(fields, &added)
+			changed = true
+			continue
+		}
+		if existing.Type == "RECORD" && w.Type == "RECORD" {
+			var recordChanged bool
+			existing.Fields, recordChanged = extendFields(existing.Fields, w.Fields)
+			changed = changed || recordChanged
+		}
+	}
+	return fields, changed
+}
+
 // Add a row to the connected table.
 func (c *Client) InsertRow(rowData map[string]interface{}) error {
+	return c.InsertRows([]map[string]interface{}{rowData}, nil)
+}
+
+// Streams rows to the connected table in a single request. If set, insertIds holds a unique
+// identifier of each row, BigQuery ignores rows with an identifier it recently received so
+// retries do not duplicate rows.
+func (c *Client) InsertRows(rowData []map[string]interface{}, insertIds []string) error {
 	service, _ := c.getService()
 	if service == nil || c.datasetId == "" || c.tableId == "" {
 		return fmt.Errorf("Table not setup to add rows")
 	}
-	jsonRows := make(map[string]bigquery.JsonValue)
-	for key, value := range rowData {
-		jsonRows[key] = bigquery.JsonValue(value)
-	}
-	rows := []*bigquery.TableDataInsertAllRequestRows{
-		{
-			Json: jsonRows,
-		},
+	rows := make([]*bigquery.TableDataInsertAllRequestRows, 0, len(rowData))
+	for i, data := range rowData {
+		jsonRow := make(map[string]bigquery.JsonValue)
+		for key, value := range data {
+			jsonRow[key] = bigquery.JsonValue(value)
+		}
+		row := &bigquery.TableDataInsertAllRequestRows{
+			Json: jsonRow,
+		}
+		if i < len(insertIds) {
+			row.InsertId = insertIds[i]
+		}
+		rows = append(rows, row)
 	}
 
-	// TODO(jnagal): Batch insert requests.
 	insertRequest := &bigquery.TableDataInsertAllRequest{Rows: rows}
 
 	result, err := service.Tabledata.InsertAll(*projectId, c.datasetId, c.tableId, insertRequest).Do()
@@ -0,0 +1,362 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package disk implements a storage driver persisting stats to the local disk so they survive
+// restarts of cAdvisor and history beyond the in-memory buffer can be served.
+//
+// The stats of each container are appended to segment files, each holding the stats of a
+// fixed time span. Whole segments are deleted once they are older than the retention.
+package disk
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/cadvisor/info"
+	"github.com/google/cadvisor/storage"
+)
+
+var argDir = flag.String("storage_driver_disk_dir", "/var/lib/cadvisor/stats", "directory the disk storage driver keeps stats in")
+var argRetention = flag.Duration("storage_driver_disk_retention", 24*time.Hour, "how long the disk storage driver keeps stats")
+var argSegmentDuration = flag.Duration("storage_driver_disk_segment_duration", time.Hour, "time span of the stats in each file of the disk storage driver, files are deleted as a whole once older than the retention")
+
+const (
+	segmentSuffix = ".log"
+
+	// Each record is the length and CRC-32 of its payload followed by the JSON encoded stats.
+	recordHeaderSize = 8
+	// Records larger than this are considered corrupted.
+	maxRecordSize = 16 << 20
+	// Most stats read for a single request, even when all are requested, so a long retention is
+	// not read into memory at once.
+	maxReadStats = 10000
+)
+
+func init() {
+	storage.RegisterStorageDriver("disk", newStorage)
+}
+
+type diskStorage struct {
+	dir             string
+	retention       time.Duration
+	segmentDuration time.Duration
+
+	lock       sync.Mutex
+	containers map[string]*containerLog
+	lastPrune  time.Time
+}
+
+// Segment stats of a container are currently appended to.
+type containerLog struct {
+	file         *os.File
+	segmentStart time.Time
+}
+
+func newStorage() (storage.StorageDriver, error) {
+	return New(*argDir, *argRetention, *argSegmentDuration)
+}
+
+// Creates a disk storage driver keeping the stats of the last retention in dir.
+func New(dir string, retention, segmentDuration time.Duration) (*diskStorage, error) {
+	if retention <= 0 || segmentDuration <= 0 {
+		return nil, fmt.Errorf("retention and segment duration of the disk storage driver must be positive")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	ret := &diskStorage{
+		dir:             dir,
+		retention:       retention,
+		segmentDuration: segmentDuration,
+		containers:      make(map[string]*containerLog),
+	}
+	// Drop what expired while cAdvisor was not running.
+	ret.lock.Lock()
+	ret.prune(time.Now())
+	ret.lock.Unlock()
+	return ret, nil
+}
+
+func (self *diskStorage) containerDir(name string) string {
+	return filepath.Join(self.dir, url.QueryEscape(name))
+}
+
+func segmentName(start time.Time) string {
+	return strconv.FormatInt(start.Unix(), 10) + segmentSuffix
+}
+
+// Returns the start times of the segments in dir, newest first.
+func listSegments(dir string) ([]int64, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	starts := make([]int64, 0, len(files))
+	for _, f := range files {
+		if !strings.HasSuffix(f.Name(), segmentSuffix) {
+			continue
+		}
+		start, err := strconv.ParseInt(strings.TrimSuffix(f.Name(), segmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		starts = append(starts, start)
+	}
+	sort.Sort(sort.Reverse(int64Slice(starts)))
+	return starts, nil
+}
+
+type int64Slice []int64
+
+func (self int64Slice) Len() int           { return len(self) }
+func (self int64Slice) Less(i, j int) bool { return self[i] < self[j] }
+func (self int64Slice) Swap(i, j int)      { self[i], self[j] = self[j], self[i] }
+
+func (self *diskStorage) AddStats(ref info.ContainerReference, stats *info.ContainerStats) error {
+	if stats == nil {
+		return nil
+	}
+	payload, err := json.Marshal(stats)
+	if err != nil {
+		return err
+	}
+	record := make([]byte, recordHeaderSize+len(payload))
+	binary.LittleEndian.PutUint32(record[0:], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(record[4:], crc32.ChecksumIEEE(payload))
+	copy(record[recordHeaderSize:], payload)
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	now := time.Now()
+	if now.Sub(self.lastPrune) > self.segmentDuration {
+		self.prune(now)
+	}
+	log, err := self.segment(ref.Name, stats.Timestamp.Truncate(self.segmentDuration))
+	if err != nil {
+		return err
+	}
+	if _, err := log.file.Write(record); err != nil {
+		return fmt.Errorf("failed to write stats of %q to disk: %v", ref.Name, err)
+	}
+	return nil
+}
+
+// Returns the log of the container, switched to the segment starting at start.
+func (self *diskStorage) segment(name string, start time.Time) (*containerLog, error) {
+	log, ok := self.containers[name]
+	if ok && log.segmentStart.Equal(start) {
+		return log, nil
+	}
+	dir := self.containerDir(name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(filepath.Join(dir, segmentName(start)), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		log.file.Close()
+	}
+	log = &containerLog{
+		file:         file,
+		segmentStart: start,
+	}
+	self.containers[name] = log
+	return log, nil
+}
+
+// Deletes the expired segments and the directories of containers left without any.
+// Must be called with the lock held.
+func (self *diskStorage) prune(now time.Time) {
+	self.lastPrune = now
+	dirs, err := ioutil.ReadDir(self.dir)
+	if err != nil {
+		glog.Errorf("Failed to list stats on disk: %v", err)
+		return
+	}
+	expiry := now.Add(-self.retention)
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		dir := filepath.Join(self.dir, d.Name())
+		starts, err := listSegments(dir)
+		if err != nil {
+			continue
+		}
+		remaining := len(starts)
+		for _, start := range starts {
+			if time.Unix(start, 0).Add(self.segmentDuration).After(expiry) {
+				continue
+			}
+			if err := os.Remove(filepath.Join(dir, segmentName(time.Unix(start, 0)))); err != nil {
+				glog.Errorf("Failed to delete expired stats: %v", err)
+				continue
+			}
+			remaining--
+		}
+		if remaining > 0 {
+			continue
+		}
+		if name, err := url.QueryUnescape(d.Name()); err == nil {
+			if log, ok := self.containers[name]; ok {
+				log.file.Close()
+				delete(self.containers, name)
+			}
+		}
+		os.Remove(dir)
+	}
+}
+
+// Returns the offsets of the complete records in the segment. A torn write at the end of the
+// segment is ignored.
+func recordOffsets(file *os.File) ([]int64, error) {
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := fileInfo.Size()
+	var offsets []int64
+	header := make([]byte, recordHeaderSize)
+	for offset := int64(0); offset+recordHeaderSize <= size; {
+		if _, err := file.ReadAt(header, offset); err != nil {
+			return nil, err
+		}
+		length := int64(binary.LittleEndian.Uint32(header))
+		if length > maxRecordSize || offset+recordHeaderSize+length > size {
+			break
+		}
+		offsets = append(offsets, offset)
+		offset += recordHeaderSize + length
+	}
+	return offsets, nil
+}
+
+func readRecord(file *os.File, offset int64) (*info.ContainerStats, error) {
+	header := make([]byte, recordHeaderSize)
+	if _, err := file.ReadAt(header, offset); err != nil {
+		return nil, err
+	}
+	payload := make([]byte, binary.LittleEndian.Uint32(header))
+	if _, err := file.ReadAt(payload, offset+recordHeaderSize); err != nil && err != io.EOF {
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(header[4:]) {
+		return nil, fmt.Errorf("corrupted record at offset %d of %q", offset, file.Name())
+	}
+	stats := &info.ContainerStats{}
+	if err := json.Unmarshal(payload, stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// Reads up to numStats of the most recent stats of the segment, all of them if numStats < 0.
+// Stats are returned oldest first.
+func readSegment(path string, numStats int) ([]*info.ContainerStats, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	offsets, err := recordOffsets(file)
+	if err != nil {
+		return nil, err
+	}
+	if numStats >= 0 && len(offsets) > numStats {
+		offsets = offsets[len(offsets)-numStats:]
+	}
+	ret := make([]*info.ContainerStats, 0, len(offsets))
+	for _, offset := range offsets {
+		stats, err := readRecord(file, offset)
+		if err != nil {
+			glog.Warningf("Skipping stats on disk: %v", err)
+			continue
+		}
+		ret = append(ret, stats)
+	}
+	return ret, nil
+}
+
+func (self *diskStorage) RecentStats(containerName string, numStats int) ([]*info.ContainerStats, error) {
+	return self.History(containerName, numStats)
+}
+
+// Reads the stats from the newest segments until enough are found, at most maxReadStats.
+func (self *diskStorage) History(containerName string, numStats int) ([]*info.ContainerStats, error) {
+	if numStats == 0 {
+		return nil, nil
+	}
+	if numStats < 0 || numStats > maxReadStats {
+		numStats = maxReadStats
+	}
+	dir := self.containerDir(containerName)
+	starts, err := listSegments(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no stats on disk for container %q", containerName)
+		}
+		return nil, err
+	}
+	expiry := time.Now().Add(-self.retention)
+	var ret []*info.ContainerStats
+	for _, start := range starts {
+		stats, err := readSegment(filepath.Join(dir, segmentName(time.Unix(start, 0))), numStats-len(ret))
+		if err != nil {
+			if os.IsNotExist(err) {
+				// Pruned while reading.
+				continue
+			}
+			return nil, err
+		}
+		ret = append(stats, ret...)
+		if len(ret) >= numStats {
+			break
+		}
+	}
+	// Segments partially expire, drop their stats older than the retention.
+	i := 0
+	for i < len(ret) && ret[i].Timestamp.Before(expiry) {
+		i++
+	}
+	return ret[i:], nil
+}
+
+func (self *diskStorage) Close() error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	var lastErr error
+	for name, log := range self.containers {
+		if err := log.file.Close(); err != nil {
+			lastErr = err
+		}
+		delete(self.containers, name)
+	}
+	return lastErr
+}
@@ -0,0 +1,164 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package disk
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/info"
+	"github.com/google/cadvisor/storage/test"
+)
+
+type diskTestStorageDriver struct {
+	*diskStorage
+}
+
+func (self *diskTestStorageDriver) StatsEq(a, b *info.ContainerStats) bool {
+	return test.DefaultStatsEq(a, b)
+}
+
+func runStorageTest(f func(test.TestStorageDriver, *testing.T), t *testing.T) {
+	dir, err := ioutil.TempDir("", "cadvisor-disk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	driver, err := New(dir, time.Hour, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f(&diskTestStorageDriver{driver}, t)
+}
+
+func TestRetrievePartialRecentStats(t *testing.T) {
+	runStorageTest(test.StorageDriverTestRetrievePartialRecentStats, t)
+}
+
+func TestRetrieveAllRecentStats(t *testing.T) {
+	runStorageTest(test.StorageDriverTestRetrieveAllRecentStats, t)
+}
+
+func TestNoRecentStats(t *testing.T) {
+	runStorageTest(test.StorageDriverTestNoRecentStats, t)
+}
+
+func TestRetrieveZeroStats(t *testing.T) {
+	runStorageTest(test.StorageDriverTestRetrieveZeroRecentStats, t)
+}
+
+func addStats(t *testing.T, driver *diskStorage, name string, timestamps ...time.Time) {
+	for i, timestamp := range timestamps {
+		stats := &info.ContainerStats{Timestamp: timestamp}
+		stats.Memory.Usage = uint64(i)
+		if err := driver.AddStats(info.ContainerReference{Name: name}, stats); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestStatsSurviveRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cadvisor-disk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	driver, err := New(dir, time.Hour, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addStats(t, driver, "/docker/a", now.Add(-3*time.Minute), now.Add(-2*time.Minute), now.Add(-time.Minute))
+	driver.Close()
+
+	// A torn write at the end of the last segment is ignored.
+	segment := filepath.Join(driver.containerDir("/docker/a"), segmentName(now.Add(-time.Minute).Truncate(time.Minute)))
+	f, err := os.OpenFile(segment, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{200, 0, 0, 0, 1})
+	f.Close()
+
+	driver, err = New(dir, time.Hour, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer driver.Close()
+	addStats(t, driver, "/docker/a", now)
+	stats, err := driver.RecentStats("/docker/a", -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 4 {
+		t.Fatalf("expected 4 stats, got %d", len(stats))
+	}
+	for i := 1; i < len(stats); i++ {
+		if !stats[i-1].Timestamp.Before(stats[i].Timestamp) {
+			t.Errorf("stats are not sorted by time: %v", stats)
+		}
+	}
+	stats, err = driver.RecentStats("/docker/a", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 2 || !stats[1].Timestamp.Equal(now) {
+		t.Errorf("expected the 2 most recent stats, got %v", stats)
+	}
+}
+
+func TestExpiredStatsAreDeleted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cadvisor-disk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	driver, err := New(dir, time.Hour, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addStats(t, driver, "/old", now.Add(-3*time.Hour))
+	addStats(t, driver, "/new", now.Add(-3*time.Hour), now)
+	stats, err := driver.RecentStats("/new", -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 1 {
+		t.Errorf("expected expired stats to be skipped, got %d stats", len(stats))
+	}
+	driver.Close()
+
+	driver, err = New(dir, time.Hour, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer driver.Close()
+	if _, err := os.Stat(driver.containerDir("/old")); !os.IsNotExist(err) {
+		t.Errorf("expected the stats of /old to be deleted: %v", err)
+	}
+	starts, err := listSegments(driver.containerDir("/new"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(starts) != 1 {
+		t.Errorf("expected a single segment left, got %v", starts)
+	}
+}
@@ -0,0 +1,295 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package edge
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/info"
+)
+
+type message struct {
+	topic   string
+	payload []byte
+}
+
+func listen(t *testing.T) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+// Accepts a single client, checks its CONNECT and acknowledges QoS 1 publishes.
+func fakeMqttBroker(t *testing.T, l net.Listener, messages chan<- message) {
+	conn, err := l.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	packetType, body, err := readMqttPacket(reader)
+	if err != nil || packetType != mqttConnect {
+		t.Errorf("expected CONNECT, got type %d: %v", packetType, err)
+		return
+	}
+	// Protocol name, level, flags and keep alive, followed by the client identifier.
+	if string(body[2:6]) != "MQTT" || body[6] != 4 {
+		t.Errorf("unexpected protocol in CONNECT: %q", body[:7])
+	}
+	if clientId := string(body[12:]); clientId != "test-client" {
+		t.Errorf("expected client identifier test-client, got %q", clientId)
+	}
+	conn.Write(mqttPacket(mqttConnack<<4, []byte{0, 0}))
+	for {
+		header, err := reader.ReadByte()
+		if err != nil {
+			return
+		}
+		reader.UnreadByte()
+		packetType, body, err := readMqttPacket(reader)
+		if err != nil || packetType == mqttDisconnect {
+			return
+		}
+		topicLen := int(binary.BigEndian.Uint16(body))
+		topic := string(body[2 : 2+topicLen])
+		body = body[2+topicLen:]
+		if qos := (header >> 1) & 3; qos == 1 {
+			conn.Write(mqttPacket(mqttPuback<<4, body[:2]))
+			body = body[2:]
+		}
+		messages <- message{topic, body}
+	}
+}
+
+// Accepts a single client, checks its CONNECT, answers its PING and acknowledges publishes.
+func fakeNatsServer(t *testing.T, l net.Listener, messages chan<- message) {
+	conn, err := l.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	fmt.Fprintf(conn, "INFO {\"server_id\":\"test\",\"max_payload\":1048576}\r\n")
+	line, err := readNatsLine(reader)
+	if err != nil || !strings.HasPrefix(line, "CONNECT ") {
+		t.Errorf("expected CONNECT, got %q: %v", line, err)
+		return
+	}
+	var options natsConnectOptions
+	if err := json.Unmarshal([]byte(line[len("CONNECT "):]), &options); err != nil {
+		t.Errorf("malformed CONNECT %q: %v", line, err)
+		return
+	}
+	if options.AuthToken != "secret" {
+		t.Errorf("expected auth token secret, got %q", options.AuthToken)
+	}
+	if options.Verbose {
+		fmt.Fprintf(conn, "+OK\r\n")
+	}
+	for {
+		line, err := readNatsLine(reader)
+		if err != nil {
+			return
+		}
+		if line == "PING" {
+			fmt.Fprintf(conn, "PONG\r\n")
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 3 || fields[0] != "PUB" {
+			t.Errorf("unexpected line %q", line)
+			return
+		}
+		size, _ := strconv.Atoi(fields[2])
+		payload := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, payload); err != nil {
+			return
+		}
+		if options.Verbose {
+			fmt.Fprintf(conn, "+OK\r\n")
+		}
+		messages <- message{fields[1], payload[:size]}
+	}
+}
+
+func receive(t *testing.T, messages <-chan message) message {
+	select {
+	case m := <-messages:
+		return m
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message published")
+	}
+	return message{}
+}
+
+func testStats() (info.ContainerReference, *info.ContainerStats) {
+	ref := info.ContainerReference{
+		Name:    "/docker/abcdef",
+		Aliases: []string{"web.1", "abcdef"},
+	}
+	stats := &info.ContainerStats{
+		Timestamp: time.Unix(1400000000, 0),
+	}
+	stats.Cpu.Usage.Total = 1000
+	stats.Memory.Usage = 2000
+	stats.Memory.WorkingSet = 1500
+	stats.Network.RxBytes = 10
+	stats.Network.TxBytes = 20
+	stats.Filesystem = []info.FsStats{{Usage: 30}, {Usage: 40}}
+	return ref, stats
+}
+
+func checkPayload(t *testing.T, data []byte, expected payload) {
+	var p payload
+	if err := json.Unmarshal(data, &p); err != nil {
+		t.Fatalf("malformed payload %q: %v", data, err)
+	}
+	if p != expected {
+		t.Errorf("expected payload %+v, got %+v", expected, p)
+	}
+}
+
+func TestContainerLevels(t *testing.T) {
+	cases := []struct {
+		container string
+		expected  []string
+	}{
+		{"/", []string{"root"}},
+		{"/docker/abc", []string{"docker", "abc"}},
+		{"web+1", []string{"web_1"}},
+	}
+	for _, c := range cases {
+		levels := containerLevels(c.container, mqttReservedChars)
+		if strings.Join(levels, "|") != strings.Join(c.expected, "|") {
+			t.Errorf("expected levels %v for %q, got %v", c.expected, c.container, levels)
+		}
+	}
+}
+
+func TestMqttAddStats(t *testing.T) {
+	l := listen(t)
+	defer l.Close()
+	messages := make(chan message, 2)
+	go fakeMqttBroker(t, l, messages)
+
+	driver, err := NewMqtt("host/1", l.Addr().String(), "cadvisor/", 1, "test-client", "", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer driver.Close()
+
+	ref, stats := testStats()
+	if err := driver.AddStats(ref, stats); err != nil {
+		t.Fatal(err)
+	}
+	m := receive(t, messages)
+	if m.topic != "cadvisor/host_1/web.1" {
+		t.Errorf("unexpected topic %q", m.topic)
+	}
+	checkPayload(t, m.payload, payload{
+		Timestamp:        1400000000000,
+		MachineName:      "host/1",
+		ContainerName:    "web.1",
+		CpuUsage:         1000,
+		MemoryUsage:      2000,
+		MemoryWorkingSet: 1500,
+		RxBytes:          10,
+		TxBytes:          20,
+		FsUsage:          70,
+	})
+
+	machineStats := &info.MachineStats{
+		Timestamp:      time.Unix(1400000000, 0),
+		NumCores:       4,
+		MemoryCapacity: 4096,
+		MemoryUsage:    1024,
+	}
+	if err := driver.AddMachineStats(machineStats); err != nil {
+		t.Fatal(err)
+	}
+	m = receive(t, messages)
+	if m.topic != "cadvisor/host_1" {
+		t.Errorf("unexpected topic %q", m.topic)
+	}
+	checkPayload(t, m.payload, payload{
+		Timestamp:      1400000000000,
+		MachineName:    "host/1",
+		MemoryUsage:    1024,
+		NumCores:       4,
+		MemoryCapacity: 4096,
+	})
+}
+
+func TestNatsAddStats(t *testing.T) {
+	l := listen(t)
+	defer l.Close()
+	messages := make(chan message, 2)
+	go fakeNatsServer(t, l, messages)
+
+	driver, err := NewNats("host.1", l.Addr().String(), "cadvisor", true, "", "", "secret", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer driver.Close()
+
+	ref, stats := testStats()
+	ref.Aliases = nil
+	if err := driver.AddStats(ref, stats); err != nil {
+		t.Fatal(err)
+	}
+	m := receive(t, messages)
+	if m.topic != "cadvisor.host_1.docker.abcdef" {
+		t.Errorf("unexpected subject %q", m.topic)
+	}
+	checkPayload(t, m.payload, payload{
+		Timestamp:        1400000000000,
+		MachineName:      "host.1",
+		ContainerName:    "/docker/abcdef",
+		CpuUsage:         1000,
+		MemoryUsage:      2000,
+		MemoryWorkingSet: 1500,
+		RxBytes:          10,
+		TxBytes:          20,
+		FsUsage:          70,
+	})
+
+	if err := driver.AddMachineStats(&info.MachineStats{Timestamp: time.Unix(1400000000, 0)}); err != nil {
+		t.Fatal(err)
+	}
+	m = receive(t, messages)
+	if m.topic != "cadvisor.host_1" {
+		t.Errorf("unexpected subject %q", m.topic)
+	}
+}
+
+func TestNatsUnreachable(t *testing.T) {
+	l := listen(t)
+	address := l.Addr().String()
+	l.Close()
+	if _, err := NewNats("host", address, "cadvisor", false, "", "", "", nil); err == nil {
+		t.Error("expected an error connecting to a closed port")
+	}
+}
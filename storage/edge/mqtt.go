@@ -0,0 +1,316 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package edge
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/cadvisor/info"
+	"github.com/google/cadvisor/storage"
+)
+
+var argMqttBroker = flag.String("storage_driver_mqtt_broker", "localhost:1883", "MQTT broker (host:port) the mqtt storage driver publishes to. TLS is used when -storage_driver_secure is set")
+var argMqttTopic = flag.String("storage_driver_mqtt_topic", "cadvisor", "prefix of the MQTT topics, stats are published to <topic>/<machine>/<container> and <topic>/<machine> for the whole machine")
+var argMqttQos = flag.Int("storage_driver_mqtt_qos", 0, "MQTT quality of service: 0 (at most once) or 1 (at least once, waits for the broker to acknowledge every sample)")
+var argMqttClientId = flag.String("storage_driver_mqtt_client_id", "", "MQTT client identifier, defaults to cadvisor-<machine>")
+var argMqttUser = flag.String("storage_driver_mqtt_user", "", "MQTT username")
+var argMqttPassword = flag.String("storage_driver_mqtt_password", "", "MQTT password")
+
+const (
+	mqttTimeout = 10 * time.Second
+	// The broker drops the connection after 1.5 times the keep alive without packets, it is
+	// re-established on the next sample.
+	mqttKeepAlive = 60
+
+	// MQTT 3.1.1 packet types.
+	mqttConnect    = 1
+	mqttConnack    = 2
+	mqttPublish    = 3
+	mqttPuback     = 4
+	mqttDisconnect = 14
+)
+
+func init() {
+	storage.RegisterStorageDriver("mqtt", newMqtt)
+}
+
+type mqttStorage struct {
+	machineName string
+	address     string
+	topic       string
+	qos         int
+	clientId    string
+	username    string
+	password    string
+	tlsConfig   *tls.Config
+
+	lock         sync.Mutex
+	conn         net.Conn
+	reader       *bufio.Reader
+	nextPacketId uint16
+}
+
+func newMqtt() (storage.StorageDriver, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := storage.TlsConfigFromFlags()
+	if err != nil {
+		return nil, err
+	}
+	clientId := *argMqttClientId
+	if clientId == "" {
+		clientId = "cadvisor-" + hostname
+	}
+	return NewMqtt(hostname, *argMqttBroker, *argMqttTopic, *argMqttQos, clientId, *argMqttUser, *argMqttPassword, tlsConfig)
+}
+
+// Creates an MQTT storage driver. tlsConfig is nil to connect without TLS.
+func NewMqtt(machineName, address, topic string, qos int, clientId, username, password string, tlsConfig *tls.Config) (*mqttStorage, error) {
+	if qos != 0 && qos != 1 {
+		return nil, fmt.Errorf("unsupported MQTT quality of service %d", qos)
+	}
+	ret := &mqttStorage{
+		machineName: machineName,
+		address:     address,
+		topic:       strings.TrimRight(topic, "/"),
+		qos:         qos,
+		clientId:    clientId,
+		username:    username,
+		password:    password,
+		tlsConfig:   tlsConfig,
+	}
+	// Fail early if the broker is unreachable.
+	if err := ret.connect(); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// Appends an MQTT string (length-prefixed).
+func appendMqttString(buf []byte, s string) []byte {
+	buf = append(buf, byte(len(s)>>8), byte(len(s)))
+	return append(buf, s...)
+}
+
+// Returns the packet with its fixed header.
+func mqttPacket(header byte, body []byte) []byte {
+	packet := []byte{header}
+	// The remaining length is encoded 7 bits at a time.
+	n := len(body)
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		packet = append(packet, b)
+		if n == 0 {
+			break
+		}
+	}
+	return append(packet, body...)
+}
+
+// Reads a packet, returns its type and body.
+func readMqttPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	length := 0
+	for shift := uint(0); ; shift += 7 {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length |= int(b&0x7f) << shift
+		if b&0x80 == 0 {
+			break
+		}
+		if shift > 21 {
+			return 0, nil, fmt.Errorf("malformed MQTT packet length")
+		}
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return header >> 4, body, nil
+}
+
+func (self *mqttStorage) connect() error {
+	conn, err := net.DialTimeout("tcp", self.address, mqttTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to connect to MQTT broker at %q: %v", self.address, err)
+	}
+	if self.tlsConfig != nil {
+		config := self.tlsConfig.Clone()
+		if config.ServerName == "" {
+			config.ServerName, _, _ = net.SplitHostPort(self.address)
+		}
+		conn = tls.Client(conn, config)
+	}
+	conn.SetDeadline(time.Now().Add(mqttTimeout))
+
+	// Clean session, the driver does not subscribe to anything.
+	flags := byte(0x02)
+	if self.username != "" {
+		flags |= 0x80
+	}
+	if self.password != "" {
+		flags |= 0x40
+	}
+	body := appendMqttString(nil, "MQTT")
+	body = append(body, 4, flags, byte(mqttKeepAlive>>8), byte(mqttKeepAlive&0xff))
+	body = appendMqttString(body, self.clientId)
+	if self.username != "" {
+		body = appendMqttString(body, self.username)
+	}
+	if self.password != "" {
+		body = appendMqttString(body, self.password)
+	}
+	reader := bufio.NewReader(conn)
+	_, err = conn.Write(mqttPacket(mqttConnect<<4, body))
+	var packetType byte
+	var ack []byte
+	if err == nil {
+		packetType, ack, err = readMqttPacket(reader)
+	}
+	if err == nil && (packetType != mqttConnack || len(ack) != 2) {
+		err = fmt.Errorf("unexpected packet of type %d", packetType)
+	}
+	if err == nil && ack[1] != 0 {
+		err = fmt.Errorf("connection refused with code %d", ack[1])
+	}
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to connect to MQTT broker at %q: %v", self.address, err)
+	}
+	self.conn = conn
+	self.reader = reader
+	return nil
+}
+
+// Publishes the payload to the topic, waiting for the acknowledgement with QoS 1.
+func (self *mqttStorage) publish(topic string, payload []byte) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if self.conn == nil {
+		if err := self.connect(); err != nil {
+			return err
+		}
+	}
+	err := self.publishLocked(topic, payload)
+	if err != nil {
+		// Reconnect on the next sample, the connection may be in an unknown state.
+		self.conn.Close()
+		self.conn = nil
+		return fmt.Errorf("failed to publish to MQTT topic %q: %v", topic, err)
+	}
+	return nil
+}
+
+func (self *mqttStorage) publishLocked(topic string, payload []byte) error {
+	body := appendMqttString(nil, topic)
+	var packetId uint16
+	if self.qos > 0 {
+		self.nextPacketId++
+		if self.nextPacketId == 0 {
+			// Packet identifiers must not be 0.
+			self.nextPacketId = 1
+		}
+		packetId = self.nextPacketId
+		body = append(body, byte(packetId>>8), byte(packetId))
+	}
+	body = append(body, payload...)
+	self.conn.SetDeadline(time.Now().Add(mqttTimeout))
+	if _, err := self.conn.Write(mqttPacket(mqttPublish<<4|byte(self.qos<<1), body)); err != nil {
+		return err
+	}
+	if self.qos == 0 {
+		return nil
+	}
+	for {
+		packetType, ack, err := readMqttPacket(self.reader)
+		if err != nil {
+			return err
+		}
+		// Skip other packets, e.g.: ping responses.
+		if packetType == mqttPuback && len(ack) == 2 && binary.BigEndian.Uint16(ack) == packetId {
+			return nil
+		}
+	}
+}
+
+// Wildcards and the level separator are not allowed in topic levels.
+const mqttReservedChars = "+#/"
+
+// Returns the topic of the stats of the whole machine.
+func (self *mqttStorage) machineTopic() string {
+	return self.topic + "/" + sanitizeLevel(self.machineName, mqttReservedChars)
+}
+
+// Returns the topic of a container.
+func (self *mqttStorage) containerTopic(ref info.ContainerReference) string {
+	return self.machineTopic() + "/" + strings.Join(containerLevels(containerName(ref), mqttReservedChars), "/")
+}
+
+func (self *mqttStorage) AddStats(ref info.ContainerReference, stats *info.ContainerStats) error {
+	if stats == nil {
+		return nil
+	}
+	payload, err := encodeStats(self.machineName, ref, stats)
+	if err != nil {
+		return err
+	}
+	return self.publish(self.containerTopic(ref), payload)
+}
+
+func (self *mqttStorage) AddMachineStats(stats *info.MachineStats) error {
+	payload, err := encodeMachineStats(self.machineName, stats)
+	if err != nil {
+		return err
+	}
+	return self.publish(self.machineTopic(), payload)
+}
+
+func (self *mqttStorage) RecentStats(containerName string, numStats int) ([]*info.ContainerStats, error) {
+	return nil, fmt.Errorf("the mqtt storage driver does not support reading stats")
+}
+
+func (self *mqttStorage) Close() error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if self.conn == nil {
+		return nil
+	}
+	self.conn.SetDeadline(time.Now().Add(mqttTimeout))
+	self.conn.Write(mqttPacket(mqttDisconnect<<4, nil))
+	err := self.conn.Close()
+	self.conn = nil
+	return err
+}
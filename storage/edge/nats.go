@@ -0,0 +1,296 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package edge
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/cadvisor/info"
+	"github.com/google/cadvisor/storage"
+)
+
+var argNatsServer = flag.String("storage_driver_nats_server", "localhost:4222", "NATS server (host:port) the nats storage driver publishes to")
+var argNatsSubject = flag.String("storage_driver_nats_subject", "cadvisor", "prefix of the NATS subjects, stats are published to <subject>.<machine>.<container> and <subject>.<machine> for the whole machine")
+var argNatsAck = flag.Bool("storage_driver_nats_ack", false, "wait for the NATS server to acknowledge every sample")
+var argNatsUser = flag.String("storage_driver_nats_user", "", "NATS username")
+var argNatsPassword = flag.String("storage_driver_nats_password", "", "NATS password")
+
+const natsTimeout = 10 * time.Second
+
+// Tokens are separated by dots, wildcards and whitespace are not allowed in them.
+const natsReservedChars = ".*> \t"
+
+func init() {
+	storage.RegisterStorageDriver("nats", newNats)
+}
+
+type natsStorage struct {
+	machineName string
+	address     string
+	subject     string
+	ack         bool
+	username    string
+	password    string
+	token       string
+	tlsConfig   *tls.Config
+
+	lock   sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// Sent when connecting to the server.
+type natsConnectOptions struct {
+	Verbose     bool   `json:"verbose"`
+	Pedantic    bool   `json:"pedantic"`
+	TlsRequired bool   `json:"tls_required"`
+	Name        string `json:"name"`
+	User        string `json:"user,omitempty"`
+	Pass        string `json:"pass,omitempty"`
+	AuthToken   string `json:"auth_token,omitempty"`
+}
+
+// The part of the server information the driver uses.
+type natsServerInfo struct {
+	TlsRequired bool `json:"tls_required"`
+}
+
+func newNats() (storage.StorageDriver, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := storage.TlsConfigFromFlags()
+	if err != nil {
+		return nil, err
+	}
+	return NewNats(hostname, *argNatsServer, *argNatsSubject, *argNatsAck, *argNatsUser, *argNatsPassword, *storage.ArgDbToken, tlsConfig)
+}
+
+// Creates a NATS storage driver. TLS is used if tlsConfig is set or the server requires it.
+func NewNats(machineName, address, subject string, ack bool, username, password, token string, tlsConfig *tls.Config) (*natsStorage, error) {
+	ret := &natsStorage{
+		machineName: machineName,
+		address:     address,
+		subject:     strings.TrimRight(subject, "."),
+		ack:         ack,
+		username:    username,
+		password:    password,
+		token:       token,
+		tlsConfig:   tlsConfig,
+	}
+	// Fail early if the server is unreachable.
+	if err := ret.connect(); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// Reads a protocol line, without the trailing CRLF.
+func readNatsLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func (self *natsStorage) connect() error {
+	conn, err := net.DialTimeout("tcp", self.address, natsTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to connect to NATS server at %q: %v", self.address, err)
+	}
+	err = self.handshake(conn)
+	if err != nil {
+		self.conn.Close()
+		self.conn = nil
+		return fmt.Errorf("failed to connect to NATS server at %q: %v", self.address, err)
+	}
+	return nil
+}
+
+// Upgrades the connection to TLS if needed, authenticates and checks the server answers.
+func (self *natsStorage) handshake(conn net.Conn) error {
+	self.conn = conn
+	self.reader = bufio.NewReader(conn)
+	conn.SetDeadline(time.Now().Add(natsTimeout))
+	line, err := readNatsLine(self.reader)
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		return fmt.Errorf("unexpected greeting %q", line)
+	}
+	var serverInfo natsServerInfo
+	if err := json.Unmarshal([]byte(line[len("INFO "):]), &serverInfo); err != nil {
+		return fmt.Errorf("malformed server information: %v", err)
+	}
+
+	useTls := self.tlsConfig != nil || serverInfo.TlsRequired
+	if useTls {
+		config := &tls.Config{}
+		if self.tlsConfig != nil {
+			config = self.tlsConfig.Clone()
+		}
+		if config.ServerName == "" {
+			config.ServerName, _, _ = net.SplitHostPort(self.address)
+		}
+		tlsConn := tls.Client(conn, config)
+		tlsConn.SetDeadline(time.Now().Add(natsTimeout))
+		if err := tlsConn.Handshake(); err != nil {
+			return err
+		}
+		self.conn = tlsConn
+		self.reader = bufio.NewReader(tlsConn)
+	}
+
+	options, err := json.Marshal(&natsConnectOptions{
+		Verbose:     self.ack,
+		TlsRequired: useTls,
+		Name:        "cadvisor",
+		User:        self.username,
+		Pass:        self.password,
+		AuthToken:   self.token,
+	})
+	if err != nil {
+		return err
+	}
+	// The server answers the PING once it processed the CONNECT, or reports an error.
+	if _, err := fmt.Fprintf(self.conn, "CONNECT %s\r\nPING\r\n", options); err != nil {
+		return err
+	}
+	return self.waitFor("PONG")
+}
+
+// Reads until the expected line, answering the pings of the server.
+func (self *natsStorage) waitFor(expected string) error {
+	for {
+		line, err := readNatsLine(self.reader)
+		if err != nil {
+			return err
+		}
+		switch {
+		case line == expected:
+			return nil
+		case line == "PING":
+			if _, err := self.conn.Write([]byte("PONG\r\n")); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("server error: %s", strings.TrimSpace(line[len("-ERR"):]))
+		}
+		// Other lines (e.g.: +OK of the CONNECT in verbose mode, INFO updates) are skipped.
+	}
+}
+
+// Answers the pings the server sent since the last sample, so it does not close the connection.
+// Must be called with the lock held.
+func (self *natsStorage) answerPings() error {
+	for {
+		self.conn.SetReadDeadline(time.Now().Add(time.Millisecond))
+		line, err := readNatsLine(self.reader)
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				return nil
+			}
+			return err
+		}
+		if line == "PING" {
+			if _, err := self.conn.Write([]byte("PONG\r\n")); err != nil {
+				return err
+			}
+		} else if strings.HasPrefix(line, "-ERR") {
+			return fmt.Errorf("server error: %s", strings.TrimSpace(line[len("-ERR"):]))
+		}
+	}
+}
+
+func (self *natsStorage) publish(subject string, payload []byte) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if self.conn == nil {
+		if err := self.connect(); err != nil {
+			return err
+		}
+	}
+	err := self.answerPings()
+	if err == nil {
+		self.conn.SetDeadline(time.Now().Add(natsTimeout))
+		_, err = fmt.Fprintf(self.conn, "PUB %s %d\r\n%s\r\n", subject, len(payload), payload)
+	}
+	if err == nil && self.ack {
+		err = self.waitFor("+OK")
+	}
+	if err != nil {
+		// Reconnect on the next sample, the connection may be in an unknown state.
+		self.conn.Close()
+		self.conn = nil
+		return fmt.Errorf("failed to publish to NATS subject %q: %v", subject, err)
+	}
+	return nil
+}
+
+// Returns the subject of the stats of the whole machine.
+func (self *natsStorage) machineSubject() string {
+	return self.subject + "." + sanitizeLevel(self.machineName, natsReservedChars)
+}
+
+// Returns the subject of a container.
+func (self *natsStorage) containerSubject(ref info.ContainerReference) string {
+	return self.machineSubject() + "." + strings.Join(containerLevels(containerName(ref), natsReservedChars), ".")
+}
+
+func (self *natsStorage) AddStats(ref info.ContainerReference, stats *info.ContainerStats) error {
+	if stats == nil {
+		return nil
+	}
+	payload, err := encodeStats(self.machineName, ref, stats)
+	if err != nil {
+		return err
+	}
+	return self.publish(self.containerSubject(ref), payload)
+}
+
+func (self *natsStorage) AddMachineStats(stats *info.MachineStats) error {
+	payload, err := encodeMachineStats(self.machineName, stats)
+	if err != nil {
+		return err
+	}
+	return self.publish(self.machineSubject(), payload)
+}
+
+func (self *natsStorage) RecentStats(containerName string, numStats int) ([]*info.ContainerStats, error) {
+	return nil, fmt.Errorf("the nats storage driver does not support reading stats")
+}
+
+func (self *natsStorage) Close() error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if self.conn == nil {
+		return nil
+	}
+	err := self.conn.Close()
+	self.conn = nil
+	return err
+}
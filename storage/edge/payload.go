@@ -0,0 +1,110 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package edge implements lightweight storage drivers publishing compact stats payloads to
+// MQTT brokers and NATS servers, for hosts where running a full TSDB client is not feasible.
+package edge
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/google/cadvisor/info"
+)
+
+// A stats sample as published, with short field names to keep payloads small.
+type payload struct {
+	// Milliseconds since the Unix epoch.
+	Timestamp     int64  `json:"t"`
+	MachineName   string `json:"m"`
+	ContainerName string `json:"c,omitempty"`
+
+	// Cumulative CPU time, in nanoseconds.
+	CpuUsage         uint64 `json:"cpu"`
+	MemoryUsage      uint64 `json:"mem"`
+	MemoryWorkingSet uint64 `json:"ws"`
+	RxBytes          uint64 `json:"rx"`
+	TxBytes          uint64 `json:"tx"`
+	FsUsage          uint64 `json:"fs,omitempty"`
+
+	// Only set for the stats of the whole machine.
+	NumCores       int    `json:"cores,omitempty"`
+	MemoryCapacity int64  `json:"mem_cap,omitempty"`
+	FsCapacity     uint64 `json:"fs_cap,omitempty"`
+}
+
+// Returns the readable name of the container: its first alias (e.g.: Docker name) if it has one.
+func containerName(ref info.ContainerReference) string {
+	if len(ref.Aliases) > 0 {
+		return ref.Aliases[0]
+	}
+	return ref.Name
+}
+
+func encodeStats(machineName string, ref info.ContainerReference, stats *info.ContainerStats) ([]byte, error) {
+	p := &payload{
+		Timestamp:        stats.Timestamp.UnixNano() / 1e6,
+		MachineName:      machineName,
+		ContainerName:    containerName(ref),
+		CpuUsage:         stats.Cpu.Usage.Total,
+		MemoryUsage:      stats.Memory.Usage,
+		MemoryWorkingSet: stats.Memory.WorkingSet,
+		RxBytes:          stats.Network.RxBytes,
+		TxBytes:          stats.Network.TxBytes,
+	}
+	for _, fs := range stats.Filesystem {
+		p.FsUsage += fs.Usage
+	}
+	return json.Marshal(p)
+}
+
+func encodeMachineStats(machineName string, stats *info.MachineStats) ([]byte, error) {
+	return json.Marshal(&payload{
+		Timestamp:        stats.Timestamp.UnixNano() / 1e6,
+		MachineName:      machineName,
+		CpuUsage:         stats.CpuUsage,
+		MemoryUsage:      stats.MemoryUsage,
+		MemoryWorkingSet: stats.MemoryWorkingSet,
+		RxBytes:          stats.Network.RxBytes,
+		TxBytes:          stats.Network.TxBytes,
+		FsUsage:          stats.FsUsage,
+		NumCores:         stats.NumCores,
+		MemoryCapacity:   stats.MemoryCapacity,
+		FsCapacity:       stats.FsCapacity,
+	})
+}
+
+// Replaces the characters in replace by underscores.
+func sanitizeLevel(level, replace string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(replace, r) {
+			return '_'
+		}
+		return r
+	}, level)
+}
+
+// Splits the container name into topic levels, sanitized with sanitizeLevel. The root container
+// is named "root".
+func containerLevels(container, replace string) []string {
+	container = strings.Trim(container, "/")
+	if container == "" {
+		container = "root"
+	}
+	levels := strings.Split(container, "/")
+	for i := range levels {
+		levels[i] = sanitizeLevel(levels[i], replace)
+	}
+	return levels
+}
@@ -0,0 +1,218 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elasticsearch
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/cadvisor/info"
+	"github.com/google/cadvisor/storage"
+)
+
+var argHost = flag.String("storage_driver_es_host", "http://localhost:9200", "URL of the Elasticsearch node the stats are indexed in")
+var argIndex = flag.String("storage_driver_es_index", "cadvisor", "Elasticsearch index the stats are indexed in")
+var argDailyIndices = flag.Bool("storage_driver_es_daily_indices", true, "index the stats of each day in its own index named <index>-YYYY.MM.DD")
+var argType = flag.String("storage_driver_es_type", "stats", "Elasticsearch document type of the stats")
+
+type elasticStorage struct {
+	machineName    string
+	url            string
+	index          string
+	dailyIndices   bool
+	docType        string
+	bufferDuration time.Duration
+	client         *http.Client
+
+	lock      sync.Mutex
+	lastWrite time.Time
+	buffer    bytes.Buffer
+}
+
+// A stats sample as indexed in Elasticsearch.
+type detailSpec struct {
+	Timestamp       time.Time            `json:"timestamp"`
+	MachineName     string               `json:"machine_name,omitempty"`
+	ContainerName   string               `json:"container_name,omitempty"`
+	ContainerImage  string               `json:"container_image,omitempty"`
+	ContainerLabels map[string]string    `json:"container_labels,omitempty"`
+	ContainerEnvs   map[string]string    `json:"container_envs,omitempty"`
+	ContainerStats  *info.ContainerStats `json:"container_stats,omitempty"`
+	// Set instead of the container fields in the documents holding the stats of the machine.
+	MachineStats *info.MachineStats `json:"machine_stats,omitempty"`
+}
+
+func init() {
+	storage.RegisterStorageDriver("elasticsearch", newStorage)
+}
+
+func newStorage() (storage.StorageDriver, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+	client, err := storage.HttpClientFromFlags(30 * time.Second)
+	if err != nil {
+		return nil, err
+	}
+	return New(hostname, *argHost, *argIndex, *argDailyIndices, *argType, *storage.ArgDbBufferDuration, client)
+}
+
+// Creates an Elasticsearch storage driver. Stats are sent in bulk every bufferDuration. A
+// default client is used if client is nil.
+func New(machineName, url, index string, dailyIndices bool, docType string, bufferDuration time.Duration, client *http.Client) (*elasticStorage, error) {
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	ret := &elasticStorage{
+		machineName:    machineName,
+		url:            strings.TrimRight(url, "/"),
+		index:          index,
+		dailyIndices:   dailyIndices,
+		docType:        docType,
+		bufferDuration: bufferDuration,
+		client:         client,
+		lastWrite:      time.Now(),
+	}
+
+	// Fail early if Elasticsearch is unreachable.
+	resp, err := ret.client.Get(ret.url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Elasticsearch at %q: %v", ret.url, err)
+	}
+	resp.Body.Close()
+	return ret, nil
+}
+
+// Returns the name of the index of stats taken at the specified time.
+func (self *elasticStorage) indexName(timestamp time.Time) string {
+	if !self.dailyIndices {
+		return self.index
+	}
+	return self.index + "-" + timestamp.UTC().Format("2006.01.02")
+}
+
+func (self *elasticStorage) AddStats(ref info.ContainerReference, stats *info.ContainerStats) error {
+	if stats == nil {
+		return nil
+	}
+	return self.add(&detailSpec{
+		Timestamp:       stats.Timestamp,
+		MachineName:     self.machineName,
+		ContainerName:   ref.Name,
+		ContainerImage:  ref.Image,
+		ContainerLabels: ref.Labels,
+		ContainerEnvs:   ref.Envs,
+		ContainerStats:  stats,
+	})
+}
+
+func (self *elasticStorage) AddMachineStats(stats *info.MachineStats) error {
+	return self.add(&detailSpec{
+		Timestamp:    stats.Timestamp,
+		MachineName:  self.machineName,
+		MachineStats: stats,
+	})
+}
+
+// Buffers the document and sends the buffer when it is due.
+func (self *elasticStorage) add(spec *detailSpec) error {
+	action, err := json.Marshal(map[string]interface{}{
+		"index": map[string]string{
+			"_index": self.indexName(spec.Timestamp),
+			"_type":  self.docType,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	doc, err := json.Marshal(spec)
+	if err != nil {
+		return err
+	}
+
+	var body []byte
+	func() {
+		// add will be invoked simultaneously from multiple threads and only one of them will perform a write.
+		self.lock.Lock()
+		defer self.lock.Unlock()
+
+		self.buffer.Write(action)
+		self.buffer.WriteByte('\n')
+		self.buffer.Write(doc)
+		self.buffer.WriteByte('\n')
+		if time.Since(self.lastWrite) >= self.bufferDuration {
+			body = append([]byte(nil), self.buffer.Bytes()...)
+			self.buffer.Reset()
+			self.lastWrite = time.Now()
+		}
+	}()
+	if len(body) > 0 {
+		return self.bulk(body)
+	}
+	return nil
+}
+
+// Sends the newline-delimited actions and documents to the bulk API.
+func (self *elasticStorage) bulk(body []byte) error {
+	resp, err := self.client.Post(self.url+"/_bulk", "application/x-ndjson", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to write stats to Elasticsearch: %v", err)
+	}
+	defer resp.Body.Close()
+	out, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return storage.HttpStatusError(resp.StatusCode, fmt.Errorf("failed to write stats to Elasticsearch: %s: %s", resp.Status, out))
+	}
+
+	// Errors of single documents are reported in the body.
+	var result struct {
+		Errors bool `json:"errors"`
+	}
+	if err := json.Unmarshal(out, &result); err != nil {
+		return fmt.Errorf("failed to decode Elasticsearch bulk response: %v", err)
+	}
+	if result.Errors {
+		// Mostly documents not matching the mapping, indexing them again fails the same way.
+		return storage.Permanent(fmt.Errorf("Elasticsearch failed to index some stats: %s", out))
+	}
+	return nil
+}
+
+func (self *elasticStorage) RecentStats(containerName string, numStats int) ([]*info.ContainerStats, error) {
+	return nil, fmt.Errorf("the Elasticsearch storage driver does not support reading stats")
+}
+
+func (self *elasticStorage) Close() error {
+	self.lock.Lock()
+	body := append([]byte(nil), self.buffer.Bytes()...)
+	self.buffer.Reset()
+	self.lock.Unlock()
+	if len(body) > 0 {
+		return self.bulk(body)
+	}
+	return nil
+}
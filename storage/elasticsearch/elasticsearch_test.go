@@ -0,0 +1,73 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elasticsearch
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/info"
+)
+
+func TestAddStats(t *testing.T) {
+	var lines []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/_bulk" {
+			scanner := bufio.NewScanner(r.Body)
+			scanner.Buffer(nil, 1<<20)
+			for scanner.Scan() {
+				lines = append(lines, scanner.Text())
+			}
+			w.Write([]byte(`{"errors":false}`))
+		}
+	}))
+	defer server.Close()
+
+	// Flush on every sample.
+	driver, err := New("machine", server.URL, "cadvisor", true, "stats", 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref := info.ContainerReference{
+		Name:   "/test",
+		Labels: map[string]string{"app": "web"},
+	}
+	stats := &info.ContainerStats{Timestamp: time.Date(2015, 1, 2, 23, 0, 0, 0, time.UTC)}
+	if err := driver.AddStats(ref, stats); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(lines) != 2 {
+		t.Fatalf("expected an action and a document, got %q", lines)
+	}
+	var action map[string]map[string]string
+	if err := json.Unmarshal([]byte(lines[0]), &action); err != nil {
+		t.Fatal(err)
+	}
+	if action["index"]["_index"] != "cadvisor-2015.01.02" {
+		t.Errorf("unexpected index action %q", lines[0])
+	}
+	var doc detailSpec
+	if err := json.Unmarshal([]byte(lines[1]), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.MachineName != "machine" || doc.ContainerName != "/test" || doc.ContainerLabels["app"] != "web" {
+		t.Errorf("unexpected document %q", lines[1])
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"errors"
+	"net/http"
+)
+
+// An error writing stats that retrying cannot fix, e.g. stats the backend rejects as invalid or
+// not matching its schema. Such stats are dropped rather than retried.
+type PermanentError struct {
+	Err error
+}
+
+func (self *PermanentError) Error() string {
+	return self.Err.Error()
+}
+
+func (self *PermanentError) Unwrap() error {
+	return self.Err
+}
+
+// Marks the error as permanent, nil stays nil.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &PermanentError{err}
+}
+
+// Returns whether retrying the write that failed with the error cannot succeed.
+func IsPermanent(err error) bool {
+	var permanent *PermanentError
+	return errors.As(err, &permanent)
+}
+
+// Returns the error of an HTTP response with the status code, permanent for client errors (4xx)
+// other than timeouts and rate limits, which the same request may pass later.
+func HttpStatusError(statusCode int, err error) error {
+	if statusCode/100 == 4 && statusCode != http.StatusRequestTimeout && statusCode != http.StatusTooManyRequests {
+		return Permanent(err)
+	}
+	return err
+}
@@ -0,0 +1,47 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestHttpStatusError(t *testing.T) {
+	for _, test := range []struct {
+		statusCode int
+		permanent  bool
+	}{
+		{http.StatusBadRequest, true},
+		{http.StatusNotFound, true},
+		{http.StatusRequestEntityTooLarge, true},
+		{http.StatusRequestTimeout, false},
+		{http.StatusTooManyRequests, false},
+		{http.StatusInternalServerError, false},
+		{http.StatusServiceUnavailable, false},
+	} {
+		err := HttpStatusError(test.statusCode, fmt.Errorf("status %d", test.statusCode))
+		if IsPermanent(err) != test.permanent {
+			t.Errorf("expected status %d to be permanent: %v, got %v", test.statusCode, test.permanent, IsPermanent(err))
+		}
+		if err.Error() != fmt.Sprintf("status %d", test.statusCode) {
+			t.Errorf("unexpected message %q", err)
+		}
+	}
+	if Permanent(nil) != nil || IsPermanent(fmt.Errorf("unavailable")) {
+		t.Error("expected only errors marked permanent to be permanent")
+	}
+}
@@ -0,0 +1,234 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"flag"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/cadvisor/info"
+)
+
+var ArgExportDockerOnly = flag.Bool("storage_driver_docker_only", false, "only export the stats of Docker containers to the storage drivers")
+var ArgExportInclude = flag.String("storage_driver_include_containers", "", "regular expression matching the names or aliases of the containers whose stats are exported to the storage drivers. Empty exports all containers")
+var ArgExportExclude = flag.String("storage_driver_exclude_containers", "", "regular expression matching the names or aliases of the containers whose stats are not exported to the storage drivers")
+var ArgExportExcludeMetrics = flag.String("storage_driver_exclude_metrics", "", "comma-separated list of metrics not exported to the storage drivers. Options are: cpu, percpu, diskio, memory, network, filesystem")
+
+// Removes a group of metrics from a copy of the stats.
+type metricFilter func(stats *info.ContainerStats)
+
+var metricFilters = map[string]metricFilter{
+	"cpu": func(stats *info.ContainerStats) {
+		stats.Cpu = info.CpuStats{}
+	},
+	"percpu": func(stats *info.ContainerStats) {
+		stats.Cpu.Usage.PerCpu = nil
+	},
+	"diskio": func(stats *info.ContainerStats) {
+		stats.DiskIo = info.DiskIoStats{}
+	},
+	"memory": func(stats *info.ContainerStats) {
+		stats.Memory = info.MemoryStats{}
+	},
+	"network": func(stats *info.ContainerStats) {
+		stats.Network = info.NetworkStats{}
+	},
+	"filesystem": func(stats *info.ContainerStats) {
+		stats.Filesystem = nil
+		stats.Volumes = nil
+	},
+}
+
+// Containers and metrics exported to the storage drivers.
+type exportFilters struct {
+	dockerOnly bool
+	include    *regexp.Regexp
+	exclude    *regexp.Regexp
+	metrics    []metricFilter
+}
+
+// Only passes the stats of the selected containers, without the excluded metrics, to the driver.
+type filteringDriver struct {
+	StorageDriver
+	lock    sync.RWMutex
+	filters *exportFilters
+}
+
+var (
+	flagDriversLock sync.Mutex
+	// Drivers filtered through flags, whose filters are replaced by ReloadFilters.
+	flagDrivers []*filteringDriver
+)
+
+// Wraps the driver with the filters set through flags. The driver is wrapped even without filters so
+// that ReloadFilters can add some.
+func newFilteringDriverFromFlags(driver StorageDriver) (StorageDriver, error) {
+	filters, err := newExportFiltersFromFlags()
+	if err != nil {
+		return nil, err
+	}
+	ret := &filteringDriver{
+		StorageDriver: driver,
+		filters:       filters,
+	}
+	flagDriversLock.Lock()
+	defer flagDriversLock.Unlock()
+	flagDrivers = append(flagDrivers, ret)
+	return ret, nil
+}
+
+// Applies the current values of the export filter flags to the storage drivers created from flags.
+func ReloadFilters() error {
+	filters, err := newExportFiltersFromFlags()
+	if err != nil {
+		return err
+	}
+	flagDriversLock.Lock()
+	defer flagDriversLock.Unlock()
+	for _, driver := range flagDrivers {
+		driver.lock.Lock()
+		driver.filters = filters
+		driver.lock.Unlock()
+	}
+	return nil
+}
+
+// Wraps the driver with the specified filters. The driver is returned as-is if there are none.
+func newFilteringDriver(driver StorageDriver, dockerOnly bool, include, exclude, excludeMetrics string) (StorageDriver, error) {
+	filters, err := newExportFilters(dockerOnly, include, exclude, excludeMetrics)
+	if err != nil {
+		return nil, err
+	}
+	if !filters.dockerOnly && filters.include == nil && filters.exclude == nil && len(filters.metrics) == 0 {
+		return driver, nil
+	}
+	return &filteringDriver{
+		StorageDriver: driver,
+		filters:       filters,
+	}, nil
+}
+
+func newExportFiltersFromFlags() (*exportFilters, error) {
+	return newExportFilters(*ArgExportDockerOnly, *ArgExportInclude, *ArgExportExclude, *ArgExportExcludeMetrics)
+}
+
+func newExportFilters(dockerOnly bool, include, exclude, excludeMetrics string) (*exportFilters, error) {
+	ret := &exportFilters{
+		dockerOnly: dockerOnly,
+	}
+	var err error
+	if include != "" {
+		if ret.include, err = regexp.Compile(include); err != nil {
+			return nil, fmt.Errorf("invalid containers to include %q: %v", include, err)
+		}
+	}
+	if exclude != "" {
+		if ret.exclude, err = regexp.Compile(exclude); err != nil {
+			return nil, fmt.Errorf("invalid containers to exclude %q: %v", exclude, err)
+		}
+	}
+	for _, metric := range strings.Split(excludeMetrics, ",") {
+		metric = strings.TrimSpace(metric)
+		if metric == "" {
+			continue
+		}
+		f, ok := metricFilters[metric]
+		if !ok {
+			return nil, fmt.Errorf("unknown metric %q to exclude", metric)
+		}
+		ret.metrics = append(ret.metrics, f)
+	}
+	return ret, nil
+}
+
+// Whether the regular expression matches the name or one of the aliases of the container.
+func matchesContainer(re *regexp.Regexp, ref info.ContainerReference) bool {
+	if re.MatchString(ref.Name) {
+		return true
+	}
+	for _, alias := range ref.Aliases {
+		if re.MatchString(alias) {
+			return true
+		}
+	}
+	return false
+}
+
+func (self *exportFilters) exported(ref info.ContainerReference) bool {
+	// Only Docker containers have an image.
+	if self.dockerOnly && ref.Image == "" {
+		return false
+	}
+	if self.include != nil && !matchesContainer(self.include, ref) {
+		return false
+	}
+	if self.exclude != nil && matchesContainer(self.exclude, ref) {
+		return false
+	}
+	return true
+}
+
+func (self *filteringDriver) AddStats(ref info.ContainerReference, stats *info.ContainerStats) error {
+	self.lock.RLock()
+	filters := self.filters
+	self.lock.RUnlock()
+	if stats == nil || !filters.exported(ref) {
+		return nil
+	}
+	if len(filters.metrics) > 0 {
+		// The stats are shared with the in-memory storage.
+		filtered := *stats
+		for _, f := range filters.metrics {
+			f(&filtered)
+		}
+		stats = &filtered
+	}
+	return self.StorageDriver.AddStats(ref, stats)
+}
+
+// Machine stats are not filtered.
+func (self *filteringDriver) AddMachineStats(stats *info.MachineStats) error {
+	if machineDriver, ok := self.StorageDriver.(MachineStatsDriver); ok {
+		return machineDriver.AddMachineStats(stats)
+	}
+	return nil
+}
+
+func (self *filteringDriver) Status() []info.StorageDriverStatus {
+	if statusDriver, ok := self.StorageDriver.(StatusDriver); ok {
+		return statusDriver.Status()
+	}
+	return nil
+}
+
+func (self *filteringDriver) History(containerName string, numStats int) ([]*info.ContainerStats, error) {
+	history, ok := self.StorageDriver.(HistoryDriver)
+	if !ok {
+		return nil, fmt.Errorf("storage driver keeps no history")
+	}
+	return history.History(containerName, numStats)
+}
+
+func (self *filteringDriver) StatsInRange(containerName string, start, end time.Time, maxStats int) ([]*info.ContainerStats, error) {
+	timeRange, ok := self.StorageDriver.(TimeRangeDriver)
+	if !ok {
+		return nil, fmt.Errorf("storage driver cannot find time ranges")
+	}
+	return timeRange.StatsInRange(containerName, start, end, maxStats)
+}
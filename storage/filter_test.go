@@ -0,0 +1,117 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/cadvisor/info"
+)
+
+// Records the stats it gets.
+type statsRecorder struct {
+	recordingDriver
+	stats []*info.ContainerStats
+}
+
+func (self *statsRecorder) AddStats(ref info.ContainerReference, stats *info.ContainerStats) error {
+	self.stats = append(self.stats, stats)
+	return self.recordingDriver.AddStats(ref, stats)
+}
+
+func TestFilteringDriverWithoutFilters(t *testing.T) {
+	backend := &statsRecorder{}
+	driver, err := newFilteringDriver(backend, false, "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if driver != backend {
+		t.Errorf("the driver should not be wrapped without filters")
+	}
+	if _, err := newFilteringDriver(backend, false, "", "", "cpu,bogus"); err == nil {
+		t.Errorf("expected an error for an unknown metric")
+	}
+}
+
+func TestFilteringDriverContainers(t *testing.T) {
+	backend := &statsRecorder{}
+	driver, err := newFilteringDriver(backend, true, "^/docker/", "^db$", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	refs := []info.ContainerReference{
+		{Name: "/"},
+		{Name: "/docker/a", Aliases: []string{"web"}, Image: "nginx"},
+		{Name: "/docker/b", Aliases: []string{"db"}, Image: "postgres"},
+		{Name: "/docker/c"},
+	}
+	for _, ref := range refs {
+		driver.AddStats(ref, &info.ContainerStats{})
+	}
+	if len(backend.containers) != 1 || backend.containers[0] != "/docker/a" {
+		t.Errorf("expected only /docker/a to be exported, got %v", backend.containers)
+	}
+}
+
+func TestReloadFilters(t *testing.T) {
+	defer func() {
+		*ArgExportInclude = ""
+		flagDrivers = nil
+	}()
+	backend := &statsRecorder{}
+	driver, err := newFilteringDriverFromFlags(backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	driver.AddStats(info.ContainerReference{Name: "/system"}, &info.ContainerStats{})
+
+	*ArgExportInclude = "^/docker/"
+	if err := ReloadFilters(); err != nil {
+		t.Fatal(err)
+	}
+	driver.AddStats(info.ContainerReference{Name: "/system"}, &info.ContainerStats{})
+	driver.AddStats(info.ContainerReference{Name: "/docker/a"}, &info.ContainerStats{})
+	if !reflect.DeepEqual(backend.containers, []string{"/system", "/docker/a"}) {
+		t.Errorf("expected /system to be exported only before the reload, got %v", backend.containers)
+	}
+
+	*ArgExportInclude = "("
+	if err := ReloadFilters(); err == nil {
+		t.Error("expected an error for an invalid regular expression")
+	}
+}
+
+func TestFilteringDriverMetrics(t *testing.T) {
+	backend := &statsRecorder{}
+	driver, err := newFilteringDriver(backend, false, "", "", "percpu, filesystem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := &info.ContainerStats{
+		Filesystem: []info.FsStats{{Device: "/dev/sda1"}},
+	}
+	stats.Cpu.Usage.Total = 10
+	stats.Cpu.Usage.PerCpu = []uint64{4, 6}
+	driver.AddStats(info.ContainerReference{Name: "/"}, stats)
+
+	exported := backend.stats[0]
+	if exported.Cpu.Usage.Total != 10 || exported.Cpu.Usage.PerCpu != nil || exported.Filesystem != nil {
+		t.Errorf("unexpected exported stats %+v", exported)
+	}
+	if len(stats.Cpu.Usage.PerCpu) != 2 || len(stats.Filesystem) != 1 {
+		t.Errorf("the original stats were modified: %+v", stats)
+	}
+}
@@ -15,16 +15,43 @@
 package influxdb
 
 import (
+	"flag"
 	"fmt"
+	"os"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/golang/glog"
 	"github.com/google/cadvisor/info"
+	"github.com/google/cadvisor/storage"
 	influxdb "github.com/influxdb/influxdb/client"
 )
 
+var argMaxBatchSize = flag.Int("storage_driver_influxdb_max_batch_size", 1000, "maximum number of points written to InfluxDB in a single request, points are written earlier when the buffer duration elapses")
+var argMaxBufferedPoints = flag.Int("storage_driver_influxdb_max_buffered_points", 10000, "maximum number of points kept while InfluxDB is unavailable, the oldest are dropped first")
+
+const (
+	// Backoff after the first failed write, doubled on every consecutive failure.
+	initialRetryBackoff = time.Second
+	maxRetryBackoff     = 5 * time.Minute
+)
+
+func init() {
+	storage.RegisterStorageDriver("influxdb", newStorage)
+}
+
+// The methods of the InfluxDB client the driver uses.
+type influxdbClient interface {
+	WriteSeriesWithTimePrecision(series []*influxdb.Series, timePrecision influxdb.TimePrecision) error
+	Query(query string, precision ...influxdb.TimePrecision) ([]*influxdb.Series, error)
+	GetShardSpaces() ([]*influxdb.ShardSpace, error)
+	CreateShardSpace(space *influxdb.ShardSpace) error
+	GetContinuousQueries() ([]map[string]interface{}, error)
+}
+
 type influxdbStorage struct {
-	client         *influxdb.Client
+	client         influxdbClient
 	machineName    string
 	tableName      string
 	bufferDuration time.Duration
@@ -32,6 +59,15 @@ type influxdbStorage struct {
 	series         []*influxdb.Series
 	lock           sync.Mutex
 	readyToFlush   func() bool
+
+	// Maximum number of points per write and number of points kept while writes fail.
+	maxBatchSize      int
+	maxBufferedPoints int
+
+	// Writes are not attempted before retryAfter when they fail. The backoff grows with every
+	// consecutive failure.
+	retryBackoff time.Duration
+	retryAfter   time.Time
 }
 
 const (
@@ -57,6 +93,10 @@ const (
 	colFsLimit = "fs_limit"
 	// Filesystem usage.
 	colFsUsage = "fs_usage"
+	// Capacity of the machine.
+	colNumCores       = "num_cores"
+	colMemoryCapacity = "memory_capacity"
+	colFsCapacity     = "fs_capacity"
 )
 
 func (self *influxdbStorage) getSeriesDefaultValues(
@@ -259,37 +299,203 @@ func (self *influxdbStorage) AddStats(ref info.ContainerReference, stats *info.C
 	if stats == nil {
 		return nil
 	}
+	series := []*influxdb.Series{self.newSeries(self.containerStatsToValues(ref, stats))}
+	return self.add(append(series, self.containerFilesystemStatsToSeries(ref, stats)...))
+}
+
+// Name of the series holding the stats of the machine.
+func (self *influxdbStorage) machineSeriesName() string {
+	return self.tableName + ".machine"
+}
+
+func (self *influxdbStorage) AddMachineStats(stats *info.MachineStats) error {
+	columns := []string{
+		colTimestamp,
+		colMachineName,
+		colNumCores,
+		colMemoryCapacity,
+		colFsCapacity,
+		colCpuCumulativeUsage,
+		colMemoryUsage,
+		colMemoryWorkingSet,
+		colFsUsage,
+		colRxBytes,
+		colRxErrors,
+		colTxBytes,
+		colTxErrors,
+	}
+	values := []interface{}{
+		stats.Timestamp.UnixNano() / 1E3,
+		self.machineName,
+		stats.NumCores,
+		stats.MemoryCapacity,
+		stats.FsCapacity,
+		stats.CpuUsage,
+		stats.MemoryUsage,
+		stats.MemoryWorkingSet,
+		stats.FsUsage,
+		stats.Network.RxBytes,
+		stats.Network.RxErrors,
+		stats.Network.TxBytes,
+		stats.Network.TxErrors,
+	}
+	series := self.newSeries(columns, values)
+	series.Name = self.machineSeriesName()
+	return self.add([]*influxdb.Series{series})
+}
+
+// Buffers the series and writes the buffer when it is due.
+func (self *influxdbStorage) add(series []*influxdb.Series) error {
 	var seriesToFlush []*influxdb.Series
 	func() {
-		// AddStats will be invoked simultaneously from multiple threads and only one of them will perform a write.
+		// add will be invoked simultaneously from multiple threads and only one of them will perform a write.
 		self.lock.Lock()
 		defer self.lock.Unlock()
 
-		self.series = append(self.series, self.newSeries(self.containerStatsToValues(ref, stats)))
-		self.series = append(self.series, self.containerFilesystemStatsToSeries(ref, stats)...)
-		if self.readyToFlush() {
+		self.series = append(self.series, series...)
+		if time.Now().Before(self.retryAfter) {
+			self.dropOldest()
+			return
+		}
+		if self.readyToFlush() || len(self.series) >= self.maxBatchSize {
 			seriesToFlush = self.series
 			self.series = make([]*influxdb.Series, 0)
 			self.lastWrite = time.Now()
 		}
 	}()
-	if len(seriesToFlush) > 0 {
-		err := self.client.WriteSeriesWithTimePrecision(seriesToFlush, influxdb.Microsecond)
-		if err != nil {
-			return fmt.Errorf("failed to write stats to influxDb - %s", err)
-		}
+	if len(seriesToFlush) == 0 {
+		return nil
+	}
+
+	unwritten, err := self.write(seriesToFlush)
+	if len(unwritten) > 0 {
+		self.retryLater(unwritten)
+		return fmt.Errorf("failed to write stats to influxDb - %s", err)
 	}
 
+	self.lock.Lock()
+	self.retryBackoff = 0
+	self.lock.Unlock()
+	if err != nil {
+		return storage.Permanent(fmt.Errorf("influxDb rejected stats - %s", err))
+	}
 	return nil
 }
 
+// Writes the series, each with a single point, in batches of at most maxBatchSize points. Returns
+// the series not written when a write fails. Batches InfluxDB rejects are dropped, the error of the
+// last one is returned once the others are written.
+func (self *influxdbStorage) write(series []*influxdb.Series) ([]*influxdb.Series, error) {
+	var rejected error
+	for len(series) > 0 {
+		n := len(series)
+		if n > self.maxBatchSize {
+			n = self.maxBatchSize
+		}
+		err := writeError(self.client.WriteSeriesWithTimePrecision(mergeSeries(series[:n]), influxdb.Microsecond))
+		if storage.IsPermanent(err) {
+			// Writing the batch again cannot succeed, drop it rather than block the ones after it.
+			glog.Warningf("InfluxDB rejected %d points, dropping them: %v", n, err)
+			rejected = err
+		} else if err != nil {
+			return series, err
+		}
+		series = series[n:]
+	}
+	return nil, rejected
+}
+
+// Marks the errors of the requests InfluxDB rejected as permanent. The client reports the status
+// code of failed requests only in the message.
+func writeError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var statusCode int
+	if _, scanErr := fmt.Sscanf(err.Error(), "Server returned (%d)", &statusCode); scanErr != nil {
+		return err
+	}
+	return storage.HttpStatusError(statusCode, err)
+}
+
+// Puts back series that failed to be written and backs off before writing again.
+func (self *influxdbStorage) retryLater(failed []*influxdb.Series) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.series = append(failed, self.series...)
+	self.dropOldest()
+
+	if self.retryBackoff == 0 {
+		self.retryBackoff = initialRetryBackoff
+	} else if self.retryBackoff < maxRetryBackoff {
+		self.retryBackoff *= 2
+		if self.retryBackoff > maxRetryBackoff {
+			self.retryBackoff = maxRetryBackoff
+		}
+	}
+	self.retryAfter = time.Now().Add(self.retryBackoff)
+	glog.V(2).Infof("Retrying InfluxDB writes in %v", self.retryBackoff)
+}
+
+// Drops the oldest points if there are more than maxBufferedPoints. Must be called with the lock held.
+func (self *influxdbStorage) dropOldest() {
+	if excess := len(self.series) - self.maxBufferedPoints; excess > 0 {
+		glog.Warningf("InfluxDB is unavailable, dropping %d points", excess)
+		self.series = self.series[excess:]
+	}
+}
+
+// Combines series with the same name and columns into a single series with all their points.
+func mergeSeries(series []*influxdb.Series) []*influxdb.Series {
+	merged := make([]*influxdb.Series, 0, 2)
+	index := make(map[string]*influxdb.Series)
+	for _, s := range series {
+		key := s.Name + "\x00" + strings.Join(s.Columns, "\x00")
+		if m, ok := index[key]; ok {
+			m.Points = append(m.Points, s.Points...)
+			continue
+		}
+		m := &influxdb.Series{
+			Name:    s.Name,
+			Columns: s.Columns,
+			Points:  append([][]interface{}(nil), s.Points...),
+		}
+		index[key] = m
+		merged = append(merged, m)
+	}
+	return merged
+}
+
 func (self *influxdbStorage) RecentStats(containerName string, numStats int) ([]*info.ContainerStats, error) {
+	return self.queryStats(containerName, "", numStats)
+}
+
+// The stats written to InfluxDB serve those no longer held in memory.
+func (self *influxdbStorage) History(containerName string, numStats int) ([]*info.ContainerStats, error) {
+	return self.queryStats(containerName, "", numStats)
+}
+
+func (self *influxdbStorage) StatsInRange(containerName string, start, end time.Time, maxStats int) ([]*info.ContainerStats, error) {
+	// Times are compared in microseconds, the precision of the stats written.
+	var condition string
+	if !start.IsZero() {
+		condition += fmt.Sprintf(" and time > %du", start.UnixNano()/1000-1)
+	}
+	if !end.IsZero() {
+		condition += fmt.Sprintf(" and time < %du", end.UnixNano()/1000+1)
+	}
+	return self.queryStats(containerName, condition, maxStats)
+}
+
+// Returns the latest numStats stats of the container (all if negative) matching the condition, in
+// time increasing order.
+func (self *influxdbStorage) queryStats(containerName, condition string, numStats int) ([]*info.ContainerStats, error) {
 	if numStats == 0 {
 		return nil, nil
 	}
 	// TODO(dengnan): select only columns that we need
 	// TODO(dengnan): escape names
-	query := fmt.Sprintf("select * from %v where %v='%v' and %v='%v'", self.tableName, colContainerName, containerName, colMachineName, self.machineName)
+	query := fmt.Sprintf("select * from %v where %v='%v' and %v='%v'%v", self.tableName, colContainerName, containerName, colMachineName, self.machineName, condition)
 	if numStats > 0 {
 		query = fmt.Sprintf("%v limit %v", query, numStats)
 	}
@@ -319,9 +525,19 @@ func (self *influxdbStorage) RecentStats(containerName string, numStats int) ([]
 	return statsList, nil
 }
 
+// Writes the buffered series before closing.
 func (self *influxdbStorage) Close() error {
+	self.lock.Lock()
+	series := self.series
+	self.series = nil
+	self.lock.Unlock()
+
+	var err error
+	if unwritten, writeErr := self.write(series); writeErr != nil {
+		err = fmt.Errorf("failed to write %d buffered points to influxDb - %s", len(unwritten), writeErr)
+	}
 	self.client = nil
-	return nil
+	return err
 }
 
 // Returns a new influxdb series.
@@ -336,6 +552,23 @@ func (self *influxdbStorage) newSeries(columns []string, points []interface{}) *
 	return out
 }
 
+func newStorage() (storage.StorageDriver, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+	return New(
+		hostname,
+		*storage.ArgDbTable,
+		*storage.ArgDbName,
+		*storage.ArgDbUsername,
+		*storage.ArgDbPassword,
+		*storage.ArgDbHost,
+		*storage.ArgDbIsSecure,
+		*storage.ArgDbBufferDuration,
+	)
+}
+
 // machineName: A unique identifier to identify the host that current cAdvisor
 // instance is running on.
 // influxdbHost: The host which runs influxdb.
@@ -348,12 +581,17 @@ func New(machineName,
 	isSecure bool,
 	bufferDuration time.Duration,
 ) (*influxdbStorage, error) {
+	httpClient, err := storage.HttpClientFromFlags(0)
+	if err != nil {
+		return nil, err
+	}
 	config := &influxdb.ClientConfig{
-		Host:     influxdbHost,
-		Username: username,
-		Password: password,
-		Database: database,
-		IsSecure: isSecure,
+		Host:       influxdbHost,
+		Username:   username,
+		Password:   password,
+		Database:   database,
+		HttpClient: httpClient,
+		IsSecure:   isSecure,
 	}
 	client, err := influxdb.NewClient(config)
 	if err != nil {
@@ -369,7 +607,17 @@ func New(machineName,
 		bufferDuration: bufferDuration,
 		lastWrite:      time.Now(),
 		series:         make([]*influxdb.Series, 0),
+
+		maxBatchSize:      *argMaxBatchSize,
+		maxBufferedPoints: *argMaxBufferedPoints,
+	}
+	if ret.maxBatchSize < 1 {
+		ret.maxBatchSize = 1
 	}
 	ret.readyToFlush = ret.defaultReadyToFlush
+	err = ret.ensureRetention(database, *argRetention, *argDownsampleInterval, *argDownsampleRetention)
+	if err != nil {
+		return nil, err
+	}
 	return ret, nil
 }
@@ -0,0 +1,141 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package influxdb
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/golang/glog"
+	influxdb "github.com/influxdb/influxdb/client"
+)
+
+var argRetention = flag.String("storage_driver_influxdb_retention", "", "how long InfluxDB keeps raw stats (e.g.: 7d). cAdvisor creates a shard space with this retention policy if one does not exist. Empty leaves retention unmanaged")
+var argDownsampleInterval = flag.String("storage_driver_influxdb_downsample_interval", "", "interval of the continuous query downsampling stats into the <table>.<interval> series (e.g.: 1h). Empty disables downsampling")
+var argDownsampleRetention = flag.String("storage_driver_influxdb_downsample_retention", "inf", "how long InfluxDB keeps downsampled stats (e.g.: 365d)")
+
+const shardDuration = "1d"
+
+// Aggregation applied to each column when downsampling. Cumulative counters keep their
+// largest value in the interval, gauges are averaged.
+var downsampledColumns = []struct {
+	column, function string
+}{
+	{colCpuCumulativeUsage, "max"},
+	{colMemoryUsage, "mean"},
+	{colMemoryWorkingSet, "mean"},
+	{colRxBytes, "max"},
+	{colRxErrors, "max"},
+	{colTxBytes, "max"},
+	{colTxErrors, "max"},
+}
+
+// Creates the shard spaces and continuous query requested through flags. Existing shard
+// spaces are left untouched since dropping them would drop the stats they hold.
+func (self *influxdbStorage) ensureRetention(database, retention, interval, downsampleRetention string) error {
+	if retention == "" && interval == "" {
+		return nil
+	}
+	spaces, err := self.client.GetShardSpaces()
+	if err != nil {
+		return fmt.Errorf("failed to list shard spaces: %v", err)
+	}
+	if retention != "" {
+		// The raw stats include the stats of the machine.
+		regex := fmt.Sprintf("/^(%s|%s)$/", escapeSeriesName(self.tableName), escapeSeriesName(self.machineSeriesName()))
+		err = self.ensureShardSpace(spaces, database, self.tableName, regex, retention)
+		if err != nil {
+			return err
+		}
+	}
+	if interval == "" {
+		return nil
+	}
+	target := downsampledSeriesName(self.tableName, interval)
+	err = self.ensureShardSpace(spaces, database, target, fmt.Sprintf("/^%s$/", escapeSeriesName(target)), downsampleRetention)
+	if err != nil {
+		return err
+	}
+	return self.ensureContinuousQuery(target, interval)
+}
+
+func (self *influxdbStorage) ensureShardSpace(spaces []*influxdb.ShardSpace, database, series, regex, retention string) error {
+	name := shardSpaceName(series)
+	for _, space := range spaces {
+		if space.Database != database || space.Name != name {
+			continue
+		}
+		if space.RetentionPolicy != retention {
+			glog.Warningf("InfluxDB shard space %q has retention %q instead of %q, drop it to apply the new retention", name, space.RetentionPolicy, retention)
+		}
+		return nil
+	}
+	err := self.client.CreateShardSpace(&influxdb.ShardSpace{
+		Name:              name,
+		Database:          database,
+		Regex:             regex,
+		RetentionPolicy:   retention,
+		ShardDuration:     shardDuration,
+		ReplicationFactor: 1,
+		Split:             1,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create shard space %q: %v", name, err)
+	}
+	glog.Infof("Created InfluxDB shard space %q with retention %q", name, retention)
+	return nil
+}
+
+func (self *influxdbStorage) ensureContinuousQuery(target, interval string) error {
+	queries, err := self.client.GetContinuousQueries()
+	if err != nil {
+		return fmt.Errorf("failed to list continuous queries: %v", err)
+	}
+	suffix := fmt.Sprintf("into %s", target)
+	for _, q := range queries {
+		query, ok := q["query"].(string)
+		if ok && strings.HasSuffix(strings.TrimSpace(query), suffix) {
+			return nil
+		}
+	}
+	_, err = self.client.Query(continuousQuery(self.tableName, target, interval))
+	if err != nil {
+		return fmt.Errorf("failed to create continuous query into %q: %v", target, err)
+	}
+	glog.Infof("Created InfluxDB continuous query downsampling %q into %q", self.tableName, target)
+	return nil
+}
+
+func escapeSeriesName(series string) string {
+	return strings.Replace(series, ".", `\.`, -1)
+}
+
+func shardSpaceName(series string) string {
+	return "cadvisor_" + strings.Replace(series, ".", "_", -1)
+}
+
+func downsampledSeriesName(tableName, interval string) string {
+	return fmt.Sprintf("%s.%s", tableName, interval)
+}
+
+func continuousQuery(tableName, target, interval string) string {
+	selectors := make([]string, 0, len(downsampledColumns))
+	for _, c := range downsampledColumns {
+		selectors = append(selectors, fmt.Sprintf("%s(%s) as %s", c.function, c.column, c.column))
+	}
+	return fmt.Sprintf("select %s from %s group by time(%s), %s, %s into %s",
+		strings.Join(selectors, ", "), tableName, interval, colMachineName, colContainerName, target)
+}
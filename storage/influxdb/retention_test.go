@@ -0,0 +1,109 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package influxdb
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	influxdb "github.com/influxdb/influxdb/client"
+)
+
+func TestEnsureRetention(t *testing.T) {
+	rawSpace := &influxdb.ShardSpace{Name: "cadvisor_stats", Database: "cadvisor", RetentionPolicy: "7d"}
+	downsampledSpace := &influxdb.ShardSpace{Name: "cadvisor_stats_1h", Database: "cadvisor", RetentionPolicy: "inf"}
+	cq := map[string]interface{}{"query": continuousQuery("stats", "stats.1h", "1h")}
+	for _, test := range []struct {
+		desc      string
+		retention string
+		interval  string
+		spaces    []*influxdb.ShardSpace
+		cqs       []map[string]interface{}
+		// Name and retention of the shard spaces created.
+		created []string
+		queries int
+	}{
+		{"unmanaged", "", "", nil, nil, nil, 0},
+		{"raw retention", "7d", "", nil, nil, []string{"cadvisor_stats:7d"}, 0},
+		{"raw retention already set", "7d", "", []*influxdb.ShardSpace{rawSpace}, nil, nil, 0},
+		// Shard spaces cannot be altered without dropping their stats, a different retention is only
+		// logged.
+		{"raw retention changed", "30d", "", []*influxdb.ShardSpace{rawSpace}, nil, nil, 0},
+		{"space of another database", "7d", "", []*influxdb.ShardSpace{{Name: "cadvisor_stats", Database: "other"}}, nil, []string{"cadvisor_stats:7d"}, 0},
+		{"downsampling", "", "1h", nil, nil, []string{"cadvisor_stats_1h:inf"}, 1},
+		{"both", "7d", "1h", nil, nil, []string{"cadvisor_stats:7d", "cadvisor_stats_1h:inf"}, 1},
+		{"downsampling already set", "", "1h", []*influxdb.ShardSpace{downsampledSpace}, []map[string]interface{}{cq}, nil, 0},
+	} {
+		client := &fakeClient{spaces: test.spaces, cqs: test.cqs}
+		driver := newTestStorage(client, 1, time.Hour)
+		if err := driver.ensureRetention("cadvisor", test.retention, test.interval, "inf"); err != nil {
+			t.Errorf("%s: %v", test.desc, err)
+			continue
+		}
+		var created []string
+		for _, space := range client.created {
+			created = append(created, space.Name+":"+space.RetentionPolicy)
+		}
+		if !reflect.DeepEqual(created, test.created) {
+			t.Errorf("%s: expected shard spaces %v to be created, got %v", test.desc, test.created, created)
+		}
+		if len(client.queries) != test.queries {
+			t.Errorf("%s: expected %d continuous queries to be created, got %q", test.desc, test.queries, client.queries)
+		}
+	}
+}
+
+func TestEnsureRetentionCreates(t *testing.T) {
+	client := &fakeClient{}
+	driver := newTestStorage(client, 1, time.Hour)
+	if err := driver.ensureRetention("cadvisor", "7d", "1h", "365d"); err != nil {
+		t.Fatal(err)
+	}
+	expected := []*influxdb.ShardSpace{
+		{
+			Name:              "cadvisor_stats",
+			Database:          "cadvisor",
+			Regex:             `/^(stats|stats\.machine)$/`,
+			RetentionPolicy:   "7d",
+			ShardDuration:     shardDuration,
+			ReplicationFactor: 1,
+			Split:             1,
+		},
+		{
+			Name:              "cadvisor_stats_1h",
+			Database:          "cadvisor",
+			Regex:             `/^stats\.1h$/`,
+			RetentionPolicy:   "365d",
+			ShardDuration:     shardDuration,
+			ReplicationFactor: 1,
+			Split:             1,
+		},
+	}
+	if !reflect.DeepEqual(client.created, expected) {
+		t.Errorf("expected shard spaces %+v, got %+v", expected, client.created)
+	}
+	query := "select max(cpu_cumulative_usage) as cpu_cumulative_usage, mean(memory_usage) as memory_usage, mean(memory_working_set) as memory_working_set, max(rx_bytes) as rx_bytes, max(rx_errors) as rx_errors, max(tx_bytes) as tx_bytes, max(tx_errors) as tx_errors from stats group by time(1h), machine, container_name into stats.1h"
+	if !reflect.DeepEqual(client.queries, []string{query}) {
+		t.Errorf("expected continuous query %q, got %q", query, client.queries)
+	}
+
+	client = &fakeClient{spacesErr: fmt.Errorf("unauthorized")}
+	driver = newTestStorage(client, 1, time.Hour)
+	if err := driver.ensureRetention("cadvisor", "7d", "", "inf"); err == nil {
+		t.Error("expected an error when the shard spaces cannot be listed")
+	}
+}
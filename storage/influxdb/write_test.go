@@ -0,0 +1,203 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package influxdb
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/storage"
+	influxdb "github.com/influxdb/influxdb/client"
+)
+
+// Records the calls made to InfluxDB.
+type fakeClient struct {
+	// Number of points of each write.
+	writes   []int
+	writeErr error
+
+	spaces    []*influxdb.ShardSpace
+	spacesErr error
+	created   []*influxdb.ShardSpace
+	cqs       []map[string]interface{}
+	queries   []string
+}
+
+func (self *fakeClient) WriteSeriesWithTimePrecision(series []*influxdb.Series, timePrecision influxdb.TimePrecision) error {
+	if self.writeErr != nil {
+		return self.writeErr
+	}
+	points := 0
+	for _, s := range series {
+		points += len(s.Points)
+	}
+	self.writes = append(self.writes, points)
+	return nil
+}
+
+func (self *fakeClient) Query(query string, precision ...influxdb.TimePrecision) ([]*influxdb.Series, error) {
+	self.queries = append(self.queries, query)
+	return nil, nil
+}
+
+func (self *fakeClient) GetShardSpaces() ([]*influxdb.ShardSpace, error) {
+	return self.spaces, self.spacesErr
+}
+
+func (self *fakeClient) CreateShardSpace(space *influxdb.ShardSpace) error {
+	self.created = append(self.created, space)
+	return nil
+}
+
+func (self *fakeClient) GetContinuousQueries() ([]map[string]interface{}, error) {
+	return self.cqs, nil
+}
+
+func newTestStorage(client influxdbClient, maxBatchSize int, bufferDuration time.Duration) *influxdbStorage {
+	ret := &influxdbStorage{
+		client:            client,
+		machineName:       "machine",
+		tableName:         "stats",
+		bufferDuration:    bufferDuration,
+		lastWrite:         time.Now(),
+		maxBatchSize:      maxBatchSize,
+		maxBufferedPoints: 100,
+	}
+	ret.readyToFlush = ret.defaultReadyToFlush
+	return ret
+}
+
+// Returns n series of a single point, alternating between the stats of containers and machines.
+func testSeries(self *influxdbStorage, n int) []*influxdb.Series {
+	series := make([]*influxdb.Series, n)
+	for i := range series {
+		series[i] = self.newSeries([]string{colTimestamp, colMemoryUsage}, []interface{}{int64(i), uint64(i)})
+		if i%2 == 1 {
+			series[i].Name = self.machineSeriesName()
+		}
+	}
+	return series
+}
+
+func TestWriteBatches(t *testing.T) {
+	for _, test := range []struct {
+		points    int
+		batchSize int
+		expected  []int
+	}{
+		{1, 3, []int{1}},
+		{3, 3, []int{3}},
+		{4, 3, []int{3, 1}},
+		{7, 3, []int{3, 3, 1}},
+		{6, 1, []int{1, 1, 1, 1, 1, 1}},
+	} {
+		client := &fakeClient{}
+		driver := newTestStorage(client, test.batchSize, time.Hour)
+		unwritten, err := driver.write(testSeries(driver, test.points))
+		if err != nil || len(unwritten) != 0 {
+			t.Errorf("failed to write %d points: %v", test.points, err)
+		}
+		if !reflect.DeepEqual(client.writes, test.expected) {
+			t.Errorf("expected %d points in batches of %d to be written as %v, got %v", test.points, test.batchSize, test.expected, client.writes)
+		}
+	}
+}
+
+func TestAddFlushes(t *testing.T) {
+	client := &fakeClient{}
+	driver := newTestStorage(client, 3, time.Hour)
+	series := testSeries(driver, 5)
+
+	// Buffered until a batch is full.
+	for i := range series[:3] {
+		if err := driver.add(series[i : i+1]); err != nil {
+			t.Fatal(err)
+		}
+		if i < 2 && len(client.writes) != 0 {
+			t.Fatalf("expected %d points to be buffered, got writes %v", i+1, client.writes)
+		}
+	}
+	if !reflect.DeepEqual(client.writes, []int{3}) {
+		t.Fatalf("expected a full batch to be written, got %v", client.writes)
+	}
+
+	// Or until the buffer duration elapses.
+	if err := driver.add(series[3:4]); err != nil {
+		t.Fatal(err)
+	}
+	driver.lastWrite = time.Now().Add(-time.Hour)
+	if err := driver.add(series[4:5]); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(client.writes, []int{3, 2}) {
+		t.Fatalf("expected the buffer to be written once the duration elapsed, got %v", client.writes)
+	}
+}
+
+func TestAddRetries(t *testing.T) {
+	client := &fakeClient{writeErr: fmt.Errorf("Server returned (503): unavailable")}
+	driver := newTestStorage(client, 2, time.Hour)
+	series := testSeries(driver, 4)
+
+	// Failed writes are kept and retried after a backoff.
+	if err := driver.add(series[:2]); err == nil || storage.IsPermanent(err) {
+		t.Fatalf("expected a transient error, got %v", err)
+	}
+	if len(driver.series) != 2 || driver.retryBackoff != initialRetryBackoff {
+		t.Fatalf("expected 2 points kept with a backoff of %v, got %d and %v", initialRetryBackoff, len(driver.series), driver.retryBackoff)
+	}
+	client.writeErr = nil
+	if err := driver.add(series[2:3]); err != nil || len(client.writes) != 0 {
+		t.Fatalf("expected no write during the backoff, got %v: %v", client.writes, err)
+	}
+	driver.retryAfter = time.Time{}
+	if err := driver.add(series[3:4]); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(client.writes, []int{2, 2}) || driver.retryBackoff != 0 {
+		t.Errorf("expected the kept points to be written, got %v with a backoff of %v", client.writes, driver.retryBackoff)
+	}
+
+	// Rejected writes are dropped.
+	client.writeErr = fmt.Errorf("Server returned (400): invalid")
+	if err := driver.add(series[:2]); !storage.IsPermanent(err) {
+		t.Errorf("expected a permanent error, got %v", err)
+	}
+	if len(driver.series) != 0 || driver.retryBackoff != 0 {
+		t.Errorf("expected the rejected points to be dropped, got %d kept with a backoff of %v", len(driver.series), driver.retryBackoff)
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	for _, test := range []struct {
+		err       error
+		permanent bool
+	}{
+		{fmt.Errorf("Server returned (400): field type conflict"), true},
+		{fmt.Errorf("Server returned (404): database not found"), true},
+		{fmt.Errorf("Server returned (500): timeout"), false},
+		{fmt.Errorf("dial tcp: connection refused"), false},
+	} {
+		err := writeError(test.err)
+		if storage.IsPermanent(err) != test.permanent {
+			t.Errorf("expected %q to be permanent: %v", test.err, test.permanent)
+		}
+	}
+	if writeError(nil) != nil {
+		t.Error("expected no error")
+	}
+}
@@ -0,0 +1,310 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafka
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"hash/crc32"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/cadvisor/info"
+	"github.com/google/cadvisor/storage"
+	"github.com/google/cadvisor/utils"
+)
+
+var argBrokers = flag.String("storage_driver_kafka_brokers", "localhost:9092", "comma-separated list of Kafka brokers (host:port) used to bootstrap the Kafka storage driver")
+var argTopic = flag.String("storage_driver_kafka_topic", "stats", "Kafka topic the stats are published to")
+var argTls = flag.Bool("storage_driver_kafka_tls", false, "use TLS configured by the storage_driver_kafka_tls_* flags to connect to the Kafka brokers. Otherwise -storage_driver_secure enables TLS configured by the common storage_driver_tls_* flags")
+var argTlsCa = flag.String("storage_driver_kafka_tls_ca", "", "PEM file with the CA certificates used to verify the Kafka brokers, defaults to the system's")
+var argTlsCert = flag.String("storage_driver_kafka_tls_cert", "", "PEM file with the client certificate presented to the Kafka brokers")
+var argTlsKey = flag.String("storage_driver_kafka_tls_key", "", "PEM file with the key of the client certificate")
+var argTlsInsecure = flag.Bool("storage_driver_kafka_tls_insecure_skip_verify", false, "do not verify the certificates of the Kafka brokers")
+var argSaslUser = flag.String("storage_driver_kafka_sasl_user", "", "user to authenticate with to the Kafka brokers using SASL PLAIN, empty disables SASL")
+var argSaslPassword = flag.String("storage_driver_kafka_sasl_password", "", "password to authenticate with to the Kafka brokers using SASL PLAIN")
+var argBatchSize = flag.Int("storage_driver_kafka_batch_size", 500, "maximum number of stats samples buffered before they are produced to Kafka, regardless of -storage_driver_buffer_duration")
+
+// How often the partitions of the topic and their leaders are looked up again.
+const metadataRefreshInterval = 5 * time.Minute
+
+func init() {
+	storage.RegisterStorageDriver("kafka", newStorage)
+}
+
+type kafkaStorage struct {
+	machineName  string
+	topic        string
+	brokerAddrs  []string
+	tlsConfig    *tls.Config
+	saslUser     string
+	saslPassword string
+
+	bufferDuration time.Duration
+	batchSize      int
+
+	// Guards the buffered messages.
+	lock      sync.Mutex
+	lastWrite time.Time
+	buffer    []message
+
+	// Guards the metadata and the connections, held while producing.
+	sendLock    sync.Mutex
+	brokers     map[int32]broker
+	partitions  []partitionMetadata
+	lastRefresh time.Time
+	conns       map[int32]*brokerConn
+}
+
+// A stats sample as published to Kafka.
+type detailSpec struct {
+	Timestamp       int64                `json:"timestamp"`
+	MachineName     string               `json:"machine_name,omitempty"`
+	ContainerName   string               `json:"container_name,omitempty"`
+	ContainerImage  string               `json:"container_image,omitempty"`
+	ContainerLabels map[string]string    `json:"container_labels,omitempty"`
+	ContainerEnvs   map[string]string    `json:"container_envs,omitempty"`
+	ContainerStats  *info.ContainerStats `json:"container_stats,omitempty"`
+	// Set instead of the container fields in the messages holding the stats of the machine.
+	MachineStats *info.MachineStats `json:"machine_stats,omitempty"`
+}
+
+func newStorage() (storage.StorageDriver, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+	var tlsConfig *tls.Config
+	if *argTls {
+		tlsConfig, err = utils.NewTlsConfig(*argTlsCa, *argTlsCert, *argTlsKey, *argTlsInsecure)
+	} else {
+		tlsConfig, err = storage.TlsConfigFromFlags()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return New(hostname, strings.Split(*argBrokers, ","), *argTopic, tlsConfig, *argSaslUser, *argSaslPassword, *storage.ArgDbBufferDuration, *argBatchSize)
+}
+
+// Creates a Kafka storage driver. tlsConfig is nil to connect without TLS and saslUser is
+// empty to not authenticate. Stats are produced in batches every bufferDuration, or as soon as
+// batchSize samples are buffered.
+func New(machineName string, brokerAddrs []string, topic string, tlsConfig *tls.Config, saslUser, saslPassword string, bufferDuration time.Duration, batchSize int) (*kafkaStorage, error) {
+	ret := &kafkaStorage{
+		machineName:    machineName,
+		topic:          topic,
+		brokerAddrs:    brokerAddrs,
+		tlsConfig:      tlsConfig,
+		saslUser:       saslUser,
+		saslPassword:   saslPassword,
+		bufferDuration: bufferDuration,
+		batchSize:      batchSize,
+		lastWrite:      time.Now(),
+		conns:          make(map[int32]*brokerConn),
+	}
+	// Fail early if the cluster is unreachable.
+	if err := ret.refreshMetadata(); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func (self *kafkaStorage) dial(addr string) (*brokerConn, error) {
+	conn, err := net.DialTimeout("tcp", addr, requestTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if self.tlsConfig != nil {
+		config := self.tlsConfig.Clone()
+		if config.ServerName == "" {
+			config.ServerName, _, _ = net.SplitHostPort(addr)
+		}
+		tlsConn := tls.Client(conn, config)
+		tlsConn.SetDeadline(time.Now().Add(requestTimeout))
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+	bc := newBrokerConn(conn)
+	if self.saslUser != "" {
+		if err := bc.saslPlain(self.saslUser, self.saslPassword); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return bc, nil
+}
+
+// Looks up the partitions of the topic from the first reachable bootstrap broker.
+func (self *kafkaStorage) refreshMetadata() error {
+	var lastErr error
+	for _, addr := range self.brokerAddrs {
+		conn, err := self.dial(strings.TrimSpace(addr))
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		brokers, partitions, err := conn.metadata(self.topic)
+		conn.conn.Close()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if len(partitions) == 0 {
+			return fmt.Errorf("topic %q has no partitions with a leader", self.topic)
+		}
+		self.brokers = brokers
+		self.partitions = partitions
+		self.lastRefresh = time.Now()
+		return nil
+	}
+	return fmt.Errorf("failed to get metadata of topic %q from brokers %v: %v", self.topic, self.brokerAddrs, lastErr)
+}
+
+// Returns a connection to the broker with the specified ID.
+func (self *kafkaStorage) conn(id int32) (*brokerConn, error) {
+	if conn, ok := self.conns[id]; ok {
+		return conn, nil
+	}
+	b, ok := self.brokers[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown Kafka broker %d", id)
+	}
+	conn, err := self.dial(b.addr)
+	if err != nil {
+		return nil, err
+	}
+	self.conns[id] = conn
+	return conn, nil
+}
+
+func (self *kafkaStorage) closeConns() {
+	for id, conn := range self.conns {
+		conn.conn.Close()
+		delete(self.conns, id)
+	}
+}
+
+func (self *kafkaStorage) AddStats(ref info.ContainerReference, stats *info.ContainerStats) error {
+	if stats == nil {
+		return nil
+	}
+	return self.publish(ref.Name, stats.Timestamp, &detailSpec{
+		Timestamp:       stats.Timestamp.UnixNano(),
+		MachineName:     self.machineName,
+		ContainerName:   ref.Name,
+		ContainerImage:  ref.Image,
+		ContainerLabels: ref.Labels,
+		ContainerEnvs:   ref.Envs,
+		ContainerStats:  stats,
+	})
+}
+
+// Machine stats are keyed by the name of the machine.
+func (self *kafkaStorage) AddMachineStats(stats *info.MachineStats) error {
+	return self.publish(self.machineName, stats.Timestamp, &detailSpec{
+		Timestamp:    stats.Timestamp.UnixNano(),
+		MachineName:  self.machineName,
+		MachineStats: stats,
+	})
+}
+
+// Buffers the sample with the specified key and produces the buffer when it is due.
+func (self *kafkaStorage) publish(key string, timestamp time.Time, spec *detailSpec) error {
+	value, err := json.Marshal(spec)
+	if err != nil {
+		return err
+	}
+
+	var messages []message
+	func() {
+		// publish will be invoked simultaneously from multiple threads and only one of them will produce.
+		self.lock.Lock()
+		defer self.lock.Unlock()
+
+		self.buffer = append(self.buffer, message{[]byte(key), value, timestamp})
+		if len(self.buffer) >= self.batchSize || time.Since(self.lastWrite) >= self.bufferDuration {
+			messages = self.buffer
+			self.buffer = nil
+			self.lastWrite = time.Now()
+		}
+	}()
+	if len(messages) > 0 {
+		return self.send(messages)
+	}
+	return nil
+}
+
+// Produces the messages with a request per partition leader.
+func (self *kafkaStorage) send(messages []message) error {
+	self.sendLock.Lock()
+	defer self.sendLock.Unlock()
+	if self.partitions == nil || time.Since(self.lastRefresh) > metadataRefreshInterval {
+		if err := self.refreshMetadata(); err != nil {
+			return err
+		}
+	}
+
+	// The samples with the same key always go to the same partition so they stay ordered.
+	byLeader := make(map[int32]map[int32][]message)
+	for _, m := range messages {
+		partition := self.partitions[crc32.ChecksumIEEE(m.key)%uint32(len(self.partitions))]
+		if byLeader[partition.leader] == nil {
+			byLeader[partition.leader] = make(map[int32][]message)
+		}
+		byLeader[partition.leader][partition.id] = append(byLeader[partition.leader][partition.id], m)
+	}
+	for leader, partitions := range byLeader {
+		conn, err := self.conn(leader)
+		if err == nil {
+			err = conn.produce(self.topic, partitions)
+		}
+		if err != nil {
+			// Leadership may have moved, start over on the next batch.
+			glog.V(2).Infof("Failed to publish to Kafka, refreshing metadata: %v", err)
+			self.closeConns()
+			self.partitions = nil
+			return fmt.Errorf("failed to publish %d stats samples to Kafka: %v", len(messages), err)
+		}
+	}
+	return nil
+}
+
+func (self *kafkaStorage) RecentStats(containerName string, numStats int) ([]*info.ContainerStats, error) {
+	return nil, fmt.Errorf("the Kafka storage driver does not support reading stats")
+}
+
+func (self *kafkaStorage) Close() error {
+	self.lock.Lock()
+	messages := self.buffer
+	self.buffer = nil
+	self.lock.Unlock()
+	var err error
+	if len(messages) > 0 {
+		err = self.send(messages)
+	}
+	self.sendLock.Lock()
+	defer self.sendLock.Unlock()
+	self.closeConns()
+	return err
+}
@@ -0,0 +1,277 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafka
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/info"
+)
+
+type producedMessage struct {
+	topic     string
+	partition int32
+	key       []byte
+	value     []byte
+	timestamp time.Time
+}
+
+// Decodes the record batch of a produce request and checks its CRC.
+func decodeRecordBatch(t *testing.T, topic string, partition int32, d *decoder) []producedMessage {
+	d.int64() // Base offset.
+	d.int32() // Batch length.
+	d.int32() // Partition leader epoch.
+	if magic := d.int8(); magic != 2 {
+		t.Errorf("record batch has magic byte %d, expected 2", magic)
+	}
+	crc := uint32(d.int32())
+	if crc32.Checksum(d.buf, castagnoli) != crc {
+		t.Errorf("wrong record batch CRC")
+	}
+	d.int16() // Attributes.
+	lastOffsetDelta := d.int32()
+	first := d.int64()
+	d.int64() // Max timestamp.
+	d.int64() // Producer ID.
+	d.int16() // Producer epoch.
+	d.int32() // Base sequence.
+	count := d.arrayLen()
+	if int(lastOffsetDelta) != count-1 {
+		t.Errorf("record batch has last offset delta %d with %d records", lastOffsetDelta, count)
+	}
+	var messages []producedMessage
+	for i := 0; i < count; i++ {
+		record := &decoder{buf: d.next(int(d.varint()))}
+		record.int8() // Attributes.
+		timestamp := first + record.varint()
+		if offset := record.varint(); offset != int64(i) {
+			t.Errorf("record %d has offset delta %d", i, offset)
+		}
+		key := record.varBytes()
+		value := record.varBytes()
+		if headers := record.varint(); headers != 0 {
+			t.Errorf("record %d has %d headers", i, headers)
+		}
+		if record.err != nil || len(record.buf) != 0 {
+			t.Errorf("record %d is malformed: %v", i, record.err)
+		}
+		messages = append(messages, producedMessage{topic, partition, key, value, time.Unix(0, timestamp*int64(time.Millisecond))})
+	}
+	if d.err != nil {
+		t.Errorf("record batch is malformed: %v", d.err)
+	}
+	return messages
+}
+
+// Serves metadata and produce requests like a single broker with one partition, authenticating
+// user "user" with password "password". Each produce request is sent to produced.
+func fakeBroker(t *testing.T, listener net.Listener, produced chan []producedMessage) {
+	host, portStr, _ := net.SplitHostPort(listener.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func(conn net.Conn) {
+			defer conn.Close()
+			for {
+				var size [4]byte
+				if _, err := io.ReadFull(conn, size[:]); err != nil {
+					return
+				}
+				frame := make([]byte, binary.BigEndian.Uint32(size[:]))
+				if _, err := io.ReadFull(conn, frame); err != nil {
+					return
+				}
+				d := &decoder{buf: frame}
+				apiKey := d.int16()
+				version := d.int16()
+				correlationId := d.int32()
+				d.string()
+
+				var resp encoder
+				resp.int32(correlationId)
+				switch apiKey {
+				case apiKeySaslHandshake:
+					if version != saslHandshakeVersion || d.string() != "PLAIN" {
+						t.Errorf("unexpected SASL handshake version %d", version)
+					}
+					resp.int16(0)
+					resp.int32(1)
+					resp.string("PLAIN")
+				case apiKeySaslAuthenticate:
+					if string(d.bytes()) == "\x00user\x00password" {
+						resp.int16(0)
+						resp.nullString()
+					} else {
+						resp.int16(58) // SASL_AUTHENTICATION_FAILED
+						resp.string("wrong password")
+					}
+					resp.bytes([]byte{})
+				case apiKeyMetadata:
+					if version != metadataVersion {
+						t.Errorf("unexpected metadata version %d", version)
+					}
+					d.arrayLen()
+					topic := d.string()
+					resp.int32(0) // Throttle time.
+					resp.int32(1)
+					resp.int32(7)
+					resp.string(host)
+					resp.int32(int32(port))
+					resp.nullString()
+					resp.string("cluster")
+					resp.int32(7)
+					resp.int32(1)
+					resp.int16(0)
+					resp.string(topic)
+					resp.int8(0)
+					resp.int32(1)
+					resp.int16(0)
+					resp.int32(0)
+					resp.int32(7)
+					resp.int32(0)
+					resp.int32(0)
+				case apiKeyProduce:
+					if version != produceVersion {
+						t.Errorf("unexpected produce version %d", version)
+					}
+					d.string() // Transactional ID.
+					d.int16()
+					d.int32()
+					d.arrayLen()
+					topic := d.string()
+					d.arrayLen()
+					partition := d.int32()
+					produced <- decodeRecordBatch(t, topic, partition, &decoder{buf: d.bytes()})
+					resp.int32(1)
+					resp.string(topic)
+					resp.int32(1)
+					resp.int32(partition)
+					resp.int16(0)
+					resp.int64(0)
+					resp.int64(-1)
+					resp.int32(0) // Throttle time.
+				}
+				binary.BigEndian.PutUint32(size[:], uint32(len(resp.buf)))
+				conn.Write(append(size[:], resp.buf...))
+			}
+		}(conn)
+	}
+}
+
+func startFakeBroker(t *testing.T) (net.Listener, chan []producedMessage) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	produced := make(chan []producedMessage, 10)
+	go fakeBroker(t, listener, produced)
+	return listener, produced
+}
+
+func TestAddStats(t *testing.T) {
+	listener, produced := startFakeBroker(t)
+	defer listener.Close()
+
+	driver, err := New("machine", []string{listener.Addr().String()}, "stats", nil, "", "", 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer driver.Close()
+
+	stats := &info.ContainerStats{Timestamp: time.Unix(1400000000, 0)}
+	stats.Cpu.Usage.Total = 100
+	if err := driver.AddStats(info.ContainerReference{Name: "/test"}, stats); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := <-produced
+	if len(messages) != 1 {
+		t.Fatalf("produced %d messages, expected 1", len(messages))
+	}
+	msg := messages[0]
+	if msg.topic != "stats" || msg.partition != 0 || string(msg.key) != "/test" || !msg.timestamp.Equal(stats.Timestamp) {
+		t.Errorf("unexpected message %+v", msg)
+	}
+	var spec detailSpec
+	if err := json.Unmarshal(msg.value, &spec); err != nil {
+		t.Fatal(err)
+	}
+	if spec.MachineName != "machine" || spec.ContainerName != "/test" || spec.ContainerStats.Cpu.Usage.Total != 100 {
+		t.Errorf("unexpected stats published: %+v", spec)
+	}
+}
+
+func TestAddStatsBatches(t *testing.T) {
+	listener, produced := startFakeBroker(t)
+	defer listener.Close()
+
+	driver, err := New("machine", []string{listener.Addr().String()}, "stats", nil, "", "", time.Hour, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Unix(1400000000, 0)
+	names := []string{"/a", "/b", "/c", "/d"}
+	for i, name := range names {
+		stats := &info.ContainerStats{Timestamp: start.Add(time.Duration(i) * time.Second)}
+		if err := driver.AddStats(info.ContainerReference{Name: name}, stats); err != nil {
+			t.Fatal(err)
+		}
+		if i < 2 && len(produced) != 0 {
+			t.Fatalf("produced after %d samples, expected a batch of 3", i+1)
+		}
+	}
+	// The last sample stays buffered until the driver is closed.
+	if err := driver.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []producedMessage
+	for _, size := range []int{3, 1} {
+		messages := <-produced
+		if len(messages) != size {
+			t.Fatalf("produced a batch of %d messages, expected %d", len(messages), size)
+		}
+		got = append(got, messages...)
+	}
+	for i, msg := range got {
+		if string(msg.key) != names[i] || !msg.timestamp.Equal(start.Add(time.Duration(i)*time.Second)) {
+			t.Errorf("unexpected message %d: key %q at %v", i, msg.key, msg.timestamp)
+		}
+	}
+}
+
+func TestSaslPlain(t *testing.T) {
+	listener, _ := startFakeBroker(t)
+	defer listener.Close()
+
+	driver, err := New("machine", []string{listener.Addr().String()}, "stats", nil, "user", "password", 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	driver.Close()
+	if _, err := New("machine", []string{listener.Addr().String()}, "stats", nil, "user", "wrong", 0, 100); err == nil {
+		t.Errorf("authenticated with a wrong password")
+	}
+}
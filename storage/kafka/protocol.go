@@ -0,0 +1,433 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafka
+
+// Minimal client of the Kafka wire protocol, enough to produce messages.
+// See https://kafka.apache.org/protocol for the format of the requests. The versions used are
+// supported by Kafka 1.0 and later, including Kafka 4.0 which removed the older ones.
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"time"
+)
+
+const (
+	apiKeyProduce          = 0
+	apiKeyMetadata         = 3
+	apiKeySaslHandshake    = 17
+	apiKeySaslAuthenticate = 36
+
+	// Versions of the requests: produce with record batches, and SASL tokens sent in requests.
+	produceVersion          = 3
+	metadataVersion         = 4
+	saslHandshakeVersion    = 1
+	saslAuthenticateVersion = 0
+
+	clientId = "cadvisor"
+
+	requestTimeout = 10 * time.Second
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// Encodes a request body.
+type encoder struct {
+	buf []byte
+}
+
+func (self *encoder) int8(v int8) {
+	self.buf = append(self.buf, byte(v))
+}
+
+func (self *encoder) int16(v int16) {
+	self.buf = append(self.buf, byte(v>>8), byte(v))
+}
+
+func (self *encoder) int32(v int32) {
+	self.buf = append(self.buf, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func (self *encoder) int64(v int64) {
+	self.int32(int32(v >> 32))
+	self.int32(int32(v))
+}
+
+// Encodes a zig-zag variable-length integer, as in records.
+func (self *encoder) varint(v int64) {
+	self.buf = binary.AppendVarint(self.buf, v)
+}
+
+func (self *encoder) string(s string) {
+	self.int16(int16(len(s)))
+	self.buf = append(self.buf, s...)
+}
+
+// Encodes a null string.
+func (self *encoder) nullString() {
+	self.int16(-1)
+}
+
+// Encodes nil as a null byte array.
+func (self *encoder) bytes(b []byte) {
+	if b == nil {
+		self.int32(-1)
+		return
+	}
+	self.int32(int32(len(b)))
+	self.buf = append(self.buf, b...)
+}
+
+// Encodes nil as a null byte array, with a variable-length size as in records.
+func (self *encoder) varBytes(b []byte) {
+	if b == nil {
+		self.varint(-1)
+		return
+	}
+	self.varint(int64(len(b)))
+	self.buf = append(self.buf, b...)
+}
+
+// Decodes a response body. Decoding errors are sticky and reported by err.
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (self *decoder) next(n int) []byte {
+	if self.err != nil {
+		return nil
+	}
+	if n < 0 || len(self.buf) < n {
+		self.err = fmt.Errorf("kafka response is too short")
+		self.buf = nil
+		return nil
+	}
+	b := self.buf[:n]
+	self.buf = self.buf[n:]
+	return b
+}
+
+func (self *decoder) int8() int8 {
+	b := self.next(1)
+	if b == nil {
+		return 0
+	}
+	return int8(b[0])
+}
+
+func (self *decoder) int16() int16 {
+	b := self.next(2)
+	if b == nil {
+		return 0
+	}
+	return int16(binary.BigEndian.Uint16(b))
+}
+
+func (self *decoder) int32() int32 {
+	b := self.next(4)
+	if b == nil {
+		return 0
+	}
+	return int32(binary.BigEndian.Uint32(b))
+}
+
+func (self *decoder) int64() int64 {
+	b := self.next(8)
+	if b == nil {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(b))
+}
+
+func (self *decoder) varint() int64 {
+	if self.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(self.buf)
+	if n <= 0 {
+		self.err = fmt.Errorf("kafka varint is malformed")
+		self.buf = nil
+		return 0
+	}
+	self.buf = self.buf[n:]
+	return v
+}
+
+// Returns "" for a null string.
+func (self *decoder) string() string {
+	n := self.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(self.next(int(n)))
+}
+
+// Returns nil for a null byte array.
+func (self *decoder) bytes() []byte {
+	n := self.int32()
+	if n < 0 {
+		return nil
+	}
+	return self.next(int(n))
+}
+
+// Returns nil for a null byte array, with a variable-length size as in records.
+func (self *decoder) varBytes() []byte {
+	n := self.varint()
+	if n < 0 {
+		return nil
+	}
+	return self.next(int(n))
+}
+
+// Returns the length of an array, 0 if it is null.
+func (self *decoder) arrayLen() int {
+	n := self.int32()
+	if n < 0 || self.err != nil {
+		return 0
+	}
+	return int(n)
+}
+
+// A message to produce.
+type message struct {
+	key       []byte
+	value     []byte
+	timestamp time.Time
+}
+
+// Encodes the messages in a record batch (format v2), uncompressed and not transactional.
+func encodeRecordBatch(messages []message) []byte {
+	first := messages[0].timestamp.UnixNano() / int64(time.Millisecond)
+	max := first
+	var records encoder
+	for i, m := range messages {
+		timestamp := m.timestamp.UnixNano() / int64(time.Millisecond)
+		if timestamp > max {
+			max = timestamp
+		}
+		var record encoder
+		record.int8(0) // Attributes.
+		record.varint(timestamp - first)
+		record.varint(int64(i)) // Offset delta.
+		record.varBytes(m.key)
+		record.varBytes(m.value)
+		record.varint(0) // Headers.
+		records.varint(int64(len(record.buf)))
+		records.buf = append(records.buf, record.buf...)
+	}
+
+	// The fields covered by the CRC.
+	var batch encoder
+	batch.int16(0) // Attributes.
+	batch.int32(int32(len(messages) - 1))
+	batch.int64(first)
+	batch.int64(max)
+	batch.int64(-1) // Producer ID.
+	batch.int16(-1) // Producer epoch.
+	batch.int32(-1) // Base sequence.
+	batch.int32(int32(len(messages)))
+	batch.buf = append(batch.buf, records.buf...)
+
+	var header encoder
+	header.int64(0) // Base offset, assigned by the broker.
+	header.int32(int32(4 + 1 + 4 + len(batch.buf)))
+	header.int32(-1) // Partition leader epoch.
+	header.int8(2)   // Magic byte.
+	header.int32(int32(crc32.Checksum(batch.buf, castagnoli)))
+	return append(header.buf, batch.buf...)
+}
+
+// A connection to a broker.
+type brokerConn struct {
+	conn          net.Conn
+	reader        *bufio.Reader
+	correlationId int32
+}
+
+func newBrokerConn(conn net.Conn) *brokerConn {
+	return &brokerConn{
+		conn:   conn,
+		reader: bufio.NewReader(conn),
+	}
+}
+
+// Sends a raw size-prefixed frame and returns the raw size-prefixed reply.
+func (self *brokerConn) roundTrip(frame []byte) ([]byte, error) {
+	self.conn.SetDeadline(time.Now().Add(requestTimeout))
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(len(frame)))
+	if _, err := self.conn.Write(append(size[:], frame...)); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(self.reader, size[:]); err != nil {
+		return nil, err
+	}
+	reply := make([]byte, binary.BigEndian.Uint32(size[:]))
+	if _, err := io.ReadFull(self.reader, reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// Sends a request and returns the body of its response.
+func (self *brokerConn) request(apiKey, version int16, body []byte) (*decoder, error) {
+	self.correlationId++
+	var header encoder
+	header.int16(apiKey)
+	header.int16(version)
+	header.int32(self.correlationId)
+	header.string(clientId)
+
+	reply, err := self.roundTrip(append(header.buf, body...))
+	if err != nil {
+		return nil, err
+	}
+	d := &decoder{buf: reply}
+	if id := d.int32(); id != self.correlationId {
+		return nil, fmt.Errorf("kafka response has correlation ID %d, expected %d", id, self.correlationId)
+	}
+	return d, d.err
+}
+
+// Authenticates with SASL PLAIN.
+func (self *brokerConn) saslPlain(user, password string) error {
+	var body encoder
+	body.string("PLAIN")
+	d, err := self.request(apiKeySaslHandshake, saslHandshakeVersion, body.buf)
+	if err != nil {
+		return err
+	}
+	if code := d.int16(); code != 0 {
+		return fmt.Errorf("broker does not support SASL PLAIN (error %d)", code)
+	}
+
+	body = encoder{}
+	body.bytes([]byte("\x00" + user + "\x00" + password))
+	d, err = self.request(apiKeySaslAuthenticate, saslAuthenticateVersion, body.buf)
+	if err != nil {
+		return fmt.Errorf("SASL authentication failed: %v", err)
+	}
+	code := d.int16()
+	message := d.string()
+	if d.err != nil {
+		return fmt.Errorf("SASL authentication failed: %v", d.err)
+	}
+	if code != 0 {
+		return fmt.Errorf("SASL authentication failed (error %d): %s", code, message)
+	}
+	return nil
+}
+
+type broker struct {
+	id   int32
+	addr string
+}
+
+type partitionMetadata struct {
+	id     int32
+	leader int32
+}
+
+// Returns the brokers of the cluster and the partitions of the topic, which is not created if
+// missing.
+func (self *brokerConn) metadata(topic string) (map[int32]broker, []partitionMetadata, error) {
+	var body encoder
+	body.int32(1)
+	body.string(topic)
+	body.int8(0) // Do not create the topic.
+	d, err := self.request(apiKeyMetadata, metadataVersion, body.buf)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	d.int32() // Throttle time.
+	brokers := make(map[int32]broker)
+	for i := d.arrayLen(); i > 0; i-- {
+		id := d.int32()
+		host := d.string()
+		port := d.int32()
+		d.string() // Rack.
+		brokers[id] = broker{id, net.JoinHostPort(host, fmt.Sprint(port))}
+	}
+	d.string() // Cluster ID.
+	d.int32()  // Controller ID.
+	var partitions []partitionMetadata
+	for i := d.arrayLen(); i > 0; i-- {
+		code := d.int16()
+		name := d.string()
+		d.int8() // Internal.
+		if code != 0 && d.err == nil {
+			return nil, nil, fmt.Errorf("failed to get metadata of topic %q (error %d)", name, code)
+		}
+		for j := d.arrayLen(); j > 0; j-- {
+			d.int16() // Partition error, the leader is what matters.
+			id := d.int32()
+			leader := d.int32()
+			for k := d.arrayLen(); k > 0; k-- {
+				d.int32() // Replicas.
+			}
+			for k := d.arrayLen(); k > 0; k-- {
+				d.int32() // In-sync replicas.
+			}
+			if name == topic && leader >= 0 {
+				partitions = append(partitions, partitionMetadata{id, leader})
+			}
+		}
+	}
+	if d.err != nil {
+		return nil, nil, d.err
+	}
+	return brokers, partitions, nil
+}
+
+// Produces the messages to the partitions of the topic they are keyed by, a record batch per
+// partition in a single request, and waits for the leader to acknowledge them.
+func (self *brokerConn) produce(topic string, messages map[int32][]message) error {
+	var body encoder
+	body.nullString() // Transactional ID.
+	body.int16(1)     // Required acks, only the leader.
+	body.int32(int32(requestTimeout / time.Millisecond))
+	body.int32(1)
+	body.string(topic)
+	body.int32(int32(len(messages)))
+	for partition, m := range messages {
+		body.int32(partition)
+		body.bytes(encodeRecordBatch(m))
+	}
+
+	d, err := self.request(apiKeyProduce, produceVersion, body.buf)
+	if err != nil {
+		return err
+	}
+	for i := d.arrayLen(); i > 0; i-- {
+		d.string()
+		for j := d.arrayLen(); j > 0; j-- {
+			partition := d.int32()
+			code := d.int16()
+			d.int64() // Base offset.
+			d.int64() // Log append time.
+			if code != 0 && d.err == nil {
+				return fmt.Errorf("failed to produce to partition %d of topic %q (error %d)", partition, topic, code)
+			}
+		}
+	}
+	return d.err
+}
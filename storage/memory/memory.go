@@ -16,7 +16,9 @@ package memory
 
 import (
 	"fmt"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/golang/glog"
 	"github.com/google/cadvisor/info"
@@ -58,6 +60,22 @@ func (self *containerStorage) RecentStats(numStats int) ([]*info.ContainerStats,
 	return self.recentStats.FirstN(numStats), nil
 }
 
+func (self *containerStorage) StatsInRange(start, end time.Time, maxStats int) []*info.ContainerStats {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	return self.recentStats.InTimeRange(start, end, maxStats)
+}
+
+// Returns the timestamp of the oldest stats held, false if none is.
+func (self *containerStorage) Oldest() (time.Time, bool) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	if self.recentStats.Size() == 0 {
+		return time.Time{}, false
+	}
+	return self.recentStats.get(0).Timestamp, true
+}
+
 func newContainerStore(ref info.ContainerReference, maxNumStats int) *containerStorage {
 	return &containerStorage{
 		ref:         ref,
@@ -71,6 +89,14 @@ type InMemoryStorage struct {
 	containerStorageMap map[string]*containerStorage
 	maxNumStats         int
 	backend             storage.StorageDriver
+	// Serves the stats not held in memory, nil if the backend keeps no history or reading it is
+	// disabled.
+	history storage.HistoryDriver
+	// Serves the time ranges not held in memory, nil if the backend cannot find them or reading
+	// them is disabled.
+	timeRange storage.TimeRangeDriver
+	// Most stats read from the backend for a single request.
+	maxHistoryStats int
 }
 
 func (self *InMemoryStorage) AddStats(ref info.ContainerReference, stats *info.ContainerStats) error {
@@ -97,39 +123,161 @@ func (self *InMemoryStorage) AddStats(ref info.ContainerReference, stats *info.C
 	return cstore.AddStats(stats)
 }
 
+// Machine stats are not kept in memory, they are only written to the backend.
+func (self *InMemoryStorage) AddMachineStats(stats *info.MachineStats) error {
+	if machineDriver, ok := self.backend.(storage.MachineStatsDriver); ok {
+		return machineDriver.AddMachineStats(stats)
+	}
+	return nil
+}
+
+// Reports the status of the backend, the in-memory storage itself does not fail.
+func (self *InMemoryStorage) Status() []info.StorageDriverStatus {
+	if statusDriver, ok := self.backend.(storage.StatusDriver); ok {
+		return statusDriver.Status()
+	}
+	return nil
+}
+
+// Only the stats held in memory are returned, the backend is not read. Use History to also read the
+// older stats of the backend.
 func (self *InMemoryStorage) RecentStats(name string, numStats int) ([]*info.ContainerStats, error) {
 	var cstore *containerStorage
 	var ok bool
-	err := func() error {
+	func() {
 		self.lock.RLock()
 		defer self.lock.RUnlock()
-		if cstore, ok = self.containerStorageMap[name]; !ok {
-			return fmt.Errorf("unable to find data for container %v", name)
-		}
-		return nil
+		cstore, ok = self.containerStorageMap[name]
 	}()
-	if err != nil {
-		return nil, err
+	if !ok {
+		return nil, fmt.Errorf("unable to find data for container %v", name)
 	}
-
 	return cstore.RecentStats(numStats)
 }
 
+// Same as RecentStats, but the stats older than those held in memory are read from the backend if
+// it keeps a history, e.g. after cAdvisor restarted. At most maxHistoryStats are read from it.
+func (self *InMemoryStorage) History(name string, numStats int) ([]*info.ContainerStats, error) {
+	self.lock.RLock()
+	cstore, ok := self.containerStorageMap[name]
+	self.lock.RUnlock()
+
+	var stats []*info.ContainerStats
+	var oldest time.Time
+	if ok {
+		var err error
+		stats, err = cstore.RecentStats(numStats)
+		if err != nil {
+			return nil, err
+		}
+		oldest, _ = cstore.Oldest()
+	}
+	if self.history != nil && numStats != 0 && (numStats < 0 || len(stats) < numStats) {
+		// Older stats may be in the history of the backend.
+		history, err := self.history.History(name, self.historyLimit(numStats))
+		if err != nil {
+			glog.V(4).Infof("Failed to read history of %q: %v", name, err)
+		} else if len(history) != 0 {
+			return backfill(history, oldest, stats, numStats), nil
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("unable to find data for container %v", name)
+	}
+	return stats, nil
+}
+
+// The stats older than those held in memory are read from the backend, if it can find them.
+func (self *InMemoryStorage) StatsInRange(name string, start, end time.Time, maxStats int) ([]*info.ContainerStats, error) {
+	self.lock.RLock()
+	cstore, ok := self.containerStorageMap[name]
+	self.lock.RUnlock()
+
+	var stats []*info.ContainerStats
+	var oldest time.Time
+	held := false
+	if ok {
+		stats = cstore.StatsInRange(start, end, maxStats)
+		oldest, held = cstore.Oldest()
+	}
+	if self.timeRange != nil && maxStats != 0 && (maxStats < 0 || len(stats) < maxStats) && (!held || start.Before(oldest)) {
+		older, err := self.timeRange.StatsInRange(name, start, end, self.historyLimit(maxStats))
+		if err != nil {
+			glog.V(4).Infof("Failed to read stats of %q from %v to %v: %v", name, start, end, err)
+		} else if len(older) != 0 {
+			return backfill(older, oldest, stats, maxStats), nil
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("unable to find data for container %v", name)
+	}
+	return stats, nil
+}
+
+// Returns the number of stats to read from the backend for a request of numStats, all if negative.
+func (self *InMemoryStorage) historyLimit(numStats int) int {
+	if numStats < 0 || numStats > self.maxHistoryStats {
+		return self.maxHistoryStats
+	}
+	return numStats
+}
+
+// Returns the stats of the backend older than the oldest held in memory (all if it is zero),
+// followed by the stats held in memory. Only the latest maxStats are returned, all if negative.
+func backfill(older []*info.ContainerStats, oldest time.Time, stats []*info.ContainerStats, maxStats int) []*info.ContainerStats {
+	if !oldest.IsZero() {
+		older = older[:sort.Search(len(older), func(i int) bool {
+			return !older[i].Timestamp.Before(oldest)
+		})]
+	}
+	ret := make([]*info.ContainerStats, 0, len(older)+len(stats))
+	ret = append(append(ret, older...), stats...)
+	if maxStats >= 0 && len(ret) > maxStats {
+		ret = ret[len(ret)-maxStats:]
+	}
+	return ret
+}
+
+// Drops the stats held for the container. Those of its backend are kept.
+func (self *InMemoryStorage) RemoveContainer(containerName string) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	delete(self.containerStorageMap, containerName)
+}
+
+// Closes the backend too, which writes the stats it buffers.
 func (self *InMemoryStorage) Close() error {
 	self.lock.Lock()
 	self.containerStorageMap = make(map[string]*containerStorage, 32)
 	self.lock.Unlock()
+	if self.backend != nil {
+		return self.backend.Close()
+	}
 	return nil
 }
 
+// Creates an in-memory storage keeping maxNumStats stats per container and writing them to the
+// backend, if not nil. Up to maxHistoryStats stats older than those held are read back from the
+// backend per request, none if it is 0.
 func New(
 	maxNumStats int,
 	backend storage.StorageDriver,
+	maxHistoryStats int,
 ) *InMemoryStorage {
 	ret := &InMemoryStorage{
 		containerStorageMap: make(map[string]*containerStorage, 32),
 		maxNumStats:         maxNumStats,
 		backend:             backend,
+		maxHistoryStats:     maxHistoryStats,
+	}
+	if maxHistoryStats <= 0 {
+		return ret
+	}
+	if history, ok := backend.(storage.HistoryDriver); ok {
+		ret.history = history
+	}
+	if timeRange, ok := backend.(storage.TimeRangeDriver); ok {
+		ret.timeRange = timeRange
 	}
 	return ret
 }
@@ -15,7 +15,10 @@
 package memory
 
 import (
+	"fmt"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/google/cadvisor/info"
 	"github.com/google/cadvisor/storage"
@@ -35,7 +38,7 @@ func runStorageTest(f func(test.TestStorageDriver, *testing.T), t *testing.T) {
 
 	for N := 10; N < maxSize; N += 10 {
 		testDriver := &memoryTestStorageDriver{}
-		testDriver.StorageDriver = New(N, nil)
+		testDriver.StorageDriver = New(N, nil, 0)
 		f(testDriver, t)
 	}
 }
@@ -55,3 +58,138 @@ func TestNoRecentStats(t *testing.T) {
 func TestRetrieveZeroStats(t *testing.T) {
 	runStorageTest(test.StorageDriverTestRetrieveZeroRecentStats, t)
 }
+
+// Backend keeping a history of the stats of a single container.
+type historyBackend struct {
+	name  string
+	stats []*info.ContainerStats
+}
+
+func (self *historyBackend) AddStats(ref info.ContainerReference, stats *info.ContainerStats) error {
+	return nil
+}
+
+func (self *historyBackend) RecentStats(name string, numStats int) ([]*info.ContainerStats, error) {
+	return nil, fmt.Errorf("not supported")
+}
+
+func (self *historyBackend) History(name string, numStats int) ([]*info.ContainerStats, error) {
+	if name != self.name {
+		return nil, fmt.Errorf("no history for %q", name)
+	}
+	if numStats >= 0 && numStats < len(self.stats) {
+		return self.stats[len(self.stats)-numStats:], nil
+	}
+	return self.stats, nil
+}
+
+func (self *historyBackend) Close() error {
+	return nil
+}
+
+func TestHistory(t *testing.T) {
+	backend := &historyBackend{name: "/a"}
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		backend.stats = append(backend.stats, &info.ContainerStats{Timestamp: now.Add(time.Duration(i) * time.Second)})
+	}
+	driver := New(2, backend, 10)
+	ref := info.ContainerReference{Name: "/a"}
+	for _, stats := range backend.stats[3:] {
+		driver.AddStats(ref, stats)
+	}
+
+	stats, err := driver.History("/a", 2)
+	if err != nil || len(stats) != 2 {
+		t.Errorf("expected 2 stats from memory, got %d: %v", len(stats), err)
+	}
+	stats, err = driver.History("/a", 4)
+	if err != nil || len(stats) != 4 {
+		t.Errorf("expected 4 stats from the history, got %d: %v", len(stats), err)
+	}
+	// The housekeeping reads only the stats held in memory.
+	stats, err = driver.RecentStats("/a", 4)
+	if err != nil || len(stats) != 2 {
+		t.Errorf("expected 2 stats from memory, got %d: %v", len(stats), err)
+	}
+
+	// Containers only in the history, e.g. after a restart.
+	driver = New(2, backend, 10)
+	stats, err = driver.History("/a", -1)
+	if err != nil || len(stats) != 5 {
+		t.Errorf("expected 5 stats from the history, got %d: %v", len(stats), err)
+	}
+	if _, err := driver.History("/b", -1); err == nil {
+		t.Error("expected an error for an unknown container")
+	}
+	if _, err := driver.RecentStats("/a", -1); err == nil {
+		t.Error("expected an error for a container not held in memory")
+	}
+
+	// Reads from the backend are capped.
+	driver = New(2, backend, 3)
+	stats, err = driver.History("/a", -1)
+	if err != nil || !reflect.DeepEqual(stats, backend.stats[2:]) {
+		t.Errorf("expected the 3 latest stats from the history, got %v: %v", stats, err)
+	}
+
+	// The backend is not read unless a history is configured.
+	driver = New(2, backend, 0)
+	if _, err := driver.History("/a", -1); err == nil {
+		t.Error("expected an error for a container not held in memory")
+	}
+}
+
+// Backend finding the time ranges of the history of a single container.
+type timeRangeBackend struct {
+	historyBackend
+}
+
+func (self *timeRangeBackend) StatsInRange(name string, start, end time.Time, maxStats int) ([]*info.ContainerStats, error) {
+	if name != self.name {
+		return nil, fmt.Errorf("no history for %q", name)
+	}
+	var ret []*info.ContainerStats
+	for _, stats := range self.stats {
+		if (start.IsZero() || !stats.Timestamp.Before(start)) && (end.IsZero() || !stats.Timestamp.After(end)) {
+			ret = append(ret, stats)
+		}
+	}
+	if maxStats >= 0 && len(ret) > maxStats {
+		ret = ret[len(ret)-maxStats:]
+	}
+	return ret, nil
+}
+
+func TestStatsInRangeFromBackend(t *testing.T) {
+	backend := &timeRangeBackend{historyBackend{name: "/a"}}
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		backend.stats = append(backend.stats, &info.ContainerStats{Timestamp: now.Add(time.Duration(i) * time.Second)})
+	}
+	driver := New(2, backend, 10)
+	ref := info.ContainerReference{Name: "/a"}
+	for _, stats := range backend.stats[3:] {
+		driver.AddStats(ref, stats)
+	}
+
+	// Ranges held in memory are not read from the backend.
+	stats, err := driver.StatsInRange("/a", now.Add(3*time.Second), time.Time{}, -1)
+	if err != nil || !reflect.DeepEqual(stats, backend.stats[3:]) {
+		t.Errorf("expected the 2 stats held in memory, got %v: %v", stats, err)
+	}
+
+	// Older stats are read from the backend.
+	stats, err = driver.StatsInRange("/a", now.Add(time.Second), time.Time{}, -1)
+	if err != nil || !reflect.DeepEqual(stats, backend.stats[1:]) {
+		t.Errorf("expected 4 stats, got %v: %v", stats, err)
+	}
+	stats, err = driver.StatsInRange("/a", time.Time{}, now.Add(time.Second), -1)
+	if err != nil || !reflect.DeepEqual(stats, backend.stats[:2]) {
+		t.Errorf("expected the 2 oldest stats, got %v: %v", stats, err)
+	}
+	stats, err = driver.StatsInRange("/a", time.Time{}, time.Time{}, 3)
+	if err != nil || !reflect.DeepEqual(stats, backend.stats[2:]) {
+		t.Errorf("expected the 3 latest stats, got %v: %v", stats, err)
+	}
+}
@@ -1,12 +1,17 @@
 package memory
 
 import (
+	"sort"
+	"time"
+
 	"github.com/google/cadvisor/info"
 )
 
-// A circular buffer for ContainerStats.
+// A circular buffer for ContainerStats. Adding stats is O(1) and does not allocate, the buffer
+// holds the stats themselves which must not be modified once added. Stats are expected to be added
+// in time order, time ranges are found by binary search.
 type StatsBuffer struct {
-	buffer []info.ContainerStats
+	buffer []*info.ContainerStats
 	size   int
 	index  int
 }
@@ -14,7 +19,7 @@ type StatsBuffer struct {
 // Returns a new thread-compatible StatsBuffer.
 func NewStatsBuffer(size int) *StatsBuffer {
 	return &StatsBuffer{
-		buffer: make([]info.ContainerStats, size),
+		buffer: make([]*info.ContainerStats, size),
 		size:   0,
 		index:  size - 1,
 	}
@@ -26,7 +31,21 @@ func (self *StatsBuffer) Add(item *info.ContainerStats) {
 		self.size++
 	}
 	self.index = (self.index + 1) % len(self.buffer)
-	self.buffer[self.index] = *item
+	self.buffer[self.index] = item
+}
+
+// Returns the i-th oldest element, i must be less than the size of the buffer.
+func (self *StatsBuffer) get(i int) *info.ContainerStats {
+	return self.buffer[(self.index-self.size+1+i+len(self.buffer))%len(self.buffer)]
+}
+
+// Returns the elements from the i-th oldest to the j-th oldest, excluded.
+func (self *StatsBuffer) slice(i, j int) []*info.ContainerStats {
+	res := make([]*info.ContainerStats, j-i)
+	for k := range res {
+		res[k] = self.get(i + k)
+	}
+	return res
 }
 
 // Returns the first N elements in the buffer. If N > size of buffer, size of buffer elements are returned.
@@ -35,20 +54,32 @@ func (self *StatsBuffer) FirstN(n int) []*info.ContainerStats {
 	if n > self.size {
 		n = self.size
 	}
+	return self.slice(self.size-n, self.size)
+}
 
-	// index points to the latest element, get n before that one (keeping in mind we may have gone through 0).
-	start := self.index - (n - 1)
-	if start < 0 {
-		start += len(self.buffer)
+// Returns the elements timestamped from start to end, inclusive, oldest first. A zero start or end
+// leaves the range open on that side. Only the latest maxResults elements are returned, all if
+// maxResults is negative.
+func (self *StatsBuffer) InTimeRange(start, end time.Time, maxResults int) []*info.ContainerStats {
+	first := 0
+	if !start.IsZero() {
+		first = sort.Search(self.size, func(i int) bool {
+			return !self.get(i).Timestamp.Before(start)
+		})
 	}
-
-	// Copy the elements.
-	res := make([]*info.ContainerStats, n)
-	for i := 0; i < n; i++ {
-		index := (start + i) % len(self.buffer)
-		res[i] = &self.buffer[index]
+	last := self.size
+	if !end.IsZero() {
+		last = sort.Search(self.size, func(i int) bool {
+			return self.get(i).Timestamp.After(end)
+		})
 	}
-	return res
+	if last < first {
+		last = first
+	}
+	if maxResults >= 0 && last-first > maxResults {
+		first = last - maxResults
+	}
+	return self.slice(first, last)
 }
 
 func (self *StatsBuffer) Size() int {
@@ -1,7 +1,9 @@
 package memory
 
 import (
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/google/cadvisor/info"
 
@@ -62,3 +64,51 @@ func TestAddAndFirstN(t *testing.T) {
 	expectSize(t, sb, 5)
 	expectElements(t, sb, []int32{6, 7, 8, 9, 10})
 }
+
+func TestInTimeRange(t *testing.T) {
+	sb := NewStatsBuffer(5)
+	start := time.Now()
+	for i := 1; i <= 7; i++ {
+		stats := createStats(int32(i))
+		stats.Timestamp = start.Add(time.Duration(i) * time.Second)
+		sb.Add(stats)
+	}
+	// The buffer holds the stats of 3s to 7s.
+	at := func(i int) time.Time {
+		return start.Add(time.Duration(i) * time.Second)
+	}
+	for _, test := range []struct {
+		start, end time.Time
+		maxResults int
+		expected   []int32
+	}{
+		{time.Time{}, time.Time{}, -1, []int32{3, 4, 5, 6, 7}},
+		{at(4), at(6), -1, []int32{4, 5, 6}},
+		{at(4), time.Time{}, 2, []int32{6, 7}},
+		{time.Time{}, at(5), -1, []int32{3, 4, 5}},
+		{at(1), at(2), -1, []int32{}},
+		{at(8), time.Time{}, -1, []int32{}},
+		{at(6), at(4), -1, []int32{}},
+		{at(3), at(7), 0, []int32{}},
+	} {
+		res := sb.InTimeRange(test.start, test.end, test.maxResults)
+		loads := []int32{}
+		for _, stats := range res {
+			loads = append(loads, stats.Cpu.Load)
+		}
+		if !reflect.DeepEqual(loads, test.expected) {
+			t.Errorf("expected %v from %v to %v, got %v", test.expected, test.start.Sub(start), test.end.Sub(start), loads)
+		}
+	}
+}
+
+func TestAddDoesNotAllocate(t *testing.T) {
+	sb := NewStatsBuffer(5)
+	stats := createStats(1)
+	allocs := testing.AllocsPerRun(100, func() {
+		sb.Add(stats)
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations adding stats, got %v", allocs)
+	}
+}
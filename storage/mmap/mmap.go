@@ -0,0 +1,264 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows
+// +build !windows
+
+// Package mmap implements an experimental storage driver that writes every stats sample into a
+// ring buffer in a memory-mapped file. Co-located consumers can map the same file and read the
+// stats without going through the HTTP API. The layout of the file is described in README.md.
+package mmap
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/google/cadvisor/info"
+	"github.com/google/cadvisor/storage"
+)
+
+var argPath = flag.String("storage_driver_mmap_path", "/var/run/cadvisor/stats.ring", "file the mmap storage driver writes its ring buffer to")
+var argSlots = flag.Int("storage_driver_mmap_slots", 4096, "number of samples kept in the ring buffer of the mmap storage driver")
+
+const (
+	magic   = "CADVRING"
+	version = 1
+
+	headerSize = 64
+	slotSize   = 256
+
+	// Offsets in the header.
+	headerMagic    = 0
+	headerVersion  = 8
+	headerSlotSize = 12
+	headerNumSlots = 16
+	headerWritten  = 24
+
+	// Offsets in a slot.
+	slotSequence    = 0
+	slotTimestamp   = 8
+	slotCpuTotal    = 16
+	slotCpuUser     = 24
+	slotCpuSystem   = 32
+	slotMemoryUsage = 40
+	slotWorkingSet  = 48
+	slotRxBytes     = 56
+	slotTxBytes     = 64
+	slotFsUsage     = 72
+	slotNameLength  = 80
+	slotName        = 82
+	maxNameLength   = slotSize - slotName
+)
+
+func init() {
+	storage.RegisterStorageDriver("mmap", newStorage)
+}
+
+type mmapStorage struct {
+	file     *os.File
+	data     []byte
+	numSlots uint64
+
+	// Number of samples written so far.
+	written uint64
+	lock    sync.Mutex
+}
+
+func newStorage() (storage.StorageDriver, error) {
+	return New(*argPath, *argSlots)
+}
+
+// Creates (or truncates) the ring buffer file with the specified number of slots.
+func New(path string, numSlots int) (*mmapStorage, error) {
+	if numSlots <= 0 {
+		return nil, fmt.Errorf("invalid number of slots %d", numSlots)
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, err
+	}
+	size := headerSize + numSlots*slotSize
+	err = file.Truncate(int64(size))
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	data, err := syscall.Mmap(int(file.Fd()), 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to mmap %q: %v", path, err)
+	}
+
+	copy(data[headerMagic:], magic)
+	putUint32(data, headerVersion, version)
+	putUint32(data, headerSlotSize, slotSize)
+	putUint32(data, headerNumSlots, uint32(numSlots))
+	return &mmapStorage{
+		file:     file,
+		data:     data,
+		numSlots: uint64(numSlots),
+	}, nil
+}
+
+func (self *mmapStorage) AddStats(ref info.ContainerReference, stats *info.ContainerStats) error {
+	if stats == nil {
+		return nil
+	}
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if self.data == nil {
+		return fmt.Errorf("mmap storage is closed")
+	}
+
+	slot := self.data[headerSize+(self.written%self.numSlots)*slotSize:][:slotSize]
+
+	// Invalidate the slot while it is being written so readers skip it.
+	atomic.StoreUint64(uint64At(slot, slotSequence), 0)
+
+	putUint64(slot, slotTimestamp, uint64(stats.Timestamp.UnixNano()))
+	putUint64(slot, slotCpuTotal, stats.Cpu.Usage.Total)
+	putUint64(slot, slotCpuUser, stats.Cpu.Usage.User)
+	putUint64(slot, slotCpuSystem, stats.Cpu.Usage.System)
+	putUint64(slot, slotMemoryUsage, stats.Memory.Usage)
+	putUint64(slot, slotWorkingSet, stats.Memory.WorkingSet)
+	putUint64(slot, slotRxBytes, stats.Network.RxBytes)
+	putUint64(slot, slotTxBytes, stats.Network.TxBytes)
+	var fsUsage uint64
+	for _, fs := range stats.Filesystem {
+		fsUsage += fs.Usage
+	}
+	putUint64(slot, slotFsUsage, fsUsage)
+
+	name := ref.Name
+	if len(name) > maxNameLength {
+		name = name[:maxNameLength]
+	}
+	*(*uint16)(unsafe.Pointer(&slot[slotNameLength])) = uint16(len(name))
+	copy(slot[slotName:], name)
+
+	// Publish the slot, then the total number of samples written.
+	self.written++
+	atomic.StoreUint64(uint64At(slot, slotSequence), self.written)
+	atomic.StoreUint64(uint64At(self.data, headerWritten), self.written)
+	return nil
+}
+
+func (self *mmapStorage) RecentStats(containerName string, numStats int) ([]*info.ContainerStats, error) {
+	return nil, fmt.Errorf("the mmap storage driver does not support reading stats")
+}
+
+func (self *mmapStorage) Close() error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if self.data == nil {
+		return nil
+	}
+	err := syscall.Munmap(self.data)
+	self.data = nil
+	if closeErr := self.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// A sample read from a ring buffer.
+type Sample struct {
+	// Position of the sample in the stream of samples written, starting at 1.
+	Sequence      uint64
+	ContainerName string
+	Timestamp     time.Time
+	CpuTotal      uint64
+	CpuUser       uint64
+	CpuSystem     uint64
+	MemoryUsage   uint64
+	WorkingSet    uint64
+	RxBytes       uint64
+	TxBytes       uint64
+	FsUsage       uint64
+}
+
+// Reads the samples currently in the ring buffer, oldest first. Slots being written are skipped.
+// data is the mapped content of a ring buffer file.
+func ReadSamples(data []byte) ([]Sample, error) {
+	if len(data) < headerSize || string(data[headerMagic:headerMagic+len(magic)]) != magic {
+		return nil, fmt.Errorf("not a cAdvisor ring buffer")
+	}
+	if v := *uint32At(data, headerVersion); v != version {
+		return nil, fmt.Errorf("unsupported ring buffer version %d", v)
+	}
+	size := uint64(*uint32At(data, headerSlotSize))
+	numSlots := uint64(*uint32At(data, headerNumSlots))
+	if uint64(len(data)) < headerSize+numSlots*size || size < slotSize {
+		return nil, fmt.Errorf("truncated ring buffer")
+	}
+
+	written := atomic.LoadUint64(uint64At(data, headerWritten))
+	first := uint64(1)
+	if written > numSlots {
+		first = written - numSlots + 1
+	}
+	samples := make([]Sample, 0, written-first+1)
+	for seq := first; seq <= written; seq++ {
+		slot := data[headerSize+((seq-1)%numSlots)*size:][:size]
+		if atomic.LoadUint64(uint64At(slot, slotSequence)) != seq {
+			continue
+		}
+		nameLength := int(*(*uint16)(unsafe.Pointer(&slot[slotNameLength])))
+		if nameLength > maxNameLength {
+			continue
+		}
+		sample := Sample{
+			Sequence:      seq,
+			ContainerName: string(slot[slotName : slotName+nameLength]),
+			Timestamp:     time.Unix(0, int64(*uint64At(slot, slotTimestamp))),
+			CpuTotal:      *uint64At(slot, slotCpuTotal),
+			CpuUser:       *uint64At(slot, slotCpuUser),
+			CpuSystem:     *uint64At(slot, slotCpuSystem),
+			MemoryUsage:   *uint64At(slot, slotMemoryUsage),
+			WorkingSet:    *uint64At(slot, slotWorkingSet),
+			RxBytes:       *uint64At(slot, slotRxBytes),
+			TxBytes:       *uint64At(slot, slotTxBytes),
+			FsUsage:       *uint64At(slot, slotFsUsage),
+		}
+		// Drop the sample if the slot was overwritten while reading it.
+		if atomic.LoadUint64(uint64At(slot, slotSequence)) != seq {
+			continue
+		}
+		samples = append(samples, sample)
+	}
+	return samples, nil
+}
+
+// Fields are stored in host byte order so they can be accessed atomically.
+func uint64At(data []byte, offset int) *uint64 {
+	return (*uint64)(unsafe.Pointer(&data[offset]))
+}
+
+func uint32At(data []byte, offset int) *uint32 {
+	return (*uint32)(unsafe.Pointer(&data[offset]))
+}
+
+func putUint64(data []byte, offset int, val uint64) {
+	*uint64At(data, offset) = val
+}
+
+func putUint32(data []byte, offset int, val uint32) {
+	*uint32At(data, offset) = val
+}
@@ -0,0 +1,86 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows
+// +build !windows
+
+package mmap
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/info"
+)
+
+func TestRingBuffer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mmap_storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ringPath := path.Join(dir, "stats.ring")
+
+	const numSlots = 3
+	driver, err := New(ringPath, numSlots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer driver.Close()
+
+	start := time.Unix(1400000000, 0)
+	for i := 0; i < 5; i++ {
+		stats := &info.ContainerStats{
+			Timestamp: start.Add(time.Duration(i) * time.Second),
+			Memory:    info.MemoryStats{Usage: uint64(i * 1024)},
+		}
+		stats.Cpu.Usage.Total = uint64(i * 100)
+		err = driver.AddStats(info.ContainerReference{Name: "/test"}, stats)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := ioutil.ReadFile(ringPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples, err := ReadSamples(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the last numSlots samples are kept.
+	if len(samples) != numSlots {
+		t.Fatalf("expected %d samples, got %d: %+v", numSlots, len(samples), samples)
+	}
+	for i, sample := range samples {
+		n := i + 2
+		if sample.Sequence != uint64(n+1) {
+			t.Errorf("sample %d has sequence %d, expected %d", i, sample.Sequence, n+1)
+		}
+		if sample.ContainerName != "/test" {
+			t.Errorf("sample %d has container name %q", i, sample.ContainerName)
+		}
+		if !sample.Timestamp.Equal(start.Add(time.Duration(n) * time.Second)) {
+			t.Errorf("sample %d has timestamp %v", i, sample.Timestamp)
+		}
+		if sample.CpuTotal != uint64(n*100) || sample.MemoryUsage != uint64(n*1024) {
+			t.Errorf("sample %d has wrong usage: %+v", i, sample)
+		}
+	}
+}
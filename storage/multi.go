@@ -0,0 +1,250 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/cadvisor/info"
+)
+
+var argWriteTimeout = flag.Duration("storage_driver_write_timeout", 5*time.Second, "how long to wait for each storage driver to write stats when several are used, a slower driver keeps writing them in the background while the stats of that container are dropped for it. 0 waits for all drivers")
+
+// Writes stats to several storage drivers concurrently. A failing or slow driver does not prevent
+// the others from getting the stats: at most one write per driver and container is pending, and
+// writes taking longer than the timeout finish in the background.
+type multiDriver struct {
+	names   []string
+	drivers []StorageDriver
+	// The driver named by --storage_history_driver, nil if none is.
+	history StorageDriver
+	timeout time.Duration
+
+	lock sync.Mutex
+	// The containers each driver is still writing stats of, keyed by name ("" for the machine).
+	// Stats of those containers are dropped for it until it is done.
+	writing []map[string]bool
+}
+
+// Result of the write of a driver.
+type driverWrite struct {
+	index int
+	err   error
+}
+
+// Creates the registered storage drivers named in the comma-separated list. A single driver is
+// used on its own, several are combined so stats are written to all of them. The stats exported
+// are restricted by the export filtering flags, which ReloadFilters applies again, and unchanged
+// stats are skipped with --storage_driver_skip_unchanged.
+func NewFromList(names string) (StorageDriver, error) {
+	list := strings.Split(names, ",")
+	for i := range list {
+		list[i] = strings.TrimSpace(list[i])
+	}
+	history := -1
+	if *ArgHistoryDriver != "" {
+		for i, name := range list {
+			if name == *ArgHistoryDriver {
+				history = i
+			}
+		}
+		if history < 0 {
+			return nil, fmt.Errorf("history storage driver %q is not one of the storage drivers %v", *ArgHistoryDriver, list)
+		}
+	}
+	drivers := make([]StorageDriver, 0, len(list))
+	for i := range list {
+		driver, err := New(list[i])
+		if err == nil {
+			driver = newStatusDriver(list[i], driver)
+		}
+		if err == nil && *ArgSpillMaxSamples > 0 {
+			var spilling *spillingDriver
+			spilling, err = newSpillingDriver(list[i], driver, *ArgSpillMaxSamples, *ArgSpillDir)
+			if err != nil {
+				driver.Close()
+			}
+			driver = spilling
+		}
+		if err != nil {
+			for _, d := range drivers {
+				d.Close()
+			}
+			return nil, fmt.Errorf("failed to create storage driver %q: %v", list[i], err)
+		}
+		drivers = append(drivers, driver)
+	}
+	var driver StorageDriver
+	if len(drivers) == 1 {
+		driver = drivers[0]
+	} else {
+		multi := newMultiDriver(list, drivers, *argWriteTimeout)
+		if history >= 0 {
+			multi.history = drivers[history]
+		}
+		driver = multi
+	}
+	skipping, err := newUnchangedSkippingDriverFromFlags(driver)
+	if err != nil {
+		driver.Close()
+		return nil, err
+	}
+	filtered, err := newFilteringDriverFromFlags(skipping)
+	if err != nil {
+		skipping.Close()
+		return nil, err
+	}
+	return filtered, nil
+}
+
+func newMultiDriver(names []string, drivers []StorageDriver, timeout time.Duration) *multiDriver {
+	writing := make([]map[string]bool, len(drivers))
+	for i := range writing {
+		writing[i] = make(map[string]bool)
+	}
+	return &multiDriver{
+		names:   names,
+		drivers: drivers,
+		timeout: timeout,
+		writing: writing,
+	}
+}
+
+// Combines the errors of the drivers, nil if there are none.
+func (self *multiDriver) combineErrors(errs []error) error {
+	var messages []string
+	for i, err := range errs {
+		if err != nil {
+			messages = append(messages, fmt.Sprintf("%s: %v", self.names[i], err))
+		}
+	}
+	if len(messages) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s", strings.Join(messages, "; "))
+}
+
+// Calls write for each driver concurrently and waits for them until the timeout. Drivers still
+// writing the previous stats of the container are skipped.
+func (self *multiDriver) write(containerName string, write func(driver StorageDriver) error) error {
+	errs := make([]error, len(self.drivers))
+	// Buffered so writes finishing after the timeout do not block.
+	done := make(chan driverWrite, len(self.drivers))
+	pending := make(map[int]bool, len(self.drivers))
+	self.lock.Lock()
+	for i, driver := range self.drivers {
+		if self.writing[i][containerName] {
+			errs[i] = fmt.Errorf("still writing previous stats, dropping these")
+			continue
+		}
+		self.writing[i][containerName] = true
+		pending[i] = true
+		go func(i int, driver StorageDriver) {
+			err := write(driver)
+			self.lock.Lock()
+			delete(self.writing[i], containerName)
+			self.lock.Unlock()
+			done <- driverWrite{i, err}
+		}(i, driver)
+	}
+	self.lock.Unlock()
+
+	var timeout <-chan time.Time
+	if self.timeout > 0 {
+		timer := time.NewTimer(self.timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	for len(pending) > 0 {
+		select {
+		case result := <-done:
+			errs[result.index] = result.err
+			delete(pending, result.index)
+		case <-timeout:
+			for i := range pending {
+				errs[i] = fmt.Errorf("timed out after %v, still writing in the background", self.timeout)
+			}
+			return self.combineErrors(errs)
+		}
+	}
+	return self.combineErrors(errs)
+}
+
+func (self *multiDriver) AddStats(ref info.ContainerReference, stats *info.ContainerStats) error {
+	return self.write(ref.Name, func(driver StorageDriver) error {
+		return driver.AddStats(ref, stats)
+	})
+}
+
+// Writes the machine stats to the drivers exporting them.
+func (self *multiDriver) AddMachineStats(stats *info.MachineStats) error {
+	return self.write("", func(driver StorageDriver) error {
+		if machineDriver, ok := driver.(MachineStatsDriver); ok {
+			return machineDriver.AddMachineStats(stats)
+		}
+		return nil
+	})
+}
+
+// Returns the stats of the first driver able to read them.
+func (self *multiDriver) RecentStats(containerName string, numStats int) ([]*info.ContainerStats, error) {
+	errs := make([]error, len(self.drivers))
+	for i, driver := range self.drivers {
+		stats, err := driver.RecentStats(containerName, numStats)
+		if err == nil {
+			return stats, nil
+		}
+		errs[i] = err
+	}
+	return nil, self.combineErrors(errs)
+}
+
+// Returns the history of the driver named by --storage_history_driver.
+func (self *multiDriver) History(containerName string, numStats int) ([]*info.ContainerStats, error) {
+	if history, ok := self.history.(HistoryDriver); ok {
+		return history.History(containerName, numStats)
+	}
+	return nil, fmt.Errorf("no history storage driver among %v", self.names)
+}
+
+// Returns the stats of the time range from the driver named by --storage_history_driver.
+func (self *multiDriver) StatsInRange(containerName string, start, end time.Time, maxStats int) ([]*info.ContainerStats, error) {
+	if timeRange, ok := self.history.(TimeRangeDriver); ok {
+		return timeRange.StatsInRange(containerName, start, end, maxStats)
+	}
+	return nil, fmt.Errorf("no history storage driver among %v", self.names)
+}
+
+func (self *multiDriver) Status() []info.StorageDriverStatus {
+	var statuses []info.StorageDriverStatus
+	for _, driver := range self.drivers {
+		if statusDriver, ok := driver.(StatusDriver); ok {
+			statuses = append(statuses, statusDriver.Status()...)
+		}
+	}
+	return statuses
+}
+
+func (self *multiDriver) Close() error {
+	errs := make([]error, len(self.drivers))
+	for i, driver := range self.drivers {
+		errs[i] = driver.Close()
+	}
+	return self.combineErrors(errs)
+}
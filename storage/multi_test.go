@@ -0,0 +1,193 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/info"
+)
+
+// Records the containers it gets stats for, fails if err is set.
+type recordingDriver struct {
+	lock       sync.Mutex
+	containers []string
+	err        error
+}
+
+func (self *recordingDriver) AddStats(ref info.ContainerReference, stats *info.ContainerStats) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if self.err != nil {
+		return self.err
+	}
+	self.containers = append(self.containers, ref.Name)
+	return nil
+}
+
+func (self *recordingDriver) RecentStats(containerName string, numStats int) ([]*info.ContainerStats, error) {
+	return nil, fmt.Errorf("unsupported")
+}
+
+func (self *recordingDriver) Close() error {
+	return nil
+}
+
+func TestNewFromList(t *testing.T) {
+	good := &recordingDriver{}
+	bad := &recordingDriver{err: fmt.Errorf("unavailable")}
+	RegisterStorageDriver("test_good", func() (StorageDriver, error) { return good, nil })
+	RegisterStorageDriver("test_bad", func() (StorageDriver, error) { return bad, nil })
+
+	driver, err := NewFromList("test_good")
+	if err != nil {
+		t.Fatal(err)
+	}
+	filtered, ok := driver.(*filteringDriver)
+	if !ok {
+		t.Fatalf("the driver should be wrapped so that its export filters can be reloaded")
+	}
+	if status, ok := filtered.StorageDriver.(*statusDriver); !ok || status.StorageDriver != good {
+		t.Errorf("a single driver should only be wrapped to record its status")
+	}
+
+	driver, err = NewFromList("test_bad, test_good")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = driver.AddStats(info.ContainerReference{Name: "/test"}, &info.ContainerStats{})
+	if err == nil {
+		t.Errorf("expected the error of the failing driver")
+	}
+	if len(good.containers) != 1 || good.containers[0] != "/test" {
+		t.Errorf("the working driver did not get the stats: %v", good.containers)
+	}
+	statuses := driver.(StatusDriver).Status()
+	if len(statuses) != 2 {
+		t.Fatalf("expected the status of both drivers, got %+v", statuses)
+	}
+	if s := statuses[0]; s.Name != "test_bad" || s.Connected || s.ConsecutiveFailures != 1 || s.Dropped != 1 || s.LastError != "unavailable" {
+		t.Errorf("unexpected status of the failing driver: %+v", s)
+	}
+	if s := statuses[1]; s.Name != "test_good" || !s.Connected || s.LastSuccess.IsZero() || s.Dropped != 0 {
+		t.Errorf("unexpected status of the working driver: %+v", s)
+	}
+
+	if _, err := NewFromList("test_good,unknown"); err == nil {
+		t.Errorf("expected an error for an unknown driver")
+	}
+}
+
+// Blocks writes until released.
+type blockingDriver struct {
+	recordingDriver
+	release chan struct{}
+}
+
+func (self *blockingDriver) AddStats(ref info.ContainerReference, stats *info.ContainerStats) error {
+	<-self.release
+	return self.recordingDriver.AddStats(ref, stats)
+}
+
+func TestMultiDriverWriteTimeout(t *testing.T) {
+	good := &recordingDriver{}
+	slow := &blockingDriver{release: make(chan struct{})}
+	driver := newMultiDriver([]string{"good", "slow"}, []StorageDriver{good, slow}, 10*time.Millisecond)
+
+	err := driver.AddStats(info.ContainerReference{Name: "/a"}, &info.ContainerStats{})
+	if err == nil || !strings.Contains(err.Error(), "slow: timed out") {
+		t.Errorf("expected the slow driver to time out, got %v", err)
+	}
+	// The stats of the container are dropped for the driver still writing them.
+	err = driver.AddStats(info.ContainerReference{Name: "/a"}, &info.ContainerStats{})
+	if err == nil || !strings.Contains(err.Error(), "slow: still writing") {
+		t.Errorf("expected the stats to be dropped for the slow driver, got %v", err)
+	}
+	// Those of other containers are not.
+	err = driver.AddStats(info.ContainerReference{Name: "/b"}, &info.ContainerStats{})
+	if err == nil || !strings.Contains(err.Error(), "slow: timed out") {
+		t.Errorf("expected the slow driver to time out, got %v", err)
+	}
+	expected := []string{"/a", "/a", "/b"}
+	if !reflect.DeepEqual(good.recorded(), expected) {
+		t.Errorf("expected the working driver to get %v, got %v", expected, good.recorded())
+	}
+
+	// Written in the background once released.
+	close(slow.release)
+	for i := 0; i < 100 && len(slow.recorded()) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	for i := 0; i < 100 && len(slow.recorded()) < 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	recorded := slow.recorded()
+	sort.Strings(recorded)
+	if !reflect.DeepEqual(recorded, []string{"/a", "/b"}) {
+		t.Errorf("expected the slow driver to finish writing /a and /b, got %v", recorded)
+	}
+	for i := 0; i < 100; i++ {
+		if err = driver.AddStats(info.ContainerReference{Name: "/c"}, &info.ContainerStats{}); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Errorf("expected both drivers to write once the slow one is done, got %v", err)
+	}
+}
+
+// Blocks writes until as many as added to arrived are in progress.
+type barrierDriver struct {
+	recordingDriver
+	arrived sync.WaitGroup
+}
+
+func (self *barrierDriver) AddStats(ref info.ContainerReference, stats *info.ContainerStats) error {
+	self.arrived.Done()
+	self.arrived.Wait()
+	return self.recordingDriver.AddStats(ref, stats)
+}
+
+func TestMultiDriverConcurrentWriters(t *testing.T) {
+	const writers = 10
+	first := &barrierDriver{}
+	second := &barrierDriver{}
+	first.arrived.Add(writers)
+	second.arrived.Add(writers)
+	driver := newMultiDriver([]string{"first", "second"}, []StorageDriver{first, second}, time.Second)
+
+	// Each driver writes the stats of all containers at the same time.
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		go func(i int) {
+			errs <- driver.AddStats(info.ContainerReference{Name: fmt.Sprintf("/%d", i)}, &info.ContainerStats{})
+		}(i)
+	}
+	for i := 0; i < writers; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("unexpected error from a concurrent writer: %v", err)
+		}
+	}
+	if len(first.recorded()) != writers || len(second.recorded()) != writers {
+		t.Errorf("expected both drivers to write the stats of %d containers, got %v and %v", writers, first.recorded(), second.recorded())
+	}
+}
@@ -0,0 +1,375 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package opentsdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/golang/glog"
+	"github.com/google/cadvisor/info"
+	"github.com/google/cadvisor/storage"
+)
+
+var argHost = flag.String("storage_driver_opentsdb_host", "http://localhost:4242", "URL of the OpenTSDB server")
+var argPrefix = flag.String("storage_driver_opentsdb_prefix", "cadvisor", "prefix of the names of the metrics written to OpenTSDB")
+var argBatchSize = flag.Int("storage_driver_opentsdb_batch_size", 500, "maximum number of data points sent to OpenTSDB in a single request, data points are sent earlier when the buffer duration elapses")
+var argRetries = flag.Int("storage_driver_opentsdb_retries", 3, "number of times a failed write to OpenTSDB is retried, with exponential backoff")
+var argLabels = flag.String("storage_driver_opentsdb_labels", "", "comma-separated list of the container labels written as OpenTSDB tags, in order of preference. Empty writes all labels, in alphabetical order")
+var argMaxTags = flag.Int("storage_driver_opentsdb_max_tags", 8, "maximum number of tags of a data point, tsd.storage.max_tags of OpenTSDB. The labels that do not fit are not written")
+
+// Maximum number of batches waiting to be sent before the oldest are dropped.
+const maxPendingBatches = 10
+
+// Backoff before the first retry, doubled on every retry.
+const initialBackoff = time.Second
+
+// Tags of a data point besides the labels: host, container and image, and at most 2 for the metric
+// itself (the device and mount point of volumes).
+const (
+	containerTags = 3
+	metricTags    = 2
+)
+
+func init() {
+	storage.RegisterStorageDriver("opentsdb", newStorage)
+}
+
+type dataPoint struct {
+	Metric    string            `json:"metric"`
+	Timestamp int64             `json:"timestamp"`
+	Value     uint64            `json:"value"`
+	Tags      map[string]string `json:"tags"`
+}
+
+type openTsdbStorage struct {
+	machineName    string
+	url            string
+	prefix         string
+	batchSize      int
+	retries        int
+	bufferDuration time.Duration
+	client         *http.Client
+	// Labels written as tags, all if empty.
+	labels []string
+	// Number of labels that fit in the tags of a data point.
+	maxLabels int
+
+	// Labels already logged as not written, by key.
+	rejectedLock   sync.Mutex
+	rejectedLabels map[string]bool
+
+	lock      sync.Mutex
+	points    []dataPoint
+	lastFlush time.Time
+	closed    bool
+
+	// Batches waiting to be sent by the sender goroutine.
+	batches chan []dataPoint
+	done    chan struct{}
+}
+
+func newStorage() (storage.StorageDriver, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+	if *argMaxTags < containerTags+metricTags {
+		return nil, fmt.Errorf("-storage_driver_opentsdb_max_tags must be at least %d, got %d", containerTags+metricTags, *argMaxTags)
+	}
+	client, err := storage.HttpClientFromFlags(30 * time.Second)
+	if err != nil {
+		return nil, err
+	}
+	var labels []string
+	for _, label := range strings.Split(*argLabels, ",") {
+		if label = strings.TrimSpace(label); label != "" {
+			labels = append(labels, label)
+		}
+	}
+	return New(hostname, *argHost, *argPrefix, *argBatchSize, *argRetries, *storage.ArgDbBufferDuration, client, labels, *argMaxTags), nil
+}
+
+// Creates an OpenTSDB storage driver. Data points are sent in batches of at most batchSize, at
+// least every bufferDuration. A default client is used if client is nil. The container labels
+// listed in labels, all if empty, are written as tags as long as data points have at most maxTags.
+func New(machineName, url, prefix string, batchSize, retries int, bufferDuration time.Duration, client *http.Client, labels []string, maxTags int) *openTsdbStorage {
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	ret := &openTsdbStorage{
+		machineName:    machineName,
+		url:            strings.TrimRight(url, "/") + "/api/put",
+		prefix:         prefix,
+		batchSize:      batchSize,
+		retries:        retries,
+		bufferDuration: bufferDuration,
+		client:         client,
+		labels:         labels,
+		maxLabels:      maxTags - containerTags - metricTags,
+		rejectedLabels: make(map[string]bool),
+		lastFlush:      time.Now(),
+		batches:        make(chan []dataPoint, maxPendingBatches),
+		done:           make(chan struct{}),
+	}
+	go ret.sender()
+	return ret
+}
+
+// Replaces the characters OpenTSDB does not allow in tag values.
+func sanitizeTag(value string) string {
+	value = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("-_./", r) {
+			return r
+		}
+		return '_'
+	}, value)
+	if value == "" {
+		return "_"
+	}
+	return value
+}
+
+// Returns the data points of a stats sample.
+func (self *openTsdbStorage) dataPoints(ref info.ContainerReference, stats *info.ContainerStats) []dataPoint {
+	tags := map[string]string{
+		"host":      sanitizeTag(self.machineName),
+		"container": sanitizeTag(ref.Name),
+	}
+	if ref.Image != "" {
+		tags["image"] = sanitizeTag(ref.Image)
+	}
+	for _, k := range self.labelTags(ref) {
+		tags["label."+sanitizeTag(k)] = sanitizeTag(ref.Labels[k])
+	}
+
+	timestamp := stats.Timestamp.UnixNano() / int64(time.Millisecond)
+	point := func(name string, value uint64, extraTags map[string]string) dataPoint {
+		pointTags := tags
+		if len(extraTags) > 0 {
+			pointTags = make(map[string]string, len(tags)+len(extraTags))
+			for k, v := range tags {
+				pointTags[k] = v
+			}
+			for k, v := range extraTags {
+				pointTags[k] = v
+			}
+		}
+		return dataPoint{self.prefix + "." + name, timestamp, value, pointTags}
+	}
+
+	points := []dataPoint{
+		point("cpu.usage.total", stats.Cpu.Usage.Total, nil),
+		point("cpu.usage.user", stats.Cpu.Usage.User, nil),
+		point("cpu.usage.system", stats.Cpu.Usage.System, nil),
+		point("memory.usage", stats.Memory.Usage, nil),
+		point("memory.working_set", stats.Memory.WorkingSet, nil),
+		point("network.rx_bytes", stats.Network.RxBytes, nil),
+		point("network.rx_errors", stats.Network.RxErrors, nil),
+		point("network.tx_bytes", stats.Network.TxBytes, nil),
+		point("network.tx_errors", stats.Network.TxErrors, nil),
+	}
+	for _, fs := range stats.Filesystem {
+		device := map[string]string{"device": sanitizeTag(fs.Device)}
+		points = append(points, point("fs.usage", fs.Usage, device), point("fs.limit", fs.Limit, device))
+	}
+	for _, volume := range stats.Volumes {
+		tags := map[string]string{
+			"device":      sanitizeTag(volume.Device),
+			"mount_point": sanitizeTag(volume.MountPoint),
+		}
+		points = append(points, point("volume.usage", volume.Usage, tags), point("volume.limit", volume.Limit, tags))
+	}
+	return points
+}
+
+// Returns the keys of the labels of the container written as tags. Those that do not fit are
+// logged the first time.
+func (self *openTsdbStorage) labelTags(ref info.ContainerReference) []string {
+	var keys []string
+	if len(self.labels) == 0 {
+		for k := range ref.Labels {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+	} else {
+		for _, k := range self.labels {
+			if _, ok := ref.Labels[k]; ok {
+				keys = append(keys, k)
+			}
+		}
+	}
+	if len(keys) <= self.maxLabels {
+		return keys
+	}
+	self.rejectedLock.Lock()
+	defer self.rejectedLock.Unlock()
+	for _, k := range keys[self.maxLabels:] {
+		if !self.rejectedLabels[k] {
+			self.rejectedLabels[k] = true
+			glog.Warningf("Not writing label %q of container %q to OpenTSDB, data points are limited to %d tags", k, ref.Name, self.maxLabels+containerTags+metricTags)
+		}
+	}
+	return keys[:self.maxLabels]
+}
+
+func (self *openTsdbStorage) AddStats(ref info.ContainerReference, stats *info.ContainerStats) error {
+	if stats == nil {
+		return nil
+	}
+	return self.add(self.dataPoints(ref, stats))
+}
+
+// Returns the data points of a machine stats sample, named <prefix>.machine.<metric>.
+func (self *openTsdbStorage) machineDataPoints(stats *info.MachineStats) []dataPoint {
+	tags := map[string]string{
+		"host": sanitizeTag(self.machineName),
+	}
+	timestamp := stats.Timestamp.UnixNano() / int64(time.Millisecond)
+	point := func(name string, value uint64) dataPoint {
+		return dataPoint{self.prefix + ".machine." + name, timestamp, value, tags}
+	}
+	return []dataPoint{
+		point("num_cores", uint64(stats.NumCores)),
+		point("memory.capacity", uint64(stats.MemoryCapacity)),
+		point("fs.capacity", stats.FsCapacity),
+		point("cpu.usage.total", stats.CpuUsage),
+		point("memory.usage", stats.MemoryUsage),
+		point("memory.working_set", stats.MemoryWorkingSet),
+		point("fs.usage", stats.FsUsage),
+		point("network.rx_bytes", stats.Network.RxBytes),
+		point("network.rx_errors", stats.Network.RxErrors),
+		point("network.tx_bytes", stats.Network.TxBytes),
+		point("network.tx_errors", stats.Network.TxErrors),
+	}
+}
+
+func (self *openTsdbStorage) AddMachineStats(stats *info.MachineStats) error {
+	return self.add(self.machineDataPoints(stats))
+}
+
+// Buffers the data points and queues the batches that are due.
+func (self *openTsdbStorage) add(points []dataPoint) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if self.closed {
+		return fmt.Errorf("OpenTSDB storage is closed")
+	}
+	self.points = append(self.points, points...)
+	for len(self.points) >= self.batchSize {
+		self.queue(self.points[:self.batchSize])
+		self.points = self.points[self.batchSize:]
+	}
+	if len(self.points) > 0 && time.Since(self.lastFlush) >= self.bufferDuration {
+		self.queue(self.points)
+		self.points = nil
+	}
+	return nil
+}
+
+// Hands a batch to the sender. Drops the oldest batch if the sender is falling behind.
+func (self *openTsdbStorage) queue(batch []dataPoint) {
+	batch = append([]dataPoint(nil), batch...)
+	self.lastFlush = time.Now()
+	for {
+		select {
+		case self.batches <- batch:
+			return
+		default:
+		}
+		select {
+		case <-self.batches:
+			glog.Warningf("OpenTSDB is falling behind, dropped a batch of data points")
+		default:
+		}
+	}
+}
+
+// Sends the queued batches until the driver is closed.
+func (self *openTsdbStorage) sender() {
+	defer close(self.done)
+	for batch := range self.batches {
+		backoff := initialBackoff
+		for attempt := 0; ; attempt++ {
+			err := self.send(batch)
+			if err == nil {
+				break
+			}
+			if storage.IsPermanent(err) {
+				glog.Errorf("OpenTSDB rejected %d data points, dropping them: %v", len(batch), err)
+				break
+			}
+			if attempt >= self.retries {
+				glog.Errorf("Dropping %d data points after %d attempts: %v", len(batch), attempt+1, err)
+				break
+			}
+			glog.V(2).Infof("Failed to write to OpenTSDB, retrying in %v: %v", backoff, err)
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+}
+
+func (self *openTsdbStorage) send(batch []dataPoint) error {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return storage.Permanent(err)
+	}
+	resp, err := self.client.Post(self.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		out, _ := ioutil.ReadAll(resp.Body)
+		return storage.HttpStatusError(resp.StatusCode, fmt.Errorf("OpenTSDB returned %s: %s", resp.Status, out))
+	}
+	return nil
+}
+
+func (self *openTsdbStorage) RecentStats(containerName string, numStats int) ([]*info.ContainerStats, error) {
+	return nil, fmt.Errorf("the OpenTSDB storage driver does not support reading stats")
+}
+
+// Sends the buffered data points and waits for the pending batches to be sent.
+func (self *openTsdbStorage) Close() error {
+	self.lock.Lock()
+	if self.closed {
+		self.lock.Unlock()
+		return nil
+	}
+	if len(self.points) > 0 {
+		self.queue(self.points)
+		self.points = nil
+	}
+	self.closed = true
+	close(self.batches)
+	self.lock.Unlock()
+	<-self.done
+	return nil
+}
@@ -0,0 +1,117 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package opentsdb
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/info"
+)
+
+func TestBatchingAndRetry(t *testing.T) {
+	var lock sync.Mutex
+	var requests int
+	var points []dataPoint
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		requests++
+		// Fail the first request to exercise the retry.
+		if requests == 1 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		var batch []dataPoint
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			t.Error(err)
+		}
+		points = append(points, batch...)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	driver := New("machine", server.URL, "cadvisor", 4, 1, time.Hour, nil, nil, 8)
+	ref := info.ContainerReference{
+		Name:   "/docker/abcd",
+		Image:  "nginx:latest",
+		Labels: map[string]string{"app": "web server"},
+	}
+	stats := &info.ContainerStats{Timestamp: time.Unix(1400000000, 0)}
+	stats.Memory.Usage = 2048
+	if err := driver.AddStats(ref, stats); err != nil {
+		t.Fatal(err)
+	}
+	if err := driver.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 9 data points in batches of 4, the first batch is retried once.
+	if requests != 4 {
+		t.Errorf("expected 4 requests, got %d", requests)
+	}
+	if len(points) != 9 {
+		t.Fatalf("expected 9 data points, got %d", len(points))
+	}
+	for _, p := range points {
+		if p.Metric != "cadvisor.memory.usage" {
+			continue
+		}
+		if p.Value != 2048 || p.Timestamp != 1400000000000 {
+			t.Errorf("unexpected data point %+v", p)
+		}
+		expectedTags := map[string]string{
+			"host":      "machine",
+			"container": "/docker/abcd",
+			"image":     "nginx_latest",
+			"label.app": "web_server",
+		}
+		for k, v := range expectedTags {
+			if p.Tags[k] != v {
+				t.Errorf("expected tag %s=%s, got tags %v", k, v, p.Tags)
+			}
+		}
+	}
+}
+
+func TestLabelTags(t *testing.T) {
+	ref := info.ContainerReference{
+		Name:   "/docker/abcd",
+		Labels: map[string]string{"app": "web", "tier": "frontend", "owner": "team", "zone": "a"},
+	}
+	for _, test := range []struct {
+		labels   []string
+		maxTags  int
+		expected []string
+	}{
+		{nil, 9, []string{"app", "owner", "tier", "zone"}},
+		{nil, 8, []string{"app", "owner", "tier"}},
+		{nil, 5, []string{}},
+		{[]string{"zone", "missing", "app"}, 8, []string{"zone", "app"}},
+		{[]string{"zone", "tier", "app", "owner"}, 7, []string{"zone", "tier"}},
+	} {
+		driver := New("machine", "http://localhost:4242", "cadvisor", 4, 1, time.Hour, nil, test.labels, test.maxTags)
+		keys := driver.labelTags(ref)
+		if !reflect.DeepEqual(keys, test.expected) {
+			t.Errorf("expected labels %v with %v and %d tags, got %v", test.expected, test.labels, test.maxTags, keys)
+		}
+		driver.Close()
+	}
+}
@@ -0,0 +1,288 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/cadvisor/info"
+	"github.com/google/cadvisor/storage"
+)
+
+var argKeyPrefix = flag.String("storage_driver_redis_key_prefix", "cadvisor:", "prefix of the Redis keys stats are pushed to, the container name is appended to it")
+var argTtl = flag.Duration("storage_driver_redis_ttl", 0, "time after which the Redis key of a container expires if it gets no new stats, 0 for never")
+var argStream = flag.Bool("storage_driver_redis_stream", false, "add stats to Redis streams (XADD) instead of lists (RPUSH)")
+var argMaxLen = flag.Int("storage_driver_redis_max_len", 10000, "maximum number of samples kept in the Redis key of a container, older ones are trimmed. 0 keeps all samples")
+
+// Address of the server when -storage_driver_host, whose default is that of InfluxDB, is not set.
+const defaultAddress = "localhost:6379"
+
+func init() {
+	storage.RegisterStorageDriver("redis", newStorage)
+}
+
+type redisStorage struct {
+	machineName string
+	address     string
+	keyPrefix   string
+	ttl         time.Duration
+	stream      bool
+	maxLen      int
+	tlsConfig   *tls.Config
+	password    string
+
+	lock   sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// A stats sample as stored in Redis.
+type detailSpec struct {
+	Timestamp       int64                `json:"timestamp"`
+	MachineName     string               `json:"machine_name,omitempty"`
+	ContainerName   string               `json:"container_name,omitempty"`
+	ContainerImage  string               `json:"container_image,omitempty"`
+	ContainerLabels map[string]string    `json:"container_labels,omitempty"`
+	ContainerEnvs   map[string]string    `json:"container_envs,omitempty"`
+	ContainerStats  *info.ContainerStats `json:"container_stats,omitempty"`
+	// Set instead of the container fields in the samples holding the stats of the machine.
+	MachineStats *info.MachineStats `json:"machine_stats,omitempty"`
+}
+
+func newStorage() (storage.StorageDriver, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := storage.TlsConfigFromFlags()
+	if err != nil {
+		return nil, err
+	}
+	if *argMaxLen < 0 {
+		return nil, fmt.Errorf("invalid -storage_driver_redis_max_len %d, it must not be negative", *argMaxLen)
+	}
+	address := defaultAddress
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "storage_driver_host" {
+			address = *storage.ArgDbHost
+		}
+	})
+	return New(hostname, address, *argKeyPrefix, *argTtl, *argStream, *argMaxLen, tlsConfig, *storage.ArgDbToken)
+}
+
+// Creates a Redis storage driver for the server at address (host:port). Keys are trimmed to the
+// latest maxLen samples, 0 keeps all. tlsConfig is nil to connect without TLS and password is
+// empty to skip authentication.
+func New(machineName, address, keyPrefix string, ttl time.Duration, stream bool, maxLen int, tlsConfig *tls.Config, password string) (*redisStorage, error) {
+	ret := &redisStorage{
+		machineName: machineName,
+		address:     address,
+		keyPrefix:   keyPrefix,
+		ttl:         ttl,
+		stream:      stream,
+		maxLen:      maxLen,
+		tlsConfig:   tlsConfig,
+		password:    password,
+	}
+	// Fail early if the server is unreachable.
+	if err := ret.connect(); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func (self *redisStorage) connect() error {
+	conn, err := net.DialTimeout("tcp", self.address, 10*time.Second)
+	if err != nil {
+		return fmt.Errorf("failed to connect to Redis at %q: %v", self.address, err)
+	}
+	if self.tlsConfig != nil {
+		config := self.tlsConfig.Clone()
+		if config.ServerName == "" {
+			config.ServerName, _, _ = net.SplitHostPort(self.address)
+		}
+		tlsConn := tls.Client(conn, config)
+		tlsConn.SetDeadline(time.Now().Add(10 * time.Second))
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return fmt.Errorf("TLS handshake with Redis at %q failed: %v", self.address, err)
+		}
+		conn = tlsConn
+	}
+	self.conn = conn
+	self.reader = bufio.NewReader(conn)
+	if self.password != "" {
+		if err := self.do([][]string{{"AUTH", self.password}}); err != nil {
+			self.conn.Close()
+			self.conn = nil
+			return fmt.Errorf("failed to authenticate with Redis at %q: %v", self.address, err)
+		}
+	}
+	return nil
+}
+
+func (self *redisStorage) AddStats(ref info.ContainerReference, stats *info.ContainerStats) error {
+	if stats == nil {
+		return nil
+	}
+	return self.push(ref.Name, &detailSpec{
+		Timestamp:       stats.Timestamp.UnixNano(),
+		MachineName:     self.machineName,
+		ContainerName:   ref.Name,
+		ContainerImage:  ref.Image,
+		ContainerLabels: ref.Labels,
+		ContainerEnvs:   ref.Envs,
+		ContainerStats:  stats,
+	})
+}
+
+// Machine stats are pushed to the <prefix>machine key. Container names start with a slash so
+// they do not collide with it.
+func (self *redisStorage) AddMachineStats(stats *info.MachineStats) error {
+	return self.push("machine", &detailSpec{
+		Timestamp:    stats.Timestamp.UnixNano(),
+		MachineName:  self.machineName,
+		MachineStats: stats,
+	})
+}
+
+// Pushes the sample to the <prefix><name> key.
+func (self *redisStorage) push(name string, spec *detailSpec) error {
+	value, err := json.Marshal(spec)
+	if err != nil {
+		return err
+	}
+
+	key := self.keyPrefix + name
+	commands := make([][]string, 0, 3)
+	if self.stream {
+		add := []string{"XADD", key}
+		if self.maxLen > 0 {
+			// Trimmed approximately, which Redis does more efficiently.
+			add = append(add, "MAXLEN", "~", strconv.Itoa(self.maxLen))
+		}
+		commands = append(commands, append(add, "*", "stats", string(value)))
+	} else {
+		commands = append(commands, []string{"RPUSH", key, string(value)})
+		if self.maxLen > 0 {
+			commands = append(commands, []string{"LTRIM", key, strconv.Itoa(-self.maxLen), "-1"})
+		}
+	}
+	if self.ttl > 0 {
+		commands = append(commands, []string{"PEXPIRE", key, strconv.FormatInt(int64(self.ttl/time.Millisecond), 10)})
+	}
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if self.conn == nil {
+		if err := self.connect(); err != nil {
+			return err
+		}
+	}
+	err = self.do(commands)
+	if err != nil {
+		// Reconnect on the next sample, the connection may be in an unknown state.
+		self.conn.Close()
+		self.conn = nil
+		return fmt.Errorf("failed to write stats of %q to Redis: %v", name, err)
+	}
+	return nil
+}
+
+// Sends the commands in a single write and reads their replies.
+func (self *redisStorage) do(commands [][]string) error {
+	var buf []byte
+	for _, args := range commands {
+		buf = append(buf, fmt.Sprintf("*%d\r\n", len(args))...)
+		for _, arg := range args {
+			buf = append(buf, fmt.Sprintf("$%d\r\n%s\r\n", len(arg), arg)...)
+		}
+	}
+	self.conn.SetDeadline(time.Now().Add(10 * time.Second))
+	if _, err := self.conn.Write(buf); err != nil {
+		return err
+	}
+	for _ = range commands {
+		if err := readReply(self.reader); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reads and discards a reply. Returns the error sent by the server, if any.
+func readReply(r *bufio.Reader) error {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return fmt.Errorf("malformed Redis reply %q", line)
+	}
+	line = line[:len(line)-2]
+	switch line[0] {
+	case '+', ':':
+		return nil
+	case '-':
+		return fmt.Errorf("redis error: %s", line[1:])
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return fmt.Errorf("malformed Redis reply %q", line)
+		}
+		if n < 0 {
+			return nil
+		}
+		// Skip the bulk string and its trailing CRLF.
+		_, err = r.Discard(n + 2)
+		return err
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return fmt.Errorf("malformed Redis reply %q", line)
+		}
+		for i := 0; i < n; i++ {
+			if err := readReply(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown Redis reply %q", line)
+}
+
+func (self *redisStorage) RecentStats(containerName string, numStats int) ([]*info.ContainerStats, error) {
+	return nil, fmt.Errorf("the Redis storage driver does not support reading stats")
+}
+
+func (self *redisStorage) Close() error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if self.conn == nil {
+		return nil
+	}
+	err := self.conn.Close()
+	self.conn = nil
+	return err
+}
@@ -0,0 +1,117 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/info"
+)
+
+// Reads a command sent by the driver.
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err = r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func TestAddStats(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	commands := make(chan []string, 10)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			args, err := readCommand(r)
+			if err != nil {
+				close(commands)
+				return
+			}
+			commands <- args
+			conn.Write([]byte(":1\r\n"))
+		}
+	}()
+
+	driver, err := New("machine", listener.Addr().String(), "cadvisor:", time.Minute, false, 100, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := &info.ContainerStats{Timestamp: time.Unix(1400000000, 0)}
+	stats.Memory.Usage = 1024
+	if err := driver.AddStats(info.ContainerReference{Name: "/test"}, stats); err != nil {
+		t.Fatal(err)
+	}
+	driver.Close()
+
+	push := <-commands
+	if len(push) != 3 || push[0] != "RPUSH" || push[1] != "cadvisor:/test" {
+		t.Fatalf("unexpected push command %q", push)
+	}
+	var spec detailSpec
+	if err := json.Unmarshal([]byte(push[2]), &spec); err != nil {
+		t.Fatal(err)
+	}
+	if spec.MachineName != "machine" || spec.ContainerName != "/test" || spec.ContainerStats.Memory.Usage != 1024 {
+		t.Errorf("unexpected stats pushed: %+v", spec)
+	}
+
+	trim := <-commands
+	if len(trim) != 4 || trim[0] != "LTRIM" || trim[1] != "cadvisor:/test" || trim[2] != "-100" || trim[3] != "-1" {
+		t.Errorf("unexpected trim command %q", trim)
+	}
+
+	expire := <-commands
+	if len(expire) != 3 || expire[0] != "PEXPIRE" || expire[2] != "60000" {
+		t.Errorf("unexpected expire command %q", expire)
+	}
+}
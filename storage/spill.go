@@ -0,0 +1,527 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"expvar"
+	"flag"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/cadvisor/info"
+)
+
+var ArgSpillMaxSamples = flag.Int("storage_driver_spill_max_samples", 0, "maximum number of samples queued for each storage driver while its backend is unreachable, they are written once it is reachable again. The oldest samples are dropped when the queue is full. 0 disables the queue")
+var ArgSpillDir = flag.String("storage_driver_spill_dir", "", "directory the queued samples are kept in, so they survive restarts of cAdvisor. Empty keeps them in memory")
+
+const (
+	// Backoff after the first failed write, doubled on every consecutive failure.
+	initialSpillBackoff = time.Second
+	maxSpillBackoff     = time.Minute
+)
+
+// Depth of the queues and number of dropped, replayed and rejected samples, keyed by storage
+// driver. Published under /debug/vars.
+var spillVars = expvar.NewMap("storage_driver_spill")
+
+// A sample waiting to be written to a storage driver.
+type spilledSample struct {
+	Ref   info.ContainerReference `json:"ref"`
+	Stats *info.ContainerStats    `json:"stats"`
+}
+
+// FIFO of samples.
+type spillQueue interface {
+	Push(sample *spilledSample) error
+	// Returns the oldest sample, nil if the queue is empty.
+	Peek() (*spilledSample, error)
+	// Removes the oldest sample.
+	Pop() error
+	Len() int
+	// Drops all the samples.
+	Clear() error
+	Close() error
+}
+
+// Queues the samples a storage driver fails to write and writes them, in order, once the
+// driver succeeds again. New samples are queued behind them in the meantime. Samples the driver
+// fails to write with a permanent error, e.g. rejected by the backend, are dropped instead so they
+// do not hold up the queue.
+type spillingDriver struct {
+	name       string
+	driver     StorageDriver
+	maxSamples int
+
+	lock  sync.Mutex
+	queue spillQueue
+	// Number of samples removed from the queue, to detect the head being dropped while it is
+	// replayed.
+	pops         uint64
+	replaying    bool
+	closed       bool
+	retryBackoff time.Duration
+	retryAfter   time.Time
+
+	depth    *expvar.Int
+	dropped  *expvar.Int
+	replayed *expvar.Int
+	// Samples dropped because of a permanent error, counted in dropped too.
+	rejected *expvar.Int
+}
+
+// Wraps the driver so the samples it fails to write are queued, in spillDir if not empty.
+func newSpillingDriver(name string, driver StorageDriver, maxSamples int, spillDir string) (*spillingDriver, error) {
+	var queue spillQueue = &memoryQueue{}
+	if spillDir != "" {
+		if err := os.MkdirAll(spillDir, 0755); err != nil {
+			return nil, err
+		}
+		var err error
+		queue, err = openFileQueue(filepath.Join(spillDir, name+".spill"))
+		if err != nil {
+			return nil, err
+		}
+	}
+	ret := &spillingDriver{
+		name:       name,
+		driver:     driver,
+		maxSamples: maxSamples,
+		queue:      queue,
+		depth:      new(expvar.Int),
+		dropped:    new(expvar.Int),
+		replayed:   new(expvar.Int),
+		rejected:   new(expvar.Int),
+	}
+	vars := new(expvar.Map).Init()
+	vars.Set("depth", ret.depth)
+	vars.Set("dropped", ret.dropped)
+	vars.Set("replayed", ret.replayed)
+	vars.Set("rejected", ret.rejected)
+	spillVars.Set(name, vars)
+	ret.depth.Set(int64(queue.Len()))
+	return ret, nil
+}
+
+func (self *spillingDriver) AddStats(ref info.ContainerReference, stats *info.ContainerStats) error {
+	self.lock.Lock()
+	if self.queue.Len() == 0 {
+		self.lock.Unlock()
+		err := self.driver.AddStats(ref, stats)
+		if err == nil {
+			return nil
+		}
+		if IsPermanent(err) {
+			self.reject(ref, err)
+			return err
+		}
+		self.lock.Lock()
+		glog.Warningf("Storage driver %q failed, queueing samples until it recovers: %v", self.name, err)
+		self.backoff()
+	}
+	defer self.lock.Unlock()
+	err := self.push(&spilledSample{ref, stats})
+	if err != nil {
+		return err
+	}
+	if !self.replaying && !time.Now().Before(self.retryAfter) {
+		self.replaying = true
+		go self.replay()
+	}
+	return nil
+}
+
+// Queues the sample, dropping the oldest one if the queue is full. Must be called with the lock held.
+func (self *spillingDriver) push(sample *spilledSample) error {
+	if self.queue.Len() >= self.maxSamples {
+		if err := self.queue.Pop(); err != nil {
+			return self.clear(err)
+		}
+		self.pops++
+		self.dropped.Add(1)
+	}
+	if err := self.queue.Push(sample); err != nil {
+		self.dropped.Add(1)
+		return fmt.Errorf("failed to queue sample of %q for storage driver %q: %v", sample.Ref.Name, self.name, err)
+	}
+	self.depth.Set(int64(self.queue.Len()))
+	return nil
+}
+
+// Drops the queued samples after the queue failed. Must be called with the lock held.
+func (self *spillingDriver) clear(cause error) error {
+	self.dropped.Add(int64(self.queue.Len()))
+	err := self.queue.Clear()
+	self.depth.Set(int64(self.queue.Len()))
+	if err != nil {
+		return fmt.Errorf("failed to clear the queue of storage driver %q after %v: %v", self.name, cause, err)
+	}
+	return fmt.Errorf("dropped the queue of storage driver %q: %v", self.name, cause)
+}
+
+// Drops a sample the driver failed to write with a permanent error.
+func (self *spillingDriver) reject(ref info.ContainerReference, err error) {
+	self.dropped.Add(1)
+	self.rejected.Add(1)
+	glog.Warningf("Storage driver %q rejected a sample of %q, dropping it: %v", self.name, ref.Name, err)
+}
+
+// Must be called with the lock held.
+func (self *spillingDriver) backoff() {
+	if self.retryBackoff == 0 {
+		self.retryBackoff = initialSpillBackoff
+	} else if self.retryBackoff < maxSpillBackoff {
+		self.retryBackoff *= 2
+		if self.retryBackoff > maxSpillBackoff {
+			self.retryBackoff = maxSpillBackoff
+		}
+	}
+	self.retryAfter = time.Now().Add(self.retryBackoff)
+}
+
+// Writes the queued samples until the queue is empty or the driver fails again.
+func (self *spillingDriver) replay() {
+	self.lock.Lock()
+	defer func() {
+		self.replaying = false
+		self.lock.Unlock()
+	}()
+	for !self.closed {
+		sample, err := self.queue.Peek()
+		if err != nil {
+			glog.Error(self.clear(err))
+			return
+		}
+		if sample == nil {
+			glog.Infof("Storage driver %q recovered, wrote all queued samples", self.name)
+			self.retryBackoff = 0
+			return
+		}
+		pops := self.pops
+		self.lock.Unlock()
+		err = self.driver.AddStats(sample.Ref, sample.Stats)
+		self.lock.Lock()
+		switch {
+		case IsPermanent(err):
+			// Retrying it would block the samples behind it forever.
+			self.reject(sample.Ref, err)
+		case err != nil:
+			glog.V(2).Infof("Storage driver %q still failing, %d samples queued: %v", self.name, self.queue.Len(), err)
+			self.backoff()
+			return
+		default:
+			self.replayed.Add(1)
+		}
+		if pops != self.pops {
+			// Dropped while it was written.
+			continue
+		}
+		if err := self.queue.Pop(); err != nil {
+			glog.Error(self.clear(err))
+			return
+		}
+		self.pops++
+		self.depth.Set(int64(self.queue.Len()))
+	}
+}
+
+// Machine stats are not queued, they are superseded by the next ones.
+func (self *spillingDriver) AddMachineStats(stats *info.MachineStats) error {
+	machineDriver, ok := self.driver.(MachineStatsDriver)
+	if !ok {
+		return nil
+	}
+	err := machineDriver.AddMachineStats(stats)
+	if err != nil {
+		self.dropped.Add(1)
+	}
+	return err
+}
+
+// Failed writes are queued, samples are only dropped when the queue overflows.
+func (self *spillingDriver) Status() []info.StorageDriverStatus {
+	statusDriver, ok := self.driver.(StatusDriver)
+	if !ok {
+		return nil
+	}
+	statuses := statusDriver.Status()
+	for i := range statuses {
+		statuses[i].Dropped = uint64(self.dropped.Value())
+		statuses[i].Queued = uint64(self.depth.Value())
+	}
+	return statuses
+}
+
+func (self *spillingDriver) RecentStats(containerName string, numStats int) ([]*info.ContainerStats, error) {
+	return self.driver.RecentStats(containerName, numStats)
+}
+
+func (self *spillingDriver) History(containerName string, numStats int) ([]*info.ContainerStats, error) {
+	history, ok := self.driver.(HistoryDriver)
+	if !ok {
+		return nil, fmt.Errorf("storage driver %q keeps no history", self.name)
+	}
+	return history.History(containerName, numStats)
+}
+
+func (self *spillingDriver) StatsInRange(containerName string, start, end time.Time, maxStats int) ([]*info.ContainerStats, error) {
+	timeRange, ok := self.driver.(TimeRangeDriver)
+	if !ok {
+		return nil, fmt.Errorf("storage driver %q cannot find time ranges", self.name)
+	}
+	return timeRange.StatsInRange(containerName, start, end, maxStats)
+}
+
+func (self *spillingDriver) Close() error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.closed = true
+	if err := self.queue.Close(); err != nil {
+		glog.Errorf("Failed to close the queue of storage driver %q: %v", self.name, err)
+	}
+	return self.driver.Close()
+}
+
+// Queue lost when cAdvisor exits.
+type memoryQueue struct {
+	samples []*spilledSample
+}
+
+func (self *memoryQueue) Push(sample *spilledSample) error {
+	self.samples = append(self.samples, sample)
+	return nil
+}
+
+func (self *memoryQueue) Peek() (*spilledSample, error) {
+	if len(self.samples) == 0 {
+		return nil, nil
+	}
+	return self.samples[0], nil
+}
+
+func (self *memoryQueue) Pop() error {
+	if len(self.samples) > 0 {
+		self.samples[0] = nil
+		self.samples = self.samples[1:]
+	}
+	return nil
+}
+
+func (self *memoryQueue) Len() int {
+	return len(self.samples)
+}
+
+func (self *memoryQueue) Clear() error {
+	self.samples = nil
+	return nil
+}
+
+func (self *memoryQueue) Close() error {
+	return nil
+}
+
+const (
+	// The file starts with the offset of the oldest sample, followed by the samples. Each is the
+	// length and CRC-32 of its payload followed by the JSON encoded sample.
+	spillHeaderSize = 8
+	spillRecordSize = 8
+	// The samples already written are removed from the file once they take this much space
+	// and more than the queued ones.
+	spillCompactSize = 1 << 20
+)
+
+// Queue kept in a file so it survives restarts.
+type fileQueue struct {
+	path        string
+	file        *os.File
+	readOffset  int64
+	writeOffset int64
+	count       int
+}
+
+func openFileQueue(path string) (*fileQueue, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	ret := &fileQueue{
+		path: path,
+		file: file,
+	}
+	if err := ret.load(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to load the queued samples in %q: %v", path, err)
+	}
+	return ret, nil
+}
+
+// Counts the queued samples, dropping a sample cut short by a crash.
+func (self *fileQueue) load() error {
+	fileInfo, err := self.file.Stat()
+	if err != nil {
+		return err
+	}
+	size := fileInfo.Size()
+	if size < spillHeaderSize {
+		return self.reset()
+	}
+	header := make([]byte, spillRecordSize)
+	if _, err := self.file.ReadAt(header, 0); err != nil {
+		return err
+	}
+	self.readOffset = int64(binary.LittleEndian.Uint64(header))
+	if self.readOffset < spillHeaderSize || self.readOffset > size {
+		return self.reset()
+	}
+	offset := self.readOffset
+	for offset+spillRecordSize <= size {
+		if _, err := self.file.ReadAt(header, offset); err != nil {
+			return err
+		}
+		next := offset + spillRecordSize + int64(binary.LittleEndian.Uint32(header))
+		if next > size {
+			break
+		}
+		offset = next
+		self.count++
+	}
+	self.writeOffset = offset
+	return self.file.Truncate(offset)
+}
+
+func (self *fileQueue) reset() error {
+	self.count = 0
+	self.readOffset = spillHeaderSize
+	self.writeOffset = spillHeaderSize
+	if err := self.file.Truncate(spillHeaderSize); err != nil {
+		return err
+	}
+	return self.writeReadOffset()
+}
+
+func (self *fileQueue) writeReadOffset() error {
+	header := make([]byte, spillHeaderSize)
+	binary.LittleEndian.PutUint64(header, uint64(self.readOffset))
+	_, err := self.file.WriteAt(header, 0)
+	return err
+}
+
+func (self *fileQueue) Push(sample *spilledSample) error {
+	payload, err := json.Marshal(sample)
+	if err != nil {
+		return err
+	}
+	record := make([]byte, spillRecordSize+len(payload))
+	binary.LittleEndian.PutUint32(record[0:], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(record[4:], crc32.ChecksumIEEE(payload))
+	copy(record[spillRecordSize:], payload)
+	if _, err := self.file.WriteAt(record, self.writeOffset); err != nil {
+		// Drop what may have been partially written.
+		self.file.Truncate(self.writeOffset)
+		return err
+	}
+	self.writeOffset += int64(len(record))
+	self.count++
+	return nil
+}
+
+func (self *fileQueue) Peek() (*spilledSample, error) {
+	if self.count == 0 {
+		return nil, nil
+	}
+	header := make([]byte, spillRecordSize)
+	if _, err := self.file.ReadAt(header, self.readOffset); err != nil {
+		return nil, err
+	}
+	payload := make([]byte, binary.LittleEndian.Uint32(header))
+	if _, err := self.file.ReadAt(payload, self.readOffset+spillRecordSize); err != nil && err != io.EOF {
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(header[4:]) {
+		return nil, fmt.Errorf("corrupted sample at offset %d of %q", self.readOffset, self.path)
+	}
+	sample := &spilledSample{}
+	if err := json.Unmarshal(payload, sample); err != nil {
+		return nil, err
+	}
+	return sample, nil
+}
+
+func (self *fileQueue) Pop() error {
+	if self.count == 0 {
+		return nil
+	}
+	header := make([]byte, spillRecordSize)
+	if _, err := self.file.ReadAt(header, self.readOffset); err != nil {
+		return err
+	}
+	self.readOffset += spillRecordSize + int64(binary.LittleEndian.Uint32(header))
+	self.count--
+	if self.count == 0 {
+		return self.reset()
+	}
+	written := self.readOffset - spillHeaderSize
+	if written > spillCompactSize && written > self.writeOffset-self.readOffset {
+		return self.compact()
+	}
+	return self.writeReadOffset()
+}
+
+// Rewrites the file without the samples already written.
+func (self *fileQueue) compact() error {
+	tmpPath := self.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	header := make([]byte, spillHeaderSize)
+	binary.LittleEndian.PutUint64(header, spillHeaderSize)
+	_, err = tmp.Write(header)
+	if err == nil {
+		_, err = io.Copy(tmp, io.NewSectionReader(self.file, self.readOffset, self.writeOffset-self.readOffset))
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, self.path)
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	self.file.Close()
+	self.file = tmp
+	self.writeOffset -= self.readOffset - spillHeaderSize
+	self.readOffset = spillHeaderSize
+	return nil
+}
+
+func (self *fileQueue) Len() int {
+	return self.count
+}
+
+func (self *fileQueue) Clear() error {
+	return self.reset()
+}
+
+func (self *fileQueue) Close() error {
+	return self.file.Close()
+}
@@ -0,0 +1,217 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/info"
+)
+
+func (self *recordingDriver) setErr(err error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.err = err
+}
+
+func (self *recordingDriver) recorded() []string {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return append([]string(nil), self.containers...)
+}
+
+// Adds stats for the named containers, allowing an immediate replay.
+func addSpilled(t *testing.T, driver *spillingDriver, names ...string) {
+	for _, name := range names {
+		driver.lock.Lock()
+		driver.retryAfter = time.Time{}
+		driver.lock.Unlock()
+		if err := driver.AddStats(info.ContainerReference{Name: name}, &info.ContainerStats{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func waitForReplay(t *testing.T, driver *spillingDriver) {
+	for i := 0; i < 100; i++ {
+		driver.lock.Lock()
+		done := !driver.replaying
+		driver.lock.Unlock()
+		if done {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("replay did not finish")
+}
+
+func testSpill(t *testing.T, spillDir string) {
+	backend := &recordingDriver{err: fmt.Errorf("unavailable")}
+	status := newStatusDriver("test_spill", backend)
+	driver, err := newSpillingDriver("test_spill", status, 3, spillDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addSpilled(t, driver, "/a", "/b", "/c", "/d")
+	waitForReplay(t, driver)
+	if driver.queue.Len() != 3 || driver.dropped.String() != "1" {
+		t.Fatalf("expected 3 queued and 1 dropped samples, got %d and %s", driver.queue.Len(), driver.dropped)
+	}
+	if s := driver.Status()[0]; s.Connected || s.Queued != 3 || s.Dropped != 1 {
+		t.Errorf("unexpected status of the failing driver: %+v", s)
+	}
+
+	if spillDir != "" {
+		// Queued samples survive restarts.
+		driver.Close()
+		driver, err = newSpillingDriver("test_spill", status, 3, spillDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if driver.queue.Len() != 3 {
+			t.Fatalf("expected 3 queued samples after a restart, got %d", driver.queue.Len())
+		}
+	}
+	defer driver.Close()
+
+	// The queue is full, the oldest sample is dropped before the queue is replayed.
+	backend.setErr(nil)
+	addSpilled(t, driver, "/e")
+	waitForReplay(t, driver)
+	expected := []string{"/c", "/d", "/e"}
+	if !reflect.DeepEqual(backend.recorded(), expected) {
+		t.Errorf("expected samples %v to be written in order, got %v", expected, backend.recorded())
+	}
+	if driver.queue.Len() != 0 || driver.depth.String() != "0" {
+		t.Errorf("expected an empty queue, got %d samples", driver.queue.Len())
+	}
+	if s := driver.Status()[0]; !s.Connected || s.Queued != 0 || s.ConsecutiveFailures != 0 {
+		t.Errorf("unexpected status of the recovered driver: %+v", s)
+	}
+
+	// Written directly once the queue is empty.
+	addSpilled(t, driver, "/f")
+	if len(backend.recorded()) != 4 {
+		t.Errorf("expected the sample to be written directly, got %v", backend.recorded())
+	}
+}
+
+func TestSpillInMemory(t *testing.T) {
+	testSpill(t, "")
+}
+
+func TestSpillToDisk(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cadvisor-spill")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	testSpill(t, dir)
+}
+
+// Rejects the stats of the given containers permanently.
+type rejectingDriver struct {
+	*recordingDriver
+	rejected map[string]bool
+}
+
+func (self *rejectingDriver) AddStats(ref info.ContainerReference, stats *info.ContainerStats) error {
+	if self.rejected[ref.Name] {
+		return Permanent(fmt.Errorf("invalid stats of %q", ref.Name))
+	}
+	return self.recordingDriver.AddStats(ref, stats)
+}
+
+func TestSpillDropsRejectedSamples(t *testing.T) {
+	backend := &recordingDriver{err: fmt.Errorf("unavailable")}
+	driver, err := newSpillingDriver("test_spill_rejected", &rejectingDriver{backend, map[string]bool{"/b": true}}, 10, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer driver.Close()
+	addSpilled(t, driver, "/a", "/b", "/c")
+	waitForReplay(t, driver)
+
+	// The rejected sample does not hold up the ones queued behind it.
+	backend.setErr(nil)
+	addSpilled(t, driver, "/d")
+	waitForReplay(t, driver)
+	expected := []string{"/a", "/c", "/d"}
+	if !reflect.DeepEqual(backend.recorded(), expected) {
+		t.Errorf("expected samples %v to be written, got %v", expected, backend.recorded())
+	}
+	if driver.queue.Len() != 0 || driver.rejected.String() != "1" || driver.dropped.String() != "1" {
+		t.Errorf("expected an empty queue and 1 rejected sample, got %d queued, %s rejected and %s dropped", driver.queue.Len(), driver.rejected, driver.dropped)
+	}
+
+	// Not queued when written directly.
+	if err := driver.AddStats(info.ContainerReference{Name: "/b"}, &info.ContainerStats{}); !IsPermanent(err) {
+		t.Errorf("expected a permanent error, got %v", err)
+	}
+	if driver.queue.Len() != 0 || driver.rejected.String() != "2" {
+		t.Errorf("expected the rejected sample to be dropped, got %d queued and %s rejected", driver.queue.Len(), driver.rejected)
+	}
+}
+
+func TestFileQueueCompaction(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cadvisor-spill")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "test.spill")
+	queue, err := openFileQueue(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		if err := queue.Push(&spilledSample{Ref: info.ContainerReference{Name: fmt.Sprintf("/%d", i)}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	queue.Pop()
+	queue.Pop()
+	if err := queue.compact(); err != nil {
+		t.Fatal(err)
+	}
+	// A sample cut short is dropped when the queue is opened.
+	queue.file.WriteAt([]byte{100, 0, 0}, queue.writeOffset)
+	queue.Close()
+
+	queue, err = openFileQueue(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer queue.Close()
+	if queue.Len() != 2 {
+		t.Fatalf("expected 2 queued samples, got %d", queue.Len())
+	}
+	for _, name := range []string{"/2", "/3"} {
+		sample, err := queue.Peek()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sample.Ref.Name != name {
+			t.Errorf("expected sample of %q, got %q", name, sample.Ref.Name)
+		}
+		queue.Pop()
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statsd
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/cadvisor/info"
+	"github.com/google/cadvisor/storage"
+)
+
+var argGraphiteHost = flag.String("storage_driver_graphite_host", "localhost:2003", "Graphite server (host:port) the graphite storage driver sends metrics to using the plaintext protocol")
+
+func init() {
+	storage.RegisterStorageDriver("graphite", newGraphite)
+}
+
+// Sends all metrics with their value as-is, cumulative values can be turned into rates in Graphite.
+type graphiteStorage struct {
+	machineName string
+	namespace   string
+	address     string
+
+	lock sync.Mutex
+	conn net.Conn
+}
+
+func newGraphite() (storage.StorageDriver, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+	return NewGraphite(hostname, *argNamespace, *argGraphiteHost)
+}
+
+func NewGraphite(machineName, namespace, address string) (*graphiteStorage, error) {
+	ret := &graphiteStorage{
+		machineName: machineName,
+		namespace:   namespace,
+		address:     address,
+	}
+	// Fail early if the server is unreachable.
+	if err := ret.connect(); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func (self *graphiteStorage) connect() error {
+	conn, err := net.DialTimeout("tcp", self.address, 10*time.Second)
+	if err != nil {
+		return fmt.Errorf("failed to connect to Graphite at %q: %v", self.address, err)
+	}
+	self.conn = conn
+	return nil
+}
+
+func (self *graphiteStorage) AddStats(ref info.ContainerReference, stats *info.ContainerStats) error {
+	if stats == nil {
+		return nil
+	}
+	return self.sendMetrics(metricPrefix(self.namespace, self.machineName, ref), statsToMetrics(stats), stats.Timestamp)
+}
+
+func (self *graphiteStorage) AddMachineStats(stats *info.MachineStats) error {
+	return self.sendMetrics(machineMetricPrefix(self.namespace, self.machineName), machineStatsToMetrics(stats), stats.Timestamp)
+}
+
+func (self *graphiteStorage) sendMetrics(prefix string, metrics []metric, timestamp time.Time) error {
+	var buf bytes.Buffer
+	for _, m := range metrics {
+		fmt.Fprintf(&buf, "%s.%s %d %d\n", prefix, m.name, m.value, timestamp.Unix())
+	}
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if self.conn == nil {
+		if err := self.connect(); err != nil {
+			return err
+		}
+	}
+	self.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	_, err := self.conn.Write(buf.Bytes())
+	if err != nil {
+		// Reconnect on the next sample.
+		self.conn.Close()
+		self.conn = nil
+		return fmt.Errorf("failed to send metrics to Graphite: %v", err)
+	}
+	return nil
+}
+
+func (self *graphiteStorage) RecentStats(containerName string, numStats int) ([]*info.ContainerStats, error) {
+	return nil, fmt.Errorf("the graphite storage driver does not support reading stats")
+}
+
+func (self *graphiteStorage) Close() error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if self.conn == nil {
+		return nil
+	}
+	err := self.conn.Close()
+	self.conn = nil
+	return err
+}
@@ -0,0 +1,113 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package statsd implements storage drivers exporting stats as StatsD or Graphite metrics.
+package statsd
+
+import (
+	"flag"
+	"strings"
+
+	"github.com/google/cadvisor/info"
+)
+
+var argNamespace = flag.String("storage_driver_metrics_namespace", "cadvisor", "prefix of the metrics exported by the statsd and graphite storage drivers, metrics are named <namespace>.<machine>.<container>.<metric> and <namespace>.<machine>.machine.<metric> for the whole machine")
+
+// A metric extracted from a stats sample.
+type metric struct {
+	name  string
+	value uint64
+
+	// Whether the value only ever increases (e.g.: CPU time) as opposed to being a gauge.
+	cumulative bool
+}
+
+// Replaces the characters that have a meaning in metric names.
+func sanitize(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '.', '/', ' ', ':', '|', '@':
+			return '_'
+		}
+		return r
+	}, name)
+}
+
+// Returns the prefix of the metrics of the specified container on the machine.
+func metricPrefix(namespace, machineName string, ref info.ContainerReference) string {
+	// Prefer the alias of the container (e.g.: Docker name), it is more readable.
+	container := ref.Name
+	if len(ref.Aliases) > 0 {
+		container = ref.Aliases[0]
+	}
+	container = strings.Trim(container, "/")
+	if container == "" {
+		container = "root"
+	}
+	parts := strings.Split(container, "/")
+	for i := range parts {
+		parts[i] = sanitize(parts[i])
+	}
+
+	prefix := sanitize(machineName) + "." + strings.Join(parts, ".")
+	if namespace != "" {
+		prefix = namespace + "." + prefix
+	}
+	return prefix
+}
+
+// Returns the prefix of the metrics of the whole machine.
+func machineMetricPrefix(namespace, machineName string) string {
+	prefix := sanitize(machineName) + ".machine"
+	if namespace != "" {
+		prefix = namespace + "." + prefix
+	}
+	return prefix
+}
+
+// Returns the metrics of a machine stats sample.
+func machineStatsToMetrics(stats *info.MachineStats) []metric {
+	return []metric{
+		{"num_cores", uint64(stats.NumCores), false},
+		{"memory.capacity", uint64(stats.MemoryCapacity), false},
+		{"fs.capacity", stats.FsCapacity, false},
+		{"cpu.usage.total", stats.CpuUsage, true},
+		{"memory.usage", stats.MemoryUsage, false},
+		{"memory.working_set", stats.MemoryWorkingSet, false},
+		{"fs.usage", stats.FsUsage, false},
+		{"network.rx_bytes", stats.Network.RxBytes, true},
+		{"network.rx_errors", stats.Network.RxErrors, true},
+		{"network.tx_bytes", stats.Network.TxBytes, true},
+		{"network.tx_errors", stats.Network.TxErrors, true},
+	}
+}
+
+// Returns the metrics of a stats sample.
+func statsToMetrics(stats *info.ContainerStats) []metric {
+	metrics := []metric{
+		{"cpu.usage.total", stats.Cpu.Usage.Total, true},
+		{"cpu.usage.user", stats.Cpu.Usage.User, true},
+		{"cpu.usage.system", stats.Cpu.Usage.System, true},
+		{"memory.usage", stats.Memory.Usage, false},
+		{"memory.working_set", stats.Memory.WorkingSet, false},
+		{"network.rx_bytes", stats.Network.RxBytes, true},
+		{"network.rx_errors", stats.Network.RxErrors, true},
+		{"network.tx_bytes", stats.Network.TxBytes, true},
+		{"network.tx_errors", stats.Network.TxErrors, true},
+	}
+	for _, fs := range stats.Filesystem {
+		metrics = append(metrics, metric{"fs." + sanitize(strings.TrimPrefix(fs.Device, "/dev/")) + ".usage", fs.Usage, false})
+	}
+	return metrics
+}
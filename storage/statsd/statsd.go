@@ -0,0 +1,155 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statsd
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/cadvisor/info"
+	"github.com/google/cadvisor/storage"
+)
+
+var argStatsdHost = flag.String("storage_driver_statsd_host", "localhost:8125", "StatsD server (host:port) the statsd storage driver sends metrics to over UDP")
+
+const (
+	// Keep packets within a typical MTU.
+	maxPacketSize = 1400
+	// Last values not updated for that long are dropped, their container is likely gone.
+	staleValueAge = 10 * time.Minute
+)
+
+func init() {
+	storage.RegisterStorageDriver("statsd", newStatsd)
+}
+
+// Sends gauges as they are and cumulative values as counters of their increase.
+type statsdStorage struct {
+	machineName string
+	namespace   string
+	conn        net.Conn
+
+	lock sync.Mutex
+
+	// Last value of the cumulative metrics, keyed by metric name.
+	lastValues map[string]lastValue
+	lastPrune  time.Time
+}
+
+type lastValue struct {
+	value   uint64
+	updated time.Time
+}
+
+func newStatsd() (storage.StorageDriver, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+	return NewStatsd(hostname, *argNamespace, *argStatsdHost)
+}
+
+func NewStatsd(machineName, namespace, address string) (*statsdStorage, error) {
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	return &statsdStorage{
+		machineName: machineName,
+		namespace:   namespace,
+		conn:        conn,
+		lastValues:  make(map[string]lastValue),
+		lastPrune:   time.Now(),
+	}, nil
+}
+
+func (self *statsdStorage) AddStats(ref info.ContainerReference, stats *info.ContainerStats) error {
+	if stats == nil {
+		return nil
+	}
+	return self.sendMetrics(metricPrefix(self.namespace, self.machineName, ref), statsToMetrics(stats))
+}
+
+func (self *statsdStorage) AddMachineStats(stats *info.MachineStats) error {
+	return self.sendMetrics(machineMetricPrefix(self.namespace, self.machineName), machineStatsToMetrics(stats))
+}
+
+func (self *statsdStorage) sendMetrics(prefix string, metrics []metric) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	now := time.Now()
+	if now.Sub(self.lastPrune) > staleValueAge {
+		self.pruneLastValues(now)
+	}
+	var packet bytes.Buffer
+	for _, m := range metrics {
+		name := prefix + "." + m.name
+		var line string
+		if m.cumulative {
+			last, ok := self.lastValues[name]
+			self.lastValues[name] = lastValue{m.value, now}
+			// The first sample and counter resets have no meaningful increase.
+			if !ok || m.value < last.value {
+				continue
+			}
+			line = fmt.Sprintf("%s:%d|c\n", name, m.value-last.value)
+		} else {
+			line = fmt.Sprintf("%s:%d|g\n", name, m.value)
+		}
+		if packet.Len()+len(line) > maxPacketSize {
+			if err := self.send(packet.Bytes()); err != nil {
+				return err
+			}
+			packet.Reset()
+		}
+		packet.WriteString(line)
+	}
+	if packet.Len() > 0 {
+		return self.send(packet.Bytes())
+	}
+	return nil
+}
+
+// Drops the last values not updated recently. Must be called with the lock held.
+func (self *statsdStorage) pruneLastValues(now time.Time) {
+	self.lastPrune = now
+	for name, last := range self.lastValues {
+		if now.Sub(last.updated) > staleValueAge {
+			delete(self.lastValues, name)
+		}
+	}
+}
+
+func (self *statsdStorage) send(packet []byte) error {
+	// Drop the trailing newline.
+	_, err := self.conn.Write(packet[:len(packet)-1])
+	if err != nil {
+		return fmt.Errorf("failed to send metrics to StatsD: %v", err)
+	}
+	return nil
+}
+
+func (self *statsdStorage) RecentStats(containerName string, numStats int) ([]*info.ContainerStats, error) {
+	return nil, fmt.Errorf("the statsd storage driver does not support reading stats")
+}
+
+func (self *statsdStorage) Close() error {
+	return self.conn.Close()
+}
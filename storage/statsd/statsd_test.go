@@ -0,0 +1,168 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statsd
+
+import (
+	"net"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/info"
+)
+
+func TestMetricPrefix(t *testing.T) {
+	cases := []struct {
+		ref      info.ContainerReference
+		expected string
+	}{
+		{info.ContainerReference{Name: "/"}, "cadvisor.host_example_com.root"},
+		{info.ContainerReference{Name: "/system/sshd"}, "cadvisor.host_example_com.system.sshd"},
+		{info.ContainerReference{Name: "/docker/abcd", Aliases: []string{"web.1", "abcd"}}, "cadvisor.host_example_com.web_1"},
+	}
+	for _, c := range cases {
+		if prefix := metricPrefix("cadvisor", "host.example.com", c.ref); prefix != c.expected {
+			t.Errorf("expected prefix %q for %+v, got %q", c.expected, c.ref, prefix)
+		}
+	}
+}
+
+func TestStatsdCounters(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	driver, err := NewStatsd("machine", "cadvisor", conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer driver.Close()
+
+	ref := info.ContainerReference{Name: "/test"}
+	for _, usage := range []uint64{100, 250} {
+		stats := &info.ContainerStats{Timestamp: time.Now()}
+		stats.Cpu.Usage.Total = usage
+		stats.Memory.Usage = 4096
+		if err := driver.AddStats(ref, stats); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The first sample only has gauges, the second also has the counters.
+	buf := make([]byte, maxPacketSize)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var lines []string
+	for i := 0; i < 2; i++ {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = strings.Split(string(buf[:n]), "\n")
+	}
+	sort.Strings(lines)
+	expected := []string{
+		"cadvisor.machine.test.cpu.usage.total:150|c",
+		"cadvisor.machine.test.memory.usage:4096|g",
+	}
+	for _, e := range expected {
+		i := sort.SearchStrings(lines, e)
+		if i == len(lines) || lines[i] != e {
+			t.Errorf("expected %q in %q", e, lines)
+		}
+	}
+}
+
+func TestStatsdPrunesLastValues(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	driver, err := NewStatsd("machine", "cadvisor", conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer driver.Close()
+
+	stats := &info.ContainerStats{Timestamp: time.Now()}
+	if err := driver.AddStats(info.ContainerReference{Name: "/gone"}, stats); err != nil {
+		t.Fatal(err)
+	}
+	if len(driver.lastValues) == 0 {
+		t.Fatal("expected the last values of /gone to be kept")
+	}
+
+	// The values of /gone are no longer updated.
+	old := time.Now().Add(-2 * staleValueAge)
+	for name, last := range driver.lastValues {
+		driver.lastValues[name] = lastValue{last.value, old}
+	}
+	driver.lastPrune = old
+	if err := driver.AddStats(info.ContainerReference{Name: "/test"}, stats); err != nil {
+		t.Fatal(err)
+	}
+	for name := range driver.lastValues {
+		if strings.Contains(name, ".gone.") {
+			t.Errorf("expected the stale value %q to be pruned", name)
+		}
+	}
+	if len(driver.lastValues) == 0 {
+		t.Error("expected the last values of /test to be kept")
+	}
+}
+
+func TestGraphiteMachineStats(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	received := make(chan string)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			close(received)
+			return
+		}
+		defer conn.Close()
+		buf := make([]byte, 4096)
+		n, _ := conn.Read(buf)
+		received <- string(buf[:n])
+	}()
+
+	driver, err := NewGraphite("host", "cadvisor", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer driver.Close()
+	stats := &info.MachineStats{
+		Timestamp:      time.Unix(1400000000, 0),
+		NumCores:       4,
+		MemoryCapacity: 8192,
+	}
+	if err := driver.AddMachineStats(stats); err != nil {
+		t.Fatal(err)
+	}
+	lines := <-received
+	for _, e := range []string{"cadvisor.host.machine.num_cores 4 1400000000\n", "cadvisor.host.machine.memory.capacity 8192 1400000000\n"} {
+		if !strings.Contains(lines, e) {
+			t.Errorf("expected %q in %q", e, lines)
+		}
+	}
+}
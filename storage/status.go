@@ -0,0 +1,93 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/cadvisor/info"
+)
+
+// Records the outcome of the writes to a storage driver.
+type statusDriver struct {
+	StorageDriver
+
+	lock   sync.Mutex
+	status info.StorageDriverStatus
+}
+
+func newStatusDriver(name string, driver StorageDriver) *statusDriver {
+	return &statusDriver{
+		StorageDriver: driver,
+		status: info.StorageDriverStatus{
+			Name: name,
+			// The driver was created, most drivers connect to their backend when created.
+			Connected: true,
+		},
+	}
+}
+
+func (self *statusDriver) record(err error) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if err == nil {
+		self.status.Connected = true
+		self.status.LastSuccess = time.Now()
+		self.status.ConsecutiveFailures = 0
+		return nil
+	}
+	self.status.Connected = false
+	self.status.LastError = err.Error()
+	self.status.LastErrorTime = time.Now()
+	self.status.ConsecutiveFailures++
+	self.status.Dropped++
+	return err
+}
+
+func (self *statusDriver) AddStats(ref info.ContainerReference, stats *info.ContainerStats) error {
+	return self.record(self.StorageDriver.AddStats(ref, stats))
+}
+
+func (self *statusDriver) AddMachineStats(stats *info.MachineStats) error {
+	machineDriver, ok := self.StorageDriver.(MachineStatsDriver)
+	if !ok {
+		return nil
+	}
+	return self.record(machineDriver.AddMachineStats(stats))
+}
+
+func (self *statusDriver) History(containerName string, numStats int) ([]*info.ContainerStats, error) {
+	history, ok := self.StorageDriver.(HistoryDriver)
+	if !ok {
+		return nil, fmt.Errorf("storage driver %q keeps no history", self.status.Name)
+	}
+	return history.History(containerName, numStats)
+}
+
+func (self *statusDriver) StatsInRange(containerName string, start, end time.Time, maxStats int) ([]*info.ContainerStats, error) {
+	timeRange, ok := self.StorageDriver.(TimeRangeDriver)
+	if !ok {
+		return nil, fmt.Errorf("storage driver %q cannot find time ranges", self.status.Name)
+	}
+	return timeRange.StatsInRange(containerName, start, end, maxStats)
+}
+
+func (self *statusDriver) Status() []info.StorageDriverStatus {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return []info.StorageDriverStatus{self.status}
+}
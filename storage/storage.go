@@ -14,7 +14,15 @@
 
 package storage
 
-import "github.com/google/cadvisor/info"
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/cadvisor/info"
+)
 
 type StorageDriver interface {
 	AddStats(ref info.ContainerReference, stats *info.ContainerStats) error
@@ -31,3 +39,78 @@ type StorageDriver interface {
 	// on the implementation of the storage driver.
 	Close() error
 }
+
+var ArgHistoryDriver = flag.String("storage_history_driver", "", "storage driver the stats older than those held in memory are read back from, e.g. disk or influxdb. It must be one of --storage_driver. Empty serves only the stats held in memory")
+
+// Implemented by storage drivers that can read back the stats they store, on the local machine or
+// in a database. The in-memory storage reads from them the stats it does not hold, e.g. older ones
+// or after cAdvisor restarted, when the driver is --storage_history_driver.
+type HistoryDriver interface {
+	// Same semantics as StorageDriver.RecentStats.
+	History(containerName string, numStats int) ([]*info.ContainerStats, error)
+}
+
+// Implemented by storage drivers that can find the stats of a time range.
+type TimeRangeDriver interface {
+	// Returns the latest maxStats stats (all if negative) timestamped from start to end, in time
+	// increasing order. A zero start or end leaves the range open on that side.
+	StatsInRange(containerName string, start, end time.Time, maxStats int) ([]*info.ContainerStats, error)
+}
+
+// Implemented by storage drivers exporting the stats of the whole machine as their own series.
+type MachineStatsDriver interface {
+	AddMachineStats(stats *info.MachineStats) error
+}
+
+// Implemented by storage drivers holding the stats of containers, e.g. in memory, which can drop
+// those of a container no longer monitored.
+type RemovingDriver interface {
+	RemoveContainer(containerName string)
+}
+
+// Implemented by storage drivers reporting the health of the backends they write to.
+type StatusDriver interface {
+	Status() []info.StorageDriverStatus
+}
+
+// Creates a storage driver, configured through its flags.
+type StorageDriverFunc func() (StorageDriver, error)
+
+// Global list of storage drivers, keyed by name.
+var (
+	registeredDrivers     = make(map[string]StorageDriverFunc)
+	registeredDriversLock sync.RWMutex
+)
+
+// Registers a storage driver so it can be selected by name with --storage_driver.
+// Drivers are expected to register themselves from an init() function.
+func RegisterStorageDriver(name string, f StorageDriverFunc) {
+	registeredDriversLock.Lock()
+	defer registeredDriversLock.Unlock()
+
+	registeredDrivers[name] = f
+}
+
+// Creates the storage driver registered with the specified name.
+func New(name string) (StorageDriver, error) {
+	registeredDriversLock.RLock()
+	f, ok := registeredDrivers[name]
+	registeredDriversLock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown storage driver %q, registered drivers: %v", name, ListDrivers())
+	}
+	return f()
+}
+
+// Returns the names of the registered storage drivers, sorted.
+func ListDrivers() []string {
+	registeredDriversLock.RLock()
+	defer registeredDriversLock.RUnlock()
+
+	names := make([]string, 0, len(registeredDrivers))
+	for name := range registeredDrivers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
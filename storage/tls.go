@@ -0,0 +1,97 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"crypto/tls"
+	"net/http"
+	"time"
+
+	"github.com/google/cadvisor/utils"
+)
+
+// Returns the TLS configuration set through the common storage driver flags, nil if
+// -storage_driver_secure is not set.
+func TlsConfigFromFlags() (*tls.Config, error) {
+	if !*ArgDbIsSecure {
+		return nil, nil
+	}
+	return utils.NewTlsConfig(*ArgDbTlsCa, *ArgDbTlsCert, *ArgDbTlsKey, *ArgDbTlsInsecure)
+}
+
+// Returns an HTTP client for the drivers talking to their database over HTTP. It uses the
+// TLS configuration and token of the common storage driver flags. With -storage_driver_secure,
+// http URLs are requested over https.
+func HttpClientFromFlags(timeout time.Duration) (*http.Client, error) {
+	config, err := utils.NewTlsConfig(*ArgDbTlsCa, *ArgDbTlsCert, *ArgDbTlsKey, *ArgDbTlsInsecure)
+	if err != nil {
+		return nil, err
+	}
+	client := NewHttpClient(config, *ArgDbToken, timeout)
+	if *ArgDbIsSecure {
+		client.Transport = &httpsTransport{client.Transport}
+	}
+	return client, nil
+}
+
+// Creates an HTTP client using tlsConfig for HTTPS requests. A non-empty token is sent as a
+// bearer token with every request.
+func NewHttpClient(tlsConfig *tls.Config, token string, timeout time.Duration) *http.Client {
+	var transport http.RoundTripper = &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: tlsConfig,
+	}
+	if token != "" {
+		transport = &tokenTransport{token, transport}
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+	}
+}
+
+type tokenTransport struct {
+	token     string
+	transport http.RoundTripper
+}
+
+func (self *tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Requests must not be modified by round trippers.
+	r := new(http.Request)
+	*r = *req
+	r.Header = make(http.Header, len(req.Header)+1)
+	for k, v := range req.Header {
+		r.Header[k] = v
+	}
+	r.Header.Set("Authorization", "Bearer "+self.token)
+	return self.transport.RoundTrip(r)
+}
+
+// Sends the requests of http URLs over https.
+type httpsTransport struct {
+	transport http.RoundTripper
+}
+
+func (self *httpsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "http" {
+		return self.transport.RoundTrip(req)
+	}
+	r := new(http.Request)
+	*r = *req
+	u := *req.URL
+	u.Scheme = "https"
+	r.URL = &u
+	return self.transport.RoundTrip(r)
+}
@@ -0,0 +1,116 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/utils"
+)
+
+func TestHttpClientSendsToken(t *testing.T) {
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+	}))
+	defer server.Close()
+
+	client := NewHttpClient(nil, "secret", time.Second)
+	req, err := http.NewRequest("GET", server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if auth != "Bearer secret" {
+		t.Errorf("expected bearer token, got %q", auth)
+	}
+	if req.Header.Get("Authorization") != "" {
+		t.Errorf("request was modified: %v", req.Header)
+	}
+}
+
+func TestHttpClientVerifiesWithCa(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	// Unknown CA.
+	_, err := NewHttpClient(nil, "", time.Second).Get(server.URL)
+	if err == nil {
+		t.Fatal("expected certificate verification to fail")
+	}
+
+	caFile, err := ioutil.TempFile("", "ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(caFile.Name())
+	pem.Encode(caFile, &pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	caFile.Close()
+
+	config, err := utils.NewTlsConfig(caFile.Name(), "", "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := NewHttpClient(config, "", time.Second).Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+}
+
+func TestHttpClientSecure(t *testing.T) {
+	var tlsUsed bool
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tlsUsed = r.TLS != nil
+	}))
+	defer server.Close()
+
+	caFile, err := ioutil.TempFile("", "ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(caFile.Name())
+	pem.Encode(caFile, &pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	caFile.Close()
+
+	defer func(secure bool, ca string) {
+		*ArgDbIsSecure, *ArgDbTlsCa = secure, ca
+	}(*ArgDbIsSecure, *ArgDbTlsCa)
+	*ArgDbIsSecure, *ArgDbTlsCa = true, caFile.Name()
+	client, err := HttpClientFromFlags(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The http URL is requested over TLS.
+	resp, err := client.Get("http://" + server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if !tlsUsed {
+		t.Error("expected the request to use TLS")
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"flag"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/cadvisor/info"
+)
+
+var ArgSkipUnchanged = flag.Bool("storage_driver_skip_unchanged", false, "do not export the stats of containers that did not change since they were last exported, e.g. of idle containers, except every --storage_driver_heartbeat")
+var ArgUnchangedEpsilon = flag.Float64("storage_driver_unchanged_epsilon", 0, "relative difference of the values of gauges, e.g. the memory usage, up to which they are unchanged for --storage_driver_skip_unchanged, e.g. 0.01 for 1%. Cumulative counters, e.g. the CPU usage, are unchanged only when equal")
+var ArgHeartbeat = flag.Duration("storage_driver_heartbeat", 5*time.Minute, "interval at which the unchanged stats of containers are still exported with --storage_driver_skip_unchanged")
+
+// Only passes the stats of a container to the driver when they changed since those it last wrote,
+// or a heartbeat is due.
+type unchangedSkippingDriver struct {
+	StorageDriver
+	epsilon   float64
+	heartbeat time.Duration
+
+	lock sync.Mutex
+	// Stats last written, by container name.
+	last map[string]*info.ContainerStats
+	// Time the stats of containers which are gone were last forgotten.
+	lastSweep time.Time
+}
+
+// Wraps the driver to skip unchanged stats if --storage_driver_skip_unchanged is set, otherwise
+// returns it as-is.
+func newUnchangedSkippingDriverFromFlags(driver StorageDriver) (StorageDriver, error) {
+	if !*ArgSkipUnchanged {
+		return driver, nil
+	}
+	if *ArgUnchangedEpsilon < 0 {
+		return nil, fmt.Errorf("the unchanged epsilon must not be negative, got %v", *ArgUnchangedEpsilon)
+	}
+	if *ArgHeartbeat <= 0 {
+		return nil, fmt.Errorf("the heartbeat must be positive, got %v", *ArgHeartbeat)
+	}
+	return newUnchangedSkippingDriver(driver, *ArgUnchangedEpsilon, *ArgHeartbeat), nil
+}
+
+func newUnchangedSkippingDriver(driver StorageDriver, epsilon float64, heartbeat time.Duration) *unchangedSkippingDriver {
+	return &unchangedSkippingDriver{
+		StorageDriver: driver,
+		epsilon:       epsilon,
+		heartbeat:     heartbeat,
+		last:          make(map[string]*info.ContainerStats),
+	}
+}
+
+// Returns whether the stats of the container are to be written.
+func (self *unchangedSkippingDriver) changed(ref info.ContainerReference, stats *info.ContainerStats) bool {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	// Containers whose stats were not written for two heartbeats are gone.
+	if stats.Timestamp.Sub(self.lastSweep) >= self.heartbeat {
+		for name, last := range self.last {
+			if stats.Timestamp.Sub(last.Timestamp) >= 2*self.heartbeat {
+				delete(self.last, name)
+			}
+		}
+		self.lastSweep = stats.Timestamp
+	}
+
+	last, ok := self.last[ref.Name]
+	if ok && stats.Timestamp.Sub(last.Timestamp) < self.heartbeat && stats.StatsApproxEq(last, self.epsilon) {
+		return false
+	}
+	return true
+}
+
+// Records the stats of the container once written, the next ones are compared to them.
+func (self *unchangedSkippingDriver) written(ref info.ContainerReference, stats *info.ContainerStats) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.last[ref.Name] = stats
+}
+
+func (self *unchangedSkippingDriver) AddStats(ref info.ContainerReference, stats *info.ContainerStats) error {
+	if stats == nil || !self.changed(ref, stats) {
+		return nil
+	}
+	if err := self.StorageDriver.AddStats(ref, stats); err != nil {
+		return err
+	}
+	self.written(ref, stats)
+	return nil
+}
+
+func (self *unchangedSkippingDriver) AddMachineStats(stats *info.MachineStats) error {
+	if machineDriver, ok := self.StorageDriver.(MachineStatsDriver); ok {
+		return machineDriver.AddMachineStats(stats)
+	}
+	return nil
+}
+
+func (self *unchangedSkippingDriver) Status() []info.StorageDriverStatus {
+	if statusDriver, ok := self.StorageDriver.(StatusDriver); ok {
+		return statusDriver.Status()
+	}
+	return nil
+}
+
+func (self *unchangedSkippingDriver) History(containerName string, numStats int) ([]*info.ContainerStats, error) {
+	history, ok := self.StorageDriver.(HistoryDriver)
+	if !ok {
+		return nil, fmt.Errorf("storage driver keeps no history")
+	}
+	return history.History(containerName, numStats)
+}
+
+func (self *unchangedSkippingDriver) StatsInRange(containerName string, start, end time.Time, maxStats int) ([]*info.ContainerStats, error) {
+	timeRange, ok := self.StorageDriver.(TimeRangeDriver)
+	if !ok {
+		return nil, fmt.Errorf("storage driver cannot find time ranges")
+	}
+	return timeRange.StatsInRange(containerName, start, end, maxStats)
+}
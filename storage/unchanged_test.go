@@ -0,0 +1,120 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/cadvisor/info"
+)
+
+func TestUnchangedSkippingDriver(t *testing.T) {
+	backend := &statsRecorder{}
+	driver := newUnchangedSkippingDriver(backend, 0.01, time.Minute)
+	start := time.Now()
+	add := func(name string, offset time.Duration, usage uint64) {
+		stats := &info.ContainerStats{Timestamp: start.Add(offset)}
+		stats.Memory.Usage = usage
+		if err := driver.AddStats(info.ContainerReference{Name: name}, stats); err != nil {
+			t.Fatal(err)
+		}
+	}
+	add("/a", 0, 1000)
+	add("/b", 0, 1000)
+	// Within 1%, skipped.
+	add("/a", 10*time.Second, 1005)
+	// Changed.
+	add("/a", 20*time.Second, 2000)
+	// Unchanged, but the heartbeat is due.
+	add("/b", 70*time.Second, 1000)
+	add("/b", 80*time.Second, 1000)
+
+	if !reflect.DeepEqual(backend.containers, []string{"/a", "/b", "/a", "/b"}) {
+		t.Errorf("unexpected stats written for %v", backend.containers)
+	}
+	if usage := backend.stats[2].Memory.Usage; usage != 2000 {
+		t.Errorf("expected the changed stats to be written, got a usage of %d", usage)
+	}
+
+	// The stats of /a were last written more than two heartbeats ago.
+	add("/b", 3*time.Minute, 1000)
+	if _, ok := driver.last["/a"]; ok {
+		t.Errorf("expected the stats of the gone container to be forgotten")
+	}
+}
+
+func TestUnchangedSkippingDriverFailedWrite(t *testing.T) {
+	backend := &statsRecorder{}
+	driver := newUnchangedSkippingDriver(backend, 0.01, time.Minute)
+	start := time.Now()
+	ref := info.ContainerReference{Name: "/a"}
+	add := func(offset time.Duration, usage uint64) error {
+		stats := &info.ContainerStats{Timestamp: start.Add(offset)}
+		stats.Memory.Usage = usage
+		return driver.AddStats(ref, stats)
+	}
+	if err := add(0, 1000); err != nil {
+		t.Fatal(err)
+	}
+	backend.setErr(fmt.Errorf("unavailable"))
+	if err := add(10*time.Second, 2000); err == nil {
+		t.Fatal("expected the error of the driver to be returned")
+	}
+
+	// The same stats are still changed, the failed ones were not written.
+	backend.setErr(nil)
+	if err := add(20*time.Second, 2000); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(backend.containers, []string{"/a", "/a"}) {
+		t.Errorf("expected the stats to be written once the driver recovers, got %v", backend.containers)
+	}
+}
+
+func TestUnchangedSkippingDriverCounters(t *testing.T) {
+	start := time.Now()
+	newStats := func(offset time.Duration) *info.ContainerStats {
+		stats := &info.ContainerStats{Timestamp: start.Add(offset)}
+		stats.Cpu.Usage.Total = 1000000
+		stats.Network.TxBytes = 1000000
+		stats.Filesystem = []info.FsStats{{Device: "/dev/sda1", WritesCompleted: 1000}}
+		stats.DiskIo.IoServiced = []info.PerDiskStats{{Stats: map[string]uint64{"Write": 1000}}}
+		return stats
+	}
+	for name, change := range map[string]func(stats *info.ContainerStats){
+		"CPU usage":                func(stats *info.ContainerStats) { stats.Cpu.Usage.Total++ },
+		"bytes sent":               func(stats *info.ContainerStats) { stats.Network.TxBytes++ },
+		"writes to a filesystem":   func(stats *info.ContainerStats) { stats.Filesystem[0].WritesCompleted++ },
+		"writes to a block device": func(stats *info.ContainerStats) { stats.DiskIo.IoServiced[0].Stats["Write"]++ },
+	} {
+		backend := &statsRecorder{}
+		driver := newUnchangedSkippingDriver(backend, 0.01, time.Minute)
+		ref := info.ContainerReference{Name: "/a"}
+		if err := driver.AddStats(ref, newStats(0)); err != nil {
+			t.Fatal(err)
+		}
+		stats := newStats(10 * time.Second)
+		change(stats)
+		if err := driver.AddStats(ref, stats); err != nil {
+			t.Fatal(err)
+		}
+		if len(backend.stats) != 2 {
+			t.Errorf("%s: expected the counter changed by less than the epsilon to be written", name)
+		}
+	}
+}
@@ -17,76 +17,59 @@ package main
 import (
 	"flag"
 	"fmt"
-	"os"
 	"time"
 
 	"github.com/golang/glog"
 	"github.com/google/cadvisor/manager"
 	"github.com/google/cadvisor/storage"
-	"github.com/google/cadvisor/storage/bigquery"
-	"github.com/google/cadvisor/storage/influxdb"
 	"github.com/google/cadvisor/storage/memory"
+
+	// Register the storage drivers.
+	_ "github.com/google/cadvisor/storage/bigquery"
+	_ "github.com/google/cadvisor/storage/disk"
+	_ "github.com/google/cadvisor/storage/edge"
+	_ "github.com/google/cadvisor/storage/elasticsearch"
+	_ "github.com/google/cadvisor/storage/influxdb"
+	_ "github.com/google/cadvisor/storage/kafka"
+	_ "github.com/google/cadvisor/storage/opentsdb"
+	_ "github.com/google/cadvisor/storage/redis"
+	_ "github.com/google/cadvisor/storage/statsd"
 )
 
-var argDbUsername = flag.String("storage_driver_user", "root", "database username")
-var argDbPassword = flag.String("storage_driver_password", "root", "database password")
-var argDbHost = flag.String("storage_driver_host", "localhost:8086", "database host:port")
-var argDbName = flag.String("storage_driver_db", "cadvisor", "database name")
-var argDbTable = flag.String("storage_driver_table", "stats", "table name")
-var argDbIsSecure = flag.Bool("storage_driver_secure", false, "use secure connection with database")
-var argDbBufferDuration = flag.Duration("storage_driver_buffer_duration", 60*time.Second, "Writes in the storage driver will be buffered for this duration, and committed to the non memory backends as a single transaction")
+var storageDuration = flag.Duration("storage_duration", 2*time.Minute, "How long to keep the recent stats of containers in memory, at the shortest housekeeping interval")
+var storageHistoryMaxStats = flag.Int("storage_history_max_stats", 10000, "Maximum number of stats read back from --storage_history_driver for a single request")
 
 const statsRequestedByUI = 60
 
 func NewStorageDriver(driverName string) (*memory.InMemoryStorage, error) {
-	var storageDriver *memory.InMemoryStorage
-	var backendStorage storage.StorageDriver
-	var err error
+	if *manager.HousekeepingInterval <= 0 {
+		return nil, fmt.Errorf("invalid housekeeping interval %v, it must be positive", *manager.HousekeepingInterval)
+	}
 	// TODO(vmarmol): We shouldn't need the housekeeping interval here and it shouldn't be public.
-	statsToCache := int(*argDbBufferDuration / *manager.HousekeepingInterval)
+	statsToCache := int(*storageDuration / *manager.HousekeepingInterval)
 	if statsToCache < statsRequestedByUI {
 		// The UI requests the most recent 60 stats by default.
 		statsToCache = statsRequestedByUI
 	}
-	switch driverName {
-	case "":
-		backendStorage = nil
-	case "influxdb":
-		var hostname string
-		hostname, err = os.Hostname()
-		if err != nil {
-			return nil, err
-		}
 
-		backendStorage, err = influxdb.New(
-			hostname,
-			*argDbTable,
-			*argDbName,
-			*argDbUsername,
-			*argDbPassword,
-			*argDbHost,
-			*argDbIsSecure,
-			*argDbBufferDuration,
-		)
-	case "bigquery":
-		var hostname string
-		hostname, err = os.Hostname()
+	var backendStorage storage.StorageDriver
+	if driverName != "" {
+		var err error
+		backendStorage, err = storage.NewFromList(driverName)
 		if err != nil {
 			return nil, err
 		}
-		backendStorage, err = bigquery.New(
-			hostname,
-			*argDbTable,
-			*argDbName,
-		)
-
-	default:
-		err = fmt.Errorf("Unknown database driver: %v", *argDbDriver)
 	}
-	if err != nil {
-		return nil, err
+	historyStats := 0
+	if *storage.ArgHistoryDriver != "" {
+		if driverName == "" {
+			return nil, fmt.Errorf("history storage driver %q is not one of the storage drivers", *storage.ArgHistoryDriver)
+		}
+		if *storageHistoryMaxStats <= 0 {
+			return nil, fmt.Errorf("invalid --storage_history_max_stats %d, it must be positive", *storageHistoryMaxStats)
+		}
+		historyStats = *storageHistoryMaxStats
 	}
 	glog.Infof("Caching %d recent stats in memory; using \"%v\" storage driver\n", statsToCache, driverName)
-	storageDriver = memory.New(statsToCache, backendStorage)
-	return storageDriver, nil
+	return memory.New(statsToCache, backendStorage, historyStats), nil
 }
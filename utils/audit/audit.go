@@ -0,0 +1,230 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package audit logs the accesses to the web UI and API of cAdvisor: the client, the endpoint
+// and container it queried, the status of the response and its latency.
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/cadvisor/utils/systemd"
+)
+
+// Destination of the log writing the entries to the journal of systemd.
+const Journald = "journald"
+
+// An access to the web UI or API.
+type Entry struct {
+	Time time.Time `json:"time"`
+	// IP address of the client.
+	Client string `json:"client"`
+	// User the request authenticates as, or "token:" followed by the beginning of the hash of
+	// its bearer token. Whether it did is told by the status.
+	User      string `json:"user,omitempty"`
+	Method    string `json:"method"`
+	Endpoint  string `json:"endpoint"`
+	Container string `json:"container,omitempty"`
+	Status    int    `json:"status"`
+	// Size of the response body.
+	Bytes int64 `json:"bytes"`
+	// Time to serve the request, in seconds.
+	Latency float64 `json:"latency"`
+}
+
+// Writes the entries of the log.
+type sink interface {
+	write(entry *Entry) error
+}
+
+// Writes the entries to a file as JSON, one per line.
+type fileSink struct {
+	lock    sync.Mutex
+	encoder *json.Encoder
+}
+
+func (self *fileSink) write(entry *Entry) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.encoder.Encode(entry)
+}
+
+// Writes the entries to the journal, with their fields as CADVISOR_* fields.
+type journalSink struct {
+	journal *systemd.Journal
+}
+
+func (self journalSink) write(entry *Entry) error {
+	priority := systemd.PriorityInfo
+	switch {
+	case entry.Status >= 500:
+		priority = systemd.PriorityErr
+	case entry.Status == http.StatusUnauthorized || entry.Status == http.StatusForbidden:
+		priority = systemd.PriorityNotice
+	}
+	message := fmt.Sprintf("%s %s %s from %s: %d", entry.User, entry.Method, entry.Endpoint, entry.Client, entry.Status)
+	return self.journal.Send(strings.TrimSpace(message), priority, map[string]string{
+		"CADVISOR_CLIENT":    entry.Client,
+		"CADVISOR_USER":      entry.User,
+		"CADVISOR_METHOD":    entry.Method,
+		"CADVISOR_ENDPOINT":  entry.Endpoint,
+		"CADVISOR_CONTAINER": entry.Container,
+		"CADVISOR_STATUS":    strconv.Itoa(entry.Status),
+		"CADVISOR_BYTES":     strconv.FormatInt(entry.Bytes, 10),
+		"CADVISOR_LATENCY":   strconv.FormatFloat(entry.Latency, 'f', 6, 64),
+	})
+}
+
+// Records the status and size of a response.
+type recorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (self *recorder) WriteHeader(status int) {
+	if self.status == 0 {
+		self.status = status
+	}
+	self.ResponseWriter.WriteHeader(status)
+}
+
+func (self *recorder) Write(b []byte) (int, error) {
+	if self.status == 0 {
+		self.status = http.StatusOK
+	}
+	n, err := self.ResponseWriter.Write(b)
+	self.bytes += int64(n)
+	return n, err
+}
+
+// Flushes the streamed responses, e.g. of events.
+func (self *recorder) Flush() {
+	if flusher, ok := self.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (self *recorder) CloseNotify() <-chan bool {
+	if notifier, ok := self.ResponseWriter.(http.CloseNotifier); ok {
+		return notifier.CloseNotify()
+	}
+	return nil
+}
+
+type handler struct {
+	handler   http.Handler
+	sink      sink
+	container func(r *http.Request) string
+}
+
+func (self *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	rec := &recorder{ResponseWriter: w}
+	self.handler.ServeHTTP(rec, r)
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	entry := &Entry{
+		Time:     start,
+		Client:   clientIp(r),
+		User:     user(r),
+		Method:   r.Method,
+		Endpoint: r.URL.Path,
+		Status:   rec.status,
+		Bytes:    rec.bytes,
+		Latency:  time.Since(start).Seconds(),
+	}
+	if self.container != nil {
+		entry.Container = self.container(r)
+	}
+	if err := self.sink.write(entry); err != nil {
+		glog.Errorf("Failed to write audit log entry %+v: %v", entry, err)
+	}
+}
+
+func clientIp(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Returns the user of the credentials of the request, if any.
+func user(r *http.Request) string {
+	if username, _, ok := r.BasicAuth(); ok {
+		return username
+	}
+	header := r.Header.Get("Authorization")
+	switch {
+	case strings.HasPrefix(header, "Digest "):
+		for _, param := range strings.Split(header[len("Digest "):], ",") {
+			kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(kv) == 2 && kv[0] == "username" {
+				return strings.Trim(kv[1], `"`)
+			}
+		}
+	case strings.HasPrefix(header, "Bearer "):
+		// Tokens are secrets, they are identified by their hash.
+		token := strings.TrimSpace(header[len("Bearer "):])
+		hash := sha256.Sum256([]byte(token))
+		return fmt.Sprintf("token:%x", hash[:6])
+	}
+	return ""
+}
+
+// Returns a handler logging the requests to h to the file at dest, appending an entry per line
+// in JSON, or to the journal of systemd if dest is Journald. container returns the container a
+// request queried, if any. h is returned as-is if dest is empty.
+func New(h http.Handler, dest string, container func(r *http.Request) string) (http.Handler, error) {
+	var s sink
+	switch dest {
+	case "":
+		return h, nil
+	case Journald:
+		journal, err := systemd.NewJournal()
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to journald: %v", err)
+		}
+		s = journalSink{journal}
+	default:
+		f, err := os.OpenFile(dest, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			return nil, err
+		}
+		s = newFileSink(f)
+	}
+	glog.Infof("Logging the accesses to the web UI and API to %s", dest)
+	return &handler{
+		handler:   h,
+		sink:      s,
+		container: container,
+	}, nil
+}
+
+func newFileSink(w io.Writer) *fileSink {
+	return &fileSink{encoder: json.NewEncoder(w)}
+}
@@ -0,0 +1,107 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	logFile := path.Join(dir, "audit.log")
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			http.Error(w, "401 Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("ok"))
+		w.(http.Flusher).Flush()
+	})
+	container := func(r *http.Request) string {
+		return "/docker"
+	}
+	handler, err := New(h, logFile, container)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, _ := http.NewRequest("GET", "/api/v1.3/containers/docker", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	r.SetBasicAuth("admin", "password1")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	r, _ = http.NewRequest("GET", "/api/v1.3/machine", nil)
+	r.RemoteAddr = "10.0.0.2:1234"
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	f, err := os.Open(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("invalid entry %q: %v", scanner.Text(), err)
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %+v", entries)
+	}
+	e := entries[0]
+	if e.Client != "10.0.0.1" || e.User != "admin" || e.Method != "GET" || e.Endpoint != "/api/v1.3/containers/docker" || e.Container != "/docker" || e.Status != http.StatusOK || e.Bytes != 2 || e.Latency < 0 || e.Time.IsZero() {
+		t.Errorf("unexpected entry %+v", e)
+	}
+	if e = entries[1]; e.Client != "10.0.0.2" || e.User != "" || e.Status != http.StatusUnauthorized {
+		t.Errorf("unexpected entry %+v", e)
+	}
+
+	// Without destination, requests are not logged.
+	if handler, err := New(h, "", container); err != nil || handler == nil {
+		t.Errorf("expected the handler as-is, got %v %v", handler, err)
+	}
+}
+
+func TestUser(t *testing.T) {
+	tests := map[string]string{
+		"":                           "",
+		"Basic YWRtaW46cGFzc3dvcmQx": "admin",
+		`Digest username="admin", realm="localhost", nonce="abc"`: "admin",
+		"Bearer secret": "token:2bb80d537b1d",
+	}
+	for header, expected := range tests {
+		r, _ := http.NewRequest("GET", "/", nil)
+		if header != "" {
+			r.Header.Set("Authorization", header)
+		}
+		if u := user(r); u != expected {
+			t.Errorf("expected user %q for %q, got %q", expected, header, u)
+		}
+	}
+}
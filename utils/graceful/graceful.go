@@ -0,0 +1,130 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package graceful serves HTTP until it is shut down, when it stops accepting connections and
+// waits for the requests in progress to complete.
+package graceful
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type Server struct {
+	server *http.Server
+
+	lock      sync.Mutex
+	listeners map[net.Listener]bool
+	conns     map[net.Conn]http.ConnState
+	shutdown  bool
+	// Signaled when a connection closes or becomes idle.
+	changed chan struct{}
+}
+
+// Returns a server of the handler, http.DefaultServeMux if nil.
+func New(handler http.Handler) *Server {
+	self := &Server{
+		listeners: make(map[net.Listener]bool),
+		conns:     make(map[net.Conn]http.ConnState),
+		changed:   make(chan struct{}, 1),
+	}
+	self.server = &http.Server{
+		Handler:   handler,
+		ConnState: self.connState,
+	}
+	return self
+}
+
+// Serves the connections accepted on the listener. Returns nil once the server is shut down.
+func (self *Server) Serve(l net.Listener) error {
+	self.lock.Lock()
+	if self.shutdown {
+		self.lock.Unlock()
+		l.Close()
+		return nil
+	}
+	self.listeners[l] = true
+	self.lock.Unlock()
+
+	err := self.server.Serve(l)
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	delete(self.listeners, l)
+	if self.shutdown {
+		return nil
+	}
+	return err
+}
+
+func (self *Server) connState(conn net.Conn, state http.ConnState) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	switch state {
+	case http.StateNew, http.StateActive:
+		self.conns[conn] = state
+	case http.StateIdle:
+		if self.shutdown {
+			conn.Close()
+		}
+		self.conns[conn] = state
+	case http.StateHijacked, http.StateClosed:
+		delete(self.conns, conn)
+	}
+	select {
+	case self.changed <- struct{}{}:
+	default:
+	}
+}
+
+// Stops accepting connections and waits for the requests in progress to complete, for up to
+// timeout. The connections still open then are closed.
+func (self *Server) Shutdown(timeout time.Duration) error {
+	self.lock.Lock()
+	self.shutdown = true
+	self.server.SetKeepAlivesEnabled(false)
+	for l := range self.listeners {
+		l.Close()
+	}
+	// Connections without a request in progress are closed right away.
+	for conn, state := range self.conns {
+		if state == http.StateNew || state == http.StateIdle {
+			conn.Close()
+		}
+	}
+	self.lock.Unlock()
+
+	deadline := time.After(timeout)
+	for {
+		self.lock.Lock()
+		active := len(self.conns)
+		self.lock.Unlock()
+		if active == 0 {
+			return nil
+		}
+		select {
+		case <-self.changed:
+		case <-deadline:
+			self.lock.Lock()
+			defer self.lock.Unlock()
+			for conn := range self.conns {
+				conn.Close()
+			}
+			return fmt.Errorf("closed %d connections with requests still in progress after %v", len(self.conns), timeout)
+		}
+	}
+}
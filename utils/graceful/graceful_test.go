@@ -0,0 +1,97 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graceful
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// Serves a handler waiting to be released, returns the URL of the server.
+func serve(t *testing.T, started chan struct{}, release chan struct{}) (*Server, string, chan error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := New(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		w.Write([]byte("done"))
+	}))
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Serve(l)
+	}()
+	return server, "http://" + l.Addr().String(), served
+}
+
+func TestShutdownWaitsForRequests(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	server, url, served := serve(t, started, release)
+
+	response := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			response <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		response <- string(body)
+	}()
+	<-started
+
+	shutdown := make(chan error, 1)
+	go func() {
+		shutdown <- server.Shutdown(time.Second)
+	}()
+	if err := <-served; err != nil {
+		t.Errorf("expected serving to stop without error, got %v", err)
+	}
+	select {
+	case err := <-shutdown:
+		t.Fatalf("shut down with a request in progress: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if body := <-response; body != "done" {
+		t.Errorf("expected the request in progress to complete, got %q", body)
+	}
+	if err := <-shutdown; err != nil {
+		t.Error(err)
+	}
+	if _, err := http.Get(url); err == nil {
+		t.Error("expected new connections to be refused")
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	defer close(release)
+	server, url, _ := serve(t, started, release)
+
+	go http.Get(url)
+	<-started
+	if err := server.Shutdown(50 * time.Millisecond); err == nil {
+		t.Error("expected the request still in progress to be reported")
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package graceful
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestListenUnix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "graceful")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := path.Join(dir, "cadvisor.sock")
+
+	l, err := ListenUnix(socket, 0600, os.Getgid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&os.ModePerm != 0600 {
+		t.Errorf("expected mode 0600, got %v", info.Mode())
+	}
+	if _, err := ListenUnix(socket, 0600, -1); err == nil {
+		t.Errorf("expected an error listening on a socket in use")
+	}
+
+	server := New(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Serve(l)
+	}()
+	client := &http.Client{Transport: &http.Transport{
+		Dial: func(network, addr string) (net.Conn, error) {
+			return net.Dial("unix", socket)
+		},
+	}}
+	resp, err := client.Get("http://cadvisor/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "ok" {
+		t.Errorf("unexpected response %q", body)
+	}
+	if err := server.Shutdown(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-served; err != nil {
+		t.Fatal(err)
+	}
+
+	// The socket of a previous run is replaced.
+	stale, err := net.ListenUnix("unix", &net.UnixAddr{Name: socket, Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.SetUnlinkOnClose(false)
+	stale.Close()
+	l, err = ListenUnix(socket, 0660, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+
+	// Other files are not.
+	file := path.Join(dir, "file")
+	if err := ioutil.WriteFile(file, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ListenUnix(file, 0600, -1); err == nil {
+		t.Errorf("expected an error listening on a regular file")
+	}
+}
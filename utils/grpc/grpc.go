@@ -0,0 +1,131 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package grpc implements a minimal client for unary gRPC calls to local services listening on a
+// Unix socket (e.g.: container runtimes), without generated code: callers encode and decode the
+// protobuf messages with the helpers of this package.
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// gRPC status code of successful calls.
+const statusOk = 0
+
+type Client struct {
+	socket string
+	client *http.Client
+}
+
+// Creates a client of the service listening on the Unix socket. Calls fail after timeout.
+func NewClient(socket string, timeout time.Duration) *Client {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socket)
+		},
+		// gRPC runs over HTTP/2, without TLS on local sockets.
+		Protocols: new(http.Protocols),
+	}
+	transport.Protocols.SetUnencryptedHTTP2(true)
+	return &Client{
+		socket: socket,
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   timeout,
+		},
+	}
+}
+
+// Calls the method (e.g.: "/containerd.services.containers.v1.Containers/List") with the encoded
+// request message and returns the encoded response message. The metadata is sent as headers.
+func (self *Client) Invoke(method string, request []byte, metadata map[string]string) ([]byte, error) {
+	// Messages are prefixed by a compression flag and their length.
+	frame := make([]byte, 5, 5+len(request))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(request)))
+	frame = append(frame, request...)
+
+	req, err := http.NewRequest("POST", "http://localhost"+method, bytes.NewReader(frame))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+	for key, value := range metadata {
+		req.Header.Set(key, value)
+	}
+	resp, err := self.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call %s on %q: %v", method, self.socket, err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the response of %s: %v", method, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("call to %s failed with HTTP status %q", method, resp.Status)
+	}
+	if err := callStatus(resp); err != nil {
+		return nil, fmt.Errorf("call to %s failed: %v", method, err)
+	}
+
+	if len(body) < 5 {
+		return nil, fmt.Errorf("call to %s returned no message", method)
+	}
+	if body[0] != 0 {
+		return nil, fmt.Errorf("call to %s returned a compressed message", method)
+	}
+	length := binary.BigEndian.Uint32(body[1:5])
+	if uint32(len(body)-5) < length {
+		return nil, fmt.Errorf("call to %s returned a truncated message", method)
+	}
+	return body[5 : 5+length], nil
+}
+
+// Returns the error reported in the status of the call. The status is in the trailers, or in the
+// headers of responses without a message.
+func callStatus(resp *http.Response) error {
+	status := resp.Trailer.Get("Grpc-Status")
+	message := resp.Trailer.Get("Grpc-Message")
+	if status == "" {
+		status = resp.Header.Get("Grpc-Status")
+		message = resp.Header.Get("Grpc-Message")
+	}
+	if status == "" {
+		return fmt.Errorf("no status returned")
+	}
+	code, err := strconv.Atoi(status)
+	if err != nil {
+		return fmt.Errorf("invalid status %q", status)
+	}
+	if code == statusOk {
+		return nil
+	}
+	// The message is percent-encoded.
+	if unescaped, err := url.PathUnescape(message); err == nil {
+		message = unescaped
+	}
+	return fmt.Errorf("status %d: %s", code, message)
+}
@@ -0,0 +1,121 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	inner := &Encoder{}
+	inner.String(1, "abc")
+	e := &Encoder{}
+	e.Uint(1, 300)
+	e.String(2, "name")
+	e.Message(3, inner)
+	e.Message(3, inner)
+	e.MapEntry(4, "key", "value")
+
+	m, err := Decode(e.Encoded())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Uint(1) != 300 || m.String(2) != "name" || m.String(9) != "" {
+		t.Errorf("unexpected scalar fields in %+v", m)
+	}
+	messages, err := m.Messages(3)
+	if err != nil || len(messages) != 2 || messages[1].String(1) != "abc" {
+		t.Errorf("unexpected embedded messages %+v: %v", messages, err)
+	}
+	labels, err := m.Map(4)
+	if err != nil || len(labels) != 1 || labels["key"] != "value" {
+		t.Errorf("unexpected map %v: %v", labels, err)
+	}
+
+	if _, err := Decode([]byte{0x12, 0x05, 'a'}); err == nil {
+		t.Errorf("expected an error for a truncated field")
+	}
+}
+
+// Serves a method echoing the name field of the request, and a failing method.
+func serve(t *testing.T, socket string) *http.Server {
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/test.Service/Echo", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		request, err := Decode(body[5:])
+		if err != nil {
+			t.Errorf("malformed request: %v", err)
+		}
+		response := &Encoder{}
+		response.String(1, request.String(1)+" from "+r.Header.Get("test-namespace"))
+		frame := make([]byte, 5)
+		binary.BigEndian.PutUint32(frame[1:], uint32(len(response.Encoded())))
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.Write(append(frame, response.Encoded()...))
+		w.Header().Set("Grpc-Status", "0")
+	})
+	mux.HandleFunc("/test.Service/Fail", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Grpc-Status", "12")
+		w.Header().Set("Grpc-Message", "unknown%20method")
+	})
+	server := &http.Server{Handler: mux, Protocols: new(http.Protocols)}
+	server.Protocols.SetUnencryptedHTTP2(true)
+	go server.Serve(l)
+	return server
+}
+
+func TestInvoke(t *testing.T) {
+	dir, err := ioutil.TempDir("", "grpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "test.sock")
+	server := serve(t, socket)
+	defer server.Close()
+
+	client := NewClient(socket, 5*time.Second)
+	request := &Encoder{}
+	request.String(1, "hello")
+	response, err := client.Invoke("/test.Service/Echo", request.Encoded(), map[string]string{"test-namespace": "tests"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := Decode(response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.String(1) != "hello from tests" {
+		t.Errorf("unexpected response %q", m.String(1))
+	}
+
+	_, err = client.Invoke("/test.Service/Fail", nil, nil)
+	if err == nil || err.Error() != "call to /test.Service/Fail failed: status 12: unknown method" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,188 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpc
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Protobuf wire types.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// Encodes a protobuf message field by field.
+type Encoder struct {
+	buf []byte
+}
+
+func (self *Encoder) tag(field int, wireType int) {
+	self.buf = binary.AppendUvarint(self.buf, uint64(field)<<3|uint64(wireType))
+}
+
+func (self *Encoder) Uint(field int, v uint64) {
+	self.tag(field, wireVarint)
+	self.buf = binary.AppendUvarint(self.buf, v)
+}
+
+func (self *Encoder) String(field int, s string) {
+	self.Bytes(field, []byte(s))
+}
+
+func (self *Encoder) Bytes(field int, b []byte) {
+	self.tag(field, wireBytes)
+	self.buf = binary.AppendUvarint(self.buf, uint64(len(b)))
+	self.buf = append(self.buf, b...)
+}
+
+// Encodes an embedded message.
+func (self *Encoder) Message(field int, m *Encoder) {
+	self.Bytes(field, m.Encoded())
+}
+
+// Encodes an entry of a map<string, string> field.
+func (self *Encoder) MapEntry(field int, key, value string) {
+	entry := &Encoder{}
+	entry.String(1, key)
+	entry.String(2, value)
+	self.Message(field, entry)
+}
+
+func (self *Encoder) Encoded() []byte {
+	return self.buf
+}
+
+type field struct {
+	number int
+	// Value of varint and fixed fields.
+	value uint64
+	// Value of length-delimited fields: strings, bytes, embedded and packed messages.
+	bytes []byte
+}
+
+// A decoded protobuf message. Fields are looked up by number, unknown fields are ignored.
+type Message struct {
+	fields []field
+}
+
+func Decode(data []byte) (*Message, error) {
+	m := &Message{}
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, fmt.Errorf("malformed field tag")
+		}
+		data = data[n:]
+		f := field{number: int(tag >> 3)}
+		switch tag & 7 {
+		case wireVarint:
+			f.value, n = binary.Uvarint(data)
+			if n <= 0 {
+				return nil, fmt.Errorf("malformed varint in field %d", f.number)
+			}
+			data = data[n:]
+		case wireFixed64:
+			if len(data) < 8 {
+				return nil, fmt.Errorf("truncated field %d", f.number)
+			}
+			f.value = binary.LittleEndian.Uint64(data)
+			data = data[8:]
+		case wireFixed32:
+			if len(data) < 4 {
+				return nil, fmt.Errorf("truncated field %d", f.number)
+			}
+			f.value = uint64(binary.LittleEndian.Uint32(data))
+			data = data[4:]
+		case wireBytes:
+			length, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < length {
+				return nil, fmt.Errorf("truncated field %d", f.number)
+			}
+			f.bytes = data[n : n+int(length)]
+			data = data[n+int(length):]
+		default:
+			return nil, fmt.Errorf("unsupported wire type %d in field %d", tag&7, f.number)
+		}
+		m.fields = append(m.fields, f)
+	}
+	return m, nil
+}
+
+// Returns the last occurrence of the field, nil if it is not set.
+func (self *Message) last(number int) *field {
+	for i := len(self.fields) - 1; i >= 0; i-- {
+		if self.fields[i].number == number {
+			return &self.fields[i]
+		}
+	}
+	return nil
+}
+
+// Returns the value of a varint field (e.g.: integers, enums), 0 if it is not set.
+func (self *Message) Uint(number int) uint64 {
+	if f := self.last(number); f != nil {
+		return f.value
+	}
+	return 0
+}
+
+// Returns the value of a string or bytes field, empty if it is not set.
+func (self *Message) String(number int) string {
+	if f := self.last(number); f != nil {
+		return string(f.bytes)
+	}
+	return ""
+}
+
+// Returns the embedded message, an empty message if it is not set.
+func (self *Message) Message(number int) (*Message, error) {
+	if f := self.last(number); f != nil {
+		return Decode(f.bytes)
+	}
+	return &Message{}, nil
+}
+
+// Returns the messages of a repeated field.
+func (self *Message) Messages(number int) ([]*Message, error) {
+	var ret []*Message
+	for _, f := range self.fields {
+		if f.number != number {
+			continue
+		}
+		m, err := Decode(f.bytes)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, m)
+	}
+	return ret, nil
+}
+
+// Returns the entries of a map<string, string> field.
+func (self *Message) Map(number int) (map[string]string, error) {
+	entries, err := self.Messages(number)
+	if err != nil {
+		return nil, err
+	}
+	ret := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		ret[entry.String(1)] = entry.String(2)
+	}
+	return ret, nil
+}
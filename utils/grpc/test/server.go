@@ -0,0 +1,57 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Fake gRPC server for the tests of the runtimes talking to their daemon with utils/grpc.
+package test
+
+import (
+	"encoding/binary"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/google/cadvisor/utils/grpc"
+)
+
+// Returns the response message of a gRPC method for a request.
+type Method func(r *http.Request) *grpc.Encoder
+
+// Serves the methods, keyed by their path, over HTTP/2 on the unix socket.
+func Serve(t *testing.T, socket string, methods map[string]Method) *http.Server {
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	for path, method := range methods {
+		method := method
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			writeResponse(w, method(r))
+		})
+	}
+	server := &http.Server{Handler: mux, Protocols: new(http.Protocols)}
+	server.Protocols.SetUnencryptedHTTP2(true)
+	go server.Serve(l)
+	return server
+}
+
+// Writes the response message in a gRPC frame followed by an OK status.
+func writeResponse(w http.ResponseWriter, response *grpc.Encoder) {
+	frame := make([]byte, 5)
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(response.Encoded())))
+	w.Header().Set("Content-Type", "application/grpc")
+	w.Header().Set("Trailer", "Grpc-Status")
+	w.Write(append(frame, response.Encoded()...))
+	w.Header().Set("Grpc-Status", "0")
+}
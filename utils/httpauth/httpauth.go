@@ -0,0 +1,227 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package httpauth password-protects the web UI and API of cAdvisor with HTTP basic or digest
+// authentication, and authorizes the bearer tokens of clients by scope.
+package httpauth
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	auth "github.com/abbot/go-http-auth"
+	"github.com/golang/glog"
+)
+
+// Checks the credentials of requests.
+type authenticator interface {
+	// Returns the name of the user authenticated by the request, empty if it is not. Headers
+	// of the response may be set.
+	check(w http.ResponseWriter, r *http.Request) string
+
+	// Answers a request that is not authenticated, asking for credentials.
+	require(w http.ResponseWriter, r *http.Request)
+}
+
+type basicAuthenticator struct {
+	*auth.BasicAuth
+}
+
+func (self basicAuthenticator) check(w http.ResponseWriter, r *http.Request) string {
+	return self.CheckAuth(r)
+}
+
+func (self basicAuthenticator) require(w http.ResponseWriter, r *http.Request) {
+	self.RequireAuth(w, r)
+}
+
+type digestAuthenticator struct {
+	*auth.DigestAuth
+}
+
+func (self digestAuthenticator) check(w http.ResponseWriter, r *http.Request) string {
+	username, info := self.CheckAuth(r)
+	if username != "" && info != nil {
+		w.Header().Set("Authentication-Info", *info)
+	}
+	return username
+}
+
+func (self digestAuthenticator) require(w http.ResponseWriter, r *http.Request) {
+	self.RequireAuth(w, r)
+}
+
+// Configures the authentication of the requests, see New.
+type Config struct {
+	// HTTP basic auth file (htpasswd format) of the users and its realm.
+	AuthFile  string
+	AuthRealm string
+
+	// HTTP digest auth file (htdigest format) of the users and its realm, ignored with AuthFile.
+	DigestFile  string
+	DigestRealm string
+
+	// File of the bearer tokens of clients and their scopes, see tokenFile.
+	TokenFile string
+
+	// Returns the scope a request needs with a bearer token. Requests need ScopeStats if nil.
+	Scope func(r *http.Request) Scope
+
+	// Paths served without authentication, e.g. "/healthz".
+	Public []string
+}
+
+// A handler serving the authenticated requests.
+type handler struct {
+	handler http.Handler
+	// Authenticates the users, nil if there are none.
+	authenticator authenticator
+	// Authorizes the bearer tokens, nil if there are none.
+	tokens *tokenFile
+	scope  func(r *http.Request) Scope
+	realm  string
+	// Paths served without authentication.
+	public map[string]bool
+}
+
+func (self *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if self.public[r.URL.Path] {
+		self.handler.ServeHTTP(w, r)
+		return
+	}
+	if self.tokens != nil {
+		if token, ok := bearerToken(r); ok {
+			self.serveToken(w, r, token)
+			return
+		}
+	}
+	if self.authenticator == nil {
+		self.requireToken(w, "")
+		return
+	}
+	username := self.authenticator.check(w, r)
+	if username == "" {
+		self.authenticator.require(w, r)
+		return
+	}
+	glog.V(4).Infof("Authenticated %q for %s %s", username, r.Method, r.URL.Path)
+	self.handler.ServeHTTP(w, r)
+}
+
+// Serves the request if the token has the scope it needs.
+func (self *handler) serveToken(w http.ResponseWriter, r *http.Request, token string) {
+	scopes := self.tokens.scopes(token)
+	if scopes == nil {
+		self.requireToken(w, `error="invalid_token"`)
+		return
+	}
+	needed := ScopeStats
+	if self.scope != nil {
+		needed = self.scope(r)
+	}
+	if !scopes.allow(needed) {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm=%q, error="insufficient_scope", scope=%q`, self.realm, needed))
+		http.Error(w, fmt.Sprintf("token lacks the %q scope", needed), http.StatusForbidden)
+		return
+	}
+	glog.V(4).Infof("Authorized token with scopes %v for %s %s", scopes, r.Method, r.URL.Path)
+	self.handler.ServeHTTP(w, r)
+}
+
+// Answers a request without a valid token, asking for one.
+func (self *handler) requireToken(w http.ResponseWriter, params string) {
+	challenge := fmt.Sprintf("Bearer realm=%q", self.realm)
+	if params != "" {
+		challenge += ", " + params
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	http.Error(w, "401 Unauthorized", http.StatusUnauthorized)
+}
+
+// Returns the bearer token of the Authorization header of the request, if any.
+func bearerToken(r *http.Request) (string, bool) {
+	const prefix = "Bearer "
+	header := r.Header.Get("Authorization")
+	if len(header) <= len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return "", false
+	}
+	return strings.TrimSpace(header[len(prefix):]), true
+}
+
+// Returns a handler authenticating the requests to h per the config: users need HTTP basic
+// authentication with the AuthFile, or else digest authentication with the DigestFile, and
+// clients with a bearer token of the TokenFile are authorized by its scopes. Users are allowed
+// everything. The public paths are served without authentication. h is returned as-is if no
+// file is given.
+func New(h http.Handler, config Config) (http.Handler, error) {
+	ret := &handler{
+		handler: h,
+		scope:   config.Scope,
+		public:  make(map[string]bool, len(config.Public)),
+	}
+	switch {
+	case config.AuthFile != "":
+		secrets, err := fileProvider(config.AuthFile, auth.HtpasswdFileProvider, config.AuthRealm)
+		if err != nil {
+			return nil, err
+		}
+		glog.Infof("Using auth file %s", config.AuthFile)
+		ret.authenticator = basicAuthenticator{auth.NewBasicAuthenticator(config.AuthRealm, secrets)}
+		ret.realm = config.AuthRealm
+	case config.DigestFile != "":
+		secrets, err := fileProvider(config.DigestFile, auth.HtdigestFileProvider, config.DigestRealm)
+		if err != nil {
+			return nil, err
+		}
+		glog.Infof("Using digest file %s", config.DigestFile)
+		ret.authenticator = digestAuthenticator{auth.NewDigestAuthenticator(config.DigestRealm, secrets)}
+		ret.realm = config.DigestRealm
+	}
+	if config.TokenFile != "" {
+		tokens, err := newTokenFile(config.TokenFile)
+		if err != nil {
+			return nil, err
+		}
+		glog.Infof("Using token file %s", config.TokenFile)
+		ret.tokens = tokens
+		if ret.realm == "" {
+			ret.realm = config.AuthRealm
+		}
+	}
+	if ret.authenticator == nil && ret.tokens == nil {
+		return h, nil
+	}
+	for _, path := range config.Public {
+		ret.public[path] = true
+	}
+	return ret, nil
+}
+
+// Returns the provider of the secrets of the users of the file, checking it can be read: the
+// providers panic when they fail to read it.
+func fileProvider(name string, provider func(string) auth.SecretProvider, realm string) (secrets auth.SecretProvider, err error) {
+	if _, err := os.Stat(name); err != nil {
+		return nil, err
+	}
+	secrets = provider(name)
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("failed to read %q: %v", name, r)
+		}
+	}()
+	secrets("", realm)
+	return secrets, nil
+}
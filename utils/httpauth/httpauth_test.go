@@ -0,0 +1,253 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpauth
+
+import (
+	"crypto/md5"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+func md5Hex(s string) string {
+	return fmt.Sprintf("%x", md5.Sum([]byte(s)))
+}
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	name = path.Join(dir, name)
+	if err := ioutil.WriteFile(name, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprint(w, "ok")
+})
+
+func TestBasicAuth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "httpauth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// The password of test is "hello".
+	authFile := writeFile(t, dir, "htpasswd", "test:{SHA}qvTGHdzF6KLavt4PO0gs2a6pQ00=\n")
+
+	handler, err := New(okHandler, Config{AuthFile: authFile, AuthRealm: "cadvisor", Public: []string{"/healthz"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path     string
+		user     string
+		password string
+		code     int
+	}{
+		{"/api/v1.3/machine", "", "", http.StatusUnauthorized},
+		{"/api/v1.3/machine", "test", "wrong", http.StatusUnauthorized},
+		{"/api/v1.3/machine", "test", "hello", http.StatusOK},
+		{"/containers/", "test", "hello", http.StatusOK},
+		{"/healthz", "", "", http.StatusOK},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest("GET", test.path, nil)
+		if test.user != "" {
+			r.SetBasicAuth(test.user, test.password)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != test.code {
+			t.Errorf("expected %d for %s as %q, got %d", test.code, test.path, test.user, w.Code)
+		}
+		if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") != `Basic realm="cadvisor"` {
+			t.Errorf("unexpected challenge %q", w.Header().Get("WWW-Authenticate"))
+		}
+	}
+}
+
+func TestDigestAuth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "httpauth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ha1 := md5Hex("test:cadvisor:hello")
+	digestFile := writeFile(t, dir, "htdigest", "test:cadvisor:"+ha1+"\n")
+
+	handler, err := New(okHandler, Config{DigestFile: digestFile, DigestRealm: "cadvisor"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Get a nonce.
+	r, _ := http.NewRequest("GET", "/api/v1.3/machine", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected a challenge, got %d", w.Code)
+	}
+	params := map[string]string{}
+	challenge := strings.TrimPrefix(w.Header().Get("WWW-Authenticate"), "Digest ")
+	for _, kv := range strings.Split(challenge, ",") {
+		parts := strings.SplitN(strings.TrimSpace(kv), "=", 2)
+		params[parts[0]] = strings.Trim(parts[1], `"`)
+	}
+
+	authorize := func(ha1 string) int {
+		uri := "/api/v1.3/machine"
+		response := md5Hex(strings.Join([]string{ha1, params["nonce"], "00000001", "0a4f113b", "auth", md5Hex("GET:" + uri)}, ":"))
+		r, _ := http.NewRequest("GET", uri, nil)
+		r.Header.Set("Authorization", fmt.Sprintf(`Digest username="test", realm="cadvisor", nonce="%s", uri="%s", algorithm="MD5", qop="auth", nc="00000001", cnonce="0a4f113b", response="%s", opaque="%s"`, params["nonce"], uri, response, params["opaque"]))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+	if code := authorize(md5Hex("test:cadvisor:wrong")); code != http.StatusUnauthorized {
+		t.Errorf("expected a wrong password to be rejected, got %d", code)
+	}
+	if code := authorize(ha1); code != http.StatusOK {
+		t.Errorf("expected the request to be authenticated, got %d", code)
+	}
+}
+
+func TestNew(t *testing.T) {
+	// Without files, requests are not authenticated.
+	handler, err := New(okHandler, Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/api/v1.3/machine", nil)
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected no authentication, got %d", w.Code)
+	}
+
+	if _, err := New(okHandler, Config{AuthFile: "/missing/htpasswd", AuthRealm: "localhost"}); err == nil {
+		t.Errorf("expected an error for a missing auth file")
+	}
+	dir, err := ioutil.TempDir("", "httpauth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	digestFile := writeFile(t, dir, "htdigest", "test:\"cadvisor\n")
+	if _, err := New(okHandler, Config{DigestFile: digestFile, DigestRealm: "cadvisor"}); err == nil {
+		t.Errorf("expected an error for an invalid digest file")
+	}
+	tokenFile := writeFile(t, dir, "tokens", "secret root\n")
+	if _, err := New(okHandler, Config{TokenFile: tokenFile}); err == nil {
+		t.Errorf("expected an error for an unknown scope")
+	}
+}
+
+func TestTokens(t *testing.T) {
+	dir, err := ioutil.TempDir("", "httpauth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// The password of test is "hello".
+	authFile := writeFile(t, dir, "htpasswd", "test:{SHA}qvTGHdzF6KLavt4PO0gs2a6pQ00=\n")
+	tokenFile := writeFile(t, dir, "tokens", "# Tokens.\nscraper stats\nwatcher stats,events\n\noperator admin\n")
+
+	scope := func(r *http.Request) Scope {
+		switch {
+		case r.Method == "POST":
+			return ScopeAdmin
+		case strings.HasPrefix(r.URL.Path, "/api/v1.3/events"):
+			return ScopeEvents
+		}
+		return ScopeStats
+	}
+	handler, err := New(okHandler, Config{AuthFile: authFile, AuthRealm: "cadvisor", TokenFile: tokenFile, Scope: scope})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		method string
+		path   string
+		token  string
+		code   int
+	}{
+		{"GET", "/api/v1.3/machine", "scraper", http.StatusOK},
+		{"GET", "/api/v1.3/events/", "scraper", http.StatusForbidden},
+		{"POST", "/api/v2.0/config", "scraper", http.StatusForbidden},
+		{"GET", "/api/v1.3/events/", "watcher", http.StatusOK},
+		{"POST", "/api/v2.0/config", "watcher", http.StatusForbidden},
+		{"GET", "/api/v1.3/events/", "operator", http.StatusOK},
+		{"POST", "/api/v2.0/config", "operator", http.StatusOK},
+		{"GET", "/api/v1.3/machine", "wrong", http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		r, _ := http.NewRequest(test.method, test.path, nil)
+		r.Header.Set("Authorization", "Bearer "+test.token)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != test.code {
+			t.Errorf("expected %d for %s %s with %q, got %d", test.code, test.method, test.path, test.token, w.Code)
+		}
+	}
+
+	// Users are still allowed everything.
+	r, _ := http.NewRequest("POST", "/api/v2.0/config", nil)
+	r.SetBasicAuth("test", "hello")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected the user to be allowed, got %d", w.Code)
+	}
+
+	// Changes to the file are picked up.
+	writeFile(t, dir, "tokens", "scraper events\n")
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(tokenFile, later, later); err != nil {
+		t.Fatal(err)
+	}
+	r, _ = http.NewRequest("GET", "/api/v1.3/events/", nil)
+	r.Header.Set("Authorization", "Bearer scraper")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected the new scope of the token to be allowed, got %d", w.Code)
+	}
+}
+
+func TestTokensOnly(t *testing.T) {
+	dir, err := ioutil.TempDir("", "httpauth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tokenFile := writeFile(t, dir, "tokens", "scraper stats\n")
+	handler, err := New(okHandler, Config{AuthRealm: "cadvisor", TokenFile: tokenFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, _ := http.NewRequest("GET", "/api/v1.3/machine", nil)
+	r.SetBasicAuth("test", "hello")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") != `Bearer realm="cadvisor"` {
+		t.Errorf("expected a bearer challenge, got %d %q", w.Code, w.Header().Get("WWW-Authenticate"))
+	}
+}
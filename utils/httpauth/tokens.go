@@ -0,0 +1,129 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpauth
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// What a bearer token allows.
+type Scope string
+
+const (
+	// Reading the stats, specs and other state of the machine and containers.
+	ScopeStats Scope = "stats"
+	// Reading and watching the events of containers.
+	ScopeEvents Scope = "events"
+	// Everything, including changing the configuration of cAdvisor.
+	ScopeAdmin Scope = "admin"
+)
+
+type scopes []Scope
+
+// Returns whether the scopes allow requests needing the scope.
+func (self scopes) allow(needed Scope) bool {
+	for _, scope := range self {
+		if scope == needed || scope == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// The bearer tokens of a file and their scopes, reloaded when the file changes. Each line of
+// the file has a token and its comma-separated scopes, e.g. "2d2d1a6c stats,events". Empty
+// lines and lines starting with # are ignored.
+type tokenFile struct {
+	name    string
+	lock    sync.Mutex
+	modTime time.Time
+	// The scopes of the tokens by the hash of the token, not to leak the tokens through the
+	// timing of lookups.
+	tokens map[[sha256.Size]byte]scopes
+}
+
+func newTokenFile(name string) (*tokenFile, error) {
+	self := &tokenFile{name: name}
+	if err := self.reload(); err != nil {
+		return nil, err
+	}
+	return self, nil
+}
+
+// Returns the scopes of the token, nil if it is unknown.
+func (self *tokenFile) scopes(token string) scopes {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if err := self.reload(); err != nil {
+		glog.Errorf("Failed to reload token file %q, keeping the previous tokens: %v", self.name, err)
+	}
+	return self.tokens[sha256.Sum256([]byte(token))]
+}
+
+// Reads the file if it changed since it was last read.
+func (self *tokenFile) reload() error {
+	info, err := os.Stat(self.name)
+	if err != nil {
+		return err
+	}
+	if self.tokens != nil && info.ModTime().Equal(self.modTime) {
+		return nil
+	}
+	f, err := os.Open(self.name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	tokens, err := parseTokens(f.Name(), bufio.NewScanner(f))
+	if err != nil {
+		return err
+	}
+	self.tokens = tokens
+	self.modTime = info.ModTime()
+	return nil
+}
+
+func parseTokens(name string, scanner *bufio.Scanner) (map[[sha256.Size]byte]scopes, error) {
+	tokens := map[[sha256.Size]byte]scopes{}
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected a token and its scopes", name, n)
+		}
+		var tokenScopes scopes
+		for _, scope := range strings.Split(fields[1], ",") {
+			switch Scope(scope) {
+			case ScopeStats, ScopeEvents, ScopeAdmin:
+				tokenScopes = append(tokenScopes, Scope(scope))
+			default:
+				return nil, fmt.Errorf("%s:%d: unknown scope %q", name, n, scope)
+			}
+		}
+		tokens[sha256.Sum256([]byte(fields[0]))] = tokenScopes
+	}
+	return tokens, scanner.Err()
+}
@@ -0,0 +1,133 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package lsm detects the Linux security modules confining cAdvisor, SELinux and AppArmor, whose
+// policies may deny it access to the files of cgroups and processes.
+package lsm
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+)
+
+// SELinux and AppArmor state of cAdvisor.
+type Status struct {
+	// "enforcing", "permissive", or empty if SELinux is disabled.
+	SELinux string
+	// SELinux context of cAdvisor, e.g. "system_u:system_r:container_t:s0:c1,c2".
+	SELinuxContext string
+
+	// Whether AppArmor is enabled.
+	AppArmor bool
+	// AppArmor profile of cAdvisor and its mode, e.g. "docker-default (enforce)", or
+	// "unconfined".
+	AppArmorProfile string
+}
+
+// SELinux types not confined by the policy.
+var unconfinedTypes = map[string]bool{
+	"unconfined_t":         true,
+	"unconfined_service_t": true,
+	"spc_t":                true,
+	"kernel_t":             true,
+	"initrc_t":             true,
+}
+
+// Returns the SELinux and AppArmor state of cAdvisor.
+func Detect() Status {
+	return detect("/sys", "/proc/self")
+}
+
+func detect(sysRoot, procSelf string) Status {
+	var status Status
+	if enforce, err := readString(path.Join(sysRoot, "fs/selinux/enforce")); err == nil {
+		status.SELinux = "permissive"
+		if enforce == "1" {
+			status.SELinux = "enforcing"
+		}
+		status.SELinuxContext, _ = readString(path.Join(procSelf, "attr/current"))
+	}
+	if enabled, err := readString(path.Join(sysRoot, "module/apparmor/parameters/enabled")); err == nil && enabled == "Y" {
+		status.AppArmor = true
+		profile, err := readString(path.Join(procSelf, "attr/apparmor/current"))
+		if err != nil {
+			// Before Linux 5.8, the attributes are shared by all the modules.
+			profile, _ = readString(path.Join(procSelf, "attr/current"))
+		}
+		status.AppArmorProfile = profile
+	}
+	return status
+}
+
+// Returns whether SELinux confines cAdvisor, i.e. it is enforcing and cAdvisor runs as a confined
+// type.
+func (self Status) SELinuxConfined() bool {
+	if self.SELinux != "enforcing" {
+		return false
+	}
+	// user:role:type:level
+	fields := strings.SplitN(self.SELinuxContext, ":", 4)
+	return len(fields) < 3 || !unconfinedTypes[fields[2]]
+}
+
+// Returns whether AppArmor confines cAdvisor, i.e. it runs with a profile in enforce mode.
+func (self Status) AppArmorConfined() bool {
+	return self.AppArmor && strings.HasSuffix(self.AppArmorProfile, "(enforce)")
+}
+
+func (self Status) Confined() bool {
+	return self.SELinuxConfined() || self.AppArmorConfined()
+}
+
+func (self Status) String() string {
+	var modules []string
+	switch {
+	case self.SELinux == "":
+		modules = append(modules, "SELinux disabled")
+	case self.SELinuxConfined():
+		modules = append(modules, fmt.Sprintf("SELinux %s, confined to %q", self.SELinux, self.SELinuxContext))
+	default:
+		modules = append(modules, fmt.Sprintf("SELinux %s, not confined (%q)", self.SELinux, self.SELinuxContext))
+	}
+	switch {
+	case !self.AppArmor:
+		modules = append(modules, "AppArmor disabled")
+	case self.AppArmorConfined():
+		modules = append(modules, fmt.Sprintf("AppArmor confined to profile %q", self.AppArmorProfile))
+	default:
+		modules = append(modules, fmt.Sprintf("AppArmor not confined (%q)", self.AppArmorProfile))
+	}
+	return strings.Join(modules, ", ")
+}
+
+// Returns how to let cAdvisor access the files its policies deny it.
+func (self Status) Hint() string {
+	switch {
+	case self.SELinuxConfined():
+		return "run cAdvisor with an unconfined SELinux type, e.g. docker run --security-opt label=type:spc_t (or label=disable), or allow the accesses in its policy, see ausearch -m avc -c cadvisor"
+	case self.AppArmorConfined():
+		return "run cAdvisor without an AppArmor profile, e.g. docker run --security-opt apparmor=unconfined, or allow reading the files in its profile, see the apparmor=\"DENIED\" messages of dmesg"
+	}
+	return "check the permissions of the files and the capabilities of cAdvisor, e.g. --keep_capabilities"
+}
+
+func readString(name string) (string, error) {
+	out, err := ioutil.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(strings.TrimSpace(string(out)), "\x00"), nil
+}
@@ -0,0 +1,85 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lsm
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	for name, content := range files {
+		name = path.Join(root, name)
+		if err := os.MkdirAll(path.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		files    map[string]string
+		selinux  string
+		apparmor string
+		confined bool
+	}{
+		{map[string]string{}, "", "", false},
+		{map[string]string{
+			"sys/fs/selinux/enforce": "1",
+			"proc/attr/current":      "system_u:system_r:container_t:s0:c1,c2\x00",
+		}, "enforcing", "", true},
+		{map[string]string{
+			"sys/fs/selinux/enforce": "1",
+			"proc/attr/current":      "system_u:system_r:spc_t:s0",
+		}, "enforcing", "", false},
+		{map[string]string{
+			"sys/fs/selinux/enforce": "0",
+			"proc/attr/current":      "system_u:system_r:container_t:s0",
+		}, "permissive", "", false},
+		{map[string]string{
+			"sys/module/apparmor/parameters/enabled": "Y\n",
+			"proc/attr/apparmor/current":             "docker-default (enforce)\n",
+		}, "", "docker-default (enforce)", true},
+		{map[string]string{
+			"sys/module/apparmor/parameters/enabled": "Y\n",
+			"proc/attr/current":                      "unconfined\n",
+		}, "", "unconfined", false},
+		{map[string]string{
+			"sys/module/apparmor/parameters/enabled": "Y\n",
+			"proc/attr/current":                      "cadvisor (complain)\n",
+		}, "", "cadvisor (complain)", false},
+	}
+	for i, test := range tests {
+		root, err := ioutil.TempDir("", "lsm")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(root)
+		writeFiles(t, root, test.files)
+
+		status := detect(path.Join(root, "sys"), path.Join(root, "proc"))
+		if status.SELinux != test.selinux || status.AppArmorProfile != test.apparmor || status.Confined() != test.confined {
+			t.Errorf("test %d: unexpected status %+v, confined: %v", i, status, status.Confined())
+		}
+		if status.String() == "" || status.Hint() == "" {
+			t.Errorf("test %d: expected a description and a hint", i)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Reads the OOM kills of processes from the kernel log.
+package oomparser
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+const kmsgPath = "/dev/kmsg"
+
+var (
+	// e.g.: stress invoked oom-killer: gfp_mask=0xd0, order=0, oom_score_adj=0
+	invokedRegexp = regexp.MustCompile(`invoked oom-killer:`)
+	// e.g.: memory: usage 1048576kB, limit 1048576kB, failcnt 2151
+	usageRegexp = regexp.MustCompile(`^memory: usage (\d+)kB, limit (\d+)kB`)
+	// Kernels before 4.19, e.g.: Task in /docker/abc killed as a result of limit of /docker
+	taskRegexp = regexp.MustCompile(`^Task in (.*) killed as a result of limit of (.*)$`)
+	// Kernels since 4.19, e.g.: oom-kill:constraint=CONSTRAINT_MEMCG,nodemask=(null),cpuset=abc,
+	// mems_allowed=0,oom_memcg=/docker,task_memcg=/docker/abc,task=stress,pid=1234,uid=0. There is
+	// no oom_memcg when the whole machine ran out of memory.
+	oomKillRegexp = regexp.MustCompile(`^oom-kill:.*?(?:,oom_memcg=([^,]*))?,task_memcg=([^,]*),task=([^,]*),pid=(\d+)`)
+	// e.g.: Killed process 1234 (stress) total-vm:..., or Memory cgroup out of memory: Killed
+	// process 1234 (stress) ...
+	killedRegexp = regexp.MustCompile(`Killed process (\d+) \((.*?)\)`)
+)
+
+// A process killed by the OOM killer.
+type OomInstance struct {
+	// The process killed.
+	Pid         int
+	ProcessName string
+
+	TimeOfDeath time.Time
+
+	// The memory cgroup of the process killed.
+	VictimContainerName string
+
+	// The memory cgroup whose limit was reached, the victim's or one of its parents. "/" when the
+	// whole machine ran out of memory.
+	ContainerName string
+
+	// Memory usage and limit of ContainerName when the process was killed, in bytes. 0 if the
+	// whole machine ran out of memory.
+	MemoryUsage uint64
+	MemoryLimit uint64
+}
+
+// Reads the OOM kills from the kernel log. A kill spans several messages, from the one of the
+// process invoking the OOM killer to the one of the process killed.
+type OomParser struct {
+	reader   *bufio.Reader
+	closer   io.Closer
+	bootTime time.Time
+
+	// The kill being parsed, nil before the OOM killer is invoked.
+	current *OomInstance
+}
+
+// Returns a parser of the OOM kills logged from now on.
+func New() (*OomParser, error) {
+	file, err := os.Open(kmsgPath)
+	if err != nil {
+		return nil, err
+	}
+	// Skip the messages already logged.
+	if _, err := file.Seek(0, os.SEEK_END); err != nil {
+		file.Close()
+		return nil, err
+	}
+	bootTime, err := readBootTime()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &OomParser{
+		reader:   bufio.NewReader(file),
+		closer:   file,
+		bootTime: bootTime,
+	}, nil
+}
+
+// Returns the time the machine booted, from /proc/uptime.
+func readBootTime() (time.Time, error) {
+	out, err := ioutil.ReadFile("/proc/uptime")
+	if err != nil {
+		return time.Time{}, err
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) == 0 {
+		return time.Time{}, fmt.Errorf("unexpected /proc/uptime %q", string(out))
+	}
+	uptime, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Now().Add(-time.Duration(uptime * float64(time.Second))), nil
+}
+
+// Sends the OOM kills to the channel until the parser is closed.
+func (self *OomParser) StreamOoms(outStream chan<- *OomInstance) {
+	for {
+		line, err := self.reader.ReadString('\n')
+		if err != nil {
+			// Messages overwritten before being read are reported as EPIPE, reading continues
+			// with the next message.
+			if pathErr, ok := err.(*os.PathError); ok && pathErr.Err == syscall.EPIPE {
+				continue
+			}
+			glog.V(1).Infof("Stopped reading the kernel log: %v", err)
+			return
+		}
+		if oom := self.parseRecord(strings.TrimSuffix(line, "\n")); oom != nil {
+			outStream <- oom
+		}
+	}
+}
+
+func (self *OomParser) Close() error {
+	return self.closer.Close()
+}
+
+// Parses a record of /dev/kmsg: <priority>,<sequence>,<microseconds since boot>,<flags>;<message>.
+// Returns the OOM kill the record completes, if any.
+func (self *OomParser) parseRecord(record string) *OomInstance {
+	// Continuation lines holding the properties of the previous record start with a space.
+	if strings.HasPrefix(record, " ") {
+		return nil
+	}
+	parts := strings.SplitN(record, ";", 2)
+	if len(parts) != 2 {
+		return nil
+	}
+	timestamp := time.Now()
+	header := strings.Split(parts[0], ",")
+	if len(header) >= 3 {
+		if us, err := strconv.ParseInt(header[2], 10, 64); err == nil {
+			timestamp = self.bootTime.Add(time.Duration(us) * time.Microsecond)
+		}
+	}
+	return self.parseLine(parts[1], timestamp)
+}
+
+// Parses a message of the kernel log. Returns the OOM kill the message completes, if any.
+func (self *OomParser) parseLine(line string, timestamp time.Time) *OomInstance {
+	if invokedRegexp.MatchString(line) {
+		self.current = &OomInstance{
+			TimeOfDeath:   timestamp,
+			ContainerName: "/",
+		}
+		return nil
+	}
+	if self.current == nil {
+		return nil
+	}
+	if m := usageRegexp.FindStringSubmatch(line); m != nil {
+		usage, _ := strconv.ParseUint(m[1], 10, 64)
+		limit, _ := strconv.ParseUint(m[2], 10, 64)
+		self.current.MemoryUsage = usage * 1024
+		self.current.MemoryLimit = limit * 1024
+		return nil
+	}
+	if m := taskRegexp.FindStringSubmatch(line); m != nil {
+		self.current.VictimContainerName = m[1]
+		self.current.ContainerName = m[2]
+		return nil
+	}
+	if m := oomKillRegexp.FindStringSubmatch(line); m != nil {
+		if m[1] != "" {
+			self.current.ContainerName = m[1]
+		}
+		self.current.VictimContainerName = m[2]
+		self.current.ProcessName = m[3]
+		self.current.Pid, _ = strconv.Atoi(m[4])
+		return nil
+	}
+	if m := killedRegexp.FindStringSubmatch(line); m != nil {
+		oom := self.current
+		self.current = nil
+		oom.Pid, _ = strconv.Atoi(m[1])
+		oom.ProcessName = m[2]
+		if oom.VictimContainerName == "" {
+			oom.VictimContainerName = oom.ContainerName
+		}
+		return oom
+	}
+	return nil
+}
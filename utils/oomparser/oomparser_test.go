@@ -0,0 +1,107 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oomparser
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Parses the messages of the kernel log, returns the OOM kills.
+func parseLines(lines []string, timestamp time.Time) []*OomInstance {
+	parser := &OomParser{}
+	var ooms []*OomInstance
+	for _, line := range lines {
+		if oom := parser.parseLine(line, timestamp); oom != nil {
+			ooms = append(ooms, oom)
+		}
+	}
+	return ooms
+}
+
+func TestParseOldKernel(t *testing.T) {
+	now := time.Now()
+	ooms := parseLines([]string{
+		"ruby invoked oom-killer: gfp_mask=0x201da, order=0, oom_score_adj=0",
+		"ruby cpuset=docker-abc mems_allowed=0",
+		"CPU: 1 PID: 1234 Comm: ruby Not tainted 3.13.0-43-generic #72-Ubuntu",
+		"Task in /docker/abc killed as a result of limit of /docker/abc",
+		"memory: usage 262144kB, limit 262144kB, failcnt 8",
+		"memory+swap: usage 0kB, limit 18014398509481983kB, failcnt 0",
+		"kmem: usage 0kB, limit 18014398509481983kB, failcnt 0",
+		"Memory cgroup out of memory: Kill process 1234 (ruby) score 996 or sacrifice child",
+		"Killed process 1234 (ruby) total-vm:1028316kB, anon-rss:261604kB, file-rss:3432kB",
+	}, now)
+	expected := []*OomInstance{{
+		Pid:                 1234,
+		ProcessName:         "ruby",
+		TimeOfDeath:         now,
+		VictimContainerName: "/docker/abc",
+		ContainerName:       "/docker/abc",
+		MemoryUsage:         262144 * 1024,
+		MemoryLimit:         262144 * 1024,
+	}}
+	if !reflect.DeepEqual(ooms, expected) {
+		t.Errorf("expected %+v, got %+v", expected[0], ooms)
+	}
+}
+
+func TestParseNewKernel(t *testing.T) {
+	now := time.Now()
+	ooms := parseLines([]string{
+		"stress invoked oom-killer: gfp_mask=0x6000c0(GFP_KERNEL), nodemask=(null), order=0, oom_score_adj=0",
+		"memory: usage 102400kB, limit 102400kB, failcnt 52",
+		"swap: usage 0kB, limit 9007199254740988kB, failcnt 0",
+		"oom-kill:constraint=CONSTRAINT_MEMCG,nodemask=(null),cpuset=abc,mems_allowed=0,oom_memcg=/kubepods/pod1,task_memcg=/kubepods/pod1/abc,task=stress,pid=4321,uid=0",
+		"Memory cgroup out of memory: Killed process 4321 (stress) total-vm:110676kB, anon-rss:101900kB, file-rss:4kB, shmem-rss:0kB, UID:0 pgtables:244kB oom_score_adj:0",
+		// Messages outside of OOM kills are ignored.
+		"Killed process 1 (init)",
+	}, now)
+	expected := []*OomInstance{{
+		Pid:                 4321,
+		ProcessName:         "stress",
+		TimeOfDeath:         now,
+		VictimContainerName: "/kubepods/pod1/abc",
+		ContainerName:       "/kubepods/pod1",
+		MemoryUsage:         102400 * 1024,
+		MemoryLimit:         102400 * 1024,
+	}}
+	if !reflect.DeepEqual(ooms, expected) {
+		t.Errorf("expected %+v, got %+v", expected[0], ooms)
+	}
+}
+
+func TestParseMachineOom(t *testing.T) {
+	ooms := parseLines([]string{
+		"java invoked oom-killer: gfp_mask=0x100cca(GFP_HIGHUSER_MOVABLE), order=0, oom_score_adj=0",
+		"oom-kill:constraint=CONSTRAINT_NONE,nodemask=(null),cpuset=/,mems_allowed=0,global_oom,task_memcg=/system.slice/app.service,task=java,pid=99,uid=1000",
+		"Out of memory: Killed process 99 (java) total-vm:4096kB, anon-rss:2048kB",
+	}, time.Now())
+	if len(ooms) != 1 || ooms[0].ContainerName != "/" || ooms[0].VictimContainerName != "/system.slice/app.service" || ooms[0].Pid != 99 || ooms[0].MemoryUsage != 0 {
+		t.Errorf("unexpected OOM kills %+v", ooms)
+	}
+}
+
+func TestParseRecord(t *testing.T) {
+	bootTime := time.Now().Add(-time.Hour)
+	parser := &OomParser{bootTime: bootTime}
+	parser.parseRecord("6,100,2000000,-;ruby invoked oom-killer: gfp_mask=0x201da, order=0, oom_score_adj=0")
+	parser.parseRecord(" SUBSYSTEM=memory")
+	oom := parser.parseRecord("3,101,3000000,-;Killed process 1234 (ruby) total-vm:1028316kB")
+	if oom == nil || oom.Pid != 1234 || !oom.TimeOfDeath.Equal(bootTime.Add(2*time.Second)) {
+		t.Errorf("unexpected OOM kill %+v", oom)
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package privileges drops the privileges of cAdvisor once it did what needs root, re-executing
+// itself as an unprivileged user with only the capabilities its collection needs.
+package privileges
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Set in the environment of the re-executed cAdvisor.
+	droppedEnv = "CADVISOR_DROPPED_PRIVILEGES"
+	// File descriptors of the listeners passed to the re-executed cAdvisor, comma-separated.
+	listenFdsEnv = "CADVISOR_LISTEN_FDS"
+)
+
+// Numbers of the capabilities by name, see capabilities(7).
+var capabilityNumbers = map[string]uintptr{
+	"chown":            0,
+	"dac_override":     1,
+	"dac_read_search":  2,
+	"fowner":           3,
+	"kill":             5,
+	"setgid":           6,
+	"setuid":           7,
+	"net_bind_service": 10,
+	"net_admin":        12,
+	"net_raw":          13,
+	"ipc_lock":         14,
+	"sys_chroot":       18,
+	"sys_ptrace":       19,
+	"sys_admin":        21,
+	"sys_nice":         23,
+	"sys_resource":     24,
+	"syslog":           34,
+	"perfmon":          38,
+	"bpf":              39,
+}
+
+// Returns whether this cAdvisor was re-executed by Drop.
+func Dropped() bool {
+	return os.Getenv(droppedEnv) != ""
+}
+
+// Parses comma-separated capability names, with or without their "cap_" prefix, e.g.
+// "dac_read_search,CAP_SYS_PTRACE".
+func ParseCapabilities(names string) ([]uintptr, error) {
+	var caps []uintptr
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(name)), "cap_")
+		if name == "" {
+			continue
+		}
+		number, ok := capabilityNumbers[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown capability %q", name)
+		}
+		caps = append(caps, number)
+	}
+	return caps, nil
+}
+
+// Credentials of a user.
+type credentials struct {
+	uid    int
+	gid    int
+	groups []int
+}
+
+// Looks up the user of a "user[:group]" spec, by name or id. Its group defaults to the primary
+// group of the user, and it also gets the supplementary groups of the user, e.g. docker.
+func lookupUser(spec string) (*credentials, error) {
+	name := spec
+	group := ""
+	if i := strings.Index(spec, ":"); i >= 0 {
+		name, group = spec[:i], spec[i+1:]
+	}
+	u, err := user.Lookup(name)
+	if err != nil {
+		if _, numeric := err.(user.UnknownUserError); !numeric {
+			return nil, err
+		}
+		if u, err = user.LookupId(name); err != nil {
+			return nil, err
+		}
+	}
+	ret := &credentials{}
+	if ret.uid, err = strconv.Atoi(u.Uid); err != nil {
+		return nil, err
+	}
+	if ret.gid, err = strconv.Atoi(u.Gid); err != nil {
+		return nil, err
+	}
+	if group != "" {
+		g, err := user.LookupGroup(group)
+		if err != nil {
+			if g, err = user.LookupGroupId(group); err != nil {
+				return nil, err
+			}
+		}
+		if ret.gid, err = strconv.Atoi(g.Gid); err != nil {
+			return nil, err
+		}
+	}
+	gids, err := u.GroupIds()
+	if err != nil {
+		return nil, err
+	}
+	for _, gid := range gids {
+		id, err := strconv.Atoi(gid)
+		if err != nil {
+			return nil, err
+		}
+		ret.groups = append(ret.groups, id)
+	}
+	return ret, nil
+}
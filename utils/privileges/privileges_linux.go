@@ -0,0 +1,174 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package privileges
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	prSetKeepCaps     = 8
+	prCapAmbient      = 47
+	prCapAmbientRaise = 2
+
+	linuxCapabilityVersion3 = 0x20080522
+)
+
+type capHeader struct {
+	version uint32
+	pid     int32
+}
+
+type capData struct {
+	effective   uint32
+	permitted   uint32
+	inheritable uint32
+}
+
+// Returns the listeners passed by the cAdvisor which dropped its privileges, none if it did not.
+func Listeners() ([]net.Listener, error) {
+	defer os.Unsetenv(listenFdsEnv)
+	fds := os.Getenv(listenFdsEnv)
+	if fds == "" {
+		return nil, nil
+	}
+	var listeners []net.Listener
+	for _, fdStr := range strings.Split(fds, ",") {
+		fd, err := strconv.Atoi(fdStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %v", listenFdsEnv, fds, err)
+		}
+		syscall.CloseOnExec(fd)
+		file := os.NewFile(uintptr(fd), fmt.Sprintf("LISTEN_FD_%d", fd))
+		listener, err := net.FileListener(file)
+		if err != nil {
+			return nil, fmt.Errorf("fd %d is not a listening socket: %v", fd, err)
+		}
+		// FileListener dups the descriptor.
+		file.Close()
+		listeners = append(listeners, listener)
+	}
+	return listeners, nil
+}
+
+// Returns duplicates of the files of the listeners, inherited through exec. They are closed when
+// garbage collected.
+func inheritableFiles(listeners []net.Listener) ([]*os.File, error) {
+	var files []*os.File
+	for _, l := range listeners {
+		filer, ok := l.(interface {
+			File() (*os.File, error)
+		})
+		if !ok {
+			return nil, fmt.Errorf("cannot pass listener %v", l.Addr())
+		}
+		file, err := filer.File()
+		if err != nil {
+			return nil, err
+		}
+		if _, _, errno := syscall.Syscall(syscall.SYS_FCNTL, file.Fd(), syscall.F_SETFD, 0); errno != 0 {
+			return nil, errno
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// Re-executes cAdvisor as the user of spec ("user[:group]", see lookupUser) with only the
+// capabilities, passing it the listeners. The environment and flags are kept, and so are the
+// limits applied so far, e.g. the cgroup and niceness. Only returns on failure, after which the
+// process must exit as it may have lost some of its privileges.
+func Drop(spec string, caps []uintptr, listeners []net.Listener) error {
+	creds, err := lookupUser(spec)
+	if err != nil {
+		return fmt.Errorf("failed to look up user %q: %v", spec, err)
+	}
+	if creds.uid == 0 {
+		return fmt.Errorf("user %q is root", spec)
+	}
+	files, err := inheritableFiles(listeners)
+	if err != nil {
+		return err
+	}
+	fds := make([]string, 0, len(files))
+	for _, file := range files {
+		fds = append(fds, strconv.Itoa(int(file.Fd())))
+	}
+	env := append(os.Environ(), droppedEnv+"=1", listenFdsEnv+"="+strings.Join(fds, ","))
+
+	// The credentials of the thread calling exec are those of the new process, the other
+	// threads are not changed.
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	if err := setCredentials(creds, caps); err != nil {
+		return err
+	}
+	err = syscall.Exec("/proc/self/exe", os.Args, env)
+	runtime.KeepAlive(files)
+	return fmt.Errorf("failed to re-execute cAdvisor: %v", err)
+}
+
+// Sets the credentials of the calling thread, keeping the capabilities as ambient capabilities
+// to retain them through exec.
+func setCredentials(creds *credentials, caps []uintptr) error {
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetKeepCaps, 1, 0); errno != 0 {
+		return fmt.Errorf("failed to keep the capabilities: %v", errno)
+	}
+	groups := []uint32{uint32(creds.gid)}
+	for _, gid := range creds.groups {
+		if gid != creds.gid {
+			groups = append(groups, uint32(gid))
+		}
+	}
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_SETGROUPS, uintptr(len(groups)), uintptr(unsafe.Pointer(&groups[0])), 0); errno != 0 {
+		return fmt.Errorf("failed to set the groups: %v", errno)
+	}
+	gid := uintptr(creds.gid)
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_SETRESGID, gid, gid, gid); errno != 0 {
+		return fmt.Errorf("failed to set the group: %v", errno)
+	}
+	uid := uintptr(creds.uid)
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_SETRESUID, uid, uid, uid); errno != 0 {
+		return fmt.Errorf("failed to set the user: %v", errno)
+	}
+
+	header := capHeader{version: linuxCapabilityVersion3}
+	var data [2]capData
+	for _, c := range caps {
+		bit := uint32(1) << (c % 32)
+		data[c/32].effective |= bit
+		data[c/32].permitted |= bit
+		data[c/32].inheritable |= bit
+	}
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_CAPSET, uintptr(unsafe.Pointer(&header)), uintptr(unsafe.Pointer(&data[0])), 0); errno != 0 {
+		return fmt.Errorf("failed to set the capabilities: %v", errno)
+	}
+	for _, c := range caps {
+		if _, _, errno := syscall.RawSyscall6(syscall.SYS_PRCTL, prCapAmbient, prCapAmbientRaise, c, 0, 0, 0); errno != 0 {
+			return fmt.Errorf("failed to raise ambient capability %d: %v", c, errno)
+		}
+	}
+	return nil
+}
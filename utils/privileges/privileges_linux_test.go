@@ -0,0 +1,54 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package privileges
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"testing"
+)
+
+func TestListeners(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	files, err := inheritableFiles([]net.Listener{l})
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv(listenFdsEnv, fmt.Sprint(files[0].Fd()))
+	listeners, err := Listeners()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listeners) != 1 || listeners[0].Addr().String() != l.Addr().String() {
+		t.Fatalf("expected the listener on %v, got %v", l.Addr(), listeners)
+	}
+	listeners[0].Close()
+	if os.Getenv(listenFdsEnv) != "" {
+		t.Errorf("expected %s to be unset", listenFdsEnv)
+	}
+
+	// Without inherited listeners.
+	if listeners, err := Listeners(); err != nil || len(listeners) != 0 {
+		t.Errorf("expected no listeners, got %v %v", listeners, err)
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package privileges
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCapabilities(t *testing.T) {
+	caps, err := ParseCapabilities("dac_read_search, CAP_SYS_PTRACE,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(caps, []uintptr{2, 19}) {
+		t.Errorf("unexpected capabilities %v", caps)
+	}
+	if caps, err := ParseCapabilities(""); err != nil || len(caps) != 0 {
+		t.Errorf("expected no capabilities, got %v %v", caps, err)
+	}
+	if _, err := ParseCapabilities("sys_everything"); err == nil {
+		t.Errorf("expected an error for an unknown capability")
+	}
+}
+
+func TestLookupUser(t *testing.T) {
+	for _, spec := range []string{"root", "0", "root:0", "0:root"} {
+		creds, err := lookupUser(spec)
+		if err != nil {
+			t.Fatalf("failed to look up %q: %v", spec, err)
+		}
+		if creds.uid != 0 || creds.gid != 0 {
+			t.Errorf("unexpected credentials %+v for %q", creds, spec)
+		}
+	}
+	if _, err := lookupUser("no-such-user-cadvisor"); err == nil {
+		t.Errorf("expected an error for an unknown user")
+	}
+	if _, err := lookupUser("root:no-such-group-cadvisor"); err == nil {
+		t.Errorf("expected an error for an unknown group")
+	}
+}
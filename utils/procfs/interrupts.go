@@ -0,0 +1,90 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package procfs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Interrupts handled by the machine since it booted.
+type Interrupts struct {
+	// Hardware interrupts, from the intr line of /proc/stat.
+	Hardware uint64
+	// Context switches, from the ctxt line of /proc/stat.
+	ContextSwitches uint64
+	// Softirqs by type summed over the CPUs, from /proc/softirqs.
+	Softirqs map[string]uint64
+}
+
+// Reads the interrupts, softirqs and context switches of the machine.
+func ReadInterrupts() (*Interrupts, error) {
+	out, err := readFile("/proc/stat")
+	if err != nil {
+		return nil, err
+	}
+	ret := &Interrupts{}
+	for _, line := range strings.Split(string(out), "\n") {
+		// e.g.: intr 9120445 36 9 0 ..., the total followed by the count of each interrupt.
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "intr":
+			ret.Hardware, err = parseUint(fields, 1, "/proc/stat")
+		case "ctxt":
+			ret.ContextSwitches, err = parseUint(fields, 1, "/proc/stat")
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	out, err = readFile("/proc/softirqs")
+	if err != nil {
+		return nil, err
+	}
+	ret.Softirqs, err = parseSoftirqs(string(out))
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// Parses /proc/softirqs, a header of CPUs followed by a line per type with its count on each CPU,
+// e.g.: "NET_RX:      35422      12031".
+func parseSoftirqs(content string) (map[string]uint64, error) {
+	lines := strings.Split(content, "\n")
+	softirqs := make(map[string]uint64, len(lines))
+	for _, line := range lines[1:] {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		name := strings.TrimSuffix(fields[0], ":")
+		var total uint64
+		for _, field := range fields[1:] {
+			count, err := strconv.ParseUint(field, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse the %s softirqs: %v", name, err)
+			}
+			total += count
+		}
+		softirqs[name] = total
+	}
+	return softirqs, nil
+}
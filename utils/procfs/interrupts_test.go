@@ -0,0 +1,65 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package procfs
+
+import (
+	"reflect"
+	"testing"
+
+	"code.google.com/p/gomock/gomock"
+
+	"github.com/google/cadvisor/utils/fs"
+	"github.com/google/cadvisor/utils/fs/mockfs"
+)
+
+func TestReadInterrupts(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mfs := mockfs.NewMockFileSystem(mockCtrl)
+	mockfs.AddTextFile(mfs, "/proc/stat", `cpu  100 10 50 1000 20 5 5 0 0 0
+cpu0 100 10 50 1000 20 5 5 0 0 0
+intr 9120445 36 9 0 0
+ctxt 23004117
+btime 1419254880
+processes 52334
+softirq 4017352 0 1421393 1016 175426 64283 0 6427 1117383 2158 1229266
+`)
+	mockfs.AddTextFile(mfs, "/proc/softirqs", `                    CPU0       CPU1
+          HI:          1          0
+       TIMER:     710301     711092
+      NET_TX:        508        508
+      NET_RX:     100422      75004
+`)
+	fs.ChangeFileSystem(mfs)
+
+	interrupts, err := ReadInterrupts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &Interrupts{
+		Hardware:        9120445,
+		ContextSwitches: 23004117,
+		Softirqs: map[string]uint64{
+			"HI":     1,
+			"TIMER":  1421393,
+			"NET_TX": 1016,
+			"NET_RX": 175426,
+		},
+	}
+	if !reflect.DeepEqual(interrupts, expected) {
+		t.Errorf("expected %+v, got %+v", expected, interrupts)
+	}
+}
@@ -0,0 +1,219 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package procfs
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/cadvisor/utils/fs"
+)
+
+// Status of a process as reported in /proc/<pid>/stat.
+type ProcessStat struct {
+	Pid       int
+	ParentPid int
+
+	// State of the process (e.g.: R, S, D, Z).
+	State string
+
+	// Name of the executable.
+	Command string
+
+	// Time spent in user and kernel mode (Unit: jiffies)
+	UserTime   uint64
+	SystemTime uint64
+
+	// Time the process started after system boot (Unit: jiffies)
+	StartTime uint64
+
+	// Virtual memory size (Unit: bytes)
+	VirtualSize uint64
+
+	// Resident set size (Unit: pages)
+	Rss uint64
+}
+
+func readFile(path string) ([]byte, error) {
+	f, err := fs.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ioutil.ReadAll(f)
+}
+
+func parseUint(fields []string, index int, path string) (uint64, error) {
+	val, err := strconv.ParseUint(fields[index], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse field %d of %q: %v", index, path, err)
+	}
+	return val, nil
+}
+
+// Reads /proc/<pid>/stat for the specified process.
+func ReadProcessStat(pid int) (*ProcessStat, error) {
+	path := fmt.Sprintf("/proc/%d/stat", pid)
+	out, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// The command is surrounded by parenthesis and may itself contain spaces and parenthesis.
+	line := string(out)
+	start := strings.Index(line, "(")
+	end := strings.LastIndex(line, ")")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("malformed %q: %q", path, line)
+	}
+
+	// Fields after the command, starting with the state (field 3 in proc(5)).
+	fields := strings.Fields(line[end+1:])
+	if len(fields) < 22 {
+		return nil, fmt.Errorf("only %d fields found in %q", len(fields), path)
+	}
+	stat := &ProcessStat{
+		Pid:     pid,
+		State:   fields[0],
+		Command: line[start+1 : end],
+	}
+	ppid, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse parent pid of %q: %v", path, err)
+	}
+	stat.ParentPid = ppid
+	if stat.UserTime, err = parseUint(fields, 11, path); err != nil {
+		return nil, err
+	}
+	if stat.SystemTime, err = parseUint(fields, 12, path); err != nil {
+		return nil, err
+	}
+	if stat.StartTime, err = parseUint(fields, 19, path); err != nil {
+		return nil, err
+	}
+	if stat.VirtualSize, err = parseUint(fields, 20, path); err != nil {
+		return nil, err
+	}
+	if stat.Rss, err = parseUint(fields, 21, path); err != nil {
+		return nil, err
+	}
+	return stat, nil
+}
+
+// Returns the real user ID of the specified process from /proc/<pid>/status.
+func ReadProcessUid(pid int) (int, error) {
+	path := fmt.Sprintf("/proc/%d/status", pid)
+	out, err := readFile(path)
+	if err != nil {
+		return -1, err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "Uid:" {
+			return strconv.Atoi(fields[1])
+		}
+	}
+	return -1, fmt.Errorf("no Uid found in %q", path)
+}
+
+// Returns the command line arguments of the specified process. Kernel threads have none.
+func ReadProcessCmdline(pid int) ([]string, error) {
+	out, err := readFile(fmt.Sprintf("/proc/%d/cmdline", pid))
+	if err != nil {
+		return nil, err
+	}
+	out = bytes.TrimRight(out, "\x00")
+	if len(out) == 0 {
+		return nil, nil
+	}
+	return strings.Split(string(out), "\x00"), nil
+}
+
+// Returns the cgroup of the specified process in each hierarchy.
+// e.g.: "cpu" -> "/docker/abcd"
+func ReadProcessCgroups(pid int) (map[string]string, error) {
+	path := fmt.Sprintf("/proc/%d/cgroup", pid)
+	out, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cgroups := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		// Format: <hierarchy ID>:<subsystems>:<cgroup path>
+		parts := strings.SplitN(scanner.Text(), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		for _, subsystem := range strings.Split(parts[1], ",") {
+			cgroups[subsystem] = parts[2]
+		}
+	}
+	return cgroups, nil
+}
+
+// Returns the CPU time consumed by the machine, i.e.: the time its CPUs were not idle, from the
+// first line of /proc/stat.
+func ReadCpuTime() (time.Duration, error) {
+	out, err := readFile("/proc/stat")
+	if err != nil {
+		return 0, err
+	}
+	line := string(out)
+	if i := strings.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	// cpu user nice system idle iowait irq softirq steal ...
+	fields := strings.Fields(line)
+	if len(fields) < 5 || fields[0] != "cpu" {
+		return 0, fmt.Errorf("invalid /proc/stat: %q", line)
+	}
+	var jiffies uint64
+	for i := 1; i < len(fields) && i <= 8; i++ {
+		if i == 4 || i == 5 {
+			// idle and iowait.
+			continue
+		}
+		value, err := parseUint(fields, i, "/proc/stat")
+		if err != nil {
+			return 0, err
+		}
+		jiffies += value
+	}
+	return JiffiesToDuration(jiffies), nil
+}
+
+// Returns the time elapsed since the system booted.
+func ReadUptime() (time.Duration, error) {
+	out, err := readFile("/proc/uptime")
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("empty /proc/uptime")
+	}
+	seconds, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse /proc/uptime: %v", err)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
@@ -0,0 +1,112 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package procfs
+
+import (
+	"reflect"
+	"testing"
+
+	"code.google.com/p/gomock/gomock"
+
+	"github.com/google/cadvisor/utils/fs"
+	"github.com/google/cadvisor/utils/fs/mockfs"
+)
+
+func TestReadProcessStat(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mfs := mockfs.NewMockFileSystem(mockCtrl)
+	content := "42 (my (odd) cmd) S 1 42 42 0 -1 4202752 1000 0 0 0 150 30 0 0 20 0 3 0 5000 10485760 256 18446744073709551615 0 0 0 0 0 0 0 0 0 0 0 0 17 0 0 0 0 0 0\n"
+	mockfs.AddTextFile(mfs, "/proc/42/stat", content)
+	fs.ChangeFileSystem(mfs)
+
+	stat, err := ReadProcessStat(42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &ProcessStat{
+		Pid:         42,
+		ParentPid:   1,
+		State:       "S",
+		Command:     "my (odd) cmd",
+		UserTime:    150,
+		SystemTime:  30,
+		StartTime:   5000,
+		VirtualSize: 10485760,
+		Rss:         256,
+	}
+	if !reflect.DeepEqual(stat, expected) {
+		t.Errorf("Received wrong stat: %+v", stat)
+	}
+}
+
+func TestReadProcessCgroupsAndCmdline(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mfs := mockfs.NewMockFileSystem(mockCtrl)
+	mockfs.AddTextFile(mfs, "/proc/7/cgroup", "4:cpu,cpuacct:/docker/abcd\n2:memory:/docker/abcd\n")
+	mockfs.AddTextFile(mfs, "/proc/7/cmdline", "nginx\x00-g\x00daemon off;\x00")
+	mockfs.AddTextFile(mfs, "/proc/7/status", "Name:\tnginx\nUid:\t33\t33\t33\t33\n")
+	fs.ChangeFileSystem(mfs)
+
+	cgroups, err := ReadProcessCgroups(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedCgroups := map[string]string{
+		"cpu":     "/docker/abcd",
+		"cpuacct": "/docker/abcd",
+		"memory":  "/docker/abcd",
+	}
+	if !reflect.DeepEqual(cgroups, expectedCgroups) {
+		t.Errorf("Received wrong cgroups: %+v", cgroups)
+	}
+
+	cmdline, err := ReadProcessCmdline(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cmdline, []string{"nginx", "-g", "daemon off;"}) {
+		t.Errorf("Received wrong cmdline: %q", cmdline)
+	}
+
+	uid, err := ReadProcessUid(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uid != 33 {
+		t.Errorf("Expected uid 33, got %d", uid)
+	}
+}
+
+func TestReadCpuTime(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mfs := mockfs.NewMockFileSystem(mockCtrl)
+	content := "cpu  100 10 50 1000 20 5 5 0 0 0\ncpu0 100 10 50 1000 20 5 5 0 0 0\n"
+	mockfs.AddTextFile(mfs, "/proc/stat", content)
+	fs.ChangeFileSystem(mfs)
+
+	cpuTime, err := ReadCpuTime()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := JiffiesToDuration(170); cpuTime != expected {
+		t.Errorf("expected %v, got %v", expected, cpuTime)
+	}
+}
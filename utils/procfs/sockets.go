@@ -0,0 +1,91 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package procfs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Returns the process owning the UDP socket bound to the local port, e.g.: the sender of a packet
+// received from the loopback interface.
+func FindUdpSocketOwner(port int) (int, error) {
+	var inode string
+	for _, table := range []string{"/proc/net/udp", "/proc/net/udp6"} {
+		out, err := readFile(table)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		if inode = findSocketInode(string(out), port); inode != "" {
+			break
+		}
+	}
+	if inode == "" {
+		return 0, fmt.Errorf("no UDP socket is bound to port %d", port)
+	}
+
+	// The process having the socket open.
+	target := "socket:[" + inode + "]"
+	processes, err := ioutil.ReadDir("/proc")
+	if err != nil {
+		return 0, err
+	}
+	for _, process := range processes {
+		pid, err := strconv.Atoi(process.Name())
+		if err != nil {
+			continue
+		}
+		fdPath := path.Join("/proc", process.Name(), "fd")
+		fds, err := ioutil.ReadDir(fdPath)
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			if link, err := os.Readlink(path.Join(fdPath, fd.Name())); err == nil && link == target {
+				return pid, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("no process has the UDP socket bound to port %d", port)
+}
+
+// Returns the inode of the socket bound to the local port in a /proc/net/udp table, whose lines
+// hold the local address and port in hexadecimal and the inode in the tenth field, e.g.:
+// "112: 0100007F:A1B2 00000000:0000 07 00000000:00000000 00:00000000 00000000 1000 0 98765".
+func findSocketInode(table string, port int) string {
+	for _, line := range strings.Split(table, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 10 {
+			continue
+		}
+		colon := strings.LastIndex(fields[1], ":")
+		if colon < 0 {
+			continue
+		}
+		localPort, err := strconv.ParseUint(fields[1][colon+1:], 16, 16)
+		if err != nil || int(localPort) != port {
+			continue
+		}
+		return fields[9]
+	}
+	return ""
+}
@@ -0,0 +1,33 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package procfs
+
+import "testing"
+
+func TestFindSocketInode(t *testing.T) {
+	table := `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode ref pointer drops
+  112: 0100007F:A1B2 00000000:0000 07 00000000:00000000 00:00000000 00000000  1000        0 98765 2 0000000000000000 0
+  257: 00000000:1FBD 00000000:0000 07 00000000:00000000 00:00000000 00000000     0        0 12345 2 0000000000000000 0
+`
+	if inode := findSocketInode(table, 0xA1B2); inode != "98765" {
+		t.Errorf("expected the inode 98765, got %q", inode)
+	}
+	if inode := findSocketInode(table, 8125); inode != "12345" {
+		t.Errorf("expected the inode 12345, got %q", inode)
+	}
+	if inode := findSocketInode(table, 53); inode != "" {
+		t.Errorf("expected no socket, got %q", inode)
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package selflimit
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/pkg/mount"
+	"github.com/docker/libcontainer/cgroups"
+)
+
+// Default period of the CPU bandwidth controller, in microseconds.
+const defaultCpuPeriod = 100000
+
+// Moves cAdvisor to the cgroup, created if need be, with the specified limits.
+func moveToCgroup(name string, cpu float64, memory int64) error {
+	pid := os.Getpid()
+	if _, err := cgroups.FindCgroupMountpoint("cpu"); err == nil {
+		for _, subsystem := range []string{"cpu", "cpuacct", "memory"} {
+			mnt, err := cgroups.FindCgroupMountpoint(subsystem)
+			if err != nil {
+				return err
+			}
+			if err := setupCgroupV1(path.Join(mnt, name), subsystem, cpu, memory, pid); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	mnt, err := findUnifiedMountpoint()
+	if err != nil {
+		return err
+	}
+	return setupCgroupV2(mnt, name, cpu, memory, pid)
+}
+
+func findUnifiedMountpoint() (string, error) {
+	mounts, err := mount.GetMounts()
+	if err != nil {
+		return "", err
+	}
+	for _, m := range mounts {
+		if m.Fstype == "cgroup2" {
+			return m.Mountpoint, nil
+		}
+	}
+	return "", fmt.Errorf("no cgroup hierarchy is mounted")
+}
+
+// Sets the limits of the subsystem in the cgroup directory of a v1 hierarchy and moves the process
+// to it.
+func setupCgroupV1(dir, subsystem string, cpu float64, memory int64, pid int) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	switch {
+	case subsystem == "cpu" && cpu > 0:
+		period := int64(defaultCpuPeriod)
+		if out, err := ioutil.ReadFile(path.Join(dir, "cpu.cfs_period_us")); err == nil {
+			if p, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64); err == nil && p > 0 {
+				period = p
+			}
+		}
+		if err := writeFile(dir, "cpu.cfs_quota_us", strconv.FormatInt(int64(cpu*float64(period)), 10)); err != nil {
+			return err
+		}
+	case subsystem == "memory" && memory > 0:
+		if err := writeFile(dir, "memory.limit_in_bytes", strconv.FormatInt(memory, 10)); err != nil {
+			return err
+		}
+	}
+	return writeFile(dir, "cgroup.procs", strconv.Itoa(pid))
+}
+
+// Creates the cgroup in the unified hierarchy with the limits and moves the process to it.
+func setupCgroupV2(mnt, name string, cpu float64, memory int64, pid int) error {
+	dir := path.Join(mnt, name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if cpu > 0 || memory > 0 {
+		// The controllers must be enabled for the children of the parent.
+		if err := writeFile(path.Dir(dir), "cgroup.subtree_control", "+cpu +memory"); err != nil {
+			return err
+		}
+	}
+	if cpu > 0 {
+		if err := writeFile(dir, "cpu.max", fmt.Sprintf("%d %d", int64(cpu*defaultCpuPeriod), defaultCpuPeriod)); err != nil {
+			return err
+		}
+	}
+	if memory > 0 {
+		if err := writeFile(dir, "memory.max", strconv.FormatInt(memory, 10)); err != nil {
+			return err
+		}
+	}
+	return writeFile(dir, "cgroup.procs", strconv.Itoa(pid))
+}
+
+func writeFile(dir, file, value string) error {
+	if err := ioutil.WriteFile(path.Join(dir, file), []byte(value), 0644); err != nil {
+		return fmt.Errorf("failed to write %q to %q: %v", value, path.Join(dir, file), err)
+	}
+	return nil
+}
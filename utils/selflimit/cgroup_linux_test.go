@@ -0,0 +1,86 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package selflimit
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func readFile(t *testing.T, dir, file string) string {
+	out, err := ioutil.ReadFile(path.Join(dir, file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestSetupCgroupV1(t *testing.T) {
+	mnt, err := ioutil.TempDir("", "selflimit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(mnt)
+	dir := path.Join(mnt, "cadvisor")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path.Join(dir, "cpu.cfs_period_us"), []byte("50000\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := setupCgroupV1(dir, "cpu", 1.5, 1<<20, 42); err != nil {
+		t.Fatal(err)
+	}
+	if quota := readFile(t, dir, "cpu.cfs_quota_us"); quota != "75000" {
+		t.Errorf("expected a quota of 75000us, got %q", quota)
+	}
+	if err := setupCgroupV1(dir, "memory", 1.5, 1<<20, 42); err != nil {
+		t.Fatal(err)
+	}
+	if limit := readFile(t, dir, "memory.limit_in_bytes"); limit != "1048576" {
+		t.Errorf("expected a limit of 1048576 bytes, got %q", limit)
+	}
+	if pid := readFile(t, dir, "cgroup.procs"); pid != "42" {
+		t.Errorf("expected process 42 to be moved, got %q", pid)
+	}
+}
+
+func TestSetupCgroupV2(t *testing.T) {
+	mnt, err := ioutil.TempDir("", "selflimit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(mnt)
+
+	if err := setupCgroupV2(mnt, "/system/cadvisor", 0.5, 1<<20, 42); err != nil {
+		t.Fatal(err)
+	}
+	dir := path.Join(mnt, "system", "cadvisor")
+	if controllers := readFile(t, path.Dir(dir), "cgroup.subtree_control"); controllers != "+cpu +memory" {
+		t.Errorf("expected the controllers to be enabled, got %q", controllers)
+	}
+	if max := readFile(t, dir, "cpu.max"); max != "50000 100000" {
+		t.Errorf("expected half of the CPU period, got %q", max)
+	}
+	if max := readFile(t, dir, "memory.max"); max != "1048576" {
+		t.Errorf("expected a limit of 1048576 bytes, got %q", max)
+	}
+	if pid := readFile(t, dir, "cgroup.procs"); pid != "42" {
+		t.Errorf("expected process 42 to be moved, got %q", pid)
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package selflimit limits the resources cAdvisor uses, so that it does not starve the containers
+// it monitors: its niceness, its own cgroup with CPU and memory limits, and a budget beyond which
+// it skips its expensive collections.
+package selflimit
+
+import (
+	"flag"
+	"fmt"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+var (
+	argNice         = flag.Int("nice", 0, "Niceness of cAdvisor, from -20 to 19, unchanged if 0")
+	argCgroup       = flag.String("self_cgroup", "", "Cgroup cAdvisor moves itself to at startup, e.g. /cadvisor, limited by --self_cpu_limit and --self_memory_limit. Unchanged if empty")
+	argCpuLimit     = flag.Float64("self_cpu_limit", 0, "Maximum number of cores used by the cgroup of --self_cgroup, no limit if 0")
+	argMemoryLimit  = flag.Int64("self_memory_limit", 0, "Maximum bytes of memory used by the cgroup of --self_cgroup, no limit if 0")
+	argCpuBudget    = flag.Float64("self_cpu_budget", 0, "Number of cores used by cAdvisor beyond which it skips its expensive collections, e.g. of the processes of containers, until back under it. No budget if 0")
+	argMemoryBudget = flag.Int64("self_memory_budget", 0, "Bytes of heap used by cAdvisor beyond which it skips its expensive collections until back under it. No budget if 0")
+)
+
+// Interval between the checks of the resources used against the budget.
+const budgetInterval = 5 * time.Second
+
+// 1 while cAdvisor uses more than its budget.
+var overBudget int32
+
+// Returns whether cAdvisor uses more resources than its budget, in which case its expensive
+// collections are skipped.
+func OverBudget() bool {
+	return atomic.LoadInt32(&overBudget) == 1
+}
+
+// Applies the limits of the flags to cAdvisor and starts watching its budget. Called once at
+// startup.
+func Apply() error {
+	if *argCpuLimit < 0 || *argMemoryLimit < 0 || *argCpuBudget < 0 || *argMemoryBudget < 0 {
+		return fmt.Errorf("limits and budgets must not be negative")
+	}
+	if (*argCpuLimit > 0 || *argMemoryLimit > 0) && *argCgroup == "" {
+		return fmt.Errorf("--self_cpu_limit and --self_memory_limit need --self_cgroup")
+	}
+	if *argNice != 0 {
+		if err := setNice(*argNice); err != nil {
+			return fmt.Errorf("failed to set the niceness to %d: %v", *argNice, err)
+		}
+		glog.Infof("Running with niceness %d", *argNice)
+	}
+	if *argCgroup != "" {
+		if err := moveToCgroup(*argCgroup, *argCpuLimit, *argMemoryLimit); err != nil {
+			return fmt.Errorf("failed to move to cgroup %q: %v", *argCgroup, err)
+		}
+		glog.Infof("Running in cgroup %q limited to %v cores and %d bytes (0 is no limit)", *argCgroup, *argCpuLimit, *argMemoryLimit)
+	}
+	WatchBudget()
+	return nil
+}
+
+// Starts watching the budget of the flags, without applying the limits, e.g. when they were
+// applied before cAdvisor re-executed itself.
+func WatchBudget() {
+	if *argCpuBudget > 0 || *argMemoryBudget > 0 {
+		go watchBudget(&budget{cpu: *argCpuBudget, memory: uint64(*argMemoryBudget)})
+	}
+}
+
+// Resources cAdvisor may use, 0 for no limit.
+type budget struct {
+	// Number of cores.
+	cpu float64
+	// Bytes of heap in use.
+	memory uint64
+
+	// CPU time used as of the last check, and its time.
+	lastCpuTime time.Duration
+	lastCheck   time.Time
+}
+
+// Returns whether the resources used are over the budget, given the CPU time used so far at the
+// time of the check and the bytes of heap in use.
+func (self *budget) check(cpuTime time.Duration, now time.Time, heap uint64) bool {
+	over := false
+	if self.cpu > 0 && !self.lastCheck.IsZero() && now.After(self.lastCheck) {
+		cores := float64(cpuTime-self.lastCpuTime) / float64(now.Sub(self.lastCheck))
+		over = cores > self.cpu
+	}
+	self.lastCpuTime = cpuTime
+	self.lastCheck = now
+	if self.memory > 0 && heap > self.memory {
+		over = true
+	}
+	return over
+}
+
+func watchBudget(b *budget) {
+	for {
+		var cpu time.Duration
+		if b.cpu > 0 {
+			var err error
+			if cpu, err = cpuTime(); err != nil {
+				glog.Warningf("Not watching the CPU budget: %v", err)
+				b.cpu = 0
+			}
+		}
+		var heap uint64
+		if b.memory > 0 {
+			var stats runtime.MemStats
+			runtime.ReadMemStats(&stats)
+			heap = stats.HeapInuse
+		}
+		over := b.check(cpu, time.Now(), heap)
+		if over != OverBudget() {
+			if over {
+				glog.Warningf("Over the budget of %v cores and %d bytes of heap, skipping expensive collections", b.cpu, b.memory)
+				atomic.StoreInt32(&overBudget, 1)
+			} else {
+				glog.Infof("Back under budget, resuming expensive collections")
+				atomic.StoreInt32(&overBudget, 0)
+			}
+		}
+		time.Sleep(budgetInterval)
+	}
+}
@@ -0,0 +1,35 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !linux
+// +build !linux
+
+package selflimit
+
+import (
+	"fmt"
+	"time"
+)
+
+func setNice(nice int) error {
+	return fmt.Errorf("setting the niceness is only supported on Linux")
+}
+
+func moveToCgroup(name string, cpu float64, memory int64) error {
+	return fmt.Errorf("cgroups are only supported on Linux")
+}
+
+func cpuTime() (time.Duration, error) {
+	return 0, fmt.Errorf("the CPU time used is only read on Linux")
+}
@@ -0,0 +1,37 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package selflimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBudgetCheck(t *testing.T) {
+	b := &budget{cpu: 0.5, memory: 1000}
+	start := time.Now()
+	if b.check(time.Second, start, 10) {
+		t.Errorf("expected the first check to be under budget")
+	}
+	if !b.check(2*time.Second, start.Add(time.Second), 10) {
+		t.Errorf("expected a core used over a second to be over a budget of 0.5")
+	}
+	if b.check(2100*time.Millisecond, start.Add(2*time.Second), 10) {
+		t.Errorf("expected 0.1 core to be under budget")
+	}
+	if !b.check(2100*time.Millisecond, start.Add(3*time.Second), 2000) {
+		t.Errorf("expected 2000 bytes of heap to be over budget")
+	}
+}
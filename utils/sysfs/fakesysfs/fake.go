@@ -62,3 +62,23 @@ func (self *FakeSysFs) GetBlockDeviceSize(name string) (string, error) {
 func (self *FakeSysFs) GetBlockDeviceNumbers(name string) (string, error) {
 	return "8:0\n", nil
 }
+
+func (self *FakeSysFs) GetHugePagesPools() ([]os.FileInfo, error) {
+	return nil, nil
+}
+
+func (self *FakeSysFs) GetHugePagesCounter(pool string, counter string) (string, error) {
+	return "0\n", nil
+}
+
+func (self *FakeSysFs) GetNodes() ([]os.FileInfo, error) {
+	return nil, nil
+}
+
+func (self *FakeSysFs) GetNodeCpuList(node string) (string, error) {
+	return "0\n", nil
+}
+
+func (self *FakeSysFs) GetNodeMeminfo(node string) (string, error) {
+	return "Node 0 MemTotal: 1024 kB\n", nil
+}
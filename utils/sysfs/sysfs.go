@@ -25,7 +25,11 @@ import (
 	"github.com/google/cadvisor/info"
 )
 
-const BlockDir = "/sys/block"
+const (
+	BlockDir     = "/sys/block"
+	HugePagesDir = "/sys/kernel/mm/hugepages"
+	NodeDir      = "/sys/devices/system/node"
+)
 
 // Abstracts the lowest level calls to sysfs.
 type SysFs interface {
@@ -35,6 +39,18 @@ type SysFs interface {
 	GetBlockDeviceSize(string) (string, error)
 	// Get device major:minor number string.
 	GetBlockDeviceNumbers(string) (string, error)
+
+	// Get directory information for the hugepages pools (e.g.: hugepages-2048kB).
+	GetHugePagesPools() ([]os.FileInfo, error)
+	// Get the value of a counter of a hugepages pool (e.g.: nr_hugepages).
+	GetHugePagesCounter(pool string, counter string) (string, error)
+
+	// Get directory information for the NUMA nodes (e.g.: node0).
+	GetNodes() ([]os.FileInfo, error)
+	// Get the list of CPUs of a NUMA node (e.g.: 0-3,8-11).
+	GetNodeCpuList(string) (string, error)
+	// Get the meminfo of a NUMA node.
+	GetNodeMeminfo(string) (string, error)
 }
 
 type realSysFs struct{}
@@ -63,6 +79,38 @@ func (self *realSysFs) GetBlockDeviceSize(name string) (string, error) {
 	return string(size), nil
 }
 
+func (self *realSysFs) GetHugePagesPools() ([]os.FileInfo, error) {
+	return ioutil.ReadDir(HugePagesDir)
+}
+
+func (self *realSysFs) GetHugePagesCounter(pool string, counter string) (string, error) {
+	value, err := ioutil.ReadFile(path.Join(HugePagesDir, pool, counter))
+	if err != nil {
+		return "", err
+	}
+	return string(value), nil
+}
+
+func (self *realSysFs) GetNodes() ([]os.FileInfo, error) {
+	return ioutil.ReadDir(NodeDir)
+}
+
+func (self *realSysFs) GetNodeCpuList(node string) (string, error) {
+	cpus, err := ioutil.ReadFile(path.Join(NodeDir, node, "cpulist"))
+	if err != nil {
+		return "", err
+	}
+	return string(cpus), nil
+}
+
+func (self *realSysFs) GetNodeMeminfo(node string) (string, error) {
+	meminfo, err := ioutil.ReadFile(path.Join(NodeDir, node, "meminfo"))
+	if err != nil {
+		return "", err
+	}
+	return string(meminfo), nil
+}
+
 // Get information about block devices present on the system.
 // Uses the passed in system interface to retrieve the low level OS information.
 func GetBlockDeviceInfo(sysfs SysFs) (map[string]info.DiskInfo, error) {
@@ -106,3 +154,112 @@ func GetBlockDeviceInfo(sysfs SysFs) (map[string]info.DiskInfo, error) {
 	}
 	return diskMap, nil
 }
+
+// Get the hugepages pools of the system, by page size.
+// Returns none if the kernel was built without hugetlbfs.
+func GetHugePagesInfo(sysfs SysFs) ([]info.HugePagesInfo, error) {
+	pools, err := sysfs.GetHugePagesPools()
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var hugePages []info.HugePagesInfo
+	for _, pool := range pools {
+		name := pool.Name()
+		var pageSize uint64
+		n, err := fmt.Sscanf(name, "hugepages-%dkB", &pageSize)
+		if err != nil || n != 1 {
+			return nil, fmt.Errorf("could not parse page size from hugepages pool %s", name)
+		}
+		pages := info.HugePagesInfo{PageSize: pageSize}
+		for counter, value := range map[string]*uint64{
+			"nr_hugepages":   &pages.NumPages,
+			"free_hugepages": &pages.FreePages,
+		} {
+			out, err := sysfs.GetHugePagesCounter(name, counter)
+			if err != nil {
+				return nil, err
+			}
+			*value, err = strconv.ParseUint(strings.TrimSpace(out), 10, 64)
+			if err != nil {
+				return nil, err
+			}
+		}
+		hugePages = append(hugePages, pages)
+	}
+	return hugePages, nil
+}
+
+// Get the NUMA topology of the system.
+// Returns none if the kernel was built without NUMA support.
+func GetNodesInfo(sysfs SysFs) ([]info.Node, error) {
+	entries, err := sysfs.GetNodes()
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var nodes []info.Node
+	for _, entry := range entries {
+		name := entry.Name()
+		var id int
+		if n, err := fmt.Sscanf(name, "node%d", &id); err != nil || n != 1 {
+			// Not a node, e.g.: online, possible.
+			continue
+		}
+		node := info.Node{Id: id}
+		cpuList, err := sysfs.GetNodeCpuList(name)
+		if err != nil {
+			return nil, err
+		}
+		node.Cpus, err = parseCpuList(cpuList)
+		if err != nil {
+			return nil, err
+		}
+		meminfo, err := sysfs.GetNodeMeminfo(name)
+		if err != nil {
+			return nil, err
+		}
+		// e.g.: Node 0 MemTotal:        5078776 kB
+		for _, line := range strings.Split(meminfo, "\n") {
+			fields := strings.Fields(line)
+			if len(fields) == 5 && fields[2] == "MemTotal:" {
+				memory, err := strconv.ParseUint(fields[3], 10, 64)
+				if err != nil {
+					return nil, err
+				}
+				node.Memory = memory * 1024
+				break
+			}
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// Parses a list of CPUs in the format of sysfs, e.g.: 0-3,8,10-11
+func parseCpuList(cpuList string) ([]int, error) {
+	var cpus []int
+	cpuList = strings.TrimSpace(cpuList)
+	if cpuList == "" {
+		return cpus, nil
+	}
+	for _, cpuRange := range strings.Split(cpuList, ",") {
+		var first, last int
+		if n, err := fmt.Sscanf(cpuRange, "%d-%d", &first, &last); err != nil || n != 2 {
+			if n, err := fmt.Sscanf(cpuRange, "%d", &first); err != nil || n != 1 {
+				return nil, fmt.Errorf("could not parse cpu list %q", cpuList)
+			}
+			last = first
+		}
+		for cpu := first; cpu <= last; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+	return cpus, nil
+}
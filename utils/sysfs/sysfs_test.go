@@ -0,0 +1,38 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sysfs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCpuList(t *testing.T) {
+	for cpuList, expected := range map[string][]int{
+		"\n":            nil,
+		"0\n":           {0},
+		"0-3,8,10-11\n": {0, 1, 2, 3, 8, 10, 11},
+	} {
+		cpus, err := parseCpuList(cpuList)
+		if err != nil {
+			t.Errorf("failed to parse %q: %v", cpuList, err)
+		} else if !reflect.DeepEqual(cpus, expected) {
+			t.Errorf("expected %v for %q, got %v", expected, cpuList, cpus)
+		}
+	}
+	if _, err := parseCpuList("a-b"); err == nil {
+		t.Error("expected an invalid cpu list to fail")
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package systemd implements the systemd socket activation and notification protocols.
+package systemd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+)
+
+// First file descriptor passed by systemd (SD_LISTEN_FDS_START).
+const listenFdsStart = 3
+
+// Returns the listening sockets passed by systemd through socket activation.
+// Returns no listeners if the process was not socket activated.
+func Listeners() ([]net.Listener, error) {
+	defer os.Unsetenv("LISTEN_PID")
+	defer os.Unsetenv("LISTEN_FDS")
+
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	numFds, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || numFds <= 0 {
+		return nil, nil
+	}
+
+	listeners := make([]net.Listener, 0, numFds)
+	for fd := listenFdsStart; fd < listenFdsStart+numFds; fd++ {
+		closeOnExec(fd)
+		file := os.NewFile(uintptr(fd), fmt.Sprintf("LISTEN_FD_%d", fd))
+		listener, err := net.FileListener(file)
+		if err != nil {
+			return nil, fmt.Errorf("socket activation fd %d is not a listening socket: %v", fd, err)
+		}
+		// FileListener dups the descriptor.
+		file.Close()
+		listeners = append(listeners, listener)
+	}
+	return listeners, nil
+}
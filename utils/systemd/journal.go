@@ -0,0 +1,121 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package systemd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Socket of the native protocol of journald.
+const journalSocket = "/run/systemd/journal/socket"
+
+// Priorities of journal entries, as syslog's.
+const (
+	PriorityErr    = 3
+	PriorityNotice = 5
+	PriorityInfo   = 6
+)
+
+// Writes entries to the journal of systemd.
+type Journal struct {
+	socket string
+	lock   sync.Mutex
+	conn   *net.UnixConn
+}
+
+// Returns a writer of entries to the journal, failing if journald is not running.
+func NewJournal() (*Journal, error) {
+	return newJournal(journalSocket)
+}
+
+func newJournal(socket string) (*Journal, error) {
+	self := &Journal{socket: socket}
+	if err := self.dial(); err != nil {
+		return nil, err
+	}
+	return self, nil
+}
+
+func (self *Journal) dial() error {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: self.socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	self.conn = conn
+	return nil
+}
+
+// Sends an entry with the message, priority and fields, whose names must be uppercase letters,
+// digits and underscores, e.g. "CADVISOR_CLIENT".
+func (self *Journal) Send(message string, priority int, fields map[string]string) error {
+	entry := journalEntry(message, priority, fields)
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if _, err := self.conn.Write(entry); err != nil {
+		// Journald may have restarted.
+		self.conn.Close()
+		if err := self.dial(); err != nil {
+			return err
+		}
+		_, err = self.conn.Write(entry)
+		return err
+	}
+	return nil
+}
+
+func (self *Journal) Close() error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.conn.Close()
+}
+
+// Encodes an entry in the native protocol of journald: a "NAME=value" line per field, or the
+// name, the length of the value as a little-endian uint64 and the value for values spanning
+// several lines.
+func journalEntry(message string, priority int, fields map[string]string) []byte {
+	var buf bytes.Buffer
+	writeJournalField(&buf, "MESSAGE", message)
+	writeJournalField(&buf, "PRIORITY", strconv.Itoa(priority))
+	writeJournalField(&buf, "SYSLOG_IDENTIFIER", "cadvisor")
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		writeJournalField(&buf, name, fields[name])
+	}
+	return buf.Bytes()
+}
+
+func writeJournalField(buf *bytes.Buffer, name, value string) {
+	buf.WriteString(name)
+	if !strings.Contains(value, "\n") {
+		buf.WriteByte('=')
+		buf.WriteString(value)
+		buf.WriteByte('\n')
+		return
+	}
+	buf.WriteByte('\n')
+	binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
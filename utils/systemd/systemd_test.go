@@ -0,0 +1,122 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package systemd
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestNotify(t *testing.T) {
+	dir, err := ioutil.TempDir("", "systemd_notify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	socketPath := path.Join(dir, "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socketPath, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	os.Setenv("NOTIFY_SOCKET", socketPath)
+	defer os.Unsetenv("NOTIFY_SOCKET")
+	sent, err := Notify("READY=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sent {
+		t.Fatalf("expected the notification to be sent")
+	}
+
+	buf := make([]byte, 64)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf[:n]) != "READY=1" {
+		t.Errorf("received %q instead of READY=1", buf[:n])
+	}
+}
+
+func TestNotifyWithoutSocket(t *testing.T) {
+	os.Unsetenv("NOTIFY_SOCKET")
+	sent, err := Notify("READY=1")
+	if err != nil || sent {
+		t.Errorf("expected no notification without NOTIFY_SOCKET, got %v, %v", sent, err)
+	}
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	defer os.Unsetenv("WATCHDOG_USEC")
+	defer os.Unsetenv("WATCHDOG_PID")
+
+	os.Setenv("WATCHDOG_USEC", "30000000")
+	os.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+	if interval := WatchdogInterval(); interval != 30*time.Second {
+		t.Errorf("expected a 30s watchdog, got %v", interval)
+	}
+
+	// The watchdog is meant for another process.
+	os.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()+1))
+	if interval := WatchdogInterval(); interval != 0 {
+		t.Errorf("expected no watchdog, got %v", interval)
+	}
+}
+
+func TestJournal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "systemd_journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	socketPath := path.Join(dir, "journal")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socketPath, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	journal, err := newJournal(socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer journal.Close()
+	if err := journal.Send("hello", PriorityInfo, map[string]string{"CADVISOR_B": "two\nlines", "CADVISOR_A": "a"}); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 1024)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "MESSAGE=hello\nPRIORITY=6\nSYSLOG_IDENTIFIER=cadvisor\nCADVISOR_A=a\nCADVISOR_B\n\x09\x00\x00\x00\x00\x00\x00\x00two\nlines\n"
+	if string(buf[:n]) != expected {
+		t.Errorf("expected entry %q, got %q", expected, buf[:n])
+	}
+
+	if _, err := newJournal(path.Join(dir, "missing")); err == nil {
+		t.Errorf("expected an error without journald")
+	}
+}
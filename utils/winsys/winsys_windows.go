@@ -0,0 +1,94 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+// +build windows
+
+package winsys
+
+import (
+	"fmt"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+var (
+	kernel32 = syscall.NewLazyDLL("kernel32.dll")
+	ntdll    = syscall.NewLazyDLL("ntdll.dll")
+
+	procGlobalMemoryStatusEx = kernel32.NewProc("GlobalMemoryStatusEx")
+	procGetSystemTimes       = kernel32.NewProc("GetSystemTimes")
+	procRtlGetVersion        = ntdll.NewProc("RtlGetVersion")
+)
+
+// MEMORYSTATUSEX of the Windows API.
+type memoryStatusEx struct {
+	Length               uint32
+	MemoryLoad           uint32
+	TotalPhys            uint64
+	AvailPhys            uint64
+	TotalPageFile        uint64
+	AvailPageFile        uint64
+	TotalVirtual         uint64
+	AvailVirtual         uint64
+	AvailExtendedVirtual uint64
+}
+
+// Returns the physical memory of the machine and the part of it available, in bytes.
+func Memory() (total, available uint64, err error) {
+	status := memoryStatusEx{}
+	status.Length = uint32(unsafe.Sizeof(status))
+	if r, _, err := procGlobalMemoryStatusEx.Call(uintptr(unsafe.Pointer(&status))); r == 0 {
+		return 0, 0, fmt.Errorf("GlobalMemoryStatusEx failed: %v", err)
+	}
+	return status.TotalPhys, status.AvailPhys, nil
+}
+
+// Returns the time all the processors of the machine spent idle, in the kernel and in user mode
+// since the machine started. The kernel time includes the idle time.
+func CpuTimes() (idle, kernel, user time.Duration, err error) {
+	var idleTime, kernelTime, userTime syscall.Filetime
+	r, _, callErr := procGetSystemTimes.Call(uintptr(unsafe.Pointer(&idleTime)), uintptr(unsafe.Pointer(&kernelTime)), uintptr(unsafe.Pointer(&userTime)))
+	if r == 0 {
+		return 0, 0, 0, fmt.Errorf("GetSystemTimes failed: %v", callErr)
+	}
+	return filetimeDuration(idleTime), filetimeDuration(kernelTime), filetimeDuration(userTime), nil
+}
+
+// Converts a FILETIME holding a duration, in units of 100ns.
+func filetimeDuration(ft syscall.Filetime) time.Duration {
+	return time.Duration(uint64(ft.HighDateTime)<<32|uint64(ft.LowDateTime)) * 100
+}
+
+// RTL_OSVERSIONINFOW of the Windows API.
+type osVersionInfo struct {
+	OSVersionInfoSize uint32
+	MajorVersion      uint32
+	MinorVersion      uint32
+	BuildNumber       uint32
+	PlatformId        uint32
+	CSDVersion        [128]uint16
+}
+
+// Returns the version of Windows, e.g. 10.0.17763. Unlike GetVersion, RtlGetVersion reports the
+// actual version whatever the manifest of the executable.
+func KernelVersion() (string, error) {
+	version := osVersionInfo{}
+	version.OSVersionInfoSize = uint32(unsafe.Sizeof(version))
+	if status, _, _ := procRtlGetVersion.Call(uintptr(unsafe.Pointer(&version))); status != 0 {
+		return "", fmt.Errorf("RtlGetVersion failed with NTSTATUS 0x%x", status)
+	}
+	return fmt.Sprintf("%d.%d.%d", version.MajorVersion, version.MinorVersion, version.BuildNumber), nil
+}
@@ -20,15 +20,15 @@ package validate
 import (
 	"fmt"
 	"github.com/google/cadvisor/manager"
-	"io/ioutil"
+	"io"
 	"log"
 	"net/http"
-	"strings"
+	"os"
+	"time"
 
-	"github.com/docker/libcontainer/cgroups"
-	dclient "github.com/fsouza/go-dockerclient"
-	"github.com/google/cadvisor/container/docker"
-	"github.com/google/cadvisor/utils"
+	"github.com/google/cadvisor/container"
+	"github.com/google/cadvisor/info"
+	"github.com/google/cadvisor/utils/lsm"
 )
 
 const ValidatePage = "/validate/"
@@ -36,6 +36,7 @@ const Supported = "[Supported, but not recommended]"
 const Unsupported = "[Unsupported]"
 const Recommended = "[Supported and recommended]"
 const Unknown = "[Unknown]"
+const Failing = "[Failing]"
 const VersionFormat = "%d.%d.%s"
 const OutputFormat = "%s: %s\n\t%s\n\n"
 
@@ -91,115 +92,86 @@ func validateDockerVersion(version string) (string, string) {
 	return Recommended, desc
 }
 
-func getEnabledCgroups() (map[string]int, error) {
-	out, err := ioutil.ReadFile("/proc/cgroups")
-	if err != nil {
-		return nil, err
-	}
-	cgroups := make(map[string]int)
-	for i, line := range strings.Split(string(out), "\n") {
-		var cgroup string
-		var ign, enabled int
-		if i == 0 || line == "" {
-			continue
-		}
-		n, err := fmt.Sscanf(line, "%s %d %d %d", &cgroup, &ign, &ign, &enabled)
-		if n != 4 || err != nil {
-			if err == nil {
-				err = fmt.Errorf("failed to parse /proc/cgroup entry %s", line)
+func validateStorageDrivers(statuses []info.StorageDriverStatus) (string, string) {
+	if len(statuses) == 0 {
+		return Recommended, "No storage driver is used, stats are only kept in memory.\n"
+	}
+	result := Recommended
+	desc := ""
+	for _, status := range statuses {
+		if status.Connected {
+			desc += fmt.Sprintf("\t%s is healthy", status.Name)
+			if !status.LastSuccess.IsZero() {
+				desc += fmt.Sprintf(", last write at %s", status.LastSuccess.Format(time.RFC3339))
 			}
-			return nil, err
+		} else {
+			result = Failing
+			desc += fmt.Sprintf("\t%s is failing, %d consecutive failures, last error at %s: %s", status.Name, status.ConsecutiveFailures, status.LastErrorTime.Format(time.RFC3339), status.LastError)
 		}
-		cgroups[cgroup] = enabled
+		desc += fmt.Sprintf(". %d samples dropped, %d queued.\n", status.Dropped, status.Queued)
 	}
-	return cgroups, nil
+	return result, desc
 }
 
-func areCgroupsPresent(available map[string]int, desired []string) (bool, string) {
-	for _, cgroup := range desired {
-		enabled, ok := available[cgroup]
-		if !ok {
-			reason := fmt.Sprintf("Missing cgroup %s. Available cgroups: %v\n", cgroup, available)
-			return false, reason
-		}
-		if enabled != 1 {
-			reason := fmt.Sprintf("Cgroup %s not enabled. Available cgroups: %v\n", cgroup, available)
-			return false, reason
-		}
-	}
-	return true, ""
+// A file cAdvisor reads, checked for accesses denied by security modules.
+type probe struct {
+	path string
+	// What cAdvisor reads the file for.
+	what string
 }
 
-func validateCgroups() (string, string) {
-	required_cgroups := []string{"cpu", "cpuacct"}
-	recommended_cgroups := []string{"memory", "blkio", "cpuset", "devices", "freezer"}
-	available_cgroups, err := getEnabledCgroups()
-	desc := fmt.Sprintf("\tFollowing cgroups are required: %v\n\tFollowing other cgroups are recommended: %v\n", required_cgroups, recommended_cgroups)
+// Returns the error reading the file or directory, nil if it can be read.
+func readProbe(name string) error {
+	f, err := os.Open(name)
 	if err != nil {
-		desc = fmt.Sprintf("Could not parse /proc/cgroups.\n%s", desc)
-		return Unknown, desc
+		return err
 	}
-	ok, out := areCgroupsPresent(available_cgroups, required_cgroups)
-	if !ok {
-		out += desc
-		return Unsupported, out
+	defer f.Close()
+	if stat, err := f.Stat(); err == nil && stat.IsDir() {
+		_, err = f.Readdirnames(1)
+		if err == io.EOF {
+			return nil
+		}
+		return err
 	}
-	ok, out = areCgroupsPresent(available_cgroups, recommended_cgroups)
-	if !ok {
-		// supported, but not recommended.
-		out += desc
-		return Supported, out
+	_, err = f.Read(make([]byte, 1))
+	if err == io.EOF {
+		return nil
 	}
-	out = fmt.Sprintf("Available cgroups: %v\n", available_cgroups)
-	out += desc
-	return Recommended, out
+	return err
 }
 
-func validateDockerInfo() (string, string) {
-	client, err := dclient.NewClient(*docker.ArgDockerEndpoint)
-	if err == nil {
-		info, err := client.Info()
-		if err == nil {
-			execDriver := info.Get("ExecutionDriver")
-			storageDriver := info.Get("Driver")
-			desc := fmt.Sprintf("Docker exec driver is %s. Storage driver is %s.\n", execDriver, storageDriver)
-			if docker.UseSystemd() {
-				desc += "\tsystemd is being used to create cgroups.\n"
-			} else {
-				desc += "\tCgroups are being created through cgroup filesystem.\n"
-			}
-			if strings.Contains(execDriver, "native") {
-				return Recommended, desc
-			} else if strings.Contains(execDriver, "lxc") {
-				return Supported, desc
-			}
-			return Unknown, desc
+// Containers whose denied metrics are listed, the others are counted.
+const maxDeniedContainers = 20
+
+func validateSecurityModules(status lsm.Status, probes []probe, denials []container.DeniedMetric) (string, string) {
+	desc := fmt.Sprintf("%s.\n", status)
+	denied := false
+	for _, p := range probes {
+		if err := readProbe(p.path); err != nil && os.IsPermission(err) {
+			desc += fmt.Sprintf("\tAccess to %s is denied, needed for %s.\n", p.path, p.what)
+			denied = true
 		}
 	}
-	return Unknown, "Docker remote API not reachable\n\t"
-}
-
-func validateCgroupMounts() (string, string) {
-	const recommendedMount = "/sys/fs/cgroup"
-	desc := fmt.Sprintf("\tAny cgroup mount point that is detectible and accessible is supported. %s is recommended as a standard location.\n", recommendedMount)
-	mnt, err := cgroups.FindCgroupMountpoint("cpu")
-	if err != nil {
-		out := "Could not locate cgroup mount point.\n"
-		out += desc
-		return Unknown, out
-	}
-	mnt = strings.TrimSuffix(mnt, "/cpu")
-	if !utils.FileExists(mnt) {
-		out := fmt.Sprintf("Cgroup mount directory %s inaccessible.\n", mnt)
-		out += desc
-		return Unsupported, out
-	}
-	out := fmt.Sprintf("Cgroups are mounted at %s.\n", mnt)
-	out += desc
-	if mnt == recommendedMount {
-		return Recommended, out
-	}
-	return Supported, out
+	containers := map[string]bool{}
+	for _, d := range denials {
+		containers[d.Container] = true
+		if len(containers) <= maxDeniedContainers {
+			desc += fmt.Sprintf("\tThe %s metrics of %s are not collected, retrying at %s: %v\n", d.Kind, d.Container, d.Retry.Format(time.RFC3339), d.Err)
+		}
+		denied = true
+	}
+	if len(containers) > maxDeniedContainers {
+		desc += fmt.Sprintf("\tAccess to the metrics of %d more containers is denied.\n", len(containers)-maxDeniedContainers)
+	}
+	switch {
+	case denied:
+		desc += fmt.Sprintf("\tTo collect everything, %s.\n", status.Hint())
+		return Failing, desc
+	case status.Confined():
+		return Supported, desc + "\tNo access needed by cAdvisor is denied so far.\n"
+	}
+	return Recommended, desc
 }
 
 func HandleRequest(w http.ResponseWriter, containerManager manager.Manager) error {
@@ -217,17 +189,18 @@ func HandleRequest(w http.ResponseWriter, containerManager manager.Manager) erro
 	kernelValidation, desc := validateKernelVersion(versionInfo.KernelVersion)
 	out += fmt.Sprintf(OutputFormat, "Kernel version", kernelValidation, desc)
 
-	cgroupValidation, desc := validateCgroups()
-	out += fmt.Sprintf(OutputFormat, "Cgroup setup", cgroupValidation, desc)
-
-	mountsValidation, desc := validateCgroupMounts()
-	out += fmt.Sprintf(OutputFormat, "Cgroup mount setup", mountsValidation, desc)
+	out += validateCgroupSetup()
 
 	dockerValidation, desc := validateDockerVersion(versionInfo.DockerVersion)
 	out += fmt.Sprintf(OutputFormat, "Docker version", dockerValidation, desc)
 
-	dockerInfoValidation, desc := validateDockerInfo()
-	out += fmt.Sprintf(OutputFormat, "Docker driver setup", dockerInfoValidation, desc)
+	out += validateDockerSetup()
+
+	storageValidation, desc := validateStorageDrivers(containerManager.GetStorageStatus())
+	out += fmt.Sprintf(OutputFormat, "Storage drivers", storageValidation, desc)
+
+	securityValidation, desc := validateSecurityModules(lsm.Detect(), securityProbes(), container.DeniedMetrics())
+	out += fmt.Sprintf(OutputFormat, "Security modules", securityValidation, desc)
 
 	_, err = w.Write([]byte(out))
 	return err
@@ -0,0 +1,35 @@
+// Copyright 2014 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !linux
+// +build !linux
+
+package validate
+
+import "fmt"
+
+// Containers have no cgroups outside Linux, Windows containers are monitored through the HCS.
+func validateCgroupSetup() string {
+	return fmt.Sprintf(OutputFormat, "Cgroup setup", Supported, "Cgroups are only used on Linux, Windows containers are monitored through the Host Compute Service.\n")
+}
+
+// The exec and storage drivers of Docker only matter to the cgroups of its containers.
+func validateDockerSetup() string {
+	return ""
+}
+
+// The files read through /proc and the cgroups are only read on Linux.
+func securityProbes() []probe {
+	return nil
+}